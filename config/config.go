@@ -0,0 +1,377 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecommerce/models"
+	"ecommerce/utils"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is prepended to every environment variable read by Load
+const EnvPrefix = "ECOMMERCE_"
+
+// Config is the application configuration. Values are resolved in this order,
+// later sources overriding earlier ones: defaults, config file, environment, flags.
+type Config struct {
+	Server    ServerConfig    `yaml:"server" toml:"server" json:"server"`
+	Database  DatabaseConfig  `yaml:"database" toml:"database" json:"database"`
+	JWT       JWTConfig       `yaml:"jwt" toml:"jwt" json:"jwt"`
+	Inventory InventoryConfig `yaml:"inventory" toml:"inventory" json:"inventory"`
+	Payments  PaymentsConfig  `yaml:"payments" toml:"payments" json:"payments"`
+	Pricing   PricingConfig   `yaml:"pricing" toml:"pricing" json:"pricing"`
+	Tax       TaxConfig       `yaml:"tax" toml:"tax" json:"tax"`
+	Shipping  ShippingConfig  `yaml:"shipping" toml:"shipping" json:"shipping"`
+}
+
+type ServerConfig struct {
+	Addr         string   `yaml:"addr" toml:"addr" json:"addr"`
+	ReadTimeout  Duration `yaml:"read_timeout" toml:"read_timeout" json:"read_timeout"`
+	WriteTimeout Duration `yaml:"write_timeout" toml:"write_timeout" json:"write_timeout"`
+	IdleTimeout  Duration `yaml:"idle_timeout" toml:"idle_timeout" json:"idle_timeout"`
+}
+
+type DatabaseConfig struct {
+	DSN             string   `yaml:"dsn" toml:"dsn" json:"dsn"` // go-sql-driver/mysql format
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns" json:"max_open_conns"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns" json:"max_idle_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" json:"conn_max_lifetime"`
+}
+
+// JWTConfig takes its keys from exactly one of Keys, KeysFile or Secret
+type JWTConfig struct {
+	Issuer         string            `yaml:"issuer" toml:"issuer" json:"issuer"`
+	Audience       string            `yaml:"audience" toml:"audience" json:"audience"`
+	AccessTokenTTL Duration          `yaml:"access_token_ttl" toml:"access_token_ttl" json:"access_token_ttl"`
+	Secret         string            `yaml:"secret" toml:"secret" json:"secret"` // single HS256 key
+	KeysFile       string            `yaml:"keys_file" toml:"keys_file" json:"keys_file"`
+	Keys           []utils.KeyConfig `yaml:"keys" toml:"keys" json:"keys"`
+}
+
+type InventoryConfig struct {
+	// Allocation picks the warehouses stock is reserved from: priority, closest or highest_stock
+	Allocation string `yaml:"allocation" toml:"allocation" json:"allocation"`
+}
+
+type PaymentsConfig struct {
+	// Gateway names the payment provider, "fake" is the in-process one for development
+	Gateway string `yaml:"gateway" toml:"gateway" json:"gateway"`
+	// WebhookSecret signs the gateway's webhook calls, without it every call is rejected
+	WebhookSecret string `yaml:"webhook_secret" toml:"webhook_secret" json:"webhook_secret"`
+}
+
+type PricingConfig struct {
+	// Currency is the ISO 4217 code the catalogue is priced in
+	Currency string `yaml:"currency" toml:"currency" json:"currency"`
+	// Currencies the storefront also sells in, prices are converted into them
+	// unless a product has an explicit price there
+	Currencies []string `yaml:"currencies" toml:"currencies" json:"currencies"`
+	// Rounding applies to converted prices: half_up, half_even, half_down, down, up, floor or ceiling
+	Rounding string `yaml:"rounding" toml:"rounding" json:"rounding"`
+}
+
+type TaxConfig struct {
+	// Mode says whether catalogue prices exclude tax (exclusive) or contain it (inclusive)
+	Mode string `yaml:"mode" toml:"mode" json:"mode"`
+	// DefaultCountry is taxed when no destination is known, empty leaves such orders untaxed
+	DefaultCountry string `yaml:"default_country" toml:"default_country" json:"default_country"`
+	// Rounding applies to the tax of every line, with the same modes as pricing.rounding
+	Rounding string `yaml:"rounding" toml:"rounding" json:"rounding"`
+}
+
+type ShippingConfig struct {
+	// Providers quote the shipping options of a cart: table (the admin
+	// managed rate tables) and carrier_stub (a stand-in for a carrier API)
+	Providers []string `yaml:"providers" toml:"providers" json:"providers"`
+}
+
+// Duration accepts time.ParseDuration strings ("15m", "1h30m") in every file format
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:         ":8080",
+			ReadTimeout:  Duration(10 * time.Second),
+			WriteTimeout: Duration(10 * time.Second),
+			IdleTimeout:  Duration(time.Minute),
+		},
+		Database: DatabaseConfig{
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: Duration(5 * time.Minute),
+		},
+		JWT: JWTConfig{
+			Issuer:         "ecommerce",
+			Audience:       "ecommerce-api",
+			AccessTokenTTL: Duration(utils.AccessTokenTTL),
+		},
+		Inventory: InventoryConfig{
+			Allocation: "priority",
+		},
+		Payments: PaymentsConfig{
+			Gateway: "fake",
+		},
+		Pricing: PricingConfig{
+			Currency: "USD",
+			Rounding: "half_up",
+		},
+		Tax: TaxConfig{
+			Mode:     string(models.TaxExclusive),
+			Rounding: "half_up",
+		},
+		Shipping: ShippingConfig{
+			Providers: []string{"table"},
+		},
+	}
+}
+
+// Load builds the configuration from args (without the program name) and the
+// environment and validates all of it. The config file is named by -config or
+// ECOMMERCE_CONFIG.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	cfg, err := Read(args, getenv)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Read is Load without the validation, for commands that only use part of the
+// configuration and validate that part themselves
+func Read(args []string, getenv func(string) string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("ecommerce", flag.ContinueOnError)
+	configFile := fs.String("config", "", "path to a YAML, TOML or JSON config file")
+	addr := fs.String("addr", "", "HTTP listen address")
+	dsn := fs.String("db-dsn", "", "MySQL data source name")
+	keysFile := fs.String("jwt-keys-file", "", "JSON file with the JWT signing keys")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	path := *configFile
+	if path == "" {
+		path = getenv(EnvPrefix + "CONFIG")
+	}
+	if path != "" {
+		if err := loadFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(cfg, getenv); err != nil {
+		return nil, err
+	}
+
+	// only flags given on the command line override, defaults would otherwise win over the file
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Server.Addr = *addr
+		case "db-dsn":
+			cfg.Database.DSN = *dsn
+		case "jwt-keys-file":
+			cfg.JWT.KeysFile = *keysFile
+		}
+	})
+	return cfg, nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	case ".json":
+		err = json.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config file format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return nil
+}
+
+func applyEnv(cfg *Config, getenv func(string) string) error {
+	stringVars := map[string]*string{
+		"HTTP_ADDR":     &cfg.Server.Addr,
+		"DB_DSN":        &cfg.Database.DSN,
+		"JWT_ISSUER":    &cfg.JWT.Issuer,
+		"JWT_AUDIENCE":  &cfg.JWT.Audience,
+		"JWT_SECRET":    &cfg.JWT.Secret,
+		"JWT_KEYS_FILE": &cfg.JWT.KeysFile,
+
+		"INVENTORY_ALLOCATION":    &cfg.Inventory.Allocation,
+		"PAYMENTS_GATEWAY":        &cfg.Payments.Gateway,
+		"PAYMENTS_WEBHOOK_SECRET": &cfg.Payments.WebhookSecret,
+		"PRICING_CURRENCY":        &cfg.Pricing.Currency,
+		"PRICING_ROUNDING":        &cfg.Pricing.Rounding,
+		"TAX_MODE":                &cfg.Tax.Mode,
+		"TAX_DEFAULT_COUNTRY":     &cfg.Tax.DefaultCountry,
+		"TAX_ROUNDING":            &cfg.Tax.Rounding,
+	}
+	for name, target := range stringVars {
+		if value := getenv(EnvPrefix + name); value != "" {
+			*target = value
+		}
+	}
+
+	listVars := map[string]*[]string{
+		"PRICING_CURRENCIES": &cfg.Pricing.Currencies,
+		"SHIPPING_PROVIDERS": &cfg.Shipping.Providers,
+	}
+	for name, target := range listVars {
+		if value := getenv(EnvPrefix + name); value != "" {
+			*target = strings.Split(value, ",")
+			for i := range *target {
+				(*target)[i] = strings.TrimSpace((*target)[i])
+			}
+		}
+	}
+
+	intVars := map[string]*int{
+		"DB_MAX_OPEN_CONNS": &cfg.Database.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS": &cfg.Database.MaxIdleConns,
+	}
+	for name, target := range intVars {
+		if value := getenv(EnvPrefix + name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s%s: %v", EnvPrefix, name, err)
+			}
+			*target = n
+		}
+	}
+
+	durationVars := map[string]*Duration{
+		"HTTP_READ_TIMEOUT":    &cfg.Server.ReadTimeout,
+		"HTTP_WRITE_TIMEOUT":   &cfg.Server.WriteTimeout,
+		"HTTP_IDLE_TIMEOUT":    &cfg.Server.IdleTimeout,
+		"DB_CONN_MAX_LIFETIME": &cfg.Database.ConnMaxLifetime,
+		"JWT_ACCESS_TOKEN_TTL": &cfg.JWT.AccessTokenTTL,
+	}
+	for name, target := range durationVars {
+		if value := getenv(EnvPrefix + name); value != "" {
+			if err := target.UnmarshalText([]byte(value)); err != nil {
+				return fmt.Errorf("%s%s: %v", EnvPrefix, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate reports every missing or inconsistent value at once
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr is required"))
+	}
+	if err := c.Database.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.JWT.Issuer == "" || c.JWT.Audience == "" {
+		errs = append(errs, errors.New("jwt.issuer and jwt.audience are required"))
+	}
+	if c.JWT.AccessTokenTTL <= 0 {
+		errs = append(errs, errors.New("jwt.access_token_ttl must be positive"))
+	}
+	if !models.ValidCurrency(c.Pricing.Currency) {
+		errs = append(errs, fmt.Errorf("pricing.currency %q is not a supported currency", c.Pricing.Currency))
+	}
+	for _, currency := range c.Pricing.Currencies {
+		if !models.ValidCurrency(currency) {
+			errs = append(errs, fmt.Errorf("pricing.currencies: %q is not a supported currency", currency))
+		}
+	}
+	if _, err := models.ParseRoundingMode(c.Pricing.Rounding); err != nil {
+		errs = append(errs, fmt.Errorf("pricing.rounding: %v", err))
+	}
+	if _, err := models.ParseTaxMode(c.Tax.Mode); err != nil {
+		errs = append(errs, fmt.Errorf("tax.mode: %v", err))
+	}
+	if country := c.Tax.DefaultCountry; country != "" && !models.ValidCountry(country) {
+		errs = append(errs, fmt.Errorf("tax.default_country %q is not an ISO 3166-1 alpha-2 code", country))
+	}
+	if _, err := models.ParseRoundingMode(c.Tax.Rounding); err != nil {
+		errs = append(errs, fmt.Errorf("tax.rounding: %v", err))
+	}
+	if len(c.Shipping.Providers) == 0 {
+		errs = append(errs, errors.New("shipping.providers must name at least one provider"))
+	}
+
+	sources := 0
+	for _, set := range []bool{c.JWT.Secret != "", c.JWT.KeysFile != "", len(c.JWT.Keys) > 0} {
+		if set {
+			sources++
+		}
+	}
+	switch {
+	case sources == 0:
+		errs = append(errs, errors.New("one of jwt.secret, jwt.keys_file or jwt.keys is required"))
+	case sources > 1:
+		errs = append(errs, errors.New("only one of jwt.secret, jwt.keys_file or jwt.keys may be set"))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the database section on its own, which is all migrations need
+func (d DatabaseConfig) Validate() error {
+	var errs []error
+	if d.DSN == "" {
+		errs = append(errs, errors.New("database.dsn is required"))
+	}
+	if d.MaxOpenConns < 0 || d.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("database connection limits must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// KeyConfigs returns the JWT keys from whichever source is configured
+func (j JWTConfig) KeyConfigs() ([]utils.KeyConfig, error) {
+	switch {
+	case len(j.Keys) > 0:
+		return j.Keys, nil
+	case j.KeysFile != "":
+		return utils.LoadKeyConfigs(j.KeysFile)
+	default:
+		return []utils.KeyConfig{{ID: "default", Algorithm: "HS256", Secret: j.Secret, Active: true}}, nil
+	}
+}
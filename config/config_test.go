@@ -0,0 +1,184 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ecommerce/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// env returns a getenv func backed by a map
+func env(values map[string]string) func(string) string {
+	return func(name string) string { return values[name] }
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoadFileFormats(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+server:
+  addr: ":9000"
+  read_timeout: 5s
+database:
+  dsn: "user:pass@tcp(db:3306)/shop"
+jwt:
+  secret: "` + testSecret + `"
+`,
+		"config.toml": `
+[server]
+addr = ":9000"
+read_timeout = "5s"
+
+[database]
+dsn = "user:pass@tcp(db:3306)/shop"
+
+[jwt]
+secret = "` + testSecret + `"
+`,
+		"config.json": `{
+  "server": {"addr": ":9000", "read_timeout": "5s"},
+  "database": {"dsn": "user:pass@tcp(db:3306)/shop"},
+  "jwt": {"secret": "` + testSecret + `"}
+}`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := Load([]string{"-config", writeConfig(t, name, content)}, env(nil))
+			assert.NoError(t, err)
+			assert.Equal(t, ":9000", cfg.Server.Addr)
+			assert.Equal(t, 5*time.Second, cfg.Server.ReadTimeout.Std())
+			assert.Equal(t, 10*time.Second, cfg.Server.WriteTimeout.Std()) // default kept
+			assert.Equal(t, "user:pass@tcp(db:3306)/shop", cfg.Database.DSN)
+			assert.Equal(t, "ecommerce", cfg.JWT.Issuer)
+		})
+	}
+	t.Run("Unsupported format", func(t *testing.T) {
+		_, err := Load([]string{"-config", writeConfig(t, "config.ini", "")}, env(nil))
+		assert.Error(t, err)
+	})
+	t.Run("Missing file", func(t *testing.T) {
+		_, err := Load([]string{"-config", "/does/not/exist.yaml"}, env(nil))
+		assert.Error(t, err)
+	})
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+server:
+  addr: ":9000"
+database:
+  dsn: "file@tcp(db:3306)/shop"
+jwt:
+  secret: "`+testSecret+`"
+`)
+
+	t.Run("Env overrides file", func(t *testing.T) {
+		cfg, err := Load(nil, env(map[string]string{
+			"ECOMMERCE_CONFIG":                  path,
+			"ECOMMERCE_DB_DSN":                  "env@tcp(db:3306)/shop",
+			"ECOMMERCE_JWT_ACCESS_TOKEN_TTL":    "5m",
+			"ECOMMERCE_INVENTORY_ALLOCATION":    "closest",
+			"ECOMMERCE_PAYMENTS_WEBHOOK_SECRET": "whsec",
+			"ECOMMERCE_PRICING_CURRENCY":        "EUR",
+			"ECOMMERCE_PRICING_CURRENCIES":      "USD, GBP",
+			"ECOMMERCE_TAX_MODE":                "inclusive",
+			"ECOMMERCE_SHIPPING_PROVIDERS":      "table,carrier_stub",
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, ":9000", cfg.Server.Addr)
+		assert.Equal(t, "env@tcp(db:3306)/shop", cfg.Database.DSN)
+		assert.Equal(t, 5*time.Minute, cfg.JWT.AccessTokenTTL.Std())
+		assert.Equal(t, "closest", cfg.Inventory.Allocation)
+		assert.Equal(t, "whsec", cfg.Payments.WebhookSecret)
+		assert.Equal(t, "EUR", cfg.Pricing.Currency)
+		assert.Equal(t, []string{"USD", "GBP"}, cfg.Pricing.Currencies)
+		assert.Equal(t, "inclusive", cfg.Tax.Mode)
+		assert.Equal(t, []string{"table", "carrier_stub"}, cfg.Shipping.Providers)
+	})
+	t.Run("Flags override env", func(t *testing.T) {
+		cfg, err := Load([]string{"-config", path, "-addr", ":7000", "-db-dsn", "flag@tcp(db:3306)/shop"}, env(map[string]string{
+			"ECOMMERCE_HTTP_ADDR": ":8000",
+			"ECOMMERCE_DB_DSN":    "env@tcp(db:3306)/shop",
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, ":7000", cfg.Server.Addr)
+		assert.Equal(t, "flag@tcp(db:3306)/shop", cfg.Database.DSN)
+	})
+	t.Run("Invalid env value", func(t *testing.T) {
+		_, err := Load([]string{"-config", path}, env(map[string]string{"ECOMMERCE_DB_MAX_OPEN_CONNS": "many"}))
+		assert.Error(t, err)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		cfg := Default()
+		cfg.Database.DSN = "user:pass@tcp(db:3306)/shop"
+		cfg.JWT.Secret = testSecret
+		return cfg
+	}
+	assert.NoError(t, valid().Validate())
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+	}{
+		{"Missing DSN", func(c *Config) { c.Database.DSN = "" }},
+		{"Missing addr", func(c *Config) { c.Server.Addr = "" }},
+		{"Missing issuer", func(c *Config) { c.JWT.Issuer = "" }},
+		{"No JWT keys", func(c *Config) { c.JWT.Secret = "" }},
+		{"Two key sources", func(c *Config) { c.JWT.KeysFile = "keys.json" }},
+		{"Negative pool size", func(c *Config) { c.Database.MaxOpenConns = -1 }},
+		{"Zero token TTL", func(c *Config) { c.JWT.AccessTokenTTL = 0 }},
+		{"Unknown currency", func(c *Config) { c.Pricing.Currency = "XYZ" }},
+		{"Unknown extra currency", func(c *Config) { c.Pricing.Currencies = []string{"EUR", "eur"} }},
+		{"Unknown rounding", func(c *Config) { c.Pricing.Rounding = "bankers" }},
+		{"Unknown tax mode", func(c *Config) { c.Tax.Mode = "included" }},
+		{"Invalid tax country", func(c *Config) { c.Tax.DefaultCountry = "USA" }},
+		{"No shipping providers", func(c *Config) { c.Shipping.Providers = nil }},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := valid()
+			tc.modify(cfg)
+			assert.Error(t, cfg.Validate())
+		})
+	}
+}
+
+func TestReadDatabaseOnly(t *testing.T) {
+	env := map[string]string{"ECOMMERCE_DB_DSN": "user:pass@tcp(db:3306)/shop", "ECOMMERCE_PRICING_CURRENCY": "XYZ"}
+	getenv := func(name string) string { return env[name] }
+
+	_, err := Load(nil, getenv)
+	assert.Error(t, err) // no JWT keys, unknown currency
+
+	cfg, err := Read(nil, getenv)
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.Database.Validate())
+
+	cfg.Database.DSN = ""
+	assert.Error(t, cfg.Database.Validate())
+}
+
+func TestKeyConfigs(t *testing.T) {
+	keys, err := JWTConfig{Secret: testSecret}.KeyConfigs()
+	assert.NoError(t, err)
+	assert.Equal(t, []utils.KeyConfig{{ID: "default", Algorithm: "HS256", Secret: testSecret, Active: true}}, keys)
+
+	inline := []utils.KeyConfig{{ID: "k1", Algorithm: "HS256", Secret: testSecret, Active: true}}
+	keys, err = JWTConfig{Keys: inline}.KeyConfigs()
+	assert.NoError(t, err)
+	assert.Equal(t, inline, keys)
+}
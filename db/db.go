@@ -2,30 +2,33 @@ package db
 
 import (
 	"database/sql" // Provides an interface for database operations
+	"ecommerce/config"
 	"fmt"
 	"log"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
-var DB *sql.DB // database connection
-
-func ConnectDb() {
-	dsn := "root:root@123@tcp(127.0.0.1:3306)/ecommerce"
-	var err error
-	DB, err = sql.Open("mysql", dsn) //  initializes a database connection (only validates arguments)
-
+// Connect opens the connection pool described by cfg and verifies it with a ping
+func Connect(cfg config.DatabaseConfig) (*sql.DB, error) {
+	dsn, err := mysql.ParseDSN(cfg.DSN)
 	if err != nil {
-		log.Fatal("Error connting to databse: ", err) // Logs error message  and exits program
+		return nil, fmt.Errorf("invalid database dsn: %v", err)
 	}
+	dsn.ParseTime = true // repositories scan DATETIME columns into time.Time
 
-	err = DB.Ping() // verifies the connection
+	db, err := sql.Open("mysql", dsn.FormatDSN()) //  initializes a database connection (only validates arguments)
 	if err != nil {
-		log.Fatal("Database connection failed", err)
+		return nil, fmt.Errorf("error connecting to database: %v", err)
 	}
-	fmt.Println("Database connected successfully!")
-}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime.Std())
 
-func GetDb() *sql.DB {
-	return DB
+	if err := db.Ping(); err != nil { // verifies the connection
+		db.Close()
+		return nil, fmt.Errorf("database connection failed: %v", err)
+	}
+	log.Printf("Database connected successfully! (%s@%s/%s)", dsn.User, dsn.Addr, dsn.DBName)
+	return db, nil
 }
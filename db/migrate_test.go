@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(Migrations, "migrations")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		assert.Equal(t, int64(i+1), m.Version, "versions must be contiguous")
+		assert.NotEmpty(t, SplitStatements(m.Up), m.Name)
+		assert.NotEmpty(t, SplitStatements(m.Down), m.Name)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("Sorted with checksums", func(t *testing.T) {
+		migrations, err := LoadMigrations(fstest.MapFS{
+			"m/0002_b.up.sql":   {Data: []byte("create table b (id int);")},
+			"m/0001_a.up.sql":   {Data: []byte("create table a (id int);")},
+			"m/0001_a.down.sql": {Data: []byte("drop table a;")},
+		}, "m")
+		assert.NoError(t, err)
+		assert.Len(t, migrations, 2)
+		assert.Equal(t, "a", migrations[0].Name)
+		assert.Equal(t, "drop table a;", migrations[0].Down)
+		assert.Equal(t, "", migrations[1].Down)
+		assert.Len(t, migrations[0].Checksum, 64)
+	})
+	t.Run("Bad file name", func(t *testing.T) {
+		_, err := LoadMigrations(fstest.MapFS{"m/create_a.sql": {Data: []byte("select 1;")}}, "m")
+		assert.Error(t, err)
+	})
+	t.Run("Duplicate version", func(t *testing.T) {
+		_, err := LoadMigrations(fstest.MapFS{
+			"m/0001_a.up.sql": {Data: []byte("select 1;")},
+			"m/0001_b.up.sql": {Data: []byte("select 1;")},
+		}, "m")
+		assert.Error(t, err)
+	})
+	t.Run("Missing up", func(t *testing.T) {
+		_, err := LoadMigrations(fstest.MapFS{"m/0001_a.down.sql": {Data: []byte("drop table a;")}}, "m")
+		assert.Error(t, err)
+	})
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- leading comment; not a statement
+CREATE TABLE a (note VARCHAR(10) DEFAULT 'x;y'); /* block; comment */
+INSERT INTO a VALUES ("it\"s;"); # trailing
+`
+	assert.Equal(t, []string{
+		"CREATE TABLE a (note VARCHAR(10) DEFAULT 'x;y')",
+		`INSERT INTO a VALUES ("it\"s;")`,
+	}, SplitStatements(script))
+}
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "a", Up: "create table a (id int);", Down: "drop table a;", Checksum: "sum-a"},
+	{Version: 2, Name: "b", Up: "create table b (id int);", Down: "drop table b;", Checksum: "sum-b"},
+}
+
+func expectLock(mock sqlmock.Sqlmock, acquired int) {
+	mock.ExpectQuery(regexp.QuoteMeta("select get_lock(?, ?)")).
+		WithArgs(migrationLock, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(acquired))
+}
+
+func appliedRows(rows ...[]driver.Value) *sqlmock.Rows {
+	result := sqlmock.NewRows([]string{"version", "checksum", "applied_at"})
+	for _, row := range rows {
+		result.AddRow(row...)
+	}
+	return result
+}
+
+func TestMigratorUp(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer database.Close()
+	migrator := NewMigrator(database, testMigrations)
+
+	t.Run("Applies pending", func(t *testing.T) {
+		expectLock(mock, 1)
+		mock.ExpectExec("create table if not exists schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("select version, checksum, applied_at from schema_migrations").
+			WillReturnRows(appliedRows([]driver.Value{1, "sum-a", time.Now()}))
+		mock.ExpectExec(regexp.QuoteMeta("create table b (id int)")).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("insert into schema_migrations").
+			WithArgs(int64(2), "b", "sum-b", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("select release_lock(?)")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		applied, err := migrator.Up(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, applied, 1)
+		assert.Equal(t, int64(2), applied[0].Version)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Checksum mismatch", func(t *testing.T) {
+		expectLock(mock, 1)
+		mock.ExpectExec("create table if not exists schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("select version, checksum, applied_at from schema_migrations").
+			WillReturnRows(appliedRows([]driver.Value{1, "edited", time.Now()}))
+		mock.ExpectExec(regexp.QuoteMeta("select release_lock(?)")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		applied, err := migrator.Up(context.Background())
+		assert.ErrorContains(t, err, "checksum mismatch")
+		assert.Empty(t, applied)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Locked", func(t *testing.T) {
+		expectLock(mock, 0)
+
+		_, err := migrator.Up(context.Background())
+		assert.Equal(t, ErrMigrationLocked, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestMigratorDown(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer database.Close()
+	migrator := NewMigrator(database, testMigrations)
+
+	expectLock(mock, 1)
+	mock.ExpectExec("create table if not exists schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("select version, checksum, applied_at from schema_migrations").
+		WillReturnRows(appliedRows([]driver.Value{1, "sum-a", time.Now()}, []driver.Value{2, "sum-b", time.Now()}))
+	mock.ExpectExec(regexp.QuoteMeta("drop table b")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("delete from schema_migrations where version=?")).
+		WithArgs(int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("select release_lock(?)")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	reverted, err := migrator.Down(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Len(t, reverted, 1)
+	assert.Equal(t, "b", reverted[0].Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorStatus(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer database.Close()
+	migrator := NewMigrator(database, testMigrations)
+
+	mock.ExpectExec("create table if not exists schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("select version, checksum, applied_at from schema_migrations").
+		WillReturnRows(appliedRows([]driver.Value{1, "edited", time.Now()}, []driver.Value{7, "sum-x", time.Now()}))
+
+	statuses, err := migrator.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 3)
+	assert.True(t, statuses[0].Modified)
+	assert.Nil(t, statuses[1].AppliedAt) // pending
+	assert.True(t, statuses[2].Missing)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "0003_existing.up.sql"), []byte("select 1;"), 0644))
+
+	up, down, err := CreateMigration(dir, "Add Orders Table")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0004_add_orders_table.up.sql"), up)
+	assert.Equal(t, filepath.Join(dir, "0004_add_orders_table.down.sql"), down)
+
+	_, _, err = CreateMigration(dir, "  ")
+	assert.Error(t, err)
+}
@@ -0,0 +1,12 @@
+CREATE TABLE refresh_tokens (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    user_id    INT         NOT NULL,
+    token_hash CHAR(64)    NOT NULL,
+    family_id  VARCHAR(64) NOT NULL,
+    expires_at DATETIME    NOT NULL,
+    revoked_at DATETIME    NULL,
+    created_at DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_refresh_tokens_hash (token_hash),
+    KEY idx_refresh_tokens_family (family_id),
+    CONSTRAINT fk_refresh_tokens_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,6 @@
+-- access tokens revoked before their expiry, keyed by jti
+CREATE TABLE revoked_tokens (
+    jti        VARCHAR(64) PRIMARY KEY,
+    expires_at DATETIME    NOT NULL,
+    KEY idx_revoked_tokens_expires (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
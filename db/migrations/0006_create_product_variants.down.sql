@@ -0,0 +1,4 @@
+DROP TABLE variant_option_values;
+DROP TABLE product_variants;
+DROP TABLE option_values;
+DROP TABLE option_types;
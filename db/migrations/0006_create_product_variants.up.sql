@@ -0,0 +1,35 @@
+-- option types are shared by all products, e.g. size with S/M/L and color with red/blue
+CREATE TABLE option_types (
+    id   INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    UNIQUE KEY uq_option_types_name (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE option_values (
+    id             INT AUTO_INCREMENT PRIMARY KEY,
+    option_type_id INT NOT NULL,
+    value          VARCHAR(50) NOT NULL,
+    UNIQUE KEY uq_option_values (option_type_id, value),
+    CONSTRAINT fk_option_values_type FOREIGN KEY (option_type_id) REFERENCES option_types (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE product_variants (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    product_id INT            NOT NULL,
+    sku        VARCHAR(64)    NOT NULL,
+    price      DECIMAL(12, 2) NULL, -- NULL uses the product price
+    barcode    VARCHAR(64)    NULL,
+    stock      INT            NOT NULL DEFAULT 0,
+    created_at DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_product_variants_sku (sku),
+    UNIQUE KEY uq_product_variants_barcode (barcode),
+    CONSTRAINT fk_product_variants_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE variant_option_values (
+    variant_id      INT NOT NULL,
+    option_value_id INT NOT NULL,
+    PRIMARY KEY (variant_id, option_value_id),
+    CONSTRAINT fk_variant_option_values_variant FOREIGN KEY (variant_id) REFERENCES product_variants (id) ON DELETE CASCADE,
+    CONSTRAINT fk_variant_option_values_value FOREIGN KEY (option_value_id) REFERENCES option_values (id) ON DELETE RESTRICT
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
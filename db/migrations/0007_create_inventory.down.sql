@@ -0,0 +1,7 @@
+ALTER TABLE product_variants ADD COLUMN stock INT NOT NULL DEFAULT 0 AFTER barcode;
+
+UPDATE product_variants v JOIN inventory_items i ON i.variant_id = v.id SET v.stock = i.on_hand;
+
+DROP TABLE inventory_adjustments;
+DROP TABLE inventory_reservations;
+DROP TABLE inventory_items;
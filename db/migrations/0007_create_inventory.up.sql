@@ -0,0 +1,50 @@
+-- stock of a product, or of one of its variants when variant_id is set
+CREATE TABLE inventory_items (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    product_id INT      NOT NULL,
+    variant_id INT      NULL,
+    on_hand    INT      NOT NULL DEFAULT 0,
+    reserved   INT      NOT NULL DEFAULT 0, -- held for orders, part of on_hand
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_inventory_items (product_id, variant_id),
+    CONSTRAINT chk_inventory_items_quantities CHECK (reserved >= 0 AND reserved <= on_hand),
+    CONSTRAINT fk_inventory_items_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+    CONSTRAINT fk_inventory_items_variant FOREIGN KEY (variant_id) REFERENCES product_variants (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE inventory_reservations (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    reference  VARCHAR(64) NOT NULL, -- what the stock is held for, e.g. an order
+    item_id    INT         NOT NULL,
+    quantity   INT         NOT NULL,
+    status     VARCHAR(16) NOT NULL DEFAULT 'active',
+    created_at DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    KEY idx_inventory_reservations_reference (reference, status),
+    CONSTRAINT fk_inventory_reservations_item FOREIGN KEY (item_id) REFERENCES inventory_items (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- append only ledger of every on_hand change
+CREATE TABLE inventory_adjustments (
+    id            INT AUTO_INCREMENT PRIMARY KEY,
+    item_id       INT          NOT NULL,
+    delta         INT          NOT NULL,
+    on_hand_after INT          NOT NULL,
+    reason        VARCHAR(32)  NOT NULL,
+    reference     VARCHAR(64)  NULL,
+    note          VARCHAR(255) NULL,
+    created_by    INT          NULL,
+    created_at    DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_inventory_adjustments_item (item_id, id),
+    CONSTRAINT fk_inventory_adjustments_item FOREIGN KEY (item_id) REFERENCES inventory_items (id) ON DELETE CASCADE,
+    CONSTRAINT fk_inventory_adjustments_user FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- variant stock moves into the inventory tables
+INSERT INTO inventory_items (product_id, variant_id, on_hand)
+SELECT product_id, id, stock FROM product_variants WHERE stock > 0;
+
+INSERT INTO inventory_adjustments (item_id, delta, on_hand_after, reason, note)
+SELECT id, on_hand, on_hand, 'initial', 'product_variants.stock' FROM inventory_items;
+
+ALTER TABLE product_variants DROP COLUMN stock;
@@ -0,0 +1,31 @@
+DROP TABLE warehouse_transfers;
+
+-- fold the stock of every warehouse into the oldest item of each product or variant
+CREATE TEMPORARY TABLE inventory_merge AS
+SELECT i.id AS item_id, k.keep_id
+FROM inventory_items i
+JOIN (SELECT product_id, variant_id, MIN(id) AS keep_id FROM inventory_items GROUP BY product_id, variant_id) k
+    ON k.product_id = i.product_id AND k.variant_id <=> i.variant_id;
+
+UPDATE inventory_items keep
+JOIN (SELECT m.keep_id, SUM(i.on_hand) AS on_hand, SUM(i.reserved) AS reserved
+      FROM inventory_merge m JOIN inventory_items i ON i.id = m.item_id GROUP BY m.keep_id) totals
+    ON totals.keep_id = keep.id
+SET keep.on_hand = totals.on_hand, keep.reserved = totals.reserved;
+
+UPDATE inventory_reservations r JOIN inventory_merge m ON m.item_id = r.item_id SET r.item_id = m.keep_id;
+
+UPDATE inventory_adjustments a JOIN inventory_merge m ON m.item_id = a.item_id SET a.item_id = m.keep_id;
+
+DELETE i FROM inventory_items i JOIN inventory_merge m ON m.item_id = i.id WHERE m.item_id <> m.keep_id;
+
+DROP TEMPORARY TABLE inventory_merge;
+
+ALTER TABLE inventory_items
+    DROP FOREIGN KEY fk_inventory_items_warehouse,
+    DROP INDEX uq_inventory_items,
+    ADD UNIQUE KEY uq_inventory_items (product_id, variant_id);
+
+ALTER TABLE inventory_items DROP INDEX idx_inventory_items_product, DROP COLUMN warehouse_id;
+
+DROP TABLE warehouses;
@@ -0,0 +1,44 @@
+CREATE TABLE warehouses (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    code       VARCHAR(32)   NOT NULL,
+    name       VARCHAR(100)  NOT NULL,
+    latitude   DECIMAL(9, 6) NULL,
+    longitude  DECIMAL(9, 6) NULL,
+    priority   INT           NOT NULL DEFAULT 0, -- lower ships first with the priority strategy
+    active     BOOLEAN       NOT NULL DEFAULT TRUE,
+    created_at DATETIME      NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_warehouses_code (code)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- existing stock is assumed to sit in a single warehouse
+INSERT INTO warehouses (code, name) VALUES ('main', 'Main warehouse');
+
+ALTER TABLE inventory_items ADD COLUMN warehouse_id INT NULL AFTER id;
+
+UPDATE inventory_items SET warehouse_id = (SELECT id FROM warehouses WHERE code = 'main');
+
+-- the product foreign key needs an index of its own once the unique key starts with warehouse_id
+ALTER TABLE inventory_items ADD KEY idx_inventory_items_product (product_id, variant_id);
+
+ALTER TABLE inventory_items
+    MODIFY warehouse_id INT NOT NULL,
+    DROP INDEX uq_inventory_items,
+    ADD UNIQUE KEY uq_inventory_items (warehouse_id, product_id, variant_id),
+    ADD CONSTRAINT fk_inventory_items_warehouse FOREIGN KEY (warehouse_id) REFERENCES warehouses (id) ON DELETE RESTRICT;
+
+CREATE TABLE warehouse_transfers (
+    id                INT AUTO_INCREMENT PRIMARY KEY,
+    product_id        INT          NOT NULL,
+    variant_id        INT          NULL,
+    from_warehouse_id INT          NOT NULL,
+    to_warehouse_id   INT          NOT NULL,
+    quantity          INT          NOT NULL,
+    note              VARCHAR(255) NULL,
+    created_by        INT          NULL,
+    created_at        DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_warehouse_transfers_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+    CONSTRAINT fk_warehouse_transfers_variant FOREIGN KEY (variant_id) REFERENCES product_variants (id) ON DELETE CASCADE,
+    CONSTRAINT fk_warehouse_transfers_from FOREIGN KEY (from_warehouse_id) REFERENCES warehouses (id) ON DELETE RESTRICT,
+    CONSTRAINT fk_warehouse_transfers_to FOREIGN KEY (to_warehouse_id) REFERENCES warehouses (id) ON DELETE RESTRICT,
+    CONSTRAINT fk_warehouse_transfers_user FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,29 @@
+-- a cart belongs to a user or, before login, to whoever holds the guest token
+CREATE TABLE carts (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    user_id    INT      NULL,
+    token_hash CHAR(64) NULL, -- sha256 of the guest cart token
+    expires_at DATETIME NULL, -- guest carts only
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_carts_user (user_id),
+    UNIQUE KEY uq_carts_token (token_hash),
+    KEY idx_carts_expires (expires_at),
+    CONSTRAINT chk_carts_owner CHECK (user_id IS NOT NULL OR token_hash IS NOT NULL),
+    CONSTRAINT fk_carts_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE cart_items (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    cart_id    INT            NOT NULL,
+    product_id INT            NOT NULL,
+    variant_id INT            NULL,
+    quantity   INT            NOT NULL,
+    unit_price DECIMAL(12, 2) NOT NULL, -- price when the line was last changed, to spot price changes
+    created_at DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_cart_items_cart (cart_id),
+    CONSTRAINT chk_cart_items_quantity CHECK (quantity > 0),
+    CONSTRAINT fk_cart_items_cart FOREIGN KEY (cart_id) REFERENCES carts (id) ON DELETE CASCADE,
+    CONSTRAINT fk_cart_items_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+    CONSTRAINT fk_cart_items_variant FOREIGN KEY (variant_id) REFERENCES product_variants (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,41 @@
+CREATE TABLE orders (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    user_id    INT            NULL, -- NULL once the customer's account is deleted
+    status     VARCHAR(16)    NOT NULL DEFAULT 'pending',
+    subtotal   DECIMAL(12, 2) NOT NULL,
+    created_at DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    KEY idx_orders_user (user_id, created_at),
+    KEY idx_orders_status (status, created_at),
+    CONSTRAINT fk_orders_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- lines keep a snapshot of what was bought, they outlive changes to the catalogue
+CREATE TABLE order_items (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    order_id   INT            NOT NULL,
+    product_id INT            NULL,
+    variant_id INT            NULL,
+    sku        VARCHAR(64)    NULL,
+    name       VARCHAR(255)   NOT NULL,
+    quantity   INT            NOT NULL,
+    unit_price DECIMAL(12, 2) NOT NULL,
+    CONSTRAINT chk_order_items_quantity CHECK (quantity > 0),
+    CONSTRAINT fk_order_items_order FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE,
+    CONSTRAINT fk_order_items_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE SET NULL,
+    CONSTRAINT fk_order_items_variant FOREIGN KEY (variant_id) REFERENCES product_variants (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- append only log of every status change
+CREATE TABLE order_status_history (
+    id          INT AUTO_INCREMENT PRIMARY KEY,
+    order_id    INT          NOT NULL,
+    from_status VARCHAR(16)  NULL, -- NULL for the order being placed
+    to_status   VARCHAR(16)  NOT NULL,
+    note        VARCHAR(255) NULL,
+    changed_by  INT          NULL,
+    created_at  DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_order_status_history_order (order_id),
+    CONSTRAINT fk_order_status_history_order FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE,
+    CONSTRAINT fk_order_status_history_user FOREIGN KEY (changed_by) REFERENCES users (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
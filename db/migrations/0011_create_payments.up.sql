@@ -0,0 +1,25 @@
+CREATE TABLE payments (
+    id              INT AUTO_INCREMENT PRIMARY KEY,
+    order_id        INT            NOT NULL,
+    provider        VARCHAR(32)    NOT NULL,
+    reference       VARCHAR(128)   NOT NULL, -- the gateway's id of the payment
+    status          VARCHAR(24)    NOT NULL,
+    amount          DECIMAL(12, 2) NOT NULL,
+    refunded_amount DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    failure_reason  VARCHAR(255)   NULL,
+    created_at      DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at      DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_payments_reference (provider, reference),
+    KEY idx_payments_order (order_id),
+    CONSTRAINT chk_payments_refunded CHECK (refunded_amount >= 0 AND refunded_amount <= amount),
+    CONSTRAINT fk_payments_order FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE RESTRICT
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- webhook events already handled, gateways deliver at least once
+CREATE TABLE payment_events (
+    provider    VARCHAR(32)  NOT NULL,
+    event_id    VARCHAR(128) NOT NULL,
+    type        VARCHAR(64)  NOT NULL,
+    received_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, event_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
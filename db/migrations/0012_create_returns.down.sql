@@ -0,0 +1,4 @@
+DROP TABLE return_items;
+DROP TABLE returns;
+
+ALTER TABLE orders DROP COLUMN refunded_amount;
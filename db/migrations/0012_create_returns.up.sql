@@ -0,0 +1,31 @@
+-- what has been given back through the payment gateway, across all payments and returns
+ALTER TABLE orders ADD COLUMN refunded_amount DECIMAL(12, 2) NOT NULL DEFAULT 0 AFTER subtotal;
+
+CREATE TABLE returns (
+    id            INT AUTO_INCREMENT PRIMARY KEY,
+    order_id      INT            NOT NULL,
+    user_id       INT            NULL, -- NULL once the customer's account is deleted
+    status        VARCHAR(16)    NOT NULL DEFAULT 'requested',
+    reason        VARCHAR(255)   NOT NULL,
+    note          VARCHAR(255)   NULL, -- left by staff when handling the return
+    refund_amount DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    created_at    DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at    DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    KEY idx_returns_order (order_id),
+    KEY idx_returns_user (user_id, created_at),
+    KEY idx_returns_status (status, created_at),
+    CONSTRAINT fk_returns_order FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE,
+    CONSTRAINT fk_returns_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE return_items (
+    id            INT AUTO_INCREMENT PRIMARY KEY,
+    return_id     INT     NOT NULL,
+    order_item_id INT     NOT NULL,
+    quantity      INT     NOT NULL,
+    restocked     BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE KEY uq_return_items (return_id, order_item_id),
+    CONSTRAINT chk_return_items_quantity CHECK (quantity > 0),
+    CONSTRAINT fk_return_items_return FOREIGN KEY (return_id) REFERENCES returns (id) ON DELETE CASCADE,
+    CONSTRAINT fk_return_items_order_item FOREIGN KEY (order_item_id) REFERENCES order_items (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
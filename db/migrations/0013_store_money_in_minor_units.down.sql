@@ -0,0 +1,38 @@
+ALTER TABLE returns MODIFY refund_amount DECIMAL(16, 2) NOT NULL DEFAULT 0, DROP COLUMN currency;
+UPDATE returns SET refund_amount = refund_amount / 100;
+ALTER TABLE returns MODIFY refund_amount DECIMAL(12, 2) NOT NULL DEFAULT 0;
+
+ALTER TABLE payments
+    MODIFY amount DECIMAL(16, 2) NOT NULL,
+    MODIFY refunded_amount DECIMAL(16, 2) NOT NULL DEFAULT 0,
+    DROP COLUMN currency;
+UPDATE payments SET amount = amount / 100, refunded_amount = refunded_amount / 100;
+ALTER TABLE payments
+    MODIFY amount DECIMAL(12, 2) NOT NULL,
+    MODIFY refunded_amount DECIMAL(12, 2) NOT NULL DEFAULT 0;
+
+ALTER TABLE order_items MODIFY unit_price DECIMAL(16, 2) NOT NULL;
+UPDATE order_items SET unit_price = unit_price / 100;
+ALTER TABLE order_items MODIFY unit_price DECIMAL(12, 2) NOT NULL;
+
+ALTER TABLE orders
+    MODIFY subtotal DECIMAL(16, 2) NOT NULL,
+    MODIFY refunded_amount DECIMAL(16, 2) NOT NULL DEFAULT 0,
+    DROP COLUMN currency;
+UPDATE orders SET subtotal = subtotal / 100, refunded_amount = refunded_amount / 100;
+ALTER TABLE orders
+    MODIFY subtotal DECIMAL(12, 2) NOT NULL,
+    MODIFY refunded_amount DECIMAL(12, 2) NOT NULL DEFAULT 0;
+
+ALTER TABLE cart_items MODIFY unit_price DECIMAL(16, 2) NOT NULL;
+UPDATE cart_items SET unit_price = unit_price / 100;
+ALTER TABLE cart_items MODIFY unit_price DECIMAL(12, 2) NOT NULL;
+ALTER TABLE carts DROP COLUMN currency;
+
+ALTER TABLE product_variants MODIFY price DECIMAL(16, 2) NULL, DROP COLUMN currency;
+UPDATE product_variants SET price = price / 100 WHERE price IS NOT NULL;
+ALTER TABLE product_variants MODIFY price DECIMAL(12, 2) NULL;
+
+ALTER TABLE products MODIFY price DECIMAL(16, 2) NOT NULL, DROP COLUMN currency;
+UPDATE products SET price = price / 100;
+ALTER TABLE products MODIFY price DECIMAL(12, 2) NOT NULL;
@@ -0,0 +1,48 @@
+-- money is stored as a whole number of the currency's minor unit (cents for
+-- USD) next to its ISO 4217 code. Amounts so far were all in USD.
+ALTER TABLE products
+    MODIFY price DECIMAL(16, 2) NOT NULL,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' AFTER price;
+UPDATE products SET price = price * 100;
+ALTER TABLE products MODIFY price BIGINT NOT NULL;
+
+ALTER TABLE product_variants
+    MODIFY price DECIMAL(16, 2) NULL,
+    ADD COLUMN currency CHAR(3) NULL AFTER price; -- set together with price
+UPDATE product_variants SET price = price * 100, currency = 'USD' WHERE price IS NOT NULL;
+ALTER TABLE product_variants MODIFY price BIGINT NULL;
+
+-- cart lines are priced in the cart's currency
+ALTER TABLE carts ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' AFTER token_hash;
+ALTER TABLE cart_items MODIFY unit_price DECIMAL(16, 2) NOT NULL;
+UPDATE cart_items SET unit_price = unit_price * 100;
+ALTER TABLE cart_items MODIFY unit_price BIGINT NOT NULL;
+
+-- order lines and returns are in the order's currency
+ALTER TABLE orders
+    MODIFY subtotal DECIMAL(16, 2) NOT NULL,
+    MODIFY refunded_amount DECIMAL(16, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' AFTER status;
+UPDATE orders SET subtotal = subtotal * 100, refunded_amount = refunded_amount * 100;
+ALTER TABLE orders
+    MODIFY subtotal BIGINT NOT NULL,
+    MODIFY refunded_amount BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE order_items MODIFY unit_price DECIMAL(16, 2) NOT NULL;
+UPDATE order_items SET unit_price = unit_price * 100;
+ALTER TABLE order_items MODIFY unit_price BIGINT NOT NULL;
+
+ALTER TABLE payments
+    MODIFY amount DECIMAL(16, 2) NOT NULL,
+    MODIFY refunded_amount DECIMAL(16, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' AFTER status;
+UPDATE payments SET amount = amount * 100, refunded_amount = refunded_amount * 100;
+ALTER TABLE payments
+    MODIFY amount BIGINT NOT NULL,
+    MODIFY refunded_amount BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE returns
+    MODIFY refund_amount DECIMAL(16, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' AFTER note;
+UPDATE returns SET refund_amount = refund_amount * 100;
+ALTER TABLE returns MODIFY refund_amount BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,28 @@
+-- explicit prices of a product in currencies other than its own, products
+-- without one are converted at the exchange rate in effect
+CREATE TABLE product_prices (
+    product_id INT      NOT NULL,
+    currency   CHAR(3)  NOT NULL,
+    amount     BIGINT   NOT NULL, -- minor units of currency
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (product_id, currency),
+    KEY idx_product_prices_currency (currency),
+    CONSTRAINT chk_product_prices_amount CHECK (amount > 0),
+    CONSTRAINT fk_product_prices_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- one unit of base buys rate units of quote from effective_at on, rows are
+-- never updated so past prices can be explained
+CREATE TABLE exchange_rates (
+    id           INT AUTO_INCREMENT PRIMARY KEY,
+    base         CHAR(3)        NOT NULL,
+    quote        CHAR(3)        NOT NULL,
+    rate         DECIMAL(18, 8) NOT NULL,
+    effective_at DATETIME       NOT NULL,
+    created_by   INT            NULL,
+    created_at   DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_exchange_rates_pair (base, quote, effective_at),
+    CONSTRAINT chk_exchange_rates_rate CHECK (rate > 0),
+    CONSTRAINT chk_exchange_rates_pair CHECK (base <> quote),
+    CONSTRAINT fk_exchange_rates_created_by FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,6 @@
+DROP TABLE order_discounts;
+ALTER TABLE orders DROP COLUMN free_shipping, DROP COLUMN discount;
+DROP TABLE promotion_redemptions;
+DROP TABLE promotion_categories;
+DROP TABLE promotion_products;
+DROP TABLE promotions;
@@ -0,0 +1,78 @@
+-- discount rules, with a code they are coupons the customer has to enter,
+-- without one they apply to every cart that qualifies
+CREATE TABLE promotions (
+    id                 INT AUTO_INCREMENT PRIMARY KEY,
+    name               VARCHAR(100) NOT NULL,
+    code               VARCHAR(64)  NULL, -- upper case
+    kind               VARCHAR(16)  NOT NULL,
+    percent            INT          NOT NULL DEFAULT 0,
+    amount             BIGINT       NOT NULL DEFAULT 0, -- minor units of currency
+    currency           CHAR(3)      NOT NULL,           -- of amount and min_subtotal
+    buy_quantity       INT          NOT NULL DEFAULT 0,
+    get_quantity       INT          NOT NULL DEFAULT 0,
+    min_subtotal       BIGINT       NOT NULL DEFAULT 0,
+    starts_at          DATETIME     NULL,
+    ends_at            DATETIME     NULL,
+    usage_limit        INT          NOT NULL DEFAULT 0, -- 0 for no limit
+    per_customer_limit INT          NOT NULL DEFAULT 0,
+    usage_count        INT          NOT NULL DEFAULT 0,
+    stackable          BOOLEAN      NOT NULL DEFAULT TRUE,
+    priority           INT          NOT NULL DEFAULT 0,
+    active             BOOLEAN      NOT NULL DEFAULT TRUE,
+    created_at         DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at         DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_promotions_code (code),
+    KEY idx_promotions_active (active, priority),
+    CONSTRAINT chk_promotions_percent CHECK (percent BETWEEN 0 AND 100)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- the lines a promotion applies to, a promotion with neither applies to all
+CREATE TABLE promotion_products (
+    promotion_id INT NOT NULL,
+    product_id   INT NOT NULL,
+    PRIMARY KEY (promotion_id, product_id),
+    CONSTRAINT fk_promotion_products_promotion FOREIGN KEY (promotion_id) REFERENCES promotions (id) ON DELETE CASCADE,
+    CONSTRAINT fk_promotion_products_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE promotion_categories (
+    promotion_id INT NOT NULL,
+    category_id  INT NOT NULL,
+    PRIMARY KEY (promotion_id, category_id),
+    CONSTRAINT fk_promotion_categories_promotion FOREIGN KEY (promotion_id) REFERENCES promotions (id) ON DELETE CASCADE,
+    CONSTRAINT fk_promotion_categories_category FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- one row per order a promotion was used by, counted for per customer limits
+CREATE TABLE promotion_redemptions (
+    id           INT AUTO_INCREMENT PRIMARY KEY,
+    promotion_id INT      NOT NULL,
+    order_id     INT      NOT NULL,
+    user_id      INT      NULL,
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_promotion_redemptions (promotion_id, order_id),
+    KEY idx_promotion_redemptions_user (promotion_id, user_id),
+    CONSTRAINT fk_promotion_redemptions_promotion FOREIGN KEY (promotion_id) REFERENCES promotions (id) ON DELETE CASCADE,
+    CONSTRAINT fk_promotion_redemptions_order FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE,
+    CONSTRAINT fk_promotion_redemptions_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE orders
+    ADD COLUMN discount BIGINT NOT NULL DEFAULT 0 AFTER subtotal,
+    ADD COLUMN free_shipping BOOLEAN NOT NULL DEFAULT FALSE AFTER discount;
+
+-- which promotion took what off which line, a snapshot that outlives the promotion
+CREATE TABLE order_discounts (
+    id            INT AUTO_INCREMENT PRIMARY KEY,
+    order_id      INT          NOT NULL,
+    order_item_id INT          NULL, -- NULL for discounts on the whole order
+    promotion_id  INT          NULL,
+    name          VARCHAR(100) NOT NULL,
+    code          VARCHAR(64)  NULL,
+    kind          VARCHAR(16)  NOT NULL,
+    amount        BIGINT       NOT NULL, -- minor units of the order's currency
+    KEY idx_order_discounts_order (order_id),
+    CONSTRAINT fk_order_discounts_order FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE,
+    CONSTRAINT fk_order_discounts_item FOREIGN KEY (order_item_id) REFERENCES order_items (id) ON DELETE CASCADE,
+    CONSTRAINT fk_order_discounts_promotion FOREIGN KEY (promotion_id) REFERENCES promotions (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
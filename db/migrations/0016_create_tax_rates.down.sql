@@ -0,0 +1,5 @@
+ALTER TABLE order_items DROP COLUMN tax, DROP COLUMN tax_rate, DROP COLUMN tax_name, DROP COLUMN tax_class;
+ALTER TABLE orders DROP COLUMN prices_include_tax, DROP COLUMN tax;
+DROP TABLE tax_rates;
+ALTER TABLE products DROP FOREIGN KEY fk_products_tax_class, DROP COLUMN tax_class;
+DROP TABLE tax_classes;
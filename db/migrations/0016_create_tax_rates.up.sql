@@ -0,0 +1,46 @@
+-- products taxed alike share a class, every product has one
+CREATE TABLE tax_classes (
+    code       VARCHAR(32)  NOT NULL PRIMARY KEY,
+    name       VARCHAR(100) NOT NULL,
+    created_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+INSERT INTO tax_classes (code, name) VALUES
+    ('standard', 'Standard rate'),
+    ('reduced', 'Reduced rate'),
+    ('zero', 'Zero rated');
+
+ALTER TABLE products
+    ADD COLUMN tax_class VARCHAR(32) NOT NULL DEFAULT 'standard' AFTER currency,
+    ADD CONSTRAINT fk_products_tax_class FOREIGN KEY (tax_class) REFERENCES tax_classes (code);
+
+-- the rate table, the most specific row matching a destination wins: a
+-- postcode (exact, or a prefix ending in *) over a region over the country.
+-- Destinations without a matching row are not taxed.
+CREATE TABLE tax_rates (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    tax_class  VARCHAR(32)   NOT NULL,
+    country    CHAR(2)       NOT NULL,
+    region     VARCHAR(64)   NOT NULL DEFAULT '', -- '' for the whole country
+    postcode   VARCHAR(16)   NOT NULL DEFAULT '', -- '' for the whole region
+    rate       DECIMAL(7, 4) NOT NULL,            -- percent
+    name       VARCHAR(100)  NOT NULL,
+    created_at DATETIME      NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME      NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_tax_rates_jurisdiction (tax_class, country, region, postcode),
+    KEY idx_tax_rates_country (country),
+    CONSTRAINT chk_tax_rates_rate CHECK (rate BETWEEN 0 AND 100),
+    CONSTRAINT fk_tax_rates_tax_class FOREIGN KEY (tax_class) REFERENCES tax_classes (code) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- tax as charged at checkout, prices_include_tax records the mode the
+-- order was priced in
+ALTER TABLE orders
+    ADD COLUMN tax BIGINT NOT NULL DEFAULT 0 AFTER discount,
+    ADD COLUMN prices_include_tax BOOLEAN NOT NULL DEFAULT FALSE AFTER tax;
+
+ALTER TABLE order_items
+    ADD COLUMN tax_class VARCHAR(32)   NOT NULL DEFAULT 'standard' AFTER unit_price,
+    ADD COLUMN tax_name  VARCHAR(100)  NULL AFTER tax_class, -- NULL when no rate matched
+    ADD COLUMN tax_rate  DECIMAL(7, 4) NOT NULL DEFAULT 0 AFTER tax_name,
+    ADD COLUMN tax       BIGINT        NOT NULL DEFAULT 0 AFTER tax_rate;
@@ -0,0 +1,5 @@
+DROP TABLE shipping_rate_tiers;
+DROP TABLE shipping_methods;
+DROP TABLE shipping_zone_locations;
+DROP TABLE shipping_zones;
+ALTER TABLE products DROP COLUMN height_mm, DROP COLUMN width_mm, DROP COLUMN length_mm, DROP COLUMN weight_grams;
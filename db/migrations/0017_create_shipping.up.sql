@@ -0,0 +1,51 @@
+-- shipping weight in grams and packed size in millimetres, 0 when unknown
+ALTER TABLE products
+    ADD COLUMN weight_grams INT NOT NULL DEFAULT 0 AFTER tax_class,
+    ADD COLUMN length_mm    INT NOT NULL DEFAULT 0 AFTER weight_grams,
+    ADD COLUMN width_mm     INT NOT NULL DEFAULT 0 AFTER length_mm,
+    ADD COLUMN height_mm    INT NOT NULL DEFAULT 0 AFTER width_mm;
+
+CREATE TABLE shipping_zones (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    name       VARCHAR(100) NOT NULL,
+    created_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_shipping_zones_name (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- the places a zone covers, matched like tax rates: the most specific
+-- location wins, so a postcode can be carved out of its country's zone.
+-- A place belongs to one zone only.
+CREATE TABLE shipping_zone_locations (
+    zone_id  INT         NOT NULL,
+    country  CHAR(2)     NOT NULL,
+    region   VARCHAR(64) NOT NULL DEFAULT '',
+    postcode VARCHAR(16) NOT NULL DEFAULT '',
+    PRIMARY KEY (country, region, postcode),
+    KEY idx_shipping_zone_locations_zone (zone_id),
+    CONSTRAINT fk_shipping_zone_locations_zone FOREIGN KEY (zone_id) REFERENCES shipping_zones (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE shipping_methods (
+    id             INT AUTO_INCREMENT PRIMARY KEY,
+    zone_id        INT          NOT NULL,
+    name           VARCHAR(100) NOT NULL,
+    kind           VARCHAR(16)  NOT NULL, -- flat, weight or price
+    currency       CHAR(3)      NOT NULL, -- of the tier prices and price tier minimums
+    estimated_days INT          NOT NULL DEFAULT 0,
+    active         BOOLEAN      NOT NULL DEFAULT TRUE,
+    created_at     DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at     DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    KEY idx_shipping_methods_zone (zone_id, active),
+    CONSTRAINT fk_shipping_methods_zone FOREIGN KEY (zone_id) REFERENCES shipping_zones (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- the rate table of a method, price applies from min_value (grams or minor
+-- units of the subtotal) up to the next tier
+CREATE TABLE shipping_rate_tiers (
+    method_id INT    NOT NULL,
+    min_value BIGINT NOT NULL,
+    price     BIGINT NOT NULL,
+    PRIMARY KEY (method_id, min_value),
+    CONSTRAINT fk_shipping_rate_tiers_method FOREIGN KEY (method_id) REFERENCES shipping_methods (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
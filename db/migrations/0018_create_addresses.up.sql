@@ -0,0 +1,41 @@
+-- the users' address books, a user has at most one default address of each
+-- kind (kept so by the application)
+CREATE TABLE addresses (
+    id               INT AUTO_INCREMENT PRIMARY KEY,
+    user_id          INT          NOT NULL,
+    name             VARCHAR(100) NOT NULL, -- recipient
+    company          VARCHAR(100) NOT NULL DEFAULT '',
+    line1            VARCHAR(255) NOT NULL,
+    line2            VARCHAR(255) NOT NULL DEFAULT '',
+    city             VARCHAR(100) NOT NULL,
+    region           VARCHAR(64)  NOT NULL DEFAULT '',
+    postcode         VARCHAR(16)  NOT NULL DEFAULT '',
+    country          CHAR(2)      NOT NULL,
+    phone            VARCHAR(32)  NOT NULL DEFAULT '',
+    default_shipping BOOLEAN      NOT NULL DEFAULT FALSE,
+    default_billing  BOOLEAN      NOT NULL DEFAULT FALSE,
+    created_at       DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at       DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    KEY idx_addresses_user (user_id),
+    CONSTRAINT fk_addresses_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- the addresses an order was placed with, copied at checkout so editing or
+-- deleting the saved address doesn't change where the order went
+CREATE TABLE order_addresses (
+    order_id   INT          NOT NULL,
+    kind       VARCHAR(16)  NOT NULL, -- shipping or billing
+    address_id INT          NULL,     -- the saved address, NULL once deleted
+    name       VARCHAR(100) NOT NULL,
+    company    VARCHAR(100) NOT NULL DEFAULT '',
+    line1      VARCHAR(255) NOT NULL,
+    line2      VARCHAR(255) NOT NULL DEFAULT '',
+    city       VARCHAR(100) NOT NULL,
+    region     VARCHAR(64)  NOT NULL DEFAULT '',
+    postcode   VARCHAR(16)  NOT NULL DEFAULT '',
+    country    CHAR(2)      NOT NULL,
+    phone      VARCHAR(32)  NOT NULL DEFAULT '',
+    PRIMARY KEY (order_id, kind),
+    CONSTRAINT fk_order_addresses_order FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE,
+    CONSTRAINT fk_order_addresses_address FOREIGN KEY (address_id) REFERENCES addresses (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,17 @@
+-- refunds sent to the gateway. A refund is recorded as pending before the
+-- gateway call and settled after it, so a crash in between leaves a trace.
+CREATE TABLE payment_refunds (
+    id              INT AUTO_INCREMENT PRIMARY KEY,
+    payment_id      INT          NOT NULL,
+    idempotency_key VARCHAR(64)  NOT NULL, -- sent with the gateway call, a repeated call refunds once
+    status          VARCHAR(16)  NOT NULL DEFAULT 'pending',
+    currency        CHAR(3)      NOT NULL,
+    amount          BIGINT       NOT NULL,
+    failure_reason  VARCHAR(255) NULL,
+    created_at      DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at      DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_payment_refunds_key (idempotency_key),
+    KEY idx_payment_refunds_payment (payment_id),
+    CONSTRAINT chk_payment_refunds_amount CHECK (amount > 0),
+    CONSTRAINT fk_payment_refunds_payment FOREIGN KEY (payment_id) REFERENCES payments (id) ON DELETE RESTRICT
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,2 @@
+DELETE FROM payments WHERE reference IS NULL;
+ALTER TABLE payments MODIFY reference VARCHAR(128) NOT NULL;
@@ -0,0 +1,3 @@
+-- a payment is recorded as pending before the gateway is asked to authorize
+-- it, so it has no reference yet
+ALTER TABLE payments MODIFY reference VARCHAR(128) NULL;
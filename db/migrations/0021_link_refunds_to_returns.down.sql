@@ -0,0 +1,2 @@
+ALTER TABLE payment_refunds DROP FOREIGN KEY fk_payment_refunds_return;
+ALTER TABLE payment_refunds DROP COLUMN return_id;
@@ -0,0 +1,5 @@
+-- a refund made for a return points at it, so what the gateway rejects when
+-- a pending refund is sent again can be taken off that return
+ALTER TABLE payment_refunds
+    ADD COLUMN return_id INT NULL AFTER payment_id,
+    ADD CONSTRAINT fk_payment_refunds_return FOREIGN KEY (return_id) REFERENCES returns (id) ON DELETE RESTRICT;
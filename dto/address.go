@@ -0,0 +1,84 @@
+package dto
+
+import (
+	"ecommerce/models"
+	"time"
+)
+
+// PostalAddressPayload is an address as clients send and read it
+type PostalAddressPayload struct {
+	Name     string `json:"name"`
+	Company  string `json:"company,omitempty"`
+	Line1    string `json:"line1"`
+	Line2    string `json:"line2,omitempty"`
+	City     string `json:"city"`
+	Region   string `json:"region,omitempty"`
+	Postcode string `json:"postcode,omitempty"`
+	Country  string `json:"country"`
+	Phone    string `json:"phone,omitempty"`
+}
+
+// AddressRequest creates or replaces an entry of the caller's address book.
+// Making it a default takes the flag off the caller's other addresses.
+type AddressRequest struct {
+	PostalAddressPayload
+	DefaultShipping bool `json:"default_shipping"`
+	DefaultBilling  bool `json:"default_billing"`
+}
+
+type AddressResponse struct {
+	ID int `json:"id"`
+	PostalAddressPayload
+	DefaultShipping bool      `json:"default_shipping"`
+	DefaultBilling  bool      `json:"default_billing"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// OrderAddressResponse is an address as the order was placed with it,
+// address_id is left out once the saved address is deleted
+type OrderAddressResponse struct {
+	AddressID *int `json:"address_id,omitempty"`
+	PostalAddressPayload
+}
+
+func (p PostalAddressPayload) ToModel() models.PostalAddress {
+	return models.PostalAddress{Name: p.Name, Company: p.Company, Line1: p.Line1, Line2: p.Line2, City: p.City, Region: p.Region,
+		Postcode: p.Postcode, Country: p.Country, Phone: p.Phone}
+}
+
+func NewPostalAddressPayload(a models.PostalAddress) PostalAddressPayload {
+	return PostalAddressPayload{Name: a.Name, Company: a.Company, Line1: a.Line1, Line2: a.Line2, City: a.City, Region: a.Region,
+		Postcode: a.Postcode, Country: a.Country, Phone: a.Phone}
+}
+
+func (r AddressRequest) ToModel(userID int) *models.Address {
+	return &models.Address{UserID: userID, PostalAddress: r.PostalAddressPayload.ToModel(), DefaultShipping: r.DefaultShipping, DefaultBilling: r.DefaultBilling}
+}
+
+func NewAddressResponse(address *models.Address) AddressResponse {
+	return AddressResponse{
+		ID:                   address.ID,
+		PostalAddressPayload: NewPostalAddressPayload(address.PostalAddress),
+		DefaultShipping:      address.DefaultShipping,
+		DefaultBilling:       address.DefaultBilling,
+		CreatedAt:            address.CreatedAt,
+		UpdatedAt:            address.UpdatedAt,
+	}
+}
+
+func NewAddressResponses(addresses []models.Address) []AddressResponse {
+	responses := make([]AddressResponse, 0, len(addresses))
+	for i := range addresses {
+		responses = append(responses, NewAddressResponse(&addresses[i]))
+	}
+	return responses
+}
+
+// newOrderAddressResponse returns nil for an order without the address
+func newOrderAddressResponse(address *models.OrderAddress) *OrderAddressResponse {
+	if address == nil {
+		return nil
+	}
+	return &OrderAddressResponse{AddressID: address.AddressID, PostalAddressPayload: NewPostalAddressPayload(address.PostalAddress)}
+}
@@ -0,0 +1,79 @@
+package dto
+
+import "ecommerce/models"
+
+// CartItemRequest adds a product, or one of its variants when variant_id is set
+type CartItemRequest struct {
+	ProductID int  `json:"product_id"`
+	VariantID *int `json:"variant_id"`
+	Quantity  int  `json:"quantity"`
+}
+
+type CartQuantityRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+// CartResponse carries the guest token only when the request created the cart
+type CartResponse struct {
+	ID        int                `json:"id,omitempty"`
+	Token     string             `json:"token,omitempty"`
+	Items     []CartItemResponse `json:"items"`
+	ItemCount int                `json:"item_count"`
+	Subtotal  models.Money       `json:"subtotal"`
+}
+
+// CartItemResponse flags lines whose price moved since they were last
+// changed, previous_price is what the shopper saw then
+type CartItemResponse struct {
+	ID            int           `json:"id"`
+	ProductID     int           `json:"product_id"`
+	VariantID     *int          `json:"variant_id,omitempty"`
+	SKU           string        `json:"sku,omitempty"`
+	Name          string        `json:"name"`
+	Quantity      int           `json:"quantity"`
+	UnitPrice     models.Money  `json:"unit_price"`
+	LineTotal     models.Money  `json:"line_total"`
+	PriceChanged  bool          `json:"price_changed"`
+	PreviousPrice *models.Money `json:"previous_price,omitempty"`
+}
+
+func (r CartItemRequest) ToModel() models.CartItem {
+	return models.CartItem{ProductID: r.ProductID, VariantID: r.VariantID, Quantity: r.Quantity}
+}
+
+func NewCartResponse(cart *models.Cart) (CartResponse, error) {
+	items := make([]CartItemResponse, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		lineTotal, err := item.LineTotal()
+		if err != nil {
+			return CartResponse{}, err
+		}
+		response := CartItemResponse{
+			ID:           item.ID,
+			ProductID:    item.ProductID,
+			VariantID:    item.VariantID,
+			SKU:          item.SKU,
+			Name:         item.Name,
+			Quantity:     item.Quantity,
+			UnitPrice:    item.UnitPrice,
+			LineTotal:    lineTotal,
+			PriceChanged: item.PriceChanged(),
+		}
+		if response.PriceChanged {
+			previous := item.SavedPrice
+			response.PreviousPrice = &previous
+		}
+		items = append(items, response)
+	}
+	subtotal, err := cart.Subtotal()
+	if err != nil {
+		return CartResponse{}, err
+	}
+	return CartResponse{
+		ID:        cart.ID,
+		Token:     cart.Token,
+		Items:     items,
+		ItemCount: cart.ItemCount(),
+		Subtotal:  subtotal,
+	}, nil
+}
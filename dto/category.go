@@ -0,0 +1,52 @@
+package dto
+
+import "ecommerce/models"
+
+// CategoryRequest is accepted by create and update, the slug defaults to the slugified name
+type CategoryRequest struct {
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	ParentID *int   `json:"parent_id"`
+}
+
+type CategoryResponse struct {
+	ID       int                `json:"id"`
+	Name     string             `json:"name"`
+	Slug     string             `json:"slug"`
+	ParentID *int               `json:"parent_id"`
+	Children []CategoryResponse `json:"children,omitempty"`
+}
+
+// ProductCategoriesRequest replaces the categories a product is listed in
+type ProductCategoriesRequest struct {
+	CategoryIDs []int `json:"category_ids"`
+}
+
+func (r CategoryRequest) ToModel() *models.Category {
+	return &models.Category{
+		Name:     r.Name,
+		Slug:     r.Slug,
+		ParentID: r.ParentID,
+	}
+}
+
+func NewCategoryResponse(category *models.Category) CategoryResponse {
+	response := CategoryResponse{
+		ID:       category.ID,
+		Name:     category.Name,
+		Slug:     category.Slug,
+		ParentID: category.ParentID,
+	}
+	if len(category.Children) > 0 {
+		response.Children = NewCategoryResponses(category.Children)
+	}
+	return response
+}
+
+func NewCategoryResponses(categories []models.Category) []CategoryResponse {
+	responses := make([]CategoryResponse, 0, len(categories))
+	for i := range categories {
+		responses = append(responses, NewCategoryResponse(&categories[i]))
+	}
+	return responses
+}
@@ -0,0 +1,125 @@
+package dto
+
+import (
+	"ecommerce/models"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserMapping(t *testing.T) {
+	user := &models.User{Id: 1, Name: "Abhay", Email: "abhay123@gmail.com", Username: "abhay123", Password: "secret-hash", Role: models.RoleAdmin}
+
+	t.Run("Response omits password", func(t *testing.T) {
+		body, err := json.Marshal(NewUserResponse(user))
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"id":1,"name":"Abhay","email":"abhay123@gmail.com","username":"abhay123","role":"admin"}`, string(body))
+	})
+	t.Run("Create request", func(t *testing.T) {
+		var request CreateUserRequest
+		err := json.Unmarshal([]byte(`{"name":"Yash","email":"yash123@gmail.com","username":"yash123","password":"yash@123"}`), &request)
+		assert.NoError(t, err)
+		assert.Equal(t, &models.User{Name: "Yash", Email: "yash123@gmail.com", Username: "yash123", Password: "yash@123"}, request.ToModel())
+	})
+	t.Run("Update request keeps empty fields", func(t *testing.T) {
+		existing := *user
+		UpdateUserRequest{Email: "new@gmail.com"}.ApplyTo(&existing)
+		assert.Equal(t, "new@gmail.com", existing.Email)
+		assert.Equal(t, "Abhay", existing.Name)
+		assert.Equal(t, models.RoleAdmin, existing.Role)
+	})
+	t.Run("Empty list", func(t *testing.T) {
+		body, _ := json.Marshal(NewUserResponses(nil))
+		assert.Equal(t, "[]", string(body))
+	})
+}
+
+func TestProductMapping(t *testing.T) {
+	product := &models.Product{ID: 1, Name: "Laptop", Price: models.NewMoney(6100000, "USD"), CreatedBy: 2, UpdatedBy: 3}
+
+	body, err := json.Marshal(NewProductResponse(product))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":1,"name":"Laptop","price":{"amount":"61000.00","currency":"USD"},"created_by":2,"updated_by":3}`, string(body))
+
+	var request ProductRequest
+	assert.NoError(t, json.Unmarshal([]byte(`{"price":{"amount":"55000","currency":"USD"}}`), &request))
+	existing := *product
+	request.ApplyTo(&existing)
+	assert.Equal(t, "Laptop", existing.Name)
+	assert.Equal(t, models.NewMoney(5500000, "USD"), existing.Price)
+
+	request = ProductRequest{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"weight_grams":1800,"dimensions":{"length_mm":350,"width_mm":250,"height_mm":20}}`), &request))
+	request.ApplyTo(&existing)
+	assert.Equal(t, 1800, existing.Weight)
+	body, err = json.Marshal(NewProductResponse(&existing))
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"dimensions":{"length_mm":350,"width_mm":250,"height_mm":20}`)
+}
+
+func TestCategoryMapping(t *testing.T) {
+	parent := 1
+	tree := &models.Category{ID: 1, Name: "Electronics", Slug: "electronics", Children: []models.Category{
+		{ID: 2, Name: "Laptops", Slug: "laptops", ParentID: &parent},
+	}}
+
+	body, err := json.Marshal(NewCategoryResponse(tree))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":1,"name":"Electronics","slug":"electronics","parent_id":null,
+		"children":[{"id":2,"name":"Laptops","slug":"laptops","parent_id":1}]}`, string(body))
+}
+
+func TestVariantMapping(t *testing.T) {
+	price := models.NewMoney(55000, "USD")
+	product := &models.Product{ID: 1, Name: "T-shirt", Price: models.NewMoney(50000, "USD"), Variants: []models.Variant{
+		{ID: 3, ProductID: 1, SKU: "TS-S", Options: map[string]string{"size": "S"}},
+		{ID: 4, ProductID: 1, SKU: "TS-XL", Price: &price, Barcode: "123", Stock: 7},
+	}}
+
+	body, err := json.Marshal(NewProductResponse(product))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":1,"name":"T-shirt","price":{"amount":"500.00","currency":"USD"},"variants":[
+		{"id":3,"product_id":1,"sku":"TS-S","price":{"amount":"500.00","currency":"USD"},"price_override":false,"stock":0,"options":{"size":"S"}},
+		{"id":4,"product_id":1,"sku":"TS-XL","price":{"amount":"550.00","currency":"USD"},"price_override":true,"barcode":"123","stock":7,"options":{}}]}`, string(body))
+}
+
+func TestCartMapping(t *testing.T) {
+	variantID := 4
+	usd := func(amount int64) models.Money { return models.NewMoney(amount, "USD") }
+	cart := &models.Cart{ID: 3, Currency: "USD", Items: []models.CartItem{
+		{ID: 1, ProductID: 1, Name: "Mug", Quantity: 3, UnitPrice: usd(10), SavedPrice: usd(10)},
+		{ID: 2, ProductID: 2, VariantID: &variantID, SKU: "SHIRT-M", Name: "Shirt", Quantity: 1, UnitPrice: usd(2500), SavedPrice: usd(2250)},
+	}}
+
+	cartResponse, err := NewCartResponse(cart)
+	assert.NoError(t, err)
+	body, err := json.Marshal(cartResponse)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":3,"item_count":4,"subtotal":{"amount":"25.30","currency":"USD"},"items":[
+		{"id":1,"product_id":1,"name":"Mug","quantity":3,"unit_price":{"amount":"0.10","currency":"USD"},
+			"line_total":{"amount":"0.30","currency":"USD"},"price_changed":false},
+		{"id":2,"product_id":2,"variant_id":4,"sku":"SHIRT-M","name":"Shirt","quantity":1,"unit_price":{"amount":"25.00","currency":"USD"},
+			"line_total":{"amount":"25.00","currency":"USD"},"price_changed":true,"previous_price":{"amount":"22.50","currency":"USD"}}
+	]}`, string(body))
+}
+
+func TestAddressMapping(t *testing.T) {
+	var request AddressRequest
+	body := `{"name":"Grace Hopper","line1":"350 Fifth Avenue","city":"New York","region":"NY","postcode":"10118","country":"US","default_billing":true}`
+	assert.NoError(t, json.Unmarshal([]byte(body), &request))
+	address := request.ToModel(7)
+	assert.Equal(t, &models.Address{UserID: 7, DefaultBilling: true, PostalAddress: models.PostalAddress{
+		Name: "Grace Hopper", Line1: "350 Fifth Avenue", City: "New York", Region: "NY", Postcode: "10118", Country: "US"}}, address)
+
+	addressID := 5
+	order := &models.Order{ID: 40, Currency: "USD", Addresses: []models.OrderAddress{
+		{OrderID: 40, Kind: models.AddressShipping, AddressID: &addressID, PostalAddress: address.PostalAddress},
+	}}
+	orderResponse, err := NewOrderResponse(order)
+	assert.NoError(t, err)
+	response, err := json.Marshal(orderResponse)
+	assert.NoError(t, err)
+	assert.Contains(t, string(response), `"shipping_address":{"address_id":5,"name":"Grace Hopper","line1":"350 Fifth Avenue"`)
+	assert.NotContains(t, string(response), `"billing_address"`)
+}
@@ -0,0 +1,92 @@
+package dto
+
+import (
+	"ecommerce/models"
+	"time"
+)
+
+// InventoryItemResponse is the stock in one warehouse, warehouse_id is left
+// out for products and variants that aren't stocked anywhere yet
+type InventoryItemResponse struct {
+	WarehouseID int    `json:"warehouse_id,omitempty"`
+	ProductID   int    `json:"product_id"`
+	VariantID   *int   `json:"variant_id,omitempty"`
+	SKU         string `json:"sku,omitempty"`
+	OnHand      int    `json:"on_hand"`
+	Reserved    int    `json:"reserved"`
+	Available   int    `json:"available"`
+}
+
+// AdjustmentRequest changes the on hand stock of a product, or of one of its
+// variants when variant_id is set, in a warehouse. Delta is signed, e.g. -2
+// for two damaged units.
+type AdjustmentRequest struct {
+	WarehouseID int    `json:"warehouse_id"`
+	VariantID   *int   `json:"variant_id"`
+	Delta       int    `json:"delta"`
+	Reason      string `json:"reason"`
+	Note        string `json:"note"`
+}
+
+type AdjustmentResponse struct {
+	ID          int       `json:"id"`
+	WarehouseID int       `json:"warehouse_id"`
+	VariantID   *int      `json:"variant_id,omitempty"`
+	Delta       int       `json:"delta"`
+	OnHandAfter int       `json:"on_hand_after"`
+	Reason      string    `json:"reason"`
+	Reference   string    `json:"reference,omitempty"`
+	Note        string    `json:"note,omitempty"`
+	CreatedBy   int       `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func NewInventoryItemResponse(item *models.InventoryItem) InventoryItemResponse {
+	return InventoryItemResponse{
+		WarehouseID: item.WarehouseID,
+		ProductID:   item.ProductID,
+		VariantID:   item.VariantID,
+		SKU:         item.SKU,
+		OnHand:      item.OnHand,
+		Reserved:    item.Reserved,
+		Available:   item.Available(),
+	}
+}
+
+func NewInventoryItemResponses(items []models.InventoryItem) []InventoryItemResponse {
+	responses := make([]InventoryItemResponse, 0, len(items))
+	for i := range items {
+		responses = append(responses, NewInventoryItemResponse(&items[i]))
+	}
+	return responses
+}
+
+func (r AdjustmentRequest) ToModel(productID int) *models.InventoryAdjustment {
+	return &models.InventoryAdjustment{
+		WarehouseID: r.WarehouseID,
+		ProductID:   productID,
+		VariantID:   r.VariantID,
+		Delta:       r.Delta,
+		Reason:      models.AdjustmentReason(r.Reason),
+		Note:        r.Note,
+	}
+}
+
+func NewAdjustmentResponses(adjustments []models.InventoryAdjustment) []AdjustmentResponse {
+	responses := make([]AdjustmentResponse, 0, len(adjustments))
+	for _, adjustment := range adjustments {
+		responses = append(responses, AdjustmentResponse{
+			ID:          adjustment.ID,
+			WarehouseID: adjustment.WarehouseID,
+			VariantID:   adjustment.VariantID,
+			Delta:       adjustment.Delta,
+			OnHandAfter: adjustment.OnHandAfter,
+			Reason:      string(adjustment.Reason),
+			Reference:   adjustment.Reference,
+			Note:        adjustment.Note,
+			CreatedBy:   adjustment.CreatedBy,
+			CreatedAt:   adjustment.CreatedAt,
+		})
+	}
+	return responses
+}
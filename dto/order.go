@@ -0,0 +1,159 @@
+package dto
+
+import (
+	"ecommerce/models"
+	"time"
+)
+
+type OrderResponse struct {
+	ID              int                   `json:"id"`
+	UserID          int                   `json:"user_id,omitempty"`
+	Status          models.OrderStatus    `json:"status"`
+	Items           []OrderItemResponse   `json:"items"`
+	Subtotal        models.Money          `json:"subtotal"`
+	Discount        models.Money          `json:"discount"`
+	Tax             models.Money          `json:"tax"`
+	TaxInclusive    bool                  `json:"prices_include_tax"`
+	Total           models.Money          `json:"total"`
+	FreeShipping    bool                  `json:"free_shipping"`
+	Discounts       []DiscountResponse    `json:"discounts"`
+	ShippingAddress *OrderAddressResponse `json:"shipping_address,omitempty"`
+	BillingAddress  *OrderAddressResponse `json:"billing_address,omitempty"`
+	RefundedAmount  models.Money          `json:"refunded_amount"`
+	CreatedAt       time.Time             `json:"created_at"`
+	UpdatedAt       time.Time             `json:"updated_at"`
+}
+
+// OrderItemResponse is the line as it was sold, product_id and variant_id
+// are left out once they are deleted from the catalogue
+type OrderItemResponse struct {
+	ID        int            `json:"id"`
+	ProductID *int           `json:"product_id,omitempty"`
+	VariantID *int           `json:"variant_id,omitempty"`
+	SKU       string         `json:"sku,omitempty"`
+	Name      string         `json:"name"`
+	Quantity  int            `json:"quantity"`
+	UnitPrice models.Money   `json:"unit_price"`
+	LineTotal models.Money   `json:"line_total"`
+	TaxClass  string         `json:"tax_class,omitempty"`
+	TaxName   string         `json:"tax_name,omitempty"`
+	TaxRate   models.Percent `json:"tax_rate"`
+	Tax       models.Money   `json:"tax"`
+}
+
+// CheckoutRequest is the optional body of a checkout. The address IDs pick
+// entries of the caller's address book to ship and bill to, the shipping
+// address takes the place of destination; without either the store's
+// default tax country applies.
+type CheckoutRequest struct {
+	CouponCodes       []string           `json:"coupon_codes"`
+	Destination       DestinationRequest `json:"destination"`
+	ShippingAddressID int                `json:"shipping_address_id"`
+	BillingAddressID  int                `json:"billing_address_id"`
+}
+
+func (r CheckoutRequest) ToModel(userID int) models.CheckoutRequest {
+	return models.CheckoutRequest{UserID: userID, CouponCodes: r.CouponCodes, Destination: r.Destination.ToModel(),
+		ShippingAddressID: r.ShippingAddressID, BillingAddressID: r.BillingAddressID}
+}
+
+// OrderTransitionRequest moves an order to another status, e.g. {"status":"shipped"}
+type OrderTransitionRequest struct {
+	Status models.OrderStatus `json:"status"`
+	Note   string             `json:"note"`
+}
+
+type OrderStatusChangeResponse struct {
+	From      models.OrderStatus `json:"from,omitempty"`
+	To        models.OrderStatus `json:"to"`
+	Note      string             `json:"note,omitempty"`
+	ChangedBy int                `json:"changed_by,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+func (r OrderTransitionRequest) ToModel(orderID int) *models.OrderStatusChange {
+	return &models.OrderStatusChange{OrderID: orderID, To: r.Status, Note: r.Note}
+}
+
+func NewOrderResponse(order *models.Order) (OrderResponse, error) {
+	items := make([]OrderItemResponse, 0, len(order.Items))
+	for _, item := range order.Items {
+		lineTotal, err := item.LineTotal()
+		if err != nil {
+			return OrderResponse{}, err
+		}
+		items = append(items, OrderItemResponse{
+			ID:        item.ID,
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			SKU:       item.SKU,
+			Name:      item.Name,
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+			LineTotal: lineTotal,
+			TaxClass:  item.TaxClass,
+			TaxName:   item.TaxName,
+			TaxRate:   item.TaxRate,
+			Tax:       item.Tax,
+		})
+	}
+	discounts := make([]DiscountResponse, 0, len(order.Discounts))
+	for _, discount := range order.Discounts {
+		discounts = append(discounts, DiscountResponse{
+			PromotionID: discount.PromotionID,
+			Name:        discount.Name,
+			Code:        discount.Code,
+			Kind:        discount.Kind,
+			ItemID:      discount.OrderItemID,
+			Amount:      discount.Amount,
+		})
+	}
+	total, err := order.Total()
+	if err != nil {
+		return OrderResponse{}, err
+	}
+	return OrderResponse{
+		ID:              order.ID,
+		UserID:          order.UserID,
+		Status:          order.Status,
+		Items:           items,
+		Subtotal:        order.Subtotal,
+		Discount:        order.Discount,
+		Tax:             order.Tax,
+		TaxInclusive:    order.TaxInclusive,
+		Total:           total,
+		FreeShipping:    order.FreeShipping,
+		Discounts:       discounts,
+		ShippingAddress: newOrderAddressResponse(order.Address(models.AddressShipping)),
+		BillingAddress:  newOrderAddressResponse(order.Address(models.AddressBilling)),
+		RefundedAmount:  order.RefundedAmount,
+		CreatedAt:       order.CreatedAt,
+		UpdatedAt:       order.UpdatedAt,
+	}, nil
+}
+
+func NewOrderResponses(orders []models.Order) ([]OrderResponse, error) {
+	responses := make([]OrderResponse, 0, len(orders))
+	for i := range orders {
+		response, err := NewOrderResponse(&orders[i])
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+func NewOrderStatusChangeResponses(changes []models.OrderStatusChange) []OrderStatusChangeResponse {
+	responses := make([]OrderStatusChangeResponse, 0, len(changes))
+	for _, change := range changes {
+		responses = append(responses, OrderStatusChangeResponse{
+			From:      change.From,
+			To:        change.To,
+			Note:      change.Note,
+			ChangedBy: change.ChangedBy,
+			CreatedAt: change.CreatedAt,
+		})
+	}
+	return responses
+}
@@ -0,0 +1,63 @@
+package dto
+
+import (
+	"ecommerce/models"
+	"time"
+)
+
+// PayOrderRequest carries the token the client got from the payment gateway
+type PayOrderRequest struct {
+	PaymentToken string `json:"payment_token"`
+}
+
+// RefundRequest refunds amount, leaving it out refunds everything left
+type RefundRequest struct {
+	Amount models.Money `json:"amount"`
+}
+
+type PaymentResponse struct {
+	ID             int                  `json:"id"`
+	OrderID        int                  `json:"order_id"`
+	Provider       string               `json:"provider"`
+	Reference      string               `json:"reference"`
+	Status         models.PaymentStatus `json:"status"`
+	Amount         models.Money         `json:"amount"`
+	RefundedAmount models.Money         `json:"refunded_amount"`
+	FailureReason  string               `json:"failure_reason,omitempty"`
+	CreatedAt      time.Time            `json:"created_at"`
+}
+
+// PaymentEventRequest is the body of a payment webhook
+type PaymentEventRequest struct {
+	ID        string       `json:"id"`
+	Type      string       `json:"type"`
+	Reference string       `json:"reference"`
+	Amount    models.Money `json:"amount"` // for payment.refunded the total refunded so far
+	Reason    string       `json:"reason"`
+}
+
+func (r PaymentEventRequest) ToModel() *models.PaymentEvent {
+	return &models.PaymentEvent{ID: r.ID, Type: r.Type, Reference: r.Reference, Amount: r.Amount, Reason: r.Reason}
+}
+
+func NewPaymentResponse(payment *models.Payment) PaymentResponse {
+	return PaymentResponse{
+		ID:             payment.ID,
+		OrderID:        payment.OrderID,
+		Provider:       payment.Provider,
+		Reference:      payment.Reference,
+		Status:         payment.Status,
+		Amount:         payment.Amount,
+		RefundedAmount: payment.RefundedAmount,
+		FailureReason:  payment.FailureReason,
+		CreatedAt:      payment.CreatedAt,
+	}
+}
+
+func NewPaymentResponses(payments []models.Payment) []PaymentResponse {
+	responses := make([]PaymentResponse, 0, len(payments))
+	for i := range payments {
+		responses = append(responses, NewPaymentResponse(&payments[i]))
+	}
+	return responses
+}
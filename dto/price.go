@@ -0,0 +1,74 @@
+package dto
+
+import (
+	"ecommerce/models"
+	"time"
+)
+
+// ProductPriceRequest sets the product's price in the price's currency
+type ProductPriceRequest struct {
+	Price models.Money `json:"price"`
+}
+
+type ProductPriceResponse struct {
+	ProductID int          `json:"product_id"`
+	Price     models.Money `json:"price"`
+}
+
+// ExchangeRateRequest adds a rate, EffectiveAt defaults to now
+type ExchangeRateRequest struct {
+	Base        string      `json:"base"`
+	Quote       string      `json:"quote"`
+	Rate        models.Rate `json:"rate"` // units of quote one unit of base buys, e.g. "0.92"
+	EffectiveAt *time.Time  `json:"effective_at"`
+}
+
+type ExchangeRateResponse struct {
+	ID          int         `json:"id"`
+	Base        string      `json:"base"`
+	Quote       string      `json:"quote"`
+	Rate        models.Rate `json:"rate"`
+	EffectiveAt time.Time   `json:"effective_at"`
+	CreatedBy   int         `json:"created_by,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+func (r ProductPriceRequest) ToModel(productID int) *models.ProductPrice {
+	return &models.ProductPrice{ProductID: productID, Price: r.Price}
+}
+
+func NewProductPriceResponses(prices []models.ProductPrice) []ProductPriceResponse {
+	responses := make([]ProductPriceResponse, 0, len(prices))
+	for _, price := range prices {
+		responses = append(responses, ProductPriceResponse{ProductID: price.ProductID, Price: price.Price})
+	}
+	return responses
+}
+
+func (r ExchangeRateRequest) ToModel() *models.ExchangeRate {
+	rate := &models.ExchangeRate{Base: r.Base, Quote: r.Quote, Rate: r.Rate}
+	if r.EffectiveAt != nil {
+		rate.EffectiveAt = *r.EffectiveAt
+	}
+	return rate
+}
+
+func NewExchangeRateResponse(rate *models.ExchangeRate) ExchangeRateResponse {
+	return ExchangeRateResponse{
+		ID:          rate.ID,
+		Base:        rate.Base,
+		Quote:       rate.Quote,
+		Rate:        rate.Rate,
+		EffectiveAt: rate.EffectiveAt,
+		CreatedBy:   rate.CreatedBy,
+		CreatedAt:   rate.CreatedAt,
+	}
+}
+
+func NewExchangeRateResponses(rates []models.ExchangeRate) []ExchangeRateResponse {
+	responses := make([]ExchangeRateResponse, 0, len(rates))
+	for i := range rates {
+		responses = append(responses, NewExchangeRateResponse(&rates[i]))
+	}
+	return responses
+}
@@ -0,0 +1,162 @@
+package dto
+
+import "ecommerce/models"
+
+// ProductRequest is accepted by create and update, on update zero values are left unchanged
+type ProductRequest struct {
+	Name       string             `json:"name"`
+	Price      *models.Money      `json:"price"`
+	TaxClass   string             `json:"tax_class"` // defaults to standard
+	Weight     *int               `json:"weight_grams"`
+	Dimensions *DimensionsPayload `json:"dimensions"`
+}
+
+// DimensionsPayload is the packed size of a product in millimetres
+type DimensionsPayload struct {
+	Length int `json:"length_mm"`
+	Width  int `json:"width_mm"`
+	Height int `json:"height_mm"`
+}
+
+type ProductResponse struct {
+	ID         int                `json:"id"`
+	Name       string             `json:"name"`
+	Price      models.Money       `json:"price"`
+	TaxClass   string             `json:"tax_class,omitempty"`
+	Weight     int                `json:"weight_grams,omitempty"`
+	Dimensions *DimensionsPayload `json:"dimensions,omitempty"`
+	CreatedBy  int                `json:"created_by,omitempty"`
+	UpdatedBy  int                `json:"updated_by,omitempty"`
+	Variants   []VariantResponse  `json:"variants,omitempty"`
+}
+
+// VariantRequest is accepted by create and update of a variant
+type VariantRequest struct {
+	SKU     string            `json:"sku"`
+	Price   *models.Money     `json:"price"` // omit to use the product price
+	Barcode string            `json:"barcode"`
+	Options map[string]string `json:"options"`
+}
+
+// VariantResponse carries the price the variant sells for, PriceOverride
+// tells whether it differs from the product price
+type VariantResponse struct {
+	ID            int               `json:"id"`
+	ProductID     int               `json:"product_id"`
+	SKU           string            `json:"sku"`
+	Price         models.Money      `json:"price"`
+	PriceOverride bool              `json:"price_override"`
+	Barcode       string            `json:"barcode,omitempty"`
+	Options       map[string]string `json:"options"`
+	Stock         int               `json:"stock"` // available to sell, managed through inventory
+}
+
+type OptionTypeRequest struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+type OptionTypeResponse struct {
+	ID     int      `json:"id"`
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+func (r ProductRequest) ToModel() *models.Product {
+	product := &models.Product{Name: r.Name, TaxClass: r.TaxClass}
+	r.ApplyTo(product)
+	return product
+}
+
+// ApplyTo copies the non-zero fields onto an existing product
+func (r ProductRequest) ApplyTo(product *models.Product) {
+	if r.Name != "" {
+		product.Name = r.Name
+	}
+	if r.Price != nil {
+		product.Price = *r.Price
+	}
+	if r.TaxClass != "" {
+		product.TaxClass = r.TaxClass
+	}
+	if r.Weight != nil {
+		product.Weight = *r.Weight
+	}
+	if r.Dimensions != nil {
+		product.Dimensions = models.Dimensions{Length: r.Dimensions.Length, Width: r.Dimensions.Width, Height: r.Dimensions.Height}
+	}
+}
+
+func NewProductResponse(product *models.Product) ProductResponse {
+	response := ProductResponse{
+		ID:        product.ID,
+		Name:      product.Name,
+		Price:     product.Price,
+		TaxClass:  product.TaxClass,
+		Weight:    product.Weight,
+		CreatedBy: product.CreatedBy,
+		UpdatedBy: product.UpdatedBy,
+	}
+	if dimensions := product.Dimensions; dimensions != (models.Dimensions{}) {
+		response.Dimensions = &DimensionsPayload{Length: dimensions.Length, Width: dimensions.Width, Height: dimensions.Height}
+	}
+	for i := range product.Variants {
+		response.Variants = append(response.Variants, NewVariantResponse(&product.Variants[i], product))
+	}
+	return response
+}
+
+func NewProductResponses(products []models.Product) []ProductResponse {
+	responses := make([]ProductResponse, 0, len(products))
+	for i := range products {
+		responses = append(responses, NewProductResponse(&products[i]))
+	}
+	return responses
+}
+
+func (r VariantRequest) ToModel(productID int) *models.Variant {
+	return &models.Variant{
+		ProductID: productID,
+		SKU:       r.SKU,
+		Price:     r.Price,
+		Barcode:   r.Barcode,
+		Options:   r.Options,
+	}
+}
+
+func NewVariantResponse(variant *models.Variant, product *models.Product) VariantResponse {
+	options := variant.Options
+	if options == nil {
+		options = map[string]string{}
+	}
+	return VariantResponse{
+		ID:            variant.ID,
+		ProductID:     variant.ProductID,
+		SKU:           variant.SKU,
+		Price:         variant.EffectivePrice(product),
+		PriceOverride: variant.Price != nil,
+		Barcode:       variant.Barcode,
+		Options:       options,
+		Stock:         variant.Stock,
+	}
+}
+
+func (r OptionTypeRequest) ToModel() *models.OptionType {
+	optionType := &models.OptionType{Name: r.Name}
+	for _, value := range r.Values {
+		optionType.Values = append(optionType.Values, models.OptionValue{Value: value})
+	}
+	return optionType
+}
+
+func NewOptionTypeResponses(types []models.OptionType) []OptionTypeResponse {
+	responses := make([]OptionTypeResponse, 0, len(types))
+	for _, optionType := range types {
+		values := make([]string, 0, len(optionType.Values))
+		for _, value := range optionType.Values {
+			values = append(values, value.Value)
+		}
+		responses = append(responses, OptionTypeResponse{ID: optionType.ID, Name: optionType.Name, Values: values})
+	}
+	return responses
+}
@@ -0,0 +1,163 @@
+package dto
+
+import (
+	"ecommerce/models"
+	"time"
+)
+
+// PromotionRequest creates or replaces a promotion. Leave code empty for a
+// promotion that applies automatically, amounts default to the store's currency.
+type PromotionRequest struct {
+	Name             string               `json:"name"`
+	Code             string               `json:"code"`
+	Kind             models.PromotionKind `json:"kind"`
+	Percent          int                  `json:"percent"`
+	Amount           models.Money         `json:"amount"`
+	BuyQuantity      int                  `json:"buy_quantity"`
+	GetQuantity      int                  `json:"get_quantity"`
+	MinSubtotal      models.Money         `json:"min_subtotal"`
+	ProductIDs       []int                `json:"product_ids"`
+	CategoryIDs      []int                `json:"category_ids"`
+	StartsAt         *time.Time           `json:"starts_at"`
+	EndsAt           *time.Time           `json:"ends_at"`
+	UsageLimit       int                  `json:"usage_limit"`
+	PerCustomerLimit int                  `json:"per_customer_limit"`
+	Stackable        bool                 `json:"stackable"`
+	Priority         int                  `json:"priority"`
+	Active           bool                 `json:"active"`
+}
+
+type PromotionResponse struct {
+	ID               int                  `json:"id"`
+	Name             string               `json:"name"`
+	Code             string               `json:"code,omitempty"`
+	Kind             models.PromotionKind `json:"kind"`
+	Percent          int                  `json:"percent,omitempty"`
+	Amount           *models.Money        `json:"amount,omitempty"`
+	BuyQuantity      int                  `json:"buy_quantity,omitempty"`
+	GetQuantity      int                  `json:"get_quantity,omitempty"`
+	MinSubtotal      models.Money         `json:"min_subtotal"`
+	ProductIDs       []int                `json:"product_ids"`
+	CategoryIDs      []int                `json:"category_ids"`
+	StartsAt         *time.Time           `json:"starts_at,omitempty"`
+	EndsAt           *time.Time           `json:"ends_at,omitempty"`
+	UsageLimit       int                  `json:"usage_limit"`
+	PerCustomerLimit int                  `json:"per_customer_limit"`
+	UsageCount       int                  `json:"usage_count"`
+	Stackable        bool                 `json:"stackable"`
+	Priority         int                  `json:"priority"`
+	Active           bool                 `json:"active"`
+	CreatedAt        time.Time            `json:"created_at"`
+	UpdatedAt        time.Time            `json:"updated_at"`
+}
+
+// DiscountResponse is what one promotion took off one line, item_id is left
+// out for discounts on the whole order like free shipping
+type DiscountResponse struct {
+	PromotionID *int                 `json:"promotion_id,omitempty"`
+	Name        string               `json:"name"`
+	Code        string               `json:"code,omitempty"`
+	Kind        models.PromotionKind `json:"kind"`
+	ItemID      *int                 `json:"item_id,omitempty"`
+	Amount      models.Money         `json:"amount"`
+}
+
+// CartDiscountsResponse previews what checking out the cart would take off
+type CartDiscountsResponse struct {
+	Subtotal     models.Money       `json:"subtotal"`
+	Discount     models.Money       `json:"discount"`
+	Total        models.Money       `json:"total"`
+	FreeShipping bool               `json:"free_shipping"`
+	Discounts    []DiscountResponse `json:"discounts"`
+}
+
+func (r PromotionRequest) ToModel() *models.Promotion {
+	return &models.Promotion{
+		Name:             r.Name,
+		Code:             r.Code,
+		Kind:             r.Kind,
+		Percent:          r.Percent,
+		Amount:           r.Amount,
+		BuyQuantity:      r.BuyQuantity,
+		GetQuantity:      r.GetQuantity,
+		MinSubtotal:      r.MinSubtotal,
+		ProductIDs:       r.ProductIDs,
+		CategoryIDs:      r.CategoryIDs,
+		StartsAt:         r.StartsAt,
+		EndsAt:           r.EndsAt,
+		UsageLimit:       r.UsageLimit,
+		PerCustomerLimit: r.PerCustomerLimit,
+		Stackable:        r.Stackable,
+		Priority:         r.Priority,
+		Active:           r.Active,
+	}
+}
+
+func NewPromotionResponse(p *models.Promotion) PromotionResponse {
+	response := PromotionResponse{
+		ID:               p.ID,
+		Name:             p.Name,
+		Code:             p.Code,
+		Kind:             p.Kind,
+		Percent:          p.Percent,
+		BuyQuantity:      p.BuyQuantity,
+		GetQuantity:      p.GetQuantity,
+		MinSubtotal:      p.MinSubtotal,
+		ProductIDs:       p.ProductIDs,
+		CategoryIDs:      p.CategoryIDs,
+		StartsAt:         p.StartsAt,
+		EndsAt:           p.EndsAt,
+		UsageLimit:       p.UsageLimit,
+		PerCustomerLimit: p.PerCustomerLimit,
+		UsageCount:       p.UsageCount,
+		Stackable:        p.Stackable,
+		Priority:         p.Priority,
+		Active:           p.Active,
+		CreatedAt:        p.CreatedAt,
+		UpdatedAt:        p.UpdatedAt,
+	}
+	if p.Kind == models.PromotionFixed {
+		amount := p.Amount
+		response.Amount = &amount
+	}
+	if response.ProductIDs == nil {
+		response.ProductIDs = []int{}
+	}
+	if response.CategoryIDs == nil {
+		response.CategoryIDs = []int{}
+	}
+	return response
+}
+
+func NewPromotionResponses(promotions []models.Promotion) []PromotionResponse {
+	responses := make([]PromotionResponse, 0, len(promotions))
+	for i := range promotions {
+		responses = append(responses, NewPromotionResponse(&promotions[i]))
+	}
+	return responses
+}
+
+// NewCartDiscountsResponse ties the discounts to the cart's lines
+func NewCartDiscountsResponse(cart *models.Cart, result *models.PromotionResult) (CartDiscountsResponse, error) {
+	discounts := make([]DiscountResponse, 0, len(result.Discounts))
+	for _, discount := range result.Discounts {
+		promotionID := discount.PromotionID
+		response := DiscountResponse{PromotionID: &promotionID, Name: discount.Name, Code: discount.Code, Kind: discount.Kind, Amount: discount.Amount}
+		if discount.Line >= 0 {
+			itemID := cart.Items[discount.Line].ID
+			response.ItemID = &itemID
+		}
+		discounts = append(discounts, response)
+	}
+	total, err := result.Total()
+	if err != nil {
+		return CartDiscountsResponse{}, err
+	}
+	return CartDiscountsResponse{
+		Subtotal:     result.Subtotal,
+		Discount:     result.Discount,
+		Total:        total,
+		FreeShipping: result.FreeShipping,
+		Discounts:    discounts,
+	}, nil
+}
@@ -0,0 +1,117 @@
+package dto
+
+import (
+	"ecommerce/models"
+	"time"
+)
+
+// ReturnRequest asks to return quantities of order lines, e.g.
+// {"reason":"too small","items":[{"order_item_id":3,"quantity":1}]}
+type ReturnRequest struct {
+	Reason string              `json:"reason"`
+	Items  []ReturnItemRequest `json:"items"`
+}
+
+type ReturnItemRequest struct {
+	OrderItemID int `json:"order_item_id"`
+	Quantity    int `json:"quantity"`
+}
+
+// ReturnNoteRequest approves or rejects a return, the note is shown to the customer
+type ReturnNoteRequest struct {
+	Note string `json:"note"`
+}
+
+// ReceiveReturnRequest books a return in, restock lists the return items
+// that go back into stock in warehouse_id
+type ReceiveReturnRequest struct {
+	WarehouseID int    `json:"warehouse_id"`
+	Restock     []int  `json:"restock"`
+	Note        string `json:"note"`
+}
+
+type ReturnResponse struct {
+	ID           int                  `json:"id"`
+	OrderID      int                  `json:"order_id"`
+	UserID       int                  `json:"user_id,omitempty"`
+	Status       models.ReturnStatus  `json:"status"`
+	Reason       string               `json:"reason"`
+	Note         string               `json:"note,omitempty"`
+	Items        []ReturnItemResponse `json:"items"`
+	Value        models.Money         `json:"value"`
+	RefundAmount models.Money         `json:"refund_amount"`
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+}
+
+type ReturnItemResponse struct {
+	ID          int          `json:"id"`
+	OrderItemID int          `json:"order_item_id"`
+	SKU         string       `json:"sku,omitempty"`
+	Name        string       `json:"name"`
+	Quantity    int          `json:"quantity"`
+	UnitPrice   models.Money `json:"unit_price"`
+	LineTotal   models.Money `json:"line_total"`
+	Restocked   bool         `json:"restocked"`
+}
+
+func (r ReturnRequest) ToModel(userID, orderID int) *models.Return {
+	ret := &models.Return{OrderID: orderID, UserID: userID, Reason: r.Reason}
+	for _, item := range r.Items {
+		ret.Items = append(ret.Items, models.ReturnItem{OrderItemID: item.OrderItemID, Quantity: item.Quantity})
+	}
+	return ret
+}
+
+func (r ReceiveReturnRequest) ToModel(returnID int) *models.ReturnReceipt {
+	return &models.ReturnReceipt{ReturnID: returnID, WarehouseID: r.WarehouseID, Restock: r.Restock, Note: r.Note}
+}
+
+func NewReturnResponse(ret *models.Return) (ReturnResponse, error) {
+	value, err := ret.Value()
+	if err != nil {
+		return ReturnResponse{}, err
+	}
+	items := make([]ReturnItemResponse, 0, len(ret.Items))
+	for _, item := range ret.Items {
+		lineTotal, err := item.LineTotal()
+		if err != nil {
+			return ReturnResponse{}, err
+		}
+		items = append(items, ReturnItemResponse{
+			ID:          item.ID,
+			OrderItemID: item.OrderItemID,
+			SKU:         item.SKU,
+			Name:        item.Name,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			LineTotal:   lineTotal,
+			Restocked:   item.Restocked,
+		})
+	}
+	return ReturnResponse{
+		ID:           ret.ID,
+		OrderID:      ret.OrderID,
+		UserID:       ret.UserID,
+		Status:       ret.Status,
+		Reason:       ret.Reason,
+		Note:         ret.Note,
+		Items:        items,
+		Value:        value,
+		RefundAmount: ret.RefundAmount,
+		CreatedAt:    ret.CreatedAt,
+		UpdatedAt:    ret.UpdatedAt,
+	}, nil
+}
+
+func NewReturnResponses(returns []models.Return) ([]ReturnResponse, error) {
+	responses := make([]ReturnResponse, 0, len(returns))
+	for i := range returns {
+		response, err := NewReturnResponse(&returns[i])
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
@@ -0,0 +1,163 @@
+package dto
+
+import (
+	"ecommerce/models"
+	"time"
+)
+
+// ZoneLocationPayload is a place a zone covers. Leave region and postcode
+// empty for the whole country, a postcode ending in * covers every postcode
+// starting with the rest.
+type ZoneLocationPayload struct {
+	Country  string `json:"country"`
+	Region   string `json:"region,omitempty"`
+	Postcode string `json:"postcode,omitempty"`
+}
+
+// ShippingZoneRequest creates or replaces a zone, locations included
+type ShippingZoneRequest struct {
+	Name      string                `json:"name"`
+	Locations []ZoneLocationPayload `json:"locations"`
+}
+
+type ShippingZoneResponse struct {
+	ID        int                   `json:"id"`
+	Name      string                `json:"name"`
+	Locations []ZoneLocationPayload `json:"locations"`
+	CreatedAt time.Time             `json:"created_at"`
+	UpdatedAt time.Time             `json:"updated_at"`
+}
+
+// ShippingTierPayload is a row of a rate table. Min is grams for weight
+// based methods and minor units of the subtotal for price based ones.
+type ShippingTierPayload struct {
+	Min   int64        `json:"min"`
+	Price models.Money `json:"price"`
+}
+
+// ShippingMethodRequest creates or replaces a method with its rate table, a
+// flat method has a single tier from 0
+type ShippingMethodRequest struct {
+	ZoneID        int                     `json:"zone_id"`
+	Name          string                  `json:"name"`
+	Kind          models.ShippingRateKind `json:"kind"`
+	Currency      string                  `json:"currency"`
+	Tiers         []ShippingTierPayload   `json:"tiers"`
+	EstimatedDays int                     `json:"estimated_days"`
+	Active        bool                    `json:"active"`
+}
+
+type ShippingMethodResponse struct {
+	ID            int                     `json:"id"`
+	ZoneID        int                     `json:"zone_id"`
+	Name          string                  `json:"name"`
+	Kind          models.ShippingRateKind `json:"kind"`
+	Currency      string                  `json:"currency"`
+	Tiers         []ShippingTierPayload   `json:"tiers"`
+	EstimatedDays int                     `json:"estimated_days,omitempty"`
+	Active        bool                    `json:"active"`
+	CreatedAt     time.Time               `json:"created_at"`
+	UpdatedAt     time.Time               `json:"updated_at"`
+}
+
+// ShippingOptionResponse is a way of shipping the cart. Cost is what the
+// customer pays, below price when a promotion makes shipping free.
+type ShippingOptionResponse struct {
+	Code          string       `json:"code"`
+	Provider      string       `json:"provider"`
+	Name          string       `json:"name"`
+	Price         models.Money `json:"price"`
+	Cost          models.Money `json:"cost"`
+	EstimatedDays int          `json:"estimated_days,omitempty"`
+}
+
+type CartShippingOptionsResponse struct {
+	Destination DestinationRequest       `json:"destination"`
+	Options     []ShippingOptionResponse `json:"options"`
+}
+
+func (r ShippingZoneRequest) ToModel() *models.ShippingZone {
+	zone := &models.ShippingZone{Name: r.Name, Locations: make([]models.ZoneLocation, 0, len(r.Locations))}
+	for _, location := range r.Locations {
+		zone.Locations = append(zone.Locations, models.ZoneLocation{Country: location.Country, Region: location.Region, Postcode: location.Postcode})
+	}
+	return zone
+}
+
+func (r ShippingMethodRequest) ToModel() *models.ShippingMethod {
+	method := &models.ShippingMethod{
+		ZoneID:        r.ZoneID,
+		Name:          r.Name,
+		Kind:          r.Kind,
+		Currency:      r.Currency,
+		Tiers:         make([]models.ShippingTier, 0, len(r.Tiers)),
+		EstimatedDays: r.EstimatedDays,
+		Active:        r.Active,
+	}
+	for _, tier := range r.Tiers {
+		method.Tiers = append(method.Tiers, models.ShippingTier{Min: tier.Min, Price: tier.Price})
+	}
+	return method
+}
+
+func NewShippingZoneResponse(zone *models.ShippingZone) ShippingZoneResponse {
+	locations := make([]ZoneLocationPayload, 0, len(zone.Locations))
+	for _, location := range zone.Locations {
+		locations = append(locations, ZoneLocationPayload{Country: location.Country, Region: location.Region, Postcode: location.Postcode})
+	}
+	return ShippingZoneResponse{ID: zone.ID, Name: zone.Name, Locations: locations, CreatedAt: zone.CreatedAt, UpdatedAt: zone.UpdatedAt}
+}
+
+func NewShippingZoneResponses(zones []models.ShippingZone) []ShippingZoneResponse {
+	responses := make([]ShippingZoneResponse, 0, len(zones))
+	for i := range zones {
+		responses = append(responses, NewShippingZoneResponse(&zones[i]))
+	}
+	return responses
+}
+
+func NewShippingMethodResponse(method *models.ShippingMethod) ShippingMethodResponse {
+	tiers := make([]ShippingTierPayload, 0, len(method.Tiers))
+	for _, tier := range method.Tiers {
+		tiers = append(tiers, ShippingTierPayload{Min: tier.Min, Price: tier.Price})
+	}
+	return ShippingMethodResponse{
+		ID:            method.ID,
+		ZoneID:        method.ZoneID,
+		Name:          method.Name,
+		Kind:          method.Kind,
+		Currency:      method.Currency,
+		Tiers:         tiers,
+		EstimatedDays: method.EstimatedDays,
+		Active:        method.Active,
+		CreatedAt:     method.CreatedAt,
+		UpdatedAt:     method.UpdatedAt,
+	}
+}
+
+func NewShippingMethodResponses(methods []models.ShippingMethod) []ShippingMethodResponse {
+	responses := make([]ShippingMethodResponse, 0, len(methods))
+	for i := range methods {
+		responses = append(responses, NewShippingMethodResponse(&methods[i]))
+	}
+	return responses
+}
+
+func NewCartShippingOptionsResponse(destination models.Destination, quotes []models.ShippingQuote) CartShippingOptionsResponse {
+	options := make([]ShippingOptionResponse, 0, len(quotes))
+	for _, quote := range quotes {
+		options = append(options, ShippingOptionResponse{
+			Code:          quote.Code,
+			Provider:      quote.Provider,
+			Name:          quote.Name,
+			Price:         quote.Price,
+			Cost:          quote.Cost,
+			EstimatedDays: quote.EstimatedDays,
+		})
+	}
+	destination = destination.Normalize()
+	return CartShippingOptionsResponse{
+		Destination: DestinationRequest{Country: destination.Country, Region: destination.Region, Postcode: destination.Postcode},
+		Options:     options,
+	}
+}
@@ -0,0 +1,155 @@
+package dto
+
+import (
+	"ecommerce/models"
+	"time"
+)
+
+// DestinationRequest is where an order ships, for taxes. Region and
+// postcode only matter where rates differ by them.
+type DestinationRequest struct {
+	Country  string `json:"country"`
+	Region   string `json:"region"`
+	Postcode string `json:"postcode"`
+}
+
+type TaxClassRequest struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+type TaxClassResponse struct {
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TaxRateRequest creates or replaces a row of the rate table. Leave region
+// and postcode empty for the whole country, a postcode ending in * covers
+// every postcode starting with the rest.
+type TaxRateRequest struct {
+	TaxClass string         `json:"tax_class"`
+	Country  string         `json:"country"`
+	Region   string         `json:"region"`
+	Postcode string         `json:"postcode"`
+	Rate     models.Percent `json:"rate"`
+	Name     string         `json:"name"`
+}
+
+type TaxRateResponse struct {
+	ID        int            `json:"id"`
+	TaxClass  string         `json:"tax_class"`
+	Country   string         `json:"country"`
+	Region    string         `json:"region,omitempty"`
+	Postcode  string         `json:"postcode,omitempty"`
+	Rate      models.Percent `json:"rate"`
+	Name      string         `json:"name"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// LineTaxResponse is the tax on a cart line after its discounts
+type LineTaxResponse struct {
+	ItemID   int            `json:"item_id"`
+	TaxClass string         `json:"tax_class"`
+	Name     string         `json:"name,omitempty"`
+	Rate     models.Percent `json:"rate"`
+	Net      models.Money   `json:"net"`
+	Tax      models.Money   `json:"tax"`
+	Gross    models.Money   `json:"gross"`
+}
+
+// CartTaxesResponse previews the tax checking out the cart would charge.
+// Total is what the customer pays, the discounted subtotal plus the tax
+// unless the prices already contain it.
+type CartTaxesResponse struct {
+	Destination      DestinationRequest `json:"destination"`
+	Subtotal         models.Money       `json:"subtotal"`
+	Discount         models.Money       `json:"discount"`
+	Tax              models.Money       `json:"tax"`
+	PricesIncludeTax bool               `json:"prices_include_tax"`
+	Total            models.Money       `json:"total"`
+	Lines            []LineTaxResponse  `json:"lines"`
+}
+
+func (r DestinationRequest) ToModel() models.Destination {
+	return models.Destination{Country: r.Country, Region: r.Region, Postcode: r.Postcode}
+}
+
+func (r TaxClassRequest) ToModel() *models.TaxClass {
+	return &models.TaxClass{Code: r.Code, Name: r.Name}
+}
+
+func (r TaxRateRequest) ToModel() *models.TaxRate {
+	return &models.TaxRate{TaxClass: r.TaxClass, Country: r.Country, Region: r.Region, Postcode: r.Postcode, Rate: r.Rate, Name: r.Name}
+}
+
+func NewTaxClassResponses(classes []models.TaxClass) []TaxClassResponse {
+	responses := make([]TaxClassResponse, 0, len(classes))
+	for i := range classes {
+		responses = append(responses, NewTaxClassResponse(&classes[i]))
+	}
+	return responses
+}
+
+func NewTaxClassResponse(class *models.TaxClass) TaxClassResponse {
+	return TaxClassResponse{Code: class.Code, Name: class.Name, CreatedAt: class.CreatedAt}
+}
+
+func NewTaxRateResponse(rate *models.TaxRate) TaxRateResponse {
+	return TaxRateResponse{
+		ID:        rate.ID,
+		TaxClass:  rate.TaxClass,
+		Country:   rate.Country,
+		Region:    rate.Region,
+		Postcode:  rate.Postcode,
+		Rate:      rate.Rate,
+		Name:      rate.Name,
+		CreatedAt: rate.CreatedAt,
+		UpdatedAt: rate.UpdatedAt,
+	}
+}
+
+func NewTaxRateResponses(rates []models.TaxRate) []TaxRateResponse {
+	responses := make([]TaxRateResponse, 0, len(rates))
+	for i := range rates {
+		responses = append(responses, NewTaxRateResponse(&rates[i]))
+	}
+	return responses
+}
+
+// NewCartTaxesResponse ties the tax breakdown to the cart's lines
+func NewCartTaxesResponse(cart *models.Cart, promotions *models.PromotionResult, taxes *models.TaxResult) (CartTaxesResponse, error) {
+	lines := make([]LineTaxResponse, 0, len(taxes.Lines))
+	for _, line := range taxes.Lines {
+		lines = append(lines, LineTaxResponse{
+			ItemID:   cart.Items[line.Line].ID,
+			TaxClass: line.TaxClass,
+			Name:     line.Name,
+			Rate:     line.Rate,
+			Net:      line.Net,
+			Tax:      line.Tax,
+			Gross:    line.Gross,
+		})
+	}
+	inclusive := taxes.Mode == models.TaxInclusive
+	total, err := promotions.Total()
+	if err != nil {
+		return CartTaxesResponse{}, err
+	}
+	if !inclusive {
+		if total, err = total.Add(taxes.Tax); err != nil {
+			return CartTaxesResponse{}, err
+		}
+	}
+	destination := taxes.Destination
+	return CartTaxesResponse{
+		Destination:      DestinationRequest{Country: destination.Country, Region: destination.Region, Postcode: destination.Postcode},
+		Subtotal:         promotions.Subtotal,
+		Discount:         promotions.Discount,
+		Tax:              taxes.Tax,
+		PricesIncludeTax: inclusive,
+		Total:            total,
+		Lines:            lines,
+	}, nil
+}
@@ -0,0 +1,91 @@
+package dto
+
+import "ecommerce/models"
+
+// request bodies accepted by the user endpoints
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type CreateUserRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// UpdateUserRequest is the admin update, empty fields are left unchanged
+type UpdateUserRequest struct {
+	Name     string      `json:"name"`
+	Email    string      `json:"email"`
+	Username string      `json:"username"`
+	Password string      `json:"password"`
+	Role     models.Role `json:"role"`
+}
+
+// UpdateMeRequest is the self-service update, email and password changes need CurrentPassword
+type UpdateMeRequest struct {
+	Name            string `json:"name"`
+	Email           string `json:"email"`
+	Username        string `json:"username"`
+	Password        string `json:"password"`
+	CurrentPassword string `json:"current_password"`
+}
+
+// responses, secrets are never part of them
+
+type UserResponse struct {
+	ID       int         `json:"id"`
+	Name     string      `json:"name"`
+	Email    string      `json:"email"`
+	Username string      `json:"username"`
+	Role     models.Role `json:"role"`
+}
+
+func (r CreateUserRequest) ToModel() *models.User {
+	return &models.User{
+		Name:     r.Name,
+		Email:    r.Email,
+		Username: r.Username,
+		Password: r.Password,
+	}
+}
+
+// ApplyTo copies the non-empty fields onto an existing user
+func (r UpdateUserRequest) ApplyTo(user *models.User) {
+	if r.Name != "" {
+		user.Name = r.Name
+	}
+	if r.Email != "" {
+		user.Email = r.Email
+	}
+	if r.Username != "" {
+		user.Username = r.Username
+	}
+	if r.Password != "" {
+		user.Password = r.Password
+	}
+	if r.Role != "" {
+		user.Role = r.Role
+	}
+}
+
+func NewUserResponse(user *models.User) UserResponse {
+	return UserResponse{
+		ID:       user.Id,
+		Name:     user.Name,
+		Email:    user.Email,
+		Username: user.Username,
+		Role:     user.Role,
+	}
+}
+
+func NewUserResponses(users []models.User) []UserResponse {
+	responses := make([]UserResponse, 0, len(users))
+	for i := range users {
+		responses = append(responses, NewUserResponse(&users[i]))
+	}
+	return responses
+}
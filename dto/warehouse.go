@@ -0,0 +1,101 @@
+package dto
+
+import "ecommerce/models"
+
+// WarehouseRequest is accepted by create and update, a location needs both
+// latitude and longitude. Active defaults to true.
+type WarehouseRequest struct {
+	Code      string   `json:"code"`
+	Name      string   `json:"name"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Priority  int      `json:"priority"`
+	Active    *bool    `json:"active"`
+}
+
+type WarehouseResponse struct {
+	ID        int      `json:"id"`
+	Code      string   `json:"code"`
+	Name      string   `json:"name"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Priority  int      `json:"priority"`
+	Active    bool     `json:"active"`
+}
+
+// TransferRequest moves stock of a product, or of one of its variants, between warehouses
+type TransferRequest struct {
+	ProductID       int    `json:"product_id"`
+	VariantID       *int   `json:"variant_id"`
+	FromWarehouseID int    `json:"from_warehouse_id"`
+	ToWarehouseID   int    `json:"to_warehouse_id"`
+	Quantity        int    `json:"quantity"`
+	Note            string `json:"note"`
+}
+
+type TransferResponse struct {
+	ID              int  `json:"id"`
+	ProductID       int  `json:"product_id"`
+	VariantID       *int `json:"variant_id,omitempty"`
+	FromWarehouseID int  `json:"from_warehouse_id"`
+	ToWarehouseID   int  `json:"to_warehouse_id"`
+	Quantity        int  `json:"quantity"`
+}
+
+func (r WarehouseRequest) ToModel() *models.Warehouse {
+	warehouse := &models.Warehouse{
+		Code:     r.Code,
+		Name:     r.Name,
+		Priority: r.Priority,
+		Active:   r.Active == nil || *r.Active,
+	}
+	if r.Latitude != nil && r.Longitude != nil {
+		warehouse.Location = &models.GeoPoint{Latitude: *r.Latitude, Longitude: *r.Longitude}
+	}
+	return warehouse
+}
+
+func NewWarehouseResponse(warehouse *models.Warehouse) WarehouseResponse {
+	response := WarehouseResponse{
+		ID:       warehouse.ID,
+		Code:     warehouse.Code,
+		Name:     warehouse.Name,
+		Priority: warehouse.Priority,
+		Active:   warehouse.Active,
+	}
+	if location := warehouse.Location; location != nil {
+		response.Latitude = &location.Latitude
+		response.Longitude = &location.Longitude
+	}
+	return response
+}
+
+func NewWarehouseResponses(warehouses []models.Warehouse) []WarehouseResponse {
+	responses := make([]WarehouseResponse, 0, len(warehouses))
+	for i := range warehouses {
+		responses = append(responses, NewWarehouseResponse(&warehouses[i]))
+	}
+	return responses
+}
+
+func (r TransferRequest) ToModel() *models.Transfer {
+	return &models.Transfer{
+		ProductID:       r.ProductID,
+		VariantID:       r.VariantID,
+		FromWarehouseID: r.FromWarehouseID,
+		ToWarehouseID:   r.ToWarehouseID,
+		Quantity:        r.Quantity,
+		Note:            r.Note,
+	}
+}
+
+func NewTransferResponse(transfer *models.Transfer) TransferResponse {
+	return TransferResponse{
+		ID:              transfer.ID,
+		ProductID:       transfer.ProductID,
+		VariantID:       transfer.VariantID,
+		FromWarehouseID: transfer.FromWarehouseID,
+		ToWarehouseID:   transfer.ToWarehouseID,
+		Quantity:        transfer.Quantity,
+	}
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"ecommerce/models"
+	"ecommerce/services"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAddressService struct {
+	mock.Mock
+}
+
+func (m *MockAddressService) GetAddresses(ctx context.Context, userID int) ([]models.Address, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]models.Address), args.Error(1)
+}
+
+func (m *MockAddressService) GetAddress(ctx context.Context, userID, id int) (*models.Address, error) {
+	args := m.Called(userID, id)
+	if address := args.Get(0); address != nil {
+		return address.(*models.Address), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAddressService) CreateAddress(ctx context.Context, address *models.Address) error {
+	args := m.Called(address)
+	return args.Error(0)
+}
+
+func (m *MockAddressService) UpdateAddress(ctx context.Context, address *models.Address) error {
+	args := m.Called(address)
+	return args.Error(0)
+}
+
+func (m *MockAddressService) DeleteAddress(ctx context.Context, userID, id int) error {
+	args := m.Called(userID, id)
+	return args.Error(0)
+}
+
+func TestCreateMyAddressHandler(t *testing.T) {
+	mockService := new(MockAddressService)
+	handler := NewAddressHandler(mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("CreateAddress", mock.MatchedBy(func(address *models.Address) bool {
+			return address.UserID == 7 && address.Postcode == "10001" && address.DefaultShipping
+		})).Run(func(args mock.Arguments) {
+			args.Get(0).(*models.Address).ID = 4
+		}).Return(nil).Once()
+
+		body := `{"name":"Abhay","line1":"350 5th Ave","city":"New York","region":"NY","postcode":"10001","country":"US","default_shipping":true}`
+		res := httptest.NewRecorder()
+		handler.CreateMyAddress(res, withCustomer(httptest.NewRequest("POST", "/me/addresses", bytes.NewBufferString(body))))
+
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Contains(t, res.Body.String(), `"id":4`)
+		assert.NotContains(t, res.Body.String(), `"company"`)
+	})
+	t.Run("Invalid postcode", func(t *testing.T) {
+		mockService.On("CreateAddress", mock.Anything).
+			Return(fmt.Errorf("%w: \"1000\" is not a postcode of US", services.ErrInvalidAddress)).Once()
+
+		body := `{"name":"Abhay","line1":"350 5th Ave","city":"New York","postcode":"1000","country":"US"}`
+		res := httptest.NewRecorder()
+		handler.CreateMyAddress(res, withCustomer(httptest.NewRequest("POST", "/me/addresses", bytes.NewBufferString(body))))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+	t.Run("Unauthenticated", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		handler.CreateMyAddress(res, httptest.NewRequest("POST", "/me/addresses", bytes.NewBufferString(`{}`)))
+
+		assert.Equal(t, http.StatusUnauthorized, res.Code)
+	})
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteMyAddressHandler(t *testing.T) {
+	mockService := new(MockAddressService)
+	handler := NewAddressHandler(mockService)
+
+	mockService.On("DeleteAddress", 7, 9).Return(services.ErrAddressNotFound).Once()
+
+	res := httptest.NewRecorder()
+	handler.DeleteMyAddress(res, withURLParam(withCustomer(httptest.NewRequest("DELETE", "/me/addresses/9", nil)), "id", "9"))
+
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	mockService.AssertExpectations(t)
+}
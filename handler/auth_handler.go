@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"ecommerce/dto"
+	"ecommerce/services"
+	"ecommerce/utils"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+type AuthHandler struct {
+	tokenService services.TokenService
+	keys         *utils.KeyManager
+}
+
+func NewAuthHandler(tokenService services.TokenService, keys *utils.KeyManager) *AuthHandler {
+	return &AuthHandler{tokenService: tokenService, keys: keys}
+}
+
+func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var request dto.RefreshRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.RefreshToken == "" {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.tokenService.Refresh(r.Context(), request.RefreshToken)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewTokenResponse(tokens))
+}
+
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	principal, ok := utils.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request dto.LogoutRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil && err != io.EOF { // the body is optional
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	err = h.tokenService.Logout(r.Context(), principal, request.RefreshToken, request.AllSessions)
+	if err != nil {
+		http.Error(w, "Failed to logout", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out successfully"})
+}
+
+// JWKS publishes the public verification keys so other services can validate our tokens
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.keys.JWKS())
+}
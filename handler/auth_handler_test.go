@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"ecommerce/dto"
+	"ecommerce/models"
+	"ecommerce/services"
+	"ecommerce/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockTokenService struct {
+	mock.Mock
+}
+
+func (m *MockTokenService) Issue(ctx context.Context, user *models.User) (*models.TokenPair, error) {
+	args := m.Called(user)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.TokenPair), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTokenService) Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	args := m.Called(refreshToken)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.TokenPair), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTokenService) Logout(ctx context.Context, principal *utils.Principal, refreshToken string, allSessions bool) error {
+	args := m.Called(principal, refreshToken, allSessions)
+	return args.Error(0)
+}
+
+func TestRefreshTokenHandler(t *testing.T) {
+	mockService := new(MockTokenService)
+	handler := NewAuthHandler(mockService, nil)
+
+	t.Run("Success", func(t *testing.T) {
+		pair := &models.TokenPair{AccessToken: "new-access", AccessExpiresAt: time.Now().Add(15 * time.Minute), RefreshToken: "new-refresh"}
+		mockService.On("Refresh", "old-refresh").Return(pair, nil)
+
+		req := httptest.NewRequest("POST", "/token/refresh", bytes.NewBufferString(`{"refresh_token": "old-refresh"}`))
+		res := httptest.NewRecorder()
+
+		handler.RefreshToken(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		var resp dto.TokenResponse
+		json.Unmarshal(res.Body.Bytes(), &resp)
+		assert.Equal(t, "new-access", resp.AccessToken)
+		assert.Equal(t, "new-refresh", resp.RefreshToken)
+	})
+	t.Run("Missing token", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/token/refresh", bytes.NewBufferString(`{}`))
+		res := httptest.NewRecorder()
+
+		handler.RefreshToken(res, req)
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+	t.Run("Reused token", func(t *testing.T) {
+		mockService.On("Refresh", "stolen").Return(nil, services.ErrRefreshTokenReused)
+
+		req := httptest.NewRequest("POST", "/token/refresh", bytes.NewBufferString(`{"refresh_token": "stolen"}`))
+		res := httptest.NewRecorder()
+
+		handler.RefreshToken(res, req)
+
+		assert.Equal(t, http.StatusUnauthorized, res.Code)
+		assert.Contains(t, res.Body.String(), "reuse detected")
+	})
+}
+
+func TestLogoutHandler(t *testing.T) {
+	mockService := new(MockTokenService)
+	handler := NewAuthHandler(mockService, nil)
+	principal := &utils.Principal{UserID: 1, Username: "abhay123", TokenID: "jti-1"}
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("Logout", principal, "refresh", false).Return(nil).Once()
+
+		req := httptest.NewRequest("POST", "/logout", bytes.NewBufferString(`{"refresh_token": "refresh"}`))
+		req = req.WithContext(utils.WithPrincipal(req.Context(), principal))
+		res := httptest.NewRecorder()
+
+		handler.Logout(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		mockService.AssertExpectations(t)
+	})
+	t.Run("Empty body", func(t *testing.T) {
+		mockService.On("Logout", principal, "", false).Return(nil).Once()
+
+		req := httptest.NewRequest("POST", "/logout", nil)
+		req = req.WithContext(utils.WithPrincipal(req.Context(), principal))
+		res := httptest.NewRecorder()
+
+		handler.Logout(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code)
+	})
+	t.Run("Failure", func(t *testing.T) {
+		mockService.On("Logout", principal, "", true).Return(errors.New("database error")).Once()
+
+		req := httptest.NewRequest("POST", "/logout", bytes.NewBufferString(`{"all_sessions": true}`))
+		req = req.WithContext(utils.WithPrincipal(req.Context(), principal))
+		res := httptest.NewRecorder()
+
+		handler.Logout(res, req)
+
+		assert.Equal(t, http.StatusInternalServerError, res.Code)
+	})
+	t.Run("Unauthenticated", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/logout", nil)
+		res := httptest.NewRecorder()
+
+		handler.Logout(res, req)
+
+		assert.Equal(t, http.StatusUnauthorized, res.Code)
+	})
+}
+
+func TestJWKSHandler(t *testing.T) {
+	keys, err := utils.NewKeyManager([]utils.KeyConfig{
+		{ID: "hs", Algorithm: "HS256", Secret: "0123456789abcdef0123456789abcdef", Active: true},
+	})
+	assert.NoError(t, err)
+	handler := NewAuthHandler(new(MockTokenService), keys)
+
+	req := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
+	res := httptest.NewRecorder()
+
+	handler.JWKS(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "application/json", res.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"keys": []}`, res.Body.String()) // symmetric keys stay private
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"ecommerce/dto"
+	"ecommerce/models"
+	"ecommerce/services"
+	"ecommerce/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CartTokenHeader carries the guest cart token. Guests get it back when
+// their first item creates a cart and send it along with /login to keep
+// their cart.
+const CartTokenHeader = "X-Cart-Token"
+
+type CartHandler struct {
+	cartService services.CartService
+}
+
+func NewCartHandler(cartService services.CartService) *CartHandler {
+	return &CartHandler{cartService: cartService}
+}
+
+func (h *CartHandler) GetCart(w http.ResponseWriter, r *http.Request) {
+	cart, err := h.cartService.GetCart(r.Context(), cartOwner(r))
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+	response, err := dto.NewCartResponse(cart)
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *CartHandler) AddItem(w http.ResponseWriter, r *http.Request) {
+	var request dto.CartItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	cart, err := h.cartService.AddItem(r.Context(), cartOwner(r), request.ToModel())
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+	if cart.Token != "" {
+		w.Header().Set(CartTokenHeader, cart.Token)
+	}
+	response, err := dto.NewCartResponse(cart)
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateItem changes the quantity of a line, 0 removes it
+func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid cart item ID", http.StatusBadRequest)
+		return
+	}
+	var request dto.CartQuantityRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	cart, err := h.cartService.UpdateItem(r.Context(), cartOwner(r), itemID, request.Quantity)
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+	response, err := dto.NewCartResponse(cart)
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *CartHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid cart item ID", http.StatusBadRequest)
+		return
+	}
+
+	cart, err := h.cartService.RemoveItem(r.Context(), cartOwner(r), itemID)
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+	response, err := dto.NewCartResponse(cart)
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *CartHandler) ClearCart(w http.ResponseWriter, r *http.Request) {
+	if err := h.cartService.ClearCart(r.Context(), cartOwner(r)); err != nil {
+		writeCartError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// cartOwner is the signed in user, or the guest holding the cart token
+func cartOwner(r *http.Request) models.CartOwner {
+	if principal, ok := utils.PrincipalFromContext(r.Context()); ok {
+		return models.CartOwner{UserID: principal.UserID}
+	}
+	return models.CartOwner{GuestToken: r.Header.Get(CartTokenHeader)}
+}
+
+func writeCartError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrCartNotFound), errors.Is(err, services.ErrCartItemNotFound),
+		errors.Is(err, services.ErrProductNotFound), errors.Is(err, services.ErrVariantNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidCartItem):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
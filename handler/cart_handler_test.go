@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"ecommerce/models"
+	"ecommerce/services"
+	"ecommerce/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCartService struct {
+	mock.Mock
+}
+
+func (m *MockCartService) GetCart(ctx context.Context, owner models.CartOwner) (*models.Cart, error) {
+	args := m.Called(owner)
+	if cart := args.Get(0); cart != nil {
+		return cart.(*models.Cart), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCartService) AddItem(ctx context.Context, owner models.CartOwner, item models.CartItem) (*models.Cart, error) {
+	args := m.Called(owner, item)
+	if cart := args.Get(0); cart != nil {
+		return cart.(*models.Cart), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCartService) UpdateItem(ctx context.Context, owner models.CartOwner, itemID, quantity int) (*models.Cart, error) {
+	args := m.Called(owner, itemID, quantity)
+	if cart := args.Get(0); cart != nil {
+		return cart.(*models.Cart), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCartService) RemoveItem(ctx context.Context, owner models.CartOwner, itemID int) (*models.Cart, error) {
+	args := m.Called(owner, itemID)
+	if cart := args.Get(0); cart != nil {
+		return cart.(*models.Cart), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCartService) ClearCart(ctx context.Context, owner models.CartOwner) error {
+	args := m.Called(owner)
+	return args.Error(0)
+}
+
+func (m *MockCartService) MergeGuestCart(ctx context.Context, userID int, guestToken string) error {
+	args := m.Called(userID, guestToken)
+	return args.Error(0)
+}
+
+func TestGetCartHandler(t *testing.T) {
+	mockService := new(MockCartService)
+	handler := NewCartHandler(mockService)
+
+	t.Run("User", func(t *testing.T) {
+		cart := &models.Cart{ID: 3, UserID: 7, Currency: "USD", Items: []models.CartItem{{ID: 1, ProductID: 1, Name: "Shirt", Quantity: 2, UnitPrice: usd(1250), SavedPrice: usd(1000)}}}
+		mockService.On("GetCart", models.CartOwner{UserID: 7}).Return(cart, nil).Once()
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/cart", nil)
+		req.Header.Set(CartTokenHeader, "ignored-once-signed-in")
+		req = req.WithContext(utils.WithPrincipal(req.Context(), &utils.Principal{UserID: 7, Username: "abhay123"}))
+		handler.GetCart(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Contains(t, res.Body.String(), `"subtotal":{"amount":"25.00","currency":"USD"}`)
+		assert.Contains(t, res.Body.String(), `"previous_price":{"amount":"10.00","currency":"USD"}`)
+	})
+	t.Run("Guest", func(t *testing.T) {
+		mockService.On("GetCart", models.CartOwner{GuestToken: "guest-token"}).Return(&models.Cart{}, nil).Once()
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/cart", nil)
+		req.Header.Set(CartTokenHeader, "guest-token")
+		handler.GetCart(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Contains(t, res.Body.String(), `"items":[]`)
+	})
+	mockService.AssertExpectations(t)
+}
+
+func TestAddCartItemHandler(t *testing.T) {
+	mockService := new(MockCartService)
+	handler := NewCartHandler(mockService)
+
+	t.Run("New guest cart", func(t *testing.T) {
+		cart := &models.Cart{ID: 30, Token: "new-token", Currency: "USD", Items: []models.CartItem{{ID: 5, ProductID: 1, Quantity: 2, UnitPrice: usd(2000), SavedPrice: usd(2000)}}}
+		mockService.On("AddItem", models.CartOwner{}, models.CartItem{ProductID: 1, Quantity: 2}).Return(cart, nil).Once()
+
+		res := httptest.NewRecorder()
+		handler.AddItem(res, httptest.NewRequest("POST", "/cart/items", bytes.NewBufferString(`{"product_id":1,"quantity":2}`)))
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, "new-token", res.Header().Get(CartTokenHeader))
+		assert.Contains(t, res.Body.String(), `"token":"new-token"`)
+		assert.Contains(t, res.Body.String(), `"item_count":2`)
+	})
+	t.Run("Unknown product", func(t *testing.T) {
+		mockService.On("AddItem", models.CartOwner{}, models.CartItem{ProductID: 9, Quantity: 1}).Return(nil, services.ErrProductNotFound).Once()
+
+		res := httptest.NewRecorder()
+		handler.AddItem(res, httptest.NewRequest("POST", "/cart/items", bytes.NewBufferString(`{"product_id":9,"quantity":1}`)))
+
+		assert.Equal(t, http.StatusNotFound, res.Code)
+	})
+	t.Run("Invalid quantity", func(t *testing.T) {
+		mockService.On("AddItem", models.CartOwner{}, models.CartItem{ProductID: 1}).Return(nil, services.ErrInvalidCartItem).Once()
+
+		res := httptest.NewRecorder()
+		handler.AddItem(res, httptest.NewRequest("POST", "/cart/items", bytes.NewBufferString(`{"product_id":1}`)))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+}
+
+func TestUpdateCartItemHandler(t *testing.T) {
+	mockService := new(MockCartService)
+	handler := NewCartHandler(mockService)
+	mockService.On("UpdateItem", models.CartOwner{GuestToken: "guest-token"}, 8, 3).Return(nil, services.ErrCartItemNotFound)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("PATCH", "/cart/items/8", bytes.NewBufferString(`{"quantity":3}`))
+	req.Header.Set(CartTokenHeader, "guest-token")
+	handler.UpdateItem(res, withURLParam(req, "id", "8"))
+
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
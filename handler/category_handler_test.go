@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"ecommerce/models"
+	"ecommerce/services"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCategoryService struct {
+	mock.Mock
+}
+
+func (m *MockCategoryService) CreateCategory(ctx context.Context, category *models.Category) error {
+	args := m.Called(category)
+	return args.Error(0)
+}
+
+func (m *MockCategoryService) GetCategory(ctx context.Context, id int) (*models.Category, error) {
+	args := m.Called(id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Category), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCategoryService) GetCategoryTree(ctx context.Context) ([]models.Category, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Category), args.Error(1)
+}
+
+func (m *MockCategoryService) UpdateCategory(ctx context.Context, category *models.Category) error {
+	args := m.Called(category)
+	return args.Error(0)
+}
+
+func (m *MockCategoryService) DeleteCategory(ctx context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockCategoryService) GetCategoryProducts(ctx context.Context, id int, includeDescendants bool) ([]models.Product, error) {
+	args := m.Called(id, includeDescendants)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Product), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCategoryService) GetProductCategories(ctx context.Context, productID int) ([]models.Category, error) {
+	args := m.Called(productID)
+	return args.Get(0).([]models.Category), args.Error(1)
+}
+
+func (m *MockCategoryService) SetProductCategories(ctx context.Context, productID int, categoryIDs []int) error {
+	args := m.Called(productID, categoryIDs)
+	return args.Error(0)
+}
+
+// withURLParam routes the request as chi would for a {key} pattern
+func withURLParam(req *http.Request, key, value string) *http.Request {
+	chiCtx := chi.NewRouteContext()
+	chiCtx.URLParams.Add(key, value)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+}
+
+func TestGetCategoriesHandler(t *testing.T) {
+	mockService := new(MockCategoryService)
+	handler := NewCategoryHandler(mockService)
+	parent := 1
+	mockService.On("GetCategoryTree").Return([]models.Category{
+		{ID: 1, Name: "Electronics", Slug: "electronics", Children: []models.Category{{ID: 2, Name: "Laptops", Slug: "laptops", ParentID: &parent}}},
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/categories", nil)
+	res := httptest.NewRecorder()
+	handler.GetCategories(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `[{"id":1,"name":"Electronics","slug":"electronics","parent_id":null,
+		"children":[{"id":2,"name":"Laptops","slug":"laptops","parent_id":1}]}]`, res.Body.String())
+}
+
+func TestCreateCategoryHandler(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		mockService := new(MockCategoryService)
+		handler := NewCategoryHandler(mockService)
+		mockService.On("CreateCategory", mock.MatchedBy(func(c *models.Category) bool { return c.Name == "Books" })).
+			Run(func(args mock.Arguments) {
+				category := args.Get(0).(*models.Category)
+				category.ID, category.Slug = 3, "books"
+			}).
+			Return(nil)
+
+		req := httptest.NewRequest("POST", "/categories", bytes.NewBufferString(`{"name":"Books"}`))
+		res := httptest.NewRecorder()
+		handler.CreateCategory(res, req)
+
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.JSONEq(t, `{"id":3,"name":"Books","slug":"books","parent_id":null}`, res.Body.String())
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		mockService := new(MockCategoryService)
+		handler := NewCategoryHandler(mockService)
+		mockService.On("CreateCategory", mock.Anything).Return(services.ErrInvalidCategory)
+
+		req := httptest.NewRequest("POST", "/categories", bytes.NewBufferString(`{"name":""}`))
+		res := httptest.NewRecorder()
+		handler.CreateCategory(res, req)
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+}
+
+func TestUpdateCategoryHandler(t *testing.T) {
+	mockService := new(MockCategoryService)
+	handler := NewCategoryHandler(mockService)
+	mockService.On("GetCategory", 2).Return(&models.Category{ID: 2, Name: "Laptops"}, nil)
+	mockService.On("UpdateCategory", mock.Anything).Return(services.ErrCategoryCycle)
+
+	req := withURLParam(httptest.NewRequest("PUT", "/categories/2", bytes.NewBufferString(`{"name":"Laptops","parent_id":5}`)), "id", "2")
+	res := httptest.NewRecorder()
+	handler.UpdateCategory(res, req)
+
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	updated := mockService.Calls[1].Arguments.Get(0).(*models.Category)
+	assert.Equal(t, 2, updated.ID)
+	assert.Equal(t, 5, *updated.ParentID)
+}
+
+func TestDeleteCategoryHandler(t *testing.T) {
+	mockService := new(MockCategoryService)
+	handler := NewCategoryHandler(mockService)
+	mockService.On("DeleteCategory", 1).Return(services.ErrCategoryHasChildren)
+	mockService.On("DeleteCategory", 9).Return(services.ErrCategoryNotFound)
+
+	res := httptest.NewRecorder()
+	handler.DeleteCategory(res, withURLParam(httptest.NewRequest("DELETE", "/categories/1", nil), "id", "1"))
+	assert.Equal(t, http.StatusConflict, res.Code)
+
+	res = httptest.NewRecorder()
+	handler.DeleteCategory(res, withURLParam(httptest.NewRequest("DELETE", "/categories/9", nil), "id", "9"))
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestGetCategoryProductsHandler(t *testing.T) {
+	mockService := new(MockCategoryService)
+	handler := NewCategoryHandler(mockService)
+	mockService.On("GetCategoryProducts", 1, true).Return([]models.Product{{ID: 1, Name: "Laptop", Price: usd(6100000)}}, nil)
+	mockService.On("GetCategoryProducts", 1, false).Return([]models.Product{}, nil)
+
+	t.Run("With descendants", func(t *testing.T) {
+		req := withURLParam(httptest.NewRequest("GET", "/categories/1/products?include_descendants=true", nil), "id", "1")
+		res := httptest.NewRecorder()
+		handler.GetCategoryProducts(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		var products []map[string]interface{}
+		assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &products))
+		assert.Len(t, products, 1)
+	})
+	t.Run("Direct only", func(t *testing.T) {
+		req := withURLParam(httptest.NewRequest("GET", "/categories/1/products", nil), "id", "1")
+		res := httptest.NewRecorder()
+		handler.GetCategoryProducts(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.JSONEq(t, `[]`, res.Body.String())
+	})
+}
+
+func TestSetProductCategoriesHandler(t *testing.T) {
+	mockService := new(MockCategoryService)
+	handler := NewCategoryHandler(mockService)
+	mockService.On("SetProductCategories", 1, []int{2, 5}).Return(nil)
+
+	req := withURLParam(httptest.NewRequest("PUT", "/products/1/categories", bytes.NewBufferString(`{"category_ids":[2,5]}`)), "id", "1")
+	res := httptest.NewRecorder()
+	handler.SetProductCategories(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	mockService.AssertExpectations(t)
+}
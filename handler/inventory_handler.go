@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"ecommerce/dto"
+	"ecommerce/services"
+	"ecommerce/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type InventoryHandler struct {
+	inventoryService services.InventoryService
+}
+
+func NewInventoryHandler(inventoryService services.InventoryService) *InventoryHandler {
+	return &InventoryHandler{inventoryService: inventoryService}
+}
+
+// GetProductInventory shows on hand, reserved and available stock of a product and its variants
+func (h *InventoryHandler) GetProductInventory(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	items, err := h.inventoryService.GetProductInventory(r.Context(), productID)
+	if err != nil {
+		writeInventoryError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewInventoryItemResponses(items))
+}
+
+// AdjustStock records a stock change such as a delivery or a damaged unit
+func (h *InventoryHandler) AdjustStock(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	var request dto.AdjustmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	adjustment := request.ToModel(productID)
+	if principal, ok := utils.PrincipalFromContext(r.Context()); ok {
+		adjustment.CreatedBy = principal.UserID
+	}
+	item, err := h.inventoryService.AdjustStock(r.Context(), adjustment)
+	if err != nil {
+		writeInventoryError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewInventoryItemResponse(item))
+}
+
+// GetAdjustments returns the newest ledger entries, ?limit= caps how many
+func (h *InventoryHandler) GetAdjustments(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	adjustments, err := h.inventoryService.GetAdjustments(r.Context(), productID, limit)
+	if err != nil {
+		writeInventoryError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewAdjustmentResponses(adjustments))
+}
+
+// TransferStock moves available stock from one warehouse to another
+func (h *InventoryHandler) TransferStock(w http.ResponseWriter, r *http.Request) {
+	var request dto.TransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	transfer := request.ToModel()
+	if principal, ok := utils.PrincipalFromContext(r.Context()); ok {
+		transfer.CreatedBy = principal.UserID
+	}
+	if err := h.inventoryService.TransferStock(r.Context(), transfer); err != nil {
+		writeInventoryError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.NewTransferResponse(transfer))
+}
+
+func writeInventoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrProductNotFound), errors.Is(err, services.ErrVariantNotFound), errors.Is(err, services.ErrWarehouseNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidAdjustment), errors.Is(err, services.ErrInvalidTransfer):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrInsufficientStock):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
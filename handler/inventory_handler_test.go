@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"ecommerce/dto"
+	"ecommerce/models"
+	"ecommerce/services"
+	"ecommerce/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockInventoryService struct {
+	mock.Mock
+}
+
+func (m *MockInventoryService) GetProductInventory(ctx context.Context, productID int) ([]models.InventoryItem, error) {
+	args := m.Called(productID)
+	return args.Get(0).([]models.InventoryItem), args.Error(1)
+}
+
+func (m *MockInventoryService) AdjustStock(ctx context.Context, adjustment *models.InventoryAdjustment) (*models.InventoryItem, error) {
+	args := m.Called(adjustment)
+	if item := args.Get(0); item != nil {
+		return item.(*models.InventoryItem), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockInventoryService) GetAdjustments(ctx context.Context, productID, limit int) ([]models.InventoryAdjustment, error) {
+	args := m.Called(productID, limit)
+	return args.Get(0).([]models.InventoryAdjustment), args.Error(1)
+}
+
+func (m *MockInventoryService) TransferStock(ctx context.Context, transfer *models.Transfer) error {
+	args := m.Called(transfer)
+	return args.Error(0)
+}
+
+func (m *MockInventoryService) Reserve(ctx context.Context, reference string, lines []models.StockLine, destination *models.GeoPoint) error {
+	args := m.Called(reference, lines, destination)
+	return args.Error(0)
+}
+
+func (m *MockInventoryService) Release(ctx context.Context, reference string) error {
+	args := m.Called(reference)
+	return args.Error(0)
+}
+
+func (m *MockInventoryService) Commit(ctx context.Context, reference string) error {
+	args := m.Called(reference)
+	return args.Error(0)
+}
+
+func TestGetProductInventoryHandler(t *testing.T) {
+	mockService := new(MockInventoryService)
+	handler := NewInventoryHandler(mockService)
+
+	variantID := 3
+	mockService.On("GetProductInventory", 1).Return([]models.InventoryItem{{WarehouseID: 2, ProductID: 1, VariantID: &variantID, SKU: "TS-S", OnHand: 10, Reserved: 4}}, nil)
+	mockService.On("GetProductInventory", 2).Return([]models.InventoryItem(nil), services.ErrProductNotFound)
+
+	res := httptest.NewRecorder()
+	handler.GetProductInventory(res, withURLParam(httptest.NewRequest("GET", "/products/1/inventory", nil), "id", "1"))
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `[{"warehouse_id":2,"product_id":1,"variant_id":3,"sku":"TS-S","on_hand":10,"reserved":4,"available":6}]`, res.Body.String())
+
+	res = httptest.NewRecorder()
+	handler.GetProductInventory(res, withURLParam(httptest.NewRequest("GET", "/products/2/inventory", nil), "id", "2"))
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestAdjustStockHandler(t *testing.T) {
+	mockService := new(MockInventoryService)
+	handler := NewInventoryHandler(mockService)
+
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest("POST", "/products/1/inventory/adjustments", bytes.NewBufferString(body))
+		req = req.WithContext(utils.WithPrincipal(req.Context(), &utils.Principal{UserID: 7, Username: "staff"}))
+		return withURLParam(req, "id", "1")
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		expected := &models.InventoryAdjustment{WarehouseID: 2, ProductID: 1, Delta: 20, Reason: models.ReasonRestock, Note: "PO 118", CreatedBy: 7}
+		mockService.On("AdjustStock", expected).Return(&models.InventoryItem{WarehouseID: 2, ProductID: 1, OnHand: 20}, nil).Once()
+
+		res := httptest.NewRecorder()
+		handler.AdjustStock(res, newRequest(`{"warehouse_id":2,"delta":20,"reason":"restock","note":"PO 118"}`))
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		var response dto.InventoryItemResponse
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&response))
+		assert.Equal(t, 20, response.Available)
+	})
+	t.Run("Below reserved", func(t *testing.T) {
+		mockService.On("AdjustStock", mock.Anything).Return(nil, fmt.Errorf("%w: 5 on hand, 4 reserved", services.ErrInsufficientStock)).Once()
+
+		res := httptest.NewRecorder()
+		handler.AdjustStock(res, newRequest(`{"delta":-2,"reason":"damaged"}`))
+
+		assert.Equal(t, http.StatusConflict, res.Code)
+	})
+	t.Run("Unknown reason", func(t *testing.T) {
+		mockService.On("AdjustStock", mock.Anything).Return(nil, fmt.Errorf("%w: unknown reason \"gift\"", services.ErrInvalidAdjustment)).Once()
+
+		res := httptest.NewRecorder()
+		handler.AdjustStock(res, newRequest(`{"delta":-1,"reason":"gift"}`))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+}
+
+func TestGetAdjustmentsHandler(t *testing.T) {
+	mockService := new(MockInventoryService)
+	handler := NewInventoryHandler(mockService)
+	mockService.On("GetAdjustments", 1, 10).Return([]models.InventoryAdjustment{{ID: 2, Delta: -1, OnHandAfter: 9, Reason: models.ReasonSale, Reference: "order-4"}}, nil)
+
+	res := httptest.NewRecorder()
+	handler.GetAdjustments(res, withURLParam(httptest.NewRequest("GET", "/products/1/inventory/adjustments?limit=10", nil), "id", "1"))
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), `"reason":"sale"`)
+
+	res = httptest.NewRecorder()
+	handler.GetAdjustments(res, withURLParam(httptest.NewRequest("GET", "/products/1/inventory/adjustments?limit=ten", nil), "id", "1"))
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
+
+func TestTransferStockHandler(t *testing.T) {
+	mockService := new(MockInventoryService)
+	handler := NewInventoryHandler(mockService)
+	body := `{"product_id":1,"from_warehouse_id":1,"to_warehouse_id":2,"quantity":5}`
+
+	t.Run("Success", func(t *testing.T) {
+		expected := &models.Transfer{ProductID: 1, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: 5}
+		mockService.On("TransferStock", expected).Run(func(args mock.Arguments) {
+			args.Get(0).(*models.Transfer).ID = 8
+		}).Return(nil).Once()
+
+		res := httptest.NewRecorder()
+		handler.TransferStock(res, httptest.NewRequest("POST", "/inventory/transfers", bytes.NewBufferString(body)))
+
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.JSONEq(t, `{"id":8,"product_id":1,"from_warehouse_id":1,"to_warehouse_id":2,"quantity":5}`, res.Body.String())
+	})
+	t.Run("Unknown warehouse", func(t *testing.T) {
+		mockService.On("TransferStock", mock.Anything).Return(services.ErrWarehouseNotFound).Once()
+
+		res := httptest.NewRecorder()
+		handler.TransferStock(res, httptest.NewRequest("POST", "/inventory/transfers", bytes.NewBufferString(body)))
+
+		assert.Equal(t, http.StatusNotFound, res.Code)
+	})
+}
@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"ecommerce/dto"
+	"ecommerce/models"
+	"ecommerce/services"
+	"ecommerce/utils"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type OrderHandler struct {
+	orderService services.OrderService
+}
+
+func NewOrderHandler(orderService services.OrderService) *OrderHandler {
+	return &OrderHandler{orderService: orderService}
+}
+
+// Checkout places an order for everything in the caller's cart, the body
+// optionally names coupons to apply, the destination to tax for and the
+// saved addresses to ship and bill to
+func (h *OrderHandler) Checkout(w http.ResponseWriter, r *http.Request) {
+	principal, ok := utils.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var request dto.CheckoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	order, err := h.orderService.Checkout(r.Context(), request.ToModel(principal.UserID))
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	response, err := dto.NewOrderResponse(order)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetMyOrders is the caller's order history, newest first
+func (h *OrderHandler) GetMyOrders(w http.ResponseWriter, r *http.Request) {
+	principal, ok := utils.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	filter, ok := orderFilter(w, r)
+	if !ok {
+		return
+	}
+	filter.UserID = principal.UserID
+
+	orders, err := h.orderService.GetOrders(r.Context(), filter)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	response, err := dto.NewOrderResponses(orders)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *OrderHandler) GetMyOrder(w http.ResponseWriter, r *http.Request) {
+	principal, ok := utils.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	order, err := h.orderService.GetUserOrder(r.Context(), principal.UserID, id)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	response, err := dto.NewOrderResponse(order)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// CancelMyOrder cancels one of the caller's orders that isn't paid yet
+func (h *OrderHandler) CancelMyOrder(w http.ResponseWriter, r *http.Request) {
+	principal, ok := utils.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	order, err := h.orderService.CancelOrder(r.Context(), principal.UserID, id)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	response, err := dto.NewOrderResponse(order)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetOrders lists all orders, filtered by ?status= and ?user_id=, ?limit= caps how many
+func (h *OrderHandler) GetOrders(w http.ResponseWriter, r *http.Request) {
+	filter, ok := orderFilter(w, r)
+	if !ok {
+		return
+	}
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		userID, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		filter.UserID = userID
+	}
+
+	orders, err := h.orderService.GetOrders(r.Context(), filter)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	response, err := dto.NewOrderResponses(orders)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	order, err := h.orderService.GetOrder(r.Context(), id)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	response, err := dto.NewOrderResponse(order)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *OrderHandler) GetOrderHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	changes, err := h.orderService.GetOrderHistory(r.Context(), id)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewOrderStatusChangeResponses(changes))
+}
+
+// TransitionOrder moves an order along its state machine, e.g. to shipped
+func (h *OrderHandler) TransitionOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+	var request dto.OrderTransitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	change := request.ToModel(id)
+	if principal, ok := utils.PrincipalFromContext(r.Context()); ok {
+		change.ChangedBy = principal.UserID
+	}
+	order, err := h.orderService.TransitionOrder(r.Context(), change)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	response, err := dto.NewOrderResponse(order)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func orderFilter(w http.ResponseWriter, r *http.Request) (models.OrderFilter, bool) {
+	filter := models.OrderFilter{Status: models.OrderStatus(r.URL.Query().Get("status"))}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.Limit = limit
+	}
+	return filter, true
+}
+
+func writeOrderError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound), errors.Is(err, services.ErrProductNotFound), errors.Is(err, services.ErrVariantNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrEmptyCart), errors.Is(err, services.ErrInvalidCartItem), errors.Is(err, services.ErrInvalidOrderStatus),
+		errors.Is(err, services.ErrCouponNotApplicable), errors.Is(err, services.ErrInvalidDestination), errors.Is(err, services.ErrInvalidAddress):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrInvalidTransition), errors.Is(err, services.ErrOrderStatusConflict), errors.Is(err, services.ErrInsufficientStock),
+		errors.Is(err, services.ErrPromotionExhausted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
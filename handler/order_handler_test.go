@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"ecommerce/models"
+	"ecommerce/services"
+	"ecommerce/utils"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockOrderService struct {
+	mock.Mock
+}
+
+func (m *MockOrderService) Checkout(ctx context.Context, request models.CheckoutRequest) (*models.Order, error) {
+	args := m.Called(request)
+	if order := args.Get(0); order != nil {
+		return order.(*models.Order), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderService) GetOrder(ctx context.Context, id int) (*models.Order, error) {
+	args := m.Called(id)
+	if order := args.Get(0); order != nil {
+		return order.(*models.Order), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderService) GetUserOrder(ctx context.Context, userID, id int) (*models.Order, error) {
+	args := m.Called(userID, id)
+	if order := args.Get(0); order != nil {
+		return order.(*models.Order), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderService) GetOrders(ctx context.Context, filter models.OrderFilter) ([]models.Order, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrderHistory(ctx context.Context, id int) ([]models.OrderStatusChange, error) {
+	args := m.Called(id)
+	return args.Get(0).([]models.OrderStatusChange), args.Error(1)
+}
+
+func (m *MockOrderService) TransitionOrder(ctx context.Context, change *models.OrderStatusChange) (*models.Order, error) {
+	args := m.Called(change)
+	if order := args.Get(0); order != nil {
+		return order.(*models.Order), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderService) CancelOrder(ctx context.Context, userID, id int) (*models.Order, error) {
+	args := m.Called(userID, id)
+	if order := args.Get(0); order != nil {
+		return order.(*models.Order), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func withCustomer(req *http.Request) *http.Request {
+	return req.WithContext(utils.WithPrincipal(req.Context(), &utils.Principal{UserID: 7, Username: "abhay123"}))
+}
+
+func TestCheckoutHandler(t *testing.T) {
+	mockService := new(MockOrderService)
+	handler := NewOrderHandler(mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		productID := 1
+		order := &models.Order{ID: 40, UserID: 7, Status: models.OrderPending, Currency: "USD", Subtotal: usd(1900),
+			Discount: usd(0), Tax: usd(0), Items: []models.OrderItem{{ID: 1, ProductID: &productID, Name: "Mug", Quantity: 2, UnitPrice: usd(950)}}}
+		mockService.On("Checkout", models.CheckoutRequest{UserID: 7}).Return(order, nil).Once()
+
+		res := httptest.NewRecorder()
+		handler.Checkout(res, withCustomer(httptest.NewRequest("POST", "/checkout", nil)))
+
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Contains(t, res.Body.String(), `"status":"pending"`)
+		assert.Contains(t, res.Body.String(), `"line_total":{"amount":"19.00","currency":"USD"}`)
+	})
+	t.Run("Coupon", func(t *testing.T) {
+		productID, itemID, promotionID := 1, 1, 3
+		order := &models.Order{ID: 40, UserID: 7, Status: models.OrderPending, Currency: "USD", Subtotal: usd(1900), Discount: usd(190), Tax: usd(0),
+			Items:     []models.OrderItem{{ID: itemID, ProductID: &productID, Name: "Mug", Quantity: 2, UnitPrice: usd(950)}},
+			Discounts: []models.OrderDiscount{{OrderItemID: &itemID, PromotionID: &promotionID, Name: "10% off", Code: "TENOFF", Kind: models.PromotionPercentage, Amount: usd(190)}}}
+		mockService.On("Checkout", models.CheckoutRequest{UserID: 7, CouponCodes: []string{"TENOFF"}}).Return(order, nil).Once()
+
+		res := httptest.NewRecorder()
+		handler.Checkout(res, withCustomer(httptest.NewRequest("POST", "/checkout", bytes.NewBufferString(`{"coupon_codes":["TENOFF"]}`))))
+
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Contains(t, res.Body.String(), `"total":{"amount":"17.10","currency":"USD"}`)
+		assert.Contains(t, res.Body.String(), `"item_id":1`)
+	})
+	t.Run("Coupon doesn't apply", func(t *testing.T) {
+		mockService.On("Checkout", models.CheckoutRequest{UserID: 7, CouponCodes: []string{"BIG"}}).Return(nil, fmt.Errorf("%w: BIG has expired", services.ErrCouponNotApplicable)).Once()
+
+		res := httptest.NewRecorder()
+		handler.Checkout(res, withCustomer(httptest.NewRequest("POST", "/checkout", bytes.NewBufferString(`{"coupon_codes":["BIG"]}`))))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+	t.Run("Destination", func(t *testing.T) {
+		productID := 1
+		order := &models.Order{ID: 40, UserID: 7, Status: models.OrderPending, Currency: "USD", Subtotal: usd(1900), Discount: usd(0), Tax: usd(138),
+			Items: []models.OrderItem{{ID: 1, ProductID: &productID, Name: "Mug", Quantity: 2, UnitPrice: usd(950), TaxClass: "standard",
+				TaxName: "CA sales tax", TaxRate: 72500, Tax: usd(138)}}}
+		request := models.CheckoutRequest{UserID: 7, Destination: models.Destination{Country: "US", Region: "CA", Postcode: "94105"}}
+		mockService.On("Checkout", request).Return(order, nil).Once()
+
+		body := `{"destination":{"country":"US","region":"CA","postcode":"94105"}}`
+		res := httptest.NewRecorder()
+		handler.Checkout(res, withCustomer(httptest.NewRequest("POST", "/checkout", bytes.NewBufferString(body))))
+
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Contains(t, res.Body.String(), `"tax_rate":"7.25"`)
+		assert.Contains(t, res.Body.String(), `"total":{"amount":"20.38","currency":"USD"}`)
+	})
+	t.Run("Out of stock", func(t *testing.T) {
+		mockService.On("Checkout", models.CheckoutRequest{UserID: 7}).Return(nil, fmt.Errorf("%w: product 1", services.ErrInsufficientStock)).Once()
+
+		res := httptest.NewRecorder()
+		handler.Checkout(res, withCustomer(httptest.NewRequest("POST", "/checkout", nil)))
+
+		assert.Equal(t, http.StatusConflict, res.Code)
+	})
+	t.Run("Empty cart", func(t *testing.T) {
+		mockService.On("Checkout", models.CheckoutRequest{UserID: 7}).Return(nil, services.ErrEmptyCart).Once()
+
+		res := httptest.NewRecorder()
+		handler.Checkout(res, withCustomer(httptest.NewRequest("POST", "/checkout", nil)))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+}
+
+func TestGetMyOrdersHandler(t *testing.T) {
+	mockService := new(MockOrderService)
+	handler := NewOrderHandler(mockService)
+	mockService.On("GetOrders", models.OrderFilter{UserID: 7, Status: models.OrderShipped, Limit: 5}).Return([]models.Order{{ID: 40, Status: models.OrderShipped}}, nil)
+
+	res := httptest.NewRecorder()
+	handler.GetMyOrders(res, withCustomer(httptest.NewRequest("GET", "/me/orders?status=shipped&limit=5", nil)))
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), `"id":40`)
+}
+
+func TestTransitionOrderHandler(t *testing.T) {
+	mockService := new(MockOrderService)
+	handler := NewOrderHandler(mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		change := &models.OrderStatusChange{OrderID: 40, To: models.OrderShipped, Note: "DHL 123", ChangedBy: 7}
+		mockService.On("TransitionOrder", change).Return(&models.Order{ID: 40, Status: models.OrderShipped}, nil).Once()
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/orders/40/transitions", bytes.NewBufferString(`{"status":"shipped","note":"DHL 123"}`))
+		handler.TransitionOrder(res, withURLParam(withCustomer(req), "id", "40"))
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Contains(t, res.Body.String(), `"status":"shipped"`)
+	})
+	t.Run("Not allowed", func(t *testing.T) {
+		mockService.On("TransitionOrder", mock.Anything).Return(nil, services.ErrInvalidTransition).Once()
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/orders/40/transitions", bytes.NewBufferString(`{"status":"paid"}`))
+		handler.TransitionOrder(res, withURLParam(req, "id", "40"))
+
+		assert.Equal(t, http.StatusConflict, res.Code)
+	})
+}
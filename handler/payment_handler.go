@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"ecommerce/dto"
+	"ecommerce/services"
+	"ecommerce/utils"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxWebhookBody   = 64 << 10
+	webhookTolerance = 5 * time.Minute // how old a signature may be
+)
+
+type PaymentHandler struct {
+	paymentService services.PaymentService
+	webhookSecret  []byte
+}
+
+func NewPaymentHandler(paymentService services.PaymentService, webhookSecret string) *PaymentHandler {
+	return &PaymentHandler{paymentService: paymentService, webhookSecret: []byte(webhookSecret)}
+}
+
+// PayOrder pays one of the caller's pending orders
+func (h *PaymentHandler) PayOrder(w http.ResponseWriter, r *http.Request) {
+	principal, ok := utils.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+	var request dto.PayOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	payment, err := h.paymentService.PayOrder(r.Context(), principal.UserID, orderID, request.PaymentToken)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.NewPaymentResponse(payment))
+}
+
+func (h *PaymentHandler) GetOrderPayments(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	payments, err := h.paymentService.GetOrderPayments(r.Context(), orderID)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewPaymentResponses(payments))
+}
+
+func (h *PaymentHandler) VoidPayment(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+
+	payment, err := h.paymentService.VoidPayment(r.Context(), paymentID, actorID(r))
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewPaymentResponse(payment))
+}
+
+func (h *PaymentHandler) RefundPayment(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+	var request dto.RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	payment, err := h.paymentService.RefundPayment(r.Context(), paymentID, request.Amount, actorID(r))
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewPaymentResponse(payment))
+}
+
+// Webhook receives the gateway's events. The signature is checked against
+// the raw body before anything is parsed.
+func (h *PaymentHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody+1))
+	if err != nil || len(body) > maxWebhookBody {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if err := utils.VerifyWebhook(h.webhookSecret, r.Header.Get(utils.WebhookSignatureHeader), body, time.Now(), webhookTolerance); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var request dto.PaymentEventRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.paymentService.HandleEvent(r.Context(), request.ToModel()); err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// actorID is the signed in user making the change, 0 when unknown
+func actorID(r *http.Request) int {
+	if principal, ok := utils.PrincipalFromContext(r.Context()); ok {
+		return principal.UserID
+	}
+	return 0
+}
+
+func writePaymentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrPaymentNotFound), errors.Is(err, services.ErrOrderNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidPayment), errors.Is(err, services.ErrInvalidPaymentEvent):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrPaymentDeclined):
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+	case errors.Is(err, services.ErrInvalidTransition), errors.Is(err, services.ErrOrderStatusConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrGatewayRejected):
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"ecommerce/models"
+	"ecommerce/services"
+	"ecommerce/utils"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPaymentService struct {
+	mock.Mock
+}
+
+func (m *MockPaymentService) PayOrder(ctx context.Context, userID, orderID int, methodToken string) (*models.Payment, error) {
+	args := m.Called(userID, orderID, methodToken)
+	if payment := args.Get(0); payment != nil {
+		return payment.(*models.Payment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentService) GetOrderPayments(ctx context.Context, orderID int) ([]models.Payment, error) {
+	args := m.Called(orderID)
+	return args.Get(0).([]models.Payment), args.Error(1)
+}
+
+func (m *MockPaymentService) VoidPayment(ctx context.Context, paymentID, actorID int) (*models.Payment, error) {
+	args := m.Called(paymentID, actorID)
+	if payment := args.Get(0); payment != nil {
+		return payment.(*models.Payment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentService) RefundPayment(ctx context.Context, paymentID int, amount models.Money, actorID int) (*models.Payment, error) {
+	args := m.Called(paymentID, amount, actorID)
+	if payment := args.Get(0); payment != nil {
+		return payment.(*models.Payment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentService) HandleEvent(ctx context.Context, event *models.PaymentEvent) error {
+	args := m.Called(event)
+	return args.Error(0)
+}
+
+func TestPayOrderHandler(t *testing.T) {
+	mockService := new(MockPaymentService)
+	handler := NewPaymentHandler(mockService, "whsec")
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("PayOrder", 7, 40, "tok_visa").Return(&models.Payment{ID: 50, OrderID: 40, Status: models.PaymentCaptured, Amount: usd(1900)}, nil).Once()
+
+		req := withCustomer(httptest.NewRequest("POST", "/me/orders/40/payments", bytes.NewBufferString(`{"payment_token":"tok_visa"}`)))
+		res := httptest.NewRecorder()
+		handler.PayOrder(res, withURLParam(req, "id", "40"))
+
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Contains(t, res.Body.String(), `"status":"captured"`)
+	})
+	t.Run("Declined", func(t *testing.T) {
+		mockService.On("PayOrder", 7, 40, "tok_declined").Return(&models.Payment{ID: 51, Status: models.PaymentFailed}, fmt.Errorf("%w: card declined", services.ErrPaymentDeclined)).Once()
+
+		req := withCustomer(httptest.NewRequest("POST", "/me/orders/40/payments", bytes.NewBufferString(`{"payment_token":"tok_declined"}`)))
+		res := httptest.NewRecorder()
+		handler.PayOrder(res, withURLParam(req, "id", "40"))
+
+		assert.Equal(t, http.StatusPaymentRequired, res.Code)
+	})
+}
+
+func TestPaymentWebhookHandler(t *testing.T) {
+	mockService := new(MockPaymentService)
+	handler := NewPaymentHandler(mockService, "whsec")
+	body := []byte(`{"id":"evt_1","type":"payment.captured","reference":"fake_40_1"}`)
+
+	t.Run("Signed", func(t *testing.T) {
+		mockService.On("HandleEvent", &models.PaymentEvent{ID: "evt_1", Type: models.EventPaymentCaptured, Reference: "fake_40_1"}).Return(nil).Once()
+
+		req := httptest.NewRequest("POST", "/webhooks/payments", bytes.NewReader(body))
+		req.Header.Set(utils.WebhookSignatureHeader, utils.SignWebhook([]byte("whsec"), time.Now(), body))
+		res := httptest.NewRecorder()
+		handler.Webhook(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		mockService.AssertExpectations(t)
+	})
+	t.Run("Wrong secret", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/webhooks/payments", bytes.NewReader(body))
+		req.Header.Set(utils.WebhookSignatureHeader, utils.SignWebhook([]byte("other"), time.Now(), body))
+		res := httptest.NewRecorder()
+		handler.Webhook(res, req)
+
+		assert.Equal(t, http.StatusUnauthorized, res.Code)
+	})
+	t.Run("Unsigned", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		handler.Webhook(res, httptest.NewRequest("POST", "/webhooks/payments", bytes.NewReader(body)))
+
+		assert.Equal(t, http.StatusUnauthorized, res.Code)
+		mockService.AssertNumberOfCalls(t, "HandleEvent", 1)
+	})
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"ecommerce/dto"
+	"ecommerce/models"
+	"ecommerce/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// PricingHandler manages the explicit product prices and the exchange rates
+// the catalogue is converted with
+type PricingHandler struct {
+	pricingService services.PricingService
+}
+
+func NewPricingHandler(pricingService services.PricingService) *PricingHandler {
+	return &PricingHandler{pricingService: pricingService}
+}
+
+func (h *PricingHandler) GetProductPrices(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	prices, err := h.pricingService.GetProductPrices(r.Context(), productID)
+	if err != nil {
+		writePricingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewProductPriceResponses(prices))
+}
+
+// SetProductPrice adds or replaces the product's price in one currency
+func (h *PricingHandler) SetProductPrice(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	var request dto.ProductPriceRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	price := request.ToModel(productID)
+	if err := h.pricingService.SetProductPrice(r.Context(), price); err != nil {
+		writePricingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewProductPriceResponses([]models.ProductPrice{*price})[0])
+}
+
+// DeleteProductPrice drops the explicit price, the product is converted into the currency again
+func (h *PricingHandler) DeleteProductPrice(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.pricingService.DeleteProductPrice(r.Context(), productID, chi.URLParam(r, "currency")); err != nil {
+		writePricingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Product price deleted successfully"})
+}
+
+// GetExchangeRates lists the rate history, optionally of the ?base= and ?quote= currencies only
+func (h *PricingHandler) GetExchangeRates(w http.ResponseWriter, r *http.Request) {
+	filter := models.ExchangeRateFilter{Base: r.URL.Query().Get("base"), Quote: r.URL.Query().Get("quote")}
+	rates, err := h.pricingService.GetExchangeRates(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to retrieve exchange rates", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewExchangeRateResponses(rates))
+}
+
+func (h *PricingHandler) CreateExchangeRate(w http.ResponseWriter, r *http.Request) {
+	var request dto.ExchangeRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	rate := request.ToModel()
+	rate.CreatedBy = actorID(r)
+	if err := h.pricingService.CreateExchangeRate(r.Context(), rate); err != nil {
+		writePricingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.NewExchangeRateResponse(rate))
+}
+
+func writePricingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrProductNotFound), errors.Is(err, services.ErrProductPriceNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrUnsupportedCurrency), errors.Is(err, services.ErrNoExchangeRate),
+		errors.Is(err, services.ErrInvalidPrice), errors.Is(err, services.ErrInvalidExchangeRate):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
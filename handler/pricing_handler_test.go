@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"ecommerce/dto"
+	"ecommerce/models"
+	"ecommerce/services"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPricingService struct {
+	mock.Mock
+}
+
+func (m *MockPricingService) BaseCurrency() string {
+	return m.Called().String(0)
+}
+
+func (m *MockPricingService) Supports(currency string) bool {
+	return m.Called(currency).Bool(0)
+}
+
+func (m *MockPricingService) LocalizeProducts(ctx context.Context, products []models.Product, currency string) error {
+	args := m.Called(products, currency)
+	return args.Error(0)
+}
+
+func (m *MockPricingService) Convert(ctx context.Context, amount models.Money, currency string) (models.Money, error) {
+	args := m.Called(amount, currency)
+	return args.Get(0).(models.Money), args.Error(1)
+}
+
+func (m *MockPricingService) GetProductPrices(ctx context.Context, productID int) ([]models.ProductPrice, error) {
+	args := m.Called(productID)
+	return args.Get(0).([]models.ProductPrice), args.Error(1)
+}
+
+func (m *MockPricingService) SetProductPrice(ctx context.Context, price *models.ProductPrice) error {
+	args := m.Called(price)
+	return args.Error(0)
+}
+
+func (m *MockPricingService) DeleteProductPrice(ctx context.Context, productID int, currency string) error {
+	args := m.Called(productID, currency)
+	return args.Error(0)
+}
+
+func (m *MockPricingService) CreateExchangeRate(ctx context.Context, rate *models.ExchangeRate) error {
+	args := m.Called(rate)
+	return args.Error(0)
+}
+
+func (m *MockPricingService) GetExchangeRates(ctx context.Context, filter models.ExchangeRateFilter) ([]models.ExchangeRate, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]models.ExchangeRate), args.Error(1)
+}
+
+func TestProductCurrency(t *testing.T) {
+	productService, pricingService := new(MockProductService), new(MockPricingService)
+	handler := NewProductHander(productService, pricingService)
+
+	productService.On("GetAllProducts").Return([]models.Product{{ID: 1, Name: "Mug", Price: usd(1000)}}, nil)
+	pricingService.On("BaseCurrency").Return("USD")
+	for _, currency := range []string{"EUR", "GBP"} {
+		pricingService.On("Supports", currency).Return(true)
+	}
+	pricingService.On("Supports", mock.Anything).Return(false)
+	localized := func(currency string) {
+		pricingService.On("LocalizeProducts", mock.Anything, currency).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(0).([]models.Product)[0].Price = models.NewMoney(926, currency)
+		}).Once()
+	}
+	get := func(url, header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		if header != "" {
+			req.Header.Set(CurrencyHeader, header)
+		}
+		res := httptest.NewRecorder()
+		handler.GetAllProducts(res, req)
+		return res
+	}
+
+	t.Run("Query parameter", func(t *testing.T) {
+		localized("EUR")
+		res := get("/products?currency=eur", "GBP")
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, CurrencyHeader, res.Header().Get("Vary"))
+		var products []dto.ProductResponse
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&products))
+		assert.Equal(t, models.NewMoney(926, "EUR"), products[0].Price)
+	})
+	t.Run("First supported header entry", func(t *testing.T) {
+		localized("GBP")
+		res := get("/products", "CHF, gbp;q=0.9, EUR;q=0.5")
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Contains(t, res.Body.String(), `"currency":"GBP"`)
+	})
+	t.Run("Base currency by default", func(t *testing.T) {
+		localized("USD")
+		res := get("/products", "")
+
+		assert.Equal(t, http.StatusOK, res.Code)
+	})
+	t.Run("Unsupported query currency", func(t *testing.T) {
+		res := get("/products?currency=CHF", "")
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+	t.Run("No exchange rate", func(t *testing.T) {
+		pricingService.On("LocalizeProducts", mock.Anything, "EUR").Return(fmt.Errorf("%w from USD to EUR", services.ErrNoExchangeRate)).Once()
+		res := get("/products", "EUR")
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+	pricingService.AssertExpectations(t)
+}
+
+func TestSetProductPriceHandler(t *testing.T) {
+	pricingService := new(MockPricingService)
+	handler := NewPricingHandler(pricingService)
+
+	t.Run("Success", func(t *testing.T) {
+		pricingService.On("SetProductPrice", &models.ProductPrice{ProductID: 1, Price: models.NewMoney(899, "EUR")}).Return(nil).Once()
+
+		req := httptest.NewRequest("PUT", "/products/1/prices", bytes.NewBufferString(`{"price":{"amount":"8.99","currency":"EUR"}}`))
+		res := httptest.NewRecorder()
+		handler.SetProductPrice(res, withURLParam(req, "id", "1"))
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Contains(t, res.Body.String(), `"amount":"8.99"`)
+	})
+	t.Run("Unsupported currency", func(t *testing.T) {
+		pricingService.On("SetProductPrice", mock.Anything).Return(fmt.Errorf("%w: %q", services.ErrUnsupportedCurrency, "CHF")).Once()
+
+		req := httptest.NewRequest("PUT", "/products/1/prices", bytes.NewBufferString(`{"price":{"amount":"8.99","currency":"CHF"}}`))
+		res := httptest.NewRecorder()
+		handler.SetProductPrice(res, withURLParam(req, "id", "1"))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+	t.Run("Unknown product", func(t *testing.T) {
+		pricingService.On("SetProductPrice", mock.Anything).Return(services.ErrProductNotFound).Once()
+
+		req := httptest.NewRequest("PUT", "/products/9/prices", bytes.NewBufferString(`{"price":{"amount":"8.99","currency":"EUR"}}`))
+		res := httptest.NewRecorder()
+		handler.SetProductPrice(res, withURLParam(req, "id", "9"))
+
+		assert.Equal(t, http.StatusNotFound, res.Code)
+	})
+	pricingService.AssertExpectations(t)
+}
+
+func TestCreateExchangeRateHandler(t *testing.T) {
+	pricingService := new(MockPricingService)
+	handler := NewPricingHandler(pricingService)
+
+	t.Run("Records creator", func(t *testing.T) {
+		pricingService.On("CreateExchangeRate", mock.MatchedBy(func(rate *models.ExchangeRate) bool {
+			return rate.Base == "USD" && rate.Quote == "EUR" && rate.Rate.String() == "0.9215" && rate.CreatedBy == 7
+		})).Return(nil).Once()
+
+		req := httptest.NewRequest("POST", "/exchange-rates", bytes.NewBufferString(`{"base":"USD","quote":"EUR","rate":"0.9215"}`))
+		res := httptest.NewRecorder()
+		handler.CreateExchangeRate(res, withPrincipal(req, 7))
+
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Contains(t, res.Body.String(), `"rate":"0.9215"`)
+	})
+	t.Run("Invalid rate", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/exchange-rates", bytes.NewBufferString(`{"base":"USD","quote":"EUR","rate":"lots"}`))
+		res := httptest.NewRecorder()
+		handler.CreateExchangeRate(res, req)
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+	pricingService.AssertExpectations(t)
+}
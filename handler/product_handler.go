@@ -1,9 +1,14 @@
 package handler
 
 import (
+	"ecommerce/dto"
 	"ecommerce/models"
 	"ecommerce/services"
+	"ecommerce/utils"
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
 
 	"encoding/json"
 	"net/http"
@@ -11,25 +16,35 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
+// CurrencyHeader asks for prices in another currency, the ?currency= query parameter takes precedence
+const CurrencyHeader = "Accept-Currency"
+
 type ProductHandler struct {
 	productService services.ProductService
+	pricingService services.PricingService
 }
 
-func NewProductHander(productService services.ProductService) *ProductHandler {
-	return &ProductHandler{productService: productService}
+func NewProductHander(productService services.ProductService, pricingService services.PricingService) *ProductHandler {
+	return &ProductHandler{productService: productService, pricingService: pricingService}
 }
 
 func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
-	var product models.Product
-	err := json.NewDecoder(r.Body).Decode(&product)
+	var request dto.ProductRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
+	product := request.ToModel()
+
+	if principal, ok := utils.PrincipalFromContext(r.Context()); ok {
+		product.CreatedBy = principal.UserID
+		product.UpdatedBy = principal.UserID
+	}
 
-	err = h.productService.CreateProduct(&product)
+	err = h.productService.CreateProduct(r.Context(), product)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeProductError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -44,25 +59,32 @@ func (h *ProductHandler) GetProductByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	product, err := h.productService.GetProductByID(id)
+	product, err := h.productService.GetProductByID(r.Context(), id)
 	if err != nil {
 		http.Error(w, "Product not found", http.StatusNotFound)
 		return
 	}
+	products := []models.Product{*product}
+	if !h.localize(w, r, products) {
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(product)
+	json.NewEncoder(w).Encode(dto.NewProductResponse(&products[0]))
 }
 
 func (h *ProductHandler) GetAllProducts(w http.ResponseWriter, r *http.Request) {
-	products, err := h.productService.GetAllProducts()
+	products, err := h.productService.GetAllProducts(r.Context())
 	if err != nil {
 		http.Error(w, "Failed to retrieve products", http.StatusInternalServerError)
 		return
 	}
+	if !h.localize(w, r, products) {
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(products)
+	json.NewEncoder(w).Encode(dto.NewProductResponses(products))
 }
 
 func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
@@ -73,29 +95,27 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var updatedProduct models.Product
-	err = json.NewDecoder(r.Body).Decode(&updatedProduct)
+	var request dto.ProductRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		http.Error(w, "Invalid request ", http.StatusBadRequest)
 		return
 	}
 
 	// Retrieve existing user details from the database
-	existingProduct, err := h.productService.GetProductByID(id)
+	existingProduct, err := h.productService.GetProductByID(r.Context(), id)
 	if err != nil || existingProduct == nil {
 		http.Error(w, "Product not found", http.StatusInternalServerError)
 		return
 	}
-	if updatedProduct.Name != "" {
-		existingProduct.Name = updatedProduct.Name
-	}
-	if updatedProduct.Price != 0 {
-		existingProduct.Price = updatedProduct.Price
+	request.ApplyTo(existingProduct)
+	if principal, ok := utils.PrincipalFromContext(r.Context()); ok {
+		existingProduct.UpdatedBy = principal.UserID
 	}
 
-	err = h.productService.UpdateProduct(existingProduct)
+	err = h.productService.UpdateProduct(r.Context(), existingProduct)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeProductError(w, err)
 		return
 	}
 
@@ -110,7 +130,7 @@ func (h *ProductHandler) DeleteProducts(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Invalid Product ID", http.StatusBadRequest)
 		return
 	}
-	err = h.productService.DeleteProducts(id)
+	err = h.productService.DeleteProducts(r.Context(), id)
 	if err != nil {
 		http.Error(w, "Failed to delete product", http.StatusInternalServerError)
 		return
@@ -118,3 +138,158 @@ func (h *ProductHandler) DeleteProducts(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message: ": "Product deleted successfully"})
 }
+
+func (h *ProductHandler) GetOptionTypes(w http.ResponseWriter, r *http.Request) {
+	types, err := h.productService.GetOptionTypes(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to retrieve option types", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewOptionTypeResponses(types))
+}
+
+func (h *ProductHandler) CreateOptionType(w http.ResponseWriter, r *http.Request) {
+	var request dto.OptionTypeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	optionType := request.ToModel()
+	if err := h.productService.CreateOptionType(r.Context(), optionType); err != nil {
+		writeVariantError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.NewOptionTypeResponses([]models.OptionType{*optionType})[0])
+}
+
+func (h *ProductHandler) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	var request dto.VariantRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	variant := request.ToModel(productID)
+	if err := h.productService.CreateVariant(r.Context(), variant); err != nil {
+		writeVariantError(w, err)
+		return
+	}
+	h.writeVariant(w, r, http.StatusCreated, variant.SKU)
+}
+
+// GetVariant addresses a variant by SKU
+func (h *ProductHandler) GetVariant(w http.ResponseWriter, r *http.Request) {
+	h.writeVariant(w, r, http.StatusOK, chi.URLParam(r, "sku"))
+}
+
+// UpdateVariant replaces the variant's fields and options, the SKU itself can be renamed
+func (h *ProductHandler) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	var request dto.VariantRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	existing, _, err := h.productService.GetVariantBySKU(r.Context(), chi.URLParam(r, "sku"))
+	if err != nil {
+		writeVariantError(w, err)
+		return
+	}
+	variant := request.ToModel(existing.ProductID)
+	variant.ID = existing.ID
+	if variant.SKU == "" {
+		variant.SKU = existing.SKU
+	}
+	if err := h.productService.UpdateVariant(r.Context(), variant); err != nil {
+		writeVariantError(w, err)
+		return
+	}
+	h.writeVariant(w, r, http.StatusOK, variant.SKU)
+}
+
+func (h *ProductHandler) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	if err := h.productService.DeleteVariant(r.Context(), chi.URLParam(r, "sku")); err != nil {
+		writeVariantError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Variant deleted successfully"})
+}
+
+// writeVariant reloads the variant so the response shows the stored state and effective price
+func (h *ProductHandler) writeVariant(w http.ResponseWriter, r *http.Request, status int, sku string) {
+	variant, product, err := h.productService.GetVariantBySKU(r.Context(), sku)
+	if err != nil {
+		writeVariantError(w, err)
+		return
+	}
+	products := []models.Product{*product}
+	products[0].Variants = []models.Variant{*variant}
+	if !h.localize(w, r, products) {
+		return
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(dto.NewVariantResponse(&products[0].Variants[0], &products[0]))
+}
+
+// responseCurrency is the currency the caller wants prices in: the ?currency=
+// parameter, else the first supported entry of the Accept-Currency header,
+// else the base currency
+func (h *ProductHandler) responseCurrency(r *http.Request) (string, error) {
+	if currency := r.URL.Query().Get("currency"); currency != "" {
+		currency = strings.ToUpper(currency)
+		if !h.pricingService.Supports(currency) {
+			return "", fmt.Errorf("%w: %q", services.ErrUnsupportedCurrency, currency)
+		}
+		return currency, nil
+	}
+	for _, entry := range strings.Split(r.Header.Get(CurrencyHeader), ",") {
+		currency, _, _ := strings.Cut(entry, ";")
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		if h.pricingService.Supports(currency) {
+			return currency, nil
+		}
+	}
+	return h.pricingService.BaseCurrency(), nil
+}
+
+// localize reprices the products in the caller's currency, on failure the
+// error response is written and false returned
+func (h *ProductHandler) localize(w http.ResponseWriter, r *http.Request, products []models.Product) bool {
+	w.Header().Add("Vary", CurrencyHeader)
+	currency, err := h.responseCurrency(r)
+	if err == nil {
+		err = h.pricingService.LocalizeProducts(r.Context(), products, currency)
+	}
+	if err != nil {
+		writePricingError(w, err)
+		return false
+	}
+	return true
+}
+
+func writeProductError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrInvalidProduct) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func writeVariantError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrVariantNotFound), errors.Is(err, services.ErrProductNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidVariant):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
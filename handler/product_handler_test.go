@@ -3,9 +3,13 @@ package handler
 import (
 	"bytes"
 	"context"
+	"ecommerce/dto"
 	"ecommerce/models"
+	"ecommerce/services"
+	"ecommerce/utils"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -21,12 +25,12 @@ type MockProductService struct {
 	mock.Mock
 }
 
-func (m *MockProductService) CreateProduct(product *models.Product) error {
+func (m *MockProductService) CreateProduct(ctx context.Context, product *models.Product) error {
 	args := m.Called(product)
 	return args.Error(0)
 }
 
-func (m *MockProductService) GetProductByID(id int) (*models.Product, error) {
+func (m *MockProductService) GetProductByID(ctx context.Context, id int) (*models.Product, error) {
 	args := m.Called(id)
 	if args.Get(0) != nil {
 		return args.Get(0).(*models.Product), args.Error(1)
@@ -34,31 +38,78 @@ func (m *MockProductService) GetProductByID(id int) (*models.Product, error) {
 	return nil, args.Error(1)
 }
 
-func (m *MockProductService) GetAllProducts() ([]models.Product, error) {
+func (m *MockProductService) GetAllProducts(ctx context.Context) ([]models.Product, error) {
 	args := m.Called()
 	return args.Get(0).([]models.Product), args.Error(1)
 }
 
-func (m *MockProductService) UpdateProduct(product *models.Product) error {
+func (m *MockProductService) UpdateProduct(ctx context.Context, product *models.Product) error {
 	args := m.Called(product)
 	return args.Error(0)
 }
 
-func (m *MockProductService) DeleteProducts(id int) error {
+func (m *MockProductService) DeleteProducts(ctx context.Context, id int) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
+func (m *MockProductService) GetProductCategoryIDs(ctx context.Context, productIDs []int) (map[int][]int, error) {
+	args := m.Called(productIDs)
+	return args.Get(0).(map[int][]int), args.Error(1)
+}
+
+func (m *MockProductService) CreateOptionType(ctx context.Context, optionType *models.OptionType) error {
+	args := m.Called(optionType)
+	return args.Error(0)
+}
+
+func (m *MockProductService) GetOptionTypes(ctx context.Context) ([]models.OptionType, error) {
+	args := m.Called()
+	return args.Get(0).([]models.OptionType), args.Error(1)
+}
+
+func (m *MockProductService) CreateVariant(ctx context.Context, variant *models.Variant) error {
+	args := m.Called(variant)
+	return args.Error(0)
+}
+
+func (m *MockProductService) GetVariantBySKU(ctx context.Context, sku string) (*models.Variant, *models.Product, error) {
+	args := m.Called(sku)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Variant), args.Get(1).(*models.Product), args.Error(2)
+	}
+	return nil, nil, args.Error(2)
+}
+
+func (m *MockProductService) UpdateVariant(ctx context.Context, variant *models.Variant) error {
+	args := m.Called(variant)
+	return args.Error(0)
+}
+
+func (m *MockProductService) DeleteVariant(ctx context.Context, sku string) error {
+	args := m.Called(sku)
+	return args.Error(0)
+}
+
+// basePricing only shows prices in USD, so it never reaches its repos
+var basePricing = services.NewPricingService(nil, nil, nil, "USD", nil, models.RoundHalfUp)
+
+func usd(amount int64) models.Money { return models.NewMoney(amount, "USD") }
+
+func usdPtr(amount int64) *models.Money {
+	price := usd(amount)
+	return &price
+}
+
 func TestCreateProductHandler(t *testing.T) {
 	mockService := new(MockProductService)
-	handler := NewProductHander(mockService)
+	handler := NewProductHander(mockService, basePricing)
 
 	product := models.Product{
-		ID:    1,
 		Name:  "Mouse",
-		Price: 900,
+		Price: usd(90000),
 	}
-	body, _ := json.Marshal(product)
+	body, _ := json.Marshal(dto.ProductRequest{Name: "Mouse", Price: usdPtr(90000)})
 
 	t.Run("Success", func(t *testing.T) {
 		req := httptest.NewRequest("Post", "/products", bytes.NewBuffer(body))
@@ -71,6 +122,23 @@ func TestCreateProductHandler(t *testing.T) {
 		assert.Equal(t, http.StatusCreated, res.Code)
 		assert.Contains(t, res.Body.String(), "Product created successfully")
 	})
+	t.Run("Records creator", func(t *testing.T) {
+		mockService.ExpectedCalls = nil
+
+		req := httptest.NewRequest("POST", "/products", bytes.NewBuffer(body))
+		req = req.WithContext(utils.WithPrincipal(req.Context(), &utils.Principal{UserID: 7, Username: "admin"}))
+		res := httptest.NewRecorder()
+
+		expected := product
+		expected.CreatedBy = 7
+		expected.UpdatedBy = 7
+		mockService.On("CreateProduct", &expected).Return(nil)
+
+		handler.CreateProduct(res, req)
+
+		assert.Equal(t, http.StatusCreated, res.Code)
+		mockService.AssertExpectations(t)
+	})
 	t.Run("Invalid request", func(t *testing.T) {
 		product := `{
 		"name": "Mouse",
@@ -98,15 +166,27 @@ func TestCreateProductHandler(t *testing.T) {
 
 		assert.Equal(t, http.StatusInternalServerError, res.Code)
 	})
+	t.Run("Invalid price", func(t *testing.T) {
+		mockService.ExpectedCalls = nil
+
+		req := httptest.NewRequest("POST", "/products", bytes.NewBuffer(body))
+		res := httptest.NewRecorder()
+
+		mockService.On("CreateProduct", &product).Return(fmt.Errorf("%w: prices are in EUR, not \"USD\"", services.ErrInvalidProduct))
+
+		handler.CreateProduct(res, req)
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
 }
 
 func TestGetProductByID(t *testing.T) {
 	mockService := new(MockProductService)
-	handler := NewProductHander(mockService)
+	handler := NewProductHander(mockService, basePricing)
 	product := &models.Product{
 		ID:    1,
 		Name:  "Mouse",
-		Price: 999,
+		Price: usd(99900),
 	}
 
 	t.Run("Success", func(t *testing.T) {
@@ -121,6 +201,10 @@ func TestGetProductByID(t *testing.T) {
 		r.ServeHTTP(res, req) // Serve the request
 
 		assert.Equal(t, http.StatusOK, res.Code)
+
+		var resp dto.ProductResponse
+		json.Unmarshal(res.Body.Bytes(), &resp)
+		assert.Equal(t, dto.ProductResponse{ID: 1, Name: "Mouse", Price: usd(99900)}, resp)
 	})
 	t.Run("invalid product id", func(t *testing.T) {
 		r := chi.NewRouter()
@@ -151,18 +235,18 @@ func TestGetProductByID(t *testing.T) {
 
 func TestGetAllProducts(t *testing.T) {
 	mockService := new(MockProductService)
-	handler := NewProductHander(mockService)
+	handler := NewProductHander(mockService, basePricing)
 
 	products := []models.Product{
 		{
 			ID:    1,
 			Name:  "Laptop",
-			Price: 61000,
+			Price: usd(6100000),
 		},
 		{
 			ID:    2,
 			Name:  "Mouse",
-			Price: 0,
+			Price: usd(0),
 		},
 	}
 	t.Run("Success", func(t *testing.T) {
@@ -174,8 +258,8 @@ func TestGetAllProducts(t *testing.T) {
 		handler.GetAllProducts(res, req)
 
 		assert.Equal(t, http.StatusOK, res.Code)
-		assert.Contains(t, res.Body.String(), `"Name":"Laptop"`)
-		assert.Contains(t, res.Body.String(), `"Name":"Mouse"`)
+		assert.Contains(t, res.Body.String(), `"name":"Laptop"`)
+		assert.Contains(t, res.Body.String(), `"name":"Mouse"`)
 
 	})
 	t.Run("Fail", func(t *testing.T) {
@@ -194,12 +278,12 @@ func TestGetAllProducts(t *testing.T) {
 
 func TestUpdateProduct(t *testing.T) {
 	mockService := new(MockProductService)
-	handler := NewProductHander(mockService)
+	handler := NewProductHander(mockService, basePricing)
 
 	product := models.Product{
 		ID:    1,
 		Name:  "Laptop",
-		Price: 61000,
+		Price: usd(6100000),
 	}
 	reqBody, _ := json.Marshal(product)
 
@@ -220,6 +304,26 @@ func TestUpdateProduct(t *testing.T) {
 		assert.Equal(t, http.StatusOK, rec.Code)
 		assert.Contains(t, rec.Body.String(), "Product updated successfully")
 	})
+	t.Run("Records editor", func(t *testing.T) {
+		mockService.ExpectedCalls = nil
+
+		req := httptest.NewRequest("PUT", "/product/1", bytes.NewBuffer(reqBody))
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", "1")
+		ctx := utils.WithPrincipal(req.Context(), &utils.Principal{UserID: 9, Username: "staff"})
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, chiCtx))
+		rec := httptest.NewRecorder()
+
+		existing := models.Product{ID: 1, Name: "Laptop", Price: usd(6100000), CreatedBy: 7, UpdatedBy: 7}
+		mockService.On("GetProductByID", 1).Return(&existing, nil)
+		mockService.On("UpdateProduct", &existing).Return(nil)
+
+		handler.UpdateProduct(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 7, existing.CreatedBy)
+		assert.Equal(t, 9, existing.UpdatedBy)
+	})
 	t.Run("Invalid Product ID", func(t *testing.T) {
 		req := httptest.NewRequest("PUT", "/product/abc", bytes.NewBuffer(reqBody))
 		req.Header.Set("Content-Type", "application/json")
@@ -294,7 +398,7 @@ func TestUpdateProduct(t *testing.T) {
 
 func TestDeleteProducts(t *testing.T) {
 	mockService := new(MockProductService)
-	handler := NewProductHander(mockService)
+	handler := NewProductHander(mockService, basePricing)
 
 	t.Run("Success", func(t *testing.T) {
 		mockService.On("DeleteProducts", 1).Return(nil)
@@ -343,3 +447,73 @@ func TestDeleteProducts(t *testing.T) {
 		assert.Contains(t, res.Body.String(), "Failed to delete product")
 	})
 }
+
+func TestGetVariantHandler(t *testing.T) {
+	mockService := new(MockProductService)
+	handler := NewProductHander(mockService, basePricing)
+
+	product := &models.Product{ID: 1, Name: "T-shirt", Price: usd(50000)}
+	mockService.On("GetVariantBySKU", "TS-S-RED").Return(&models.Variant{ID: 3, ProductID: 1, SKU: "TS-S-RED", Options: map[string]string{"size": "S"}}, product, nil)
+	mockService.On("GetVariantBySKU", "TS-XL-RED").Return(&models.Variant{ID: 4, ProductID: 1, SKU: "TS-XL-RED", Price: usdPtr(55000)}, product, nil)
+	mockService.On("GetVariantBySKU", "NOPE").Return(nil, nil, services.ErrVariantNotFound)
+
+	t.Run("Product price", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		handler.GetVariant(res, withURLParam(httptest.NewRequest("GET", "/variants/TS-S-RED", nil), "sku", "TS-S-RED"))
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		var response dto.VariantResponse
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&response))
+		assert.Equal(t, usd(50000), response.Price)
+		assert.False(t, response.PriceOverride)
+		assert.Equal(t, "S", response.Options["size"])
+	})
+	t.Run("Price override", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		handler.GetVariant(res, withURLParam(httptest.NewRequest("GET", "/variants/TS-XL-RED", nil), "sku", "TS-XL-RED"))
+
+		var response dto.VariantResponse
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&response))
+		assert.Equal(t, usd(55000), response.Price)
+		assert.True(t, response.PriceOverride)
+	})
+	t.Run("Not found", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		handler.GetVariant(res, withURLParam(httptest.NewRequest("GET", "/variants/NOPE", nil), "sku", "NOPE"))
+
+		assert.Equal(t, http.StatusNotFound, res.Code)
+	})
+}
+
+func TestCreateVariantHandler(t *testing.T) {
+	mockService := new(MockProductService)
+	handler := NewProductHander(mockService, basePricing)
+
+	body := `{"sku":"TS-S-RED","options":{"size":"S","color":"red"}}`
+	expected := &models.Variant{ProductID: 1, SKU: "TS-S-RED", Options: map[string]string{"size": "S", "color": "red"}}
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("CreateVariant", expected).Return(nil).Once()
+		mockService.On("GetVariantBySKU", "TS-S-RED").Return(expected, &models.Product{ID: 1, Price: usd(50000)}, nil).Once()
+
+		res := httptest.NewRecorder()
+		handler.CreateVariant(res, withURLParam(httptest.NewRequest("POST", "/products/1/variants", bytes.NewBufferString(body)), "id", "1"))
+
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Contains(t, res.Body.String(), `"sku":"TS-S-RED"`)
+	})
+	t.Run("Duplicate options", func(t *testing.T) {
+		mockService.On("CreateVariant", expected).Return(fmt.Errorf("%w: variant TS-1 already has these options", services.ErrInvalidVariant)).Once()
+
+		res := httptest.NewRecorder()
+		handler.CreateVariant(res, withURLParam(httptest.NewRequest("POST", "/products/1/variants", bytes.NewBufferString(body)), "id", "1"))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+	t.Run("Invalid product ID", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		handler.CreateVariant(res, withURLParam(httptest.NewRequest("POST", "/products/x/variants", bytes.NewBufferString(body)), "id", "x"))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+}
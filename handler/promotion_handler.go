@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"ecommerce/dto"
+	"ecommerce/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// PromotionHandler manages discount rules and previews them on the caller's cart
+type PromotionHandler struct {
+	promotionService services.PromotionService
+}
+
+func NewPromotionHandler(promotionService services.PromotionService) *PromotionHandler {
+	return &PromotionHandler{promotionService: promotionService}
+}
+
+func (h *PromotionHandler) GetPromotions(w http.ResponseWriter, r *http.Request) {
+	promotions, err := h.promotionService.GetPromotions(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to retrieve promotions", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewPromotionResponses(promotions))
+}
+
+func (h *PromotionHandler) GetPromotion(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid promotion ID", http.StatusBadRequest)
+		return
+	}
+	promotion, err := h.promotionService.GetPromotion(r.Context(), id)
+	if err != nil {
+		writePromotionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewPromotionResponse(promotion))
+}
+
+func (h *PromotionHandler) CreatePromotion(w http.ResponseWriter, r *http.Request) {
+	var request dto.PromotionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	promotion := request.ToModel()
+	if err := h.promotionService.CreatePromotion(r.Context(), promotion); err != nil {
+		writePromotionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.NewPromotionResponse(promotion))
+}
+
+func (h *PromotionHandler) UpdatePromotion(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid promotion ID", http.StatusBadRequest)
+		return
+	}
+	var request dto.PromotionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	promotion := request.ToModel()
+	promotion.ID = id
+	if err := h.promotionService.UpdatePromotion(r.Context(), promotion); err != nil {
+		writePromotionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewPromotionResponse(promotion))
+}
+
+func (h *PromotionHandler) DeletePromotion(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid promotion ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.promotionService.DeletePromotion(r.Context(), id); err != nil {
+		writePromotionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Promotion deleted successfully"})
+}
+
+// GetCartDiscounts previews the discounts on the caller's cart, with the
+// coupons given as ?code= parameters
+func (h *PromotionHandler) GetCartDiscounts(w http.ResponseWriter, r *http.Request) {
+	cart, result, err := h.promotionService.EvaluateCart(r.Context(), cartOwner(r), r.URL.Query()["code"])
+	if err != nil {
+		if errors.Is(err, services.ErrCartNotFound) {
+			writeCartError(w, err)
+			return
+		}
+		writePromotionError(w, err)
+		return
+	}
+	response, err := dto.NewCartDiscountsResponse(cart, result)
+	if err != nil {
+		writePromotionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func writePromotionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrPromotionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidPromotion), errors.Is(err, services.ErrCouponNotApplicable):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrPromotionExhausted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
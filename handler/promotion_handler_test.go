@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"ecommerce/models"
+	"ecommerce/services"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPromotionService struct {
+	mock.Mock
+}
+
+func (m *MockPromotionService) CreatePromotion(ctx context.Context, promotion *models.Promotion) error {
+	args := m.Called(promotion)
+	return args.Error(0)
+}
+
+func (m *MockPromotionService) GetPromotion(ctx context.Context, id int) (*models.Promotion, error) {
+	args := m.Called(id)
+	if promotion := args.Get(0); promotion != nil {
+		return promotion.(*models.Promotion), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPromotionService) GetPromotions(ctx context.Context) ([]models.Promotion, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Promotion), args.Error(1)
+}
+
+func (m *MockPromotionService) UpdatePromotion(ctx context.Context, promotion *models.Promotion) error {
+	args := m.Called(promotion)
+	return args.Error(0)
+}
+
+func (m *MockPromotionService) DeletePromotion(ctx context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockPromotionService) EvaluateCart(ctx context.Context, owner models.CartOwner, codes []string) (*models.Cart, *models.PromotionResult, error) {
+	args := m.Called(owner, codes)
+	if cart := args.Get(0); cart != nil {
+		return cart.(*models.Cart), args.Get(1).(*models.PromotionResult), args.Error(2)
+	}
+	return nil, nil, args.Error(2)
+}
+
+func TestCreatePromotionHandler(t *testing.T) {
+	mockService := new(MockPromotionService)
+	handler := NewPromotionHandler(mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("CreatePromotion", mock.MatchedBy(func(p *models.Promotion) bool {
+			return p.Kind == models.PromotionBuyXGetY && p.BuyQuantity == 2 && p.GetQuantity == 1 && len(p.CategoryIDs) == 1
+		})).Return(nil).Once()
+
+		body := `{"name":"Socks 3 for 2","kind":"buy_x_get_y","buy_quantity":2,"get_quantity":1,"category_ids":[4],"stackable":true,"active":true}`
+		res := httptest.NewRecorder()
+		handler.CreatePromotion(res, httptest.NewRequest("POST", "/promotions", bytes.NewBufferString(body)))
+
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Contains(t, res.Body.String(), `"product_ids":[]`)
+		assert.NotContains(t, res.Body.String(), `"percent"`)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		mockService.On("CreatePromotion", mock.Anything).Return(fmt.Errorf("%w: percent must be between 1 and 100", services.ErrInvalidPromotion)).Once()
+
+		res := httptest.NewRecorder()
+		handler.CreatePromotion(res, httptest.NewRequest("POST", "/promotions", bytes.NewBufferString(`{"name":"Sale","kind":"percentage"}`)))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+	mockService.AssertExpectations(t)
+}
+
+func TestGetCartDiscounts(t *testing.T) {
+	mockService := new(MockPromotionService)
+	handler := NewPromotionHandler(mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		cart := &models.Cart{ID: 3, UserID: 7, Currency: "USD", Items: []models.CartItem{{ID: 8, ProductID: 1, Quantity: 2, UnitPrice: usd(1000)}}}
+		result := &models.PromotionResult{Subtotal: usd(2000), Discount: usd(500), FreeShipping: true, Discounts: []models.Discount{
+			{PromotionID: 3, Name: "WELCOME", Code: "WELCOME", Kind: models.PromotionFixed, Line: 0, Amount: usd(500)},
+			{PromotionID: 5, Name: "Free shipping", Kind: models.PromotionFreeShipping, Line: -1, Amount: usd(0)},
+		}}
+		mockService.On("EvaluateCart", models.CartOwner{UserID: 7}, []string{"WELCOME"}).Return(cart, result, nil).Once()
+
+		res := httptest.NewRecorder()
+		handler.GetCartDiscounts(res, withCustomer(httptest.NewRequest("GET", "/cart/discounts?code=WELCOME", nil)))
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Contains(t, res.Body.String(), `"total":{"amount":"15.00","currency":"USD"}`)
+		assert.Contains(t, res.Body.String(), `"item_id":8`)
+		assert.Contains(t, res.Body.String(), `"free_shipping":true`)
+	})
+	t.Run("Coupon doesn't apply", func(t *testing.T) {
+		mockService.On("EvaluateCart", models.CartOwner{UserID: 7}, []string{"BIG"}).
+			Return(nil, nil, fmt.Errorf("%w: BIG needs an order of at least 100.00 USD", services.ErrCouponNotApplicable)).Once()
+
+		res := httptest.NewRecorder()
+		handler.GetCartDiscounts(res, withCustomer(httptest.NewRequest("GET", "/cart/discounts?code=BIG", nil)))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Contains(t, res.Body.String(), "100.00 USD")
+	})
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,278 @@
+package handler
+
+import (
+	"context"
+	"ecommerce/dto"
+	"ecommerce/models"
+	"ecommerce/services"
+	"ecommerce/utils"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ReturnHandler struct {
+	returnService services.ReturnService
+}
+
+func NewReturnHandler(returnService services.ReturnService) *ReturnHandler {
+	return &ReturnHandler{returnService: returnService}
+}
+
+// RequestReturn opens a return for lines of one of the caller's orders
+func (h *ReturnHandler) RequestReturn(w http.ResponseWriter, r *http.Request) {
+	principal, ok := utils.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+	var request dto.ReturnRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	ret, err := h.returnService.RequestReturn(r.Context(), request.ToModel(principal.UserID, orderID))
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+	response, err := dto.NewReturnResponse(ret)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *ReturnHandler) GetMyReturns(w http.ResponseWriter, r *http.Request) {
+	principal, ok := utils.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	filter, ok := returnFilter(w, r)
+	if !ok {
+		return
+	}
+	filter.UserID = principal.UserID
+
+	returns, err := h.returnService.GetReturns(r.Context(), filter)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+	response, err := dto.NewReturnResponses(returns)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *ReturnHandler) GetMyReturn(w http.ResponseWriter, r *http.Request) {
+	principal, ok := utils.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid return ID", http.StatusBadRequest)
+		return
+	}
+
+	ret, err := h.returnService.GetUserReturn(r.Context(), principal.UserID, id)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+	response, err := dto.NewReturnResponse(ret)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetReturns lists all returns, filtered by ?status= and ?user_id=, ?limit= caps how many
+func (h *ReturnHandler) GetReturns(w http.ResponseWriter, r *http.Request) {
+	filter, ok := returnFilter(w, r)
+	if !ok {
+		return
+	}
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		userID, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		filter.UserID = userID
+	}
+
+	returns, err := h.returnService.GetReturns(r.Context(), filter)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+	response, err := dto.NewReturnResponses(returns)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *ReturnHandler) GetReturn(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid return ID", http.StatusBadRequest)
+		return
+	}
+
+	ret, err := h.returnService.GetReturn(r.Context(), id)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+	response, err := dto.NewReturnResponse(ret)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *ReturnHandler) ApproveReturn(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, h.returnService.ApproveReturn)
+}
+
+func (h *ReturnHandler) RejectReturn(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, h.returnService.RejectReturn)
+}
+
+// ReceiveReturn books the goods of an approved return in, optionally restocking them
+func (h *ReturnHandler) ReceiveReturn(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid return ID", http.StatusBadRequest)
+		return
+	}
+	var request dto.ReceiveReturnRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	receipt := request.ToModel(id)
+	receipt.ReceivedBy = actorID(r)
+	ret, err := h.returnService.ReceiveReturn(r.Context(), receipt)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+	response, err := dto.NewReturnResponse(ret)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// RefundReturn refunds the return through the order's payments, the full
+// value of the returned lines unless an amount is given
+func (h *ReturnHandler) RefundReturn(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid return ID", http.StatusBadRequest)
+		return
+	}
+	var request dto.RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	ret, err := h.returnService.RefundReturn(r.Context(), id, request.Amount, actorID(r))
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+	response, err := dto.NewReturnResponse(ret)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// review approves or rejects a return with an optional note
+func (h *ReturnHandler) review(w http.ResponseWriter, r *http.Request, apply func(ctx context.Context, id int, note string) (*models.Return, error)) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid return ID", http.StatusBadRequest)
+		return
+	}
+	var request dto.ReturnNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	ret, err := apply(r.Context(), id, request.Note)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+	response, err := dto.NewReturnResponse(ret)
+	if err != nil {
+		writeReturnError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func returnFilter(w http.ResponseWriter, r *http.Request) (models.ReturnFilter, bool) {
+	filter := models.ReturnFilter{Status: models.ReturnStatus(r.URL.Query().Get("status"))}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.Limit = limit
+	}
+	return filter, true
+}
+
+func writeReturnError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrReturnNotFound), errors.Is(err, services.ErrOrderNotFound), errors.Is(err, services.ErrWarehouseNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidReturn), errors.Is(err, services.ErrInvalidReturnStatus):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrInvalidReturnTransition), errors.Is(err, services.ErrInvalidTransition), errors.Is(err, services.ErrOrderStatusConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrGatewayRejected):
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
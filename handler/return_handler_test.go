@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"ecommerce/models"
+	"ecommerce/services"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReturnService struct {
+	mock.Mock
+}
+
+func (m *MockReturnService) RequestReturn(ctx context.Context, ret *models.Return) (*models.Return, error) {
+	return m.ret(m.Called(ret))
+}
+
+func (m *MockReturnService) GetReturn(ctx context.Context, id int) (*models.Return, error) {
+	return m.ret(m.Called(id))
+}
+
+func (m *MockReturnService) GetUserReturn(ctx context.Context, userID, id int) (*models.Return, error) {
+	return m.ret(m.Called(userID, id))
+}
+
+func (m *MockReturnService) GetReturns(ctx context.Context, filter models.ReturnFilter) ([]models.Return, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]models.Return), args.Error(1)
+}
+
+func (m *MockReturnService) ApproveReturn(ctx context.Context, id int, note string) (*models.Return, error) {
+	return m.ret(m.Called(id, note))
+}
+
+func (m *MockReturnService) RejectReturn(ctx context.Context, id int, note string) (*models.Return, error) {
+	return m.ret(m.Called(id, note))
+}
+
+func (m *MockReturnService) ReceiveReturn(ctx context.Context, receipt *models.ReturnReceipt) (*models.Return, error) {
+	return m.ret(m.Called(receipt))
+}
+
+func (m *MockReturnService) RefundReturn(ctx context.Context, id int, amount models.Money, actorID int) (*models.Return, error) {
+	return m.ret(m.Called(id, amount, actorID))
+}
+
+func (m *MockReturnService) ret(args mock.Arguments) (*models.Return, error) {
+	if ret := args.Get(0); ret != nil {
+		return ret.(*models.Return), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestRequestReturnHandler(t *testing.T) {
+	mockService := new(MockReturnService)
+	handler := NewReturnHandler(mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		expected := &models.Return{OrderID: 40, UserID: 7, Reason: "chipped", Items: []models.ReturnItem{{OrderItemID: 1, Quantity: 1}}}
+		created := &models.Return{ID: 60, OrderID: 40, UserID: 7, Status: models.ReturnRequested, Currency: "USD", Reason: "chipped",
+			Items: []models.ReturnItem{{ID: 5, OrderItemID: 1, Name: "Mug", Quantity: 1, UnitPrice: usd(950)}}}
+		mockService.On("RequestReturn", expected).Return(created, nil).Once()
+
+		body := `{"reason":"chipped","items":[{"order_item_id":1,"quantity":1}]}`
+		req := withCustomer(httptest.NewRequest("POST", "/me/orders/40/returns", bytes.NewBufferString(body)))
+		res := httptest.NewRecorder()
+		handler.RequestReturn(res, withURLParam(req, "id", "40"))
+
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Contains(t, res.Body.String(), `"status":"requested"`)
+		assert.Contains(t, res.Body.String(), `"value":{"amount":"9.50","currency":"USD"}`)
+	})
+	t.Run("Too many", func(t *testing.T) {
+		mockService.On("RequestReturn", mock.Anything).Return(nil, fmt.Errorf("%w: 1 of Mug can still be returned", services.ErrInvalidReturn)).Once()
+
+		body := `{"reason":"chipped","items":[{"order_item_id":1,"quantity":3}]}`
+		req := withCustomer(httptest.NewRequest("POST", "/me/orders/40/returns", bytes.NewBufferString(body)))
+		res := httptest.NewRecorder()
+		handler.RequestReturn(res, withURLParam(req, "id", "40"))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+}
+
+func TestRefundReturnHandler(t *testing.T) {
+	mockService := new(MockReturnService)
+	handler := NewReturnHandler(mockService)
+
+	t.Run("Full value", func(t *testing.T) {
+		mockService.On("RefundReturn", 60, models.Money{}, 0).Return(&models.Return{ID: 60, Status: models.ReturnRefunded, RefundAmount: usd(1900)}, nil).Once()
+
+		res := httptest.NewRecorder()
+		handler.RefundReturn(res, withURLParam(httptest.NewRequest("POST", "/returns/60/refund", nil), "id", "60"))
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Contains(t, res.Body.String(), `"refund_amount":{"amount":"19.00","currency":"USD"}`)
+	})
+	t.Run("Not received yet", func(t *testing.T) {
+		mockService.On("RefundReturn", 60, usd(500), 0).Return(nil, fmt.Errorf("%w: a requested return can't become refunded", services.ErrInvalidReturnTransition)).Once()
+
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/returns/60/refund", bytes.NewBufferString(`{"amount":{"amount":"5","currency":"USD"}}`))
+		handler.RefundReturn(res, withURLParam(req, "id", "60"))
+
+		assert.Equal(t, http.StatusConflict, res.Code)
+	})
+}
@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"ecommerce/dto"
+	"ecommerce/models"
+	"ecommerce/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ShippingHandler manages the shipping zones and methods and quotes the
+// ways of shipping the caller's cart
+type ShippingHandler struct {
+	shippingService services.ShippingService
+}
+
+func NewShippingHandler(shippingService services.ShippingService) *ShippingHandler {
+	return &ShippingHandler{shippingService: shippingService}
+}
+
+func (h *ShippingHandler) GetShippingZones(w http.ResponseWriter, r *http.Request) {
+	zones, err := h.shippingService.GetShippingZones(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to retrieve shipping zones", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewShippingZoneResponses(zones))
+}
+
+func (h *ShippingHandler) GetShippingZone(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid shipping zone ID", http.StatusBadRequest)
+		return
+	}
+	zone, err := h.shippingService.GetShippingZone(r.Context(), id)
+	if err != nil {
+		writeShippingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewShippingZoneResponse(zone))
+}
+
+func (h *ShippingHandler) CreateShippingZone(w http.ResponseWriter, r *http.Request) {
+	var request dto.ShippingZoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	zone := request.ToModel()
+	if err := h.shippingService.CreateShippingZone(r.Context(), zone); err != nil {
+		writeShippingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.NewShippingZoneResponse(zone))
+}
+
+func (h *ShippingHandler) UpdateShippingZone(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid shipping zone ID", http.StatusBadRequest)
+		return
+	}
+	var request dto.ShippingZoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	zone := request.ToModel()
+	zone.ID = id
+	if err := h.shippingService.UpdateShippingZone(r.Context(), zone); err != nil {
+		writeShippingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewShippingZoneResponse(zone))
+}
+
+func (h *ShippingHandler) DeleteShippingZone(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid shipping zone ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.shippingService.DeleteShippingZone(r.Context(), id); err != nil {
+		writeShippingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Shipping zone deleted successfully"})
+}
+
+// GetShippingMethods lists the methods, ?zone_id= and ?active=true narrow them down
+func (h *ShippingHandler) GetShippingMethods(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var filter models.ShippingMethodFilter
+	if zoneID := query.Get("zone_id"); zoneID != "" {
+		id, err := strconv.Atoi(zoneID)
+		if err != nil {
+			http.Error(w, "Invalid shipping zone ID", http.StatusBadRequest)
+			return
+		}
+		filter.ZoneID = id
+	}
+	filter.ActiveOnly, _ = strconv.ParseBool(query.Get("active"))
+
+	methods, err := h.shippingService.GetShippingMethods(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to retrieve shipping methods", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewShippingMethodResponses(methods))
+}
+
+func (h *ShippingHandler) GetShippingMethod(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid shipping method ID", http.StatusBadRequest)
+		return
+	}
+	method, err := h.shippingService.GetShippingMethod(r.Context(), id)
+	if err != nil {
+		writeShippingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewShippingMethodResponse(method))
+}
+
+func (h *ShippingHandler) CreateShippingMethod(w http.ResponseWriter, r *http.Request) {
+	var request dto.ShippingMethodRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	method := request.ToModel()
+	if err := h.shippingService.CreateShippingMethod(r.Context(), method); err != nil {
+		writeShippingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.NewShippingMethodResponse(method))
+}
+
+func (h *ShippingHandler) UpdateShippingMethod(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid shipping method ID", http.StatusBadRequest)
+		return
+	}
+	var request dto.ShippingMethodRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	method := request.ToModel()
+	method.ID = id
+	if err := h.shippingService.UpdateShippingMethod(r.Context(), method); err != nil {
+		writeShippingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewShippingMethodResponse(method))
+}
+
+func (h *ShippingHandler) DeleteShippingMethod(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid shipping method ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.shippingService.DeleteShippingMethod(r.Context(), id); err != nil {
+		writeShippingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Shipping method deleted successfully"})
+}
+
+// GetCartShippingOptions lists the ways of shipping the caller's cart to the
+// destination in ?country=, ?region= and ?postcode=, with the coupons given
+// as ?code=
+func (h *ShippingHandler) GetCartShippingOptions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	destination := models.Destination{Country: query.Get("country"), Region: query.Get("region"), Postcode: query.Get("postcode")}
+	quotes, err := h.shippingService.GetCartShippingOptions(r.Context(), cartOwner(r), destination, query["code"])
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCartNotFound):
+			writeCartError(w, err)
+		case errors.Is(err, services.ErrCouponNotApplicable):
+			writePromotionError(w, err)
+		default:
+			writeShippingError(w, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewCartShippingOptionsResponse(destination, quotes))
+}
+
+func writeShippingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrShippingZoneNotFound), errors.Is(err, services.ErrShippingMethodNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidShippingZone), errors.Is(err, services.ErrInvalidShippingMethod),
+		errors.Is(err, services.ErrInvalidDestination), errors.Is(err, services.ErrEmptyCart):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrShippingUnavailable):
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"ecommerce/models"
+	"ecommerce/services"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockShippingService struct {
+	mock.Mock
+}
+
+func (m *MockShippingService) GetShippingZones(ctx context.Context) ([]models.ShippingZone, error) {
+	args := m.Called()
+	return args.Get(0).([]models.ShippingZone), args.Error(1)
+}
+
+func (m *MockShippingService) GetShippingZone(ctx context.Context, id int) (*models.ShippingZone, error) {
+	args := m.Called(id)
+	if zone := args.Get(0); zone != nil {
+		return zone.(*models.ShippingZone), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockShippingService) CreateShippingZone(ctx context.Context, zone *models.ShippingZone) error {
+	args := m.Called(zone)
+	return args.Error(0)
+}
+
+func (m *MockShippingService) UpdateShippingZone(ctx context.Context, zone *models.ShippingZone) error {
+	args := m.Called(zone)
+	return args.Error(0)
+}
+
+func (m *MockShippingService) DeleteShippingZone(ctx context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockShippingService) GetShippingMethods(ctx context.Context, filter models.ShippingMethodFilter) ([]models.ShippingMethod, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]models.ShippingMethod), args.Error(1)
+}
+
+func (m *MockShippingService) GetShippingMethod(ctx context.Context, id int) (*models.ShippingMethod, error) {
+	args := m.Called(id)
+	if method := args.Get(0); method != nil {
+		return method.(*models.ShippingMethod), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockShippingService) CreateShippingMethod(ctx context.Context, method *models.ShippingMethod) error {
+	args := m.Called(method)
+	return args.Error(0)
+}
+
+func (m *MockShippingService) UpdateShippingMethod(ctx context.Context, method *models.ShippingMethod) error {
+	args := m.Called(method)
+	return args.Error(0)
+}
+
+func (m *MockShippingService) DeleteShippingMethod(ctx context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockShippingService) GetCartShippingOptions(ctx context.Context, owner models.CartOwner, destination models.Destination,
+	codes []string) ([]models.ShippingQuote, error) {
+	args := m.Called(owner, destination, codes)
+	if quotes := args.Get(0); quotes != nil {
+		return quotes.([]models.ShippingQuote), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestCreateShippingMethodHandler(t *testing.T) {
+	mockService := new(MockShippingService)
+	handler := NewShippingHandler(mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("CreateShippingMethod", &models.ShippingMethod{ZoneID: 1, Name: "Standard", Kind: models.ShippingByWeight, Currency: "USD",
+			Tiers: []models.ShippingTier{{Min: 0, Price: usd(500)}, {Min: 2000, Price: usd(900)}}, EstimatedDays: 5, Active: true}).
+			Return(nil).Once()
+
+		body := `{"zone_id":1,"name":"Standard","kind":"weight","currency":"USD","estimated_days":5,"active":true,
+			"tiers":[{"min":0,"price":{"amount":"5.00","currency":"USD"}},{"min":2000,"price":{"amount":"9.00","currency":"USD"}}]}`
+		res := httptest.NewRecorder()
+		handler.CreateShippingMethod(res, httptest.NewRequest("POST", "/shipping-methods", bytes.NewBufferString(body)))
+
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Contains(t, res.Body.String(), `{"min":2000,"price":{"amount":"9.00","currency":"USD"}}`)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		mockService.On("CreateShippingMethod", mock.Anything).Return(fmt.Errorf("%w: kind must be flat, weight or price", services.ErrInvalidShippingMethod)).Once()
+
+		res := httptest.NewRecorder()
+		handler.CreateShippingMethod(res, httptest.NewRequest("POST", "/shipping-methods", bytes.NewBufferString(`{"zone_id":1,"name":"Standard","kind":"volume"}`)))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+	mockService.AssertExpectations(t)
+}
+
+func TestGetShippingZoneHandler(t *testing.T) {
+	mockService := new(MockShippingService)
+	handler := NewShippingHandler(mockService)
+
+	mockService.On("GetShippingZone", 4).Return(nil, services.ErrShippingZoneNotFound).Once()
+
+	res := httptest.NewRecorder()
+	handler.GetShippingZone(res, withURLParam(httptest.NewRequest("GET", "/shipping-zones/4", nil), "id", "4"))
+
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetCartShippingOptions(t *testing.T) {
+	mockService := new(MockShippingService)
+	handler := NewShippingHandler(mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		destination := models.Destination{Country: "us", Postcode: "10001"}
+		quotes := []models.ShippingQuote{
+			{Provider: "table", Code: "table:4", Name: "Standard", Price: usd(900), Cost: usd(0), EstimatedDays: 5},
+			{Provider: "table", Code: "table:5", Name: "Next day", Price: usd(2500), Cost: usd(2500), EstimatedDays: 1},
+		}
+		mockService.On("GetCartShippingOptions", models.CartOwner{UserID: 7}, destination, []string{"SHIPFREE"}).Return(quotes, nil).Once()
+
+		res := httptest.NewRecorder()
+		handler.GetCartShippingOptions(res, withCustomer(httptest.NewRequest("GET", "/cart/shipping-options?country=us&postcode=10001&code=SHIPFREE", nil)))
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Contains(t, res.Body.String(), `"destination":{"country":"US","region":"","postcode":"10001"}`)
+		assert.Contains(t, res.Body.String(), `"code":"table:4"`)
+		assert.Contains(t, res.Body.String(), `"cost":{"amount":"0.00","currency":"USD"}`)
+	})
+	t.Run("Empty cart", func(t *testing.T) {
+		mockService.On("GetCartShippingOptions", models.CartOwner{UserID: 7}, models.Destination{Country: "US"}, []string(nil)).Return(nil, services.ErrEmptyCart).Once()
+
+		res := httptest.NewRecorder()
+		handler.GetCartShippingOptions(res, withCustomer(httptest.NewRequest("GET", "/cart/shipping-options?country=US", nil)))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+	t.Run("Providers down", func(t *testing.T) {
+		mockService.On("GetCartShippingOptions", models.CartOwner{UserID: 7}, models.Destination{Country: "DE"}, []string(nil)).
+			Return(nil, fmt.Errorf("%w: carrier unreachable", services.ErrShippingUnavailable)).Once()
+
+		res := httptest.NewRecorder()
+		handler.GetCartShippingOptions(res, withCustomer(httptest.NewRequest("GET", "/cart/shipping-options?country=DE", nil)))
+
+		assert.Equal(t, http.StatusBadGateway, res.Code)
+	})
+	mockService.AssertExpectations(t)
+}
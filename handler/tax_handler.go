@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"ecommerce/dto"
+	"ecommerce/models"
+	"ecommerce/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TaxHandler manages the tax classes and the rate table and previews the
+// tax on the caller's cart
+type TaxHandler struct {
+	taxService services.TaxService
+}
+
+func NewTaxHandler(taxService services.TaxService) *TaxHandler {
+	return &TaxHandler{taxService: taxService}
+}
+
+func (h *TaxHandler) GetTaxClasses(w http.ResponseWriter, r *http.Request) {
+	classes, err := h.taxService.GetTaxClasses(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to retrieve tax classes", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewTaxClassResponses(classes))
+}
+
+func (h *TaxHandler) CreateTaxClass(w http.ResponseWriter, r *http.Request) {
+	var request dto.TaxClassRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	class := request.ToModel()
+	if err := h.taxService.CreateTaxClass(r.Context(), class); err != nil {
+		writeTaxError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.NewTaxClassResponse(class))
+}
+
+// GetTaxRates lists the rate table, ?tax_class= and ?country= narrow it down
+func (h *TaxHandler) GetTaxRates(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.TaxRateFilter{TaxClass: query.Get("tax_class"), Country: query.Get("country")}
+	rates, err := h.taxService.GetTaxRates(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to retrieve tax rates", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewTaxRateResponses(rates))
+}
+
+func (h *TaxHandler) GetTaxRate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid tax rate ID", http.StatusBadRequest)
+		return
+	}
+	rate, err := h.taxService.GetTaxRate(r.Context(), id)
+	if err != nil {
+		writeTaxError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewTaxRateResponse(rate))
+}
+
+func (h *TaxHandler) CreateTaxRate(w http.ResponseWriter, r *http.Request) {
+	var request dto.TaxRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	rate := request.ToModel()
+	if err := h.taxService.CreateTaxRate(r.Context(), rate); err != nil {
+		writeTaxError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.NewTaxRateResponse(rate))
+}
+
+func (h *TaxHandler) UpdateTaxRate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid tax rate ID", http.StatusBadRequest)
+		return
+	}
+	var request dto.TaxRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	rate := request.ToModel()
+	rate.ID = id
+	if err := h.taxService.UpdateTaxRate(r.Context(), rate); err != nil {
+		writeTaxError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewTaxRateResponse(rate))
+}
+
+func (h *TaxHandler) DeleteTaxRate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid tax rate ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.taxService.DeleteTaxRate(r.Context(), id); err != nil {
+		writeTaxError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Tax rate deleted successfully"})
+}
+
+// GetCartTaxes previews the tax on the caller's cart for the destination in
+// ?country=, ?region= and ?postcode=, with the coupons given as ?code=
+func (h *TaxHandler) GetCartTaxes(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	destination := models.Destination{Country: query.Get("country"), Region: query.Get("region"), Postcode: query.Get("postcode")}
+	cart, promotions, taxes, err := h.taxService.EvaluateCart(r.Context(), cartOwner(r), destination, query["code"])
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCartNotFound):
+			writeCartError(w, err)
+		case errors.Is(err, services.ErrCouponNotApplicable):
+			writePromotionError(w, err)
+		default:
+			writeTaxError(w, err)
+		}
+		return
+	}
+	response, err := dto.NewCartTaxesResponse(cart, promotions, taxes)
+	if err != nil {
+		writeTaxError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeTaxError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrTaxRateNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidTaxRate), errors.Is(err, services.ErrInvalidTaxClass), errors.Is(err, services.ErrInvalidDestination):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"ecommerce/models"
+	"ecommerce/services"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockTaxService struct {
+	mock.Mock
+}
+
+func (m *MockTaxService) GetTaxClasses(ctx context.Context) ([]models.TaxClass, error) {
+	args := m.Called()
+	return args.Get(0).([]models.TaxClass), args.Error(1)
+}
+
+func (m *MockTaxService) CreateTaxClass(ctx context.Context, class *models.TaxClass) error {
+	args := m.Called(class)
+	return args.Error(0)
+}
+
+func (m *MockTaxService) GetTaxRates(ctx context.Context, filter models.TaxRateFilter) ([]models.TaxRate, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]models.TaxRate), args.Error(1)
+}
+
+func (m *MockTaxService) GetTaxRate(ctx context.Context, id int) (*models.TaxRate, error) {
+	args := m.Called(id)
+	if rate := args.Get(0); rate != nil {
+		return rate.(*models.TaxRate), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTaxService) CreateTaxRate(ctx context.Context, rate *models.TaxRate) error {
+	args := m.Called(rate)
+	return args.Error(0)
+}
+
+func (m *MockTaxService) UpdateTaxRate(ctx context.Context, rate *models.TaxRate) error {
+	args := m.Called(rate)
+	return args.Error(0)
+}
+
+func (m *MockTaxService) DeleteTaxRate(ctx context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockTaxService) EvaluateCart(ctx context.Context, owner models.CartOwner, destination models.Destination,
+	codes []string) (*models.Cart, *models.PromotionResult, *models.TaxResult, error) {
+	args := m.Called(owner, destination, codes)
+	if cart := args.Get(0); cart != nil {
+		return cart.(*models.Cart), args.Get(1).(*models.PromotionResult), args.Get(2).(*models.TaxResult), args.Error(3)
+	}
+	return nil, nil, nil, args.Error(3)
+}
+
+func TestCreateTaxRateHandler(t *testing.T) {
+	mockService := new(MockTaxService)
+	handler := NewTaxHandler(mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("CreateTaxRate", &models.TaxRate{TaxClass: "standard", Country: "US", Region: "CA", Rate: 72500, Name: "CA sales tax"}).
+			Return(nil).Once()
+
+		body := `{"tax_class":"standard","country":"US","region":"CA","rate":"7.25","name":"CA sales tax"}`
+		res := httptest.NewRecorder()
+		handler.CreateTaxRate(res, httptest.NewRequest("POST", "/tax-rates", bytes.NewBufferString(body)))
+
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Contains(t, res.Body.String(), `"rate":"7.25"`)
+		assert.NotContains(t, res.Body.String(), `"postcode"`)
+	})
+	t.Run("Rate out of range", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		handler.CreateTaxRate(res, httptest.NewRequest("POST", "/tax-rates", bytes.NewBufferString(`{"tax_class":"standard","rate":"150"}`)))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+	t.Run("Unknown class", func(t *testing.T) {
+		mockService.On("CreateTaxRate", mock.Anything).Return(fmt.Errorf("%w: unknown tax class \"luxury\"", services.ErrInvalidTaxRate)).Once()
+
+		res := httptest.NewRecorder()
+		handler.CreateTaxRate(res, httptest.NewRequest("POST", "/tax-rates", bytes.NewBufferString(`{"tax_class":"luxury","country":"US","rate":"10","name":"Tax"}`)))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteTaxRateHandler(t *testing.T) {
+	mockService := new(MockTaxService)
+	handler := NewTaxHandler(mockService)
+
+	mockService.On("DeleteTaxRate", 9).Return(services.ErrTaxRateNotFound).Once()
+
+	res := httptest.NewRecorder()
+	handler.DeleteTaxRate(res, withURLParam(httptest.NewRequest("DELETE", "/tax-rates/9", nil), "id", "9"))
+
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetCartTaxes(t *testing.T) {
+	mockService := new(MockTaxService)
+	handler := NewTaxHandler(mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		cart := &models.Cart{ID: 3, UserID: 7, Currency: "USD", Items: []models.CartItem{{ID: 8, ProductID: 1, TaxClass: "standard", Quantity: 2, UnitPrice: usd(1000)}}}
+		promotions := &models.PromotionResult{Subtotal: usd(2000), Discount: usd(500)}
+		destination := models.Destination{Country: "US", Region: "NY", Postcode: "10001"}
+		taxes := &models.TaxResult{Mode: models.TaxExclusive, Destination: destination, Net: usd(1500), Tax: usd(133), Gross: usd(1633),
+			Lines: []models.LineTax{{Line: 0, TaxClass: "standard", RateID: 2, Name: "NYC sales tax", Rate: 88750, Net: usd(1500), Tax: usd(133), Gross: usd(1633)}}}
+		mockService.On("EvaluateCart", models.CartOwner{UserID: 7}, destination, []string{"WELCOME"}).Return(cart, promotions, taxes, nil).Once()
+
+		res := httptest.NewRecorder()
+		handler.GetCartTaxes(res, withCustomer(httptest.NewRequest("GET", "/cart/taxes?country=US&region=NY&postcode=10001&code=WELCOME", nil)))
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Contains(t, res.Body.String(), `"total":{"amount":"16.33","currency":"USD"}`)
+		assert.Contains(t, res.Body.String(), `"item_id":8`)
+		assert.Contains(t, res.Body.String(), `"rate":"8.875"`)
+	})
+	t.Run("Invalid destination", func(t *testing.T) {
+		mockService.On("EvaluateCart", models.CartOwner{UserID: 7}, models.Destination{Country: "USA"}, []string(nil)).
+			Return(nil, nil, nil, fmt.Errorf("%w: country must be an ISO 3166-1 alpha-2 code", services.ErrInvalidDestination)).Once()
+
+		res := httptest.NewRecorder()
+		handler.GetCartTaxes(res, withCustomer(httptest.NewRequest("GET", "/cart/taxes?country=USA", nil)))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+	mockService.AssertExpectations(t)
+}
@@ -1,8 +1,12 @@
 package handler
 
 import (
+	"ecommerce/dto"
 	"ecommerce/models"
 	"ecommerce/services"
+	"ecommerce/utils"
+	"errors"
+	"log"
 	"strconv"
 
 	"encoding/json"
@@ -13,43 +17,49 @@ import (
 
 type UserHandler struct {
 	userService services.UserService
+	cartService services.CartService
 }
 
-func NewUserHandler(userService services.UserService) *UserHandler {
-	return &UserHandler{userService: userService}
+func NewUserHandler(userService services.UserService, cartService services.CartService) *UserHandler {
+	return &UserHandler{userService: userService, cartService: cartService}
 }
 
 func (h *UserHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
-	var request struct {
-		Username string
-		Password string
-	}
+	var request dto.LoginRequest
 	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
 
-	token, err := h.userService.Login(request.Username, request.Password)
+	tokens, err := h.userService.Login(r.Context(), request.Username, request.Password)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
+	// a guest cart follows the shopper into their account, failing to merge it must not fail the login
+	if guestToken := r.Header.Get(CartTokenHeader); guestToken != "" {
+		if err := h.cartService.MergeGuestCart(r.Context(), tokens.UserID, guestToken); err != nil {
+			log.Printf("failed to merge guest cart into cart of user %d: %v", tokens.UserID, err)
+		}
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"token": token})
+	json.NewEncoder(w).Encode(dto.NewTokenResponse(tokens))
 }
 
 func (h *UserHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
-	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
+	var request dto.CreateUserRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
+	user := request.ToModel()
+	user.Role = models.RoleCustomer // self-registration never grants elevated roles
 
-	err = h.userService.CreateUser(&user)
+	err = h.userService.CreateUser(r.Context(), user)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeUserError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -64,24 +74,24 @@ func (h *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.userService.GetUserByID(id)
+	user, err := h.userService.GetUserByID(r.Context(), id)
 	if err != nil {
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(user)
+	json.NewEncoder(w).Encode(dto.NewUserResponse(user))
 }
 
 func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.userService.GetAllUser()
+	users, err := h.userService.GetAllUser(r.Context())
 	if err != nil {
 		http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(users)
+	json.NewEncoder(w).Encode(dto.NewUserResponses(users))
 }
 
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
@@ -92,35 +102,24 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var updatedUser models.User
-	err = json.NewDecoder(r.Body).Decode(&updatedUser)
+	var request dto.UpdateUserRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		http.Error(w, "Invalid request ", http.StatusBadRequest)
 		return
 	}
 
 	// Retrieve existing user details from the database
-	existingUser, err := h.userService.GetUserByID(id)
+	existingUser, err := h.userService.GetUserByID(r.Context(), id)
 	if err != nil || existingUser == nil {
 		http.Error(w, "User not found", http.StatusInternalServerError)
 		return
 	}
-	if updatedUser.Name != "" {
-		existingUser.Name = updatedUser.Name
-	}
-	if updatedUser.Email != "" {
-		existingUser.Email = updatedUser.Email
-	}
-	if updatedUser.Username != "" {
-		existingUser.Username = updatedUser.Username
-	}
-	if updatedUser.Password != "" {
-		existingUser.Password = updatedUser.Password
-	}
+	request.ApplyTo(existingUser)
 
-	err = h.userService.UpdateUser(existingUser)
+	err = h.userService.UpdateUser(r.Context(), existingUser)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeUserError(w, err)
 		return
 	}
 
@@ -135,7 +134,7 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
-	err = h.userService.DeleteUser(id)
+	err = h.userService.DeleteUser(r.Context(), id)
 	if err != nil {
 		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
 		return
@@ -143,3 +142,101 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message: ": "User deleted successfully"})
 }
+
+// GetMe returns the profile of the authenticated caller
+func (h *UserHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewUserResponse(user))
+}
+
+// UpdateMe lets the caller edit their own profile, email and password changes need the current password
+func (h *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	var request dto.UpdateMeRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	emailChanged := request.Email != "" && request.Email != user.Email
+	if emailChanged || request.Password != "" {
+		if request.CurrentPassword == "" {
+			http.Error(w, "Current password is required", http.StatusBadRequest)
+			return
+		}
+		if err := h.userService.CheckPassword(user, request.CurrentPassword); err != nil {
+			http.Error(w, "Current password is incorrect", http.StatusForbidden)
+			return
+		}
+	}
+
+	if request.Name != "" {
+		user.Name = request.Name
+	}
+	if request.Username != "" {
+		user.Username = request.Username
+	}
+	if emailChanged {
+		user.Email = request.Email
+	}
+	if request.Password != "" {
+		user.Password = request.Password
+	}
+
+	err = h.userService.UpdateUser(r.Context(), user)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Profile updated successfully"})
+}
+
+// DeleteMe removes the caller's own account
+func (h *UserHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	principal, ok := utils.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	err := h.userService.DeleteUser(r.Context(), principal.UserID)
+	if err != nil {
+		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Account deleted successfully"})
+}
+
+// currentUser loads the user behind the request principal, writing the error response when it can't
+func (h *UserHandler) currentUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	principal, ok := utils.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	user, err := h.userService.GetUserByID(r.Context(), principal.UserID)
+	if err != nil || user == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+	return user, true
+}
+
+func writeUserError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrUserExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -3,12 +3,17 @@ package handler
 import (
 	"bytes"
 	"context"
+	"ecommerce/dto"
 	"ecommerce/models"
+	"ecommerce/services"
+	"ecommerce/utils"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
@@ -19,39 +24,49 @@ type MockUserService struct {
 	mock.Mock
 }
 
-func (m *MockUserService) Login(username, password string) (string, error) {
+func (m *MockUserService) Login(ctx context.Context, username, password string) (*models.TokenPair, error) {
 	args := m.Called(username, password)
-	return args.String(0), args.Error(1)
+	pair := args.Get(0)
+	if pair != nil {
+		return pair.(*models.TokenPair), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockUserService) CheckPassword(user *models.User, password string) error {
+	args := m.Called(user, password)
+	return args.Error(0)
 }
 
-func (m *MockUserService) CreateUser(user *models.User) error {
+func (m *MockUserService) CreateUser(ctx context.Context, user *models.User) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
 
-func (m *MockUserService) GetUserByID(id int) (*models.User, error) {
+func (m *MockUserService) GetUserByID(ctx context.Context, id int) (*models.User, error) {
 	args := m.Called(id)
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserService) GetAllUser() ([]models.User, error) {
+func (m *MockUserService) GetAllUser(ctx context.Context) ([]models.User, error) {
 	args := m.Called()
 	return args.Get(0).([]models.User), args.Error(1)
 }
 
-func (m *MockUserService) UpdateUser(user *models.User) error {
+func (m *MockUserService) UpdateUser(ctx context.Context, user *models.User) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
 
-func (m *MockUserService) DeleteUser(id int) error {
+func (m *MockUserService) DeleteUser(ctx context.Context, id int) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
 func TestLoginHandler(t *testing.T) {
 	mockService := new(MockUserService) // Create mock service
-	handler := NewUserHandler(mockService)
+	cartService := new(MockCartService)
+	handler := NewUserHandler(mockService, cartService)
 
 	t.Run("Success", func(t *testing.T) {
 		user := models.User{
@@ -64,16 +79,33 @@ func TestLoginHandler(t *testing.T) {
 		req.Header.Set("Content-Type", "application/json")
 		res := httptest.NewRecorder() // Captures the response from the handler
 
-		mockService.On("Login", "abhay123", "abhay@123").Return("tokenString", nil) // sets up expectations
-		handler.LoginHandler(res, req)                                              // call actual Handler
+		pair := &models.TokenPair{AccessToken: "tokenString", AccessExpiresAt: time.Now().Add(15 * time.Minute), RefreshToken: "refreshString"}
+		mockService.On("Login", "abhay123", "abhay@123").Return(pair, nil) // sets up expectations
+		handler.LoginHandler(res, req)                                     // call actual Handler
 
 		assert.Equal(t, http.StatusOK, res.Code) // check status code 200
 
-		var resp map[string]string              // store key-value pairs from the JSON response
+		var resp dto.TokenResponse              // store the JSON response
 		json.Unmarshal(res.Body.Bytes(), &resp) // decode the JSON response
 		// .Bytes() extracts the raw response as a byte slice
 		// json.Unmarshal converts the byte(JSON) into a Go data structure.
-		assert.Equal(t, "tokenString", resp["token"])
+		assert.Equal(t, "tokenString", resp.AccessToken)
+		assert.Equal(t, "refreshString", resp.RefreshToken)
+		assert.Equal(t, "Bearer", resp.TokenType)
+		assert.InDelta(t, 900, resp.ExpiresIn, 1)
+	})
+	t.Run("Merges guest cart", func(t *testing.T) {
+		pair := &models.TokenPair{UserID: 7, AccessToken: "tokenString", AccessExpiresAt: time.Now().Add(15 * time.Minute), RefreshToken: "refreshString"}
+		mockService.On("Login", "guest", "guest@123").Return(pair, nil).Once()
+		cartService.On("MergeGuestCart", 7, "guest-token").Return(errors.New("db down")).Once()
+
+		req := httptest.NewRequest("POST", "/login", bytes.NewBufferString(`{"username":"guest","password":"guest@123"}`))
+		req.Header.Set(CartTokenHeader, "guest-token")
+		res := httptest.NewRecorder()
+		handler.LoginHandler(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code) // a failed merge doesn't fail the login
+		cartService.AssertExpectations(t)
 	})
 	t.Run("Invalid request body", func(t *testing.T) {
 		req := httptest.NewRequest("POST", "/login", bytes.NewBuffer([]byte("{invalid json")))
@@ -95,7 +127,7 @@ func TestLoginHandler(t *testing.T) {
 		req.Header.Set("Content-Type", "application/json")
 		res := httptest.NewRecorder()
 
-		mockService.On("Login", "abhay123", "abhay123").Return("", errors.New("invalid username or password"))
+		mockService.On("Login", "abhay123", "abhay123").Return(nil, errors.New("invalid username or password"))
 
 		handler.LoginHandler(res, req)
 
@@ -106,15 +138,15 @@ func TestLoginHandler(t *testing.T) {
 
 func TestRegisterUser(t *testing.T) {
 	mockService := new(MockUserService)
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, nil)
 
 	t.Run("success", func(t *testing.T) {
 		user := models.User{
-			Id:       1,
 			Name:     "Abhay",
 			Email:    "abhay123@gmail.com",
 			Username: "abhay123",
 			Password: "abhay@123",
+			Role:     models.RoleCustomer,
 		}
 		body, _ := json.Marshal(user)
 		req := httptest.NewRequest("Post", "/users", bytes.NewBuffer(body))
@@ -132,6 +164,21 @@ func TestRegisterUser(t *testing.T) {
 		assert.Equal(t, "User registered successfully", resp["message"])
 		mockService.AssertExpectations(t)
 	})
+	t.Run("Role escalation ignored", func(t *testing.T) {
+		mockService.ExpectedCalls = nil
+
+		body := bytes.NewBufferString(`{"name": "Yash", "email": "yash123@gmail.com", "username": "yash123", "password": "yash@123", "role": "admin"}`)
+		req := httptest.NewRequest("POST", "/users", body)
+		res := httptest.NewRecorder()
+
+		expected := models.User{Name: "Yash", Email: "yash123@gmail.com", Username: "yash123", Password: "yash@123", Role: models.RoleCustomer}
+		mockService.On("CreateUser", &expected).Return(nil)
+
+		handler.RegisterUser(res, req)
+
+		assert.Equal(t, http.StatusCreated, res.Code)
+		mockService.AssertExpectations(t)
+	})
 	t.Run("Invalid Request Body", func(t *testing.T) {
 		req := httptest.NewRequest("POST", "/users", bytes.NewBuffer([]byte("{invalid json")))
 		req.Header.Set("Content-Type", "application/json")
@@ -146,11 +193,11 @@ func TestRegisterUser(t *testing.T) {
 		mockService.ExpectedCalls = nil
 
 		user := models.User{
-			Id:       1,
 			Name:     "Abhay",
 			Email:    "abhay123@gmail.com",
 			Username: "abhay123",
 			Password: "abhay@123",
+			Role:     models.RoleCustomer,
 		}
 		body, _ := json.Marshal(user)
 		req := httptest.NewRequest("POST", "/users", bytes.NewBuffer(body))
@@ -169,7 +216,7 @@ func TestRegisterUser(t *testing.T) {
 }
 func TestGetUserByID(t *testing.T) {
 	mockService := new(MockUserService)
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, nil)
 
 	user := &models.User{
 		Id:       1,
@@ -192,9 +239,11 @@ func TestGetUserByID(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, res.Code)
 
-		var resp models.User
+		var resp dto.UserResponse
 		json.Unmarshal(res.Body.Bytes(), &resp)
-		assert.Equal(t, user, &resp)
+		assert.Equal(t, dto.UserResponse{ID: 1, Name: "Abhay", Email: "abhay123@gmail.com", Username: "abhay123"}, resp)
+		assert.NotContains(t, res.Body.String(), "abhay@123") // password never leaves the server
+		assert.NotContains(t, res.Body.String(), "password")
 		mockService.AssertExpectations(t)
 	})
 	t.Run("Fail", func(t *testing.T) {
@@ -225,7 +274,7 @@ func TestGetUserByID(t *testing.T) {
 
 func TestGetAllUsers(t *testing.T) {
 	mockService := new(MockUserService)
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, nil)
 
 	users := []models.User{
 		{
@@ -252,10 +301,12 @@ func TestGetAllUsers(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, rec.Code)
 
-		var resp []models.User
+		var resp []dto.UserResponse
 		json.Unmarshal(rec.Body.Bytes(), &resp)
 
-		assert.Equal(t, users, resp)
+		assert.Equal(t, dto.NewUserResponses(users), resp)
+		assert.Equal(t, "yash123", resp[1].Username)
+		assert.NotContains(t, rec.Body.String(), "yash@123")
 		mockService.AssertExpectations(t)
 	})
 	t.Run("Empty user", func(t *testing.T) {
@@ -269,7 +320,7 @@ func TestGetAllUsers(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, rec.Code)
 
-		var resp []models.User
+		var resp []dto.UserResponse
 		json.Unmarshal(rec.Body.Bytes(), &resp)
 
 		assert.Empty(t, resp) // Verify response is empty
@@ -290,7 +341,7 @@ func TestGetAllUsers(t *testing.T) {
 
 func TestUpdateUser(t *testing.T) {
 	mockService := new(MockUserService)
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, nil)
 
 	user := models.User{
 		Id:       1,
@@ -395,7 +446,7 @@ func TestUpdateUser(t *testing.T) {
 
 func TestDeleteUser(t *testing.T) {
 	mockService := new(MockUserService)
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, nil)
 
 	t.Run("Success", func(t *testing.T) {
 		mockService.On("DeleteUser", 1).Return(nil)
@@ -442,3 +493,134 @@ func TestDeleteUser(t *testing.T) {
 		assert.Contains(t, rec.Body.String(), "Failed to delete user")
 	})
 }
+
+func withPrincipal(req *http.Request, userID int) *http.Request {
+	return req.WithContext(utils.WithPrincipal(req.Context(), &utils.Principal{UserID: userID, Username: "yash123"}))
+}
+
+func TestGetMe(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService, nil)
+
+	user := &models.User{Id: 2, Name: "Yash", Email: "yash123@gmail.com", Username: "yash123", Password: "$argon2id$v=19$m=65536,t=1,p=4$c2FsdA$aGFzaA"}
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("GetUserByID", 2).Return(user, nil)
+
+		req := withPrincipal(httptest.NewRequest("GET", "/me", nil), 2)
+		rec := httptest.NewRecorder()
+
+		handler.GetMe(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "yash123@gmail.com")
+		assert.NotContains(t, rec.Body.String(), "argon2id")
+		mockService.AssertExpectations(t)
+	})
+	t.Run("Unauthenticated", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/me", nil)
+		rec := httptest.NewRecorder()
+
+		handler.GetMe(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestUpdateMe(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService, nil)
+
+	newUser := func() *models.User {
+		return &models.User{Id: 2, Name: "Yash", Email: "yash123@gmail.com", Username: "yash123", Password: "hash", Role: models.RoleCustomer}
+	}
+
+	t.Run("Name change without password", func(t *testing.T) {
+		user := newUser()
+		mockService.On("GetUserByID", 2).Return(user, nil).Once()
+		mockService.On("UpdateUser", user).Return(nil).Once()
+
+		body := bytes.NewBufferString(`{"name": "Yash P"}`)
+		rec := httptest.NewRecorder()
+		handler.UpdateMe(rec, withPrincipal(httptest.NewRequest("PATCH", "/me", body), 2))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Yash P", user.Name)
+		mockService.AssertExpectations(t)
+	})
+	t.Run("Email change needs current password", func(t *testing.T) {
+		user := newUser()
+		mockService.On("GetUserByID", 2).Return(user, nil).Once()
+
+		body := bytes.NewBufferString(`{"email": "new@gmail.com"}`)
+		rec := httptest.NewRecorder()
+		handler.UpdateMe(rec, withPrincipal(httptest.NewRequest("PATCH", "/me", body), 2))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "yash123@gmail.com", user.Email)
+	})
+	t.Run("Wrong current password", func(t *testing.T) {
+		user := newUser()
+		mockService.On("GetUserByID", 2).Return(user, nil).Once()
+		mockService.On("CheckPassword", user, "wrong").Return(errors.New("invalid password")).Once()
+
+		body := bytes.NewBufferString(`{"password": "new@123", "current_password": "wrong"}`)
+		rec := httptest.NewRecorder()
+		handler.UpdateMe(rec, withPrincipal(httptest.NewRequest("PATCH", "/me", body), 2))
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "hash", user.Password)
+	})
+	t.Run("Password change", func(t *testing.T) {
+		user := newUser()
+		mockService.On("GetUserByID", 2).Return(user, nil).Once()
+		mockService.On("CheckPassword", user, "yash@123").Return(nil).Once()
+		mockService.On("UpdateUser", user).Return(nil).Once()
+
+		body := bytes.NewBufferString(`{"password": "new@123", "current_password": "yash@123", "role": "admin"}`)
+		rec := httptest.NewRecorder()
+		handler.UpdateMe(rec, withPrincipal(httptest.NewRequest("PATCH", "/me", body), 2))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "new@123", user.Password)
+		assert.Equal(t, models.RoleCustomer, user.Role) // role can't be self-assigned
+	})
+	t.Run("Username taken", func(t *testing.T) {
+		user := newUser()
+		mockService.On("GetUserByID", 2).Return(user, nil).Once()
+		mockService.On("UpdateUser", user).Return(fmt.Errorf("%w: username \"abhay123\" is taken", services.ErrUserExists)).Once()
+
+		body := bytes.NewBufferString(`{"username": "abhay123"}`)
+		rec := httptest.NewRecorder()
+		handler.UpdateMe(rec, withPrincipal(httptest.NewRequest("PATCH", "/me", body), 2))
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+	t.Run("Invalid JSON", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.UpdateMe(rec, withPrincipal(httptest.NewRequest("PATCH", "/me", bytes.NewBufferString("{invalid")), 2))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestDeleteMe(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService, nil)
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("DeleteUser", 2).Return(nil).Once()
+
+		rec := httptest.NewRecorder()
+		handler.DeleteMe(rec, withPrincipal(httptest.NewRequest("DELETE", "/me", nil), 2))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		mockService.AssertExpectations(t)
+	})
+	t.Run("Unauthenticated", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.DeleteMe(rec, httptest.NewRequest("DELETE", "/me", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
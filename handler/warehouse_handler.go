@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"ecommerce/dto"
+	"ecommerce/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type WarehouseHandler struct {
+	warehouseService services.WarehouseService
+}
+
+func NewWarehouseHandler(warehouseService services.WarehouseService) *WarehouseHandler {
+	return &WarehouseHandler{warehouseService: warehouseService}
+}
+
+func (h *WarehouseHandler) GetWarehouses(w http.ResponseWriter, r *http.Request) {
+	warehouses, err := h.warehouseService.GetWarehouses(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to retrieve warehouses", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewWarehouseResponses(warehouses))
+}
+
+func (h *WarehouseHandler) CreateWarehouse(w http.ResponseWriter, r *http.Request) {
+	var request dto.WarehouseRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	warehouse := request.ToModel()
+	if err := h.warehouseService.CreateWarehouse(r.Context(), warehouse); err != nil {
+		writeWarehouseError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.NewWarehouseResponse(warehouse))
+}
+
+func (h *WarehouseHandler) UpdateWarehouse(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid warehouse ID", http.StatusBadRequest)
+		return
+	}
+	var request dto.WarehouseRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	warehouse := request.ToModel()
+	warehouse.ID = id
+	if err := h.warehouseService.UpdateWarehouse(r.Context(), warehouse); err != nil {
+		writeWarehouseError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewWarehouseResponse(warehouse))
+}
+
+func writeWarehouseError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrWarehouseNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidWarehouse):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
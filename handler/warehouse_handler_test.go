@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"ecommerce/models"
+	"ecommerce/services"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockWarehouseService struct {
+	mock.Mock
+}
+
+func (m *MockWarehouseService) CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	args := m.Called(warehouse)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseService) GetWarehouse(ctx context.Context, id int) (*models.Warehouse, error) {
+	args := m.Called(id)
+	if warehouse := args.Get(0); warehouse != nil {
+		return warehouse.(*models.Warehouse), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseService) GetWarehouses(ctx context.Context) ([]models.Warehouse, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Warehouse), args.Error(1)
+}
+
+func (m *MockWarehouseService) UpdateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	args := m.Called(warehouse)
+	return args.Error(0)
+}
+
+func TestCreateWarehouseHandler(t *testing.T) {
+	mockService := new(MockWarehouseService)
+	handler := NewWarehouseHandler(mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		expected := &models.Warehouse{Code: "lisbon", Name: "Lisbon", Location: &models.GeoPoint{Latitude: 38.7, Longitude: -9.1}, Active: true}
+		mockService.On("CreateWarehouse", expected).Return(nil).Once()
+
+		res := httptest.NewRecorder()
+		body := `{"code":"lisbon","name":"Lisbon","latitude":38.7,"longitude":-9.1}`
+		handler.CreateWarehouse(res, httptest.NewRequest("POST", "/warehouses", bytes.NewBufferString(body)))
+
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Contains(t, res.Body.String(), `"active":true`)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		mockService.On("CreateWarehouse", mock.Anything).Return(fmt.Errorf("%w: name is required", services.ErrInvalidWarehouse)).Once()
+
+		res := httptest.NewRecorder()
+		handler.CreateWarehouse(res, httptest.NewRequest("POST", "/warehouses", bytes.NewBufferString(`{"code":"x"}`)))
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+}
+
+func TestUpdateWarehouseHandler(t *testing.T) {
+	mockService := new(MockWarehouseService)
+	handler := NewWarehouseHandler(mockService)
+	mockService.On("UpdateWarehouse", &models.Warehouse{ID: 2, Code: "main", Name: "Main", Active: false}).Return(nil)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", "/warehouses/2", bytes.NewBufferString(`{"code":"main","name":"Main","active":false}`))
+	handler.UpdateWarehouse(res, withURLParam(req, "id", "2"))
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), `"active":false`)
+}
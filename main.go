@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
+	"ecommerce/config"
 	"ecommerce/db"
 	"ecommerce/handler"
 	"ecommerce/middleware"
+	"ecommerce/models"
 	"ecommerce/repository"
 	"ecommerce/services"
 	"ecommerce/utils"
 	"fmt"
+	"log"
+	"os"
+	"time"
 
 	"net/http"
 
@@ -15,39 +21,288 @@ import (
 )
 
 func main() {
-	db.ConnectDb()
-	database := db.GetDb()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatal("invalid configuration: ", err)
+	}
+
+	database, err := db.Connect(cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	productRepo := repository.NewProductRepo(database)
 	userRepo := repository.NewUserRepo(database)
-	productService := services.NewProductService(productRepo)
-	userService := services.NewUserService(userRepo)
-	productHandler := handler.NewProductHander(productService)
-	userHandler := handler.NewUserHandler(userService)
+	refreshTokenRepo := repository.NewRefreshTokenRepo(database)
+	revokedTokenRepo := repository.NewRevokedTokenRepo(database)
+	categoryRepo := repository.NewCategoryRepo(database)
+	variantRepo := repository.NewVariantRepo(database)
+	inventoryRepo := repository.NewInventoryRepo(database)
+	warehouseRepo := repository.NewWarehouseRepo(database)
+	cartRepo := repository.NewCartRepo(database)
+	orderRepo := repository.NewOrderRepo(database)
+	paymentRepo := repository.NewPaymentRepo(database)
+	returnRepo := repository.NewReturnRepo(database)
+	priceRepo := repository.NewPriceRepo(database)
+	exchangeRateRepo := repository.NewExchangeRateRepo(database)
+	promotionRepo := repository.NewPromotionRepo(database)
+	taxRepo := repository.NewTaxRepo(database)
+	shippingRepo := repository.NewShippingRepo(database)
+	addressRepo := repository.NewAddressRepo(database)
+	keys := loadKeyManager(cfg.JWT)
+	signer := utils.JWTSigner{Keys: keys, Issuer: cfg.JWT.Issuer, Audience: cfg.JWT.Audience, TTL: cfg.JWT.AccessTokenTTL.Std()}
+	txManager := repository.NewTxManager(database)
+	productService := services.NewProductService(productRepo, variantRepo, categoryRepo, txManager, cfg.Pricing.Currency)
+	tokenService := services.NewTokenService(userRepo, refreshTokenRepo, revokedTokenRepo, txManager, signer)
+	categoryService := services.NewCategoryService(categoryRepo, txManager)
+	allocation, err := services.NewAllocationStrategy(cfg.Inventory.Allocation)
+	if err != nil {
+		log.Fatal("invalid inventory configuration: ", err)
+	}
+	inventoryService := services.NewInventoryService(productRepo, variantRepo, inventoryRepo, txManager, allocation)
+	warehouseService := services.NewWarehouseService(warehouseRepo)
+	cartService := services.NewCartService(cartRepo, txManager, cfg.Pricing.Currency)
+	taxMode, err := models.ParseTaxMode(cfg.Tax.Mode)
+	if err != nil {
+		log.Fatal("invalid tax configuration: ", err)
+	}
+	taxRounding, err := models.ParseRoundingMode(cfg.Tax.Rounding)
+	if err != nil {
+		log.Fatal("invalid tax configuration: ", err)
+	}
+	taxCalculator := services.NewLocalTaxCalculator(taxRepo, taxMode, cfg.Tax.DefaultCountry, taxRounding)
+	gateway, err := services.NewPaymentGateway(cfg.Payments.Gateway)
+	if err != nil {
+		log.Fatal("invalid payments configuration: ", err)
+	}
+	orderService := services.NewOrderService(orderRepo, productService, taxCalculator, gateway, txManager, allocation)
+	if cfg.Payments.WebhookSecret == "" {
+		log.Println("payments.webhook_secret is not set, payment webhooks will be rejected")
+	}
+	paymentService := services.NewPaymentService(paymentRepo, orderRepo, gateway, txManager)
+	returnService := services.NewReturnService(returnRepo, paymentRepo, gateway, txManager)
+	rounding, err := models.ParseRoundingMode(cfg.Pricing.Rounding)
+	if err != nil {
+		log.Fatal("invalid pricing configuration: ", err)
+	}
+	pricingService := services.NewPricingService(priceRepo, exchangeRateRepo, productRepo, cfg.Pricing.Currency, cfg.Pricing.Currencies, rounding)
+	promotionService := services.NewPromotionService(promotionRepo, cartService, productService, txManager, cfg.Pricing.Currency)
+	taxService := services.NewTaxService(taxRepo, promotionService, taxCalculator, txManager)
+	shippingProviders := make([]services.ShippingRateProvider, 0, len(cfg.Shipping.Providers))
+	for _, name := range cfg.Shipping.Providers {
+		provider, err := services.NewShippingRateProvider(name, shippingRepo)
+		if err != nil {
+			log.Fatal("invalid shipping configuration: ", err)
+		}
+		shippingProviders = append(shippingProviders, provider)
+	}
+	shippingService := services.NewShippingService(shippingRepo, promotionService, shippingProviders, txManager)
+	addressService := services.NewAddressService(addressRepo, txManager)
+	userService := services.NewUserService(userRepo, utils.NewPasswordHasher(), tokenService)
+	productHandler := handler.NewProductHander(productService, pricingService)
+	userHandler := handler.NewUserHandler(userService, cartService)
+	authHandler := handler.NewAuthHandler(tokenService, keys)
+	categoryHandler := handler.NewCategoryHandler(categoryService)
+	inventoryHandler := handler.NewInventoryHandler(inventoryService)
+	warehouseHandler := handler.NewWarehouseHandler(warehouseService)
+	cartHandler := handler.NewCartHandler(cartService)
+	orderHandler := handler.NewOrderHandler(orderService)
+	paymentHandler := handler.NewPaymentHandler(paymentService, cfg.Payments.WebhookSecret)
+	returnHandler := handler.NewReturnHandler(returnService)
+	pricingHandler := handler.NewPricingHandler(pricingService)
+	promotionHandler := handler.NewPromotionHandler(promotionService)
+	taxHandler := handler.NewTaxHandler(taxService)
+	shippingHandler := handler.NewShippingHandler(shippingService)
+	addressHandler := handler.NewAddressHandler(addressService)
 
 	r := chi.NewRouter()
-	verifier := utils.JWTVerifier{}
+	verifier := utils.JWTVerifier{Keys: keys, Issuer: cfg.JWT.Issuer, Audience: cfg.JWT.Audience, Revocations: revokedTokenRepo}
 
+	// revocation entries are useless once the token they name has expired,
+	// guest carts once nobody touched them for a while
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := revokedTokenRepo.DeleteExpired(context.Background()); err != nil {
+				log.Println("failed to purge revoked tokens:", err)
+			}
+			if err := cartRepo.DeleteExpired(context.Background()); err != nil {
+				log.Println("failed to purge abandoned guest carts:", err)
+			}
+		}
+	}()
+
+	r.Get("/.well-known/jwks.json", authHandler.JWKS)
 	r.Post("/login", userHandler.LoginHandler)
+	r.Post("/token/refresh", authHandler.RefreshToken)
 
 	r.Group(func(r chi.Router) {
 		r.Use(func(next http.Handler) http.Handler {
 			return middleware.Auth(verifier, next)
 		})
 
-		r.Post("/products", productHandler.CreateProduct)
-		r.Get("/products/{id}", productHandler.GetProductByID)
-		r.Get("/products", productHandler.GetAllProducts)
-		r.Put("/products/{id}", productHandler.UpdateProduct)
-		r.Delete("/products/{id}", productHandler.DeleteProducts)
+		// permissions are declared per route, see models.rolePermissions for the role mapping
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Post("/products", productHandler.CreateProduct)
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/products/{id}", productHandler.GetProductByID)
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/products", productHandler.GetAllProducts)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Put("/products/{id}", productHandler.UpdateProduct)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Delete("/products/{id}", productHandler.DeleteProducts)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Post("/products/{id}/variants", productHandler.CreateVariant)
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/products/{id}/prices", pricingHandler.GetProductPrices)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Put("/products/{id}/prices", pricingHandler.SetProductPrice)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Delete("/products/{id}/prices/{currency}", pricingHandler.DeleteProductPrice)
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/variants/{sku}", productHandler.GetVariant)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Put("/variants/{sku}", productHandler.UpdateVariant)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Delete("/variants/{sku}", productHandler.DeleteVariant)
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/option-types", productHandler.GetOptionTypes)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Post("/option-types", productHandler.CreateOptionType)
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/exchange-rates", pricingHandler.GetExchangeRates)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Post("/exchange-rates", pricingHandler.CreateExchangeRate)
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/products/{id}/categories", categoryHandler.GetProductCategories)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Put("/products/{id}/categories", categoryHandler.SetProductCategories)
+
+		// categories are part of the catalogue and share the product permissions
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/categories", categoryHandler.GetCategories)
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/categories/{id}", categoryHandler.GetCategoryByID)
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/categories/{id}/products", categoryHandler.GetCategoryProducts)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Post("/categories", categoryHandler.CreateCategory)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Put("/categories/{id}", categoryHandler.UpdateCategory)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Delete("/categories/{id}", categoryHandler.DeleteCategory)
+
+		r.With(middleware.RequirePermission(models.PermInventoryRead)).Get("/products/{id}/inventory", inventoryHandler.GetProductInventory)
+		r.With(middleware.RequirePermission(models.PermInventoryRead)).Get("/products/{id}/inventory/adjustments", inventoryHandler.GetAdjustments)
+		r.With(middleware.RequirePermission(models.PermInventoryWrite)).Post("/products/{id}/inventory/adjustments", inventoryHandler.AdjustStock)
+		r.With(middleware.RequirePermission(models.PermInventoryWrite)).Post("/inventory/transfers", inventoryHandler.TransferStock)
+		r.With(middleware.RequirePermission(models.PermInventoryRead)).Get("/warehouses", warehouseHandler.GetWarehouses)
+		r.With(middleware.RequirePermission(models.PermInventoryWrite)).Post("/warehouses", warehouseHandler.CreateWarehouse)
+		r.With(middleware.RequirePermission(models.PermInventoryWrite)).Put("/warehouses/{id}", warehouseHandler.UpdateWarehouse)
+
+		r.With(middleware.RequirePermission(models.PermOrderRead)).Get("/orders", orderHandler.GetOrders)
+		r.With(middleware.RequirePermission(models.PermOrderRead)).Get("/orders/{id}", orderHandler.GetOrder)
+		r.With(middleware.RequirePermission(models.PermOrderRead)).Get("/orders/{id}/history", orderHandler.GetOrderHistory)
+		r.With(middleware.RequirePermission(models.PermOrderWrite)).Post("/orders/{id}/transitions", orderHandler.TransitionOrder)
+		r.With(middleware.RequirePermission(models.PermOrderRead)).Get("/orders/{id}/payments", paymentHandler.GetOrderPayments)
+		r.With(middleware.RequirePermission(models.PermOrderWrite)).Post("/payments/{id}/void", paymentHandler.VoidPayment)
+		r.With(middleware.RequirePermission(models.PermOrderWrite)).Post("/payments/{id}/refunds", paymentHandler.RefundPayment)
+
+		// returns are handled by the same staff as orders
+		r.With(middleware.RequirePermission(models.PermOrderRead)).Get("/returns", returnHandler.GetReturns)
+		r.With(middleware.RequirePermission(models.PermOrderRead)).Get("/returns/{id}", returnHandler.GetReturn)
+		r.With(middleware.RequirePermission(models.PermOrderWrite)).Post("/returns/{id}/approve", returnHandler.ApproveReturn)
+		r.With(middleware.RequirePermission(models.PermOrderWrite)).Post("/returns/{id}/reject", returnHandler.RejectReturn)
+		r.With(middleware.RequirePermission(models.PermOrderWrite)).Post("/returns/{id}/receive", returnHandler.ReceiveReturn)
+		r.With(middleware.RequirePermission(models.PermOrderWrite)).Post("/returns/{id}/refund", returnHandler.RefundReturn)
+
+		r.With(middleware.RequirePermission(models.PermPromotionRead)).Get("/promotions", promotionHandler.GetPromotions)
+		r.With(middleware.RequirePermission(models.PermPromotionRead)).Get("/promotions/{id}", promotionHandler.GetPromotion)
+		r.With(middleware.RequirePermission(models.PermPromotionWrite)).Post("/promotions", promotionHandler.CreatePromotion)
+		r.With(middleware.RequirePermission(models.PermPromotionWrite)).Put("/promotions/{id}", promotionHandler.UpdatePromotion)
+		r.With(middleware.RequirePermission(models.PermPromotionWrite)).Delete("/promotions/{id}", promotionHandler.DeletePromotion)
+
+		// tax classes and rates are catalogue settings and share the product permissions
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/tax-classes", taxHandler.GetTaxClasses)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Post("/tax-classes", taxHandler.CreateTaxClass)
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/tax-rates", taxHandler.GetTaxRates)
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/tax-rates/{id}", taxHandler.GetTaxRate)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Post("/tax-rates", taxHandler.CreateTaxRate)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Put("/tax-rates/{id}", taxHandler.UpdateTaxRate)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Delete("/tax-rates/{id}", taxHandler.DeleteTaxRate)
+
+		// so are the shipping zones and rate tables
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/shipping-zones", shippingHandler.GetShippingZones)
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/shipping-zones/{id}", shippingHandler.GetShippingZone)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Post("/shipping-zones", shippingHandler.CreateShippingZone)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Put("/shipping-zones/{id}", shippingHandler.UpdateShippingZone)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Delete("/shipping-zones/{id}", shippingHandler.DeleteShippingZone)
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/shipping-methods", shippingHandler.GetShippingMethods)
+		r.With(middleware.RequirePermission(models.PermProductRead)).Get("/shipping-methods/{id}", shippingHandler.GetShippingMethod)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Post("/shipping-methods", shippingHandler.CreateShippingMethod)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Put("/shipping-methods/{id}", shippingHandler.UpdateShippingMethod)
+		r.With(middleware.RequirePermission(models.PermProductWrite)).Delete("/shipping-methods/{id}", shippingHandler.DeleteShippingMethod)
 	})
 
 	r.Post("/users", userHandler.RegisterUser)
-	r.Get("/users/{id}", userHandler.GetUserByID)
-	r.Get("/users", userHandler.GetAllUsers)
-	r.Put("/users/{id}", userHandler.UpdateUser)
-	r.Delete("/users/{id}", userHandler.DeleteUser)
 
-	fmt.Println("Server started on : 8080")
-	http.ListenAndServe(":8080", r)
+	// the gateway authenticates with the webhook signature instead of a token
+	r.Post("/webhooks/payments", paymentHandler.Webhook)
+
+	// carts work for guests too, they are identified by the cart token header until they log in
+	r.Group(func(r chi.Router) {
+		r.Use(func(next http.Handler) http.Handler {
+			return middleware.OptionalAuth(verifier, next)
+		})
+
+		r.Get("/cart", cartHandler.GetCart)
+		r.Delete("/cart", cartHandler.ClearCart)
+		r.Post("/cart/items", cartHandler.AddItem)
+		r.Patch("/cart/items/{id}", cartHandler.UpdateItem)
+		r.Delete("/cart/items/{id}", cartHandler.RemoveItem)
+		r.Get("/cart/discounts", promotionHandler.GetCartDiscounts)
+		r.Get("/cart/taxes", taxHandler.GetCartTaxes)
+		r.Get("/cart/shipping-options", shippingHandler.GetCartShippingOptions)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(func(next http.Handler) http.Handler {
+			return middleware.Auth(verifier, next)
+		})
+
+		r.Post("/logout", authHandler.Logout)
+
+		r.Get("/me", userHandler.GetMe)
+		r.Patch("/me", userHandler.UpdateMe)
+		r.Delete("/me", userHandler.DeleteMe)
+
+		r.Get("/me/addresses", addressHandler.GetMyAddresses)
+		r.Post("/me/addresses", addressHandler.CreateMyAddress)
+		r.Get("/me/addresses/{id}", addressHandler.GetMyAddress)
+		r.Put("/me/addresses/{id}", addressHandler.UpdateMyAddress)
+		r.Delete("/me/addresses/{id}", addressHandler.DeleteMyAddress)
+
+		r.Post("/checkout", orderHandler.Checkout)
+		r.Get("/me/orders", orderHandler.GetMyOrders)
+		r.Get("/me/orders/{id}", orderHandler.GetMyOrder)
+		r.Post("/me/orders/{id}/cancel", orderHandler.CancelMyOrder)
+		r.Post("/me/orders/{id}/payments", paymentHandler.PayOrder)
+		r.Post("/me/orders/{id}/returns", returnHandler.RequestReturn)
+		r.Get("/me/returns", returnHandler.GetMyReturns)
+		r.Get("/me/returns/{id}", returnHandler.GetMyReturn)
+
+		// account management is restricted to admins
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireRole(models.RoleAdmin))
+
+			r.Get("/users/{id}", userHandler.GetUserByID)
+			r.Get("/users", userHandler.GetAllUsers)
+			r.Put("/users/{id}", userHandler.UpdateUser)
+			r.Delete("/users/{id}", userHandler.DeleteUser)
+		})
+	})
+
+	server := &http.Server{
+		Addr:         cfg.Server.Addr,
+		Handler:      r,
+		ReadTimeout:  cfg.Server.ReadTimeout.Std(),
+		WriteTimeout: cfg.Server.WriteTimeout.Std(),
+		IdleTimeout:  cfg.Server.IdleTimeout.Std(),
+	}
+	fmt.Println("Server started on", cfg.Server.Addr)
+	log.Fatal(server.ListenAndServe())
+}
+
+// loadKeyManager builds the JWT keys from the configured source
+func loadKeyManager(cfg config.JWTConfig) *utils.KeyManager {
+	configs, err := cfg.KeyConfigs()
+	if err != nil {
+		log.Fatal(err)
+	}
+	keys, err := utils.NewKeyManager(configs)
+	if err != nil {
+		log.Fatal("invalid JWT key configuration: ", err)
+	}
+	return keys
 }
@@ -1,13 +1,14 @@
 package middleware
 
 import (
-	//"ecommerce/utils"
+	"context"
+	"ecommerce/utils"
 	"net/http"
 	"strings"
 )
 
 type TokenVerifier interface {
-	VerifyToken(tokenString string) (string, error)
+	VerifyToken(ctx context.Context, tokenString string) (*utils.Claims, error)
 }
 
 func Auth(verifier TokenVerifier, next http.Handler) http.Handler {
@@ -24,12 +25,26 @@ func Auth(verifier TokenVerifier, next http.Handler) http.Handler {
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ") // Removes "Bearer " from the header
 
-		_, err := verifier.VerifyToken(tokenString)
+		claims, err := verifier.VerifyToken(r.Context(), tokenString)
 		if err != nil {
 			http.Error(w, "invalid token", http.StatusUnauthorized)
 			return
 		}
 
-		next.ServeHTTP(w, r) // passes the request to next, allowing the protected route to execute
+		ctx := utils.WithPrincipal(r.Context(), utils.NewPrincipal(claims)) // expose the caller to handlers and services
+		next.ServeHTTP(w, r.WithContext(ctx))                               // passes the request to next, allowing the protected route to execute
+	})
+}
+
+// OptionalAuth lets anonymous requests through without a principal, e.g. for
+// guest carts. A token that is sent must still be valid.
+func OptionalAuth(verifier TokenVerifier, next http.Handler) http.Handler {
+	protected := Auth(verifier, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		protected.ServeHTTP(w, r)
 	})
 }
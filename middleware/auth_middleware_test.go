@@ -1,7 +1,10 @@
 package middleware_test
 
 import (
+	"context"
 	"ecommerce/middleware"
+	"ecommerce/models"
+	"ecommerce/utils"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -14,11 +17,13 @@ type MockVerifier struct {
 	Err        error
 }
 
-func (m MockVerifier) VerifyToken(tokenString string) (string, error) {
+func (m MockVerifier) VerifyToken(ctx context.Context, tokenString string) (*utils.Claims, error) {
 	if tokenString == m.ValidToken {
-		return "testuser", nil
+		claims := &utils.Claims{UserID: 7, Username: "testuser", Roles: []models.Role{models.RoleCustomer}}
+		claims.ID = "token-id"
+		return claims, nil
 	}
-	return "", m.Err
+	return nil, m.Err
 }
 
 func TestAuthMiddleware(t *testing.T) {
@@ -60,7 +65,9 @@ func TestAuthMiddleware(t *testing.T) {
 		req.Header.Set("Authorization", "Bearer valid-token")
 		w := httptest.NewRecorder()
 
+		var principal *utils.Principal
 		handler := middleware.Auth(mockVerifier, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ = utils.PrincipalFromContext(r.Context())
 			w.WriteHeader(http.StatusOK)
 		}))
 		handler.ServeHTTP(w, req)
@@ -68,5 +75,55 @@ func TestAuthMiddleware(t *testing.T) {
 		if w.Code != http.StatusOK {
 			t.Errorf("expected %d, got %d", http.StatusOK, w.Code)
 		}
+		if principal == nil || principal.UserID != 7 || principal.Username != "testuser" || principal.TokenID != "token-id" {
+			t.Errorf("expected principal for testuser in request context, got %+v", principal)
+		}
+	})
+}
+
+func TestOptionalAuthMiddleware(t *testing.T) {
+	mockVerifier := MockVerifier{
+		ValidToken: "valid-token",
+		Err:        errors.New("invalid token"),
+	}
+
+	var principal *utils.Principal
+	handler := middleware.OptionalAuth(mockVerifier, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = utils.PrincipalFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	t.Run("Anonymous", func(t *testing.T) {
+		principal = nil
+		req := httptest.NewRequest(http.MethodGet, "/cart", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || principal != nil {
+			t.Errorf("expected anonymous request to pass without principal, got %d %+v", w.Code, principal)
+		}
+	})
+
+	t.Run("Invalid Token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/cart", nil)
+		req.Header.Set("Authorization", "Bearer invalid-token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+
+	t.Run("Valid Token", func(t *testing.T) {
+		principal = nil
+		req := httptest.NewRequest(http.MethodGet, "/cart", nil)
+		req.Header.Set("Authorization", "Bearer valid-token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || principal == nil || principal.UserID != 7 {
+			t.Errorf("expected principal for testuser, got %d %+v", w.Code, principal)
+		}
 	})
 }
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"ecommerce/models"
+	"ecommerce/utils"
+	"net/http"
+)
+
+// RequireRole lets the request through when the caller holds any of the given roles.
+// It must be mounted after Auth.
+func RequireRole(roles ...models.Role) func(http.Handler) http.Handler {
+	return authorize(func(held models.Role) bool {
+		for _, role := range roles {
+			if held == role {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// RequirePermission lets the request through when one of the caller's roles grants every given permission.
+// It must be mounted after Auth.
+func RequirePermission(permissions ...models.Permission) func(http.Handler) http.Handler {
+	return authorize(func(held models.Role) bool {
+		for _, permission := range permissions {
+			if !held.Can(permission) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func authorize(allowed func(role models.Role) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := utils.PrincipalFromContext(r.Context())
+			if !ok {
+				http.Error(w, "Unauthorized - Missing Token", http.StatusUnauthorized)
+				return
+			}
+
+			for _, role := range principal.Roles {
+				if allowed(role) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package middleware_test
+
+import (
+	"context"
+	"ecommerce/middleware"
+	"ecommerce/models"
+	"ecommerce/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type roleVerifier map[string]models.Role // token -> role
+
+func (v roleVerifier) VerifyToken(ctx context.Context, tokenString string) (*utils.Claims, error) {
+	return &utils.Claims{Username: tokenString, Roles: []models.Role{v[tokenString]}}, nil
+}
+
+func TestRequirePermission(t *testing.T) {
+	verifier := roleVerifier{"admin-token": models.RoleAdmin, "customer-token": models.RoleCustomer}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		token      string
+		permission models.Permission
+		expected   int
+	}{
+		{"Admin can write", "admin-token", models.PermProductWrite, http.StatusOK},
+		{"Customer can read", "customer-token", models.PermProductRead, http.StatusOK},
+		{"Customer cannot write", "customer-token", models.PermProductWrite, http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/products/1", nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			w := httptest.NewRecorder()
+
+			handler := middleware.Auth(verifier, middleware.RequirePermission(tc.permission)(ok))
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tc.expected {
+				t.Errorf("expected %d, got %d", tc.expected, w.Code)
+			}
+		})
+	}
+
+	t.Run("Without Auth", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		w := httptest.NewRecorder()
+
+		middleware.RequirePermission(models.PermProductRead)(ok).ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+}
+
+func TestRequireRole(t *testing.T) {
+	verifier := roleVerifier{"staff-token": models.RoleStaff, "customer-token": models.RoleCustomer}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := middleware.Auth(verifier, middleware.RequireRole(models.RoleStaff, models.RoleAdmin)(ok))
+
+	t.Run("Allowed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set("Authorization", "Bearer staff-token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+	t.Run("Forbidden", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set("Authorization", "Bearer customer-token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expected %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+}
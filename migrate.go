@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"ecommerce/config"
+	"ecommerce/db"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+const migrateUsage = `usage: ecommerce migrate <command> [flags]
+
+commands:
+  up              apply all pending migrations
+  down [steps]    roll back the last applied migration(s), default 1
+  status          list migrations and whether they are applied
+  create <name>   write an empty migration pair to ` + db.MigrationsDir
+
+// runMigrate implements `ecommerce migrate`, args excludes the "migrate" word
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+	command, args := args[0], args[1:]
+
+	if command == "create" {
+		if len(args) != 1 {
+			log.Fatal("usage: ecommerce migrate create <name>")
+		}
+		up, down, err := db.CreateMigration(db.MigrationsDir, args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("created", up)
+		fmt.Println("created", down)
+		return
+	}
+
+	steps := 1
+	if command == "down" && len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			if n < 1 {
+				log.Fatal("steps must be at least 1")
+			}
+			steps, args = n, args[1:]
+		}
+	}
+
+	// migrations only touch the database, the rest of the configuration may not be there yet
+	cfg, err := config.Read(args, os.Getenv)
+	if err == nil {
+		err = cfg.Database.Validate()
+	}
+	if err != nil {
+		log.Fatal("invalid configuration: ", err)
+	}
+	database, err := db.Connect(cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer database.Close()
+
+	migrations, err := db.LoadMigrations(db.Migrations, "migrations")
+	if err != nil {
+		log.Fatal(err)
+	}
+	migrator := db.NewMigrator(database, migrations)
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied  %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			switch {
+			case s.Missing:
+				state = "applied, missing from binary"
+			case s.Modified:
+				state = "applied, MODIFIED since"
+			case s.AppliedAt != nil:
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
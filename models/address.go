@@ -0,0 +1,132 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// AddressKind is what an order uses an address for
+type AddressKind string
+
+const (
+	AddressShipping AddressKind = "shipping"
+	AddressBilling  AddressKind = "billing"
+)
+
+// PostalAddress is a place parcels and invoices are sent to
+type PostalAddress struct {
+	Name     string // recipient
+	Company  string
+	Line1    string
+	Line2    string
+	City     string
+	Region   string // state or province
+	Postcode string
+	Country  string // ISO 3166-1 alpha-2
+	Phone    string
+}
+
+// Normalize trims every field, upper cases the country, region and
+// postcode and collapses the spaces inside the postcode
+func (a PostalAddress) Normalize() PostalAddress {
+	return PostalAddress{
+		Name:     strings.TrimSpace(a.Name),
+		Company:  strings.TrimSpace(a.Company),
+		Line1:    strings.TrimSpace(a.Line1),
+		Line2:    strings.TrimSpace(a.Line2),
+		City:     strings.TrimSpace(a.City),
+		Region:   strings.ToUpper(strings.TrimSpace(a.Region)),
+		Postcode: strings.Join(strings.Fields(strings.ToUpper(a.Postcode)), " "),
+		Country:  strings.ToUpper(strings.TrimSpace(a.Country)),
+		Phone:    strings.TrimSpace(a.Phone),
+	}
+}
+
+// Destination is where the address is for taxes and shipping
+func (a PostalAddress) Destination() Destination {
+	return Destination{Country: a.Country, Region: a.Region, Postcode: a.Postcode}.Normalize()
+}
+
+// Address is an entry of a user's address book. A user has at most one
+// default shipping and one default billing address, which may be the same.
+type Address struct {
+	ID     int
+	UserID int
+	PostalAddress
+	DefaultShipping bool
+	DefaultBilling  bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// OrderAddress is a copy of the address an order was placed with,
+// AddressID is nil once the saved address is deleted
+type OrderAddress struct {
+	OrderID   int
+	Kind      AddressKind
+	AddressID *int
+	PostalAddress
+}
+
+// postcodeFormat is what a country's postcodes look like after Normalize,
+// Example goes in error messages
+type postcodeFormat struct {
+	Pattern *regexp.Regexp
+	Example string
+}
+
+// postcodeFormats covers the countries the store commonly ships to. A nil
+// pattern marks a country without postcodes; countries not listed take any
+// postcode, or none.
+var postcodeFormats = map[string]postcodeFormat{
+	"US": {regexp.MustCompile(`^\d{5}(-\d{4})?$`), "12345 or 12345-6789"},
+	"CA": {regexp.MustCompile(`^[ABCEGHJ-NPRSTVXY]\d[A-Z] ?\d[A-Z]\d$`), "K1A 0B1"},
+	"GB": {regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`), "SW1A 1AA"},
+	"IE": {regexp.MustCompile(`^[A-Z\d]{3} ?[A-Z\d]{4}$`), "D02 X285"},
+	"DE": {regexp.MustCompile(`^\d{5}$`), "10115"},
+	"FR": {regexp.MustCompile(`^\d{5}$`), "75008"},
+	"IT": {regexp.MustCompile(`^\d{5}$`), "00184"},
+	"ES": {regexp.MustCompile(`^\d{5}$`), "28013"},
+	"NL": {regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`), "1012 JS"},
+	"BE": {regexp.MustCompile(`^\d{4}$`), "1000"},
+	"AT": {regexp.MustCompile(`^\d{4}$`), "1010"},
+	"CH": {regexp.MustCompile(`^\d{4}$`), "8001"},
+	"DK": {regexp.MustCompile(`^\d{4}$`), "1050"},
+	"NO": {regexp.MustCompile(`^\d{4}$`), "0150"},
+	"SE": {regexp.MustCompile(`^\d{3} ?\d{2}$`), "111 52"},
+	"PL": {regexp.MustCompile(`^\d{2}-\d{3}$`), "00-950"},
+	"PT": {regexp.MustCompile(`^\d{4}-\d{3}$`), "1100-148"},
+	"AU": {regexp.MustCompile(`^\d{4}$`), "2000"},
+	"NZ": {regexp.MustCompile(`^\d{4}$`), "6011"},
+	"JP": {regexp.MustCompile(`^\d{3}-?\d{4}$`), "100-0001"},
+	"IN": {regexp.MustCompile(`^\d{6}$`), "110001"},
+	"BR": {regexp.MustCompile(`^\d{5}-?\d{3}$`), "01310-100"},
+	"AE": {},
+	"HK": {},
+}
+
+// ValidatePostcode checks the (normalized) postcode against the country's
+// format: required where the country has postcodes we know the format of,
+// refused where it has none
+func ValidatePostcode(country, postcode string) error {
+	format, known := postcodeFormats[country]
+	switch {
+	case !known:
+		if len(postcode) > 16 {
+			return fmt.Errorf("postcode is too long")
+		}
+		return nil
+	case format.Pattern == nil:
+		if postcode != "" {
+			return fmt.Errorf("%s doesn't use postcodes", country)
+		}
+		return nil
+	case postcode == "":
+		return fmt.Errorf("postcode is required in %s", country)
+	case !format.Pattern.MatchString(postcode):
+		return fmt.Errorf("%q is not a postcode of %s, expected e.g. %s", postcode, country, format.Example)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePostcode(t *testing.T) {
+	tests := []struct {
+		country  string
+		postcode string
+		valid    bool
+	}{
+		{"US", "10118", true},
+		{"US", "10118-0110", true},
+		{"US", "1011", false},
+		{"CA", "K1A 0B1", true},
+		{"CA", "K1A0B1", true},
+		{"CA", "D1A 0B1", false},
+		{"GB", "SW1A 1AA", true},
+		{"GB", "M1 1AE", true},
+		{"GB", "12345", false},
+		{"NL", "1012 JS", true},
+		{"DE", "", false},
+		{"HK", "", true},
+		{"HK", "999077", false},
+		{"ZW", "", true},
+		{"ZW", "ANYTHING", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.country+" "+tc.postcode, func(t *testing.T) {
+			assert.Equal(t, tc.valid, ValidatePostcode(tc.country, tc.postcode) == nil)
+		})
+	}
+}
+
+func TestPostalAddressNormalize(t *testing.T) {
+	address := PostalAddress{Name: " Ada ", Line1: "12 St James's Square ", City: "London", Postcode: " sw1y   4jh", Country: "gb"}.Normalize()
+	assert.Equal(t, "Ada", address.Name)
+	assert.Equal(t, "SW1Y 4JH", address.Postcode)
+	assert.Equal(t, "GB", address.Country)
+	assert.Equal(t, Destination{Country: "GB", Postcode: "SW1Y4JH"}, address.Destination())
+}
+
+func TestDestinationLocation(t *testing.T) {
+	assert.Equal(t, regionCentroids["US-CA"], *Destination{Country: "us", Region: "ca"}.Location())
+	assert.Equal(t, countryCentroids["US"], *Destination{Country: "US", Region: "Somewhere"}.Location(), "unknown regions fall back to the country")
+	assert.Equal(t, countryCentroids["DE"], *Destination{Country: "DE", Postcode: "10115"}.Location())
+	assert.Nil(t, Destination{Country: "AQ"}.Location())
+}
@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+// Cart holds what a shopper intends to buy. It belongs to a user, or while
+// UserID is 0 to whoever holds the guest token.
+type Cart struct {
+	ID        int
+	UserID    int
+	Currency  string     // what the lines are priced in
+	TokenHash string     // sha256 of the guest token
+	Token     string     // raw guest token, only known right after the cart is created
+	ExpiresAt *time.Time // guest carts are dropped once abandoned
+	Items     []CartItem
+}
+
+// CartItem is a line of a cart, priced at the current product or variant price
+type CartItem struct {
+	ID         int
+	CartID     int
+	ProductID  int
+	VariantID  *int
+	Name       string
+	SKU        string
+	TaxClass   string // of the product
+	Weight     int    // of one unit in grams, from the product
+	Dimensions Dimensions
+	Quantity   int
+	UnitPrice  Money // current price
+	SavedPrice Money // price when the line was last changed
+}
+
+// CartOwner is whose cart a request is about, the signed in user or the holder of a guest token
+type CartOwner struct {
+	UserID     int
+	GuestToken string
+}
+
+func (o CartOwner) Guest() bool {
+	return o.UserID == 0
+}
+
+// LineTotal is the unit price times the quantity
+func (i CartItem) LineTotal() (Money, error) {
+	return i.UnitPrice.Mul(int64(i.Quantity))
+}
+
+// PriceChanged reports whether the price moved since the shopper last touched the line
+func (i CartItem) PriceChanged() bool {
+	return i.UnitPrice != i.SavedPrice
+}
+
+// Matches reports whether the line is for the given product or variant
+func (i CartItem) Matches(productID int, variantID *int) bool {
+	if i.ProductID != productID || (i.VariantID == nil) != (variantID == nil) {
+		return false
+	}
+	return variantID == nil || *i.VariantID == *variantID
+}
+
+// Subtotal adds up the lines, which are all in the cart's currency
+func (c *Cart) Subtotal() (Money, error) {
+	total := Money{Currency: c.Currency}
+	for _, item := range c.Items {
+		lineTotal, err := item.LineTotal()
+		if err != nil {
+			return Money{}, err
+		}
+		if total, err = total.Add(lineTotal); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+func (c *Cart) ItemCount() int {
+	count := 0
+	for _, item := range c.Items {
+		count += item.Quantity
+	}
+	return count
+}
@@ -0,0 +1,10 @@
+package models
+
+// Category is a node of the catalogue tree, ParentID is nil for top level categories
+type Category struct {
+	ID       int
+	Name     string
+	Slug     string // unique, used in storefront URLs
+	ParentID *int
+	Children []Category // only filled when the tree is built
+}
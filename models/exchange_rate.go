@@ -0,0 +1,116 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateDecimals is how many decimals an exchange rate keeps
+const RateDecimals = 8
+
+const rateScale = 100_000_000 // 10^RateDecimals
+
+// Rate is an exchange rate in fixed point, Rate(108450000) is 1.0845
+type Rate int64
+
+// ParseRate reads a positive decimal rate such as "1.0845"
+func ParseRate(text string) (Rate, error) {
+	whole, fraction, hasPoint := strings.Cut(strings.TrimSpace(text), ".")
+	if whole == "" || (hasPoint && fraction == "") || len(fraction) > RateDecimals {
+		return 0, fmt.Errorf("invalid exchange rate %q", text)
+	}
+	digits := whole + fraction + strings.Repeat("0", RateDecimals-len(fraction))
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid exchange rate %q", text)
+		}
+	}
+	value, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || value == 0 {
+		return 0, fmt.Errorf("invalid exchange rate %q", text)
+	}
+	return Rate(value), nil
+}
+
+// String formats the rate without trailing zeros, e.g. "1.0845"
+func (r Rate) String() string {
+	text := fmt.Sprintf("%d.%0*d", r/rateScale, RateDecimals, r%rateScale)
+	return strings.TrimSuffix(strings.TrimRight(text, "0"), ".")
+}
+
+// MarshalJSON writes the rate as a decimal string, like money amounts
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON reads a decimal string or a JSON number
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	parsed, err := ParseRate(text)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// ExchangeRate says one unit of Base buys Rate units of Quote from EffectiveAt
+// on, until a rate for the same pair with a later EffectiveAt takes over
+type ExchangeRate struct {
+	ID          int
+	Base        string
+	Quote       string
+	Rate        Rate
+	EffectiveAt time.Time
+	CreatedBy   int
+	CreatedAt   time.Time
+}
+
+// ExchangeRateFilter narrows the rate history, empty fields match everything
+type ExchangeRateFilter struct {
+	Base  string
+	Quote string
+}
+
+// Convert turns m into the other currency of the pair, m can be in either
+// Base or Quote. The result is rounded to a whole minor unit with mode.
+func (e *ExchangeRate) Convert(m Money, mode RoundingMode) (Money, error) {
+	baseUnits := pow10(CurrencyExponent(e.Base))
+	quoteUnits := pow10(CurrencyExponent(e.Quote))
+	amount := big.NewInt(m.Amount)
+	rate := big.NewInt(int64(e.Rate))
+
+	var n, d *big.Int
+	var currency string
+	switch m.Currency {
+	case e.Base:
+		n = amount.Mul(amount, rate).Mul(amount, quoteUnits)
+		d = new(big.Int).Mul(big.NewInt(rateScale), baseUnits)
+		currency = e.Quote
+	case e.Quote:
+		n = amount.Mul(amount, big.NewInt(rateScale)).Mul(amount, baseUnits)
+		d = rate.Mul(rate, quoteUnits)
+		currency = e.Base
+	default:
+		return Money{}, fmt.Errorf("%w: a %s/%s rate can't convert %s", ErrCurrencyMismatch, e.Base, e.Quote, m.Currency)
+	}
+	if d.Sign() == 0 {
+		return Money{}, fmt.Errorf("%w: exchange rate is zero", ErrInvalidMoney)
+	}
+	converted, err := roundQuotient(n, d, mode)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: converted, Currency: currency}, nil
+}
+
+func pow10(exponent int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil)
+}
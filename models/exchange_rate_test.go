@@ -0,0 +1,74 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		text  string
+		want  Rate
+		valid bool
+	}{
+		{"1.0845", 108450000, true},
+		{"150", 15000000000, true},
+		{"0.00000001", 1, true},
+		{"0.000000001", 0, false},
+		{"0", 0, false},
+		{"-1.2", 0, false},
+		{"1.", 0, false},
+		{"abc", 0, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.text, func(t *testing.T) {
+			got, err := ParseRate(tc.text)
+			assert.Equal(t, tc.valid, err == nil)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+	assert.Equal(t, "1.0845", Rate(108450000).String())
+	assert.Equal(t, "150", Rate(15000000000).String())
+}
+
+func TestRateJSON(t *testing.T) {
+	var payload struct{ Rate Rate }
+	assert.NoError(t, json.Unmarshal([]byte(`{"Rate":"0.92"}`), &payload))
+	assert.Equal(t, Rate(92000000), payload.Rate)
+	assert.NoError(t, json.Unmarshal([]byte(`{"Rate":1.5}`), &payload))
+	assert.Equal(t, Rate(150000000), payload.Rate)
+
+	data, err := json.Marshal(payload)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"Rate":"1.5"}`, string(data))
+}
+
+func TestExchangeRateConvert(t *testing.T) {
+	eurUSD := &ExchangeRate{Base: "EUR", Quote: "USD", Rate: 108000000}
+	usdJPY := &ExchangeRate{Base: "USD", Quote: "JPY", Rate: 15025000000}
+
+	converted, err := eurUSD.Convert(NewMoney(1000, "EUR"), RoundHalfUp)
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(1080, "USD"), converted)
+
+	converted, err = eurUSD.Convert(NewMoney(1000, "USD"), RoundHalfUp)
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(926, "EUR"), converted) // 9.259...
+
+	converted, err = eurUSD.Convert(NewMoney(1000, "USD"), RoundDown)
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(925, "EUR"), converted)
+
+	converted, err = usdJPY.Convert(NewMoney(1999, "USD"), RoundHalfUp)
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(3003, "JPY"), converted) // 3003.4975
+
+	converted, err = usdJPY.Convert(NewMoney(3000, "JPY"), RoundHalfUp)
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(1997, "USD"), converted) // 19.966...
+
+	_, err = eurUSD.Convert(NewMoney(1000, "GBP"), RoundHalfUp)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+}
@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+// InventoryItem is the stock of a product, or of one of its variants when
+// VariantID is set, in one warehouse
+type InventoryItem struct {
+	ID          int
+	WarehouseID int
+	ProductID   int
+	VariantID   *int
+	SKU         string // the variant's SKU, empty for product level stock
+	OnHand      int
+	Reserved    int // held for orders, part of OnHand
+}
+
+// Available is what can still be reserved
+func (i *InventoryItem) Available() int {
+	return i.OnHand - i.Reserved
+}
+
+// StockLine is a quantity of a product or variant to reserve
+type StockLine struct {
+	ProductID int
+	VariantID *int
+	Quantity  int
+}
+
+type ReservationStatus string
+
+const (
+	ReservationActive    ReservationStatus = "active"
+	ReservationReleased  ReservationStatus = "released"
+	ReservationCommitted ReservationStatus = "committed"
+)
+
+// Reservation holds stock of an item for a reference such as an order
+type Reservation struct {
+	ID        int
+	Reference string
+	ItemID    int
+	Quantity  int
+	Status    ReservationStatus
+	CreatedAt time.Time
+}
+
+type AdjustmentReason string
+
+const (
+	ReasonInitial     AdjustmentReason = "initial"
+	ReasonRestock     AdjustmentReason = "restock"
+	ReasonDamaged     AdjustmentReason = "damaged"
+	ReasonLost        AdjustmentReason = "lost"
+	ReasonCorrection  AdjustmentReason = "correction"
+	ReasonReturn      AdjustmentReason = "return"
+	ReasonSale        AdjustmentReason = "sale"
+	ReasonTransferIn  AdjustmentReason = "transfer_in"
+	ReasonTransferOut AdjustmentReason = "transfer_out"
+)
+
+// manualReasons may be recorded by staff, the others are written by the system
+var manualReasons = map[AdjustmentReason]bool{
+	ReasonRestock:    true,
+	ReasonDamaged:    true,
+	ReasonLost:       true,
+	ReasonCorrection: true,
+	ReasonReturn:     true,
+}
+
+func (r AdjustmentReason) Manual() bool {
+	return manualReasons[r]
+}
+
+// InventoryAdjustment is one entry of the stock ledger
+type InventoryAdjustment struct {
+	ID          int
+	ItemID      int
+	WarehouseID int
+	ProductID   int
+	VariantID   *int
+	Delta       int
+	OnHandAfter int
+	Reason      AdjustmentReason
+	Reference   string
+	Note        string
+	CreatedBy   int // user id, 0 for system entries
+	CreatedAt   time.Time
+}
@@ -0,0 +1,54 @@
+package models
+
+// Location is roughly where the destination is, for picking the warehouse
+// closest to it: the centroid of its region where one is known, else of its
+// country. It is nil for countries not in the table.
+func (d Destination) Location() *GeoPoint {
+	d = d.Normalize()
+	if point, ok := regionCentroids[d.Country+"-"+d.Region]; ok {
+		return &point
+	}
+	if point, ok := countryCentroids[d.Country]; ok {
+		return &point
+	}
+	return nil
+}
+
+// countryCentroids are the approximate geographic centres of countries, by
+// ISO 3166-1 alpha-2 code
+var countryCentroids = map[string]GeoPoint{
+	"AE": {23.42, 53.85}, "AR": {-38.42, -63.62}, "AT": {47.52, 14.55}, "AU": {-25.27, 133.78},
+	"BE": {50.50, 4.47}, "BG": {42.73, 25.49}, "BR": {-14.24, -51.93}, "CA": {56.13, -106.35},
+	"CH": {46.82, 8.23}, "CL": {-35.68, -71.54}, "CN": {35.86, 104.20}, "CO": {4.57, -74.30},
+	"CY": {35.13, 33.43}, "CZ": {49.82, 15.47}, "DE": {51.17, 10.45}, "DK": {56.26, 9.50},
+	"EE": {58.60, 25.01}, "EG": {26.82, 30.80}, "ES": {40.46, -3.75}, "FI": {61.92, 25.75},
+	"FR": {46.23, 2.21}, "GB": {55.38, -3.44}, "GR": {39.07, 21.82}, "HK": {22.40, 114.11},
+	"HR": {45.10, 15.20}, "HU": {47.16, 19.50}, "ID": {-0.79, 113.92}, "IE": {53.41, -8.24},
+	"IL": {31.05, 34.85}, "IN": {20.59, 78.96}, "IS": {64.96, -19.02}, "IT": {41.87, 12.57},
+	"JP": {36.20, 138.25}, "KE": {-0.02, 37.91}, "KR": {35.91, 127.77}, "LT": {55.17, 23.88},
+	"LU": {49.82, 6.13}, "LV": {56.88, 24.60}, "MA": {31.79, -7.09}, "MT": {35.94, 14.38},
+	"MX": {23.63, -102.55}, "MY": {4.21, 101.98}, "NG": {9.08, 8.68}, "NL": {52.13, 5.29},
+	"NO": {60.47, 8.47}, "NZ": {-40.90, 174.89}, "PE": {-9.19, -75.02}, "PH": {12.88, 121.77},
+	"PL": {51.92, 19.15}, "PT": {39.40, -8.22}, "RO": {45.94, 24.97}, "RS": {44.02, 21.01},
+	"SA": {23.89, 45.08}, "SE": {60.13, 18.64}, "SG": {1.35, 103.82}, "SI": {46.15, 15.00},
+	"SK": {48.67, 19.70}, "TH": {15.87, 100.99}, "TR": {38.96, 35.24}, "TW": {23.70, 120.96},
+	"UA": {48.38, 31.17}, "US": {39.83, -98.58}, "VN": {14.06, 108.28}, "ZA": {-30.56, 22.94},
+}
+
+// regionCentroids narrow large countries down to their states, keyed by
+// country and region code, e.g. "US-CA"
+var regionCentroids = map[string]GeoPoint{
+	"US-AK": {64.20, -149.49}, "US-AL": {32.81, -86.79}, "US-AR": {34.97, -92.37}, "US-AZ": {33.73, -111.43},
+	"US-CA": {36.78, -119.42}, "US-CO": {39.06, -105.31}, "US-CT": {41.60, -72.76}, "US-DC": {38.90, -77.03},
+	"US-DE": {39.32, -75.51}, "US-FL": {27.77, -81.69}, "US-GA": {33.04, -83.64}, "US-HI": {21.09, -157.50},
+	"US-IA": {42.01, -93.21}, "US-ID": {44.24, -114.48}, "US-IL": {40.35, -88.99}, "US-IN": {39.85, -86.26},
+	"US-KS": {38.53, -96.73}, "US-KY": {37.67, -84.67}, "US-LA": {31.17, -91.87}, "US-MA": {42.23, -71.53},
+	"US-MD": {39.06, -76.80}, "US-ME": {44.69, -69.38}, "US-MI": {43.33, -84.54}, "US-MN": {45.69, -93.90},
+	"US-MO": {38.46, -92.29}, "US-MS": {32.74, -89.68}, "US-MT": {46.92, -110.45}, "US-NC": {35.63, -79.81},
+	"US-ND": {47.53, -99.78}, "US-NE": {41.13, -98.27}, "US-NH": {43.45, -71.56}, "US-NJ": {40.30, -74.52},
+	"US-NM": {34.84, -106.25}, "US-NV": {38.31, -117.06}, "US-NY": {42.17, -74.95}, "US-OH": {40.39, -82.76},
+	"US-OK": {35.57, -96.93}, "US-OR": {44.57, -122.07}, "US-PA": {40.59, -77.21}, "US-RI": {41.68, -71.51},
+	"US-SC": {33.86, -80.95}, "US-SD": {44.30, -99.44}, "US-TN": {35.75, -86.69}, "US-TX": {31.05, -97.56},
+	"US-UT": {40.15, -111.86}, "US-VA": {37.77, -78.17}, "US-VT": {44.05, -72.71}, "US-WA": {47.40, -121.49},
+	"US-WI": {44.27, -89.62}, "US-WV": {38.49, -80.95}, "US-WY": {42.76, -107.30},
+}
@@ -0,0 +1,368 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidMoney     = errors.New("invalid money amount")
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrMoneyOverflow    = errors.New("money amount out of range")
+)
+
+// currencyExponents is the number of minor unit digits of each ISO 4217
+// currency we accept, e.g. 2 for USD cents and 0 for JPY
+var currencyExponents = map[string]int{
+	"AUD": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2,
+	"DKK": 2, "EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "INR": 2, "JPY": 0,
+	"KRW": 0, "KWD": 3, "MXN": 2, "NOK": 2, "NZD": 2, "PLN": 2, "SEK": 2,
+	"SGD": 2, "USD": 2, "ZAR": 2,
+}
+
+// ValidCurrency reports whether code is a supported ISO 4217 currency code
+func ValidCurrency(code string) bool {
+	_, ok := currencyExponents[code]
+	return ok
+}
+
+// CurrencyExponent is the number of digits after the decimal point of the
+// currency, 0 for unknown currencies
+func CurrencyExponent(code string) int {
+	return currencyExponents[code]
+}
+
+// Money is an amount in the minor unit of its currency, 1999 USD is $19.99.
+// The arithmetic refuses to mix currencies or to overflow instead of
+// quietly producing a wrong amount.
+type Money struct {
+	Amount   int64
+	Currency string // ISO 4217 code
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney reads a decimal amount such as "19.99" or "-5" in currency. More
+// decimals than the currency has are rejected rather than rounded away.
+func ParseMoney(text, currency string) (Money, error) {
+	if !ValidCurrency(currency) {
+		return Money{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+	exponent := CurrencyExponent(currency)
+
+	digits := strings.TrimPrefix(text, "-")
+	negative := len(digits) < len(text)
+	whole, fraction, hasPoint := strings.Cut(digits, ".")
+	switch {
+	case whole == "" || (hasPoint && fraction == ""):
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, text)
+	case len(fraction) > exponent:
+		return Money{}, fmt.Errorf("%w: %s has at most %d decimals", ErrInvalidMoney, currency, exponent)
+	}
+	fraction += strings.Repeat("0", exponent-len(fraction))
+	for _, c := range whole + fraction {
+		if c < '0' || c > '9' {
+			return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, text)
+		}
+	}
+
+	amount, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrMoneyOverflow, text)
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// SameCurrency reports whether m and other can be added up or compared
+func (m Money) SameCurrency(other Money) bool {
+	return m.Currency == other.Currency
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if !m.SameCurrency(other) {
+		return Money{}, m.mismatch(other)
+	}
+	sum := m.Amount + other.Amount
+	if (other.Amount > 0 && sum < m.Amount) || (other.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Amount == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return m.Add(other.Neg())
+}
+
+// Mul multiplies by a whole factor, e.g. a quantity
+func (m Money) Mul(factor int64) (Money, error) {
+	if m.Amount == 0 || factor == 0 {
+		return Money{Currency: m.Currency}, nil
+	}
+	product := m.Amount * factor
+	if product/factor != m.Amount || (m.Amount == -1 && factor == math.MinInt64) || (factor == -1 && m.Amount == math.MinInt64) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: product, Currency: m.Currency}, nil
+}
+
+// Cmp returns -1, 0 or +1 as m is less than, equal to or greater than other
+func (m Money) Cmp(other Money) (int, error) {
+	if !m.SameCurrency(other) {
+		return 0, m.mismatch(other)
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// RoundingMode decides what happens to a fraction of a minor unit
+type RoundingMode int
+
+const (
+	RoundHalfUp   RoundingMode = iota // halves away from zero, 0.5 -> 1 and -0.5 -> -1
+	RoundHalfEven                     // halves to the even neighbour, the banker's rounding
+	RoundHalfDown                     // halves towards zero
+	RoundDown                         // towards zero, truncating
+	RoundUp                           // away from zero
+	RoundFloor                        // towards negative infinity
+	RoundCeiling                      // towards positive infinity
+)
+
+var roundingModes = map[string]RoundingMode{
+	"half_up":   RoundHalfUp,
+	"half_even": RoundHalfEven,
+	"half_down": RoundHalfDown,
+	"down":      RoundDown,
+	"up":        RoundUp,
+	"floor":     RoundFloor,
+	"ceiling":   RoundCeiling,
+}
+
+// ParseRoundingMode reads a mode by its name, e.g. "half_even"
+func ParseRoundingMode(name string) (RoundingMode, error) {
+	mode, ok := roundingModes[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown rounding mode %q", name)
+	}
+	return mode, nil
+}
+
+// MulRatio returns m * numerator / denominator rounded to a whole minor unit,
+// e.g. MulRatio(15, 100, RoundHalfUp) is 15% of m
+func (m Money) MulRatio(numerator, denominator int64, mode RoundingMode) (Money, error) {
+	if denominator == 0 {
+		return Money{}, fmt.Errorf("%w: division by zero", ErrInvalidMoney)
+	}
+	product := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(numerator))
+	amount, err := roundQuotient(product, big.NewInt(denominator), mode)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount, Currency: m.Currency}, nil
+}
+
+// roundQuotient divides n by d and rounds the result to an int64
+func roundQuotient(n, d *big.Int, mode RoundingMode) (int64, error) {
+	if d.Sign() < 0 {
+		n, d = new(big.Int).Neg(n), new(big.Int).Neg(d)
+	}
+	quotient, remainder := new(big.Int).QuoRem(n, d, new(big.Int))
+	if remainder.Sign() != 0 {
+		// compare twice the remainder with the divisor to tell below, at or above half
+		half := new(big.Int).Abs(remainder)
+		half.Lsh(half, 1)
+		position := half.Cmp(d)
+		negative := n.Sign() < 0
+
+		awayFromZero := false
+		switch mode {
+		case RoundHalfUp:
+			awayFromZero = position >= 0
+		case RoundHalfEven:
+			awayFromZero = position > 0 || (position == 0 && quotient.Bit(0) == 1)
+		case RoundHalfDown:
+			awayFromZero = position > 0
+		case RoundDown:
+		case RoundUp:
+			awayFromZero = true
+		case RoundFloor:
+			awayFromZero = negative
+		case RoundCeiling:
+			awayFromZero = !negative
+		default:
+			return 0, fmt.Errorf("unknown rounding mode %d", mode)
+		}
+		if awayFromZero {
+			if negative {
+				quotient.Sub(quotient, big.NewInt(1))
+			} else {
+				quotient.Add(quotient, big.NewInt(1))
+			}
+		}
+	}
+	if !quotient.IsInt64() {
+		return 0, ErrMoneyOverflow
+	}
+	return quotient.Int64(), nil
+}
+
+// Allocate splits m in proportion to ratios without losing a minor unit:
+// what can't be divided evenly goes one unit at a time to the first shares,
+// e.g. 100 allocated 1:1:1 is 34, 33, 33
+func (m Money) Allocate(ratios ...int64) ([]Money, error) {
+	if len(ratios) == 0 {
+		return nil, fmt.Errorf("%w: nothing to allocate to", ErrInvalidMoney)
+	}
+	total := new(big.Int)
+	for _, ratio := range ratios {
+		if ratio < 0 {
+			return nil, fmt.Errorf("%w: ratios must not be negative", ErrInvalidMoney)
+		}
+		total.Add(total, big.NewInt(ratio))
+	}
+	if total.Sign() == 0 {
+		return nil, fmt.Errorf("%w: ratios must not all be zero", ErrInvalidMoney)
+	}
+
+	shares := make([]Money, len(ratios))
+	remainder := m.Amount
+	for i, ratio := range ratios {
+		share, err := roundQuotient(new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(ratio)), total, RoundDown)
+		if err != nil {
+			return nil, err
+		}
+		shares[i] = Money{Amount: share, Currency: m.Currency}
+		remainder -= share
+	}
+	unit := int64(1)
+	if remainder < 0 {
+		unit = -1
+	}
+	for i := 0; remainder != 0; i = (i + 1) % len(shares) {
+		if ratios[i] == 0 {
+			continue
+		}
+		shares[i].Amount += unit
+		remainder -= unit
+	}
+	return shares, nil
+}
+
+// Split divides m into n shares that differ by at most one minor unit
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: can't split into %d shares", ErrInvalidMoney, n)
+	}
+	ratios := make([]int64, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return m.Allocate(ratios...)
+}
+
+// Decimal formats the amount with the currency's decimals, e.g. "19.99"
+func (m Money) Decimal() string {
+	exponent := CurrencyExponent(m.Currency)
+	digits := strconv.FormatUint(absAmount(m.Amount), 10)
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	text := digits
+	if exponent > 0 {
+		text = digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+	}
+	if m.Amount < 0 {
+		text = "-" + text
+	}
+	return text
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+func absAmount(amount int64) uint64 {
+	if amount < 0 {
+		return uint64(-(amount + 1)) + 1
+	}
+	return uint64(amount)
+}
+
+func (m Money) mismatch(other Money) error {
+	return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+}
+
+// moneyJSON is how money travels in JSON. The amount is a decimal string so
+// clients don't need floating point to read it.
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON writes {"amount":"19.99","currency":"USD"}
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.Decimal(), Currency: m.Currency})
+}
+
+// UnmarshalJSON reads {"amount":"19.99","currency":"USD"}, the amount may
+// also be a JSON number
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var raw struct {
+		Amount   json.RawMessage `json:"amount"`
+		Currency string          `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	text := string(raw.Amount)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	if text == "" || text == "null" {
+		return fmt.Errorf("%w: amount is required", ErrInvalidMoney)
+	}
+
+	parsed, err := ParseMoney(text, strings.ToUpper(raw.Currency))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
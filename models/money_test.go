@@ -0,0 +1,117 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		text     string
+		currency string
+		want     Money
+		err      error
+	}{
+		{"19.99", "USD", NewMoney(1999, "USD"), nil},
+		{"19.9", "USD", NewMoney(1990, "USD"), nil},
+		{"-5", "EUR", NewMoney(-500, "EUR"), nil},
+		{"1500", "JPY", NewMoney(1500, "JPY"), nil},
+		{"1.234", "KWD", NewMoney(1234, "KWD"), nil},
+		{"19.999", "USD", Money{}, ErrInvalidMoney},
+		{"1.5", "JPY", Money{}, ErrInvalidMoney},
+		{"1e3", "USD", Money{}, ErrInvalidMoney},
+		{"5.", "USD", Money{}, ErrInvalidMoney},
+		{"10", "XYZ", Money{}, ErrUnknownCurrency},
+		{"92233720368547758.08", "USD", Money{}, ErrMoneyOverflow},
+	}
+	for _, tc := range tests {
+		t.Run(tc.text+" "+tc.currency, func(t *testing.T) {
+			got, err := ParseMoney(tc.text, tc.currency)
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	sum, err := NewMoney(1999, "USD").Add(NewMoney(1, "USD"))
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(2000, "USD"), sum)
+
+	_, err = NewMoney(1, "USD").Add(NewMoney(1, "EUR"))
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	_, err = NewMoney(math.MaxInt64, "USD").Add(NewMoney(1, "USD"))
+	assert.ErrorIs(t, err, ErrMoneyOverflow)
+	_, err = NewMoney(0, "USD").Sub(NewMoney(math.MinInt64, "USD"))
+	assert.ErrorIs(t, err, ErrMoneyOverflow)
+	_, err = NewMoney(math.MaxInt64/2+1, "USD").Mul(2)
+	assert.ErrorIs(t, err, ErrMoneyOverflow)
+
+	product, err := NewMoney(250, "USD").Mul(3)
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(750, "USD"), product)
+
+	cmp, err := NewMoney(5, "USD").Cmp(NewMoney(7, "USD"))
+	assert.NoError(t, err)
+	assert.Equal(t, -1, cmp)
+}
+
+func TestMulRatio(t *testing.T) {
+	tests := []struct {
+		mode     RoundingMode
+		positive int64 // 25 * 1/10 and 35 * 1/10
+		even     int64
+		negative int64 // -25 * 1/10
+	}{
+		{RoundHalfUp, 3, 4, -3},
+		{RoundHalfEven, 2, 4, -2},
+		{RoundHalfDown, 2, 3, -2},
+		{RoundDown, 2, 3, -2},
+		{RoundUp, 3, 4, -3},
+		{RoundFloor, 2, 3, -3},
+		{RoundCeiling, 3, 4, -2},
+	}
+	for _, tc := range tests {
+		for amount, want := range map[int64]int64{25: tc.positive, 35: tc.even, -25: tc.negative} {
+			got, err := NewMoney(amount, "USD").MulRatio(1, 10, tc.mode)
+			assert.NoError(t, err)
+			assert.Equal(t, NewMoney(want, "USD"), got, "mode %d, amount %d", tc.mode, amount)
+		}
+	}
+
+	_, err := NewMoney(1, "USD").MulRatio(1, 0, RoundHalfUp)
+	assert.ErrorIs(t, err, ErrInvalidMoney)
+	mode, err := ParseRoundingMode("half_even")
+	assert.NoError(t, err)
+	assert.Equal(t, RoundHalfEven, mode)
+}
+
+func TestAllocate(t *testing.T) {
+	shares, err := NewMoney(100, "USD").Split(3)
+	assert.NoError(t, err)
+	assert.Equal(t, []Money{NewMoney(34, "USD"), NewMoney(33, "USD"), NewMoney(33, "USD")}, shares)
+
+	shares, err = NewMoney(-5, "EUR").Allocate(0, 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []Money{NewMoney(0, "EUR"), NewMoney(-3, "EUR"), NewMoney(-2, "EUR")}, shares)
+
+	_, err = NewMoney(100, "USD").Allocate(0, 0)
+	assert.ErrorIs(t, err, ErrInvalidMoney)
+}
+
+func TestMoneyJSON(t *testing.T) {
+	body, err := json.Marshal(NewMoney(-105, "USD"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"-1.05","currency":"USD"}`, string(body))
+	body, _ = json.Marshal(NewMoney(1500, "JPY"))
+	assert.JSONEq(t, `{"amount":"1500","currency":"JPY"}`, string(body))
+
+	var m Money
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount":19.99,"currency":"eur"}`), &m))
+	assert.Equal(t, NewMoney(1999, "EUR"), m)
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"amount":"1"}`), &m), ErrUnknownCurrency)
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"currency":"USD"}`), &m), ErrInvalidMoney)
+}
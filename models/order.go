@@ -0,0 +1,171 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+type OrderStatus string
+
+const (
+	OrderPending   OrderStatus = "pending"
+	OrderPaid      OrderStatus = "paid"
+	OrderFulfilled OrderStatus = "fulfilled" // picked and packed, the stock has left inventory
+	OrderShipped   OrderStatus = "shipped"
+	OrderDelivered OrderStatus = "delivered"
+	OrderCancelled OrderStatus = "cancelled"
+	OrderRefunded  OrderStatus = "refunded"
+)
+
+// orderTransitions lists the statuses an order may move to from each status,
+// cancelled and refunded are final
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPending:   {OrderPaid, OrderCancelled},
+	OrderPaid:      {OrderFulfilled, OrderRefunded},
+	OrderFulfilled: {OrderShipped, OrderRefunded},
+	OrderShipped:   {OrderDelivered, OrderRefunded},
+	OrderDelivered: {OrderRefunded},
+	OrderCancelled: {},
+	OrderRefunded:  {},
+}
+
+func (s OrderStatus) Valid() bool {
+	_, ok := orderTransitions[s]
+	return ok
+}
+
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, status := range orderTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+	return false
+}
+
+// HoldsStock reports whether stock is still reserved for an order in this status
+func (s OrderStatus) HoldsStock() bool {
+	return s == OrderPending || s == OrderPaid
+}
+
+type Order struct {
+	ID             int
+	UserID         int
+	Status         OrderStatus
+	Currency       string
+	Subtotal       Money
+	Discount       Money // taken off the subtotal by promotions
+	Tax            Money
+	TaxInclusive   bool // the prices contain Tax, it isn't added on top
+	FreeShipping   bool
+	RefundedAmount Money // given back through the payment gateway so far
+	Items          []OrderItem
+	Addresses      []OrderAddress // shipping and billing, as they were at checkout
+	Discounts      []OrderDiscount
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// CheckoutRequest is what a customer checks their cart out with. Coupon
+// codes are applied on top of the automatic promotions, Destination decides
+// the tax. The address IDs pick saved addresses of the user to copy onto the
+// order, 0 for none; a shipping address replaces Destination.
+type CheckoutRequest struct {
+	UserID            int
+	CouponCodes       []string
+	Destination       Destination
+	ShippingAddressID int
+	BillingAddressID  int
+}
+
+// OrderItem is a snapshot of a line at checkout, ProductID and VariantID
+// are nil once the product or variant is deleted
+type OrderItem struct {
+	ID        int
+	OrderID   int
+	ProductID *int
+	VariantID *int
+	SKU       string
+	Name      string
+	Quantity  int
+	UnitPrice Money // in the order's currency
+	TaxClass  string
+	TaxName   string  // of the rate that applied, empty when the line wasn't taxed
+	TaxRate   Percent // the rate that applied
+	Tax       Money   // on the line after its discounts
+}
+
+// OrderDiscount is a line of the order's discount breakdown, a snapshot of
+// the promotion that applied. OrderItemID is nil for discounts on the whole
+// order and PromotionID once the promotion is deleted.
+type OrderDiscount struct {
+	ID          int
+	OrderID     int
+	OrderItemID *int
+	PromotionID *int
+	Name        string
+	Code        string
+	Kind        PromotionKind
+	Amount      Money
+}
+
+// OrderStatusChange is an entry of an order's history, From is empty for placing the order
+type OrderStatusChange struct {
+	ID        int
+	OrderID   int
+	From      OrderStatus
+	To        OrderStatus
+	Note      string
+	ChangedBy int
+	CreatedAt time.Time
+}
+
+// OrderFilter narrows an order listing, zero values match everything
+type OrderFilter struct {
+	UserID int
+	Status OrderStatus
+	Limit  int
+}
+
+// StockReference is what the order's inventory reservations are held under
+func (o *Order) StockReference() string {
+	return fmt.Sprintf("order-%d", o.ID)
+}
+
+func (i OrderItem) LineTotal() (Money, error) {
+	return i.UnitPrice.Mul(int64(i.Quantity))
+}
+
+// Total is what the customer pays, the subtotal less the discount plus the
+// tax unless the prices already contain it
+func (o *Order) Total() (Money, error) {
+	total, err := o.Subtotal.Sub(o.Discount)
+	if err != nil || o.TaxInclusive {
+		return total, err
+	}
+	return total.Add(o.Tax)
+}
+
+func (o *Order) ItemSubtotal() (Money, error) {
+	total := Money{Currency: o.Currency}
+	for _, item := range o.Items {
+		lineTotal, err := item.LineTotal()
+		if err != nil {
+			return Money{}, err
+		}
+		if total, err = total.Add(lineTotal); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// Address returns the order's address of the kind, nil when it has none
+func (o *Order) Address(kind AddressKind) *OrderAddress {
+	for i := range o.Addresses {
+		if o.Addresses[i].Kind == kind {
+			return &o.Addresses[i]
+		}
+	}
+	return nil
+}
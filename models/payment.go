@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+type PaymentStatus string
+
+const (
+	PaymentPending           PaymentStatus = "pending"    // the gateway is being asked to authorize it
+	PaymentAuthorized        PaymentStatus = "authorized" // funds held, not captured yet
+	PaymentCaptured          PaymentStatus = "captured"
+	PaymentVoided            PaymentStatus = "voided"
+	PaymentFailed            PaymentStatus = "failed"
+	PaymentPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentRefunded          PaymentStatus = "refunded"
+)
+
+// Payment is an attempt to pay for an order through a gateway
+type Payment struct {
+	ID             int
+	OrderID        int
+	Provider       string
+	Reference      string // the gateway's id of the payment, empty while pending
+	Status         PaymentStatus
+	Amount         Money
+	RefundedAmount Money
+	FailureReason  string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Refundable is what can still be given back. The refunded amount is capped
+// at the payment's amount as refunds are applied, so this can't go negative.
+func (p *Payment) Refundable() Money {
+	if p.Status != PaymentCaptured && p.Status != PaymentPartiallyRefunded {
+		return Money{Currency: p.Amount.Currency}
+	}
+	return Money{Amount: p.Amount.Amount - p.RefundedAmount.Amount, Currency: p.Amount.Currency}
+}
+
+type RefundStatus string
+
+const (
+	RefundPending   RefundStatus = "pending" // the gateway may or may not have refunded it yet
+	RefundSucceeded RefundStatus = "succeeded"
+	RefundFailed    RefundStatus = "failed"
+)
+
+// Refund is one refund of a payment through its gateway. It is recorded as
+// pending before the gateway is called, IdempotencyKey goes with the call so
+// sending a pending refund again can't refund it twice.
+type Refund struct {
+	ID             int
+	PaymentID      int
+	ReturnID       *int // the return it was made for, nil for refunds of the payment itself
+	IdempotencyKey string
+	Status         RefundStatus
+	Amount         Money
+	FailureReason  string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Types of payment webhook events
+const (
+	EventPaymentCaptured = "payment.captured"
+	EventPaymentFailed   = "payment.failed"
+	EventPaymentVoided   = "payment.voided"
+	EventPaymentRefunded = "payment.refunded"
+)
+
+// PaymentEvent is a notification from a gateway. For refunds Amount is the
+// total refunded so far, so replays and our own refund calls don't add up.
+type PaymentEvent struct {
+	ID        string
+	Provider  string
+	Type      string
+	Reference string
+	Amount    Money
+	Reason    string
+}
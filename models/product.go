@@ -2,7 +2,32 @@ package models
 
 // Product represents a product in the database
 type Product struct {
-	ID    int
-	Name  string
-	Price float64
+	ID         int
+	Name       string
+	Price      Money
+	TaxClass   string // code of the tax class, DefaultTaxClass unless set
+	Weight     int    // shipping weight in grams
+	Dimensions Dimensions
+	CreatedBy  int // user id of the creator
+	UpdatedBy  int // user id of the last editor
+	Variants   []Variant
+}
+
+// Dimensions are the size of a product as packed for shipping, in millimetres
+type Dimensions struct {
+	Length int
+	Width  int
+	Height int
+}
+
+// Volume is in cubic millimetres
+func (d Dimensions) Volume() int64 {
+	return int64(d.Length) * int64(d.Width) * int64(d.Height)
+}
+
+// ProductPrice is an explicit price of a product in another currency than
+// its own, it wins over converting the product price
+type ProductPrice struct {
+	ProductID int
+	Price     Money
 }
@@ -0,0 +1,140 @@
+package models
+
+import "time"
+
+type PromotionKind string
+
+const (
+	PromotionPercentage   PromotionKind = "percentage"    // Percent off the eligible lines
+	PromotionFixed        PromotionKind = "fixed"         // Amount off the eligible lines together
+	PromotionBuyXGetY     PromotionKind = "buy_x_get_y"   // of every BuyQuantity+GetQuantity eligible units the GetQuantity cheapest are free
+	PromotionFreeShipping PromotionKind = "free_shipping" // nothing off the lines, the order ships for free
+)
+
+func (k PromotionKind) Valid() bool {
+	switch k {
+	case PromotionPercentage, PromotionFixed, PromotionBuyXGetY, PromotionFreeShipping:
+		return true
+	}
+	return false
+}
+
+// Promotion is a discount rule. With a Code it is a coupon the customer has
+// to enter, without one it applies to every cart that qualifies.
+type Promotion struct {
+	ID          int
+	Name        string
+	Code        string // stored upper case, coupon codes are case insensitive
+	Kind        PromotionKind
+	Percent     int   // PromotionPercentage, 1 to 100
+	Amount      Money // PromotionFixed
+	BuyQuantity int   // PromotionBuyXGetY
+	GetQuantity int   // PromotionBuyXGetY
+	MinSubtotal Money // minimum order value over all lines, zero for none
+	// ProductIDs and CategoryIDs pick the lines the promotion applies to, a
+	// line qualifies through either; with both empty every line does
+	ProductIDs       []int
+	CategoryIDs      []int
+	StartsAt         *time.Time
+	EndsAt           *time.Time
+	UsageLimit       int  // redemptions over all customers, 0 for no limit
+	PerCustomerLimit int  // redemptions per customer, 0 for no limit
+	UsageCount       int  // redemptions so far
+	Stackable        bool // combines with other promotions
+	Priority         int  // higher priorities are applied first
+	Active           bool
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// Coupon reports whether the promotion needs its code entered
+func (p *Promotion) Coupon() bool {
+	return p.Code != ""
+}
+
+// RunsAt reports whether at falls between the promotion's start and end
+func (p *Promotion) RunsAt(at time.Time) bool {
+	if p.StartsAt != nil && at.Before(*p.StartsAt) {
+		return false
+	}
+	return p.EndsAt == nil || at.Before(*p.EndsAt)
+}
+
+// Targets reports whether the promotion applies to a line of the product in the given categories
+func (p *Promotion) Targets(productID int, categoryIDs []int) bool {
+	if len(p.ProductIDs) == 0 && len(p.CategoryIDs) == 0 {
+		return true
+	}
+	for _, id := range p.ProductIDs {
+		if id == productID {
+			return true
+		}
+	}
+	for _, id := range p.CategoryIDs {
+		for _, categoryID := range categoryIDs {
+			if id == categoryID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PromotionLine is a cart or order line as promotions see it
+type PromotionLine struct {
+	ProductID   int
+	VariantID   *int
+	Quantity    int
+	UnitPrice   Money
+	CategoryIDs []int // the product's categories and every category above them
+}
+
+func (l PromotionLine) LineTotal() (Money, error) {
+	return l.UnitPrice.Mul(int64(l.Quantity))
+}
+
+// Discount is what one promotion took off one line. Line indexes the
+// evaluated lines, it is -1 for a discount on the order as a whole.
+type Discount struct {
+	PromotionID int
+	Name        string
+	Code        string
+	Kind        PromotionKind
+	Line        int
+	Amount      Money
+}
+
+// PromotionResult is the breakdown of the promotions applied to a set of lines
+type PromotionResult struct {
+	Subtotal     Money
+	Discount     Money // the Discounts added up
+	FreeShipping bool
+	Discounts    []Discount
+}
+
+// Total is what is left to pay for the lines
+func (r *PromotionResult) Total() (Money, error) {
+	return r.Subtotal.Sub(r.Discount)
+}
+
+// PromotionIDs lists the applied promotions once each, in the order they were applied
+func (r *PromotionResult) PromotionIDs() []int {
+	var ids []int
+	seen := map[int]bool{}
+	for _, discount := range r.Discounts {
+		if !seen[discount.PromotionID] {
+			seen[discount.PromotionID] = true
+			ids = append(ids, discount.PromotionID)
+		}
+	}
+	return ids
+}
+
+// PromotionRedemption records a promotion being used by an order
+type PromotionRedemption struct {
+	ID          int
+	PromotionID int
+	OrderID     int
+	UserID      int
+	CreatedAt   time.Time
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+type ReturnStatus string
+
+const (
+	ReturnRequested ReturnStatus = "requested"
+	ReturnApproved  ReturnStatus = "approved"
+	ReturnRejected  ReturnStatus = "rejected"
+	ReturnReceived  ReturnStatus = "received" // the goods are back in the warehouse
+	ReturnRefunded  ReturnStatus = "refunded"
+)
+
+// returnTransitions lists the statuses a return may move to from each
+// status. An approved return can be refunded without the goods coming back,
+// e.g. when they arrived broken.
+var returnTransitions = map[ReturnStatus][]ReturnStatus{
+	ReturnRequested: {ReturnApproved, ReturnRejected},
+	ReturnApproved:  {ReturnReceived, ReturnRefunded},
+	ReturnReceived:  {ReturnRefunded},
+	ReturnRejected:  {},
+	ReturnRefunded:  {},
+}
+
+func (s ReturnStatus) Valid() bool {
+	_, ok := returnTransitions[s]
+	return ok
+}
+
+func (s ReturnStatus) CanTransitionTo(next ReturnStatus) bool {
+	for _, status := range returnTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Return is a customer's request to send back lines of an order (an RMA)
+type Return struct {
+	ID           int
+	OrderID      int
+	UserID       int
+	Status       ReturnStatus
+	Reason       string
+	Note         string
+	Currency     string // the order's currency
+	RefundAmount Money  // what was refunded for the return, it may be less than Value
+	Items        []ReturnItem
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// ReturnItem is a quantity of an order line being returned. The product,
+// name, price, discounts and tax come from the order line.
+type ReturnItem struct {
+	ID           int
+	ReturnID     int
+	OrderItemID  int
+	ProductID    *int
+	VariantID    *int
+	SKU          string
+	Name         string
+	Quantity     int
+	UnitPrice    Money
+	LineQuantity int   // bought on the order line
+	LineReturned int   // of the order line by the order's earlier returns, rejected ones don't count
+	LineDiscount Money // the order line's discounts, on all of LineQuantity
+	LineTax      Money // charged on top of the order line's price, zero when the price included it
+	Restocked    bool
+}
+
+// ReturnReceipt records the goods of a return arriving. The items listed in
+// Restock go back into stock in the warehouse, the others are written off.
+type ReturnReceipt struct {
+	ReturnID    int
+	WarehouseID int
+	Restock     []int // return item ids
+	Note        string
+	ReceivedBy  int
+}
+
+// ReturnFilter narrows a return listing, zero values match everything
+type ReturnFilter struct {
+	UserID int
+	Status ReturnStatus
+	Limit  int
+}
+
+// Reference is what the return's inventory adjustments are recorded under
+func (r *Return) Reference() string {
+	return fmt.Sprintf("return-%d", r.ID)
+}
+
+func (i ReturnItem) LineTotal() (Money, error) {
+	return i.UnitPrice.Mul(int64(i.Quantity))
+}
+
+// Value is what the returned quantity was sold for, its share of what the
+// order line cost after the line's discounts and with its tax. Shares are
+// rounded on the running total of the line's returns, so they add up to what
+// the line cost and the return taking its last units gets what is left.
+func (i ReturnItem) Value() (Money, error) {
+	if i.LineQuantity <= 0 {
+		return i.LineTotal()
+	}
+	paid, err := i.UnitPrice.Mul(int64(i.LineQuantity))
+	if err != nil {
+		return Money{}, err
+	}
+	if paid, err = paid.Sub(i.LineDiscount); err != nil {
+		return Money{}, err
+	}
+	if paid, err = paid.Add(i.LineTax); err != nil {
+		return Money{}, err
+	}
+	before, err := paid.MulRatio(int64(i.LineReturned), int64(i.LineQuantity), RoundHalfUp)
+	if err != nil {
+		return Money{}, err
+	}
+	after, err := paid.MulRatio(int64(i.LineReturned+i.Quantity), int64(i.LineQuantity), RoundHalfUp)
+	if err != nil {
+		return Money{}, err
+	}
+	return after.Sub(before)
+}
+
+// Value is what the returned lines were sold for
+func (r *Return) Value() (Money, error) {
+	total := Money{Currency: r.Currency}
+	for _, item := range r.Items {
+		value, err := item.Value()
+		if err != nil {
+			return Money{}, err
+		}
+		if total, err = total.Add(value); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
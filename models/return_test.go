@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReturnItemValue(t *testing.T) {
+	// 3 at 10.00 less 0.01 off the line, with 2.00 tax: 31.99 was paid
+	line := ReturnItem{Quantity: 1, UnitPrice: NewMoney(1000, "USD"), LineQuantity: 3,
+		LineDiscount: NewMoney(1, "USD"), LineTax: NewMoney(200, "USD")}
+
+	first := line
+	firstValue, err := first.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(1066, "USD"), firstValue, "a third of 31.99 rounded")
+
+	second := line
+	second.Quantity, second.LineReturned = 2, 1
+	secondValue, err := second.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(2133, "USD"), secondValue, "the last units get what is left")
+
+	total, err := firstValue.Add(secondValue)
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(3199, "USD"), total)
+}
+
+func TestReturnValue(t *testing.T) {
+	ret := Return{Currency: "USD", Items: []ReturnItem{
+		{Quantity: 1, UnitPrice: NewMoney(333, "USD"), LineQuantity: 2, LineDiscount: NewMoney(0, "USD"), LineTax: NewMoney(1, "USD")},
+		{Quantity: 2, UnitPrice: NewMoney(500, "USD")},
+	}}
+	value, err := ret.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(334+1000, "USD"), value, "667 halved rounds half up")
+}
@@ -0,0 +1,48 @@
+package models
+
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleStaff    Role = "staff"
+	RoleAdmin    Role = "admin"
+)
+
+type Permission string
+
+const (
+	PermProductRead  Permission = "product:read"
+	PermProductWrite Permission = "product:write"
+	PermUserRead     Permission = "user:read"
+	PermUserWrite    Permission = "user:write"
+
+	PermInventoryRead  Permission = "inventory:read"
+	PermInventoryWrite Permission = "inventory:write"
+
+	PermOrderRead  Permission = "order:read"
+	PermOrderWrite Permission = "order:write"
+
+	PermPromotionRead  Permission = "promotion:read"
+	PermPromotionWrite Permission = "promotion:write"
+)
+
+// rolePermissions lists what each role is allowed to do
+var rolePermissions = map[Role][]Permission{
+	RoleCustomer: {PermProductRead},
+	RoleStaff:    {PermProductRead, PermUserRead, PermInventoryRead, PermInventoryWrite, PermOrderRead, PermOrderWrite, PermPromotionRead},
+	RoleAdmin:    {PermProductRead, PermProductWrite, PermUserRead, PermUserWrite, PermInventoryRead, PermInventoryWrite, PermOrderRead, PermOrderWrite, PermPromotionRead, PermPromotionWrite},
+}
+
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// ShippingRateKind says what a shipping method's rate table is keyed by
+type ShippingRateKind string
+
+const (
+	ShippingFlat     ShippingRateKind = "flat"   // one price whatever is shipped
+	ShippingByWeight ShippingRateKind = "weight" // tiers by the total weight in grams
+	ShippingByPrice  ShippingRateKind = "price"  // tiers by the discounted subtotal in minor units
+)
+
+func (k ShippingRateKind) Valid() bool {
+	switch k {
+	case ShippingFlat, ShippingByWeight, ShippingByPrice:
+		return true
+	}
+	return false
+}
+
+// ZoneLocation is a place a shipping zone covers, Region and Postcode narrow
+// it down within the country like they do for tax rates
+type ZoneLocation struct {
+	Country  string
+	Region   string
+	Postcode string
+}
+
+// Matches reports whether the (normalized) destination lies in the location
+func (l ZoneLocation) Matches(d Destination) bool {
+	return locationMatches(l.Country, l.Region, l.Postcode, d)
+}
+
+// Specificity orders matching locations, the most specific one wins
+func (l ZoneLocation) Specificity() int {
+	return locationSpecificity(l.Region, l.Postcode)
+}
+
+// ShippingZone groups the places shipped to at the same rates. A place
+// belongs to at most one zone, a destination goes to the zone with its most
+// specific location.
+type ShippingZone struct {
+	ID        int
+	Name      string
+	Locations []ZoneLocation
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// MatchZone returns the zone of the destination, nil when none covers it
+func MatchZone(zones []ShippingZone, d Destination) *ShippingZone {
+	var best *ShippingZone
+	bestSpecificity := -1
+	for i := range zones {
+		for _, location := range zones[i].Locations {
+			if location.Matches(d) && location.Specificity() > bestSpecificity {
+				best, bestSpecificity = &zones[i], location.Specificity()
+			}
+		}
+	}
+	return best
+}
+
+// ShippingTier is a row of a rate table, Price applies from Min on: grams
+// for weight based rates, minor units of the subtotal for price based ones
+type ShippingTier struct {
+	Min   int64
+	Price Money
+}
+
+// ShippingMethod is a way of shipping to a zone, e.g. standard or express.
+// A flat method has a single tier from 0. A method doesn't ship what falls
+// below its lowest tier.
+type ShippingMethod struct {
+	ID            int
+	ZoneID        int
+	Name          string
+	Kind          ShippingRateKind
+	Currency      string
+	Tiers         []ShippingTier // ordered by Min
+	EstimatedDays int            // 0 when unknown
+	Active        bool
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Rate is what shipping weight grams costs at a subtotal, false when the
+// method doesn't cover them
+func (m *ShippingMethod) Rate(weight int, subtotal Money) (Money, bool) {
+	measure := int64(0)
+	switch m.Kind {
+	case ShippingByWeight:
+		measure = int64(weight)
+	case ShippingByPrice:
+		measure = subtotal.Amount
+	}
+	tiers := append([]ShippingTier(nil), m.Tiers...)
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].Min < tiers[j].Min })
+	for i := len(tiers) - 1; i >= 0; i-- {
+		if tiers[i].Min <= measure {
+			return tiers[i].Price, true
+		}
+	}
+	return Money{}, false
+}
+
+// ShippingMethodFilter narrows the methods listed, zero values match everything
+type ShippingMethodFilter struct {
+	ZoneID     int
+	ActiveOnly bool
+}
+
+// ShippingItem is a cart line as shipping sees it
+type ShippingItem struct {
+	ProductID  int
+	Quantity   int
+	Weight     int // of one unit, grams
+	Dimensions Dimensions
+}
+
+// ShippingRequest asks for the ways of shipping items to a destination.
+// Subtotal is what the items sell for after discounts.
+type ShippingRequest struct {
+	Destination Destination
+	Subtotal    Money
+	Items       []ShippingItem
+}
+
+// Weight is the total weight in grams
+func (r ShippingRequest) Weight() int {
+	weight := 0
+	for _, item := range r.Items {
+		weight += item.Weight * item.Quantity
+	}
+	return weight
+}
+
+// ShippingQuote is a way of shipping a request and its price. Code tells
+// the method apart from every other provider's.
+type ShippingQuote struct {
+	Provider      string
+	Code          string
+	Name          string
+	Price         Money
+	Cost          Money // what the customer pays, Price unless a promotion makes it free
+	EstimatedDays int
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShippingMethodRate(t *testing.T) {
+	byWeight := ShippingMethod{Kind: ShippingByWeight, Tiers: []ShippingTier{
+		{Min: 2000, Price: NewMoney(900, "USD")},
+		{Min: 0, Price: NewMoney(500, "USD")},
+		{Min: 10000, Price: NewMoney(2500, "USD")},
+	}}
+	tests := []struct {
+		name   string
+		weight int
+		want   Money
+	}{
+		{"Lightest tier", 0, NewMoney(500, "USD")},
+		{"Just below a tier", 1999, NewMoney(500, "USD")},
+		{"On a tier", 2000, NewMoney(900, "USD")},
+		{"Past the last tier", 25000, NewMoney(2500, "USD")},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			price, ok := byWeight.Rate(tc.weight, NewMoney(0, "USD"))
+			assert.True(t, ok)
+			assert.Equal(t, tc.want, price)
+		})
+	}
+
+	byPrice := ShippingMethod{Kind: ShippingByPrice, Tiers: []ShippingTier{{Min: 1000, Price: NewMoney(490, "EUR")}, {Min: 5000, Price: NewMoney(0, "EUR")}}}
+	_, ok := byPrice.Rate(0, NewMoney(999, "EUR"))
+	assert.False(t, ok, "below the lowest tier")
+	price, _ := byPrice.Rate(90000, NewMoney(6000, "EUR"))
+	assert.Equal(t, NewMoney(0, "EUR"), price)
+
+	flat := ShippingMethod{Kind: ShippingFlat, Tiers: []ShippingTier{{Min: 0, Price: NewMoney(399, "USD")}}}
+	price, _ = flat.Rate(50000, NewMoney(100000, "USD"))
+	assert.Equal(t, NewMoney(399, "USD"), price)
+}
+
+func TestMatchZone(t *testing.T) {
+	zones := []ShippingZone{
+		{ID: 1, Name: "Mainland", Locations: []ZoneLocation{{Country: "US"}}},
+		{ID: 2, Name: "Remote", Locations: []ZoneLocation{{Country: "US", Region: "AK"}, {Country: "US", Region: "HI"}, {Country: "US", Postcode: "006*"}}},
+		{ID: 3, Name: "Europe", Locations: []ZoneLocation{{Country: "DE"}, {Country: "FR"}}},
+	}
+	tests := []struct {
+		name        string
+		destination Destination
+		zone        int
+	}{
+		{"Country", Destination{Country: "US", Region: "NY"}, 1},
+		{"Region wins over country", Destination{Country: "US", Region: "HI"}, 2},
+		{"Postcode prefix", Destination{Country: "US", Postcode: "00601"}, 2},
+		{"Second location", Destination{Country: "FR"}, 3},
+		{"Nowhere", Destination{Country: "JP"}, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			zone := MatchZone(zones, tc.destination)
+			if tc.zone == 0 {
+				assert.Nil(t, zone)
+				return
+			}
+			assert.Equal(t, tc.zone, zone.ID)
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTaxClass is the class of products that weren't given one
+const DefaultTaxClass = "standard"
+
+// TaxMode says whether catalogue prices already contain tax
+type TaxMode string
+
+const (
+	TaxExclusive TaxMode = "exclusive" // tax is added on top of the prices
+	TaxInclusive TaxMode = "inclusive" // prices contain the tax, it is worked out of them
+)
+
+func ParseTaxMode(name string) (TaxMode, error) {
+	switch mode := TaxMode(name); mode {
+	case TaxExclusive, TaxInclusive:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown tax mode %q, expected %s or %s", name, TaxExclusive, TaxInclusive)
+}
+
+// PercentDecimals is how many decimals a tax rate keeps
+const PercentDecimals = 4
+
+const percentScale = 10_000 // 10^PercentDecimals
+
+// Percent is a tax rate in fixed point, Percent(88750) is 8.875%
+type Percent int64
+
+// ParsePercent reads a decimal percentage between 0 and 100 such as "8.875"
+func ParsePercent(text string) (Percent, error) {
+	whole, fraction, hasPoint := strings.Cut(strings.TrimSpace(text), ".")
+	if whole == "" || (hasPoint && fraction == "") || len(fraction) > PercentDecimals {
+		return 0, fmt.Errorf("invalid percentage %q", text)
+	}
+	digits := whole + fraction + strings.Repeat("0", PercentDecimals-len(fraction))
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid percentage %q", text)
+		}
+	}
+	value, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || value > 100*percentScale {
+		return 0, fmt.Errorf("invalid percentage %q", text)
+	}
+	return Percent(value), nil
+}
+
+// Valid reports whether the percentage is between 0 and 100
+func (p Percent) Valid() bool {
+	return p >= 0 && p <= 100*percentScale
+}
+
+// String formats the percentage without trailing zeros, e.g. "8.875"
+func (p Percent) String() string {
+	text := fmt.Sprintf("%d.%0*d", p/percentScale, PercentDecimals, p%percentScale)
+	return strings.TrimSuffix(strings.TrimRight(text, "0"), ".")
+}
+
+// MarshalJSON writes the percentage as a decimal string, like exchange rates
+func (p Percent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON reads a decimal string or a JSON number
+func (p *Percent) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	parsed, err := ParsePercent(text)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// Of returns the percentage of m, rounded to a whole minor unit with mode
+func (p Percent) Of(m Money, mode RoundingMode) (Money, error) {
+	return m.MulRatio(int64(p), 100*percentScale, mode)
+}
+
+// IncludedIn returns the tax contained in a gross amount m at this rate
+func (p Percent) IncludedIn(m Money, mode RoundingMode) (Money, error) {
+	return m.MulRatio(int64(p), 100*percentScale+int64(p), mode)
+}
+
+// TaxClass groups products taxed alike, e.g. standard, reduced or zero rated
+type TaxClass struct {
+	Code      string
+	Name      string
+	CreatedAt time.Time
+}
+
+// ValidCountry reports whether code looks like an ISO 3166-1 alpha-2 code
+func ValidCountry(code string) bool {
+	if len(code) != 2 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Destination is where an order ships, which decides the tax it pays
+type Destination struct {
+	Country  string // ISO 3166-1 alpha-2
+	Region   string // state or province
+	Postcode string
+}
+
+// Normalize upper cases the destination and strips spaces from the postcode
+func (d Destination) Normalize() Destination {
+	return Destination{
+		Country:  strings.ToUpper(strings.TrimSpace(d.Country)),
+		Region:   strings.ToUpper(strings.TrimSpace(d.Region)),
+		Postcode: strings.ToUpper(strings.ReplaceAll(d.Postcode, " ", "")),
+	}
+}
+
+// TaxRate is a row of the rate table. Region and Postcode narrow the rate
+// down within the country, empty they match everything. A Postcode ending
+// in * matches every postcode starting with what comes before it.
+type TaxRate struct {
+	ID        int
+	TaxClass  string
+	Country   string
+	Region    string
+	Postcode  string
+	Rate      Percent
+	Name      string // shown on receipts, e.g. "VAT" or "CA sales tax"
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Matches reports whether the rate applies to the (normalized) destination
+func (r *TaxRate) Matches(d Destination) bool {
+	return locationMatches(r.Country, r.Region, r.Postcode, d)
+}
+
+// Specificity orders matching rates, the most specific one wins
+func (r *TaxRate) Specificity() int {
+	return locationSpecificity(r.Region, r.Postcode)
+}
+
+// locationMatches reports whether a place given as a country, optionally
+// narrowed by a region and a postcode or postcode prefix ending in *,
+// contains the (normalized) destination
+func locationMatches(country, region, postcode string, d Destination) bool {
+	if country != d.Country || (region != "" && region != d.Region) {
+		return false
+	}
+	if prefix, wildcard := strings.CutSuffix(postcode, "*"); wildcard {
+		return strings.HasPrefix(d.Postcode, prefix)
+	}
+	return postcode == "" || postcode == d.Postcode
+}
+
+// locationSpecificity orders places matching a destination: a postcode over
+// a region over the whole country, a longer postcode over a shorter one and
+// an exact postcode over a prefix of the same length
+func locationSpecificity(region, postcode string) int {
+	specificity := 0
+	if prefix, wildcard := strings.CutSuffix(postcode, "*"); wildcard {
+		specificity = 4*len(prefix) + 2
+	} else if postcode != "" {
+		specificity = 4*len(postcode) + 4
+	}
+	if region != "" {
+		specificity++
+	}
+	return specificity
+}
+
+// TaxRateFilter narrows the rate table, empty fields match everything
+type TaxRateFilter struct {
+	TaxClass string
+	Country  string
+}
+
+// TaxLine is a cart or order line as taxes see it, Amount is what the line
+// sells for after discounts
+type TaxLine struct {
+	TaxClass string
+	Amount   Money
+}
+
+// LineTax is the tax breakdown of a line. RateID is 0 when no rate matched
+// and the line is untaxed.
+type LineTax struct {
+	Line     int // index of the evaluated line
+	TaxClass string
+	RateID   int
+	Name     string
+	Rate     Percent
+	Net      Money
+	Tax      Money
+	Gross    Money
+}
+
+// TaxResult is the tax on a set of lines
+type TaxResult struct {
+	Mode        TaxMode
+	Destination Destination
+	Net         Money
+	Tax         Money
+	Gross       Money
+	Lines       []LineTax
+}
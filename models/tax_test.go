@@ -0,0 +1,89 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePercent(t *testing.T) {
+	tests := []struct {
+		text  string
+		want  Percent
+		valid bool
+	}{
+		{"8.875", 88750, true},
+		{"20", 200000, true},
+		{"0", 0, true},
+		{"100", 1000000, true},
+		{"100.0001", 0, false},
+		{"0.00001", 0, false},
+		{"-5", 0, false},
+		{"5.", 0, false},
+		{"five", 0, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.text, func(t *testing.T) {
+			got, err := ParsePercent(tc.text)
+			assert.Equal(t, tc.valid, err == nil)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+	assert.Equal(t, "8.875", Percent(88750).String())
+	assert.Equal(t, "20", Percent(200000).String())
+	assert.Equal(t, "0", Percent(0).String())
+}
+
+func TestPercentJSON(t *testing.T) {
+	var payload struct{ Rate Percent }
+	assert.NoError(t, json.Unmarshal([]byte(`{"Rate":"7.25"}`), &payload))
+	assert.Equal(t, Percent(72500), payload.Rate)
+	assert.NoError(t, json.Unmarshal([]byte(`{"Rate":5.5}`), &payload))
+	assert.Equal(t, Percent(55000), payload.Rate)
+	assert.Error(t, json.Unmarshal([]byte(`{"Rate":"120"}`), &payload))
+
+	data, err := json.Marshal(payload)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"Rate":"5.5"}`, string(data))
+}
+
+func TestPercentOf(t *testing.T) {
+	rate := Percent(200000)
+	tax, err := rate.Of(NewMoney(999, "EUR"), RoundHalfUp)
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(200, "EUR"), tax)
+
+	included, err := rate.IncludedIn(NewMoney(1200, "EUR"), RoundHalfUp)
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(200, "EUR"), included)
+}
+
+func TestTaxRateMatches(t *testing.T) {
+	destination := Destination{Country: "us", Region: "ny", Postcode: "10001 "}.Normalize()
+	tests := []struct {
+		name    string
+		rate    TaxRate
+		matches bool
+	}{
+		{"Country", TaxRate{Country: "US"}, true},
+		{"Other country", TaxRate{Country: "CA"}, false},
+		{"Region", TaxRate{Country: "US", Region: "NY"}, true},
+		{"Other region", TaxRate{Country: "US", Region: "NJ"}, false},
+		{"Postcode", TaxRate{Country: "US", Postcode: "10001"}, true},
+		{"Postcode prefix", TaxRate{Country: "US", Region: "NY", Postcode: "100*"}, true},
+		{"Other postcode", TaxRate{Country: "US", Postcode: "10002"}, false},
+		{"Other prefix", TaxRate{Country: "US", Postcode: "11*"}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.matches, tc.rate.Matches(destination))
+		})
+	}
+
+	country, region := TaxRate{Country: "US"}, TaxRate{Country: "US", Region: "NY"}
+	prefix, postcode := TaxRate{Country: "US", Postcode: "10001*"}, TaxRate{Country: "US", Postcode: "10001"}
+	assert.Less(t, country.Specificity(), region.Specificity())
+	assert.Less(t, region.Specificity(), prefix.Specificity())
+	assert.Less(t, prefix.Specificity(), postcode.Specificity())
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// RefreshToken is a persisted, single-use refresh token. Tokens issued by
+// rotating one another share a FamilyID so a reused token can revoke them all.
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	TokenHash string // sha256 of the token, the raw value is only known to the client
+	FamilyID  string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// TokenPair is handed out on login and refresh
+type TokenPair struct {
+	UserID           int
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
@@ -6,4 +6,5 @@ type User struct {
 	Email    string
 	Username string
 	Password string
+	Role     Role
 }
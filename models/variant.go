@@ -0,0 +1,33 @@
+package models
+
+// OptionType is a dimension products vary in, e.g. "size" or "color"
+type OptionType struct {
+	ID     int
+	Name   string
+	Values []OptionValue
+}
+
+type OptionValue struct {
+	ID           int
+	OptionTypeID int
+	Value        string
+}
+
+// Variant is a sellable version of a product, identified by its SKU
+type Variant struct {
+	ID        int
+	ProductID int
+	SKU       string
+	Price     *Money // overrides the product price when set, in the product's currency
+	Barcode   string
+	Options   map[string]string // option type name -> value, e.g. size: M
+	Stock     int               // available across warehouses, on hand minus reserved; read from inventory
+}
+
+// EffectivePrice is what the variant sells for
+func (v *Variant) EffectivePrice(product *Product) Money {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return product.Price
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Warehouse is a location stock is held and shipped from
+type Warehouse struct {
+	ID       int
+	Code     string
+	Name     string
+	Location *GeoPoint // optional, needed by the closest warehouse allocation
+	Priority int       // lower ships first with the priority allocation
+	Active   bool      // inactive warehouses keep their stock but are never allocated from
+}
+
+// GeoPoint is a latitude/longitude pair in degrees
+type GeoPoint struct {
+	Latitude  float64
+	Longitude float64
+}
+
+const earthRadiusKm = 6371.0
+
+// DistanceKm is the great circle distance between two points
+func (p GeoPoint) DistanceKm(other GeoPoint) float64 {
+	lat1, lat2 := p.Latitude*math.Pi/180, other.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (other.Longitude - p.Longitude) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// Transfer moves stock of a product or variant between two warehouses
+type Transfer struct {
+	ID              int
+	ProductID       int
+	VariantID       *int
+	FromWarehouseID int
+	ToWarehouseID   int
+	Quantity        int
+	Note            string
+	CreatedBy       int
+	CreatedAt       time.Time
+}
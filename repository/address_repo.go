@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"errors"
+	"fmt"
+)
+
+var ErrAddressNotFound = errors.New("address not found")
+
+// AddressRepo keeps the users' address books. Every call is scoped to a
+// user, other users' addresses are not found.
+type AddressRepo interface {
+	ListByUser(ctx context.Context, userID int) ([]models.Address, error)
+	GetByID(ctx context.Context, userID, id int) (*models.Address, error)
+	Create(ctx context.Context, address *models.Address) error
+	Update(ctx context.Context, address *models.Address) error
+	Delete(ctx context.Context, userID, id int) error
+	// ClearDefault unsets the user's default address of the kind
+	ClearDefault(ctx context.Context, userID int, kind models.AddressKind) error
+}
+
+type addressRepo struct {
+	db DBTX
+}
+
+func NewAddressRepo(db DBTX) AddressRepo {
+	return &addressRepo{db: db}
+}
+
+const addressColumns = "id, user_id, name, company, line1, line2, city, region, postcode, country, phone, default_shipping, default_billing, created_at, updated_at"
+
+// defaultColumns are the flag columns of each kind of default address
+var defaultColumns = map[models.AddressKind]string{
+	models.AddressShipping: "default_shipping",
+	models.AddressBilling:  "default_billing",
+}
+
+// ListByUser returns the user's addresses, the oldest first
+func (r *addressRepo) ListByUser(ctx context.Context, userID int) ([]models.Address, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "select "+addressColumns+" from addresses where user_id=? order by id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addresses []models.Address
+	for rows.Next() {
+		address, err := scanAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, *address)
+	}
+	return addresses, rows.Err()
+}
+
+func (r *addressRepo) GetByID(ctx context.Context, userID, id int) (*models.Address, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	address, err := scanAddress(r.db.QueryRowContext(ctx, "select "+addressColumns+" from addresses where id=? and user_id=?", id, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAddressNotFound
+		}
+		return nil, err
+	}
+	return address, nil
+}
+
+func (r *addressRepo) Create(ctx context.Context, address *models.Address) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into addresses (user_id, name, company, line1, line2, city, region, postcode, country, phone, default_shipping, default_billing) " +
+		"values (?,?,?,?,?,?,?,?,?,?,?,?)"
+	a := address.PostalAddress
+	result, err := r.db.ExecContext(ctx, query, address.UserID, a.Name, a.Company, a.Line1, a.Line2, a.City, a.Region, a.Postcode, a.Country, a.Phone,
+		address.DefaultShipping, address.DefaultBilling)
+	if err != nil {
+		return fmt.Errorf("failed to insert address: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		address.ID = int(id)
+	}
+	return nil
+}
+
+func (r *addressRepo) Update(ctx context.Context, address *models.Address) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update addresses set name=?, company=?, line1=?, line2=?, city=?, region=?, postcode=?, country=?, phone=?, default_shipping=?, default_billing=? " +
+		"where id=? and user_id=?"
+	a := address.PostalAddress
+	_, err := r.db.ExecContext(ctx, query, a.Name, a.Company, a.Line1, a.Line2, a.City, a.Region, a.Postcode, a.Country, a.Phone,
+		address.DefaultShipping, address.DefaultBilling, address.ID, address.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to update address: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the address, orders keep their copy of it
+func (r *addressRepo) Delete(ctx context.Context, userID, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "delete from addresses where id=? and user_id=?", id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete address: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrAddressNotFound
+	}
+	return nil
+}
+
+func (r *addressRepo) ClearDefault(ctx context.Context, userID int, kind models.AddressKind) error {
+	column, ok := defaultColumns[kind]
+	if !ok {
+		return fmt.Errorf("unknown address kind %q", kind)
+	}
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, "update addresses set "+column+"=false where user_id=? and "+column, userID); err != nil {
+		return fmt.Errorf("failed to clear default %s address: %w", kind, err)
+	}
+	return nil
+}
+
+func scanAddress(row rowScanner) (*models.Address, error) {
+	var address models.Address
+	a := &address.PostalAddress
+	err := row.Scan(&address.ID, &address.UserID, &a.Name, &a.Company, &a.Line1, &a.Line2, &a.City, &a.Region, &a.Postcode, &a.Country, &a.Phone,
+		&address.DefaultShipping, &address.DefaultBilling, &address.CreatedAt, &address.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"ecommerce/models"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAddress(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewAddressRepo(db)
+
+	now := time.Now()
+	columns := []string{"id", "user_id", "name", "company", "line1", "line2", "city", "region", "postcode", "country", "phone",
+		"default_shipping", "default_billing", "created_at", "updated_at"}
+	mock.ExpectQuery(regexp.QuoteMeta("select "+addressColumns+" from addresses where id=? and user_id=?")).
+		WithArgs(3, 7).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(3, 7, "Grace Hopper", "", "350 Fifth Avenue", "Floor 20", "New York", "NY", "10118", "US", "+1 212 555 0100", true, false, now, now))
+
+	address, err := repo.GetByID(context.Background(), 7, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "Floor 20", address.Line2)
+	assert.Equal(t, models.Destination{Country: "US", Region: "NY", Postcode: "10118"}, address.Destination())
+	assert.True(t, address.DefaultShipping)
+
+	mock.ExpectQuery(regexp.QuoteMeta("select "+addressColumns+" from addresses where id=? and user_id=?")).
+		WithArgs(3, 8).
+		WillReturnRows(sqlmock.NewRows(columns))
+	_, err = repo.GetByID(context.Background(), 8, 3)
+	assert.ErrorIs(t, err, ErrAddressNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateAddress(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewAddressRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("insert into addresses (user_id, name, company, line1, line2, city, region, postcode, country, phone, "+
+		"default_shipping, default_billing) values (?,?,?,?,?,?,?,?,?,?,?,?)")).
+		WithArgs(7, "Grace Hopper", "", "350 Fifth Avenue", "", "New York", "NY", "10118", "US", "", true, true).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+
+	address := &models.Address{UserID: 7, DefaultShipping: true, DefaultBilling: true, PostalAddress: models.PostalAddress{
+		Name: "Grace Hopper", Line1: "350 Fifth Avenue", City: "New York", Region: "NY", Postcode: "10118", Country: "US"}}
+	assert.NoError(t, repo.Create(context.Background(), address))
+	assert.Equal(t, 3, address.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClearDefaultAddress(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewAddressRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("update addresses set default_billing=false where user_id=? and default_billing")).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, repo.ClearDefault(context.Background(), 7, models.AddressBilling))
+	assert.Error(t, repo.ClearDefault(context.Background(), 7, "home"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteAddress(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewAddressRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("delete from addresses where id=? and user_id=?")).
+		WithArgs(3, 8).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.ErrorIs(t, repo.Delete(context.Background(), 8, 3), ErrAddressNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrCartNotFound     = errors.New("cart not found")
+	ErrCartItemNotFound = errors.New("cart item not found")
+)
+
+type CartRepo interface {
+	Create(ctx context.Context, cart *models.Cart) error
+	GetByUser(ctx context.Context, userID int) (*models.Cart, error)
+	GetByTokenHash(ctx context.Context, tokenHash string) (*models.Cart, error)
+	Touch(ctx context.Context, cartID int, expiresAt *time.Time) error
+	AssignUser(ctx context.Context, cartID, userID int) error
+	Delete(ctx context.Context, cartID int) error
+	DeleteExpired(ctx context.Context) error
+
+	ListItems(ctx context.Context, cartID int) ([]models.CartItem, error)
+	AddItem(ctx context.Context, item *models.CartItem) error
+	UpdateItem(ctx context.Context, item *models.CartItem) error
+	DeleteItem(ctx context.Context, cartID, itemID int) error
+	ClearItems(ctx context.Context, cartID int) error
+}
+
+type cartRepo struct {
+	db DBTX
+}
+
+func NewCartRepo(db DBTX) CartRepo {
+	return &cartRepo{db: db}
+}
+
+const cartColumns = "id, user_id, token_hash, currency, expires_at"
+
+func (r *cartRepo) Create(ctx context.Context, cart *models.Cart) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into carts (user_id, token_hash, currency, expires_at) values (?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, nullableID(cart.UserID), nullableString(cart.TokenHash), cart.Currency, cart.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert cart: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		cart.ID = int(id)
+	}
+	return nil
+}
+
+func (r *cartRepo) GetByUser(ctx context.Context, userID int) (*models.Cart, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	return r.getCart(ctx, "select "+cartColumns+" from carts where user_id=?", userID)
+}
+
+// GetByTokenHash finds a guest cart, expired ones are treated as gone
+func (r *cartRepo) GetByTokenHash(ctx context.Context, tokenHash string) (*models.Cart, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select " + cartColumns + " from carts where token_hash=? and user_id is null and expires_at > ?"
+	return r.getCart(ctx, query, tokenHash, time.Now())
+}
+
+// Touch records activity on the cart and moves a guest cart's expiry. The
+// update locks the cart row, so changes to one cart are applied one at a
+// time when run in a transaction.
+func (r *cartRepo) Touch(ctx context.Context, cartID int, expiresAt *time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update carts set expires_at=?, updated_at=? where id=?"
+	result, err := r.db.ExecContext(ctx, query, expiresAt, time.Now(), cartID)
+	if err != nil {
+		return fmt.Errorf("failed to update cart: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrCartNotFound
+	}
+	return nil
+}
+
+// AssignUser turns a guest cart into the user's cart, the guest token stops working
+func (r *cartRepo) AssignUser(ctx context.Context, cartID, userID int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update carts set user_id=?, token_hash=null, expires_at=null where id=?"
+	if _, err := r.db.ExecContext(ctx, query, userID, cartID); err != nil {
+		return fmt.Errorf("failed to assign cart: %w", err)
+	}
+	return nil
+}
+
+func (r *cartRepo) Delete(ctx context.Context, cartID int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, "delete from carts where id=?", cartID); err != nil {
+		return fmt.Errorf("failed to delete cart: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired drops abandoned guest carts, their items go with them
+func (r *cartRepo) DeleteExpired(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, "delete from carts where user_id is null and expires_at < ?", time.Now())
+	return err
+}
+
+// ListItems returns the lines in the order they were added, priced at the
+// current variant price or, without one, the product price. The saved price
+// is in the cart's currency.
+func (r *cartRepo) ListItems(ctx context.Context, cartID int) ([]models.CartItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select ci.id, ci.cart_id, ci.product_id, ci.variant_id, p.name, coalesce(v.sku, ''), p.tax_class, p.weight_grams, " +
+		"p.length_mm, p.width_mm, p.height_mm, ci.quantity, coalesce(v.price, p.price), coalesce(v.currency, p.currency), ci.unit_price, c.currency from cart_items ci " +
+		"join carts c on c.id = ci.cart_id join products p on p.id = ci.product_id left join product_variants v on v.id = ci.variant_id " +
+		"where ci.cart_id=? order by ci.id"
+	rows, err := r.db.QueryContext(ctx, query, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.CartItem
+	for rows.Next() {
+		var item models.CartItem
+		var variantID sql.NullInt64
+		err := rows.Scan(&item.ID, &item.CartID, &item.ProductID, &variantID, &item.Name, &item.SKU, &item.TaxClass, &item.Weight,
+			&item.Dimensions.Length, &item.Dimensions.Width, &item.Dimensions.Height, &item.Quantity, &item.UnitPrice.Amount, &item.UnitPrice.Currency, &item.SavedPrice.Amount, &item.SavedPrice.Currency)
+		if err != nil {
+			return nil, err
+		}
+		if variantID.Valid {
+			id := int(variantID.Int64)
+			item.VariantID = &id
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// AddItem inserts a line saved at item.UnitPrice
+func (r *cartRepo) AddItem(ctx context.Context, item *models.CartItem) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into cart_items (cart_id, product_id, variant_id, quantity, unit_price) values (?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, item.CartID, item.ProductID, item.VariantID, item.Quantity, item.UnitPrice.Amount)
+	if err != nil {
+		return fmt.Errorf("failed to insert cart item: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		item.ID = int(id)
+	}
+	item.SavedPrice = item.UnitPrice
+	return nil
+}
+
+// UpdateItem sets the quantity and saves the line at item.UnitPrice
+func (r *cartRepo) UpdateItem(ctx context.Context, item *models.CartItem) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update cart_items set quantity=?, unit_price=? where id=? and cart_id=?"
+	result, err := r.db.ExecContext(ctx, query, item.Quantity, item.UnitPrice.Amount, item.ID, item.CartID)
+	if err != nil {
+		return fmt.Errorf("failed to update cart item: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrCartItemNotFound
+	}
+	item.SavedPrice = item.UnitPrice
+	return nil
+}
+
+func (r *cartRepo) DeleteItem(ctx context.Context, cartID, itemID int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "delete from cart_items where id=? and cart_id=?", itemID, cartID)
+	if err != nil {
+		return fmt.Errorf("failed to delete cart item: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrCartItemNotFound
+	}
+	return nil
+}
+
+func (r *cartRepo) ClearItems(ctx context.Context, cartID int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, "delete from cart_items where cart_id=?", cartID); err != nil {
+		return fmt.Errorf("failed to clear cart: %w", err)
+	}
+	return nil
+}
+
+func (r *cartRepo) getCart(ctx context.Context, query string, args ...interface{}) (*models.Cart, error) {
+	var cart models.Cart
+	var userID sql.NullInt64
+	var tokenHash sql.NullString
+	var expiresAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&cart.ID, &userID, &tokenHash, &cart.Currency, &expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCartNotFound
+		}
+		return nil, err
+	}
+	cart.UserID = int(userID.Int64)
+	cart.TokenHash = tokenHash.String
+	if expiresAt.Valid {
+		cart.ExpiresAt = &expiresAt.Time
+	}
+	return &cart, nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateGuestCart(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewCartRepo(db)
+
+	cart := &models.Cart{TokenHash: "hash", Currency: "USD"}
+	mock.ExpectExec("insert into carts").
+		WithArgs(nil, "hash", "USD", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+
+	assert.NoError(t, repo.Create(context.Background(), cart))
+	assert.Equal(t, 4, cart.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetCartByTokenHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewCartRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("from carts where token_hash=? and user_id is null and expires_at > ?")).
+		WithArgs("hash", sqlmock.AnyArg()).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.GetByTokenHash(context.Background(), "hash")
+	assert.Equal(t, ErrCartNotFound, err)
+}
+
+func TestListCartItems(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewCartRepo(db)
+
+	columns := []string{"id", "cart_id", "product_id", "variant_id", "name", "sku", "tax_class", "weight_grams", "length_mm", "width_mm", "height_mm", "quantity", "price", "currency", "unit_price", "cart_currency"}
+	mock.ExpectQuery(regexp.QuoteMeta("coalesce(v.price, p.price), coalesce(v.currency, p.currency), ci.unit_price, c.currency from cart_items ci")).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, 3, 1, nil, "Mug", "", "standard", 350, 120, 90, 100, 2, 950, "USD", 950, "USD").
+			AddRow(2, 3, 2, 4, "Shirt", "SHIRT-M", "reduced", 200, 0, 0, 0, 1, 2500, "USD", 2250, "USD"))
+
+	items, err := repo.ListItems(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.Nil(t, items[0].VariantID)
+	assert.Equal(t, 4, *items[1].VariantID)
+	assert.Equal(t, "reduced", items[1].TaxClass)
+	assert.Equal(t, 350, items[0].Weight)
+	assert.Equal(t, models.Dimensions{Length: 120, Width: 90, Height: 100}, items[0].Dimensions)
+	assert.False(t, items[0].PriceChanged())
+	assert.True(t, items[1].PriceChanged())
+	lineTotal, err := items[0].LineTotal()
+	assert.NoError(t, err)
+	assert.Equal(t, models.NewMoney(1900, "USD"), lineTotal)
+}
+
+func TestDeleteCartItem(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewCartRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("delete from cart_items where id=? and cart_id=?")).
+		WithArgs(8, 3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.Equal(t, ErrCartItemNotFound, repo.DeleteItem(context.Background(), 3, 8))
+}
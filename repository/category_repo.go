@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrCategoryNotFound = errors.New("category not found")
+
+type CategoryRepo interface {
+	Create(ctx context.Context, category *models.Category) error
+	GetByID(ctx context.Context, id int) (*models.Category, error)
+	GetAll(ctx context.Context) ([]models.Category, error)
+	Update(ctx context.Context, category *models.Category) error
+	Delete(ctx context.Context, id int) error
+	HasChildren(ctx context.Context, id int) (bool, error)
+	DescendantIDs(ctx context.Context, id int) ([]int, error)
+	GetProducts(ctx context.Context, categoryID int, includeDescendants bool) ([]models.Product, error)
+	GetProductCategories(ctx context.Context, productID int) ([]models.Category, error)
+	ProductCategoryIDs(ctx context.Context, productIDs []int) (map[int][]int, error)
+	SetProductCategories(ctx context.Context, productID int, categoryIDs []int) error
+}
+
+type categoryRepo struct {
+	db DBTX
+}
+
+func NewCategoryRepo(db DBTX) CategoryRepo {
+	return &categoryRepo{db: db}
+}
+
+// descendantsCTE expands a category id into itself and all of its subcategories
+const descendantsCTE = `with recursive tree (id) as (
+	select id from categories where id = ?
+	union all
+	select c.id from categories c join tree on c.parent_id = tree.id
+) `
+
+func (r *categoryRepo) Create(ctx context.Context, category *models.Category) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into categories (name, slug, parent_id) values (?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, category.Name, category.Slug, category.ParentID)
+	if err != nil {
+		return fmt.Errorf("failed to insert category: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		category.ID = int(id)
+	}
+	return nil
+}
+
+func (r *categoryRepo) GetByID(ctx context.Context, id int) (*models.Category, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select id, name, slug, parent_id from categories where id=?"
+	category, err := scanCategory(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, err
+	}
+	return category, nil
+}
+
+func (r *categoryRepo) GetAll(ctx context.Context) ([]models.Category, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "select id, name, slug, parent_id from categories order by name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanCategories(rows)
+}
+
+func (r *categoryRepo) Update(ctx context.Context, category *models.Category) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update categories set name=?, slug=?, parent_id=? where id=?"
+	_, err := r.db.ExecContext(ctx, query, category.Name, category.Slug, category.ParentID, category.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update category: %w", err)
+	}
+	return nil
+}
+
+func (r *categoryRepo) Delete(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "delete from categories where id=?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+func (r *categoryRepo) HasChildren(ctx context.Context, id int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, "select count(*) from categories where parent_id=?", id).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// DescendantIDs returns id followed by the ids of every category below it
+func (r *categoryRepo) DescendantIDs(ctx context.Context, id int) ([]int, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, descendantsCTE+"select id from tree", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var descendant int
+		if err := rows.Scan(&descendant); err != nil {
+			return nil, err
+		}
+		ids = append(ids, descendant)
+	}
+	return ids, rows.Err()
+}
+
+// GetProducts lists the products assigned to a category, or to the category
+// and any of its subcategories when includeDescendants is set
+func (r *categoryRepo) GetProducts(ctx context.Context, categoryID int, includeDescendants bool) ([]models.Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	query := "select distinct p.id, p.name, p.price, p.currency, p.tax_class, p.weight_grams, p.length_mm, p.width_mm, p.height_mm, p.created_by, p.updated_by from products p " +
+		"join product_categories pc on pc.product_id = p.id where pc.category_id = ? order by p.id"
+	if includeDescendants {
+		query = descendantsCTE + "select distinct p.id, p.name, p.price, p.currency, p.tax_class, p.weight_grams, p.length_mm, p.width_mm, p.height_mm, p.created_by, p.updated_by from products p " +
+			"join product_categories pc on pc.product_id = p.id join tree on tree.id = pc.category_id order by p.id"
+	}
+	rows, err := r.db.QueryContext(ctx, query, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanProducts(rows)
+}
+
+func (r *categoryRepo) GetProductCategories(ctx context.Context, productID int) ([]models.Category, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select c.id, c.name, c.slug, c.parent_id from categories c " +
+		"join product_categories pc on pc.category_id = c.id where pc.product_id = ? order by c.name"
+	rows, err := r.db.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanCategories(rows)
+}
+
+// ProductCategoryIDs returns the ids of the categories each product is in,
+// including every category above those: a product in T-shirts is in
+// Clothing too. Products without a category are left out.
+func (r *categoryRepo) ProductCategoryIDs(ctx context.Context, productIDs []int) (map[int][]int, error) {
+	categoryIDs := map[int][]int{}
+	if len(productIDs) == 0 {
+		return categoryIDs, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	placeholders, args := inClause(productIDs)
+	query := `with recursive tree (product_id, id) as (
+	select product_id, category_id from product_categories where product_id in (` + placeholders + `)
+	union
+	select tree.product_id, c.parent_id from categories c join tree on c.id = tree.id where c.parent_id is not null
+) select product_id, id from tree order by product_id, id`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productID, categoryID int
+		if err := rows.Scan(&productID, &categoryID); err != nil {
+			return nil, err
+		}
+		categoryIDs[productID] = append(categoryIDs[productID], categoryID)
+	}
+	return categoryIDs, rows.Err()
+}
+
+// SetProductCategories replaces the categories of a product, run it in a
+// transaction so the product is never seen without its categories
+func (r *categoryRepo) SetProductCategories(ctx context.Context, productID int, categoryIDs []int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, "delete from product_categories where product_id=?", productID); err != nil {
+		return fmt.Errorf("failed to clear product categories: %w", err)
+	}
+	if len(categoryIDs) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(categoryIDs))
+	args := make([]interface{}, 0, 2*len(categoryIDs))
+	for i, categoryID := range categoryIDs {
+		placeholders[i] = "(?,?)"
+		args = append(args, productID, categoryID)
+	}
+	query := "insert into product_categories (product_id, category_id) values " + strings.Join(placeholders, ",")
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to assign product categories: %w", err)
+	}
+	return nil
+}
+
+func scanCategory(row rowScanner) (*models.Category, error) {
+	var category models.Category
+	var parentID sql.NullInt64
+	if err := row.Scan(&category.ID, &category.Name, &category.Slug, &parentID); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		parent := int(parentID.Int64)
+		category.ParentID = &parent
+	}
+	return &category, nil
+}
+
+func scanCategories(rows *sql.Rows) ([]models.Category, error) {
+	var categories []models.Category
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *category)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
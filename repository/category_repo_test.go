@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateCategory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewCategoryRepo(db)
+
+	parent := 1
+	category := &models.Category{Name: "Laptops", Slug: "laptops", ParentID: &parent}
+	mock.ExpectExec("insert into categories").
+		WithArgs("Laptops", "laptops", 1).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+
+	err = repo.Create(context.Background(), category)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, category.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetByIDCategory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewCategoryRepo(db)
+
+	t.Run("Root", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta("select id, name, slug, parent_id from categories where id=?")).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "slug", "parent_id"}).AddRow(1, "Electronics", "electronics", nil))
+
+		category, err := repo.GetByID(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Nil(t, category.ParentID)
+	})
+	t.Run("Child", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta("select id, name, slug, parent_id from categories where id=?")).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "slug", "parent_id"}).AddRow(2, "Laptops", "laptops", 1))
+
+		category, err := repo.GetByID(context.Background(), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, *category.ParentID)
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta("select id, name, slug, parent_id from categories where id=?")).
+			WithArgs(9).
+			WillReturnError(sql.ErrNoRows)
+
+		category, err := repo.GetByID(context.Background(), 9)
+		assert.Equal(t, ErrCategoryNotFound, err)
+		assert.Nil(t, category)
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDescendantIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewCategoryRepo(db)
+
+	mock.ExpectQuery("with recursive tree").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(5))
+
+	ids, err := repo.DescendantIDs(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 5}, ids)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProductCategoryIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewCategoryRepo(db)
+
+	mock.ExpectQuery("with recursive tree").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "id"}).AddRow(1, 1).AddRow(1, 2).AddRow(1, 5))
+
+	ids, err := repo.ProductCategoryIDs(context.Background(), []int{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int][]int{1: {1, 2, 5}}, ids)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetCategoryProducts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewCategoryRepo(db)
+	columns := []string{"id", "name", "price", "currency", "tax_class", "weight_grams", "length_mm", "width_mm", "height_mm", "created_by", "updated_by"}
+
+	t.Run("Direct", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta("join product_categories pc on pc.product_id = p.id where pc.category_id = ?")).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "Laptop", 6100000, "USD", "standard", 0, 0, 0, 0, 1, nil))
+
+		products, err := repo.GetProducts(context.Background(), 2, false)
+		assert.NoError(t, err)
+		assert.Len(t, products, 1)
+		assert.Equal(t, 0, products[0].UpdatedBy) // NULL after the editor was deleted
+	})
+	t.Run("With descendants", func(t *testing.T) {
+		mock.ExpectQuery("with recursive tree .* join tree on tree.id = pc.category_id").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "Laptop", 6100000, "USD", "standard", 0, 0, 0, 0, 1, 1).AddRow(2, "Phone", 2000000, "USD", "standard", 0, 0, 0, 0, 1, 1))
+
+		products, err := repo.GetProducts(context.Background(), 1, true)
+		assert.NoError(t, err)
+		assert.Len(t, products, 2)
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetProductCategories(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewCategoryRepo(db)
+
+	t.Run("Replace", func(t *testing.T) {
+		mock.ExpectExec("delete from product_categories where product_id=?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec(regexp.QuoteMeta("insert into product_categories (product_id, category_id) values (?,?),(?,?)")).
+			WithArgs(1, 2, 1, 5).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		err := repo.SetProductCategories(context.Background(), 1, []int{2, 5})
+		assert.NoError(t, err)
+	})
+	t.Run("Clear", func(t *testing.T) {
+		mock.ExpectExec("delete from product_categories where product_id=?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 2))
+
+		err := repo.SetProductCategories(context.Background(), 1, nil)
+		assert.NoError(t, err)
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteCategory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewCategoryRepo(db)
+
+	mock.ExpectExec("delete from categories where id=?").WithArgs(9).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.Delete(context.Background(), 9)
+	assert.Equal(t, ErrCategoryNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
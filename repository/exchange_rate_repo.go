@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrExchangeRateNotFound = errors.New("exchange rate not found")
+
+// ExchangeRateRepo keeps the history of exchange rates, rows are only added
+type ExchangeRateRepo interface {
+	Create(ctx context.Context, rate *models.ExchangeRate) error
+	GetEffective(ctx context.Context, from, to string, at time.Time) (*models.ExchangeRate, error)
+	List(ctx context.Context, filter models.ExchangeRateFilter) ([]models.ExchangeRate, error)
+}
+
+type exchangeRateRepo struct {
+	db DBTX
+}
+
+func NewExchangeRateRepo(db DBTX) ExchangeRateRepo {
+	return &exchangeRateRepo{db: db}
+}
+
+const exchangeRateColumns = "id, base, quote, rate, effective_at, created_by, created_at"
+
+func (r *exchangeRateRepo) Create(ctx context.Context, rate *models.ExchangeRate) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into exchange_rates (base, quote, rate, effective_at, created_by) values (?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, rate.Base, rate.Quote, rate.Rate.String(), rate.EffectiveAt, nullableID(rate.CreatedBy))
+	if err != nil {
+		return fmt.Errorf("failed to insert exchange rate: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		rate.ID = int(id)
+	}
+	return nil
+}
+
+// GetEffective returns the rate between from and to in effect at the given
+// time. A rate stored the other way round (to/from) is just as good, the
+// latest of either direction wins.
+func (r *exchangeRateRepo) GetEffective(ctx context.Context, from, to string, at time.Time) (*models.ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select " + exchangeRateColumns + " from exchange_rates " +
+		"where ((base=? and quote=?) or (base=? and quote=?)) and effective_at <= ? order by effective_at desc, id desc limit 1"
+	rate, err := scanExchangeRate(r.db.QueryRowContext(ctx, query, from, to, to, from, at))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrExchangeRateNotFound
+		}
+		return nil, err
+	}
+	return rate, nil
+}
+
+// List returns the rate history, newest first within each pair
+func (r *exchangeRateRepo) List(ctx context.Context, filter models.ExchangeRateFilter) ([]models.ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	var conditions []string
+	var args []interface{}
+	if filter.Base != "" {
+		conditions = append(conditions, "base=?")
+		args = append(args, filter.Base)
+	}
+	if filter.Quote != "" {
+		conditions = append(conditions, "quote=?")
+		args = append(args, filter.Quote)
+	}
+	query := "select " + exchangeRateColumns + " from exchange_rates"
+	if len(conditions) > 0 {
+		query += " where " + strings.Join(conditions, " and ")
+	}
+	query += " order by base, quote, effective_at desc, id desc"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []models.ExchangeRate
+	for rows.Next() {
+		rate, err := scanExchangeRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, *rate)
+	}
+	return rates, rows.Err()
+}
+
+func scanExchangeRate(row rowScanner) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	var value string
+	var createdBy sql.NullInt64
+	if err := row.Scan(&rate.ID, &rate.Base, &rate.Quote, &value, &rate.EffectiveAt, &createdBy, &rate.CreatedAt); err != nil {
+		return nil, err
+	}
+	parsed, err := models.ParseRate(value)
+	if err != nil {
+		return nil, err
+	}
+	rate.Rate = parsed
+	rate.CreatedBy = int(createdBy.Int64)
+	return &rate, nil
+}
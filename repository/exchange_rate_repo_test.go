@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateExchangeRate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewExchangeRateRepo(db)
+
+	effective := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec("insert into exchange_rates").
+		WithArgs("USD", "EUR", "0.92", effective, 2).
+		WillReturnResult(sqlmock.NewResult(5, 1))
+
+	rate := &models.ExchangeRate{Base: "USD", Quote: "EUR", Rate: 92000000, EffectiveAt: effective, CreatedBy: 2}
+	assert.NoError(t, repo.Create(context.Background(), rate))
+	assert.Equal(t, 5, rate.ID)
+}
+
+func TestGetEffectiveExchangeRate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewExchangeRateRepo(db)
+
+	at := time.Now()
+	query := regexp.QuoteMeta("where ((base=? and quote=?) or (base=? and quote=?)) and effective_at <= ? order by effective_at desc, id desc limit 1")
+	mock.ExpectQuery(query).
+		WithArgs("EUR", "USD", "USD", "EUR", at).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "base", "quote", "rate", "effective_at", "created_by", "created_at"}).
+			AddRow(5, "USD", "EUR", "0.92000000", at, nil, at))
+	mock.ExpectQuery(query).
+		WithArgs("JPY", "USD", "USD", "JPY", at).
+		WillReturnError(sql.ErrNoRows)
+
+	rate, err := repo.GetEffective(context.Background(), "EUR", "USD", at)
+	assert.NoError(t, err)
+	assert.Equal(t, models.Rate(92000000), rate.Rate)
+	assert.Equal(t, 0, rate.CreatedBy)
+
+	_, err = repo.GetEffective(context.Background(), "JPY", "USD", at)
+	assert.Equal(t, ErrExchangeRateNotFound, err)
+}
+
+func TestListExchangeRates(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewExchangeRateRepo(db)
+
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta("from exchange_rates where base=? order by base, quote, effective_at desc, id desc")).
+		WithArgs("USD").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "base", "quote", "rate", "effective_at", "created_by", "created_at"}).
+			AddRow(6, "USD", "GBP", "0.79", now, 2, now).
+			AddRow(5, "USD", "EUR", "0.92", now, 2, now))
+
+	rates, err := repo.List(context.Background(), models.ExchangeRateFilter{Base: "USD"})
+	assert.NoError(t, err)
+	assert.Len(t, rates, 2)
+	assert.Equal(t, "0.79", rates[0].Rate.String())
+}
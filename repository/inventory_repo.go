@@ -0,0 +1,340 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInventoryItemNotFound = errors.New("inventory item not found")
+	ErrInsufficientStock     = errors.New("insufficient stock")
+)
+
+// InventoryRepo keeps on hand and reserved quantities. The quantity updates
+// are single conditional statements, so concurrent requests can't oversell.
+type InventoryRepo interface {
+	GetItem(ctx context.Context, warehouseID, productID int, variantID *int) (*models.InventoryItem, error)
+	EnsureItem(ctx context.Context, warehouseID, productID int, variantID *int) (*models.InventoryItem, error)
+	ListItems(ctx context.Context, productID int) ([]models.InventoryItem, error)
+	ListAllocatable(ctx context.Context, productID int, variantID *int) ([]models.InventoryItem, error)
+
+	AdjustOnHand(ctx context.Context, itemID, delta int) (*models.InventoryItem, error)
+	Reserve(ctx context.Context, itemID, quantity int) error
+	Release(ctx context.Context, itemID, quantity int) error
+	Commit(ctx context.Context, itemID, quantity int) (*models.InventoryItem, error)
+
+	CreateReservation(ctx context.Context, reservation *models.Reservation) error
+	ActiveReservations(ctx context.Context, reference string) ([]models.Reservation, error)
+	SetReservationStatus(ctx context.Context, id int, status models.ReservationStatus) error
+
+	AddAdjustment(ctx context.Context, adjustment *models.InventoryAdjustment) error
+	ListAdjustments(ctx context.Context, productID, limit int) ([]models.InventoryAdjustment, error)
+
+	CreateTransfer(ctx context.Context, transfer *models.Transfer) error
+}
+
+type inventoryRepo struct {
+	db DBTX
+}
+
+func NewInventoryRepo(db DBTX) InventoryRepo {
+	return &inventoryRepo{db: db}
+}
+
+const itemSelect = "select i.id, i.warehouse_id, i.product_id, i.variant_id, coalesce(v.sku, ''), i.on_hand, i.reserved " +
+	"from inventory_items i left join product_variants v on v.id = i.variant_id "
+
+// GetItem looks up the stock of a product (variantID nil) or of one of its variants in a warehouse
+func (r *inventoryRepo) GetItem(ctx context.Context, warehouseID, productID int, variantID *int) (*models.InventoryItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := itemSelect + "where i.warehouse_id=? and i.product_id=? and i.variant_id <=> ?"
+	return r.getItem(ctx, query, warehouseID, productID, variantID)
+}
+
+// EnsureItem returns the item, creating it with no stock the first time. It
+// locks the product row so two first adjustments can't both insert the
+// product level item (NULL variant_id never collides in the unique key),
+// run it in a transaction.
+func (r *inventoryRepo) EnsureItem(ctx context.Context, warehouseID, productID int, variantID *int) (*models.InventoryItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	var id int
+	if err := r.db.QueryRowContext(ctx, "select id from warehouses where id=?", warehouseID).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWarehouseNotFound
+		}
+		return nil, err
+	}
+	if err := r.db.QueryRowContext(ctx, "select id from products where id=? for update", productID).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	if variantID != nil {
+		err := r.db.QueryRowContext(ctx, "select id from product_variants where id=? and product_id=?", *variantID, productID).Scan(&id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, ErrVariantNotFound
+			}
+			return nil, err
+		}
+	}
+
+	item, err := r.GetItem(ctx, warehouseID, productID, variantID)
+	if !errors.Is(err, ErrInventoryItemNotFound) {
+		return item, err
+	}
+	query := "insert into inventory_items (warehouse_id, product_id, variant_id) values (?,?,?)"
+	if _, err := r.db.ExecContext(ctx, query, warehouseID, productID, variantID); err != nil {
+		return nil, fmt.Errorf("failed to insert inventory item: %w", err)
+	}
+	return r.GetItem(ctx, warehouseID, productID, variantID)
+}
+
+// ListItems returns the product level items first, then the variants' items,
+// each in warehouse order
+func (r *inventoryRepo) ListItems(ctx context.Context, productID int) ([]models.InventoryItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := itemSelect + "where i.product_id=? order by i.variant_id is not null, i.variant_id, i.warehouse_id"
+	return r.listItems(ctx, query, productID)
+}
+
+// ListAllocatable returns the items of a product or variant in active warehouses
+func (r *inventoryRepo) ListAllocatable(ctx context.Context, productID int, variantID *int) ([]models.InventoryItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := itemSelect + "join warehouses w on w.id = i.warehouse_id " +
+		"where w.active and i.product_id=? and i.variant_id <=> ? order by i.warehouse_id"
+	return r.listItems(ctx, query, productID, variantID)
+}
+
+// AdjustOnHand adds delta to the on hand quantity, it may not drop below what is reserved
+func (r *inventoryRepo) AdjustOnHand(ctx context.Context, itemID, delta int) (*models.InventoryItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update inventory_items set on_hand = on_hand + ? where id=? and on_hand + ? >= reserved"
+	if err := r.update(ctx, itemID, query, delta, itemID, delta); err != nil {
+		return nil, err
+	}
+	return r.getItem(ctx, itemSelect+"where i.id=?", itemID)
+}
+
+// Reserve holds quantity of the available stock
+func (r *inventoryRepo) Reserve(ctx context.Context, itemID, quantity int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update inventory_items set reserved = reserved + ? where id=? and on_hand - reserved >= ?"
+	return r.update(ctx, itemID, query, quantity, itemID, quantity)
+}
+
+// Release gives reserved stock back to available
+func (r *inventoryRepo) Release(ctx context.Context, itemID, quantity int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update inventory_items set reserved = reserved - ? where id=? and reserved >= ?"
+	return r.update(ctx, itemID, query, quantity, itemID, quantity)
+}
+
+// Commit takes reserved stock out of on hand once it has left with an order
+func (r *inventoryRepo) Commit(ctx context.Context, itemID, quantity int) (*models.InventoryItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update inventory_items set on_hand = on_hand - ?, reserved = reserved - ? where id=? and reserved >= ?"
+	if err := r.update(ctx, itemID, query, quantity, quantity, itemID, quantity); err != nil {
+		return nil, err
+	}
+	return r.getItem(ctx, itemSelect+"where i.id=?", itemID)
+}
+
+func (r *inventoryRepo) CreateReservation(ctx context.Context, reservation *models.Reservation) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	if reservation.Status == "" {
+		reservation.Status = models.ReservationActive
+	}
+	query := "insert into inventory_reservations (reference, item_id, quantity, status) values (?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, reservation.Reference, reservation.ItemID, reservation.Quantity, reservation.Status)
+	if err != nil {
+		return fmt.Errorf("failed to insert reservation: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		reservation.ID = int(id)
+	}
+	return nil
+}
+
+// ActiveReservations locks the active reservations of reference, in item
+// order so concurrent release and commit take the row locks the same way
+func (r *inventoryRepo) ActiveReservations(ctx context.Context, reference string) ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select id, reference, item_id, quantity, status, created_at from inventory_reservations " +
+		"where reference=? and status=? order by item_id, id for update"
+	rows, err := r.db.QueryContext(ctx, query, reference, models.ReservationActive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []models.Reservation
+	for rows.Next() {
+		var reservation models.Reservation
+		if err := rows.Scan(&reservation.ID, &reservation.Reference, &reservation.ItemID, &reservation.Quantity, &reservation.Status, &reservation.CreatedAt); err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, reservation)
+	}
+	return reservations, rows.Err()
+}
+
+func (r *inventoryRepo) SetReservationStatus(ctx context.Context, id int, status models.ReservationStatus) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, "update inventory_reservations set status=? where id=?", status, id); err != nil {
+		return fmt.Errorf("failed to update reservation: %w", err)
+	}
+	return nil
+}
+
+func (r *inventoryRepo) AddAdjustment(ctx context.Context, adjustment *models.InventoryAdjustment) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into inventory_adjustments (item_id, delta, on_hand_after, reason, reference, note, created_by) values (?,?,?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, adjustment.ItemID, adjustment.Delta, adjustment.OnHandAfter, adjustment.Reason,
+		nullableString(adjustment.Reference), nullableString(adjustment.Note), nullableID(adjustment.CreatedBy))
+	if err != nil {
+		return fmt.Errorf("failed to insert inventory adjustment: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		adjustment.ID = int(id)
+	}
+	return nil
+}
+
+// ListAdjustments returns the newest ledger entries of a product and its variants
+func (r *inventoryRepo) ListAdjustments(ctx context.Context, productID, limit int) ([]models.InventoryAdjustment, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	query := "select a.id, a.item_id, i.warehouse_id, i.product_id, i.variant_id, a.delta, a.on_hand_after, a.reason, a.reference, a.note, a.created_by, a.created_at " +
+		"from inventory_adjustments a join inventory_items i on i.id = a.item_id where i.product_id=? order by a.id desc limit ?"
+	rows, err := r.db.QueryContext(ctx, query, productID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var adjustments []models.InventoryAdjustment
+	for rows.Next() {
+		var adjustment models.InventoryAdjustment
+		var variantID, createdBy sql.NullInt64
+		var reference, note sql.NullString
+		err := rows.Scan(&adjustment.ID, &adjustment.ItemID, &adjustment.WarehouseID, &adjustment.ProductID, &variantID, &adjustment.Delta, &adjustment.OnHandAfter,
+			&adjustment.Reason, &reference, &note, &createdBy, &adjustment.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if variantID.Valid {
+			id := int(variantID.Int64)
+			adjustment.VariantID = &id
+		}
+		adjustment.Reference = reference.String
+		adjustment.Note = note.String
+		adjustment.CreatedBy = int(createdBy.Int64)
+		adjustments = append(adjustments, adjustment)
+	}
+	return adjustments, rows.Err()
+}
+
+func (r *inventoryRepo) CreateTransfer(ctx context.Context, transfer *models.Transfer) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into warehouse_transfers (product_id, variant_id, from_warehouse_id, to_warehouse_id, quantity, note, created_by) values (?,?,?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, transfer.ProductID, transfer.VariantID, transfer.FromWarehouseID, transfer.ToWarehouseID,
+		transfer.Quantity, nullableString(transfer.Note), nullableID(transfer.CreatedBy))
+	if err != nil {
+		return fmt.Errorf("failed to insert transfer: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		transfer.ID = int(id)
+	}
+	return nil
+}
+
+func (r *inventoryRepo) listItems(ctx context.Context, query string, args ...interface{}) ([]models.InventoryItem, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.InventoryItem
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+func (r *inventoryRepo) getItem(ctx context.Context, query string, args ...interface{}) (*models.InventoryItem, error) {
+	item, err := scanItem(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInventoryItemNotFound
+		}
+		return nil, err
+	}
+	return item, nil
+}
+
+// update runs a guarded quantity update, no row matched means the item is
+// missing or the guard failed
+func (r *inventoryRepo) update(ctx context.Context, itemID int, query string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update inventory: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		var id int
+		if err := r.db.QueryRowContext(ctx, "select id from inventory_items where id=?", itemID).Scan(&id); err == sql.ErrNoRows {
+			return ErrInventoryItemNotFound
+		}
+		return ErrInsufficientStock
+	}
+	return nil
+}
+
+func scanItem(row rowScanner) (*models.InventoryItem, error) {
+	var item models.InventoryItem
+	var variantID sql.NullInt64
+	if err := row.Scan(&item.ID, &item.WarehouseID, &item.ProductID, &variantID, &item.SKU, &item.OnHand, &item.Reserved); err != nil {
+		return nil, err
+	}
+	if variantID.Valid {
+		id := int(variantID.Int64)
+		item.VariantID = &id
+	}
+	return &item, nil
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"ecommerce/models"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var itemColumns = []string{"id", "warehouse_id", "product_id", "variant_id", "sku", "on_hand", "reserved"}
+
+func TestReserveInventory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewInventoryRepo(db)
+
+	reserve := regexp.QuoteMeta("update inventory_items set reserved = reserved + ? where id=? and on_hand - reserved >= ?")
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectExec(reserve).WithArgs(2, 5, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.Reserve(context.Background(), 5, 2))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Insufficient stock", func(t *testing.T) {
+		mock.ExpectExec(reserve).WithArgs(10, 5, 10).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta("select id from inventory_items where id=?")).
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+
+		assert.Equal(t, ErrInsufficientStock, repo.Reserve(context.Background(), 5, 10))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Missing item", func(t *testing.T) {
+		mock.ExpectExec(reserve).WithArgs(1, 9, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta("select id from inventory_items where id=?")).
+			WithArgs(9).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		assert.Equal(t, ErrInventoryItemNotFound, repo.Reserve(context.Background(), 9, 1))
+	})
+}
+
+func TestCommitInventory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewInventoryRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("update inventory_items set on_hand = on_hand - ?, reserved = reserved - ? where id=? and reserved >= ?")).
+		WithArgs(2, 2, 5, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("where i.id=?")).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(itemColumns).AddRow(5, 1, 1, 3, "TS-S", 8, 1))
+
+	item, err := repo.Commit(context.Background(), 5, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 8, item.OnHand)
+	assert.Equal(t, 3, *item.VariantID)
+	assert.Equal(t, 7, item.Available())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnsureItemInventory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewInventoryRepo(db)
+
+	t.Run("Creates the item", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta("select id from warehouses where id=?")).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery(regexp.QuoteMeta("select id from products where id=? for update")).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery(regexp.QuoteMeta("where i.warehouse_id=? and i.product_id=? and i.variant_id <=> ?")).
+			WithArgs(2, 1, nil).
+			WillReturnRows(sqlmock.NewRows(itemColumns))
+		mock.ExpectExec(regexp.QuoteMeta("insert into inventory_items (warehouse_id, product_id, variant_id) values (?,?,?)")).
+			WithArgs(2, 1, nil).
+			WillReturnResult(sqlmock.NewResult(6, 1))
+		mock.ExpectQuery(regexp.QuoteMeta("where i.warehouse_id=? and i.product_id=? and i.variant_id <=> ?")).
+			WithArgs(2, 1, nil).
+			WillReturnRows(sqlmock.NewRows(itemColumns).AddRow(6, 2, 1, nil, "", 0, 0))
+
+		item, err := repo.EnsureItem(context.Background(), 2, 1, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 6, item.ID)
+		assert.Equal(t, 2, item.WarehouseID)
+		assert.Nil(t, item.VariantID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Variant of another product", func(t *testing.T) {
+		variantID := 3
+		mock.ExpectQuery(regexp.QuoteMeta("select id from warehouses where id=?")).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery(regexp.QuoteMeta("select id from products where id=? for update")).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery(regexp.QuoteMeta("select id from product_variants where id=? and product_id=?")).
+			WithArgs(3, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		_, err := repo.EnsureItem(context.Background(), 1, 2, &variantID)
+		assert.Equal(t, ErrVariantNotFound, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestEnsureItemUnknownWarehouse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewInventoryRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("select id from warehouses where id=?")).
+		WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = repo.EnsureItem(context.Background(), 9, 1, nil)
+	assert.Equal(t, ErrWarehouseNotFound, err)
+}
+
+func TestListAllocatableInventory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewInventoryRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("join warehouses w on w.id = i.warehouse_id where w.active and i.product_id=? and i.variant_id <=> ?")).
+		WithArgs(1, 3).
+		WillReturnRows(sqlmock.NewRows(itemColumns).AddRow(5, 1, 1, 3, "TS-S", 8, 1).AddRow(6, 2, 1, 3, "TS-S", 2, 0))
+
+	variantID := 3
+	items, err := repo.ListAllocatable(context.Background(), 1, &variantID)
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, 2, items[1].WarehouseID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddAdjustmentInventory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewInventoryRepo(db)
+
+	mock.ExpectExec("insert into inventory_adjustments").
+		WithArgs(5, -2, 8, models.ReasonSale, "order-12", nil, nil).
+		WillReturnResult(sqlmock.NewResult(40, 1))
+
+	adjustment := &models.InventoryAdjustment{ItemID: 5, Delta: -2, OnHandAfter: 8, Reason: models.ReasonSale, Reference: "order-12"}
+	assert.NoError(t, repo.AddAdjustment(context.Background(), adjustment))
+	assert.Equal(t, 40, adjustment.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,371 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrOrderStatusConflict = errors.New("order status changed concurrently")
+)
+
+type OrderRepo interface {
+	Create(ctx context.Context, order *models.Order) error
+	AddItem(ctx context.Context, item *models.OrderItem) error
+	GetByID(ctx context.Context, id int) (*models.Order, error)
+	Lock(ctx context.Context, id int) (*models.Order, error)
+	List(ctx context.Context, filter models.OrderFilter) ([]models.Order, error)
+	ListItems(ctx context.Context, orderIDs []int) ([]models.OrderItem, error)
+	UpdateStatus(ctx context.Context, id int, from, to models.OrderStatus) error
+	AddRefund(ctx context.Context, id int, amount models.Money) error
+	AddDiscount(ctx context.Context, discount *models.OrderDiscount) error
+	ListDiscounts(ctx context.Context, orderID int) ([]models.OrderDiscount, error)
+	AddAddress(ctx context.Context, address *models.OrderAddress) error
+	ListAddresses(ctx context.Context, orderID int) ([]models.OrderAddress, error)
+
+	AddStatusChange(ctx context.Context, change *models.OrderStatusChange) error
+	ListStatusChanges(ctx context.Context, orderID int) ([]models.OrderStatusChange, error)
+}
+
+type orderRepo struct {
+	db DBTX
+}
+
+func NewOrderRepo(db DBTX) OrderRepo {
+	return &orderRepo{db: db}
+}
+
+const orderColumns = "id, user_id, status, currency, subtotal, discount, tax, prices_include_tax, free_shipping, refunded_amount, created_at, updated_at"
+
+func (r *orderRepo) Create(ctx context.Context, order *models.Order) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into orders (user_id, status, currency, subtotal, discount, tax, prices_include_tax, free_shipping) values (?,?,?,?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, nullableID(order.UserID), order.Status, order.Currency, order.Subtotal.Amount, order.Discount.Amount,
+		order.Tax.Amount, order.TaxInclusive, order.FreeShipping)
+	if err != nil {
+		return fmt.Errorf("failed to insert order: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		order.ID = int(id)
+	}
+	return nil
+}
+
+func (r *orderRepo) AddItem(ctx context.Context, item *models.OrderItem) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into order_items (order_id, product_id, variant_id, sku, name, quantity, unit_price, tax_class, tax_name, tax_rate, tax) " +
+		"values (?,?,?,?,?,?,?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, item.OrderID, item.ProductID, item.VariantID, nullableString(item.SKU), item.Name, item.Quantity, item.UnitPrice.Amount,
+		item.TaxClass, nullableString(item.TaxName), item.TaxRate.String(), item.Tax.Amount)
+	if err != nil {
+		return fmt.Errorf("failed to insert order item: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		item.ID = int(id)
+	}
+	return nil
+}
+
+// GetByID returns the order without its items
+func (r *orderRepo) GetByID(ctx context.Context, id int) (*models.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	return r.getOrder(ctx, "select "+orderColumns+" from orders where id=?", id)
+}
+
+// Lock reads the order and locks it until the transaction ends, so payment
+// attempts for it are started one after the other
+func (r *orderRepo) Lock(ctx context.Context, id int) (*models.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	return r.getOrder(ctx, "select "+orderColumns+" from orders where id=? for update", id)
+}
+
+// List returns matching orders newest first, without their items
+func (r *orderRepo) List(ctx context.Context, filter models.OrderFilter) ([]models.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	var conditions []string
+	var args []interface{}
+	if filter.UserID != 0 {
+		conditions = append(conditions, "user_id=?")
+		args = append(args, filter.UserID)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status=?")
+		args = append(args, filter.Status)
+	}
+	query := "select " + orderColumns + " from orders"
+	if len(conditions) > 0 {
+		query += " where " + strings.Join(conditions, " and ")
+	}
+	query += " order by created_at desc, id desc"
+	if filter.Limit > 0 {
+		query += " limit ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *order)
+	}
+	return orders, rows.Err()
+}
+
+// ListItems returns the items of all the given orders in one query
+func (r *orderRepo) ListItems(ctx context.Context, orderIDs []int) ([]models.OrderItem, error) {
+	if len(orderIDs) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	placeholders, args := inClause(orderIDs)
+	query := "select oi.id, oi.order_id, oi.product_id, oi.variant_id, coalesce(oi.sku, ''), oi.name, oi.quantity, oi.unit_price, o.currency, " +
+		"oi.tax_class, coalesce(oi.tax_name, ''), oi.tax_rate, oi.tax " +
+		"from order_items oi join orders o on o.id = oi.order_id where oi.order_id in (" + placeholders + ") order by oi.order_id, oi.id"
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.OrderItem
+	for rows.Next() {
+		var item models.OrderItem
+		var productID, variantID sql.NullInt64
+		var taxRate string
+		err := rows.Scan(&item.ID, &item.OrderID, &productID, &variantID, &item.SKU, &item.Name, &item.Quantity, &item.UnitPrice.Amount, &item.UnitPrice.Currency,
+			&item.TaxClass, &item.TaxName, &taxRate, &item.Tax.Amount)
+		if err != nil {
+			return nil, err
+		}
+		if item.TaxRate, err = models.ParsePercent(taxRate); err != nil {
+			return nil, err
+		}
+		item.Tax.Currency = item.UnitPrice.Currency
+		item.ProductID = nullIntPtr(productID)
+		item.VariantID = nullIntPtr(variantID)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// UpdateStatus moves the order from one status to another, it fails with
+// ErrOrderStatusConflict when the order is no longer in the from status
+func (r *orderRepo) UpdateStatus(ctx context.Context, id int, from, to models.OrderStatus) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "update orders set status=? where id=? and status=?", to, id, from)
+	if err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrOrderStatusConflict
+	}
+	return nil
+}
+
+// AddRefund adds amount, in the order's currency, to what was refunded for the order
+func (r *orderRepo) AddRefund(ctx context.Context, id int, amount models.Money) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "update orders set refunded_amount=refunded_amount+? where id=?", amount.Amount, id)
+	if err != nil {
+		return fmt.Errorf("failed to update order refunds: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrOrderNotFound
+	}
+	return nil
+}
+
+func (r *orderRepo) AddDiscount(ctx context.Context, discount *models.OrderDiscount) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into order_discounts (order_id, order_item_id, promotion_id, name, code, kind, amount) values (?,?,?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, discount.OrderID, discount.OrderItemID, discount.PromotionID, discount.Name,
+		nullableString(discount.Code), discount.Kind, discount.Amount.Amount)
+	if err != nil {
+		return fmt.Errorf("failed to insert order discount: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		discount.ID = int(id)
+	}
+	return nil
+}
+
+// ListDiscounts returns the order's discount breakdown in the order it was applied
+func (r *orderRepo) ListDiscounts(ctx context.Context, orderID int) ([]models.OrderDiscount, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select d.id, d.order_id, d.order_item_id, d.promotion_id, d.name, coalesce(d.code, ''), d.kind, d.amount, o.currency " +
+		"from order_discounts d join orders o on o.id = d.order_id where d.order_id=? order by d.id"
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var discounts []models.OrderDiscount
+	for rows.Next() {
+		var discount models.OrderDiscount
+		var itemID, promotionID sql.NullInt64
+		err := rows.Scan(&discount.ID, &discount.OrderID, &itemID, &promotionID, &discount.Name, &discount.Code, &discount.Kind,
+			&discount.Amount.Amount, &discount.Amount.Currency)
+		if err != nil {
+			return nil, err
+		}
+		discount.OrderItemID = nullIntPtr(itemID)
+		discount.PromotionID = nullIntPtr(promotionID)
+		discounts = append(discounts, discount)
+	}
+	return discounts, rows.Err()
+}
+
+// AddAddress copies an address onto the order
+func (r *orderRepo) AddAddress(ctx context.Context, address *models.OrderAddress) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into order_addresses (order_id, kind, address_id, name, company, line1, line2, city, region, postcode, country, phone) " +
+		"values (?,?,?,?,?,?,?,?,?,?,?,?)"
+	a := address.PostalAddress
+	_, err := r.db.ExecContext(ctx, query, address.OrderID, address.Kind, address.AddressID, a.Name, a.Company, a.Line1, a.Line2, a.City, a.Region,
+		a.Postcode, a.Country, a.Phone)
+	if err != nil {
+		return fmt.Errorf("failed to insert order address: %w", err)
+	}
+	return nil
+}
+
+// ListAddresses returns the addresses the order was placed with, shipping first
+func (r *orderRepo) ListAddresses(ctx context.Context, orderID int) ([]models.OrderAddress, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select order_id, kind, address_id, name, company, line1, line2, city, region, postcode, country, phone " +
+		"from order_addresses where order_id=? order by kind desc"
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addresses []models.OrderAddress
+	for rows.Next() {
+		var address models.OrderAddress
+		var addressID sql.NullInt64
+		a := &address.PostalAddress
+		err := rows.Scan(&address.OrderID, &address.Kind, &addressID, &a.Name, &a.Company, &a.Line1, &a.Line2, &a.City, &a.Region, &a.Postcode, &a.Country, &a.Phone)
+		if err != nil {
+			return nil, err
+		}
+		address.AddressID = nullIntPtr(addressID)
+		addresses = append(addresses, address)
+	}
+	return addresses, rows.Err()
+}
+
+func (r *orderRepo) AddStatusChange(ctx context.Context, change *models.OrderStatusChange) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into order_status_history (order_id, from_status, to_status, note, changed_by) values (?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, change.OrderID, nullableString(string(change.From)), change.To, nullableString(change.Note), nullableID(change.ChangedBy))
+	if err != nil {
+		return fmt.Errorf("failed to insert order status change: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		change.ID = int(id)
+	}
+	return nil
+}
+
+// ListStatusChanges returns the order's history, oldest first
+func (r *orderRepo) ListStatusChanges(ctx context.Context, orderID int) ([]models.OrderStatusChange, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select id, order_id, coalesce(from_status, ''), to_status, coalesce(note, ''), changed_by, created_at " +
+		"from order_status_history where order_id=? order by id"
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []models.OrderStatusChange
+	for rows.Next() {
+		var change models.OrderStatusChange
+		var changedBy sql.NullInt64
+		err := rows.Scan(&change.ID, &change.OrderID, &change.From, &change.To, &change.Note, &changedBy, &change.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		change.ChangedBy = int(changedBy.Int64)
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+func (r *orderRepo) getOrder(ctx context.Context, query string, args ...interface{}) (*models.Order, error) {
+	order, err := scanOrder(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+	return order, nil
+}
+
+func scanOrder(row rowScanner) (*models.Order, error) {
+	var order models.Order
+	var userID sql.NullInt64
+	err := row.Scan(&order.ID, &userID, &order.Status, &order.Currency, &order.Subtotal.Amount, &order.Discount.Amount, &order.Tax.Amount, &order.TaxInclusive,
+		&order.FreeShipping, &order.RefundedAmount.Amount, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	order.Subtotal.Currency = order.Currency
+	order.Discount.Currency = order.Currency
+	order.Tax.Currency = order.Currency
+	order.RefundedAmount.Currency = order.Currency
+	order.UserID = int(userID.Int64)
+	return &order, nil
+}
+
+func nullIntPtr(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	id := int(value.Int64)
+	return &id
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"ecommerce/models"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListOrders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewOrderRepo(db)
+
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta("select "+orderColumns+" from orders where user_id=? and status=? order by created_at desc, id desc limit ?")).
+		WithArgs(7, models.OrderPaid, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status", "currency", "subtotal", "discount", "tax", "prices_include_tax", "free_shipping", "refunded_amount", "created_at", "updated_at"}).
+			AddRow(40, 7, "paid", "USD", 1900, 300, 128, false, true, 0, now, now).
+			AddRow(39, nil, "paid", "USD", 500, 0, 0, false, false, 250, now, now))
+
+	orders, err := repo.List(context.Background(), models.OrderFilter{UserID: 7, Status: models.OrderPaid, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, orders, 2)
+	assert.Equal(t, models.OrderPaid, orders[0].Status)
+	assert.Equal(t, 0, orders[1].UserID)
+	assert.Equal(t, models.NewMoney(250, "USD"), orders[1].RefundedAmount)
+	total, err := orders[0].Total()
+	assert.NoError(t, err)
+	assert.Equal(t, models.NewMoney(1728, "USD"), total)
+	assert.Equal(t, models.NewMoney(128, "USD"), orders[0].Tax)
+	assert.True(t, orders[0].FreeShipping)
+}
+
+func TestListOrderItems(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewOrderRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("join orders o on o.id = oi.order_id where oi.order_id in (?,?) order by oi.order_id, oi.id")).
+		WithArgs(39, 40).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "product_id", "variant_id", "sku", "name", "quantity", "unit_price", "currency",
+			"tax_class", "tax_name", "tax_rate", "tax"}).
+			AddRow(1, 39, nil, nil, "", "Discontinued mug", 1, 500, "USD", "standard", "", "0.0000", 0).
+			AddRow(2, 40, 2, 4, "SHIRT-M", "Shirt", 1, 2500, "USD", "reduced", "VAT", "5.5000", 138))
+
+	items, err := repo.ListItems(context.Background(), []int{39, 40})
+	assert.NoError(t, err)
+	assert.Nil(t, items[0].ProductID)
+	assert.Equal(t, 4, *items[1].VariantID)
+	assert.Equal(t, models.NewMoney(2500, "USD"), items[1].UnitPrice)
+	assert.Equal(t, models.Percent(55000), items[1].TaxRate)
+	assert.Equal(t, models.NewMoney(138, "USD"), items[1].Tax)
+}
+
+func TestListOrderDiscounts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewOrderRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("from order_discounts d join orders o on o.id = d.order_id where d.order_id=? order by d.id")).
+		WithArgs(40).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "order_item_id", "promotion_id", "name", "code", "kind", "amount", "currency"}).
+			AddRow(1, 40, 2, 5, "Summer sale", "SUMMER", "percentage", 250, "USD").
+			AddRow(2, 40, nil, nil, "Free shipping", "", "free_shipping", 0, "USD"))
+
+	discounts, err := repo.ListDiscounts(context.Background(), 40)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, *discounts[0].OrderItemID)
+	assert.Equal(t, models.NewMoney(250, "USD"), discounts[0].Amount)
+	assert.Nil(t, discounts[1].OrderItemID)
+	assert.Nil(t, discounts[1].PromotionID)
+}
+
+func TestListOrderAddresses(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewOrderRepo(db)
+
+	columns := []string{"order_id", "kind", "address_id", "name", "company", "line1", "line2", "city", "region", "postcode", "country", "phone"}
+	mock.ExpectQuery(regexp.QuoteMeta("from order_addresses where order_id=? order by kind desc")).
+		WithArgs(40).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(40, "shipping", 3, "Ada Lovelace", "", "12 St James's Square", "", "London", "", "SW1Y 4JH", "GB", "").
+			AddRow(40, "billing", nil, "Ada Lovelace", "Analytical Ltd", "1 Main St", "", "London", "", "EC1A 1BB", "GB", ""))
+
+	addresses, err := repo.ListAddresses(context.Background(), 40)
+	assert.NoError(t, err)
+	assert.Len(t, addresses, 2)
+	assert.Equal(t, models.AddressShipping, addresses[0].Kind)
+	assert.Equal(t, 3, *addresses[0].AddressID)
+	assert.Equal(t, "SW1Y 4JH", addresses[0].Postcode)
+	assert.Nil(t, addresses[1].AddressID, "the saved address was deleted")
+}
+
+func TestUpdateOrderStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewOrderRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("update orders set status=? where id=? and status=?")).
+		WithArgs(models.OrderPaid, 40, models.OrderPending).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.UpdateStatus(context.Background(), 40, models.OrderPending, models.OrderPaid)
+	assert.Equal(t, ErrOrderStatusConflict, err)
+}
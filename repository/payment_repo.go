@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"errors"
+	"fmt"
+)
+
+var ErrPaymentNotFound = errors.New("payment not found")
+
+type PaymentRepo interface {
+	Create(ctx context.Context, payment *models.Payment) error
+	GetByID(ctx context.Context, id int) (*models.Payment, error)
+	Lock(ctx context.Context, id int) (*models.Payment, error)
+	LockByReference(ctx context.Context, provider, reference string) (*models.Payment, error)
+	ListByOrder(ctx context.Context, orderID int) ([]models.Payment, error)
+	Update(ctx context.Context, payment *models.Payment) error
+
+	CreateRefund(ctx context.Context, refund *models.Refund) error
+	ListRefunds(ctx context.Context, paymentID int) ([]models.Refund, error)
+	UpdateRefund(ctx context.Context, refund *models.Refund) error
+
+	RecordEvent(ctx context.Context, event *models.PaymentEvent) (bool, error)
+}
+
+type paymentRepo struct {
+	db DBTX
+}
+
+func NewPaymentRepo(db DBTX) PaymentRepo {
+	return &paymentRepo{db: db}
+}
+
+const paymentColumns = "id, order_id, provider, reference, status, currency, amount, refunded_amount, failure_reason, created_at, updated_at"
+
+func (r *paymentRepo) Create(ctx context.Context, payment *models.Payment) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into payments (order_id, provider, reference, status, currency, amount, failure_reason) values (?,?,?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, payment.OrderID, payment.Provider, nullableString(payment.Reference), payment.Status,
+		payment.Amount.Currency, payment.Amount.Amount, nullableString(payment.FailureReason))
+	if err != nil {
+		return fmt.Errorf("failed to insert payment: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		payment.ID = int(id)
+	}
+	return nil
+}
+
+func (r *paymentRepo) GetByID(ctx context.Context, id int) (*models.Payment, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	return r.getPayment(ctx, "select "+paymentColumns+" from payments where id=?", id)
+}
+
+// Lock reads the payment and locks it until the transaction ends, so
+// concurrent refunds and webhook deliveries apply one after the other
+func (r *paymentRepo) Lock(ctx context.Context, id int) (*models.Payment, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	return r.getPayment(ctx, "select "+paymentColumns+" from payments where id=? for update", id)
+}
+
+func (r *paymentRepo) LockByReference(ctx context.Context, provider, reference string) (*models.Payment, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select " + paymentColumns + " from payments where provider=? and reference=? for update"
+	return r.getPayment(ctx, query, provider, reference)
+}
+
+// ListByOrder returns the order's payment attempts, oldest first
+func (r *paymentRepo) ListByOrder(ctx context.Context, orderID int) ([]models.Payment, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "select "+paymentColumns+" from payments where order_id=? order by id", orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []models.Payment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *payment)
+	}
+	return payments, rows.Err()
+}
+
+// Update saves the reference, status, refunded amount and failure reason
+func (r *paymentRepo) Update(ctx context.Context, payment *models.Payment) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update payments set reference=?, status=?, refunded_amount=?, failure_reason=? where id=?"
+	_, err := r.db.ExecContext(ctx, query, nullableString(payment.Reference), payment.Status, payment.RefundedAmount.Amount,
+		nullableString(payment.FailureReason), payment.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+	return nil
+}
+
+func (r *paymentRepo) CreateRefund(ctx context.Context, refund *models.Refund) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into payment_refunds (payment_id, return_id, idempotency_key, status, currency, amount) values (?,?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, refund.PaymentID, refund.ReturnID, refund.IdempotencyKey, refund.Status,
+		refund.Amount.Currency, refund.Amount.Amount)
+	if err != nil {
+		return fmt.Errorf("failed to insert refund: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		refund.ID = int(id)
+	}
+	return nil
+}
+
+// ListRefunds returns the payment's refunds, oldest first
+func (r *paymentRepo) ListRefunds(ctx context.Context, paymentID int) ([]models.Refund, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select id, payment_id, return_id, idempotency_key, status, currency, amount, failure_reason, created_at, updated_at " +
+		"from payment_refunds where payment_id=? order by id"
+	rows, err := r.db.QueryContext(ctx, query, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []models.Refund
+	for rows.Next() {
+		var refund models.Refund
+		var returnID sql.NullInt64
+		var failureReason sql.NullString
+		err := rows.Scan(&refund.ID, &refund.PaymentID, &returnID, &refund.IdempotencyKey, &refund.Status, &refund.Amount.Currency,
+			&refund.Amount.Amount, &failureReason, &refund.CreatedAt, &refund.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		refund.ReturnID = nullIntPtr(returnID)
+		refund.FailureReason = failureReason.String
+		refunds = append(refunds, refund)
+	}
+	return refunds, rows.Err()
+}
+
+// UpdateRefund saves the status and failure reason
+func (r *paymentRepo) UpdateRefund(ctx context.Context, refund *models.Refund) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update payment_refunds set status=?, failure_reason=? where id=?"
+	_, err := r.db.ExecContext(ctx, query, refund.Status, nullableString(refund.FailureReason), refund.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update refund: %w", err)
+	}
+	return nil
+}
+
+// RecordEvent remembers a webhook event, false means it was seen before
+func (r *paymentRepo) RecordEvent(ctx context.Context, event *models.PaymentEvent) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert ignore into payment_events (provider, event_id, type) values (?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, event.Provider, event.ID, event.Type)
+	if err != nil {
+		return false, fmt.Errorf("failed to record payment event: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func (r *paymentRepo) getPayment(ctx context.Context, query string, args ...interface{}) (*models.Payment, error) {
+	payment, err := scanPayment(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, err
+	}
+	return payment, nil
+}
+
+func scanPayment(row rowScanner) (*models.Payment, error) {
+	var payment models.Payment
+	var currency string
+	var reference, failureReason sql.NullString
+	err := row.Scan(&payment.ID, &payment.OrderID, &payment.Provider, &reference, &payment.Status,
+		&currency, &payment.Amount.Amount, &payment.RefundedAmount.Amount, &failureReason, &payment.CreatedAt, &payment.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	payment.Amount.Currency, payment.RefundedAmount.Currency = currency, currency
+	payment.Reference, payment.FailureReason = reference.String, failureReason.String
+	return &payment, nil
+}
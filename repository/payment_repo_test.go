@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"ecommerce/models"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordPaymentEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPaymentRepo(db)
+
+	query := regexp.QuoteMeta("insert ignore into payment_events (provider, event_id, type) values (?,?,?)")
+	mock.ExpectExec(query).WithArgs("fake", "evt_1", models.EventPaymentCaptured).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).WithArgs("fake", "evt_1", models.EventPaymentCaptured).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	event := &models.PaymentEvent{ID: "evt_1", Provider: "fake", Type: models.EventPaymentCaptured}
+	fresh, err := repo.RecordEvent(context.Background(), event)
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+	fresh, err = repo.RecordEvent(context.Background(), event)
+	assert.NoError(t, err)
+	assert.False(t, fresh, "a redelivered event is a duplicate")
+}
+
+func TestLockPaymentByReference(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPaymentRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("from payments where provider=? and reference=? for update")).
+		WithArgs("fake", "fake_40_9").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = repo.LockByReference(context.Background(), "fake", "fake_40_9")
+	assert.Equal(t, ErrPaymentNotFound, err)
+}
+
+func TestListRefunds(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPaymentRepo(db)
+
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta("from payment_refunds where payment_id=? order by id")).
+		WithArgs(50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payment_id", "return_id", "idempotency_key", "status", "currency", "amount", "failure_reason", "created_at", "updated_at"}).
+			AddRow(70, 50, nil, "key_1", "succeeded", "USD", 400, nil, now, now).
+			AddRow(71, 50, 60, "key_2", "failed", "USD", 9900, "refund exceeds the payment", now, now))
+
+	refunds, err := repo.ListRefunds(context.Background(), 50)
+	assert.NoError(t, err)
+	assert.Len(t, refunds, 2)
+	assert.Equal(t, models.NewMoney(400, "USD"), refunds[0].Amount)
+	assert.Nil(t, refunds[0].ReturnID)
+	assert.Equal(t, 60, *refunds[1].ReturnID)
+	assert.Equal(t, models.RefundFailed, refunds[1].Status)
+	assert.Equal(t, "refund exceeds the payment", refunds[1].FailureReason)
+}
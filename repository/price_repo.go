@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"ecommerce/models"
+	"errors"
+	"fmt"
+)
+
+var ErrProductPriceNotFound = errors.New("product price not found")
+
+// PriceRepo stores the explicit per-currency prices of products
+type PriceRepo interface {
+	ListByProduct(ctx context.Context, productID int) ([]models.ProductPrice, error)
+	ListByProducts(ctx context.Context, productIDs []int, currency string) ([]models.ProductPrice, error)
+	Set(ctx context.Context, price *models.ProductPrice) error
+	Delete(ctx context.Context, productID int, currency string) error
+}
+
+type priceRepo struct {
+	db DBTX
+}
+
+func NewPriceRepo(db DBTX) PriceRepo {
+	return &priceRepo{db: db}
+}
+
+// ListByProduct returns every explicit price of the product, ordered by currency
+func (r *priceRepo) ListByProduct(ctx context.Context, productID int) ([]models.ProductPrice, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select product_id, amount, currency from product_prices where product_id=? order by currency"
+	return r.list(ctx, query, productID)
+}
+
+// ListByProducts returns the explicit prices in currency of several products at once
+func (r *priceRepo) ListByProducts(ctx context.Context, productIDs []int, currency string) ([]models.ProductPrice, error) {
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	placeholders, args := inClause(productIDs)
+	query := "select product_id, amount, currency from product_prices where currency=? and product_id in (" + placeholders + ") order by product_id"
+	return r.list(ctx, query, append([]interface{}{currency}, args...)...)
+}
+
+// Set adds the price or replaces the product's price in the same currency
+func (r *priceRepo) Set(ctx context.Context, price *models.ProductPrice) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into product_prices (product_id, currency, amount) values (?,?,?) on duplicate key update amount=values(amount)"
+	if _, err := r.db.ExecContext(ctx, query, price.ProductID, price.Price.Currency, price.Price.Amount); err != nil {
+		return fmt.Errorf("failed to save product price: %w", err)
+	}
+	return nil
+}
+
+func (r *priceRepo) Delete(ctx context.Context, productID int, currency string) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "delete from product_prices where product_id=? and currency=?", productID, currency)
+	if err != nil {
+		return fmt.Errorf("failed to delete product price: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrProductPriceNotFound
+	}
+	return nil
+}
+
+func (r *priceRepo) list(ctx context.Context, query string, args ...interface{}) ([]models.ProductPrice, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prices []models.ProductPrice
+	for rows.Next() {
+		var price models.ProductPrice
+		if err := rows.Scan(&price.ProductID, &price.Price.Amount, &price.Price.Currency); err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+	return prices, rows.Err()
+}
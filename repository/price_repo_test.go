@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"ecommerce/models"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListPricesByProducts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPriceRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("from product_prices where currency=? and product_id in (?,?) order by product_id")).
+		WithArgs("EUR", 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "amount", "currency"}).AddRow(2, 1799, "EUR"))
+
+	prices, err := repo.ListByProducts(context.Background(), []int{1, 2}, "EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ProductPrice{{ProductID: 2, Price: models.NewMoney(1799, "EUR")}}, prices)
+}
+
+func TestSetProductPrice(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPriceRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("insert into product_prices (product_id, currency, amount) values (?,?,?) on duplicate key update")).
+		WithArgs(2, "GBP", 1499).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	assert.NoError(t, repo.Set(context.Background(), &models.ProductPrice{ProductID: 2, Price: models.NewMoney(1499, "GBP")}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteProductPrice(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPriceRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("delete from product_prices where product_id=? and currency=?")).
+		WithArgs(2, "GBP").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.Equal(t, ErrProductPriceNotFound, repo.Delete(context.Background(), 2, "GBP"))
+}
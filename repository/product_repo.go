@@ -1,83 +1,101 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"ecommerce/models"
+	"errors"
 	"fmt"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
+var ErrProductNotFound = errors.New("product not found")
+
 type ProductRepo interface {
-	Create(product *models.Product) error
-	GetByID(id int) (*models.Product, error)
-	GetAll() ([]models.Product, error)
-	Update(product *models.Product) error
-	Delete(id int) error
+	Create(ctx context.Context, product *models.Product) error
+	GetByID(ctx context.Context, id int) (*models.Product, error)
+	GetAll(ctx context.Context) ([]models.Product, error)
+	Update(ctx context.Context, product *models.Product) error
+	Delete(ctx context.Context, id int) error
 }
 
 type productRepo struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewProductRepo(db *sql.DB) ProductRepo {
+func NewProductRepo(db DBTX) ProductRepo {
 	return &productRepo{db: db}
 }
 
-func (r *productRepo) Create(product *models.Product) error {
-	query := "insert into products (Name,Price) values (?,?)"
-	_, err := r.db.Exec(query, product.Name, product.Price)
+// productColumns are read by scanProduct
+const productColumns = "id, name, price, currency, tax_class, weight_grams, length_mm, width_mm, height_mm, created_by, updated_by"
+
+func (r *productRepo) Create(ctx context.Context, product *models.Product) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into products (Name,Price,currency,tax_class,weight_grams,length_mm,width_mm,height_mm,created_by,updated_by) values (?,?,?,?,?,?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, product.Name, product.Price.Amount, product.Price.Currency, product.TaxClass,
+		product.Weight, product.Dimensions.Length, product.Dimensions.Width, product.Dimensions.Height,
+		nullableID(product.CreatedBy), nullableID(product.UpdatedBy))
 	if err != nil {
-		return fmt.Errorf("failed to insert product: %v", err)
+		return fmt.Errorf("failed to insert product: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		product.ID = int(id)
 	}
 	return nil
 }
 
-func (r *productRepo) GetByID(id int) (*models.Product, error) {
-	query := "select id, name, price from products where id=?"
-	row := r.db.QueryRow(query, id)
+func (r *productRepo) GetByID(ctx context.Context, id int) (*models.Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
 
-	var product models.Product
-	err := row.Scan(&product.ID, &product.Name, &product.Price)
+	query := "select " + productColumns + " from products where id=?"
+	product, err := scanProduct(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("product not found")
+			return nil, ErrProductNotFound
 		}
 		return nil, err
 	}
-	return &product, nil
+	return product, nil
 }
 
-func (r *productRepo) GetAll() ([]models.Product, error) {
-	query := "select id, name, price from products"
-	rows, err := r.db.Query(query)
+func (r *productRepo) GetAll(ctx context.Context) ([]models.Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	query := "select " + productColumns + " from products"
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var products []models.Product
-	for rows.Next() {
-		var product models.Product
-		if err := rows.Scan(&product.ID, &product.Name, &product.Price); err != nil {
-			return nil, err
-		}
-		products = append(products, product)
-	}
-	return products, nil
+	return scanProducts(rows)
 }
 
-func (r *productRepo) Update(product *models.Product) error {
-	_, err := r.db.Exec("update products set name = ?, price = ? where id = ?",
-		product.Name, product.Price, product.ID)
+func (r *productRepo) Update(ctx context.Context, product *models.Product) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update products set name = ?, price = ?, currency = ?, tax_class = ?, weight_grams = ?, length_mm = ?, width_mm = ?, height_mm = ?, " +
+		"updated_by = ? where id = ?"
+	_, err := r.db.ExecContext(ctx, query, product.Name, product.Price.Amount, product.Price.Currency, product.TaxClass,
+		product.Weight, product.Dimensions.Length, product.Dimensions.Width, product.Dimensions.Height, nullableID(product.UpdatedBy), product.ID)
 	return err
 }
 
-func (r *productRepo) Delete(id int) error {
+func (r *productRepo) Delete(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
 	query := "delete from products where id=?"
-	result, err := r.db.Exec(query, id)
+	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		return fmt.Errorf("failed to delete product: %v", err)
+		return fmt.Errorf("failed to delete product: %w", err)
 	}
 
 	rowsAffected, _ := result.RowsAffected()
@@ -86,3 +104,45 @@ func (r *productRepo) Delete(id int) error {
 	}
 	return nil
 }
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProduct reads productColumns. The audit columns are NULL once the user
+// they point at is deleted.
+func scanProduct(row rowScanner) (*models.Product, error) {
+	var product models.Product
+	var createdBy, updatedBy sql.NullInt64
+	if err := row.Scan(&product.ID, &product.Name, &product.Price.Amount, &product.Price.Currency, &product.TaxClass, &product.Weight,
+		&product.Dimensions.Length, &product.Dimensions.Width, &product.Dimensions.Height, &createdBy, &updatedBy); err != nil {
+		return nil, err
+	}
+	product.CreatedBy = int(createdBy.Int64)
+	product.UpdatedBy = int(updatedBy.Int64)
+	return &product, nil
+}
+
+func scanProducts(rows *sql.Rows) ([]models.Product, error) {
+	var products []models.Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, *product)
+	}
+	if err := rows.Err(); err != nil { // e.g. the deadline hit mid-iteration
+		return nil, err
+	}
+	return products, nil
+}
+
+// nullableID stores a missing user reference as NULL rather than a dangling 0
+func nullableID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
@@ -1,10 +1,12 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"ecommerce/models"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -16,33 +18,50 @@ func TestCreateProduct(t *testing.T) {
 	defer db.Close()
 
 	product := &models.Product{
-		ID:    1,
-		Name:  "TubeLight",
-		Price: 999,
+		ID:         1,
+		Name:       "TubeLight",
+		Price:      models.NewMoney(99900, "USD"),
+		TaxClass:   "reduced",
+		Weight:     450,
+		Dimensions: models.Dimensions{Length: 600, Width: 80, Height: 80},
+		CreatedBy:  1,
+		UpdatedBy:  1,
 	}
 	repo := NewProductRepo(db)
 
 	t.Run("Success", func(t *testing.T) {
 		mock.ExpectExec("insert into products").
-			WithArgs(product.Name, product.Price).
+			WithArgs(product.Name, product.Price.Amount, product.Price.Currency, product.TaxClass, product.Weight, 600, 80, 80, product.CreatedBy, product.UpdatedBy).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		// 1 : The inserted row ID.
 		// 1 : One row affected (successful insert).
 
-		err = repo.Create(product)
+		err = repo.Create(context.Background(), product)
 		assert.NoError(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 	t.Run("Fail", func(t *testing.T) {
 		mock.ExpectExec("insert into products").
-			WithArgs(product.Name, product.Price).
+			WithArgs(product.Name, product.Price.Amount, product.Price.Currency, product.TaxClass, product.Weight, 600, 80, 80, product.CreatedBy, product.UpdatedBy).
 			WillReturnError(fmt.Errorf("failed to insert product"))
 
-		err = repo.Create(product)
+		err = repo.Create(context.Background(), product)
 		assert.Error(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		mock.ExpectExec("insert into products").
+			WithArgs(product.Name, product.Price.Amount, product.Price.Currency, product.TaxClass, product.Weight, 600, 80, 80, product.CreatedBy, product.UpdatedBy).
+			WillDelayFor(time.Second). // a slow query the client gave up on
+			WillReturnResult(sqlmock.NewResult(1, 1))
 
+		time.AfterFunc(10*time.Millisecond, cancel)
+		start := time.Now()
+		err = repo.Create(ctx, product)
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), time.Second) // returned without waiting for the query
+	})
 }
 
 func TestGetByIdProduct(t *testing.T) {
@@ -52,17 +71,21 @@ func TestGetByIdProduct(t *testing.T) {
 
 	repo := NewProductRepo(db)
 	t.Run("Found", func(t *testing.T) {
-		mock.ExpectQuery("select id, name, price from products where id=?").
+		mock.ExpectQuery("select id, name, price, currency, tax_class, weight_grams, length_mm, width_mm, height_mm, created_by, updated_by from products where id=?").
 			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price"}).
-				AddRow(1, "TubeLight", 999))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "tax_class", "weight_grams", "length_mm", "width_mm", "height_mm", "created_by", "updated_by"}).
+				AddRow(1, "TubeLight", 99900, "USD", "standard", 450, 600, 80, 80, 1, 2))
 
-		product, err := repo.GetByID(1)
+		product, err := repo.GetByID(context.Background(), 1)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, product)
 		assert.Equal(t, 1, product.ID)
 		assert.Equal(t, "TubeLight", product.Name)
+		assert.Equal(t, 1, product.CreatedBy)
+		assert.Equal(t, 2, product.UpdatedBy)
+		assert.Equal(t, 450, product.Weight)
+		assert.Equal(t, int64(3840000), product.Dimensions.Volume())
 
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
@@ -71,12 +94,12 @@ func TestGetByIdProduct(t *testing.T) {
 		assert.NoError(t, err)
 		defer db.Close()
 
-		mock.ExpectQuery("select id, name, price from products where id=?").
+		mock.ExpectQuery("select id, name, price, currency, tax_class, weight_grams, length_mm, width_mm, height_mm, created_by, updated_by from products where id=?").
 			WithArgs(90).
 			WillReturnError(sql.ErrNoRows)
 
 		repo := NewProductRepo(db)
-		product, err := repo.GetByID(90)
+		product, err := repo.GetByID(context.Background(), 90)
 
 		assert.Error(t, err)
 		assert.Nil(t, product)
@@ -84,11 +107,11 @@ func TestGetByIdProduct(t *testing.T) {
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 	t.Run("fail", func(t *testing.T) {
-		mock.ExpectQuery("select id, name, price from products where id=\\?").
+		mock.ExpectQuery("select id, name, price, currency, tax_class, weight_grams, length_mm, width_mm, height_mm, created_by, updated_by from products where id=\\?").
 			WithArgs(1).
 			WillReturnError(fmt.Errorf("database error"))
 
-		product, err := repo.GetByID(1)
+		product, err := repo.GetByID(context.Background(), 1)
 
 		assert.Error(t, err) // Expect an error
 		assert.Nil(t, product)
@@ -104,12 +127,12 @@ func TestGetAllProduct(t *testing.T) {
 
 	repo := NewProductRepo(db)
 	t.Run("Success", func(t *testing.T) {
-		mock.ExpectQuery("select id, name, price from products").
-			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price"}).
-				AddRow(1, "TubeLight", 999).
-				AddRow(2, "Laptop", 49999))
+		mock.ExpectQuery("select id, name, price, currency, tax_class, weight_grams, length_mm, width_mm, height_mm, created_by, updated_by from products").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "tax_class", "weight_grams", "length_mm", "width_mm", "height_mm", "created_by", "updated_by"}).
+				AddRow(1, "TubeLight", 99900, "USD", "standard", 450, 600, 80, 80, 1, 1).
+				AddRow(2, "Laptop", 4999900, "USD", "standard", 2100, 400, 300, 50, 1, 1))
 
-		products, err := repo.GetAll()
+		products, err := repo.GetAll(context.Background())
 
 		assert.NoError(t, err)
 		assert.Len(t, products, 2) // ensures that exactly 2 products were returned
@@ -124,10 +147,10 @@ func TestGetAllProduct(t *testing.T) {
 	})
 
 	t.Run("fail", func(t *testing.T) {
-		mock.ExpectQuery("select id, name, price from products").
+		mock.ExpectQuery("select id, name, price, currency, tax_class, weight_grams, length_mm, width_mm, height_mm, created_by, updated_by from products").
 			WillReturnError(fmt.Errorf("database error"))
 
-		products, err := repo.GetAll()
+		products, err := repo.GetAll(context.Background())
 
 		assert.Error(t, err)
 		assert.Nil(t, products)
@@ -135,11 +158,11 @@ func TestGetAllProduct(t *testing.T) {
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 	t.Run("Scan Error", func(t *testing.T) {
-		mock.ExpectQuery("select id, name, price from products").
+		mock.ExpectQuery("select id, name, price, currency, tax_class, weight_grams, length_mm, width_mm, height_mm, created_by, updated_by from products").
 			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}). // missing "password" column
 										AddRow(1, "TV"))
 
-		products, err := repo.GetAll()
+		products, err := repo.GetAll(context.Background())
 
 		assert.Error(t, err)
 		assert.Nil(t, products)
@@ -154,17 +177,19 @@ func TestUpdateProduct(t *testing.T) {
 	defer db.Close()
 
 	product := &models.Product{
-		ID:    1,
-		Name:  "TubeLight",
-		Price: 999,
+		ID:        1,
+		Name:      "TubeLight",
+		Price:     models.NewMoney(99900, "USD"),
+		CreatedBy: 1,
+		UpdatedBy: 1,
 	}
 	repo := NewProductRepo(db)
-	mock.ExpectExec("update products set name = \\?, price = \\? where id = \\?").
-		WithArgs(product.Name, product.Price, product.ID).
+	mock.ExpectExec("update products set name = \\?, price = \\?, currency = \\?, tax_class = \\?, weight_grams = \\?, length_mm = \\?, width_mm = \\?, height_mm = \\?, updated_by = \\? where id = \\?").
+		WithArgs(product.Name, product.Price.Amount, product.Price.Currency, product.TaxClass, 0, 0, 0, 0, product.UpdatedBy, product.ID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 		// Row ID = 1,
 		// 1 row affected
-	err = repo.Update(product)
+	err = repo.Update(context.Background(), product)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -181,7 +206,7 @@ func TestDeleteProduct(t *testing.T) {
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		// Row ID = 1,
 		// 1 row affected
-		err = repo.Delete(1)
+		err = repo.Delete(context.Background(), 1)
 		assert.NoError(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
@@ -191,7 +216,7 @@ func TestDeleteProduct(t *testing.T) {
 			WithArgs(90).
 			WillReturnResult(sqlmock.NewResult(0, 0)) // No rows affected
 
-		err = repo.Delete(90)
+		err = repo.Delete(context.Background(), 90)
 
 		assert.Error(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
@@ -201,7 +226,7 @@ func TestDeleteProduct(t *testing.T) {
 			WithArgs(1).
 			WillReturnError(fmt.Errorf("failed to delete product"))
 
-		err := repo.Delete(1)
+		err := repo.Delete(context.Background(), 1)
 
 		assert.Error(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
@@ -0,0 +1,339 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrPromotionNotFound  = errors.New("promotion not found")
+	ErrPromotionExhausted = errors.New("promotion usage limit reached")
+)
+
+type PromotionRepo interface {
+	Create(ctx context.Context, promotion *models.Promotion) error
+	GetByID(ctx context.Context, id int) (*models.Promotion, error)
+	List(ctx context.Context) ([]models.Promotion, error)
+	ListApplicable(ctx context.Context, codes []string) ([]models.Promotion, error)
+	Update(ctx context.Context, promotion *models.Promotion) error
+	Delete(ctx context.Context, id int) error
+
+	Redeem(ctx context.Context, redemption *models.PromotionRedemption) error
+	ReleaseRedemptions(ctx context.Context, orderID int) error
+	CountRedemptions(ctx context.Context, userID int, promotionIDs []int) (map[int]int, error)
+}
+
+type promotionRepo struct {
+	db DBTX
+}
+
+func NewPromotionRepo(db DBTX) PromotionRepo {
+	return &promotionRepo{db: db}
+}
+
+const promotionColumns = "id, name, coalesce(code, ''), kind, percent, amount, currency, buy_quantity, get_quantity, min_subtotal, " +
+	"starts_at, ends_at, usage_limit, per_customer_limit, usage_count, stackable, priority, active, created_at, updated_at"
+
+// Create inserts the promotion and the products and categories it targets,
+// run it in a transaction so a promotion is never seen without its targets
+func (r *promotionRepo) Create(ctx context.Context, promotion *models.Promotion) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into promotions (name, code, kind, percent, amount, currency, buy_quantity, get_quantity, min_subtotal, " +
+		"starts_at, ends_at, usage_limit, per_customer_limit, stackable, priority, active) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, r.values(promotion)...)
+	if err != nil {
+		return fmt.Errorf("failed to insert promotion: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	promotion.ID = int(id)
+	return r.linkTargets(ctx, promotion)
+}
+
+// GetByID returns the promotion with its targets
+func (r *promotionRepo) GetByID(ctx context.Context, id int) (*models.Promotion, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	promotion, err := scanPromotion(r.db.QueryRowContext(ctx, "select "+promotionColumns+" from promotions where id=?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPromotionNotFound
+		}
+		return nil, err
+	}
+	promotions := []models.Promotion{*promotion}
+	if err := r.loadTargets(ctx, promotions); err != nil {
+		return nil, err
+	}
+	return &promotions[0], nil
+}
+
+// List returns every promotion with its targets, highest priority first
+func (r *promotionRepo) List(ctx context.Context) ([]models.Promotion, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	return r.list(ctx, "select "+promotionColumns+" from promotions order by priority desc, id")
+}
+
+// ListApplicable returns the active promotions that apply without a code and
+// the active coupons among codes, highest priority first. Start and end
+// dates are left to the caller, so it can tell an expired coupon from an
+// unknown one.
+func (r *promotionRepo) ListApplicable(ctx context.Context, codes []string) ([]models.Promotion, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	query := "select " + promotionColumns + " from promotions where active and (code is null"
+	args := make([]interface{}, 0, len(codes))
+	if len(codes) > 0 {
+		placeholders := make([]string, len(codes))
+		for i, code := range codes {
+			placeholders[i] = "?"
+			args = append(args, code)
+		}
+		query += " or code in (" + strings.Join(placeholders, ",") + ")"
+	}
+	query += ") order by priority desc, id"
+	return r.list(ctx, query, args...)
+}
+
+// Update replaces the promotion's rule and targets, the usage count is kept.
+// Run it in a transaction like Create.
+func (r *promotionRepo) Update(ctx context.Context, promotion *models.Promotion) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update promotions set name=?, code=?, kind=?, percent=?, amount=?, currency=?, buy_quantity=?, get_quantity=?, min_subtotal=?, " +
+		"starts_at=?, ends_at=?, usage_limit=?, per_customer_limit=?, stackable=?, priority=?, active=? where id=?"
+	if _, err := r.db.ExecContext(ctx, query, append(r.values(promotion), promotion.ID)...); err != nil {
+		return fmt.Errorf("failed to update promotion: %w", err)
+	}
+	for _, target := range promotionTargets {
+		if _, err := r.db.ExecContext(ctx, "delete from "+target.table+" where promotion_id=?", promotion.ID); err != nil {
+			return fmt.Errorf("failed to clear promotion targets: %w", err)
+		}
+	}
+	return r.linkTargets(ctx, promotion)
+}
+
+func (r *promotionRepo) Delete(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "delete from promotions where id=?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete promotion: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrPromotionNotFound
+	}
+	return nil
+}
+
+// Redeem counts a use of the promotion and records it against the order.
+// The count only goes up while it is under the usage limit, so concurrent
+// checkouts can't take a promotion past its limit: the loser gets
+// ErrPromotionExhausted. Counting locks the promotion until the transaction
+// ends, so the customer's redemptions are checked against the per customer
+// limit one checkout at a time. Callers roll back on an error.
+func (r *promotionRepo) Redeem(ctx context.Context, redemption *models.PromotionRedemption) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update promotions set usage_count=usage_count+1 where id=? and (usage_limit=0 or usage_count<usage_limit)"
+	result, err := r.db.ExecContext(ctx, query, redemption.PromotionID)
+	if err != nil {
+		return fmt.Errorf("failed to count promotion use: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrPromotionExhausted
+	}
+
+	// guests can't be told apart, per customer limits only hold for users
+	userID := nullableID(redemption.UserID)
+	query = "insert into promotion_redemptions (promotion_id, order_id, user_id) select p.id, ?, ? from promotions p where p.id=? and " +
+		"(p.per_customer_limit=0 or ? is null or " +
+		"(select count(*) from promotion_redemptions r where r.promotion_id = p.id and r.user_id=?) < p.per_customer_limit)"
+	result, err = r.db.ExecContext(ctx, query, redemption.OrderID, userID, redemption.PromotionID, userID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to insert promotion redemption: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return fmt.Errorf("%w for the customer", ErrPromotionExhausted)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		redemption.ID = int(id)
+	}
+	return nil
+}
+
+// ReleaseRedemptions gives back the promotion uses of an order, e.g. when it
+// is cancelled
+func (r *promotionRepo) ReleaseRedemptions(ctx context.Context, orderID int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update promotions p join promotion_redemptions r on r.promotion_id = p.id set p.usage_count = p.usage_count - 1 where r.order_id=?"
+	if _, err := r.db.ExecContext(ctx, query, orderID); err != nil {
+		return fmt.Errorf("failed to give back promotion uses: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, "delete from promotion_redemptions where order_id=?", orderID); err != nil {
+		return fmt.Errorf("failed to delete promotion redemptions: %w", err)
+	}
+	return nil
+}
+
+// CountRedemptions returns how often the user redeemed each of the promotions,
+// promotions they never used are left out
+func (r *promotionRepo) CountRedemptions(ctx context.Context, userID int, promotionIDs []int) (map[int]int, error) {
+	counts := map[int]int{}
+	if len(promotionIDs) == 0 {
+		return counts, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	placeholders, args := inClause(promotionIDs)
+	query := "select promotion_id, count(*) from promotion_redemptions where user_id=? and promotion_id in (" + placeholders + ") group by promotion_id"
+	rows, err := r.db.QueryContext(ctx, query, append([]interface{}{userID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var promotionID, count int
+		if err := rows.Scan(&promotionID, &count); err != nil {
+			return nil, err
+		}
+		counts[promotionID] = count
+	}
+	return counts, rows.Err()
+}
+
+func (r *promotionRepo) values(p *models.Promotion) []interface{} {
+	currency := p.Amount.Currency
+	if currency == "" {
+		currency = p.MinSubtotal.Currency
+	}
+	return []interface{}{
+		p.Name, nullableString(p.Code), p.Kind, p.Percent, p.Amount.Amount, currency, p.BuyQuantity, p.GetQuantity, p.MinSubtotal.Amount,
+		p.StartsAt, p.EndsAt, p.UsageLimit, p.PerCustomerLimit, p.Stackable, p.Priority, p.Active,
+	}
+}
+
+// promotionTargets are the tables linking promotions to what they apply to
+var promotionTargets = []struct {
+	table, column string
+	ids           func(*models.Promotion) *[]int
+}{
+	{"promotion_products", "product_id", func(p *models.Promotion) *[]int { return &p.ProductIDs }},
+	{"promotion_categories", "category_id", func(p *models.Promotion) *[]int { return &p.CategoryIDs }},
+}
+
+func (r *promotionRepo) linkTargets(ctx context.Context, promotion *models.Promotion) error {
+	for _, target := range promotionTargets {
+		ids := *target.ids(promotion)
+		if len(ids) == 0 {
+			continue
+		}
+		placeholders := make([]string, len(ids))
+		args := make([]interface{}, 0, 2*len(ids))
+		for i, id := range ids {
+			placeholders[i] = "(?,?)"
+			args = append(args, promotion.ID, id)
+		}
+		query := "insert into " + target.table + " (promotion_id, " + target.column + ") values " + strings.Join(placeholders, ",")
+		if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to link promotion targets: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *promotionRepo) list(ctx context.Context, query string, args ...interface{}) ([]models.Promotion, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promotions []models.Promotion
+	for rows.Next() {
+		promotion, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, *promotion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return promotions, r.loadTargets(ctx, promotions)
+}
+
+// loadTargets fills in the products and categories of all the promotions in two queries
+func (r *promotionRepo) loadTargets(ctx context.Context, promotions []models.Promotion) error {
+	if len(promotions) == 0 {
+		return nil
+	}
+	ids := make([]int, len(promotions))
+	index := make(map[int]int, len(promotions))
+	for i, promotion := range promotions {
+		ids[i] = promotion.ID
+		index[promotion.ID] = i
+	}
+	placeholders, args := inClause(ids)
+
+	for _, target := range promotionTargets {
+		query := "select promotion_id, " + target.column + " from " + target.table + " where promotion_id in (" + placeholders + ") order by 1, 2"
+		rows, err := r.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var promotionID, targetID int
+			if err := rows.Scan(&promotionID, &targetID); err != nil {
+				rows.Close()
+				return err
+			}
+			promotion := &promotions[index[promotionID]]
+			*target.ids(promotion) = append(*target.ids(promotion), targetID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanPromotion(row rowScanner) (*models.Promotion, error) {
+	var p models.Promotion
+	var currency string
+	var startsAt, endsAt sql.NullTime
+	err := row.Scan(&p.ID, &p.Name, &p.Code, &p.Kind, &p.Percent, &p.Amount.Amount, &currency, &p.BuyQuantity, &p.GetQuantity, &p.MinSubtotal.Amount,
+		&startsAt, &endsAt, &p.UsageLimit, &p.PerCustomerLimit, &p.UsageCount, &p.Stackable, &p.Priority, &p.Active, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.Amount.Currency = currency
+	p.MinSubtotal.Currency = currency
+	if startsAt.Valid {
+		p.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		p.EndsAt = &endsAt.Time
+	}
+	return &p, nil
+}
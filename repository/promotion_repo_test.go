@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"ecommerce/models"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListApplicablePromotions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPromotionRepo(db)
+
+	now := time.Now()
+	columns := []string{"id", "name", "code", "kind", "percent", "amount", "currency", "buy_quantity", "get_quantity", "min_subtotal",
+		"starts_at", "ends_at", "usage_limit", "per_customer_limit", "usage_count", "stackable", "priority", "active", "created_at", "updated_at"}
+	mock.ExpectQuery(regexp.QuoteMeta("from promotions where active and (code is null or code in (?,?)) order by priority desc, id")).
+		WithArgs("SUMMER", "WELCOME").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(3, "Summer sale", "SUMMER", "percentage", 10, 0, "USD", 0, 0, 5000, nil, now, 100, 1, 4, true, 10, true, now, now).
+			AddRow(5, "Socks 3 for 2", "", "buy_x_get_y", 0, 0, "USD", 2, 1, 0, nil, nil, 0, 0, 0, true, 0, true, now, now))
+	mock.ExpectQuery(regexp.QuoteMeta("select promotion_id, product_id from promotion_products where promotion_id in (?,?)")).
+		WithArgs(3, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"promotion_id", "product_id"}).AddRow(5, 12))
+	mock.ExpectQuery(regexp.QuoteMeta("select promotion_id, category_id from promotion_categories where promotion_id in (?,?)")).
+		WithArgs(3, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"promotion_id", "category_id"}).AddRow(3, 2).AddRow(3, 4))
+
+	promotions, err := repo.ListApplicable(context.Background(), []string{"SUMMER", "WELCOME"})
+	assert.NoError(t, err)
+	assert.Len(t, promotions, 2)
+	assert.Equal(t, models.NewMoney(5000, "USD"), promotions[0].MinSubtotal)
+	assert.Nil(t, promotions[0].StartsAt)
+	assert.NotNil(t, promotions[0].EndsAt)
+	assert.Equal(t, []int{2, 4}, promotions[0].CategoryIDs)
+	assert.Equal(t, []int{12}, promotions[1].ProductIDs)
+	assert.False(t, promotions[1].Coupon())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedeemPromotion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPromotionRepo(db)
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("update promotions set usage_count=usage_count+1 where id=? and (usage_limit=0 or usage_count<usage_limit)")).
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("insert into promotion_redemptions (promotion_id, order_id, user_id) select p.id, ?, ? from promotions p where p.id=?")).
+			WithArgs(40, 7, 3, 7, 7).
+			WillReturnResult(sqlmock.NewResult(9, 1))
+
+		redemption := &models.PromotionRedemption{PromotionID: 3, OrderID: 40, UserID: 7}
+		assert.NoError(t, repo.Redeem(context.Background(), redemption))
+		assert.Equal(t, 9, redemption.ID)
+	})
+	t.Run("Limit reached", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("update promotions set usage_count=usage_count+1")).
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.Redeem(context.Background(), &models.PromotionRedemption{PromotionID: 3, OrderID: 41, UserID: 8})
+		assert.Equal(t, ErrPromotionExhausted, err)
+	})
+	t.Run("Customer limit reached", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("update promotions set usage_count=usage_count+1")).
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("(select count(*) from promotion_redemptions r where r.promotion_id = p.id and r.user_id=?) < p.per_customer_limit")).
+			WithArgs(42, 8, 3, 8, 8).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.Redeem(context.Background(), &models.PromotionRedemption{PromotionID: 3, OrderID: 42, UserID: 8})
+		assert.ErrorIs(t, err, ErrPromotionExhausted)
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReleaseRedemptions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPromotionRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("set p.usage_count = p.usage_count - 1 where r.order_id=?")).
+		WithArgs(40).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("delete from promotion_redemptions where order_id=?")).
+		WithArgs(40).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	assert.NoError(t, repo.ReleaseRedemptions(context.Background(), 40))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCountRedemptions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPromotionRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("from promotion_redemptions where user_id=? and promotion_id in (?,?) group by promotion_id")).
+		WithArgs(7, 3, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"promotion_id", "count"}).AddRow(3, 2))
+
+	counts, err := repo.CountRedemptions(context.Background(), 7, []int{3, 5})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{3: 2}, counts)
+}
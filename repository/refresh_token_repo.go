@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"fmt"
+	"time"
+)
+
+type RefreshTokenRepo interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	Revoke(ctx context.Context, id int) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAllForUser(ctx context.Context, userID int) error
+}
+
+type refreshTokenRepo struct {
+	db DBTX
+}
+
+func NewRefreshTokenRepo(db DBTX) RefreshTokenRepo {
+	return &refreshTokenRepo{db: db}
+}
+
+func (r *refreshTokenRepo) Create(ctx context.Context, token *models.RefreshToken) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into refresh_tokens (user_id, token_hash, family_id, expires_at) values (?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, token.UserID, token.TokenHash, token.FamilyID, token.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert refresh token: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err == nil {
+		token.ID = int(id)
+	}
+	return nil
+}
+
+func (r *refreshTokenRepo) GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select id, user_id, token_hash, family_id, expires_at, revoked_at, created_at from refresh_tokens where token_hash=?"
+	row := r.db.QueryRowContext(ctx, query, tokenHash)
+
+	var token models.RefreshToken
+	var revokedAt sql.NullTime
+	err := row.Scan(&token.ID, &token.UserID, &token.TokenHash, &token.FamilyID, &token.ExpiresAt, &revokedAt, &token.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("refresh token not found")
+		}
+		return nil, err
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return &token, nil
+}
+
+// Revoke marks a token as used, false means it was already revoked (e.g. by a concurrent refresh)
+func (r *refreshTokenRepo) Revoke(ctx context.Context, id int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update refresh_tokens set revoked_at=? where id=? and revoked_at is null"
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected == 1, nil
+}
+
+func (r *refreshTokenRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update refresh_tokens set revoked_at=? where family_id=? and revoked_at is null"
+	_, err := r.db.ExecContext(ctx, query, time.Now(), familyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
+func (r *refreshTokenRepo) RevokeAllForUser(ctx context.Context, userID int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update refresh_tokens set revoked_at=? where user_id=? and revoked_at is null"
+	_, err := r.db.ExecContext(ctx, query, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateRefreshToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRefreshTokenRepo(db)
+	token := &models.RefreshToken{UserID: 1, TokenHash: "hash", FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour)}
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectExec("insert into refresh_tokens").
+			WithArgs(token.UserID, token.TokenHash, token.FamilyID, token.ExpiresAt).
+			WillReturnResult(sqlmock.NewResult(7, 1))
+
+		err = repo.Create(context.Background(), token)
+		assert.NoError(t, err)
+		assert.Equal(t, 7, token.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Fail", func(t *testing.T) {
+		mock.ExpectExec("insert into refresh_tokens").
+			WillReturnError(fmt.Errorf("duplicate entry"))
+
+		err = repo.Create(context.Background(), token)
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetRefreshTokenByHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRefreshTokenRepo(db)
+	columns := []string{"id", "user_id", "token_hash", "family_id", "expires_at", "revoked_at", "created_at"}
+	now := time.Now()
+
+	t.Run("Active", func(t *testing.T) {
+		mock.ExpectQuery("select (.+) from refresh_tokens where token_hash=?").
+			WithArgs("hash").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 1, "hash", "family", now, nil, now))
+
+		token, err := repo.GetByHash(context.Background(), "hash")
+		assert.NoError(t, err)
+		assert.Equal(t, 7, token.ID)
+		assert.Equal(t, "family", token.FamilyID)
+		assert.Nil(t, token.RevokedAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Revoked", func(t *testing.T) {
+		mock.ExpectQuery("select (.+) from refresh_tokens where token_hash=?").
+			WithArgs("hash").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 1, "hash", "family", now, now, now))
+
+		token, err := repo.GetByHash(context.Background(), "hash")
+		assert.NoError(t, err)
+		assert.NotNil(t, token.RevokedAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		mock.ExpectQuery("select (.+) from refresh_tokens where token_hash=?").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+
+		token, err := repo.GetByHash(context.Background(), "missing")
+		assert.Error(t, err)
+		assert.Nil(t, token)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRefreshTokenRepo(db)
+	query := regexp.QuoteMeta("update refresh_tokens set revoked_at=? where id=? and revoked_at is null")
+
+	t.Run("Revoked", func(t *testing.T) {
+		mock.ExpectExec(query).
+			WithArgs(sqlmock.AnyArg(), 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		revoked, err := repo.Revoke(context.Background(), 7)
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Already revoked", func(t *testing.T) {
+		mock.ExpectExec(query).
+			WithArgs(sqlmock.AnyArg(), 7).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		revoked, err := repo.Revoke(context.Background(), 7)
+		assert.NoError(t, err)
+		assert.False(t, revoked)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Family", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("update refresh_tokens set revoked_at=? where family_id=? and revoked_at is null")).
+			WithArgs(sqlmock.AnyArg(), "family").
+			WillReturnResult(sqlmock.NewResult(0, 3))
+
+		assert.NoError(t, repo.RevokeFamily(context.Background(), "family"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("User", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("update refresh_tokens set revoked_at=? where user_id=? and revoked_at is null")).
+			WithArgs(sqlmock.AnyArg(), 1).
+			WillReturnError(fmt.Errorf("database error"))
+
+		assert.Error(t, repo.RevokeAllForUser(context.Background(), 1))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrReturnNotFound = errors.New("return not found")
+
+type ReturnRepo interface {
+	Create(ctx context.Context, ret *models.Return) error
+	AddItem(ctx context.Context, item *models.ReturnItem) error
+	GetByID(ctx context.Context, id int) (*models.Return, error)
+	Lock(ctx context.Context, id int) (*models.Return, error)
+	List(ctx context.Context, filter models.ReturnFilter) ([]models.Return, error)
+	ListItems(ctx context.Context, returnIDs []int) ([]models.ReturnItem, error)
+	ReturnedQuantities(ctx context.Context, orderID int) (map[int]int, error)
+	Update(ctx context.Context, ret *models.Return) error
+	SetRestocked(ctx context.Context, itemID int) error
+}
+
+type returnRepo struct {
+	db DBTX
+}
+
+func NewReturnRepo(db DBTX) ReturnRepo {
+	return &returnRepo{db: db}
+}
+
+const returnColumns = "id, order_id, user_id, status, reason, note, currency, refund_amount, created_at, updated_at"
+
+func (r *returnRepo) Create(ctx context.Context, ret *models.Return) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into returns (order_id, user_id, status, reason, currency) values (?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, ret.OrderID, nullableID(ret.UserID), ret.Status, ret.Reason, ret.Currency)
+	if err != nil {
+		return fmt.Errorf("failed to insert return: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		ret.ID = int(id)
+	}
+	return nil
+}
+
+func (r *returnRepo) AddItem(ctx context.Context, item *models.ReturnItem) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into return_items (return_id, order_item_id, quantity) values (?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, item.ReturnID, item.OrderItemID, item.Quantity)
+	if err != nil {
+		return fmt.Errorf("failed to insert return item: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		item.ID = int(id)
+	}
+	return nil
+}
+
+// GetByID returns the return without its items
+func (r *returnRepo) GetByID(ctx context.Context, id int) (*models.Return, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	return r.getReturn(ctx, "select "+returnColumns+" from returns where id=?", id)
+}
+
+// Lock reads the return and locks it until the transaction ends, so two
+// members of staff can't handle it at the same time
+func (r *returnRepo) Lock(ctx context.Context, id int) (*models.Return, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	return r.getReturn(ctx, "select "+returnColumns+" from returns where id=? for update", id)
+}
+
+// List returns matching returns newest first, without their items
+func (r *returnRepo) List(ctx context.Context, filter models.ReturnFilter) ([]models.Return, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	var conditions []string
+	var args []interface{}
+	if filter.UserID != 0 {
+		conditions = append(conditions, "user_id=?")
+		args = append(args, filter.UserID)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status=?")
+		args = append(args, filter.Status)
+	}
+	query := "select " + returnColumns + " from returns"
+	if len(conditions) > 0 {
+		query += " where " + strings.Join(conditions, " and ")
+	}
+	query += " order by created_at desc, id desc"
+	if filter.Limit > 0 {
+		query += " limit ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var returns []models.Return
+	for rows.Next() {
+		ret, err := scanReturn(rows)
+		if err != nil {
+			return nil, err
+		}
+		returns = append(returns, *ret)
+	}
+	return returns, rows.Err()
+}
+
+// ListItems returns the items of all the given returns in one query, along
+// with what their order lines say about them
+func (r *returnRepo) ListItems(ctx context.Context, returnIDs []int) ([]models.ReturnItem, error) {
+	if len(returnIDs) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	placeholders, ids := inClause(returnIDs)
+	query := "select ri.id, ri.return_id, ri.order_item_id, oi.product_id, oi.variant_id, coalesce(oi.sku, ''), oi.name, ri.quantity, oi.unit_price, rt.currency, " +
+		"oi.quantity, (select coalesce(sum(e.quantity), 0) from return_items e join returns er on er.id = e.return_id " +
+		"where e.order_item_id = ri.order_item_id and e.return_id < ri.return_id and er.status <> ?), " +
+		"(select coalesce(sum(d.amount), 0) from order_discounts d where d.order_item_id = oi.id), " +
+		"case when o.prices_include_tax then 0 else oi.tax end, ri.restocked " +
+		"from return_items ri join returns rt on rt.id = ri.return_id join order_items oi on oi.id = ri.order_item_id join orders o on o.id = rt.order_id " +
+		"where ri.return_id in (" + placeholders + ") order by ri.return_id, ri.id"
+	rows, err := r.db.QueryContext(ctx, query, append([]interface{}{models.ReturnRejected}, ids...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.ReturnItem
+	for rows.Next() {
+		var item models.ReturnItem
+		var productID, variantID sql.NullInt64
+		err := rows.Scan(&item.ID, &item.ReturnID, &item.OrderItemID, &productID, &variantID, &item.SKU, &item.Name, &item.Quantity,
+			&item.UnitPrice.Amount, &item.UnitPrice.Currency, &item.LineQuantity, &item.LineReturned, &item.LineDiscount.Amount, &item.LineTax.Amount, &item.Restocked)
+		if err != nil {
+			return nil, err
+		}
+		item.LineDiscount.Currency, item.LineTax.Currency = item.UnitPrice.Currency, item.UnitPrice.Currency
+		item.ProductID = nullIntPtr(productID)
+		item.VariantID = nullIntPtr(variantID)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// ReturnedQuantities sums, per order item, what the order's returns ask
+// for. Rejected returns don't count.
+func (r *returnRepo) ReturnedQuantities(ctx context.Context, orderID int) (map[int]int, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select ri.order_item_id, sum(ri.quantity) from return_items ri join returns rt on rt.id = ri.return_id " +
+		"where rt.order_id=? and rt.status<>? group by ri.order_item_id"
+	rows, err := r.db.QueryContext(ctx, query, orderID, models.ReturnRejected)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quantities := make(map[int]int)
+	for rows.Next() {
+		var itemID, quantity int
+		if err := rows.Scan(&itemID, &quantity); err != nil {
+			return nil, err
+		}
+		quantities[itemID] = quantity
+	}
+	return quantities, rows.Err()
+}
+
+// Update saves the status, note and refund amount
+func (r *returnRepo) Update(ctx context.Context, ret *models.Return) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update returns set status=?, note=?, refund_amount=? where id=?"
+	_, err := r.db.ExecContext(ctx, query, ret.Status, nullableString(ret.Note), ret.RefundAmount.Amount, ret.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update return: %w", err)
+	}
+	return nil
+}
+
+func (r *returnRepo) SetRestocked(ctx context.Context, itemID int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, "update return_items set restocked=true where id=?", itemID); err != nil {
+		return fmt.Errorf("failed to update return item: %w", err)
+	}
+	return nil
+}
+
+func (r *returnRepo) getReturn(ctx context.Context, query string, args ...interface{}) (*models.Return, error) {
+	ret, err := scanReturn(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReturnNotFound
+		}
+		return nil, err
+	}
+	return ret, nil
+}
+
+func scanReturn(row rowScanner) (*models.Return, error) {
+	var ret models.Return
+	var userID sql.NullInt64
+	var note sql.NullString
+	err := row.Scan(&ret.ID, &ret.OrderID, &userID, &ret.Status, &ret.Reason, &note, &ret.Currency, &ret.RefundAmount.Amount, &ret.CreatedAt, &ret.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	ret.RefundAmount.Currency = ret.Currency
+	ret.UserID = int(userID.Int64)
+	ret.Note = note.String
+	return &ret, nil
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"ecommerce/models"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReturnedQuantities(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewReturnRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("where rt.order_id=? and rt.status<>? group by ri.order_item_id")).
+		WithArgs(40, models.ReturnRejected).
+		WillReturnRows(sqlmock.NewRows([]string{"order_item_id", "quantity"}).AddRow(1, 2).AddRow(3, 1))
+
+	quantities, err := repo.ReturnedQuantities(context.Background(), 40)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{1: 2, 3: 1}, quantities)
+}
+
+func TestListReturnItems(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewReturnRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("join orders o on o.id = rt.order_id where ri.return_id in (?)")).
+		WithArgs(models.ReturnRejected, 60).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "return_id", "order_item_id", "product_id", "variant_id", "sku", "name", "quantity", "unit_price", "currency",
+			"line_quantity", "line_returned", "line_discount", "line_tax", "restocked"}).
+			AddRow(5, 60, 1, 1, nil, "", "Mug", 2, 950, "USD", 3, 0, 150, 270, true))
+
+	items, err := repo.ListItems(context.Background(), []int{60})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, *items[0].ProductID)
+	assert.Nil(t, items[0].VariantID)
+	assert.True(t, items[0].Restocked)
+	lineTotal, err := items[0].LineTotal()
+	assert.NoError(t, err)
+	assert.Equal(t, models.NewMoney(1900, "USD"), lineTotal)
+	value, err := items[0].Value()
+	assert.NoError(t, err)
+	assert.Equal(t, models.NewMoney(1980, "USD"), value, "two thirds of the line after its discount, with its tax")
+}
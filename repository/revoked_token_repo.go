@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RevokedTokenRepo is the revocation list of access tokens, keyed by jti.
+// Entries are only needed until the token would have expired anyway.
+type RevokedTokenRepo interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	DeleteExpired(ctx context.Context) error
+}
+
+type revokedTokenRepo struct {
+	db DBTX
+}
+
+func NewRevokedTokenRepo(db DBTX) RevokedTokenRepo {
+	return &revokedTokenRepo{db: db}
+}
+
+func (r *revokedTokenRepo) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert ignore into revoked_tokens (jti, expires_at) values (?,?)"
+	_, err := r.db.ExecContext(ctx, query, jti, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+func (r *revokedTokenRepo) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select count(*) from revoked_tokens where jti=?"
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, jti).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *revokedTokenRepo) DeleteExpired(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, "delete from revoked_tokens where expires_at < ?", time.Now())
+	return err
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevokedTokens(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewRevokedTokenRepo(db)
+
+	t.Run("Revoke", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Minute)
+		mock.ExpectExec("insert ignore into revoked_tokens").
+			WithArgs("jti-1", expiresAt).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		assert.NoError(t, repo.Revoke(context.Background(), "jti-1", expiresAt))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("IsRevoked", func(t *testing.T) {
+		mock.ExpectQuery("select count\\(\\*\\) from revoked_tokens where jti=?").
+			WithArgs("jti-1").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		revoked, err := repo.IsRevoked(context.Background(), "jti-1")
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Not revoked", func(t *testing.T) {
+		mock.ExpectQuery("select count\\(\\*\\) from revoked_tokens where jti=?").
+			WithArgs("jti-2").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		revoked, err := repo.IsRevoked(context.Background(), "jti-2")
+		assert.NoError(t, err)
+		assert.False(t, revoked)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Lookup failure", func(t *testing.T) {
+		mock.ExpectQuery("select count\\(\\*\\) from revoked_tokens where jti=?").
+			WithArgs("jti-3").
+			WillReturnError(fmt.Errorf("database error"))
+
+		_, err := repo.IsRevoked(context.Background(), "jti-3")
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("DeleteExpired", func(t *testing.T) {
+		mock.ExpectExec("delete from revoked_tokens where expires_at < ?").
+			WillReturnResult(sqlmock.NewResult(0, 4))
+
+		assert.NoError(t, repo.DeleteExpired(context.Background()))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,358 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrShippingZoneNotFound   = errors.New("shipping zone not found")
+	ErrShippingMethodNotFound = errors.New("shipping method not found")
+)
+
+// ShippingRepo keeps the shipping zones and the rate tables of their methods
+type ShippingRepo interface {
+	ListZones(ctx context.Context) ([]models.ShippingZone, error)
+	GetZone(ctx context.Context, id int) (*models.ShippingZone, error)
+	CreateZone(ctx context.Context, zone *models.ShippingZone) error
+	UpdateZone(ctx context.Context, zone *models.ShippingZone) error
+	DeleteZone(ctx context.Context, id int) error
+
+	ListMethods(ctx context.Context, filter models.ShippingMethodFilter) ([]models.ShippingMethod, error)
+	GetMethod(ctx context.Context, id int) (*models.ShippingMethod, error)
+	CreateMethod(ctx context.Context, method *models.ShippingMethod) error
+	UpdateMethod(ctx context.Context, method *models.ShippingMethod) error
+	DeleteMethod(ctx context.Context, id int) error
+}
+
+type shippingRepo struct {
+	db DBTX
+}
+
+func NewShippingRepo(db DBTX) ShippingRepo {
+	return &shippingRepo{db: db}
+}
+
+const (
+	shippingZoneColumns   = "id, name, created_at, updated_at"
+	shippingMethodColumns = "id, zone_id, name, kind, currency, estimated_days, active, created_at, updated_at"
+)
+
+// ListZones returns every zone with its locations, ordered by name
+func (r *shippingRepo) ListZones(ctx context.Context) ([]models.ShippingZone, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "select "+shippingZoneColumns+" from shipping_zones order by name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var zones []models.ShippingZone
+	for rows.Next() {
+		zone, err := scanShippingZone(rows)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, *zone)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return zones, r.loadLocations(ctx, zones)
+}
+
+func (r *shippingRepo) GetZone(ctx context.Context, id int) (*models.ShippingZone, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	zone, err := scanShippingZone(r.db.QueryRowContext(ctx, "select "+shippingZoneColumns+" from shipping_zones where id=?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrShippingZoneNotFound
+		}
+		return nil, err
+	}
+	zones := []models.ShippingZone{*zone}
+	if err := r.loadLocations(ctx, zones); err != nil {
+		return nil, err
+	}
+	return &zones[0], nil
+}
+
+// CreateZone inserts the zone and its locations, run it in a transaction so
+// a zone is never seen without its locations
+func (r *shippingRepo) CreateZone(ctx context.Context, zone *models.ShippingZone) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "insert into shipping_zones (name) values (?)", zone.Name)
+	if err != nil {
+		return fmt.Errorf("failed to insert shipping zone: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	zone.ID = int(id)
+	return r.insertLocations(ctx, zone)
+}
+
+// UpdateZone renames the zone and replaces its locations, run it in a
+// transaction like CreateZone
+func (r *shippingRepo) UpdateZone(ctx context.Context, zone *models.ShippingZone) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, "update shipping_zones set name=? where id=?", zone.Name, zone.ID); err != nil {
+		return fmt.Errorf("failed to update shipping zone: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, "delete from shipping_zone_locations where zone_id=?", zone.ID); err != nil {
+		return fmt.Errorf("failed to clear shipping zone locations: %w", err)
+	}
+	return r.insertLocations(ctx, zone)
+}
+
+// DeleteZone removes the zone along with its locations and methods
+func (r *shippingRepo) DeleteZone(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "delete from shipping_zones where id=?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete shipping zone: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrShippingZoneNotFound
+	}
+	return nil
+}
+
+// ListMethods returns the methods with their tiers, by zone and name
+func (r *shippingRepo) ListMethods(ctx context.Context, filter models.ShippingMethodFilter) ([]models.ShippingMethod, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	var conditions []string
+	var args []interface{}
+	if filter.ZoneID != 0 {
+		conditions = append(conditions, "zone_id=?")
+		args = append(args, filter.ZoneID)
+	}
+	if filter.ActiveOnly {
+		conditions = append(conditions, "active")
+	}
+	query := "select " + shippingMethodColumns + " from shipping_methods"
+	if len(conditions) > 0 {
+		query += " where " + strings.Join(conditions, " and ")
+	}
+	query += " order by zone_id, name"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var methods []models.ShippingMethod
+	for rows.Next() {
+		method, err := scanShippingMethod(rows)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, *method)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return methods, r.loadTiers(ctx, methods)
+}
+
+func (r *shippingRepo) GetMethod(ctx context.Context, id int) (*models.ShippingMethod, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	method, err := scanShippingMethod(r.db.QueryRowContext(ctx, "select "+shippingMethodColumns+" from shipping_methods where id=?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrShippingMethodNotFound
+		}
+		return nil, err
+	}
+	methods := []models.ShippingMethod{*method}
+	if err := r.loadTiers(ctx, methods); err != nil {
+		return nil, err
+	}
+	return &methods[0], nil
+}
+
+// CreateMethod inserts the method and its rate table, run it in a transaction
+func (r *shippingRepo) CreateMethod(ctx context.Context, method *models.ShippingMethod) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into shipping_methods (zone_id, name, kind, currency, estimated_days, active) values (?,?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, method.ZoneID, method.Name, method.Kind, method.Currency, method.EstimatedDays, method.Active)
+	if err != nil {
+		return fmt.Errorf("failed to insert shipping method: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	method.ID = int(id)
+	return r.insertTiers(ctx, method)
+}
+
+// UpdateMethod replaces the method and its rate table, run it in a transaction
+func (r *shippingRepo) UpdateMethod(ctx context.Context, method *models.ShippingMethod) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update shipping_methods set zone_id=?, name=?, kind=?, currency=?, estimated_days=?, active=? where id=?"
+	_, err := r.db.ExecContext(ctx, query, method.ZoneID, method.Name, method.Kind, method.Currency, method.EstimatedDays, method.Active, method.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update shipping method: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, "delete from shipping_rate_tiers where method_id=?", method.ID); err != nil {
+		return fmt.Errorf("failed to clear shipping rate tiers: %w", err)
+	}
+	return r.insertTiers(ctx, method)
+}
+
+func (r *shippingRepo) DeleteMethod(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "delete from shipping_methods where id=?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete shipping method: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrShippingMethodNotFound
+	}
+	return nil
+}
+
+func (r *shippingRepo) insertLocations(ctx context.Context, zone *models.ShippingZone) error {
+	if len(zone.Locations) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(zone.Locations))
+	args := make([]interface{}, 0, 4*len(zone.Locations))
+	for i, location := range zone.Locations {
+		placeholders[i] = "(?,?,?,?)"
+		args = append(args, zone.ID, location.Country, location.Region, location.Postcode)
+	}
+	query := "insert into shipping_zone_locations (zone_id, country, region, postcode) values " + strings.Join(placeholders, ",")
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to insert shipping zone locations: %w", err)
+	}
+	return nil
+}
+
+// loadLocations fills in the locations of all the zones in one query
+func (r *shippingRepo) loadLocations(ctx context.Context, zones []models.ShippingZone) error {
+	if len(zones) == 0 {
+		return nil
+	}
+	ids := make([]int, len(zones))
+	index := make(map[int]int, len(zones))
+	for i, zone := range zones {
+		ids[i] = zone.ID
+		index[zone.ID] = i
+	}
+	placeholders, args := inClause(ids)
+
+	query := "select zone_id, country, region, postcode from shipping_zone_locations where zone_id in (" + placeholders + ") order by 1, 2, 3, 4"
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var zoneID int
+		var location models.ZoneLocation
+		if err := rows.Scan(&zoneID, &location.Country, &location.Region, &location.Postcode); err != nil {
+			return err
+		}
+		zone := &zones[index[zoneID]]
+		zone.Locations = append(zone.Locations, location)
+	}
+	return rows.Err()
+}
+
+func (r *shippingRepo) insertTiers(ctx context.Context, method *models.ShippingMethod) error {
+	if len(method.Tiers) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(method.Tiers))
+	args := make([]interface{}, 0, 3*len(method.Tiers))
+	for i, tier := range method.Tiers {
+		placeholders[i] = "(?,?,?)"
+		args = append(args, method.ID, tier.Min, tier.Price.Amount)
+	}
+	query := "insert into shipping_rate_tiers (method_id, min_value, price) values " + strings.Join(placeholders, ",")
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to insert shipping rate tiers: %w", err)
+	}
+	return nil
+}
+
+// loadTiers fills in the rate tables of all the methods in one query, tier
+// prices are in the method's currency
+func (r *shippingRepo) loadTiers(ctx context.Context, methods []models.ShippingMethod) error {
+	if len(methods) == 0 {
+		return nil
+	}
+	ids := make([]int, len(methods))
+	index := make(map[int]int, len(methods))
+	for i, method := range methods {
+		ids[i] = method.ID
+		index[method.ID] = i
+	}
+	placeholders, args := inClause(ids)
+
+	query := "select method_id, min_value, price from shipping_rate_tiers where method_id in (" + placeholders + ") order by 1, 2"
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var methodID int
+		var tier models.ShippingTier
+		if err := rows.Scan(&methodID, &tier.Min, &tier.Price.Amount); err != nil {
+			return err
+		}
+		method := &methods[index[methodID]]
+		tier.Price.Currency = method.Currency
+		method.Tiers = append(method.Tiers, tier)
+	}
+	return rows.Err()
+}
+
+func scanShippingZone(row rowScanner) (*models.ShippingZone, error) {
+	var zone models.ShippingZone
+	if err := row.Scan(&zone.ID, &zone.Name, &zone.CreatedAt, &zone.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &zone, nil
+}
+
+func scanShippingMethod(row rowScanner) (*models.ShippingMethod, error) {
+	var method models.ShippingMethod
+	err := row.Scan(&method.ID, &method.ZoneID, &method.Name, &method.Kind, &method.Currency, &method.EstimatedDays, &method.Active,
+		&method.CreatedAt, &method.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &method, nil
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"ecommerce/models"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListShippingZones(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewShippingRepo(db)
+
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta("select " + shippingZoneColumns + " from shipping_zones order by name")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at"}).
+			AddRow(1, "Domestic", now, now).
+			AddRow(2, "Europe", now, now))
+	mock.ExpectQuery(regexp.QuoteMeta("select zone_id, country, region, postcode from shipping_zone_locations where zone_id in (?,?)")).
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"zone_id", "country", "region", "postcode"}).
+			AddRow(1, "US", "", "").
+			AddRow(2, "DE", "", "").
+			AddRow(2, "FR", "", ""))
+
+	zones, err := repo.ListZones(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, zones, 2)
+	assert.Equal(t, []models.ZoneLocation{{Country: "US"}}, zones[0].Locations)
+	assert.Len(t, zones[1].Locations, 2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateShippingMethod(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewShippingRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("insert into shipping_methods (zone_id, name, kind, currency, estimated_days, active) values (?,?,?,?,?,?)")).
+		WithArgs(1, "Standard", models.ShippingByWeight, "USD", 5, true).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec(regexp.QuoteMeta("insert into shipping_rate_tiers (method_id, min_value, price) values (?,?,?),(?,?,?)")).
+		WithArgs(3, int64(0), int64(500), 3, int64(2000), int64(900)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	method := &models.ShippingMethod{ZoneID: 1, Name: "Standard", Kind: models.ShippingByWeight, Currency: "USD", EstimatedDays: 5, Active: true,
+		Tiers: []models.ShippingTier{{Min: 0, Price: models.NewMoney(500, "USD")}, {Min: 2000, Price: models.NewMoney(900, "USD")}}}
+	assert.NoError(t, repo.CreateMethod(context.Background(), method))
+	assert.Equal(t, 3, method.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetShippingMethod(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewShippingRepo(db)
+
+	now := time.Now()
+	columns := []string{"id", "zone_id", "name", "kind", "currency", "estimated_days", "active", "created_at", "updated_at"}
+	mock.ExpectQuery(regexp.QuoteMeta("select " + shippingMethodColumns + " from shipping_methods where id=?")).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 1, "Standard", "price", "EUR", 3, true, now, now))
+	mock.ExpectQuery(regexp.QuoteMeta("select method_id, min_value, price from shipping_rate_tiers where method_id in (?)")).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"method_id", "min_value", "price"}).
+			AddRow(3, 0, 490).
+			AddRow(3, 5000, 0))
+
+	method, err := repo.GetMethod(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ShippingByPrice, method.Kind)
+	assert.Equal(t, []models.ShippingTier{{Min: 0, Price: models.NewMoney(490, "EUR")}, {Min: 5000, Price: models.NewMoney(0, "EUR")}}, method.Tiers)
+
+	mock.ExpectQuery(regexp.QuoteMeta("select " + shippingMethodColumns + " from shipping_methods where id=?")).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(columns))
+	_, err = repo.GetMethod(context.Background(), 4)
+	assert.ErrorIs(t, err, ErrShippingMethodNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrTaxRateNotFound = errors.New("tax rate not found")
+
+// TaxRepo keeps the tax classes and the rate table
+type TaxRepo interface {
+	ListClasses(ctx context.Context) ([]models.TaxClass, error)
+	CreateClass(ctx context.Context, class *models.TaxClass) error
+
+	ListRates(ctx context.Context, filter models.TaxRateFilter) ([]models.TaxRate, error)
+	GetRate(ctx context.Context, id int) (*models.TaxRate, error)
+	CreateRate(ctx context.Context, rate *models.TaxRate) error
+	UpdateRate(ctx context.Context, rate *models.TaxRate) error
+	DeleteRate(ctx context.Context, id int) error
+}
+
+type taxRepo struct {
+	db DBTX
+}
+
+func NewTaxRepo(db DBTX) TaxRepo {
+	return &taxRepo{db: db}
+}
+
+const taxRateColumns = "id, tax_class, country, region, postcode, rate, name, created_at, updated_at"
+
+func (r *taxRepo) ListClasses(ctx context.Context) ([]models.TaxClass, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "select code, name, created_at from tax_classes order by code")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var classes []models.TaxClass
+	for rows.Next() {
+		var class models.TaxClass
+		if err := rows.Scan(&class.Code, &class.Name, &class.CreatedAt); err != nil {
+			return nil, err
+		}
+		classes = append(classes, class)
+	}
+	return classes, rows.Err()
+}
+
+func (r *taxRepo) CreateClass(ctx context.Context, class *models.TaxClass) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, "insert into tax_classes (code, name) values (?,?)", class.Code, class.Name); err != nil {
+		return fmt.Errorf("failed to insert tax class: %w", err)
+	}
+	return nil
+}
+
+// ListRates returns the rate table ordered by class and jurisdiction
+func (r *taxRepo) ListRates(ctx context.Context, filter models.TaxRateFilter) ([]models.TaxRate, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	var conditions []string
+	var args []interface{}
+	if filter.TaxClass != "" {
+		conditions = append(conditions, "tax_class=?")
+		args = append(args, filter.TaxClass)
+	}
+	if filter.Country != "" {
+		conditions = append(conditions, "country=?")
+		args = append(args, filter.Country)
+	}
+	query := "select " + taxRateColumns + " from tax_rates"
+	if len(conditions) > 0 {
+		query += " where " + strings.Join(conditions, " and ")
+	}
+	query += " order by tax_class, country, region, postcode"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []models.TaxRate
+	for rows.Next() {
+		rate, err := scanTaxRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, *rate)
+	}
+	return rates, rows.Err()
+}
+
+func (r *taxRepo) GetRate(ctx context.Context, id int) (*models.TaxRate, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	rate, err := scanTaxRate(r.db.QueryRowContext(ctx, "select "+taxRateColumns+" from tax_rates where id=?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTaxRateNotFound
+		}
+		return nil, err
+	}
+	return rate, nil
+}
+
+func (r *taxRepo) CreateRate(ctx context.Context, rate *models.TaxRate) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into tax_rates (tax_class, country, region, postcode, rate, name) values (?,?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, rate.TaxClass, rate.Country, rate.Region, rate.Postcode, rate.Rate.String(), rate.Name)
+	if err != nil {
+		return fmt.Errorf("failed to insert tax rate: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		rate.ID = int(id)
+	}
+	return nil
+}
+
+func (r *taxRepo) UpdateRate(ctx context.Context, rate *models.TaxRate) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update tax_rates set tax_class=?, country=?, region=?, postcode=?, rate=?, name=? where id=?"
+	if _, err := r.db.ExecContext(ctx, query, rate.TaxClass, rate.Country, rate.Region, rate.Postcode, rate.Rate.String(), rate.Name, rate.ID); err != nil {
+		return fmt.Errorf("failed to update tax rate: %w", err)
+	}
+	return nil
+}
+
+func (r *taxRepo) DeleteRate(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "delete from tax_rates where id=?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete tax rate: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrTaxRateNotFound
+	}
+	return nil
+}
+
+func scanTaxRate(row rowScanner) (*models.TaxRate, error) {
+	var rate models.TaxRate
+	var value string
+	if err := row.Scan(&rate.ID, &rate.TaxClass, &rate.Country, &rate.Region, &rate.Postcode, &value, &rate.Name, &rate.CreatedAt, &rate.UpdatedAt); err != nil {
+		return nil, err
+	}
+	parsed, err := models.ParsePercent(value)
+	if err != nil {
+		return nil, err
+	}
+	rate.Rate = parsed
+	return &rate, nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"ecommerce/models"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListTaxRates(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewTaxRepo(db)
+
+	now := time.Now()
+	columns := []string{"id", "tax_class", "country", "region", "postcode", "rate", "name", "created_at", "updated_at"}
+	mock.ExpectQuery(regexp.QuoteMeta("select " + taxRateColumns + " from tax_rates where country=? order by tax_class, country, region, postcode")).
+		WithArgs("US").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "standard", "US", "CA", "", "7.2500", "CA sales tax", now, now).
+			AddRow(2, "standard", "US", "NY", "100*", "8.8750", "NYC sales tax", now, now))
+
+	rates, err := repo.ListRates(context.Background(), models.TaxRateFilter{Country: "US"})
+	assert.NoError(t, err)
+	assert.Len(t, rates, 2)
+	assert.Equal(t, models.Percent(72500), rates[0].Rate)
+	assert.Equal(t, "100*", rates[1].Postcode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateTaxRate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewTaxRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("insert into tax_rates (tax_class, country, region, postcode, rate, name) values (?,?,?,?,?,?)")).
+		WithArgs("reduced", "FR", "", "", "5.5", "TVA").
+		WillReturnResult(sqlmock.NewResult(4, 1))
+
+	rate := &models.TaxRate{TaxClass: "reduced", Country: "FR", Rate: 55000, Name: "TVA"}
+	assert.NoError(t, repo.CreateRate(context.Background(), rate))
+	assert.Equal(t, 4, rate.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteTaxRate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewTaxRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("delete from tax_rates where id=?")).
+		WithArgs(9).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.ErrorIs(t, repo.DeleteRate(context.Background(), 9), ErrTaxRateNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
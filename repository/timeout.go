@@ -0,0 +1,10 @@
+package repository
+
+import "time"
+
+// Per-operation deadlines applied with context.WithTimeout. They only shorten
+// the caller's context, a cancelled request or a tighter deadline stops sooner.
+const (
+	queryTimeout = 3 * time.Second  // single row reads and writes
+	listTimeout  = 10 * time.Second // unbounded listings
+)
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DBTX is the subset of *sql.DB and *sql.Tx the repositories use, so the same
+// repository code runs standalone or inside a transaction
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// MySQL errors that abort a transaction which can safely be run again
+const (
+	errLockWaitTimeout = 1205
+	errDeadlock        = 1213
+)
+
+const (
+	defaultTxAttempts = 3
+	txRetryBackoff    = 20 * time.Millisecond
+)
+
+// Repos is the set of repositories bound to one connection or transaction
+type Repos struct {
+	Products      ProductRepo
+	Users         UserRepo
+	RefreshTokens RefreshTokenRepo
+	RevokedTokens RevokedTokenRepo
+	Categories    CategoryRepo
+	Variants      VariantRepo
+	Inventory     InventoryRepo
+	Warehouses    WarehouseRepo
+	Carts         CartRepo
+	Orders        OrderRepo
+	Payments      PaymentRepo
+	Returns       ReturnRepo
+	Prices        PriceRepo
+	ExchangeRates ExchangeRateRepo
+	Promotions    PromotionRepo
+	Taxes         TaxRepo
+	Shipping      ShippingRepo
+	Addresses     AddressRepo
+
+	tx         *sql.Tx
+	savepoints *int // shared by every nesting level of one transaction
+}
+
+func NewRepos(db DBTX) Repos {
+	return Repos{
+		Products:      NewProductRepo(db),
+		Users:         NewUserRepo(db),
+		RefreshTokens: NewRefreshTokenRepo(db),
+		RevokedTokens: NewRevokedTokenRepo(db),
+		Categories:    NewCategoryRepo(db),
+		Variants:      NewVariantRepo(db),
+		Inventory:     NewInventoryRepo(db),
+		Warehouses:    NewWarehouseRepo(db),
+		Carts:         NewCartRepo(db),
+		Orders:        NewOrderRepo(db),
+		Payments:      NewPaymentRepo(db),
+		Returns:       NewReturnRepo(db),
+		Prices:        NewPriceRepo(db),
+		ExchangeRates: NewExchangeRateRepo(db),
+		Promotions:    NewPromotionRepo(db),
+		Taxes:         NewTaxRepo(db),
+		Shipping:      NewShippingRepo(db),
+		Addresses:     NewAddressRepo(db),
+	}
+}
+
+// WithTx runs fn in a savepoint of the transaction r is bound to: an error
+// from fn only undoes fn's own writes, the outer transaction carries on.
+// Repos not bound to a transaction (e.g. hand-built in tests) just call fn.
+func (r Repos) WithTx(ctx context.Context, fn func(tx Repos) error) error {
+	if r.tx == nil {
+		return fn(r)
+	}
+
+	*r.savepoints++
+	name := fmt.Sprintf("sp_%d", *r.savepoints)
+	if _, err := r.tx.ExecContext(ctx, "savepoint "+name); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	if err := fn(r); err != nil {
+		if _, rbErr := r.tx.ExecContext(ctx, "rollback to savepoint "+name); rbErr != nil {
+			return fmt.Errorf("%w (rollback to savepoint failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	if _, err := r.tx.ExecContext(ctx, "release savepoint "+name); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
+
+// TxManager runs a unit of work atomically across repositories
+type TxManager interface {
+	WithTx(ctx context.Context, fn func(tx Repos) error) error
+}
+
+type txManager struct {
+	db          *sql.DB
+	maxAttempts int
+}
+
+func NewTxManager(db *sql.DB) TxManager {
+	return &txManager{db: db, maxAttempts: defaultTxAttempts}
+}
+
+// WithTx commits when fn returns nil and rolls back otherwise. Deadlocks and
+// lock wait timeouts roll back and run fn again, so fn must not have side
+// effects outside the transaction.
+func (m *txManager) WithTx(ctx context.Context, fn func(tx Repos) error) error {
+	var err error
+	for attempt := 1; attempt <= m.maxAttempts; attempt++ {
+		err = m.run(ctx, fn)
+		if err == nil || !IsRetryable(err) {
+			return err
+		}
+		log.Printf("transaction attempt %d failed, retrying: %v", attempt, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * txRetryBackoff):
+		}
+	}
+	return err
+}
+
+func (m *txManager) run(ctx context.Context, fn func(tx Repos) error) (err error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	repos := NewRepos(tx)
+	repos.tx = tx
+	repos.savepoints = new(int)
+
+	if err := fn(repos); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+const (
+	errDuplicateEntry  = 1062
+	errNoReferencedRow = 1452
+)
+
+// IsDuplicate reports whether err is a unique key violation
+func IsDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateEntry
+}
+
+// IsMissingReference reports whether err is a foreign key pointing at a row that doesn't exist
+func IsMissingReference(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errNoReferencedRow
+}
+
+// IsRetryable reports whether err is a MySQL deadlock or lock wait timeout
+func IsRetryable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == errDeadlock || mysqlErr.Number == errLockWaitTimeout
+	}
+	return false
+}
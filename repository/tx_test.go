@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+var deadlock = &mysql.MySQLError{Number: errDeadlock, Message: "Deadlock found when trying to get lock"}
+
+func TestWithTx(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	txManager := NewTxManager(db)
+	ctx := context.Background()
+
+	t.Run("Commit", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("delete from products where id=?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("delete from users where id=?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := txManager.WithTx(ctx, func(tx Repos) error {
+			if err := tx.Products.Delete(ctx, 1); err != nil {
+				return err
+			}
+			return tx.Users.Delete(ctx, 2)
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("delete from products where id=?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("delete from users where id=?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err := txManager.WithTx(ctx, func(tx Repos) error {
+			if err := tx.Products.Delete(ctx, 1); err != nil {
+				return err
+			}
+			return tx.Users.Delete(ctx, 2) // not found, undoes the product delete
+		})
+		assert.EqualError(t, err, "user with id 2 not found")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Nested savepoints", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("savepoint sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("delete from products where id=?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("release savepoint sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("savepoint sp_2").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("delete from products where id=?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("rollback to savepoint sp_2").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := txManager.WithTx(ctx, func(tx Repos) error {
+			err := tx.WithTx(ctx, func(tx Repos) error {
+				return tx.Products.Delete(ctx, 1)
+			})
+			assert.NoError(t, err)
+
+			err = tx.WithTx(ctx, func(tx Repos) error {
+				return tx.Products.Delete(ctx, 2)
+			})
+			assert.Error(t, err) // only the inner unit is undone
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Retries deadlock", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("delete from products where id=?").WithArgs(1).WillReturnError(deadlock)
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectExec("delete from products where id=?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		attempts := 0
+		err := txManager.WithTx(ctx, func(tx Repos) error {
+			attempts++
+			return tx.Products.Delete(ctx, 1)
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Gives up after max attempts", func(t *testing.T) {
+		for i := 0; i < defaultTxAttempts; i++ {
+			mock.ExpectBegin()
+			mock.ExpectExec("delete from products where id=?").WithArgs(1).WillReturnError(deadlock)
+			mock.ExpectRollback()
+		}
+
+		err := txManager.WithTx(ctx, func(tx Repos) error {
+			return tx.Products.Delete(ctx, 1)
+		})
+		assert.True(t, IsRetryable(err))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Other errors are not retried", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		attempts := 0
+		err := txManager.WithTx(ctx, func(tx Repos) error {
+			attempts++
+			return errors.New("validation failed")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, attempts)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Panic rolls back", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		assert.Panics(t, func() {
+			txManager.WithTx(ctx, func(tx Repos) error { panic("boom") })
+		})
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(deadlock))
+	assert.True(t, IsRetryable(fmt.Errorf("failed to delete product: %w", &mysql.MySQLError{Number: errLockWaitTimeout})))
+	assert.False(t, IsRetryable(&mysql.MySQLError{Number: 1062})) // duplicate entry
+	assert.False(t, IsRetryable(errors.New("deadlock")))
+}
+
+func TestReposWithoutTx(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// outside a transaction WithTx just runs fn, no savepoint statements
+	mock.ExpectExec(regexp.QuoteMeta("delete from products where id=?")).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	err = NewRepos(db).WithTx(context.Background(), func(tx Repos) error {
+		return tx.Products.Delete(context.Background(), 1)
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
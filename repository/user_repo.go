@@ -1,43 +1,50 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"ecommerce/models"
 	"fmt"
 )
 
 type UserRepo interface {
-	Create(user *models.User) error
-	GetByID(id int) (*models.User, error)
-	GetByUsername(username string) (*models.User, error)
-	GetAll() ([]models.User, error)
-	Update(user *models.User) error
-	Delete(id int) error
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id int) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	GetAll(ctx context.Context) ([]models.User, error)
+	Update(ctx context.Context, user *models.User) error
+	Delete(ctx context.Context, id int) error
 }
 
 type userRepo struct {
-	db *sql.DB // hold the database connection
+	db DBTX // hold the database connection
 }
 
-func NewUserRepo(db *sql.DB) UserRepo { // constructor
+func NewUserRepo(db DBTX) UserRepo { // constructor
 	return &userRepo{db: db}
 }
 
-func (r *userRepo) Create(user *models.User) error {
-	query := "insert into users (name, email, username, password) values (?,?,?,?)"
-	_, err := r.db.Exec(query, user.Name, user.Email, user.Username, user.Password)
+func (r *userRepo) Create(ctx context.Context, user *models.User) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "insert into users (name, email, username, password, role) values (?,?,?,?,?)"
+	_, err := r.db.ExecContext(ctx, query, user.Name, user.Email, user.Username, user.Password, user.Role)
 	if err != nil {
-		return fmt.Errorf("failed to insert user: %v", err)
+		return fmt.Errorf("failed to insert user: %w", err)
 	}
 	return nil
 }
 
-func (r *userRepo) GetByID(id int) (*models.User, error) {
-	query := "select id, name, email, username, password from users where id=?"
-	row := r.db.QueryRow(query, id)
+func (r *userRepo) GetByID(ctx context.Context, id int) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select id, name, email, username, password, role from users where id=?"
+	row := r.db.QueryRowContext(ctx, query, id)
 
 	var user models.User
-	err := row.Scan(&user.Id, &user.Name, &user.Email, &user.Username, &user.Password)
+	err := row.Scan(&user.Id, &user.Name, &user.Email, &user.Username, &user.Password, &user.Role)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("user not found")
@@ -47,20 +54,26 @@ func (r *userRepo) GetByID(id int) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *userRepo) GetByUsername(username string) (*models.User, error) {
-	query := "select id, name, email, username, password from users where username=?"
-	row := r.db.QueryRow(query, username)
+func (r *userRepo) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "select id, name, email, username, password, role from users where username=?"
+	row := r.db.QueryRowContext(ctx, query, username)
 	var user models.User
-	err := row.Scan(&user.Id, &user.Name, &user.Email, &user.Username, &user.Password)
+	err := row.Scan(&user.Id, &user.Name, &user.Email, &user.Username, &user.Password, &user.Role)
 	if err != nil {
 		return nil, fmt.Errorf("user not found")
 	}
 	return &user, nil
 }
 
-func (r *userRepo) GetAll() ([]models.User, error) {
-	query := "select id, name, email, username, password from users"
-	rows, err := r.db.Query(query)
+func (r *userRepo) GetAll(ctx context.Context) ([]models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	query := "select id, name, email, username, password, role from users"
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -69,25 +82,34 @@ func (r *userRepo) GetAll() ([]models.User, error) {
 	var users []models.User
 	for rows.Next() {
 		var user models.User
-		if err := rows.Scan(&user.Id, &user.Name, &user.Email, &user.Username, &user.Password); err != nil {
+		if err := rows.Scan(&user.Id, &user.Name, &user.Email, &user.Username, &user.Password, &user.Role); err != nil {
 			return nil, err
 		}
 		users = append(users, user)
 	}
+	if err := rows.Err(); err != nil { // e.g. the deadline hit mid-iteration
+		return nil, err
+	}
 	return users, nil
 }
 
-func (r *userRepo) Update(user *models.User) error {
-	query := "update users set name=?, email=?, username=?, password=? where id=?"
-	_, err := r.db.Exec(query, user.Name, user.Email, user.Username, user.Password, user.Id)
+func (r *userRepo) Update(ctx context.Context, user *models.User) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := "update users set name=?, email=?, username=?, password=?, role=? where id=?"
+	_, err := r.db.ExecContext(ctx, query, user.Name, user.Email, user.Username, user.Password, user.Role, user.Id)
 	return err
 }
 
-func (r *userRepo) Delete(id int) error {
+func (r *userRepo) Delete(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
 	query := "delete from users where id=?"
-	result, err := r.db.Exec(query, id)
+	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		return fmt.Errorf("failed to delete user : %v", err)
+		return fmt.Errorf("failed to delete user : %w", err)
 	}
 
 	rowsAffected, _ := result.RowsAffected()
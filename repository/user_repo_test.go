@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"ecommerce/models"
 	"fmt"
@@ -24,27 +25,28 @@ func TestCreateUser(t *testing.T) {
 		Email:    "abhay123@gmail.com",
 		Username: "abhay123",
 		Password: "abhay@123",
+		Role:     models.RoleCustomer,
 	}
 
 	repo := NewUserRepo(db) // inject mock db
 
 	t.Run("Success", func(t *testing.T) {
 		mock.ExpectExec("insert into users"). //expect an insert statement
-							WithArgs(user.Name, user.Email, user.Username, user.Password). //expected arguments
-							WillReturnResult(sqlmock.NewResult(1, 1))                      // returning a mock result.
+							WithArgs(user.Name, user.Email, user.Username, user.Password, user.Role). //expected arguments
+							WillReturnResult(sqlmock.NewResult(1, 1))                                 // returning a mock result.
 		// 1 → The inserted row ID.
 		// 1 → One row affected (successful insert).
 
-		err = repo.Create(user)
+		err = repo.Create(context.Background(), user)
 		assert.NoError(t, err) // checks if the method returns an error.
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 	t.Run("Failer", func(t *testing.T) {
 		mock.ExpectExec("insert into users").
-			WithArgs(user.Name, user.Email, user.Username, user.Password).
+			WithArgs(user.Name, user.Email, user.Username, user.Password, user.Role).
 			WillReturnError(fmt.Errorf("failed to insert user"))
 
-		err = repo.Create(user)
+		err = repo.Create(context.Background(), user)
 		assert.Error(t, err) // error due to failed query
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
@@ -57,11 +59,11 @@ func TestGetByIdUser(t *testing.T) {
 
 	repo := NewUserRepo(db)
 	t.Run("Found", func(t *testing.T) {
-		mock.ExpectQuery("select id, name, email, username, password from users where id=?").
+		mock.ExpectQuery("select id, name, email, username, password, role from users where id=?").
 			WithArgs(1). // query should be called with id=1
-			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "username", "password"}).AddRow(1, "Abhay", "abhay123@gmail.com", "abhay123", "abhay@123"))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "username", "password", "role"}).AddRow(1, "Abhay", "abhay123@gmail.com", "abhay123", "abhay@123", "customer"))
 
-		user, err := repo.GetByID(1)
+		user, err := repo.GetByID(context.Background(), 1)
 
 		assert.NoError(t, err) // should not return an error
 		assert.NotNil(t, user) // returned user should not be nil
@@ -71,21 +73,21 @@ func TestGetByIdUser(t *testing.T) {
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 	t.Run("Scan error", func(t *testing.T) {
-		mock.ExpectQuery("select id, name, email, username, password from users where id=?").
+		mock.ExpectQuery("select id, name, email, username, password, role from users where id=?").
 			WithArgs(1).                                                               // query should be called with id=1
 			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Abhay")) // Missing required columns
 
-		user, err := repo.GetByID(1)
+		user, err := repo.GetByID(context.Background(), 1)
 		assert.Error(t, err) // error due to scan failure
 		assert.Nil(t, user)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 	t.Run("NotFound", func(t *testing.T) {
-		mock.ExpectQuery("select id, name, email, username, password from users where id=?").
+		mock.ExpectQuery("select id, name, email, username, password, role from users where id=?").
 			WithArgs(90).
 			WillReturnError(sql.ErrNoRows) // "database/sql"
 
-		user, err := repo.GetByID(90)
+		user, err := repo.GetByID(context.Background(), 90)
 
 		assert.Error(t, err) // function must return an error
 		assert.Nil(t, user)  // user should be nil.
@@ -102,11 +104,11 @@ func TestGetByUsernameUser(t *testing.T) {
 
 	repo := NewUserRepo(db)
 	t.Run("Found", func(t *testing.T) {
-		mock.ExpectQuery("select id, name, email, username, password from users where username=?").
+		mock.ExpectQuery("select id, name, email, username, password, role from users where username=?").
 			WithArgs("abhay123").
-			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "username", "password"}).AddRow(1, "Abhay", "abhay123@gmail.com", "abhay123", "abhay@123"))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "username", "password", "role"}).AddRow(1, "Abhay", "abhay123@gmail.com", "abhay123", "abhay@123", "customer"))
 
-		user, err := repo.GetByUsername("abhay123")
+		user, err := repo.GetByUsername(context.Background(), "abhay123")
 
 		assert.NoError(t, err)
 		assert.NotNil(t, user)
@@ -118,11 +120,11 @@ func TestGetByUsernameUser(t *testing.T) {
 	})
 
 	t.Run("NotFound", func(t *testing.T) {
-		mock.ExpectQuery("select id, name, email, username, password from users where username=?").
+		mock.ExpectQuery("select id, name, email, username, password, role from users where username=?").
 			WithArgs("abc@123").
 			WillReturnError(sql.ErrNoRows)
 
-		user, err := repo.GetByUsername("abc@123")
+		user, err := repo.GetByUsername(context.Background(), "abc@123")
 
 		assert.Error(t, err)
 		assert.Nil(t, user)
@@ -139,12 +141,12 @@ func TestGetAllUser(t *testing.T) {
 	repo := NewUserRepo(db)
 
 	t.Run("Success", func(t *testing.T) {
-		mock.ExpectQuery("select id, name, email, username, password from users").
-			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "username", "password"}).
-				AddRow(1, "Abhay", "abhay123@gmail.com", "abhay123", "abhay@123").
-				AddRow(2, "Alesh", "alesh123@gmail.com", "alesh123", "alesh@123"))
+		mock.ExpectQuery("select id, name, email, username, password, role from users").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "username", "password", "role"}).
+				AddRow(1, "Abhay", "abhay123@gmail.com", "abhay123", "abhay@123", "customer").
+				AddRow(2, "Alesh", "alesh123@gmail.com", "alesh123", "alesh@123", "admin"))
 
-		users, err := repo.GetAll()
+		users, err := repo.GetAll(context.Background())
 
 		assert.NoError(t, err)
 		assert.Len(t, users, 2) // ensures that exactly 2 users were returned
@@ -156,14 +158,15 @@ func TestGetAllUser(t *testing.T) {
 		assert.Equal(t, 2, users[1].Id)
 		assert.Equal(t, "alesh123", users[1].Username)
 		assert.Equal(t, "alesh@123", users[1].Password)
+		assert.Equal(t, models.RoleAdmin, users[1].Role)
 
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 	t.Run("Fail", func(t *testing.T) {
-		mock.ExpectQuery("select id, name, email, username, password from users").
+		mock.ExpectQuery("select id, name, email, username, password, role from users").
 			WillReturnError(fmt.Errorf("database error"))
 
-		users, err := repo.GetAll()
+		users, err := repo.GetAll(context.Background())
 
 		assert.Error(t, err)
 		assert.Nil(t, users)
@@ -171,11 +174,11 @@ func TestGetAllUser(t *testing.T) {
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 	t.Run("Scan Error", func(t *testing.T) {
-		mock.ExpectQuery("select id, name, email, username, password from users").
-			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "username"}). // missing "password" column
+		mock.ExpectQuery("select id, name, email, username, password, role from users").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "username"}). // missing "password" and "role" columns
 													AddRow(1, "Abhay", "abhay123@gmail.com", "abhay123"))
 
-		users, err := repo.GetAll()
+		users, err := repo.GetAll(context.Background())
 
 		assert.Error(t, err)
 		assert.Nil(t, users)
@@ -195,15 +198,16 @@ func TestUpdateUser(t *testing.T) {
 		Email:    "abhay123@gmail.com",
 		Username: "abhay123",
 		Password: "abhay@123",
+		Role:     models.RoleCustomer,
 	}
 
-	mock.ExpectExec(regexp.QuoteMeta("update users set name=?, email=?, username=?, password=? where id=?")).
-		WithArgs(user.Name, user.Email, user.Username, user.Password, user.Id).
+	mock.ExpectExec(regexp.QuoteMeta("update users set name=?, email=?, username=?, password=?, role=? where id=?")).
+		WithArgs(user.Name, user.Email, user.Username, user.Password, user.Role, user.Id).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	// Row ID = 1,
 	// 1 row affected
 	repo := NewUserRepo(db)
-	err = repo.Update(user)
+	err = repo.Update(context.Background(), user)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -221,7 +225,7 @@ func TestDeleteUser(t *testing.T) {
 		// Row ID = 1,
 		// 1 row affected
 
-		err = repo.Delete(1)
+		err = repo.Delete(context.Background(), 1)
 		assert.NoError(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
@@ -232,7 +236,7 @@ func TestDeleteUser(t *testing.T) {
 			WillReturnResult(sqlmock.NewResult(0, 0)) // No rows affected
 
 		repo := NewUserRepo(db)
-		err = repo.Delete(90)
+		err = repo.Delete(context.Background(), 90)
 
 		assert.Error(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
@@ -242,7 +246,7 @@ func TestDeleteUser(t *testing.T) {
 			WithArgs(1).
 			WillReturnError(fmt.Errorf("failed to delete user"))
 
-		err = repo.Delete(1)
+		err = repo.Delete(context.Background(), 1)
 
 		assert.Error(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
@@ -0,0 +1,309 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrVariantNotFound = errors.New("variant not found")
+	ErrUnknownOption   = errors.New("unknown option")
+)
+
+type VariantRepo interface {
+	CreateOptionType(ctx context.Context, optionType *models.OptionType) error
+	AddOptionValue(ctx context.Context, value *models.OptionValue) error
+	GetOptionTypes(ctx context.Context) ([]models.OptionType, error)
+
+	Create(ctx context.Context, variant *models.Variant) error
+	GetByID(ctx context.Context, id int) (*models.Variant, error)
+	GetBySKU(ctx context.Context, sku string) (*models.Variant, error)
+	ListByProducts(ctx context.Context, productIDs []int) ([]models.Variant, error)
+	Update(ctx context.Context, variant *models.Variant) error
+	Delete(ctx context.Context, id int) error
+}
+
+type variantRepo struct {
+	db DBTX
+}
+
+func NewVariantRepo(db DBTX) VariantRepo {
+	return &variantRepo{db: db}
+}
+
+// stock is summed over the variant's inventory items in every warehouse
+const variantColumns = "id, product_id, sku, price, currency, barcode, " +
+	"(select coalesce(sum(i.on_hand - i.reserved), 0) from inventory_items i where i.variant_id = product_variants.id)"
+
+func (r *variantRepo) CreateOptionType(ctx context.Context, optionType *models.OptionType) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "insert into option_types (name) values (?)", optionType.Name)
+	if err != nil {
+		return fmt.Errorf("failed to insert option type: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		optionType.ID = int(id)
+	}
+	return nil
+}
+
+func (r *variantRepo) AddOptionValue(ctx context.Context, value *models.OptionValue) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "insert into option_values (option_type_id, value) values (?,?)", value.OptionTypeID, value.Value)
+	if err != nil {
+		return fmt.Errorf("failed to insert option value: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		value.ID = int(id)
+	}
+	return nil
+}
+
+// GetOptionTypes returns every option type with its values
+func (r *variantRepo) GetOptionTypes(ctx context.Context) ([]models.OptionType, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	query := "select ot.id, ot.name, ov.id, ov.value from option_types ot " +
+		"left join option_values ov on ov.option_type_id = ot.id order by ot.name, ov.id"
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var types []models.OptionType
+	for rows.Next() {
+		var typeID int
+		var name string
+		var valueID sql.NullInt64
+		var value sql.NullString
+		if err := rows.Scan(&typeID, &name, &valueID, &value); err != nil {
+			return nil, err
+		}
+		if len(types) == 0 || types[len(types)-1].ID != typeID {
+			types = append(types, models.OptionType{ID: typeID, Name: name})
+		}
+		if valueID.Valid {
+			current := &types[len(types)-1]
+			current.Values = append(current.Values, models.OptionValue{ID: int(valueID.Int64), OptionTypeID: typeID, Value: value.String})
+		}
+	}
+	return types, rows.Err()
+}
+
+// Create inserts the variant and links its options, run it in a transaction
+// so a variant with an unknown option is not left behind half created
+func (r *variantRepo) Create(ctx context.Context, variant *models.Variant) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	price, currency := nullableMoney(variant.Price)
+	query := "insert into product_variants (product_id, sku, price, currency, barcode) values (?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, variant.ProductID, variant.SKU, price, currency, nullableString(variant.Barcode))
+	if err != nil {
+		return fmt.Errorf("failed to insert variant: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	variant.ID = int(id)
+	return r.linkOptions(ctx, variant)
+}
+
+func (r *variantRepo) GetByID(ctx context.Context, id int) (*models.Variant, error) {
+	return r.getOne(ctx, "select "+variantColumns+" from product_variants where id=?", id)
+}
+
+func (r *variantRepo) GetBySKU(ctx context.Context, sku string) (*models.Variant, error) {
+	return r.getOne(ctx, "select "+variantColumns+" from product_variants where sku=?", sku)
+}
+
+// ListByProducts returns the variants of several products at once, ordered by product then id
+func (r *variantRepo) ListByProducts(ctx context.Context, productIDs []int) ([]models.Variant, error) {
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	placeholders, args := inClause(productIDs)
+	query := "select " + variantColumns + " from product_variants where product_id in (" + placeholders + ") order by product_id, id"
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []models.Variant
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, *variant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := r.loadOptions(ctx, variants); err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+// Update saves the variant and replaces its options
+func (r *variantRepo) Update(ctx context.Context, variant *models.Variant) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	price, currency := nullableMoney(variant.Price)
+	query := "update product_variants set sku=?, price=?, currency=?, barcode=? where id=?"
+	result, err := r.db.ExecContext(ctx, query, variant.SKU, price, currency, nullableString(variant.Barcode), variant.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update variant: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		// MySQL reports 0 for an unchanged row too, so check it really is gone
+		if _, err := r.getOne(ctx, "select "+variantColumns+" from product_variants where id=?", variant.ID); err != nil {
+			return err
+		}
+	}
+	if _, err := r.db.ExecContext(ctx, "delete from variant_option_values where variant_id=?", variant.ID); err != nil {
+		return fmt.Errorf("failed to clear variant options: %w", err)
+	}
+	return r.linkOptions(ctx, variant)
+}
+
+func (r *variantRepo) Delete(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "delete from product_variants where id=?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete variant: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrVariantNotFound
+	}
+	return nil
+}
+
+func (r *variantRepo) getOne(ctx context.Context, query string, arg interface{}) (*models.Variant, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	variant, err := scanVariant(r.db.QueryRowContext(ctx, query, arg))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrVariantNotFound
+		}
+		return nil, err
+	}
+	variants := []models.Variant{*variant}
+	if err := r.loadOptions(ctx, variants); err != nil {
+		return nil, err
+	}
+	return &variants[0], nil
+}
+
+// linkOptions resolves each option by type name and value, both must exist
+func (r *variantRepo) linkOptions(ctx context.Context, variant *models.Variant) error {
+	query := "insert into variant_option_values (variant_id, option_value_id) " +
+		"select ?, ov.id from option_values ov join option_types ot on ot.id = ov.option_type_id where ot.name = ? and ov.value = ?"
+	for name, value := range variant.Options {
+		result, err := r.db.ExecContext(ctx, query, variant.ID, name, value)
+		if err != nil {
+			return fmt.Errorf("failed to link variant option: %w", err)
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+			return fmt.Errorf("%w %s=%s", ErrUnknownOption, name, value)
+		}
+	}
+	return nil
+}
+
+func (r *variantRepo) loadOptions(ctx context.Context, variants []models.Variant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+	byID := make(map[int]*models.Variant, len(variants))
+	ids := make([]int, 0, len(variants))
+	for i := range variants {
+		variants[i].Options = map[string]string{}
+		byID[variants[i].ID] = &variants[i]
+		ids = append(ids, variants[i].ID)
+	}
+
+	placeholders, args := inClause(ids)
+	query := "select vov.variant_id, ot.name, ov.value from variant_option_values vov " +
+		"join option_values ov on ov.id = vov.option_value_id join option_types ot on ot.id = ov.option_type_id " +
+		"where vov.variant_id in (" + placeholders + ")"
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var variantID int
+		var name, value string
+		if err := rows.Scan(&variantID, &name, &value); err != nil {
+			return err
+		}
+		if variant, ok := byID[variantID]; ok {
+			variant.Options[name] = value
+		}
+	}
+	return rows.Err()
+}
+
+func scanVariant(row rowScanner) (*models.Variant, error) {
+	var variant models.Variant
+	var price sql.NullInt64
+	var currency, barcode sql.NullString
+	if err := row.Scan(&variant.ID, &variant.ProductID, &variant.SKU, &price, &currency, &barcode, &variant.Stock); err != nil {
+		return nil, err
+	}
+	if price.Valid {
+		variant.Price = &models.Money{Amount: price.Int64, Currency: currency.String}
+	}
+	variant.Barcode = barcode.String
+	return &variant, nil
+}
+
+// inClause builds the "?,?,?" placeholders and arguments for an IN list
+func inClause(ids []int) (string, []interface{}) {
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return strings.Join(placeholders, ","), args
+}
+
+// nullableString stores an empty optional value as NULL, so unique keys ignore it
+// nullableMoney splits an optional amount into its price and currency columns
+func nullableMoney(m *models.Money) (interface{}, interface{}) {
+	if m == nil {
+		return nil, nil
+	}
+	return m.Amount, m.Currency
+}
+
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
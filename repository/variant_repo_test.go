@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateVariant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewVariantRepo(db)
+
+	t.Run("Success", func(t *testing.T) {
+		price := models.NewMoney(55000, "USD")
+		variant := &models.Variant{ProductID: 1, SKU: "TS-XL-RED", Price: &price, Options: map[string]string{"size": "XL"}}
+		mock.ExpectExec("insert into product_variants").
+			WithArgs(1, "TS-XL-RED", int64(55000), "USD", nil).
+			WillReturnResult(sqlmock.NewResult(9, 1))
+		mock.ExpectExec("insert into variant_option_values").
+			WithArgs(9, "size", "XL").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.Create(context.Background(), variant)
+		assert.NoError(t, err)
+		assert.Equal(t, 9, variant.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Unknown option", func(t *testing.T) {
+		variant := &models.Variant{ProductID: 1, SKU: "TS-XXXL", Options: map[string]string{"size": "XXXL"}}
+		mock.ExpectExec("insert into product_variants").
+			WillReturnResult(sqlmock.NewResult(10, 1))
+		mock.ExpectExec("insert into variant_option_values").
+			WithArgs(10, "size", "XXXL").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.Create(context.Background(), variant)
+		assert.ErrorIs(t, err, ErrUnknownOption)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetBySKUVariant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewVariantRepo(db)
+
+	query := regexp.QuoteMeta("select " + variantColumns + " from product_variants where sku=?")
+	columns := []string{"id", "product_id", "sku", "price", "currency", "barcode", "stock"}
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectQuery(query).
+			WithArgs("TS-S-RED").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 1, "TS-S-RED", nil, nil, "4006381333931", 12))
+		mock.ExpectQuery("select vov.variant_id, ot.name, ov.value from variant_option_values").
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"variant_id", "name", "value"}).AddRow(3, "size", "S").AddRow(3, "color", "red"))
+
+		variant, err := repo.GetBySKU(context.Background(), "TS-S-RED")
+		assert.NoError(t, err)
+		assert.Nil(t, variant.Price)
+		assert.Equal(t, "4006381333931", variant.Barcode)
+		assert.Equal(t, 12, variant.Stock)
+		assert.Equal(t, map[string]string{"size": "S", "color": "red"}, variant.Options)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		mock.ExpectQuery(query).
+			WithArgs("NOPE").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetBySKU(context.Background(), "NOPE")
+		assert.Equal(t, ErrVariantNotFound, err)
+	})
+}
+
+func TestListByProductsVariant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewVariantRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("from product_variants where product_id in (?,?)")).
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "sku", "price", "currency", "barcode", "stock"}).
+			AddRow(3, 1, "TS-S", nil, nil, nil, 0).
+			AddRow(4, 2, "MUG", 950, "USD", nil, 5))
+	mock.ExpectQuery(regexp.QuoteMeta("where vov.variant_id in (?,?)")).
+		WithArgs(3, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"variant_id", "name", "value"}).AddRow(3, "size", "S"))
+
+	variants, err := repo.ListByProducts(context.Background(), []int{1, 2})
+	assert.NoError(t, err)
+	assert.Len(t, variants, 2)
+	assert.Equal(t, "S", variants[0].Options["size"])
+	assert.Empty(t, variants[1].Options)
+	assert.Equal(t, models.NewMoney(950, "USD"), *variants[1].Price)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	empty, err := repo.ListByProducts(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Nil(t, empty)
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"errors"
+	"fmt"
+)
+
+var ErrWarehouseNotFound = errors.New("warehouse not found")
+
+type WarehouseRepo interface {
+	Create(ctx context.Context, warehouse *models.Warehouse) error
+	GetByID(ctx context.Context, id int) (*models.Warehouse, error)
+	GetAll(ctx context.Context) ([]models.Warehouse, error)
+	Update(ctx context.Context, warehouse *models.Warehouse) error
+}
+
+type warehouseRepo struct {
+	db DBTX
+}
+
+func NewWarehouseRepo(db DBTX) WarehouseRepo {
+	return &warehouseRepo{db: db}
+}
+
+const warehouseColumns = "id, code, name, latitude, longitude, priority, active"
+
+func (r *warehouseRepo) Create(ctx context.Context, warehouse *models.Warehouse) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	latitude, longitude := geoArgs(warehouse.Location)
+	query := "insert into warehouses (code, name, latitude, longitude, priority, active) values (?,?,?,?,?,?)"
+	result, err := r.db.ExecContext(ctx, query, warehouse.Code, warehouse.Name, latitude, longitude, warehouse.Priority, warehouse.Active)
+	if err != nil {
+		return fmt.Errorf("failed to insert warehouse: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		warehouse.ID = int(id)
+	}
+	return nil
+}
+
+func (r *warehouseRepo) GetByID(ctx context.Context, id int) (*models.Warehouse, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	warehouse, err := scanWarehouse(r.db.QueryRowContext(ctx, "select "+warehouseColumns+" from warehouses where id=?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWarehouseNotFound
+		}
+		return nil, err
+	}
+	return warehouse, nil
+}
+
+// GetAll returns every warehouse, active or not, in priority order
+func (r *warehouseRepo) GetAll(ctx context.Context) ([]models.Warehouse, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "select "+warehouseColumns+" from warehouses order by priority, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var warehouses []models.Warehouse
+	for rows.Next() {
+		warehouse, err := scanWarehouse(rows)
+		if err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, *warehouse)
+	}
+	return warehouses, rows.Err()
+}
+
+func (r *warehouseRepo) Update(ctx context.Context, warehouse *models.Warehouse) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	latitude, longitude := geoArgs(warehouse.Location)
+	query := "update warehouses set code=?, name=?, latitude=?, longitude=?, priority=?, active=? where id=?"
+	_, err := r.db.ExecContext(ctx, query, warehouse.Code, warehouse.Name, latitude, longitude, warehouse.Priority, warehouse.Active, warehouse.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update warehouse: %w", err)
+	}
+	return nil
+}
+
+func scanWarehouse(row rowScanner) (*models.Warehouse, error) {
+	var warehouse models.Warehouse
+	var latitude, longitude sql.NullFloat64
+	err := row.Scan(&warehouse.ID, &warehouse.Code, &warehouse.Name, &latitude, &longitude, &warehouse.Priority, &warehouse.Active)
+	if err != nil {
+		return nil, err
+	}
+	if latitude.Valid && longitude.Valid {
+		warehouse.Location = &models.GeoPoint{Latitude: latitude.Float64, Longitude: longitude.Float64}
+	}
+	return &warehouse, nil
+}
+
+func geoArgs(point *models.GeoPoint) (interface{}, interface{}) {
+	if point == nil {
+		return nil, nil
+	}
+	return point.Latitude, point.Longitude
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce/models"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateWarehouse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewWarehouseRepo(db)
+
+	warehouse := &models.Warehouse{Code: "lisbon", Name: "Lisbon", Location: &models.GeoPoint{Latitude: 38.7223, Longitude: -9.1393}, Priority: 1, Active: true}
+	mock.ExpectExec("insert into warehouses").
+		WithArgs("lisbon", "Lisbon", 38.7223, -9.1393, 1, true).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+
+	assert.NoError(t, repo.Create(context.Background(), warehouse))
+	assert.Equal(t, 3, warehouse.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllWarehouses(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewWarehouseRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("select " + warehouseColumns + " from warehouses order by priority, id")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code", "name", "latitude", "longitude", "priority", "active"}).
+			AddRow(1, "main", "Main warehouse", nil, nil, 0, true).
+			AddRow(3, "lisbon", "Lisbon", 38.7223, -9.1393, 1, false))
+
+	warehouses, err := repo.GetAll(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, warehouses, 2)
+	assert.Nil(t, warehouses[0].Location)
+	assert.Equal(t, -9.1393, warehouses[1].Location.Longitude)
+	assert.False(t, warehouses[1].Active)
+}
+
+func TestGetByIDWarehouse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewWarehouseRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("from warehouses where id=?")).
+		WithArgs(9).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.GetByID(context.Background(), 9)
+	assert.Equal(t, ErrWarehouseNotFound, err)
+}
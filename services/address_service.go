@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrAddressNotFound = repository.ErrAddressNotFound
+	ErrInvalidAddress  = errors.New("invalid address")
+)
+
+// AddressService manages the users' address books. Checkout copies the
+// addresses it is given onto the order, see orderService.Checkout.
+type AddressService interface {
+	GetAddresses(ctx context.Context, userID int) ([]models.Address, error)
+	GetAddress(ctx context.Context, userID, id int) (*models.Address, error)
+	// CreateAddress saves a new address of address.UserID, the user's first
+	// address becomes their default for both shipping and billing
+	CreateAddress(ctx context.Context, address *models.Address) error
+	UpdateAddress(ctx context.Context, address *models.Address) error
+	DeleteAddress(ctx context.Context, userID, id int) error
+}
+
+type addressService struct {
+	addressRepo repository.AddressRepo
+	txManager   repository.TxManager
+}
+
+func NewAddressService(addressRepo repository.AddressRepo, txManager repository.TxManager) AddressService {
+	return &addressService{addressRepo: addressRepo, txManager: txManager}
+}
+
+func (s *addressService) GetAddresses(ctx context.Context, userID int) ([]models.Address, error) {
+	return s.addressRepo.ListByUser(ctx, userID)
+}
+
+func (s *addressService) GetAddress(ctx context.Context, userID, id int) (*models.Address, error) {
+	return s.addressRepo.GetByID(ctx, userID, id)
+}
+
+func (s *addressService) CreateAddress(ctx context.Context, address *models.Address) error {
+	if err := validateAddress(&address.PostalAddress); err != nil {
+		return err
+	}
+	return s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		existing, err := tx.Addresses.ListByUser(ctx, address.UserID)
+		if err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			address.DefaultShipping, address.DefaultBilling = true, true
+		}
+		if err := clearDefaults(ctx, tx, address, nil); err != nil {
+			return err
+		}
+		return tx.Addresses.Create(ctx, address)
+	})
+}
+
+func (s *addressService) UpdateAddress(ctx context.Context, address *models.Address) error {
+	if err := validateAddress(&address.PostalAddress); err != nil {
+		return err
+	}
+	return s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		current, err := tx.Addresses.GetByID(ctx, address.UserID, address.ID)
+		if err != nil {
+			return err
+		}
+		if err := clearDefaults(ctx, tx, address, current); err != nil {
+			return err
+		}
+		return tx.Addresses.Update(ctx, address)
+	})
+}
+
+// DeleteAddress removes the address, a default address leaves the user
+// without a default of its kind
+func (s *addressService) DeleteAddress(ctx context.Context, userID, id int) error {
+	return s.addressRepo.Delete(ctx, userID, id)
+}
+
+// clearDefaults takes the default flags the address is about to get off the
+// user's other addresses, current is the address as stored (nil when new)
+func clearDefaults(ctx context.Context, tx repository.Repos, address, current *models.Address) error {
+	flags := []struct {
+		kind       models.AddressKind
+		set, isSet bool
+	}{
+		{models.AddressShipping, address.DefaultShipping, current != nil && current.DefaultShipping},
+		{models.AddressBilling, address.DefaultBilling, current != nil && current.DefaultBilling},
+	}
+	for _, flag := range flags {
+		if !flag.set || flag.isSet {
+			continue
+		}
+		if err := tx.Addresses.ClearDefault(ctx, address.UserID, flag.kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateAddress normalises the address and checks it is complete, with a
+// postcode in the format of its country
+func validateAddress(address *models.PostalAddress) error {
+	*address = address.Normalize()
+	switch {
+	case address.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidAddress)
+	case address.Line1 == "":
+		return fmt.Errorf("%w: line1 is required", ErrInvalidAddress)
+	case address.City == "":
+		return fmt.Errorf("%w: city is required", ErrInvalidAddress)
+	case !models.ValidCountry(address.Country):
+		return fmt.Errorf("%w: country must be an ISO 3166-1 alpha-2 code", ErrInvalidAddress)
+	}
+	if err := models.ValidatePostcode(address.Country, address.Postcode); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+	return nil
+}
+
+// checkoutAddresses copies the saved addresses the request picks for the
+// order, shipping first
+func checkoutAddresses(ctx context.Context, tx repository.Repos, request models.CheckoutRequest) ([]models.OrderAddress, error) {
+	picks := []struct {
+		kind models.AddressKind
+		id   int
+	}{
+		{models.AddressShipping, request.ShippingAddressID},
+		{models.AddressBilling, request.BillingAddressID},
+	}
+	var addresses []models.OrderAddress
+	for _, pick := range picks {
+		if pick.id == 0 {
+			continue
+		}
+		address, err := tx.Addresses.GetByID(ctx, request.UserID, pick.id)
+		if errors.Is(err, ErrAddressNotFound) {
+			return nil, fmt.Errorf("%w: %s address %d is not in your address book", ErrInvalidAddress, pick.kind, pick.id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		addressID := address.ID
+		addresses = append(addresses, models.OrderAddress{Kind: pick.kind, AddressID: &addressID, PostalAddress: address.PostalAddress})
+	}
+	return addresses, nil
+}
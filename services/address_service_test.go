@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAddressRepo struct {
+	mock.Mock
+}
+
+func (m *MockAddressRepo) ListByUser(ctx context.Context, userID int) ([]models.Address, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]models.Address), args.Error(1)
+}
+
+func (m *MockAddressRepo) GetByID(ctx context.Context, userID, id int) (*models.Address, error) {
+	args := m.Called(userID, id)
+	if address := args.Get(0); address != nil {
+		return address.(*models.Address), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAddressRepo) Create(ctx context.Context, address *models.Address) error {
+	args := m.Called(address)
+	return args.Error(0)
+}
+
+func (m *MockAddressRepo) Update(ctx context.Context, address *models.Address) error {
+	args := m.Called(address)
+	return args.Error(0)
+}
+
+func (m *MockAddressRepo) Delete(ctx context.Context, userID, id int) error {
+	args := m.Called(userID, id)
+	return args.Error(0)
+}
+
+func (m *MockAddressRepo) ClearDefault(ctx context.Context, userID int, kind models.AddressKind) error {
+	args := m.Called(userID, kind)
+	return args.Error(0)
+}
+
+func newTestAddress() *models.Address {
+	return &models.Address{UserID: 7, PostalAddress: models.PostalAddress{
+		Name: " Ada Lovelace ", Line1: "12 St James's Square", City: "London", Postcode: "sw1y  4jh", Country: "gb"}}
+}
+
+func TestCreateAddress(t *testing.T) {
+	t.Run("First address is the default", func(t *testing.T) {
+		addressRepo := new(MockAddressRepo)
+		addressService := NewAddressService(addressRepo, inlineTx{repository.Repos{Addresses: addressRepo}})
+		addressRepo.On("ListByUser", 7).Return([]models.Address(nil), nil).Once()
+		addressRepo.On("ClearDefault", 7, models.AddressShipping).Return(nil).Once()
+		addressRepo.On("ClearDefault", 7, models.AddressBilling).Return(nil).Once()
+		addressRepo.On("Create", mock.MatchedBy(func(address *models.Address) bool {
+			return address.Name == "Ada Lovelace" && address.Postcode == "SW1Y 4JH" && address.Country == "GB" &&
+				address.DefaultShipping && address.DefaultBilling
+		})).Return(nil).Once()
+
+		assert.NoError(t, addressService.CreateAddress(context.Background(), newTestAddress()))
+		addressRepo.AssertExpectations(t)
+	})
+	t.Run("New default billing address", func(t *testing.T) {
+		addressRepo := new(MockAddressRepo)
+		addressService := NewAddressService(addressRepo, inlineTx{repository.Repos{Addresses: addressRepo}})
+		addressRepo.On("ListByUser", 7).Return([]models.Address{{ID: 1, UserID: 7, DefaultShipping: true, DefaultBilling: true}}, nil).Once()
+		addressRepo.On("ClearDefault", 7, models.AddressBilling).Return(nil).Once()
+		addressRepo.On("Create", mock.MatchedBy(func(address *models.Address) bool {
+			return !address.DefaultShipping && address.DefaultBilling
+		})).Return(nil).Once()
+
+		address := newTestAddress()
+		address.DefaultBilling = true
+		assert.NoError(t, addressService.CreateAddress(context.Background(), address))
+		addressRepo.AssertExpectations(t)
+		addressRepo.AssertNotCalled(t, "ClearDefault", 7, models.AddressShipping)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		addressService := NewAddressService(new(MockAddressRepo), inlineTx{})
+		for name, modify := range map[string]func(*models.Address){
+			"No name":          func(a *models.Address) { a.Name = " " },
+			"No street":        func(a *models.Address) { a.Line1 = "" },
+			"No city":          func(a *models.Address) { a.City = "" },
+			"Bad country":      func(a *models.Address) { a.Country = "GBR" },
+			"Wrong format":     func(a *models.Address) { a.Postcode = "12345" },
+			"Missing postcode": func(a *models.Address) { a.Postcode = "" },
+			"Postcode where none": func(a *models.Address) {
+				a.Country, a.City, a.Postcode = "HK", "Hong Kong", "999077"
+			},
+		} {
+			address := newTestAddress()
+			modify(address)
+			assert.ErrorIs(t, addressService.CreateAddress(context.Background(), address), ErrInvalidAddress, name)
+		}
+	})
+}
+
+func TestUpdateAddress(t *testing.T) {
+	addressRepo := new(MockAddressRepo)
+	addressService := NewAddressService(addressRepo, inlineTx{repository.Repos{Addresses: addressRepo}})
+
+	t.Run("Already the default", func(t *testing.T) {
+		addressRepo.On("GetByID", 7, 3).Return(&models.Address{ID: 3, UserID: 7, DefaultShipping: true}, nil).Once()
+		addressRepo.On("Update", mock.Anything).Return(nil).Once()
+
+		address := newTestAddress()
+		address.ID, address.DefaultShipping = 3, true
+		assert.NoError(t, addressService.UpdateAddress(context.Background(), address))
+		addressRepo.AssertNotCalled(t, "ClearDefault", mock.Anything, mock.Anything)
+	})
+	t.Run("Someone else's", func(t *testing.T) {
+		addressRepo.On("GetByID", 7, 4).Return(nil, ErrAddressNotFound).Once()
+
+		address := newTestAddress()
+		address.ID = 4
+		assert.ErrorIs(t, addressService.UpdateAddress(context.Background(), address), ErrAddressNotFound)
+	})
+	addressRepo.AssertExpectations(t)
+}
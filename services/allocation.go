@@ -0,0 +1,101 @@
+package services
+
+import (
+	"ecommerce/models"
+	"fmt"
+	"sort"
+)
+
+// Names of the allocation strategies, as used in the configuration
+const (
+	AllocationPriority     = "priority"
+	AllocationClosest      = "closest"
+	AllocationHighestStock = "highest_stock"
+)
+
+// StockSource is stock of one warehouse a line could be reserved from
+type StockSource struct {
+	Item      models.InventoryItem
+	Warehouse models.Warehouse
+}
+
+// AllocationStrategy orders the warehouses a line is reserved from. A line
+// takes what it can from the first source and moves on until it is covered.
+// destination is where the order ships to, nil when unknown.
+type AllocationStrategy interface {
+	Rank(sources []StockSource, destination *models.GeoPoint) []StockSource
+}
+
+// NewAllocationStrategy returns the strategy registered under name
+func NewAllocationStrategy(name string) (AllocationStrategy, error) {
+	switch name {
+	case AllocationPriority, "":
+		return PriorityAllocation{}, nil
+	case AllocationClosest:
+		return ClosestAllocation{}, nil
+	case AllocationHighestStock:
+		return HighestStockAllocation{}, nil
+	}
+	return nil, fmt.Errorf("unknown allocation strategy %q, expected %s, %s or %s", name, AllocationPriority, AllocationClosest, AllocationHighestStock)
+}
+
+// PriorityAllocation ships from the warehouse with the lowest priority number first
+type PriorityAllocation struct{}
+
+func (PriorityAllocation) Rank(sources []StockSource, destination *models.GeoPoint) []StockSource {
+	return rankSources(sources, func(a, b StockSource) int { return 0 })
+}
+
+// HighestStockAllocation ships from the warehouse with the most available stock first
+type HighestStockAllocation struct{}
+
+func (HighestStockAllocation) Rank(sources []StockSource, destination *models.GeoPoint) []StockSource {
+	return rankSources(sources, func(a, b StockSource) int {
+		return b.Item.Available() - a.Item.Available()
+	})
+}
+
+// ClosestAllocation ships from the warehouse nearest to the destination.
+// Warehouses without a location come last, and without a destination it
+// falls back to priority order.
+type ClosestAllocation struct{}
+
+func (ClosestAllocation) Rank(sources []StockSource, destination *models.GeoPoint) []StockSource {
+	if destination == nil {
+		return PriorityAllocation{}.Rank(sources, nil)
+	}
+	return rankSources(sources, func(a, b StockSource) int {
+		switch {
+		case a.Warehouse.Location == nil && b.Warehouse.Location == nil:
+			return 0
+		case a.Warehouse.Location == nil:
+			return 1
+		case b.Warehouse.Location == nil:
+			return -1
+		}
+		distanceA := a.Warehouse.Location.DistanceKm(*destination)
+		distanceB := b.Warehouse.Location.DistanceKm(*destination)
+		switch {
+		case distanceA < distanceB:
+			return -1
+		case distanceA > distanceB:
+			return 1
+		}
+		return 0
+	})
+}
+
+// rankSources sorts a copy of sources by compare, ties go to warehouse priority then id
+func rankSources(sources []StockSource, compare func(a, b StockSource) int) []StockSource {
+	ranked := append([]StockSource(nil), sources...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if c := compare(ranked[i], ranked[j]); c != 0 {
+			return c < 0
+		}
+		if ranked[i].Warehouse.Priority != ranked[j].Warehouse.Priority {
+			return ranked[i].Warehouse.Priority < ranked[j].Warehouse.Priority
+		}
+		return ranked[i].Warehouse.ID < ranked[j].Warehouse.ID
+	})
+	return ranked
+}
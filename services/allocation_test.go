@@ -0,0 +1,50 @@
+package services
+
+import (
+	"ecommerce/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rankedCodes(sources []StockSource) []string {
+	codes := make([]string, len(sources))
+	for i, source := range sources {
+		codes[i] = source.Warehouse.Code
+	}
+	return codes
+}
+
+func TestAllocationStrategies(t *testing.T) {
+	paris := models.Warehouse{ID: 3, Code: "paris", Location: &models.GeoPoint{Latitude: 48.8566, Longitude: 2.3522}, Priority: 3}
+	remote := models.Warehouse{ID: 4, Code: "remote", Priority: 0}
+	sources := []StockSource{
+		{Item: models.InventoryItem{OnHand: 5}, Warehouse: testWarehouses[0]},
+		{Item: models.InventoryItem{OnHand: 9, Reserved: 6}, Warehouse: testWarehouses[1]},
+		{Item: models.InventoryItem{OnHand: 7}, Warehouse: paris},
+		{Item: models.InventoryItem{OnHand: 1}, Warehouse: remote},
+	}
+	lisbon := &models.GeoPoint{Latitude: 38.7223, Longitude: -9.1393}
+
+	assert.Equal(t, []string{"remote", "madrid", "berlin", "paris"}, rankedCodes(PriorityAllocation{}.Rank(sources, lisbon)))
+	assert.Equal(t, []string{"paris", "berlin", "madrid", "remote"}, rankedCodes(HighestStockAllocation{}.Rank(sources, nil)))
+	assert.Equal(t, []string{"madrid", "paris", "berlin", "remote"}, rankedCodes(ClosestAllocation{}.Rank(sources, lisbon)))
+	assert.Equal(t, []string{"remote", "madrid", "berlin", "paris"}, rankedCodes(ClosestAllocation{}.Rank(sources, nil)), "no destination falls back to priority")
+	assert.Equal(t, "berlin", sources[0].Warehouse.Code, "ranking does not reorder the input")
+}
+
+func TestNewAllocationStrategy(t *testing.T) {
+	for name, expected := range map[string]AllocationStrategy{
+		"":              PriorityAllocation{},
+		"priority":      PriorityAllocation{},
+		"closest":       ClosestAllocation{},
+		"highest_stock": HighestStockAllocation{},
+	} {
+		strategy, err := NewAllocationStrategy(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, strategy)
+	}
+
+	_, err := NewAllocationStrategy("random")
+	assert.Error(t, err)
+}
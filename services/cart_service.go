@@ -0,0 +1,305 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"ecommerce/utils"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrCartNotFound     = repository.ErrCartNotFound
+	ErrCartItemNotFound = repository.ErrCartItemNotFound
+	ErrInvalidCartItem  = errors.New("invalid cart item")
+)
+
+const (
+	maxCartQuantity = 99                  // per line
+	guestCartTTL    = 30 * 24 * time.Hour // since the last change
+)
+
+// CartService manages the carts of signed in users and guests. Lines are
+// always priced at the current product or variant price.
+type CartService interface {
+	GetCart(ctx context.Context, owner models.CartOwner) (*models.Cart, error)
+	AddItem(ctx context.Context, owner models.CartOwner, item models.CartItem) (*models.Cart, error)
+	UpdateItem(ctx context.Context, owner models.CartOwner, itemID, quantity int) (*models.Cart, error)
+	RemoveItem(ctx context.Context, owner models.CartOwner, itemID int) (*models.Cart, error)
+	ClearCart(ctx context.Context, owner models.CartOwner) error
+	MergeGuestCart(ctx context.Context, userID int, guestToken string) error
+}
+
+type cartService struct {
+	cartRepo  repository.CartRepo
+	txManager repository.TxManager
+	currency  string // new carts are priced in it
+}
+
+func NewCartService(cartRepo repository.CartRepo, txManager repository.TxManager, currency string) CartService {
+	return &cartService{cartRepo: cartRepo, txManager: txManager, currency: currency}
+}
+
+// GetCart returns the owner's cart, an empty one when there is none yet
+func (s *cartService) GetCart(ctx context.Context, owner models.CartOwner) (*models.Cart, error) {
+	cart, err := findCart(ctx, s.cartRepo, owner)
+	if errors.Is(err, ErrCartNotFound) {
+		return &models.Cart{UserID: owner.UserID, Currency: s.currency}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cart.Items, err = s.cartRepo.ListItems(ctx, cart.ID); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// AddItem puts item.Quantity of a product or variant in the cart, adding to
+// the line already holding it. A guest without a cart gets a new one, its
+// token is on the returned cart.
+func (s *cartService) AddItem(ctx context.Context, owner models.CartOwner, item models.CartItem) (*models.Cart, error) {
+	if item.Quantity <= 0 || item.Quantity > maxCartQuantity {
+		return nil, fmt.Errorf("%w: quantity must be between 1 and %d", ErrInvalidCartItem, maxCartQuantity)
+	}
+
+	var cart *models.Cart
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		var err error
+		if cart, err = openCart(ctx, tx, owner, s.currency); err != nil {
+			return err
+		}
+		if item.UnitPrice, err = currentPrice(ctx, tx, item.ProductID, item.VariantID); err != nil {
+			return err
+		}
+		if item.UnitPrice.Currency != cart.Currency {
+			return fmt.Errorf("%w: the item is priced in %s, the cart in %s", ErrInvalidCartItem, item.UnitPrice.Currency, cart.Currency)
+		}
+		items, err := tx.Carts.ListItems(ctx, cart.ID)
+		if err != nil {
+			return err
+		}
+		for _, line := range items {
+			if line.Matches(item.ProductID, item.VariantID) {
+				if line.Quantity+item.Quantity > maxCartQuantity {
+					return fmt.Errorf("%w: at most %d of an item per order", ErrInvalidCartItem, maxCartQuantity)
+				}
+				line.Quantity += item.Quantity
+				line.UnitPrice = item.UnitPrice
+				return tx.Carts.UpdateItem(ctx, &line)
+			}
+		}
+		item.CartID = cart.ID
+		return tx.Carts.AddItem(ctx, &item)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.withItems(ctx, cart)
+}
+
+// UpdateItem sets the quantity of a line, zero removes it. The line is saved
+// at the current price, which acknowledges a price change.
+func (s *cartService) UpdateItem(ctx context.Context, owner models.CartOwner, itemID, quantity int) (*models.Cart, error) {
+	if quantity == 0 {
+		return s.RemoveItem(ctx, owner, itemID)
+	}
+	if quantity < 0 || quantity > maxCartQuantity {
+		return nil, fmt.Errorf("%w: quantity must be between 0 and %d", ErrInvalidCartItem, maxCartQuantity)
+	}
+
+	var cart *models.Cart
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		var err error
+		if cart, err = lockCart(ctx, tx, owner); err != nil {
+			return err
+		}
+		items, err := tx.Carts.ListItems(ctx, cart.ID)
+		if err != nil {
+			return err
+		}
+		for _, line := range items {
+			if line.ID == itemID {
+				line.Quantity = quantity
+				return tx.Carts.UpdateItem(ctx, &line)
+			}
+		}
+		return ErrCartItemNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.withItems(ctx, cart)
+}
+
+func (s *cartService) RemoveItem(ctx context.Context, owner models.CartOwner, itemID int) (*models.Cart, error) {
+	var cart *models.Cart
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		var err error
+		if cart, err = lockCart(ctx, tx, owner); err != nil {
+			return err
+		}
+		return tx.Carts.DeleteItem(ctx, cart.ID, itemID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.withItems(ctx, cart)
+}
+
+// ClearCart empties the cart, having none is not an error
+func (s *cartService) ClearCart(ctx context.Context, owner models.CartOwner) error {
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		cart, err := lockCart(ctx, tx, owner)
+		if err != nil {
+			return err
+		}
+		return tx.Carts.ClearItems(ctx, cart.ID)
+	})
+	if errors.Is(err, ErrCartNotFound) {
+		return nil
+	}
+	return err
+}
+
+// MergeGuestCart moves the lines of a guest cart into the user's cart when
+// the guest signs in. Without a user cart the guest cart simply becomes it.
+// An unknown or expired token is ignored.
+func (s *cartService) MergeGuestCart(ctx context.Context, userID int, guestToken string) error {
+	if guestToken == "" {
+		return nil
+	}
+	return s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		guest, err := lockCart(ctx, tx, models.CartOwner{GuestToken: guestToken})
+		if errors.Is(err, ErrCartNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		cart, err := lockCart(ctx, tx, models.CartOwner{UserID: userID})
+		if errors.Is(err, ErrCartNotFound) {
+			return tx.Carts.AssignUser(ctx, guest.ID, userID)
+		}
+		if err != nil {
+			return err
+		}
+
+		guestItems, err := tx.Carts.ListItems(ctx, guest.ID)
+		if err != nil {
+			return err
+		}
+		items, err := tx.Carts.ListItems(ctx, cart.ID)
+		if err != nil {
+			return err
+		}
+		for _, guestItem := range guestItems {
+			if err := mergeLine(ctx, tx, cart.ID, items, guestItem); err != nil {
+				return err
+			}
+		}
+		return tx.Carts.Delete(ctx, guest.ID)
+	})
+}
+
+// mergeLine adds a guest line to the user's lines, capping the combined quantity
+func mergeLine(ctx context.Context, tx repository.Repos, cartID int, items []models.CartItem, guestItem models.CartItem) error {
+	for _, line := range items {
+		if line.Matches(guestItem.ProductID, guestItem.VariantID) {
+			line.Quantity = min(line.Quantity+guestItem.Quantity, maxCartQuantity)
+			return tx.Carts.UpdateItem(ctx, &line)
+		}
+	}
+	guestItem.ID = 0
+	guestItem.CartID = cartID
+	return tx.Carts.AddItem(ctx, &guestItem)
+}
+
+func (s *cartService) withItems(ctx context.Context, cart *models.Cart) (*models.Cart, error) {
+	items, err := s.cartRepo.ListItems(ctx, cart.ID)
+	if err != nil {
+		return nil, err
+	}
+	cart.Items = items
+	return cart, nil
+}
+
+func findCart(ctx context.Context, carts repository.CartRepo, owner models.CartOwner) (*models.Cart, error) {
+	if !owner.Guest() {
+		return carts.GetByUser(ctx, owner.UserID)
+	}
+	if owner.GuestToken == "" {
+		return nil, ErrCartNotFound
+	}
+	return carts.GetByTokenHash(ctx, utils.HashOpaqueToken(owner.GuestToken))
+}
+
+// lockCart finds the owner's cart and touches it, which holds its row lock
+// until the transaction ends
+func lockCart(ctx context.Context, tx repository.Repos, owner models.CartOwner) (*models.Cart, error) {
+	cart, err := findCart(ctx, tx.Carts, owner)
+	if err != nil {
+		return nil, err
+	}
+	if owner.Guest() {
+		expiresAt := time.Now().Add(guestCartTTL)
+		cart.ExpiresAt = &expiresAt
+	}
+	if err := tx.Carts.Touch(ctx, cart.ID, cart.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// openCart is lockCart that starts a cart in currency when the owner has
+// none. A guest whose token is unknown or expired starts over with a new token.
+func openCart(ctx context.Context, tx repository.Repos, owner models.CartOwner, currency string) (*models.Cart, error) {
+	cart, err := lockCart(ctx, tx, owner)
+	if !errors.Is(err, ErrCartNotFound) {
+		return cart, err
+	}
+
+	cart = &models.Cart{UserID: owner.UserID, Currency: currency}
+	if owner.Guest() {
+		token, hash, err := utils.NewOpaqueToken()
+		if err != nil {
+			return nil, err
+		}
+		expiresAt := time.Now().Add(guestCartTTL)
+		cart.Token, cart.TokenHash, cart.ExpiresAt = token, hash, &expiresAt
+	}
+	if err := tx.Carts.Create(ctx, cart); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// currentPrice checks the product or variant can be put in a cart and returns its price
+func currentPrice(ctx context.Context, tx repository.Repos, productID int, variantID *int) (models.Money, error) {
+	product, err := tx.Products.GetByID(ctx, productID)
+	if err != nil {
+		return models.Money{}, err
+	}
+	if variantID != nil {
+		variant, err := tx.Variants.GetByID(ctx, *variantID)
+		if err != nil {
+			return models.Money{}, err
+		}
+		if variant.ProductID != productID {
+			return models.Money{}, fmt.Errorf("%w: variant %d is not a variant of product %d", ErrInvalidCartItem, *variantID, productID)
+		}
+		return variant.EffectivePrice(product), nil
+	}
+
+	variants, err := tx.Variants.ListByProducts(ctx, []int{productID})
+	if err != nil {
+		return models.Money{}, err
+	}
+	if len(variants) > 0 {
+		return models.Money{}, fmt.Errorf("%w: product %d is sold by variant, choose one", ErrInvalidCartItem, productID)
+	}
+	return product.Price, nil
+}
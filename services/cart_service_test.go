@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"ecommerce/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCartRepo struct {
+	mock.Mock
+}
+
+func (m *MockCartRepo) Create(ctx context.Context, cart *models.Cart) error {
+	args := m.Called(cart)
+	if args.Error(0) == nil {
+		cart.ID = 30
+	}
+	return args.Error(0)
+}
+
+func (m *MockCartRepo) GetByUser(ctx context.Context, userID int) (*models.Cart, error) {
+	args := m.Called(userID)
+	if cart := args.Get(0); cart != nil {
+		return cart.(*models.Cart), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCartRepo) GetByTokenHash(ctx context.Context, tokenHash string) (*models.Cart, error) {
+	args := m.Called(tokenHash)
+	if cart := args.Get(0); cart != nil {
+		return cart.(*models.Cart), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCartRepo) Touch(ctx context.Context, cartID int, expiresAt *time.Time) error {
+	args := m.Called(cartID, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockCartRepo) AssignUser(ctx context.Context, cartID, userID int) error {
+	args := m.Called(cartID, userID)
+	return args.Error(0)
+}
+
+func (m *MockCartRepo) Delete(ctx context.Context, cartID int) error {
+	args := m.Called(cartID)
+	return args.Error(0)
+}
+
+func (m *MockCartRepo) DeleteExpired(ctx context.Context) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockCartRepo) ListItems(ctx context.Context, cartID int) ([]models.CartItem, error) {
+	args := m.Called(cartID)
+	return args.Get(0).([]models.CartItem), args.Error(1)
+}
+
+func (m *MockCartRepo) AddItem(ctx context.Context, item *models.CartItem) error {
+	args := m.Called(item)
+	return args.Error(0)
+}
+
+func (m *MockCartRepo) UpdateItem(ctx context.Context, item *models.CartItem) error {
+	args := m.Called(item)
+	return args.Error(0)
+}
+
+func (m *MockCartRepo) DeleteItem(ctx context.Context, cartID, itemID int) error {
+	args := m.Called(cartID, itemID)
+	return args.Error(0)
+}
+
+func (m *MockCartRepo) ClearItems(ctx context.Context, cartID int) error {
+	args := m.Called(cartID)
+	return args.Error(0)
+}
+
+func newTestCartService(cartRepo *MockCartRepo, productRepo *MockProductRepo, variantRepo *MockVariantRepo) CartService {
+	tx := inlineTx{repository.Repos{Carts: cartRepo, Products: productRepo, Variants: variantRepo}}
+	return NewCartService(cartRepo, tx, "USD")
+}
+
+func TestGetCart(t *testing.T) {
+	cartRepo := new(MockCartRepo)
+	cartService := newTestCartService(cartRepo, new(MockProductRepo), new(MockVariantRepo))
+
+	t.Run("Guest without token", func(t *testing.T) {
+		cart, err := cartService.GetCart(context.Background(), models.CartOwner{})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, cart.ID)
+		assert.Empty(t, cart.Items)
+	})
+
+	t.Run("User", func(t *testing.T) {
+		items := []models.CartItem{{ID: 1, ProductID: 1, Quantity: 2, UnitPrice: usd(1250), SavedPrice: usd(1000)}}
+		cartRepo.On("GetByUser", 7).Return(&models.Cart{ID: 3, UserID: 7, Currency: "USD"}, nil).Once()
+		cartRepo.On("ListItems", 3).Return(items, nil).Once()
+
+		cart, err := cartService.GetCart(context.Background(), models.CartOwner{UserID: 7})
+		assert.NoError(t, err)
+		subtotal, err := cart.Subtotal()
+		assert.NoError(t, err)
+		assert.Equal(t, usd(2500), subtotal)
+		assert.True(t, cart.Items[0].PriceChanged())
+	})
+	cartRepo.AssertExpectations(t)
+}
+
+func TestAddCartItem(t *testing.T) {
+	product := &models.Product{ID: 1, Name: "Shirt", Price: usd(2000)}
+
+	t.Run("Guest gets a new cart", func(t *testing.T) {
+		cartRepo, productRepo, variantRepo := new(MockCartRepo), new(MockProductRepo), new(MockVariantRepo)
+		cartService := newTestCartService(cartRepo, productRepo, variantRepo)
+
+		cartRepo.On("GetByTokenHash", utils.HashOpaqueToken("stale")).Return(nil, ErrCartNotFound).Once()
+		cartRepo.On("Create", mock.MatchedBy(func(cart *models.Cart) bool {
+			return cart.UserID == 0 && cart.Token != "" && cart.TokenHash == utils.HashOpaqueToken(cart.Token) && cart.ExpiresAt != nil
+		})).Return(nil).Once()
+		productRepo.On("GetByID", 1).Return(product, nil).Once()
+		variantRepo.On("ListByProducts", []int{1}).Return([]models.Variant(nil), nil).Once()
+		cartRepo.On("ListItems", 30).Return([]models.CartItem(nil), nil).Once()
+		cartRepo.On("AddItem", mock.MatchedBy(func(item *models.CartItem) bool {
+			return item.CartID == 30 && item.Quantity == 2 && item.UnitPrice == usd(2000)
+		})).Return(nil).Once()
+		cartRepo.On("ListItems", 30).Return([]models.CartItem{{ID: 5, ProductID: 1, Quantity: 2, UnitPrice: usd(2000), SavedPrice: usd(2000)}}, nil).Once()
+
+		cart, err := cartService.AddItem(context.Background(), models.CartOwner{GuestToken: "stale"}, models.CartItem{ProductID: 1, Quantity: 2})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, cart.Token)
+		subtotal, err := cart.Subtotal()
+		assert.NoError(t, err)
+		assert.Equal(t, usd(4000), subtotal)
+		cartRepo.AssertExpectations(t)
+	})
+
+	t.Run("Adds to the existing line", func(t *testing.T) {
+		cartRepo, productRepo, variantRepo := new(MockCartRepo), new(MockProductRepo), new(MockVariantRepo)
+		cartService := newTestCartService(cartRepo, productRepo, variantRepo)
+		variantID := 4
+
+		cartRepo.On("GetByUser", 7).Return(&models.Cart{ID: 3, UserID: 7, Currency: "USD"}, nil)
+		cartRepo.On("Touch", 3, (*time.Time)(nil)).Return(nil).Once()
+		productRepo.On("GetByID", 1).Return(product, nil).Once()
+		variantRepo.On("GetByID", 4).Return(&models.Variant{ID: 4, ProductID: 1, Price: usdPtr(2500)}, nil).Once()
+		cartRepo.On("ListItems", 3).Return([]models.CartItem{
+			{ID: 8, CartID: 3, ProductID: 1, Quantity: 1, UnitPrice: usd(2000), SavedPrice: usd(2000)},
+			{ID: 9, CartID: 3, ProductID: 1, VariantID: &variantID, Quantity: 1, UnitPrice: usd(2500), SavedPrice: usd(2200)},
+		}, nil)
+		cartRepo.On("UpdateItem", mock.MatchedBy(func(item *models.CartItem) bool {
+			return item.ID == 9 && item.Quantity == 3 && item.UnitPrice == usd(2500)
+		})).Return(nil).Once()
+
+		_, err := cartService.AddItem(context.Background(), models.CartOwner{UserID: 7}, models.CartItem{ProductID: 1, VariantID: &variantID, Quantity: 2})
+		assert.NoError(t, err)
+		cartRepo.AssertExpectations(t)
+	})
+
+	t.Run("Product sold by variant", func(t *testing.T) {
+		cartRepo, productRepo, variantRepo := new(MockCartRepo), new(MockProductRepo), new(MockVariantRepo)
+		cartService := newTestCartService(cartRepo, productRepo, variantRepo)
+
+		cartRepo.On("GetByUser", 7).Return(&models.Cart{ID: 3, UserID: 7, Currency: "USD"}, nil)
+		cartRepo.On("Touch", 3, (*time.Time)(nil)).Return(nil)
+		productRepo.On("GetByID", 1).Return(product, nil)
+		variantRepo.On("ListByProducts", []int{1}).Return([]models.Variant{{ID: 4, ProductID: 1}}, nil)
+
+		_, err := cartService.AddItem(context.Background(), models.CartOwner{UserID: 7}, models.CartItem{ProductID: 1, Quantity: 1})
+		assert.ErrorIs(t, err, ErrInvalidCartItem)
+	})
+
+	t.Run("Invalid quantity", func(t *testing.T) {
+		cartService := newTestCartService(new(MockCartRepo), new(MockProductRepo), new(MockVariantRepo))
+
+		_, err := cartService.AddItem(context.Background(), models.CartOwner{UserID: 7}, models.CartItem{ProductID: 1, Quantity: 100})
+		assert.ErrorIs(t, err, ErrInvalidCartItem)
+	})
+}
+
+func TestUpdateCartItem(t *testing.T) {
+	cartRepo := new(MockCartRepo)
+	cartService := newTestCartService(cartRepo, new(MockProductRepo), new(MockVariantRepo))
+	cartRepo.On("GetByUser", 7).Return(&models.Cart{ID: 3, UserID: 7, Currency: "USD"}, nil)
+	cartRepo.On("Touch", 3, (*time.Time)(nil)).Return(nil)
+	cartRepo.On("ListItems", 3).Return([]models.CartItem{{ID: 8, CartID: 3, ProductID: 1, Quantity: 1, UnitPrice: usd(2000)}}, nil)
+
+	t.Run("Quantity", func(t *testing.T) {
+		cartRepo.On("UpdateItem", mock.MatchedBy(func(item *models.CartItem) bool {
+			return item.ID == 8 && item.Quantity == 4
+		})).Return(nil).Once()
+
+		_, err := cartService.UpdateItem(context.Background(), models.CartOwner{UserID: 7}, 8, 4)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Zero removes the line", func(t *testing.T) {
+		cartRepo.On("DeleteItem", 3, 8).Return(nil).Once()
+
+		_, err := cartService.UpdateItem(context.Background(), models.CartOwner{UserID: 7}, 8, 0)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Line of another cart", func(t *testing.T) {
+		_, err := cartService.UpdateItem(context.Background(), models.CartOwner{UserID: 7}, 99, 1)
+		assert.ErrorIs(t, err, ErrCartItemNotFound)
+	})
+	cartRepo.AssertExpectations(t)
+}
+
+func TestMergeGuestCart(t *testing.T) {
+	guestHash := utils.HashOpaqueToken("guest-token")
+
+	t.Run("User without cart takes the guest cart", func(t *testing.T) {
+		cartRepo := new(MockCartRepo)
+		cartService := newTestCartService(cartRepo, new(MockProductRepo), new(MockVariantRepo))
+
+		cartRepo.On("GetByTokenHash", guestHash).Return(&models.Cart{ID: 5, TokenHash: guestHash, Currency: "USD"}, nil).Once()
+		cartRepo.On("Touch", 5, mock.AnythingOfType("*time.Time")).Return(nil).Once()
+		cartRepo.On("GetByUser", 7).Return(nil, ErrCartNotFound).Once()
+		cartRepo.On("AssignUser", 5, 7).Return(nil).Once()
+
+		assert.NoError(t, cartService.MergeGuestCart(context.Background(), 7, "guest-token"))
+		cartRepo.AssertExpectations(t)
+	})
+
+	t.Run("Lines are merged into the user cart", func(t *testing.T) {
+		cartRepo := new(MockCartRepo)
+		cartService := newTestCartService(cartRepo, new(MockProductRepo), new(MockVariantRepo))
+
+		cartRepo.On("GetByTokenHash", guestHash).Return(&models.Cart{ID: 5, TokenHash: guestHash, Currency: "USD"}, nil).Once()
+		cartRepo.On("Touch", 5, mock.AnythingOfType("*time.Time")).Return(nil).Once()
+		cartRepo.On("GetByUser", 7).Return(&models.Cart{ID: 3, UserID: 7, Currency: "USD"}, nil).Once()
+		cartRepo.On("Touch", 3, (*time.Time)(nil)).Return(nil).Once()
+		cartRepo.On("ListItems", 5).Return([]models.CartItem{
+			{ID: 10, CartID: 5, ProductID: 1, Quantity: 60, UnitPrice: usd(2000)},
+			{ID: 11, CartID: 5, ProductID: 2, Quantity: 1, UnitPrice: usd(1500)},
+		}, nil).Once()
+		cartRepo.On("ListItems", 3).Return([]models.CartItem{{ID: 8, CartID: 3, ProductID: 1, Quantity: 50, UnitPrice: usd(2000)}}, nil).Once()
+		cartRepo.On("UpdateItem", mock.MatchedBy(func(item *models.CartItem) bool {
+			return item.ID == 8 && item.Quantity == maxCartQuantity
+		})).Return(nil).Once()
+		cartRepo.On("AddItem", mock.MatchedBy(func(item *models.CartItem) bool {
+			return item.ID == 0 && item.CartID == 3 && item.ProductID == 2 && item.Quantity == 1
+		})).Return(nil).Once()
+		cartRepo.On("Delete", 5).Return(nil).Once()
+
+		assert.NoError(t, cartService.MergeGuestCart(context.Background(), 7, "guest-token"))
+		cartRepo.AssertExpectations(t)
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		cartRepo := new(MockCartRepo)
+		cartService := newTestCartService(cartRepo, new(MockProductRepo), new(MockVariantRepo))
+		cartRepo.On("GetByTokenHash", guestHash).Return(nil, ErrCartNotFound).Once()
+
+		assert.NoError(t, cartService.MergeGuestCart(context.Background(), 7, "guest-token"))
+		cartRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"ecommerce/utils"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrCategoryNotFound    = repository.ErrCategoryNotFound
+	ErrInvalidCategory     = errors.New("invalid category")
+	ErrCategoryHasChildren = errors.New("category has subcategories, move or delete them first")
+	ErrCategoryCycle       = errors.New("a category can't be moved below itself or its subcategories")
+)
+
+type CategoryService interface {
+	CreateCategory(ctx context.Context, category *models.Category) error
+	GetCategory(ctx context.Context, id int) (*models.Category, error)
+	GetCategoryTree(ctx context.Context) ([]models.Category, error)
+	UpdateCategory(ctx context.Context, category *models.Category) error
+	DeleteCategory(ctx context.Context, id int) error
+	GetCategoryProducts(ctx context.Context, id int, includeDescendants bool) ([]models.Product, error)
+	GetProductCategories(ctx context.Context, productID int) ([]models.Category, error)
+	SetProductCategories(ctx context.Context, productID int, categoryIDs []int) error
+}
+
+type categoryService struct {
+	categoryRepo repository.CategoryRepo
+	txManager    repository.TxManager
+}
+
+func NewCategoryService(categoryRepo repository.CategoryRepo, txManager repository.TxManager) CategoryService {
+	return &categoryService{categoryRepo: categoryRepo, txManager: txManager}
+}
+
+func (s *categoryService) CreateCategory(ctx context.Context, category *models.Category) error {
+	if err := s.validate(ctx, category); err != nil {
+		return err
+	}
+	return slugTaken(s.categoryRepo.Create(ctx, category), category.Slug)
+}
+
+func (s *categoryService) GetCategory(ctx context.Context, id int) (*models.Category, error) {
+	return s.categoryRepo.GetByID(ctx, id)
+}
+
+// GetCategoryTree returns the top level categories with their subcategories nested in Children
+func (s *categoryService) GetCategoryTree(ctx context.Context) ([]models.Category, error) {
+	categories, err := s.categoryRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return BuildCategoryTree(categories), nil
+}
+
+func (s *categoryService) UpdateCategory(ctx context.Context, category *models.Category) error {
+	if err := s.validate(ctx, category); err != nil {
+		return err
+	}
+	if category.ParentID != nil {
+		descendants, err := s.categoryRepo.DescendantIDs(ctx, category.ID)
+		if err != nil {
+			return err
+		}
+		for _, id := range descendants { // includes the category itself
+			if id == *category.ParentID {
+				return ErrCategoryCycle
+			}
+		}
+	}
+	return slugTaken(s.categoryRepo.Update(ctx, category), category.Slug)
+}
+
+func slugTaken(err error, slug string) error {
+	if repository.IsDuplicate(err) {
+		return fmt.Errorf("%w: slug %q is already used", ErrInvalidCategory, slug)
+	}
+	return err
+}
+
+func (s *categoryService) DeleteCategory(ctx context.Context, id int) error {
+	hasChildren, err := s.categoryRepo.HasChildren(ctx, id)
+	if err != nil {
+		return err
+	}
+	if hasChildren {
+		return ErrCategoryHasChildren
+	}
+	return s.categoryRepo.Delete(ctx, id)
+}
+
+func (s *categoryService) GetCategoryProducts(ctx context.Context, id int, includeDescendants bool) ([]models.Product, error) {
+	if _, err := s.categoryRepo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.categoryRepo.GetProducts(ctx, id, includeDescendants)
+}
+
+func (s *categoryService) GetProductCategories(ctx context.Context, productID int) ([]models.Category, error) {
+	return s.categoryRepo.GetProductCategories(ctx, productID)
+}
+
+// SetProductCategories replaces the categories of a product, every category must exist
+func (s *categoryService) SetProductCategories(ctx context.Context, productID int, categoryIDs []int) error {
+	unique := make([]int, 0, len(categoryIDs))
+	seen := map[int]bool{}
+	for _, id := range categoryIDs {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	return s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		if _, err := tx.Products.GetByID(ctx, productID); err != nil {
+			return err
+		}
+		for _, id := range unique {
+			if _, err := tx.Categories.GetByID(ctx, id); err != nil {
+				return fmt.Errorf("category %d: %w", id, err)
+			}
+		}
+		return tx.Categories.SetProductCategories(ctx, productID, unique)
+	})
+}
+
+// validate fills in the slug and checks the parent exists
+func (s *categoryService) validate(ctx context.Context, category *models.Category) error {
+	if category.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidCategory)
+	}
+	if category.Slug == "" {
+		category.Slug = utils.Slugify(category.Name)
+	} else {
+		category.Slug = utils.Slugify(category.Slug)
+	}
+	if category.Slug == "" {
+		return fmt.Errorf("%w: slug must contain letters or digits", ErrInvalidCategory)
+	}
+	if category.ParentID != nil {
+		if *category.ParentID == category.ID && category.ID != 0 {
+			return ErrCategoryCycle
+		}
+		if _, err := s.categoryRepo.GetByID(ctx, *category.ParentID); err != nil {
+			return fmt.Errorf("%w: parent: %v", ErrInvalidCategory, err)
+		}
+	}
+	return nil
+}
+
+// BuildCategoryTree nests a flat category list by ParentID, keeping the list order
+func BuildCategoryTree(categories []models.Category) []models.Category {
+	children := map[int][]models.Category{}
+	known := map[int]bool{}
+	for _, c := range categories {
+		known[c.ID] = true
+	}
+
+	var roots []models.Category
+	for _, c := range categories {
+		if c.ParentID != nil && known[*c.ParentID] {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+
+	var attach func(nodes []models.Category) []models.Category
+	attach = func(nodes []models.Category) []models.Category {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+	return attach(roots)
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCategoryRepo struct {
+	mock.Mock
+}
+
+func (m *MockCategoryRepo) Create(ctx context.Context, category *models.Category) error {
+	args := m.Called(category)
+	return args.Error(0)
+}
+
+func (m *MockCategoryRepo) GetByID(ctx context.Context, id int) (*models.Category, error) {
+	args := m.Called(id)
+	if category := args.Get(0); category != nil {
+		return category.(*models.Category), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCategoryRepo) GetAll(ctx context.Context) ([]models.Category, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Category), args.Error(1)
+}
+
+func (m *MockCategoryRepo) Update(ctx context.Context, category *models.Category) error {
+	args := m.Called(category)
+	return args.Error(0)
+}
+
+func (m *MockCategoryRepo) Delete(ctx context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockCategoryRepo) HasChildren(ctx context.Context, id int) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockCategoryRepo) DescendantIDs(ctx context.Context, id int) ([]int, error) {
+	args := m.Called(id)
+	return args.Get(0).([]int), args.Error(1)
+}
+
+func (m *MockCategoryRepo) GetProducts(ctx context.Context, categoryID int, includeDescendants bool) ([]models.Product, error) {
+	args := m.Called(categoryID, includeDescendants)
+	return args.Get(0).([]models.Product), args.Error(1)
+}
+
+func (m *MockCategoryRepo) GetProductCategories(ctx context.Context, productID int) ([]models.Category, error) {
+	args := m.Called(productID)
+	return args.Get(0).([]models.Category), args.Error(1)
+}
+
+func (m *MockCategoryRepo) SetProductCategories(ctx context.Context, productID int, categoryIDs []int) error {
+	args := m.Called(productID, categoryIDs)
+	return args.Error(0)
+}
+
+func (m *MockCategoryRepo) ProductCategoryIDs(ctx context.Context, productIDs []int) (map[int][]int, error) {
+	args := m.Called(productIDs)
+	return args.Get(0).(map[int][]int), args.Error(1)
+}
+
+func intPtr(i int) *int { return &i }
+
+func TestCreateCategory(t *testing.T) {
+	t.Run("Derives slug", func(t *testing.T) {
+		repo := new(MockCategoryRepo)
+		categoryService := NewCategoryService(repo, inlineTx{})
+		repo.On("GetByID", 1).Return(&models.Category{ID: 1, Name: "Electronics"}, nil)
+		repo.On("Create", mock.Anything).Return(nil)
+
+		category := &models.Category{Name: "TV & Audio", ParentID: intPtr(1)}
+		err := categoryService.CreateCategory(context.Background(), category)
+		assert.NoError(t, err)
+		assert.Equal(t, "tv-audio", category.Slug)
+	})
+	t.Run("Missing name", func(t *testing.T) {
+		categoryService := NewCategoryService(new(MockCategoryRepo), inlineTx{})
+		err := categoryService.CreateCategory(context.Background(), &models.Category{})
+		assert.ErrorIs(t, err, ErrInvalidCategory)
+	})
+	t.Run("Unknown parent", func(t *testing.T) {
+		repo := new(MockCategoryRepo)
+		categoryService := NewCategoryService(repo, inlineTx{})
+		repo.On("GetByID", 9).Return(nil, ErrCategoryNotFound)
+
+		err := categoryService.CreateCategory(context.Background(), &models.Category{Name: "Laptops", ParentID: intPtr(9)})
+		assert.ErrorIs(t, err, ErrInvalidCategory)
+		repo.AssertNotCalled(t, "Create", mock.Anything)
+	})
+}
+
+func TestUpdateCategory(t *testing.T) {
+	t.Run("Move below a descendant", func(t *testing.T) {
+		repo := new(MockCategoryRepo)
+		categoryService := NewCategoryService(repo, inlineTx{})
+		repo.On("GetByID", 5).Return(&models.Category{ID: 5, Name: "Gaming laptops", ParentID: intPtr(2)}, nil)
+		repo.On("DescendantIDs", 2).Return([]int{2, 5}, nil)
+
+		err := categoryService.UpdateCategory(context.Background(), &models.Category{ID: 2, Name: "Laptops", ParentID: intPtr(5)})
+		assert.Equal(t, ErrCategoryCycle, err)
+		repo.AssertNotCalled(t, "Update", mock.Anything)
+	})
+	t.Run("Own parent", func(t *testing.T) {
+		categoryService := NewCategoryService(new(MockCategoryRepo), inlineTx{})
+		err := categoryService.UpdateCategory(context.Background(), &models.Category{ID: 2, Name: "Laptops", ParentID: intPtr(2)})
+		assert.Equal(t, ErrCategoryCycle, err)
+	})
+	t.Run("Move", func(t *testing.T) {
+		repo := new(MockCategoryRepo)
+		categoryService := NewCategoryService(repo, inlineTx{})
+		repo.On("GetByID", 3).Return(&models.Category{ID: 3, Name: "Computers"}, nil)
+		repo.On("DescendantIDs", 2).Return([]int{2, 5}, nil)
+		repo.On("Update", mock.Anything).Return(nil)
+
+		err := categoryService.UpdateCategory(context.Background(), &models.Category{ID: 2, Name: "Laptops", ParentID: intPtr(3)})
+		assert.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+}
+
+func TestDeleteCategory(t *testing.T) {
+	repo := new(MockCategoryRepo)
+	categoryService := NewCategoryService(repo, inlineTx{})
+	repo.On("HasChildren", 1).Return(true, nil)
+	repo.On("HasChildren", 2).Return(false, nil)
+	repo.On("Delete", 2).Return(nil)
+
+	assert.Equal(t, ErrCategoryHasChildren, categoryService.DeleteCategory(context.Background(), 1))
+	assert.NoError(t, categoryService.DeleteCategory(context.Background(), 2))
+	repo.AssertNotCalled(t, "Delete", 1)
+}
+
+func TestGetCategoryTree(t *testing.T) {
+	repo := new(MockCategoryRepo)
+	categoryService := NewCategoryService(repo, inlineTx{})
+	repo.On("GetAll").Return([]models.Category{
+		{ID: 1, Name: "Electronics"},
+		{ID: 3, Name: "Gaming laptops", ParentID: intPtr(2)},
+		{ID: 2, Name: "Laptops", ParentID: intPtr(1)},
+		{ID: 4, Name: "Books"},
+	}, nil)
+
+	tree, err := categoryService.GetCategoryTree(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, tree, 2)
+	assert.Equal(t, "Electronics", tree[0].Name)
+	assert.Equal(t, "Laptops", tree[0].Children[0].Name)
+	assert.Equal(t, "Gaming laptops", tree[0].Children[0].Children[0].Name)
+	assert.Empty(t, tree[1].Children)
+}
+
+func TestSetProductCategories(t *testing.T) {
+	newService := func() (CategoryService, *MockCategoryRepo, *MockProductRepo) {
+		categoryRepo := new(MockCategoryRepo)
+		productRepo := new(MockProductRepo)
+		tx := inlineTx{repository.Repos{Products: productRepo, Categories: categoryRepo}}
+		return NewCategoryService(categoryRepo, tx), categoryRepo, productRepo
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		categoryService, categoryRepo, productRepo := newService()
+		productRepo.On("GetByID", 1).Return(&models.Product{ID: 1}, nil)
+		categoryRepo.On("GetByID", 2).Return(&models.Category{ID: 2}, nil)
+		categoryRepo.On("GetByID", 5).Return(&models.Category{ID: 5}, nil)
+		categoryRepo.On("SetProductCategories", 1, []int{2, 5}).Return(nil)
+
+		err := categoryService.SetProductCategories(context.Background(), 1, []int{2, 5, 2})
+		assert.NoError(t, err)
+		categoryRepo.AssertExpectations(t)
+	})
+	t.Run("Unknown category", func(t *testing.T) {
+		categoryService, categoryRepo, productRepo := newService()
+		productRepo.On("GetByID", 1).Return(&models.Product{ID: 1}, nil)
+		categoryRepo.On("GetByID", 9).Return(nil, ErrCategoryNotFound)
+
+		err := categoryService.SetProductCategories(context.Background(), 1, []int{9})
+		assert.ErrorIs(t, err, ErrCategoryNotFound)
+		categoryRepo.AssertNotCalled(t, "SetProductCategories", mock.Anything, mock.Anything)
+	})
+	t.Run("Unknown product", func(t *testing.T) {
+		categoryService, _, productRepo := newService()
+		productRepo.On("GetByID", 1).Return(nil, errors.New("product not found"))
+
+		err := categoryService.SetProductCategories(context.Background(), 1, []int{2})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,361 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInsufficientStock   = repository.ErrInsufficientStock
+	ErrInvalidAdjustment   = errors.New("invalid inventory adjustment")
+	ErrInvalidReservation  = errors.New("invalid reservation")
+	ErrReservationNotFound = errors.New("no active reservation")
+	ErrInvalidTransfer     = errors.New("invalid transfer")
+)
+
+const (
+	defaultAdjustmentsLimit = 50
+	maxAdjustmentsLimit     = 500
+)
+
+type InventoryService interface {
+	GetProductInventory(ctx context.Context, productID int) ([]models.InventoryItem, error)
+	AdjustStock(ctx context.Context, adjustment *models.InventoryAdjustment) (*models.InventoryItem, error)
+	GetAdjustments(ctx context.Context, productID, limit int) ([]models.InventoryAdjustment, error)
+
+	TransferStock(ctx context.Context, transfer *models.Transfer) error
+
+	Reserve(ctx context.Context, reference string, lines []models.StockLine, destination *models.GeoPoint) error
+	Release(ctx context.Context, reference string) error
+	Commit(ctx context.Context, reference string) error
+}
+
+type inventoryService struct {
+	productRepo   repository.ProductRepo
+	variantRepo   repository.VariantRepo
+	inventoryRepo repository.InventoryRepo
+	txManager     repository.TxManager
+	allocation    AllocationStrategy
+}
+
+func NewInventoryService(productRepo repository.ProductRepo, variantRepo repository.VariantRepo, inventoryRepo repository.InventoryRepo, txManager repository.TxManager, allocation AllocationStrategy) InventoryService {
+	return &inventoryService{productRepo: productRepo, variantRepo: variantRepo, inventoryRepo: inventoryRepo, txManager: txManager, allocation: allocation}
+}
+
+// GetProductInventory lists the stock of a product, or of each of its
+// variants when it has any, per warehouse. Nothing stocked yet shows up once
+// with zero quantities and no warehouse.
+func (s *inventoryService) GetProductInventory(ctx context.Context, productID int) ([]models.InventoryItem, error) {
+	if _, err := s.productRepo.GetByID(ctx, productID); err != nil {
+		return nil, err
+	}
+	items, err := s.inventoryRepo.ListItems(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	variants, err := s.variantRepo.ListByProducts(ctx, []int{productID})
+	if err != nil {
+		return nil, err
+	}
+
+	stocked := map[int]bool{}
+	productStocked := false
+	for _, item := range items {
+		if item.VariantID == nil {
+			productStocked = true
+		} else {
+			stocked[*item.VariantID] = true
+		}
+	}
+	if len(variants) == 0 && !productStocked {
+		items = append([]models.InventoryItem{{ProductID: productID}}, items...)
+	}
+	for _, variant := range variants {
+		if !stocked[variant.ID] {
+			id := variant.ID
+			items = append(items, models.InventoryItem{ProductID: productID, VariantID: &id, SKU: variant.SKU})
+		}
+	}
+	return items, nil
+}
+
+// AdjustStock changes the on hand quantity in a warehouse and records it in the ledger
+func (s *inventoryService) AdjustStock(ctx context.Context, adjustment *models.InventoryAdjustment) (*models.InventoryItem, error) {
+	adjustment.Note = strings.TrimSpace(adjustment.Note)
+	switch {
+	case adjustment.WarehouseID == 0:
+		return nil, fmt.Errorf("%w: warehouse is required", ErrInvalidAdjustment)
+	case adjustment.Delta == 0:
+		return nil, fmt.Errorf("%w: delta must not be zero", ErrInvalidAdjustment)
+	case !adjustment.Reason.Manual():
+		return nil, fmt.Errorf("%w: unknown reason %q", ErrInvalidAdjustment, adjustment.Reason)
+	}
+
+	var item *models.InventoryItem
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		current, err := tx.Inventory.EnsureItem(ctx, adjustment.WarehouseID, adjustment.ProductID, adjustment.VariantID)
+		if err != nil {
+			return err
+		}
+		if item, err = tx.Inventory.AdjustOnHand(ctx, current.ID, adjustment.Delta); err != nil {
+			if errors.Is(err, ErrInsufficientStock) {
+				return fmt.Errorf("%w: %d on hand, %d reserved", ErrInsufficientStock, current.OnHand, current.Reserved)
+			}
+			return err
+		}
+		adjustment.ItemID = item.ID
+		adjustment.OnHandAfter = item.OnHand
+		return tx.Inventory.AddAdjustment(ctx, adjustment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (s *inventoryService) GetAdjustments(ctx context.Context, productID, limit int) ([]models.InventoryAdjustment, error) {
+	if limit <= 0 {
+		limit = defaultAdjustmentsLimit
+	}
+	if limit > maxAdjustmentsLimit {
+		limit = maxAdjustmentsLimit
+	}
+	if _, err := s.productRepo.GetByID(ctx, productID); err != nil {
+		return nil, err
+	}
+	return s.inventoryRepo.ListAdjustments(ctx, productID, limit)
+}
+
+// TransferStock moves available stock between warehouses, the ledger gets
+// an entry for each side referencing the transfer
+func (s *inventoryService) TransferStock(ctx context.Context, transfer *models.Transfer) error {
+	transfer.Note = strings.TrimSpace(transfer.Note)
+	switch {
+	case transfer.Quantity <= 0:
+		return fmt.Errorf("%w: quantity must be greater than zero", ErrInvalidTransfer)
+	case transfer.FromWarehouseID == 0 || transfer.ToWarehouseID == 0:
+		return fmt.Errorf("%w: source and destination warehouse are required", ErrInvalidTransfer)
+	case transfer.FromWarehouseID == transfer.ToWarehouseID:
+		return fmt.Errorf("%w: source and destination warehouse must differ", ErrInvalidTransfer)
+	}
+
+	return s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		from, err := tx.Inventory.EnsureItem(ctx, transfer.FromWarehouseID, transfer.ProductID, transfer.VariantID)
+		if err != nil {
+			return err
+		}
+		to, err := tx.Inventory.EnsureItem(ctx, transfer.ToWarehouseID, transfer.ProductID, transfer.VariantID)
+		if err != nil {
+			return err
+		}
+		// reserved stock stays put, only what is available can move
+		if from.Available() < transfer.Quantity {
+			return fmt.Errorf("%w: %d available in warehouse %d, %d requested", ErrInsufficientStock, from.Available(), from.WarehouseID, transfer.Quantity)
+		}
+		if err := tx.Inventory.CreateTransfer(ctx, transfer); err != nil {
+			return err
+		}
+
+		reference := fmt.Sprintf("transfer-%d", transfer.ID)
+		sides := []struct {
+			item   *models.InventoryItem
+			delta  int
+			reason models.AdjustmentReason
+		}{
+			{from, -transfer.Quantity, models.ReasonTransferOut},
+			{to, transfer.Quantity, models.ReasonTransferIn},
+		}
+		for _, side := range sides {
+			item, err := tx.Inventory.AdjustOnHand(ctx, side.item.ID, side.delta)
+			if err != nil {
+				return err
+			}
+			adjustment := &models.InventoryAdjustment{
+				ItemID:      item.ID,
+				Delta:       side.delta,
+				OnHandAfter: item.OnHand,
+				Reason:      side.reason,
+				Reference:   reference,
+				Note:        transfer.Note,
+				CreatedBy:   transfer.CreatedBy,
+			}
+			if err := tx.Inventory.AddAdjustment(ctx, adjustment); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Reserve holds stock for every line or for none of them, the allocation
+// strategy picks the warehouses
+func (s *inventoryService) Reserve(ctx context.Context, reference string, lines []models.StockLine, destination *models.GeoPoint) error {
+	return s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		return reserveStock(ctx, tx, s.allocation, reference, lines, destination)
+	})
+}
+
+// Release hands the stock held for reference back, releasing twice is a no-op
+func (s *inventoryService) Release(ctx context.Context, reference string) error {
+	return s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		return releaseStock(ctx, tx, reference)
+	})
+}
+
+// Commit turns the stock held for reference into a sale
+func (s *inventoryService) Commit(ctx context.Context, reference string) error {
+	return s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		return commitStock(ctx, tx, reference)
+	})
+}
+
+// reserveStock is the body of Reserve, for services that reserve as part of their own transaction
+func reserveStock(ctx context.Context, tx repository.Repos, allocation AllocationStrategy, reference string, lines []models.StockLine, destination *models.GeoPoint) error {
+	if reference == "" {
+		return fmt.Errorf("%w: reference is required", ErrInvalidReservation)
+	}
+	lines, err := mergeStockLines(lines)
+	if err != nil {
+		return err
+	}
+	warehouses, err := tx.Warehouses.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int]models.Warehouse, len(warehouses))
+	for _, warehouse := range warehouses {
+		byID[warehouse.ID] = warehouse
+	}
+
+	for _, line := range lines {
+		items, err := tx.Inventory.ListAllocatable(ctx, line.ProductID, line.VariantID)
+		if err != nil {
+			return err
+		}
+		var sources []StockSource
+		available := 0
+		for _, item := range items {
+			if item.Available() > 0 {
+				sources = append(sources, StockSource{Item: item, Warehouse: byID[item.WarehouseID]})
+				available += item.Available()
+			}
+		}
+
+		remaining := line.Quantity
+		for _, source := range allocation.Rank(sources, destination) {
+			if remaining == 0 {
+				break
+			}
+			quantity := min(remaining, source.Item.Available())
+			if err := tx.Inventory.Reserve(ctx, source.Item.ID, quantity); err != nil {
+				if errors.Is(err, ErrInsufficientStock) {
+					continue // taken by a concurrent reservation since it was listed
+				}
+				return err
+			}
+			reservation := &models.Reservation{Reference: reference, ItemID: source.Item.ID, Quantity: quantity}
+			if err := tx.Inventory.CreateReservation(ctx, reservation); err != nil {
+				return err
+			}
+			remaining -= quantity
+		}
+		if remaining > 0 {
+			return fmt.Errorf("%w: %s has %d available, %d requested", ErrInsufficientStock, describeLine(line), available, line.Quantity)
+		}
+	}
+	return nil
+}
+
+func releaseStock(ctx context.Context, tx repository.Repos, reference string) error {
+	reservations, err := tx.Inventory.ActiveReservations(ctx, reference)
+	if err != nil {
+		return err
+	}
+	for _, reservation := range reservations {
+		if err := tx.Inventory.Release(ctx, reservation.ItemID, reservation.Quantity); err != nil {
+			return err
+		}
+		if err := tx.Inventory.SetReservationStatus(ctx, reservation.ID, models.ReservationReleased); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func commitStock(ctx context.Context, tx repository.Repos, reference string) error {
+	reservations, err := tx.Inventory.ActiveReservations(ctx, reference)
+	if err != nil {
+		return err
+	}
+	if len(reservations) == 0 {
+		return fmt.Errorf("%w for %s", ErrReservationNotFound, reference)
+	}
+	for _, reservation := range reservations {
+		item, err := tx.Inventory.Commit(ctx, reservation.ItemID, reservation.Quantity)
+		if err != nil {
+			return err
+		}
+		if err := tx.Inventory.SetReservationStatus(ctx, reservation.ID, models.ReservationCommitted); err != nil {
+			return err
+		}
+		adjustment := &models.InventoryAdjustment{
+			ItemID:      item.ID,
+			Delta:       -reservation.Quantity,
+			OnHandAfter: item.OnHand,
+			Reason:      models.ReasonSale,
+			Reference:   reference,
+		}
+		if err := tx.Inventory.AddAdjustment(ctx, adjustment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeStockLines adds up lines for the same item and sorts them, so
+// concurrent reservations lock the inventory rows in the same order
+func mergeStockLines(lines []models.StockLine) ([]models.StockLine, error) {
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%w: nothing to reserve", ErrInvalidReservation)
+	}
+	index := map[[2]int]int{} // product, variant -> position in result
+	var result []models.StockLine
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: quantity of %s must be greater than zero", ErrInvalidReservation, describeLine(line))
+		}
+		k := [2]int{line.ProductID, variantKey(line)}
+		if i, ok := index[k]; ok {
+			result[i].Quantity += line.Quantity
+			continue
+		}
+		index[k] = len(result)
+		result = append(result, line)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ProductID != result[j].ProductID {
+			return result[i].ProductID < result[j].ProductID
+		}
+		return variantKey(result[i]) < variantKey(result[j])
+	})
+	return result, nil
+}
+
+func variantKey(line models.StockLine) int {
+	if line.VariantID == nil {
+		return 0
+	}
+	return *line.VariantID
+}
+
+func describeLine(line models.StockLine) string {
+	if line.VariantID != nil {
+		return fmt.Sprintf("variant %d of product %d", *line.VariantID, line.ProductID)
+	}
+	return fmt.Sprintf("product %d", line.ProductID)
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockInventoryRepo struct {
+	mock.Mock
+}
+
+func (m *MockInventoryRepo) GetItem(ctx context.Context, warehouseID, productID int, variantID *int) (*models.InventoryItem, error) {
+	args := m.Called(warehouseID, productID, variantID)
+	if item := args.Get(0); item != nil {
+		return item.(*models.InventoryItem), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockInventoryRepo) EnsureItem(ctx context.Context, warehouseID, productID int, variantID *int) (*models.InventoryItem, error) {
+	args := m.Called(warehouseID, productID, variantID)
+	if item := args.Get(0); item != nil {
+		return item.(*models.InventoryItem), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockInventoryRepo) ListItems(ctx context.Context, productID int) ([]models.InventoryItem, error) {
+	args := m.Called(productID)
+	return args.Get(0).([]models.InventoryItem), args.Error(1)
+}
+
+func (m *MockInventoryRepo) ListAllocatable(ctx context.Context, productID int, variantID *int) ([]models.InventoryItem, error) {
+	args := m.Called(productID, variantID)
+	return args.Get(0).([]models.InventoryItem), args.Error(1)
+}
+
+func (m *MockInventoryRepo) AdjustOnHand(ctx context.Context, itemID, delta int) (*models.InventoryItem, error) {
+	args := m.Called(itemID, delta)
+	if item := args.Get(0); item != nil {
+		return item.(*models.InventoryItem), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockInventoryRepo) Reserve(ctx context.Context, itemID, quantity int) error {
+	args := m.Called(itemID, quantity)
+	return args.Error(0)
+}
+
+func (m *MockInventoryRepo) Release(ctx context.Context, itemID, quantity int) error {
+	args := m.Called(itemID, quantity)
+	return args.Error(0)
+}
+
+func (m *MockInventoryRepo) Commit(ctx context.Context, itemID, quantity int) (*models.InventoryItem, error) {
+	args := m.Called(itemID, quantity)
+	if item := args.Get(0); item != nil {
+		return item.(*models.InventoryItem), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockInventoryRepo) CreateReservation(ctx context.Context, reservation *models.Reservation) error {
+	args := m.Called(reservation)
+	return args.Error(0)
+}
+
+func (m *MockInventoryRepo) ActiveReservations(ctx context.Context, reference string) ([]models.Reservation, error) {
+	args := m.Called(reference)
+	return args.Get(0).([]models.Reservation), args.Error(1)
+}
+
+func (m *MockInventoryRepo) SetReservationStatus(ctx context.Context, id int, status models.ReservationStatus) error {
+	args := m.Called(id, status)
+	return args.Error(0)
+}
+
+func (m *MockInventoryRepo) AddAdjustment(ctx context.Context, adjustment *models.InventoryAdjustment) error {
+	args := m.Called(adjustment)
+	return args.Error(0)
+}
+
+func (m *MockInventoryRepo) ListAdjustments(ctx context.Context, productID, limit int) ([]models.InventoryAdjustment, error) {
+	args := m.Called(productID, limit)
+	return args.Get(0).([]models.InventoryAdjustment), args.Error(1)
+}
+
+func (m *MockInventoryRepo) CreateTransfer(ctx context.Context, transfer *models.Transfer) error {
+	args := m.Called(transfer)
+	return args.Error(0)
+}
+
+type MockWarehouseRepo struct {
+	mock.Mock
+}
+
+func (m *MockWarehouseRepo) Create(ctx context.Context, warehouse *models.Warehouse) error {
+	args := m.Called(warehouse)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepo) GetByID(ctx context.Context, id int) (*models.Warehouse, error) {
+	args := m.Called(id)
+	if warehouse := args.Get(0); warehouse != nil {
+		return warehouse.(*models.Warehouse), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepo) GetAll(ctx context.Context) ([]models.Warehouse, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Warehouse), args.Error(1)
+}
+
+func (m *MockWarehouseRepo) Update(ctx context.Context, warehouse *models.Warehouse) error {
+	args := m.Called(warehouse)
+	return args.Error(0)
+}
+
+// testWarehouses are Berlin (priority 2) and Madrid (priority 1)
+var testWarehouses = []models.Warehouse{
+	{ID: 1, Code: "berlin", Location: &models.GeoPoint{Latitude: 52.52, Longitude: 13.405}, Priority: 2, Active: true},
+	{ID: 2, Code: "madrid", Location: &models.GeoPoint{Latitude: 40.4168, Longitude: -3.7038}, Priority: 1, Active: true},
+}
+
+func newTestInventoryService(productRepo *MockProductRepo, variantRepo *MockVariantRepo, inventoryRepo *MockInventoryRepo) InventoryService {
+	warehouseRepo := new(MockWarehouseRepo)
+	warehouseRepo.On("GetAll").Return(testWarehouses, nil)
+	tx := inlineTx{repository.Repos{Products: productRepo, Variants: variantRepo, Inventory: inventoryRepo, Warehouses: warehouseRepo}}
+	return NewInventoryService(productRepo, variantRepo, inventoryRepo, tx, PriorityAllocation{})
+}
+
+func TestReserveStock(t *testing.T) {
+	small, large := 3, 4
+
+	t.Run("Success", func(t *testing.T) {
+		inventoryRepo := new(MockInventoryRepo)
+		inventoryService := newTestInventoryService(new(MockProductRepo), new(MockVariantRepo), inventoryRepo)
+		inventoryRepo.On("ListAllocatable", 1, &small).Return([]models.InventoryItem{{ID: 10, WarehouseID: 1, OnHand: 5}}, nil)
+		inventoryRepo.On("ListAllocatable", 2, (*int)(nil)).Return([]models.InventoryItem{{ID: 11, WarehouseID: 1, OnHand: 5}}, nil)
+		inventoryRepo.On("Reserve", 10, 3).Return(nil)
+		inventoryRepo.On("Reserve", 11, 1).Return(nil)
+		inventoryRepo.On("CreateReservation", mock.Anything).Return(nil)
+
+		// the same variant twice is reserved as one line
+		err := inventoryService.Reserve(context.Background(), "order-1", []models.StockLine{
+			{ProductID: 2, Quantity: 1},
+			{ProductID: 1, VariantID: &small, Quantity: 1},
+			{ProductID: 1, VariantID: &small, Quantity: 2},
+		}, nil)
+		assert.NoError(t, err)
+		inventoryRepo.AssertNumberOfCalls(t, "CreateReservation", 2)
+		inventoryRepo.AssertCalled(t, "Reserve", 10, 3)
+		assert.Equal(t, 1, inventoryRepo.Calls[0].Arguments.Int(0), "lines are reserved in product order")
+	})
+	t.Run("Split across warehouses", func(t *testing.T) {
+		inventoryRepo := new(MockInventoryRepo)
+		inventoryService := newTestInventoryService(new(MockProductRepo), new(MockVariantRepo), inventoryRepo)
+		inventoryRepo.On("ListAllocatable", 1, (*int)(nil)).Return([]models.InventoryItem{
+			{ID: 10, WarehouseID: 1, OnHand: 5},
+			{ID: 11, WarehouseID: 2, OnHand: 3, Reserved: 1},
+		}, nil)
+		inventoryRepo.On("Reserve", 11, 2).Return(nil)
+		inventoryRepo.On("Reserve", 10, 2).Return(nil)
+		inventoryRepo.On("CreateReservation", mock.Anything).Return(nil)
+
+		err := inventoryService.Reserve(context.Background(), "order-2", []models.StockLine{{ProductID: 1, Quantity: 4}}, nil)
+		assert.NoError(t, err)
+		// Madrid has the lower priority number, so it is emptied first
+		assert.Equal(t, &models.Reservation{Reference: "order-2", ItemID: 11, Quantity: 2}, inventoryRepo.Calls[2].Arguments.Get(0))
+		assert.Equal(t, &models.Reservation{Reference: "order-2", ItemID: 10, Quantity: 2}, inventoryRepo.Calls[4].Arguments.Get(0))
+	})
+	t.Run("Insufficient stock", func(t *testing.T) {
+		inventoryRepo := new(MockInventoryRepo)
+		inventoryService := newTestInventoryService(new(MockProductRepo), new(MockVariantRepo), inventoryRepo)
+		inventoryRepo.On("ListAllocatable", 1, &small).Return([]models.InventoryItem{{ID: 10, WarehouseID: 1, OnHand: 5}}, nil)
+		inventoryRepo.On("ListAllocatable", 1, &large).Return([]models.InventoryItem{{ID: 12, WarehouseID: 1, OnHand: 2, Reserved: 1}}, nil)
+		inventoryRepo.On("Reserve", mock.Anything, mock.Anything).Return(nil)
+		inventoryRepo.On("CreateReservation", mock.Anything).Return(nil)
+
+		err := inventoryService.Reserve(context.Background(), "order-3", []models.StockLine{
+			{ProductID: 1, VariantID: &small, Quantity: 1},
+			{ProductID: 1, VariantID: &large, Quantity: 2},
+		}, nil)
+		assert.ErrorIs(t, err, ErrInsufficientStock)
+		assert.Contains(t, err.Error(), "1 available, 2 requested")
+	})
+	t.Run("Lost to a concurrent reservation", func(t *testing.T) {
+		inventoryRepo := new(MockInventoryRepo)
+		inventoryService := newTestInventoryService(new(MockProductRepo), new(MockVariantRepo), inventoryRepo)
+		inventoryRepo.On("ListAllocatable", 1, (*int)(nil)).Return([]models.InventoryItem{{ID: 10, WarehouseID: 1, OnHand: 1}}, nil)
+		inventoryRepo.On("Reserve", 10, 1).Return(repository.ErrInsufficientStock)
+
+		err := inventoryService.Reserve(context.Background(), "order-4", []models.StockLine{{ProductID: 1, Quantity: 1}}, nil)
+		assert.ErrorIs(t, err, ErrInsufficientStock)
+		inventoryRepo.AssertNotCalled(t, "CreateReservation", mock.Anything)
+	})
+	t.Run("Validation", func(t *testing.T) {
+		inventoryService := newTestInventoryService(new(MockProductRepo), new(MockVariantRepo), new(MockInventoryRepo))
+
+		assert.ErrorIs(t, inventoryService.Reserve(context.Background(), "", []models.StockLine{{ProductID: 1, Quantity: 1}}, nil), ErrInvalidReservation)
+		assert.ErrorIs(t, inventoryService.Reserve(context.Background(), "order-5", nil, nil), ErrInvalidReservation)
+		assert.ErrorIs(t, inventoryService.Reserve(context.Background(), "order-5", []models.StockLine{{ProductID: 1}}, nil), ErrInvalidReservation)
+	})
+}
+
+func TestCommitStock(t *testing.T) {
+	inventoryRepo := new(MockInventoryRepo)
+	inventoryService := newTestInventoryService(new(MockProductRepo), new(MockVariantRepo), inventoryRepo)
+	inventoryRepo.On("ActiveReservations", "order-1").Return([]models.Reservation{{ID: 1, ItemID: 10, Quantity: 3}}, nil)
+	inventoryRepo.On("ActiveReservations", "order-9").Return([]models.Reservation(nil), nil)
+	inventoryRepo.On("Commit", 10, 3).Return(&models.InventoryItem{ID: 10, OnHand: 2}, nil)
+	inventoryRepo.On("SetReservationStatus", 1, models.ReservationCommitted).Return(nil)
+	inventoryRepo.On("AddAdjustment", &models.InventoryAdjustment{ItemID: 10, Delta: -3, OnHandAfter: 2, Reason: models.ReasonSale, Reference: "order-1"}).Return(nil)
+
+	assert.NoError(t, inventoryService.Commit(context.Background(), "order-1"))
+	assert.ErrorIs(t, inventoryService.Commit(context.Background(), "order-9"), ErrReservationNotFound)
+	inventoryRepo.AssertExpectations(t)
+}
+
+func TestReleaseStock(t *testing.T) {
+	inventoryRepo := new(MockInventoryRepo)
+	inventoryService := newTestInventoryService(new(MockProductRepo), new(MockVariantRepo), inventoryRepo)
+	inventoryRepo.On("ActiveReservations", "order-1").Return([]models.Reservation{{ID: 1, ItemID: 10, Quantity: 3}}, nil).Once()
+	inventoryRepo.On("ActiveReservations", "order-1").Return([]models.Reservation(nil), nil)
+	inventoryRepo.On("Release", 10, 3).Return(nil).Once()
+	inventoryRepo.On("SetReservationStatus", 1, models.ReservationReleased).Return(nil).Once()
+
+	assert.NoError(t, inventoryService.Release(context.Background(), "order-1"))
+	assert.NoError(t, inventoryService.Release(context.Background(), "order-1"), "releasing twice is a no-op")
+	inventoryRepo.AssertExpectations(t)
+}
+
+func TestAdjustStock(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		inventoryRepo := new(MockInventoryRepo)
+		inventoryService := newTestInventoryService(new(MockProductRepo), new(MockVariantRepo), inventoryRepo)
+		inventoryRepo.On("EnsureItem", 2, 1, (*int)(nil)).Return(&models.InventoryItem{ID: 10, WarehouseID: 2}, nil)
+		inventoryRepo.On("AdjustOnHand", 10, 20).Return(&models.InventoryItem{ID: 10, OnHand: 20}, nil)
+		inventoryRepo.On("AddAdjustment", mock.Anything).Return(nil)
+
+		adjustment := &models.InventoryAdjustment{WarehouseID: 2, ProductID: 1, Delta: 20, Reason: models.ReasonRestock, Note: " PO 118 ", CreatedBy: 2}
+		item, err := inventoryService.AdjustStock(context.Background(), adjustment)
+		assert.NoError(t, err)
+		assert.Equal(t, 20, item.OnHand)
+		assert.Equal(t, 20, adjustment.OnHandAfter)
+		assert.Equal(t, "PO 118", adjustment.Note)
+	})
+	t.Run("Below reserved", func(t *testing.T) {
+		inventoryRepo := new(MockInventoryRepo)
+		inventoryService := newTestInventoryService(new(MockProductRepo), new(MockVariantRepo), inventoryRepo)
+		inventoryRepo.On("EnsureItem", 2, 1, (*int)(nil)).Return(&models.InventoryItem{ID: 10, OnHand: 5, Reserved: 4}, nil)
+		inventoryRepo.On("AdjustOnHand", 10, -2).Return(nil, repository.ErrInsufficientStock)
+
+		_, err := inventoryService.AdjustStock(context.Background(), &models.InventoryAdjustment{WarehouseID: 2, ProductID: 1, Delta: -2, Reason: models.ReasonDamaged})
+		assert.ErrorIs(t, err, ErrInsufficientStock)
+		inventoryRepo.AssertNotCalled(t, "AddAdjustment", mock.Anything)
+	})
+	t.Run("Validation", func(t *testing.T) {
+		inventoryService := newTestInventoryService(new(MockProductRepo), new(MockVariantRepo), new(MockInventoryRepo))
+
+		_, err := inventoryService.AdjustStock(context.Background(), &models.InventoryAdjustment{WarehouseID: 2, ProductID: 1, Reason: models.ReasonRestock})
+		assert.ErrorIs(t, err, ErrInvalidAdjustment)
+		_, err = inventoryService.AdjustStock(context.Background(), &models.InventoryAdjustment{ProductID: 1, Delta: 1, Reason: models.ReasonRestock})
+		assert.ErrorIs(t, err, ErrInvalidAdjustment, "the warehouse is required")
+		_, err = inventoryService.AdjustStock(context.Background(), &models.InventoryAdjustment{WarehouseID: 2, ProductID: 1, Delta: -1, Reason: models.ReasonSale})
+		assert.ErrorIs(t, err, ErrInvalidAdjustment, "sales are only recorded by Commit")
+	})
+}
+
+func TestGetProductInventory(t *testing.T) {
+	productRepo := new(MockProductRepo)
+	variantRepo := new(MockVariantRepo)
+	inventoryRepo := new(MockInventoryRepo)
+	inventoryService := newTestInventoryService(productRepo, variantRepo, inventoryRepo)
+
+	stocked := 3
+	productRepo.On("GetByID", 1).Return(&models.Product{ID: 1}, nil)
+	inventoryRepo.On("ListItems", 1).Return([]models.InventoryItem{{ID: 10, ProductID: 1, VariantID: &stocked, SKU: "TS-S", OnHand: 4}}, nil)
+	variantRepo.On("ListByProducts", []int{1}).Return([]models.Variant{{ID: 3, SKU: "TS-S"}, {ID: 4, SKU: "TS-M"}}, nil)
+
+	items, err := inventoryService.GetProductInventory(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, "TS-M", items[1].SKU)
+	assert.Equal(t, 0, items[1].OnHand)
+}
+
+func TestTransferStock(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		inventoryRepo := new(MockInventoryRepo)
+		inventoryService := newTestInventoryService(new(MockProductRepo), new(MockVariantRepo), inventoryRepo)
+		inventoryRepo.On("EnsureItem", 1, 5, (*int)(nil)).Return(&models.InventoryItem{ID: 10, WarehouseID: 1, OnHand: 8, Reserved: 2}, nil)
+		inventoryRepo.On("EnsureItem", 2, 5, (*int)(nil)).Return(&models.InventoryItem{ID: 11, WarehouseID: 2}, nil)
+		inventoryRepo.On("CreateTransfer", mock.Anything).Run(func(args mock.Arguments) {
+			args.Get(0).(*models.Transfer).ID = 3
+		}).Return(nil)
+		inventoryRepo.On("AdjustOnHand", 10, -6).Return(&models.InventoryItem{ID: 10, OnHand: 2, Reserved: 2}, nil)
+		inventoryRepo.On("AdjustOnHand", 11, 6).Return(&models.InventoryItem{ID: 11, OnHand: 6}, nil)
+		inventoryRepo.On("AddAdjustment", &models.InventoryAdjustment{ItemID: 10, Delta: -6, OnHandAfter: 2, Reason: models.ReasonTransferOut, Reference: "transfer-3", CreatedBy: 4}).Return(nil)
+		inventoryRepo.On("AddAdjustment", &models.InventoryAdjustment{ItemID: 11, Delta: 6, OnHandAfter: 6, Reason: models.ReasonTransferIn, Reference: "transfer-3", CreatedBy: 4}).Return(nil)
+
+		transfer := &models.Transfer{ProductID: 5, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: 6, CreatedBy: 4}
+		assert.NoError(t, inventoryService.TransferStock(context.Background(), transfer))
+		inventoryRepo.AssertExpectations(t)
+	})
+	t.Run("Reserved stock stays", func(t *testing.T) {
+		inventoryRepo := new(MockInventoryRepo)
+		inventoryService := newTestInventoryService(new(MockProductRepo), new(MockVariantRepo), inventoryRepo)
+		inventoryRepo.On("EnsureItem", 1, 5, (*int)(nil)).Return(&models.InventoryItem{ID: 10, WarehouseID: 1, OnHand: 8, Reserved: 4}, nil)
+		inventoryRepo.On("EnsureItem", 2, 5, (*int)(nil)).Return(&models.InventoryItem{ID: 11, WarehouseID: 2}, nil)
+
+		err := inventoryService.TransferStock(context.Background(), &models.Transfer{ProductID: 5, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: 6})
+		assert.ErrorIs(t, err, ErrInsufficientStock)
+		inventoryRepo.AssertNotCalled(t, "CreateTransfer", mock.Anything)
+	})
+	t.Run("Validation", func(t *testing.T) {
+		inventoryService := newTestInventoryService(new(MockProductRepo), new(MockVariantRepo), new(MockInventoryRepo))
+
+		for _, transfer := range []*models.Transfer{
+			{ProductID: 5, FromWarehouseID: 1, ToWarehouseID: 2},
+			{ProductID: 5, FromWarehouseID: 1, ToWarehouseID: 1, Quantity: 1},
+			{ProductID: 5, ToWarehouseID: 2, Quantity: 1},
+		} {
+			assert.ErrorIs(t, inventoryService.TransferStock(context.Background(), transfer), ErrInvalidTransfer)
+		}
+	})
+}
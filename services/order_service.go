@@ -0,0 +1,375 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"errors"
+	"fmt"
+	"log"
+)
+
+var (
+	ErrOrderNotFound       = repository.ErrOrderNotFound
+	ErrOrderStatusConflict = repository.ErrOrderStatusConflict
+	ErrEmptyCart           = errors.New("cart is empty")
+	ErrInvalidOrderStatus  = errors.New("invalid order status")
+	ErrInvalidTransition   = errors.New("invalid order status transition")
+)
+
+const (
+	defaultOrdersLimit = 50
+	maxOrdersLimit     = 500
+)
+
+type OrderService interface {
+	// Checkout places the user's cart as an order, with the active promotions
+	// and the request's coupons applied and taxed for its destination
+	Checkout(ctx context.Context, request models.CheckoutRequest) (*models.Order, error)
+	GetOrder(ctx context.Context, id int) (*models.Order, error)
+	GetUserOrder(ctx context.Context, userID, id int) (*models.Order, error)
+	GetOrders(ctx context.Context, filter models.OrderFilter) ([]models.Order, error)
+	GetOrderHistory(ctx context.Context, id int) ([]models.OrderStatusChange, error)
+	TransitionOrder(ctx context.Context, change *models.OrderStatusChange) (*models.Order, error)
+	CancelOrder(ctx context.Context, userID, id int) (*models.Order, error)
+}
+
+type orderService struct {
+	orderRepo      repository.OrderRepo
+	productService ProductService
+	taxCalculator  TaxCalculator
+	gateway        PaymentGateway
+	txManager      repository.TxManager
+	allocation     AllocationStrategy
+}
+
+func NewOrderService(orderRepo repository.OrderRepo, productService ProductService, taxCalculator TaxCalculator, gateway PaymentGateway,
+	txManager repository.TxManager, allocation AllocationStrategy) OrderService {
+	return &orderService{orderRepo: orderRepo, productService: productService, taxCalculator: taxCalculator, gateway: gateway,
+		txManager: txManager, allocation: allocation}
+}
+
+// Checkout turns the user's cart into a pending order. Lines are priced at
+// the current catalogue price and stock is reserved for all of them, or the
+// checkout fails and the cart is left as it was. Promotions are evaluated
+// against the priced lines and redeemed with the order, so a coupon that
+// runs out meanwhile fails the checkout. Tax is worked out last, on what
+// the lines sell for after their discounts.
+// The saved addresses picked are copied onto the order, the shipping
+// address is the destination taxed and the one the closest allocation
+// reserves stock near.
+func (s *orderService) Checkout(ctx context.Context, request models.CheckoutRequest) (*models.Order, error) {
+	userID := request.UserID
+	order := &models.Order{UserID: userID, Status: models.OrderPending}
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		cart, err := lockCart(ctx, tx, models.CartOwner{UserID: userID})
+		if errors.Is(err, ErrCartNotFound) {
+			return ErrEmptyCart
+		}
+		if err != nil {
+			return err
+		}
+		cartItems, err := tx.Carts.ListItems(ctx, cart.ID)
+		if err != nil {
+			return err
+		}
+		if len(cartItems) == 0 {
+			return ErrEmptyCart
+		}
+
+		if order.Addresses, err = checkoutAddresses(ctx, tx, request); err != nil {
+			return err
+		}
+		destination := request.Destination
+		if shipping := order.Address(models.AddressShipping); shipping != nil {
+			destination = shipping.Destination()
+		}
+
+		order.Currency = cart.Currency
+		lines := make([]models.StockLine, 0, len(cartItems))
+		promotionLines := make([]models.PromotionLine, 0, len(cartItems))
+		taxClasses := make([]string, 0, len(cartItems))
+		amounts := make([]models.Money, 0, len(cartItems))
+		for _, cartItem := range cartItems {
+			item, err := s.snapshot(ctx, cartItem)
+			if err != nil {
+				return err
+			}
+			if item.UnitPrice.Currency != order.Currency {
+				return fmt.Errorf("%w: %s is priced in %s, the cart in %s", ErrInvalidCartItem, item.Name, item.UnitPrice.Currency, order.Currency)
+			}
+			order.Items = append(order.Items, item)
+			lines = append(lines, models.StockLine{ProductID: cartItem.ProductID, VariantID: cartItem.VariantID, Quantity: cartItem.Quantity})
+			promotionLines = append(promotionLines, models.PromotionLine{ProductID: cartItem.ProductID, VariantID: cartItem.VariantID,
+				Quantity: item.Quantity, UnitPrice: item.UnitPrice})
+			lineTotal, err := item.LineTotal()
+			if err != nil {
+				return err
+			}
+			taxClasses = append(taxClasses, item.TaxClass)
+			amounts = append(amounts, lineTotal)
+		}
+		if order.Subtotal, err = order.ItemSubtotal(); err != nil {
+			return err
+		}
+
+		engine := promotionEngine{promotions: tx.Promotions, productService: s.productService}
+		promotions, err := engine.evaluate(ctx, userID, request.CouponCodes, order.Currency, promotionLines)
+		if err != nil {
+			return err
+		}
+		order.Discount = promotions.Discount
+		order.FreeShipping = promotions.FreeShipping
+
+		taxLines, err := discountedTaxLines(taxClasses, amounts, promotions)
+		if err != nil {
+			return err
+		}
+		taxes, err := s.taxCalculator.Calculate(ctx, destination, order.Currency, taxLines)
+		if err != nil {
+			return err
+		}
+		applyTaxes(order, taxes)
+
+		if err := tx.Orders.Create(ctx, order); err != nil {
+			return err
+		}
+		for i := range order.Items {
+			order.Items[i].OrderID = order.ID
+			if err := tx.Orders.AddItem(ctx, &order.Items[i]); err != nil {
+				return err
+			}
+		}
+		for i := range order.Addresses {
+			order.Addresses[i].OrderID = order.ID
+			if err := tx.Orders.AddAddress(ctx, &order.Addresses[i]); err != nil {
+				return err
+			}
+		}
+		if err := recordDiscounts(ctx, tx, order, promotions); err != nil {
+			return err
+		}
+		if err := reserveStock(ctx, tx, s.allocation, order.StockReference(), lines, destination.Location()); err != nil {
+			return err
+		}
+		placed := &models.OrderStatusChange{OrderID: order.ID, To: models.OrderPending, ChangedBy: userID}
+		if err := tx.Orders.AddStatusChange(ctx, placed); err != nil {
+			return err
+		}
+		return tx.Carts.ClearItems(ctx, cart.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// recordDiscounts stores the promotion breakdown with the order and counts
+// a use of every promotion applied
+func recordDiscounts(ctx context.Context, tx repository.Repos, order *models.Order, promotions *models.PromotionResult) error {
+	for _, discount := range promotions.Discounts {
+		promotionID := discount.PromotionID
+		orderDiscount := models.OrderDiscount{OrderID: order.ID, PromotionID: &promotionID, Name: discount.Name, Code: discount.Code,
+			Kind: discount.Kind, Amount: discount.Amount}
+		if discount.Line >= 0 {
+			orderDiscount.OrderItemID = &order.Items[discount.Line].ID
+		}
+		if err := tx.Orders.AddDiscount(ctx, &orderDiscount); err != nil {
+			return err
+		}
+		order.Discounts = append(order.Discounts, orderDiscount)
+	}
+	for _, promotionID := range promotions.PromotionIDs() {
+		redemption := &models.PromotionRedemption{PromotionID: promotionID, OrderID: order.ID, UserID: order.UserID}
+		if err := tx.Promotions.Redeem(ctx, redemption); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyTaxes copies the tax breakdown onto the order and its items
+func applyTaxes(order *models.Order, taxes *models.TaxResult) {
+	order.Tax = taxes.Tax
+	order.TaxInclusive = taxes.Mode == models.TaxInclusive
+	for _, line := range taxes.Lines {
+		item := &order.Items[line.Line]
+		item.TaxName, item.TaxRate, item.Tax = line.Name, line.Rate, line.Tax
+	}
+}
+
+// snapshot prices a cart line through the catalogue, so the order keeps
+// what was sold even if the product changes later
+func (s *orderService) snapshot(ctx context.Context, cartItem models.CartItem) (models.OrderItem, error) {
+	product, err := s.productService.GetProductByID(ctx, cartItem.ProductID)
+	if err != nil {
+		return models.OrderItem{}, err
+	}
+	productID := product.ID
+	item := models.OrderItem{ProductID: &productID, Name: product.Name, Quantity: cartItem.Quantity, UnitPrice: product.Price, TaxClass: product.TaxClass}
+	if cartItem.VariantID == nil {
+		return item, nil
+	}
+	for _, variant := range product.Variants {
+		if variant.ID == *cartItem.VariantID {
+			variantID := variant.ID
+			item.VariantID = &variantID
+			item.SKU = variant.SKU
+			item.UnitPrice = variant.EffectivePrice(product)
+			return item, nil
+		}
+	}
+	return models.OrderItem{}, fmt.Errorf("%w: variant %d of product %d", ErrVariantNotFound, *cartItem.VariantID, product.ID)
+}
+
+// GetOrder returns the order with its items, discounts and addresses
+func (s *orderService) GetOrder(ctx context.Context, id int) (*models.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if order.Items, err = s.orderRepo.ListItems(ctx, []int{order.ID}); err != nil {
+		return nil, err
+	}
+	if order.Discounts, err = s.orderRepo.ListDiscounts(ctx, order.ID); err != nil {
+		return nil, err
+	}
+	if order.Addresses, err = s.orderRepo.ListAddresses(ctx, order.ID); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// GetUserOrder is GetOrder for customers, other users' orders are not found
+func (s *orderService) GetUserOrder(ctx context.Context, userID, id int) (*models.Order, error) {
+	order, err := s.GetOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if order.UserID != userID {
+		return nil, ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// GetOrders lists orders newest first with their items
+func (s *orderService) GetOrders(ctx context.Context, filter models.OrderFilter) ([]models.Order, error) {
+	if filter.Status != "" && !filter.Status.Valid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidOrderStatus, filter.Status)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultOrdersLimit
+	}
+	if filter.Limit > maxOrdersLimit {
+		filter.Limit = maxOrdersLimit
+	}
+
+	orders, err := s.orderRepo.List(ctx, filter)
+	if err != nil || len(orders) == 0 {
+		return orders, err
+	}
+	ids := make([]int, len(orders))
+	index := make(map[int]int, len(orders))
+	for i, order := range orders {
+		ids[i] = order.ID
+		index[order.ID] = i
+	}
+	items, err := s.orderRepo.ListItems(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		i := index[item.OrderID]
+		orders[i].Items = append(orders[i].Items, item)
+	}
+	return orders, nil
+}
+
+func (s *orderService) GetOrderHistory(ctx context.Context, id int) ([]models.OrderStatusChange, error) {
+	if _, err := s.orderRepo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.orderRepo.ListStatusChanges(ctx, id)
+}
+
+// TransitionOrder moves an order to change.To if the state machine allows it.
+// The authorizations still held for a cancelled order are voided after the
+// commit, one the gateway won't void is left for staff.
+func (s *orderService) TransitionOrder(ctx context.Context, change *models.OrderStatusChange) (*models.Order, error) {
+	if !change.To.Valid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidOrderStatus, change.To)
+	}
+	var authorized []models.Payment
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		authorized = nil
+		order, err := tx.Orders.GetByID(ctx, change.OrderID)
+		if err != nil {
+			return err
+		}
+		if err := transitionOrder(ctx, tx, order, change); err != nil || change.To != models.OrderCancelled {
+			return err
+		}
+		payments, err := tx.Payments.ListByOrder(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		for _, payment := range payments {
+			if payment.Status == models.PaymentAuthorized {
+				authorized = append(authorized, payment)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range authorized {
+		if _, err := voidPayment(ctx, s.txManager, s.gateway, &authorized[i]); err != nil {
+			log.Printf("failed to void payment %d of cancelled order %d: %v", authorized[i].ID, change.OrderID, err)
+		}
+	}
+	return s.GetOrder(ctx, change.OrderID)
+}
+
+// CancelOrder lets a customer cancel their own order while it is unpaid
+func (s *orderService) CancelOrder(ctx context.Context, userID, id int) (*models.Order, error) {
+	if _, err := s.GetUserOrder(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	return s.TransitionOrder(ctx, &models.OrderStatusChange{OrderID: id, To: models.OrderCancelled, ChangedBy: userID})
+}
+
+// transitionOrder applies a status change inside tx: it checks the state
+// machine, settles the order's stock reservation, gives back the promotion
+// uses of a cancelled order and records the history entry. The status
+// update is conditional, so of two concurrent transitions only one wins.
+func transitionOrder(ctx context.Context, tx repository.Repos, order *models.Order, change *models.OrderStatusChange) error {
+	if !order.Status.CanTransitionTo(change.To) {
+		return fmt.Errorf("%w: a %s order can't become %s", ErrInvalidTransition, order.Status, change.To)
+	}
+	if order.Status.HoldsStock() && !change.To.HoldsStock() {
+		settle := releaseStock
+		if change.To == models.OrderFulfilled {
+			settle = commitStock
+		}
+		if err := settle(ctx, tx, order.StockReference()); err != nil {
+			return err
+		}
+	}
+	if change.To == models.OrderCancelled {
+		// the promotions it used can be used again
+		if err := tx.Promotions.ReleaseRedemptions(ctx, order.ID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Orders.UpdateStatus(ctx, order.ID, order.Status, change.To); err != nil {
+		return err
+	}
+	change.OrderID = order.ID
+	change.From = order.Status
+	if err := tx.Orders.AddStatusChange(ctx, change); err != nil {
+		return err
+	}
+	order.Status = change.To
+	return nil
+}
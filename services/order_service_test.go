@@ -0,0 +1,463 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockOrderRepo struct {
+	mock.Mock
+}
+
+func (m *MockOrderRepo) Create(ctx context.Context, order *models.Order) error {
+	args := m.Called(order)
+	if args.Error(0) == nil {
+		order.ID = 40
+	}
+	return args.Error(0)
+}
+
+func (m *MockOrderRepo) AddItem(ctx context.Context, item *models.OrderItem) error {
+	args := m.Called(item)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepo) GetByID(ctx context.Context, id int) (*models.Order, error) {
+	args := m.Called(id)
+	if order := args.Get(0); order != nil {
+		copied := *order.(*models.Order) // callers change the status of what they get
+		return &copied, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderRepo) Lock(ctx context.Context, id int) (*models.Order, error) {
+	args := m.Called(id)
+	if order := args.Get(0); order != nil {
+		copied := *order.(*models.Order)
+		return &copied, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderRepo) List(ctx context.Context, filter models.OrderFilter) ([]models.Order, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]models.Order), args.Error(1)
+}
+
+func (m *MockOrderRepo) ListItems(ctx context.Context, orderIDs []int) ([]models.OrderItem, error) {
+	args := m.Called(orderIDs)
+	return args.Get(0).([]models.OrderItem), args.Error(1)
+}
+
+func (m *MockOrderRepo) UpdateStatus(ctx context.Context, id int, from, to models.OrderStatus) error {
+	args := m.Called(id, from, to)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepo) AddRefund(ctx context.Context, id int, amount models.Money) error {
+	args := m.Called(id, amount)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepo) AddDiscount(ctx context.Context, discount *models.OrderDiscount) error {
+	args := m.Called(discount)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepo) ListDiscounts(ctx context.Context, orderID int) ([]models.OrderDiscount, error) {
+	args := m.Called(orderID)
+	return args.Get(0).([]models.OrderDiscount), args.Error(1)
+}
+
+func (m *MockOrderRepo) AddAddress(ctx context.Context, address *models.OrderAddress) error {
+	args := m.Called(address)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepo) ListAddresses(ctx context.Context, orderID int) ([]models.OrderAddress, error) {
+	args := m.Called(orderID)
+	return args.Get(0).([]models.OrderAddress), args.Error(1)
+}
+
+func (m *MockOrderRepo) AddStatusChange(ctx context.Context, change *models.OrderStatusChange) error {
+	args := m.Called(change)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepo) ListStatusChanges(ctx context.Context, orderID int) ([]models.OrderStatusChange, error) {
+	args := m.Called(orderID)
+	return args.Get(0).([]models.OrderStatusChange), args.Error(1)
+}
+
+type orderTestRepos struct {
+	orders     *MockOrderRepo
+	carts      *MockCartRepo
+	products   *MockProductRepo
+	variants   *MockVariantRepo
+	inventory  *MockInventoryRepo
+	promotions *MockPromotionRepo
+	taxes      *MockTaxRepo
+	addresses  *MockAddressRepo
+	payments   *MockPaymentRepo
+	gateway    *FakeGateway
+}
+
+func newTestOrderService() (OrderService, orderTestRepos) {
+	return newAllocatingOrderService(PriorityAllocation{})
+}
+
+func newAllocatingOrderService(allocation AllocationStrategy) (OrderService, orderTestRepos) {
+	repos := orderTestRepos{new(MockOrderRepo), new(MockCartRepo), new(MockProductRepo), new(MockVariantRepo), new(MockInventoryRepo), new(MockPromotionRepo),
+		new(MockTaxRepo), new(MockAddressRepo), new(MockPaymentRepo), NewFakeGateway()}
+	warehouseRepo := new(MockWarehouseRepo)
+	warehouseRepo.On("GetAll").Return(testWarehouses, nil)
+	tx := inlineTx{repository.Repos{
+		Orders:     repos.orders,
+		Carts:      repos.carts,
+		Products:   repos.products,
+		Variants:   repos.variants,
+		Inventory:  repos.inventory,
+		Warehouses: warehouseRepo,
+		Promotions: repos.promotions,
+		Addresses:  repos.addresses,
+		Payments:   repos.payments,
+	}}
+	productService := NewProductService(repos.products, repos.variants, new(MockCategoryRepo), tx, "USD")
+	taxCalculator := NewLocalTaxCalculator(repos.taxes, models.TaxExclusive, "", models.RoundHalfUp)
+	return NewOrderService(repos.orders, productService, taxCalculator, repos.gateway, tx, allocation), repos
+}
+
+func TestCheckout(t *testing.T) {
+	variantID := 4
+
+	t.Run("Success", func(t *testing.T) {
+		orderService, repos := newTestOrderService()
+		repos.carts.On("GetByUser", 7).Return(&models.Cart{ID: 3, UserID: 7, Currency: "USD"}, nil)
+		repos.carts.On("Touch", 3, (*time.Time)(nil)).Return(nil)
+		repos.carts.On("ListItems", 3).Return([]models.CartItem{
+			{ID: 1, CartID: 3, ProductID: 1, Quantity: 2},
+			{ID: 2, CartID: 3, ProductID: 2, VariantID: &variantID, Quantity: 1},
+		}, nil)
+		repos.products.On("GetByID", 1).Return(&models.Product{ID: 1, Name: "Mug", Price: usd(950)}, nil)
+		repos.products.On("GetByID", 2).Return(&models.Product{ID: 2, Name: "Shirt", Price: usd(2000)}, nil)
+		repos.variants.On("ListByProducts", []int{1}).Return([]models.Variant(nil), nil)
+		repos.variants.On("ListByProducts", []int{2}).Return([]models.Variant{{ID: 4, ProductID: 2, SKU: "SHIRT-M", Price: usdPtr(2500)}}, nil)
+		repos.promotions.On("ListApplicable", []string(nil)).Return([]models.Promotion(nil), nil)
+		repos.orders.On("Create", mock.MatchedBy(func(order *models.Order) bool {
+			return order.UserID == 7 && order.Status == models.OrderPending && order.Subtotal == usd(4400)
+		})).Return(nil).Once()
+		repos.orders.On("AddItem", mock.AnythingOfType("*models.OrderItem")).Return(nil).Twice()
+		repos.inventory.On("ListAllocatable", 1, (*int)(nil)).Return([]models.InventoryItem{{ID: 10, WarehouseID: 1, OnHand: 5}}, nil)
+		repos.inventory.On("ListAllocatable", 2, &variantID).Return([]models.InventoryItem{{ID: 11, WarehouseID: 1, OnHand: 5}}, nil)
+		repos.inventory.On("Reserve", mock.Anything, mock.Anything).Return(nil)
+		repos.inventory.On("CreateReservation", mock.MatchedBy(func(reservation *models.Reservation) bool {
+			return reservation.Reference == "order-40"
+		})).Return(nil).Twice()
+		repos.orders.On("AddStatusChange", &models.OrderStatusChange{OrderID: 40, To: models.OrderPending, ChangedBy: 7}).Return(nil).Once()
+		repos.carts.On("ClearItems", 3).Return(nil).Once()
+
+		order, err := orderService.Checkout(context.Background(), models.CheckoutRequest{UserID: 7})
+		assert.NoError(t, err)
+		assert.Equal(t, 40, order.ID)
+		assert.Equal(t, "SHIRT-M", order.Items[1].SKU)
+		assert.Equal(t, usd(2500), order.Items[1].UnitPrice)
+		assert.Equal(t, 40, order.Items[0].OrderID)
+		repos.orders.AssertExpectations(t)
+		repos.carts.AssertExpectations(t)
+	})
+	t.Run("Coupon", func(t *testing.T) {
+		orderService, repos := newTestOrderService()
+		repos.carts.On("GetByUser", 7).Return(&models.Cart{ID: 3, UserID: 7, Currency: "USD"}, nil)
+		repos.carts.On("Touch", 3, (*time.Time)(nil)).Return(nil)
+		repos.carts.On("ListItems", 3).Return([]models.CartItem{{ID: 1, CartID: 3, ProductID: 1, Quantity: 2}}, nil)
+		repos.products.On("GetByID", 1).Return(&models.Product{ID: 1, Name: "Mug", Price: usd(950)}, nil)
+		repos.variants.On("ListByProducts", []int{1}).Return([]models.Variant(nil), nil)
+		repos.promotions.On("ListApplicable", []string{"TENOFF"}).Return([]models.Promotion{
+			{ID: 3, Name: "10% off", Code: "TENOFF", Kind: models.PromotionPercentage, Percent: 10, MinSubtotal: usd(0), PerCustomerLimit: 1, Active: true},
+		}, nil)
+		repos.promotions.On("CountRedemptions", 7, []int{3}).Return(map[int]int{}, nil)
+		repos.orders.On("Create", mock.MatchedBy(func(order *models.Order) bool {
+			total, err := order.Total()
+			return err == nil && order.Discount == usd(190) && total == usd(1710)
+		})).Return(nil).Once()
+		repos.orders.On("AddItem", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			args.Get(0).(*models.OrderItem).ID = 80
+		})
+		repos.orders.On("AddDiscount", mock.MatchedBy(func(discount *models.OrderDiscount) bool {
+			return *discount.OrderItemID == 80 && *discount.PromotionID == 3 && discount.Amount == usd(190)
+		})).Return(nil).Once()
+		repos.promotions.On("Redeem", &models.PromotionRedemption{PromotionID: 3, OrderID: 40, UserID: 7}).Return(nil).Once()
+		repos.inventory.On("ListAllocatable", 1, (*int)(nil)).Return([]models.InventoryItem{{ID: 10, WarehouseID: 1, OnHand: 5}}, nil)
+		repos.inventory.On("Reserve", 10, 2).Return(nil)
+		repos.inventory.On("CreateReservation", mock.Anything).Return(nil)
+		repos.orders.On("AddStatusChange", mock.Anything).Return(nil)
+		repos.carts.On("ClearItems", 3).Return(nil)
+
+		order, err := orderService.Checkout(context.Background(), models.CheckoutRequest{UserID: 7, CouponCodes: []string{" tenoff"}})
+		assert.NoError(t, err)
+		assert.Len(t, order.Discounts, 1)
+		repos.orders.AssertExpectations(t)
+		repos.promotions.AssertExpectations(t)
+	})
+	t.Run("Taxed after the discount", func(t *testing.T) {
+		orderService, repos := newTestOrderService()
+		repos.carts.On("GetByUser", 7).Return(&models.Cart{ID: 3, UserID: 7, Currency: "USD"}, nil)
+		repos.carts.On("Touch", 3, (*time.Time)(nil)).Return(nil)
+		repos.carts.On("ListItems", 3).Return([]models.CartItem{
+			{ID: 1, CartID: 3, ProductID: 1, Quantity: 2},
+			{ID: 2, CartID: 3, ProductID: 3, Quantity: 1},
+		}, nil)
+		repos.products.On("GetByID", 1).Return(&models.Product{ID: 1, Name: "Mug", Price: usd(950), TaxClass: "standard"}, nil)
+		repos.products.On("GetByID", 3).Return(&models.Product{ID: 3, Name: "Book", Price: usd(1000), TaxClass: "zero"}, nil)
+		repos.variants.On("ListByProducts", mock.Anything).Return([]models.Variant(nil), nil)
+		repos.promotions.On("ListApplicable", []string(nil)).Return([]models.Promotion{
+			{ID: 3, Name: "Mugs", Kind: models.PromotionFixed, Amount: usd(400), ProductIDs: []int{1}, MinSubtotal: usd(0), Active: true},
+		}, nil)
+		repos.taxes.On("ListRates", models.TaxRateFilter{Country: "US"}).Return([]models.TaxRate{
+			{ID: 1, TaxClass: "standard", Country: "US", Region: "CA", Rate: 72500, Name: "CA sales tax"},
+		}, nil).Once()
+		repos.orders.On("Create", mock.MatchedBy(func(order *models.Order) bool {
+			// 7.25% of 19.00 less the 4.00 off, the zero rated book isn't taxed
+			total, err := order.Total()
+			return err == nil && order.Tax == usd(109) && !order.TaxInclusive && total == usd(2609)
+		})).Return(nil).Once()
+		repos.orders.On("AddItem", mock.Anything).Return(nil).Twice()
+		repos.orders.On("AddDiscount", mock.Anything).Return(nil)
+		repos.promotions.On("Redeem", mock.Anything).Return(nil)
+		repos.inventory.On("ListAllocatable", mock.Anything, (*int)(nil)).Return([]models.InventoryItem{{ID: 10, WarehouseID: 1, OnHand: 5}}, nil)
+		repos.inventory.On("Reserve", mock.Anything, mock.Anything).Return(nil)
+		repos.inventory.On("CreateReservation", mock.Anything).Return(nil)
+		repos.orders.On("AddStatusChange", mock.Anything).Return(nil)
+		repos.carts.On("ClearItems", 3).Return(nil)
+
+		order, err := orderService.Checkout(context.Background(), models.CheckoutRequest{UserID: 7,
+			Destination: models.Destination{Country: "us", Region: "ca", Postcode: "94105"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "CA sales tax", order.Items[0].TaxName)
+		assert.Equal(t, usd(109), order.Items[0].Tax)
+		assert.Equal(t, "zero", order.Items[1].TaxClass)
+		assert.True(t, order.Items[1].Tax.IsZero())
+		repos.orders.AssertExpectations(t)
+		repos.taxes.AssertExpectations(t)
+	})
+	t.Run("Saved addresses", func(t *testing.T) {
+		orderService, repos := newTestOrderService()
+		repos.carts.On("GetByUser", 7).Return(&models.Cart{ID: 3, UserID: 7, Currency: "USD"}, nil)
+		repos.carts.On("Touch", 3, (*time.Time)(nil)).Return(nil)
+		repos.carts.On("ListItems", 3).Return([]models.CartItem{{ID: 1, CartID: 3, ProductID: 1, Quantity: 1}}, nil)
+		repos.products.On("GetByID", 1).Return(&models.Product{ID: 1, Name: "Mug", Price: usd(1000), TaxClass: "standard"}, nil)
+		repos.variants.On("ListByProducts", mock.Anything).Return([]models.Variant(nil), nil)
+		repos.promotions.On("ListApplicable", []string(nil)).Return([]models.Promotion(nil), nil)
+		shipping := models.PostalAddress{Name: "Grace Hopper", Line1: "350 Fifth Avenue", City: "New York", Region: "NY", Postcode: "10118", Country: "US"}
+		repos.addresses.On("GetByID", 7, 5).Return(&models.Address{ID: 5, UserID: 7, PostalAddress: shipping}, nil)
+		repos.addresses.On("GetByID", 7, 6).Return(&models.Address{ID: 6, UserID: 7, PostalAddress: models.PostalAddress{Name: "Grace Hopper",
+			Company: "Navy", Line1: "1000 Navy Pentagon", City: "Washington", Region: "DC", Postcode: "20350", Country: "US"}}, nil)
+		repos.taxes.On("ListRates", models.TaxRateFilter{Country: "US"}).Return([]models.TaxRate{
+			{ID: 1, TaxClass: "standard", Country: "US", Region: "NY", Rate: 40000, Name: "NY sales tax"},
+			{ID: 2, TaxClass: "standard", Country: "US", Region: "DC", Rate: 60000, Name: "DC sales tax"},
+		}, nil).Once()
+		repos.orders.On("Create", mock.MatchedBy(func(order *models.Order) bool {
+			// taxed where it ships, not where it is billed or the destination the request gives
+			return order.Tax == usd(40)
+		})).Return(nil).Once()
+		repos.orders.On("AddItem", mock.Anything).Return(nil).Once()
+		repos.orders.On("AddAddress", mock.MatchedBy(func(address *models.OrderAddress) bool {
+			return address.OrderID == 40 && address.Kind == models.AddressShipping && *address.AddressID == 5 && address.PostalAddress == shipping
+		})).Return(nil).Once()
+		repos.orders.On("AddAddress", mock.MatchedBy(func(address *models.OrderAddress) bool {
+			return address.Kind == models.AddressBilling && address.Company == "Navy"
+		})).Return(nil).Once()
+		repos.inventory.On("ListAllocatable", mock.Anything, (*int)(nil)).Return([]models.InventoryItem{{ID: 10, WarehouseID: 1, OnHand: 5}}, nil)
+		repos.inventory.On("Reserve", mock.Anything, mock.Anything).Return(nil)
+		repos.inventory.On("CreateReservation", mock.Anything).Return(nil)
+		repos.orders.On("AddStatusChange", mock.Anything).Return(nil)
+		repos.carts.On("ClearItems", 3).Return(nil)
+
+		order, err := orderService.Checkout(context.Background(), models.CheckoutRequest{UserID: 7, ShippingAddressID: 5, BillingAddressID: 6,
+			Destination: models.Destination{Country: "US", Region: "DC"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "New York", order.Address(models.AddressShipping).City)
+		assert.Equal(t, 6, *order.Address(models.AddressBilling).AddressID)
+		repos.orders.AssertExpectations(t)
+	})
+	t.Run("Someone else's address", func(t *testing.T) {
+		orderService, repos := newTestOrderService()
+		repos.carts.On("GetByUser", 7).Return(&models.Cart{ID: 3, UserID: 7, Currency: "USD"}, nil)
+		repos.carts.On("Touch", 3, (*time.Time)(nil)).Return(nil)
+		repos.carts.On("ListItems", 3).Return([]models.CartItem{{ID: 1, CartID: 3, ProductID: 1, Quantity: 1}}, nil)
+		repos.addresses.On("GetByID", 7, 9).Return(nil, ErrAddressNotFound)
+
+		_, err := orderService.Checkout(context.Background(), models.CheckoutRequest{UserID: 7, ShippingAddressID: 9})
+		assert.ErrorIs(t, err, ErrInvalidAddress)
+		repos.orders.AssertNotCalled(t, "Create", mock.Anything)
+	})
+	t.Run("Coupon used up", func(t *testing.T) {
+		orderService, repos := newTestOrderService()
+		repos.carts.On("GetByUser", 7).Return(&models.Cart{ID: 3, UserID: 7, Currency: "USD"}, nil)
+		repos.carts.On("Touch", 3, (*time.Time)(nil)).Return(nil)
+		repos.carts.On("ListItems", 3).Return([]models.CartItem{{ID: 1, CartID: 3, ProductID: 1, Quantity: 2}}, nil)
+		repos.products.On("GetByID", 1).Return(&models.Product{ID: 1, Name: "Mug", Price: usd(950)}, nil)
+		repos.variants.On("ListByProducts", []int{1}).Return([]models.Variant(nil), nil)
+		repos.promotions.On("ListApplicable", []string{"TENOFF"}).Return([]models.Promotion{
+			{ID: 3, Name: "10% off", Code: "TENOFF", Kind: models.PromotionPercentage, Percent: 10, MinSubtotal: usd(0), UsageLimit: 1, Active: true},
+		}, nil)
+		repos.orders.On("Create", mock.Anything).Return(nil)
+		repos.orders.On("AddItem", mock.Anything).Return(nil)
+		repos.orders.On("AddDiscount", mock.Anything).Return(nil)
+		repos.promotions.On("Redeem", mock.Anything).Return(ErrPromotionExhausted)
+
+		_, err := orderService.Checkout(context.Background(), models.CheckoutRequest{UserID: 7, CouponCodes: []string{"TENOFF"}})
+		assert.ErrorIs(t, err, ErrPromotionExhausted)
+		repos.carts.AssertNotCalled(t, "ClearItems", 3)
+	})
+	t.Run("Empty cart", func(t *testing.T) {
+		orderService, repos := newTestOrderService()
+		repos.carts.On("GetByUser", 7).Return(nil, ErrCartNotFound)
+
+		_, err := orderService.Checkout(context.Background(), models.CheckoutRequest{UserID: 7})
+		assert.ErrorIs(t, err, ErrEmptyCart)
+	})
+	t.Run("Out of stock", func(t *testing.T) {
+		orderService, repos := newTestOrderService()
+		repos.carts.On("GetByUser", 7).Return(&models.Cart{ID: 3, UserID: 7, Currency: "USD"}, nil)
+		repos.carts.On("Touch", 3, (*time.Time)(nil)).Return(nil)
+		repos.carts.On("ListItems", 3).Return([]models.CartItem{{ID: 1, CartID: 3, ProductID: 1, Quantity: 2}}, nil)
+		repos.products.On("GetByID", 1).Return(&models.Product{ID: 1, Name: "Mug", Price: usd(950)}, nil)
+		repos.variants.On("ListByProducts", []int{1}).Return([]models.Variant(nil), nil)
+		repos.promotions.On("ListApplicable", []string(nil)).Return([]models.Promotion(nil), nil)
+		repos.orders.On("Create", mock.Anything).Return(nil)
+		repos.orders.On("AddItem", mock.Anything).Return(nil)
+		repos.inventory.On("ListAllocatable", 1, (*int)(nil)).Return([]models.InventoryItem{{ID: 10, WarehouseID: 1, OnHand: 1}}, nil)
+		repos.inventory.On("Reserve", 10, 1).Return(nil)
+		repos.inventory.On("CreateReservation", mock.Anything).Return(nil)
+
+		_, err := orderService.Checkout(context.Background(), models.CheckoutRequest{UserID: 7})
+		assert.ErrorIs(t, err, ErrInsufficientStock)
+		repos.carts.AssertNotCalled(t, "ClearItems", 3)
+	})
+	t.Run("Closest warehouse", func(t *testing.T) {
+		orderService, repos := newAllocatingOrderService(ClosestAllocation{})
+		repos.carts.On("GetByUser", 7).Return(&models.Cart{ID: 3, UserID: 7, Currency: "USD"}, nil)
+		repos.carts.On("Touch", 3, (*time.Time)(nil)).Return(nil)
+		repos.carts.On("ListItems", 3).Return([]models.CartItem{{ID: 1, CartID: 3, ProductID: 1, Quantity: 2}}, nil)
+		repos.products.On("GetByID", 1).Return(&models.Product{ID: 1, Name: "Mug", Price: usd(950)}, nil)
+		repos.variants.On("ListByProducts", []int{1}).Return([]models.Variant(nil), nil)
+		repos.promotions.On("ListApplicable", []string(nil)).Return([]models.Promotion(nil), nil)
+		repos.taxes.On("ListRates", models.TaxRateFilter{Country: "DE"}).Return([]models.TaxRate(nil), nil)
+		repos.orders.On("Create", mock.Anything).Return(nil)
+		repos.orders.On("AddItem", mock.Anything).Return(nil)
+		// Madrid has the higher priority, but Berlin is nearer to Germany
+		repos.inventory.On("ListAllocatable", 1, (*int)(nil)).Return([]models.InventoryItem{
+			{ID: 10, WarehouseID: 1, OnHand: 5},
+			{ID: 11, WarehouseID: 2, OnHand: 5},
+		}, nil)
+		repos.inventory.On("Reserve", 10, 2).Return(nil).Once()
+		repos.inventory.On("CreateReservation", mock.MatchedBy(func(reservation *models.Reservation) bool {
+			return reservation.ItemID == 10 && reservation.Quantity == 2
+		})).Return(nil).Once()
+		repos.orders.On("AddStatusChange", mock.Anything).Return(nil)
+		repos.carts.On("ClearItems", 3).Return(nil)
+
+		_, err := orderService.Checkout(context.Background(), models.CheckoutRequest{UserID: 7, Destination: models.Destination{Country: "de"}})
+		assert.NoError(t, err)
+		repos.inventory.AssertExpectations(t)
+		repos.inventory.AssertNotCalled(t, "Reserve", 11, mock.Anything)
+	})
+}
+
+func TestTransitionOrder(t *testing.T) {
+	reservations := []models.Reservation{{ID: 1, Reference: "order-40", ItemID: 10, Quantity: 2}}
+
+	t.Run("Cancel releases the stock and promotions and voids the authorization", func(t *testing.T) {
+		orderService, repos := newTestOrderService()
+		authorized, _ := repos.gateway.Authorize(context.Background(), PaymentRequest{OrderID: 40, Amount: usd(1900), MethodToken: "tok_visa"})
+		payment := models.Payment{ID: 50, OrderID: 40, Reference: authorized.Reference, Status: models.PaymentAuthorized, Amount: usd(1900), RefundedAmount: usd(0)}
+		repos.payments.On("ListByOrder", 40).Return([]models.Payment{{ID: 49, OrderID: 40, Status: models.PaymentFailed}, payment}, nil)
+		repos.payments.On("Lock", 50).Return(&payment, nil)
+		repos.payments.On("Update", mock.MatchedBy(func(payment *models.Payment) bool {
+			return payment.ID == 50 && payment.Status == models.PaymentVoided
+		})).Return(nil).Once()
+		repos.orders.On("GetByID", 40).Return(&models.Order{ID: 40, UserID: 7, Status: models.OrderPending}, nil)
+		repos.inventory.On("ActiveReservations", "order-40").Return(reservations, nil).Once()
+		repos.inventory.On("Release", 10, 2).Return(nil).Once()
+		repos.inventory.On("SetReservationStatus", 1, models.ReservationReleased).Return(nil).Once()
+		repos.promotions.On("ReleaseRedemptions", 40).Return(nil).Once()
+		repos.orders.On("UpdateStatus", 40, models.OrderPending, models.OrderCancelled).Return(nil).Once()
+		repos.orders.On("AddStatusChange", &models.OrderStatusChange{OrderID: 40, From: models.OrderPending, To: models.OrderCancelled, ChangedBy: 7}).Return(nil).Once()
+		repos.orders.On("ListItems", []int{40}).Return([]models.OrderItem(nil), nil)
+		repos.orders.On("ListDiscounts", 40).Return([]models.OrderDiscount(nil), nil)
+		repos.orders.On("ListAddresses", 40).Return([]models.OrderAddress(nil), nil)
+
+		_, err := orderService.CancelOrder(context.Background(), 7, 40)
+		assert.NoError(t, err)
+		repos.inventory.AssertExpectations(t)
+		repos.orders.AssertExpectations(t)
+		repos.payments.AssertExpectations(t)
+		repos.promotions.AssertExpectations(t)
+	})
+	t.Run("Fulfilment commits the stock", func(t *testing.T) {
+		orderService, repos := newTestOrderService()
+		repos.orders.On("GetByID", 40).Return(&models.Order{ID: 40, UserID: 7, Status: models.OrderPaid}, nil)
+		repos.inventory.On("ActiveReservations", "order-40").Return(reservations, nil).Once()
+		repos.inventory.On("Commit", 10, 2).Return(&models.InventoryItem{ID: 10, OnHand: 3}, nil).Once()
+		repos.inventory.On("SetReservationStatus", 1, models.ReservationCommitted).Return(nil).Once()
+		repos.inventory.On("AddAdjustment", mock.Anything).Return(nil).Once()
+		repos.orders.On("UpdateStatus", 40, models.OrderPaid, models.OrderFulfilled).Return(nil).Once()
+		repos.orders.On("AddStatusChange", mock.Anything).Return(nil).Once()
+		repos.orders.On("ListItems", []int{40}).Return([]models.OrderItem(nil), nil)
+		repos.orders.On("ListDiscounts", 40).Return([]models.OrderDiscount(nil), nil)
+		repos.orders.On("ListAddresses", 40).Return([]models.OrderAddress(nil), nil)
+
+		_, err := orderService.TransitionOrder(context.Background(), &models.OrderStatusChange{OrderID: 40, To: models.OrderFulfilled, ChangedBy: 2})
+		assert.NoError(t, err)
+		repos.inventory.AssertExpectations(t)
+	})
+	t.Run("Shipping leaves the stock alone", func(t *testing.T) {
+		orderService, repos := newTestOrderService()
+		repos.orders.On("GetByID", 40).Return(&models.Order{ID: 40, Status: models.OrderFulfilled}, nil)
+		repos.orders.On("UpdateStatus", 40, models.OrderFulfilled, models.OrderShipped).Return(nil).Once()
+		repos.orders.On("AddStatusChange", mock.Anything).Return(nil).Once()
+		repos.orders.On("ListItems", []int{40}).Return([]models.OrderItem(nil), nil)
+		repos.orders.On("ListDiscounts", 40).Return([]models.OrderDiscount(nil), nil)
+		repos.orders.On("ListAddresses", 40).Return([]models.OrderAddress(nil), nil)
+
+		order, err := orderService.TransitionOrder(context.Background(), &models.OrderStatusChange{OrderID: 40, To: models.OrderShipped})
+		assert.NoError(t, err)
+		assert.Equal(t, models.OrderFulfilled, order.Status, "the mock returns the stored order")
+		repos.inventory.AssertNotCalled(t, "ActiveReservations", mock.Anything)
+	})
+	t.Run("Guarded", func(t *testing.T) {
+		orderService, repos := newTestOrderService()
+		repos.orders.On("GetByID", 40).Return(&models.Order{ID: 40, Status: models.OrderPending}, nil)
+
+		_, err := orderService.TransitionOrder(context.Background(), &models.OrderStatusChange{OrderID: 40, To: models.OrderShipped})
+		assert.ErrorIs(t, err, ErrInvalidTransition)
+		_, err = orderService.TransitionOrder(context.Background(), &models.OrderStatusChange{OrderID: 40, To: "lost"})
+		assert.ErrorIs(t, err, ErrInvalidOrderStatus)
+	})
+	t.Run("Customers only cancel their own orders", func(t *testing.T) {
+		orderService, repos := newTestOrderService()
+		repos.orders.On("GetByID", 40).Return(&models.Order{ID: 40, UserID: 8, Status: models.OrderPending}, nil)
+		repos.orders.On("ListItems", []int{40}).Return([]models.OrderItem(nil), nil)
+		repos.orders.On("ListDiscounts", 40).Return([]models.OrderDiscount(nil), nil)
+		repos.orders.On("ListAddresses", 40).Return([]models.OrderAddress(nil), nil)
+
+		_, err := orderService.CancelOrder(context.Background(), 7, 40)
+		assert.ErrorIs(t, err, ErrOrderNotFound)
+	})
+}
+
+func TestOrderStateMachine(t *testing.T) {
+	assert.True(t, models.OrderPending.CanTransitionTo(models.OrderPaid))
+	assert.True(t, models.OrderDelivered.CanTransitionTo(models.OrderRefunded))
+	assert.False(t, models.OrderPaid.CanTransitionTo(models.OrderPending))
+	assert.False(t, models.OrderShipped.CanTransitionTo(models.OrderCancelled))
+	assert.False(t, models.OrderRefunded.CanTransitionTo(models.OrderPaid))
+}
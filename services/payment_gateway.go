@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Names of the payment gateways, as used in the configuration
+const GatewayFake = "fake"
+
+var ErrGatewayRejected = errors.New("rejected by payment gateway")
+
+// PaymentRequest asks a gateway to hold Amount for an order. MethodToken is
+// the card or wallet token the client got from the gateway.
+type PaymentRequest struct {
+	OrderID     int
+	Amount      models.Money
+	MethodToken string
+}
+
+// GatewayResult is the gateway's view of a payment after a call. A capture
+// that is still authorized is confirmed later by a webhook event.
+type GatewayResult struct {
+	Reference     string
+	Status        models.PaymentStatus
+	FailureReason string
+	Refunded      models.Money // total refunded so far, set by Refund
+}
+
+// PaymentGateway is a payment provider. Declines are results with the failed
+// status, errors mean the call itself went wrong. A refund repeated with the
+// same idempotency key is only made once, the repeat returns its result.
+type PaymentGateway interface {
+	Name() string
+	Authorize(ctx context.Context, request PaymentRequest) (GatewayResult, error)
+	Capture(ctx context.Context, reference string, amount models.Money) (GatewayResult, error)
+	Void(ctx context.Context, reference string) (GatewayResult, error)
+	Refund(ctx context.Context, reference string, amount models.Money, idempotencyKey string) (GatewayResult, error)
+}
+
+// NewPaymentGateway returns the gateway registered under name
+func NewPaymentGateway(name string) (PaymentGateway, error) {
+	switch name {
+	case GatewayFake, "":
+		return NewFakeGateway(), nil
+	}
+	return nil, fmt.Errorf("unknown payment gateway %q, expected %s", name, GatewayFake)
+}
+
+// Method tokens the fake gateway treats specially, any other token is approved
+const (
+	FakeTokenDeclined = "tok_declined" // authorization is declined
+	FakeTokenAsync    = "tok_async"    // capture stays pending until a payment.captured webhook
+)
+
+// FakeGateway is an in-process gateway for development and tests. It is
+// deterministic: references are numbered in call order and the outcome only
+// depends on the method token. Its state lives in memory, so payments made
+// before a restart can no longer be refunded through it.
+type FakeGateway struct {
+	mu       sync.Mutex
+	next     int
+	payments map[string]*fakePayment
+	refunds  map[string]GatewayResult // by idempotency key
+}
+
+type fakePayment struct {
+	amount   models.Money
+	captured models.Money
+	refunded models.Money
+	async    bool
+	status   models.PaymentStatus
+}
+
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{payments: make(map[string]*fakePayment), refunds: make(map[string]GatewayResult)}
+}
+
+func (g *FakeGateway) Name() string {
+	return GatewayFake
+}
+
+func (g *FakeGateway) Authorize(ctx context.Context, request PaymentRequest) (GatewayResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.next++
+	reference := fmt.Sprintf("fake_%d_%d", request.OrderID, g.next)
+	payment := &fakePayment{
+		amount:   request.Amount,
+		captured: models.Money{Currency: request.Amount.Currency},
+		refunded: models.Money{Currency: request.Amount.Currency},
+		async:    request.MethodToken == FakeTokenAsync,
+		status:   models.PaymentAuthorized,
+	}
+	g.payments[reference] = payment
+	if request.MethodToken == FakeTokenDeclined {
+		payment.status = models.PaymentFailed
+		return GatewayResult{Reference: reference, Status: models.PaymentFailed, FailureReason: "card declined"}, nil
+	}
+	return GatewayResult{Reference: reference, Status: models.PaymentAuthorized}, nil
+}
+
+func (g *FakeGateway) Capture(ctx context.Context, reference string, amount models.Money) (GatewayResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	payment, err := g.payment(reference, models.PaymentAuthorized)
+	if err != nil {
+		return GatewayResult{}, err
+	}
+	if cmp, err := amount.Cmp(payment.amount); err != nil || cmp > 0 {
+		return GatewayResult{}, fmt.Errorf("%w: capture of %s exceeds the authorized %s", ErrGatewayRejected, amount, payment.amount)
+	}
+	payment.captured = amount
+	if payment.async {
+		return GatewayResult{Reference: reference, Status: models.PaymentAuthorized}, nil
+	}
+	payment.status = models.PaymentCaptured
+	return GatewayResult{Reference: reference, Status: models.PaymentCaptured}, nil
+}
+
+func (g *FakeGateway) Void(ctx context.Context, reference string) (GatewayResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	payment, err := g.payment(reference, models.PaymentAuthorized)
+	if err != nil {
+		return GatewayResult{}, err
+	}
+	payment.status = models.PaymentVoided
+	return GatewayResult{Reference: reference, Status: models.PaymentVoided}, nil
+}
+
+func (g *FakeGateway) Refund(ctx context.Context, reference string, amount models.Money, idempotencyKey string) (GatewayResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if result, ok := g.refunds[idempotencyKey]; ok {
+		return result, nil
+	}
+	payment, ok := g.payments[reference]
+	if !ok {
+		return GatewayResult{}, fmt.Errorf("%w: unknown payment %s", ErrGatewayRejected, reference)
+	}
+	left, err := payment.captured.Sub(payment.refunded)
+	if err != nil {
+		return GatewayResult{}, fmt.Errorf("%w: %v", ErrGatewayRejected, err)
+	}
+	if cmp, err := amount.Cmp(left); err != nil || !amount.IsPositive() || cmp > 0 {
+		return GatewayResult{}, fmt.Errorf("%w: refund of %s exceeds the %s left", ErrGatewayRejected, amount, left)
+	}
+	if payment.refunded, err = payment.refunded.Add(amount); err != nil {
+		return GatewayResult{}, fmt.Errorf("%w: %v", ErrGatewayRejected, err)
+	}
+	payment.status = models.PaymentPartiallyRefunded
+	if payment.refunded == payment.captured {
+		payment.status = models.PaymentRefunded
+	}
+	result := GatewayResult{Reference: reference, Status: payment.status, Refunded: payment.refunded}
+	g.refunds[idempotencyKey] = result
+	return result, nil
+}
+
+func (g *FakeGateway) payment(reference string, status models.PaymentStatus) (*fakePayment, error) {
+	payment, ok := g.payments[reference]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown payment %s", ErrGatewayRejected, reference)
+	}
+	if payment.status != status {
+		return nil, fmt.Errorf("%w: payment %s is %s", ErrGatewayRejected, reference, payment.status)
+	}
+	return payment, nil
+}
@@ -0,0 +1,424 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"ecommerce/utils"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// pendingPaymentTimeout is how long a pending payment holds off other
+// attempts to pay the order. One left pending longer was abandoned, e.g. by
+// a crash during the gateway call, and the authorization it may have got
+// lapses at the gateway.
+const pendingPaymentTimeout = 10 * time.Minute
+
+var (
+	ErrPaymentNotFound     = repository.ErrPaymentNotFound
+	ErrPaymentDeclined     = errors.New("payment declined")
+	ErrInvalidPayment      = errors.New("invalid payment")
+	ErrInvalidPaymentEvent = errors.New("invalid payment event")
+)
+
+// PaymentService takes payments for orders through the configured gateway
+// and keeps payments and orders in step with the gateway's webhook events
+type PaymentService interface {
+	PayOrder(ctx context.Context, userID, orderID int, methodToken string) (*models.Payment, error)
+	GetOrderPayments(ctx context.Context, orderID int) ([]models.Payment, error)
+	VoidPayment(ctx context.Context, paymentID, actorID int) (*models.Payment, error)
+	RefundPayment(ctx context.Context, paymentID int, amount models.Money, actorID int) (*models.Payment, error)
+	HandleEvent(ctx context.Context, event *models.PaymentEvent) error
+}
+
+type paymentService struct {
+	paymentRepo repository.PaymentRepo
+	orderRepo   repository.OrderRepo
+	gateway     PaymentGateway
+	txManager   repository.TxManager
+}
+
+func NewPaymentService(paymentRepo repository.PaymentRepo, orderRepo repository.OrderRepo, gateway PaymentGateway, txManager repository.TxManager) PaymentService {
+	return &paymentService{paymentRepo: paymentRepo, orderRepo: orderRepo, gateway: gateway, txManager: txManager}
+}
+
+// PayOrder authorizes and captures the total of a pending order of the
+// user. A capture the gateway confirms right away marks the order paid,
+// otherwise that happens when the payment.captured event arrives. The
+// payment is recorded as pending with the order locked before the gateway
+// is called, so of two concurrent attempts only one goes through.
+func (s *paymentService) PayOrder(ctx context.Context, userID, orderID int, methodToken string) (*models.Payment, error) {
+	if methodToken == "" {
+		return nil, fmt.Errorf("%w: payment token is required", ErrInvalidPayment)
+	}
+	var payment *models.Payment
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		order, err := tx.Orders.Lock(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		if order.UserID != userID {
+			return ErrOrderNotFound
+		}
+		if order.Status != models.OrderPending {
+			return fmt.Errorf("%w: the order is %s", ErrInvalidPayment, order.Status)
+		}
+		payments, err := tx.Payments.ListByOrder(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		for _, payment := range payments {
+			switch {
+			case payment.Status == models.PaymentPending && time.Since(payment.CreatedAt) < pendingPaymentTimeout:
+				return fmt.Errorf("%w: payment %d is in progress", ErrInvalidPayment, payment.ID)
+			case payment.Status == models.PaymentAuthorized || payment.Status == models.PaymentCaptured:
+				return fmt.Errorf("%w: payment %d is already %s", ErrInvalidPayment, payment.ID, payment.Status)
+			}
+		}
+
+		// the customer pays what is left after the order's discounts
+		amount, err := order.Total()
+		if err != nil {
+			return err
+		}
+		payment = &models.Payment{
+			OrderID:        order.ID,
+			Provider:       s.gateway.Name(),
+			Status:         models.PaymentPending,
+			Amount:         amount,
+			RefundedAmount: models.Money{Currency: amount.Currency},
+		}
+		return tx.Payments.Create(ctx, payment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	request := PaymentRequest{OrderID: payment.OrderID, Amount: payment.Amount, MethodToken: methodToken}
+	result, err := s.gateway.Authorize(ctx, request)
+	if err != nil {
+		payment.Status, payment.FailureReason = models.PaymentFailed, err.Error()
+		if err := s.paymentRepo.Update(ctx, payment); err != nil {
+			log.Printf("failed to mark payment %d failed: %v", payment.ID, err)
+		}
+		return nil, err
+	}
+	payment.Reference, payment.Status, payment.FailureReason = result.Reference, result.Status, result.FailureReason
+	if err := s.paymentRepo.Update(ctx, payment); err != nil {
+		return nil, err
+	}
+	if payment.Status == models.PaymentFailed {
+		return payment, fmt.Errorf("%w: %s", ErrPaymentDeclined, payment.FailureReason)
+	}
+
+	// an authorization that fails to capture stays on record, staff can void it
+	result, err = s.gateway.Capture(ctx, payment.Reference, payment.Amount)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != models.PaymentCaptured {
+		return payment, nil
+	}
+	err = s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		if payment, err = tx.Payments.Lock(ctx, payment.ID); err != nil {
+			return err
+		}
+		return applyCapture(ctx, tx, payment, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+func (s *paymentService) GetOrderPayments(ctx context.Context, orderID int) ([]models.Payment, error) {
+	if _, err := s.orderRepo.GetByID(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return s.paymentRepo.ListByOrder(ctx, orderID)
+}
+
+// VoidPayment releases an authorization that was never captured
+func (s *paymentService) VoidPayment(ctx context.Context, paymentID, actorID int) (*models.Payment, error) {
+	payment, err := s.paymentRepo.GetByID(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != models.PaymentAuthorized {
+		return nil, fmt.Errorf("%w: a %s payment can't be voided", ErrInvalidPayment, payment.Status)
+	}
+	return voidPayment(ctx, s.txManager, s.gateway, payment)
+}
+
+// RefundPayment gives amount back, 0 refunds whatever is left. Refunding
+// everything also moves the order to refunded. Refunds an earlier call left
+// pending are sent again first, their keys keep them from being made twice.
+func (s *paymentService) RefundPayment(ctx context.Context, paymentID int, amount models.Money, actorID int) (*models.Payment, error) {
+	if amount.IsNegative() {
+		return nil, fmt.Errorf("%w: refund amount must not be negative", ErrInvalidPayment)
+	}
+	if err := s.resendPendingRefunds(ctx, paymentID, actorID); err != nil {
+		return nil, err
+	}
+
+	var reference string
+	var refund *models.Refund
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		payment, err := tx.Payments.Lock(ctx, paymentID)
+		if err != nil {
+			return err
+		}
+		refundable, err := refundableOf(ctx, tx, payment)
+		if err != nil {
+			return err
+		}
+		toRefund := amount
+		if toRefund.IsZero() {
+			toRefund = refundable
+		}
+		cmp, err := toRefund.Cmp(refundable)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPayment, err)
+		}
+		if refundable.IsZero() || cmp > 0 {
+			return fmt.Errorf("%w: %s of a %s payment can be refunded", ErrInvalidPayment, refundable, payment.Status)
+		}
+		reference = payment.Reference
+		refund, err = reserveRefund(ctx, tx, payment, toRefund, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sendRefund(ctx, s.txManager, s.gateway, reference, refund, actorID)
+}
+
+// resendPendingRefunds settles the refunds of a payment that were left
+// pending, e.g. by a failed commit after the gateway call. Ones the gateway
+// rejects are failed and no longer count.
+func (s *paymentService) resendPendingRefunds(ctx context.Context, paymentID, actorID int) error {
+	payment, err := s.paymentRepo.GetByID(ctx, paymentID)
+	if err != nil {
+		return err
+	}
+	refunds, err := s.paymentRepo.ListRefunds(ctx, paymentID)
+	if err != nil {
+		return err
+	}
+	for i := range refunds {
+		if refunds[i].Status != models.RefundPending {
+			continue
+		}
+		_, err := sendRefund(ctx, s.txManager, s.gateway, payment.Reference, &refunds[i], actorID)
+		if err != nil && !errors.Is(err, ErrGatewayRejected) {
+			return err
+		}
+	}
+	return nil
+}
+
+// HandleEvent applies a webhook event once, redeliveries are ignored. An
+// event that fails is not recorded, so the gateway's retry gets another go.
+func (s *paymentService) HandleEvent(ctx context.Context, event *models.PaymentEvent) error {
+	if event.ID == "" || event.Reference == "" {
+		return fmt.Errorf("%w: id and reference are required", ErrInvalidPaymentEvent)
+	}
+	event.Provider = s.gateway.Name()
+
+	return s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		fresh, err := tx.Payments.RecordEvent(ctx, event)
+		if err != nil || !fresh {
+			return err
+		}
+		payment, err := tx.Payments.LockByReference(ctx, event.Provider, event.Reference)
+		if err != nil {
+			return err
+		}
+
+		switch event.Type {
+		case models.EventPaymentCaptured:
+			return applyCapture(ctx, tx, payment, 0)
+		case models.EventPaymentFailed, models.EventPaymentVoided:
+			if payment.Status != models.PaymentAuthorized {
+				return nil
+			}
+			payment.Status = models.PaymentVoided
+			if event.Type == models.EventPaymentFailed {
+				payment.Status, payment.FailureReason = models.PaymentFailed, event.Reason
+			}
+			return tx.Payments.Update(ctx, payment)
+		case models.EventPaymentRefunded:
+			if !event.Amount.SameCurrency(payment.Amount) {
+				return fmt.Errorf("%w: refund in %q of a %s payment", ErrInvalidPaymentEvent, event.Amount.Currency, payment.Amount.Currency)
+			}
+			return applyRefund(ctx, tx, payment, event.Amount, 0)
+		}
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidPaymentEvent, event.Type)
+	})
+}
+
+// applyCapture marks an authorized payment captured and its pending order
+// paid. Anything else means the capture was applied already.
+func applyCapture(ctx context.Context, tx repository.Repos, payment *models.Payment, changedBy int) error {
+	if payment.Status != models.PaymentAuthorized {
+		return nil
+	}
+	payment.Status = models.PaymentCaptured
+	if err := tx.Payments.Update(ctx, payment); err != nil {
+		return err
+	}
+	order, err := tx.Orders.GetByID(ctx, payment.OrderID)
+	if err != nil {
+		return err
+	}
+	if order.Status != models.OrderPending {
+		return nil // e.g. cancelled meanwhile, the payment is left for staff to refund
+	}
+	change := &models.OrderStatusChange{To: models.OrderPaid, Note: "payment " + payment.Reference + " captured", ChangedBy: changedBy}
+	return transitionOrder(ctx, tx, order, change)
+}
+
+// voidPayment voids an authorization at the gateway and then records it. The
+// gateway is called outside of any transaction, so a deadlock retry can't
+// call it again.
+func voidPayment(ctx context.Context, txManager repository.TxManager, gateway PaymentGateway, payment *models.Payment) (*models.Payment, error) {
+	if _, err := gateway.Void(ctx, payment.Reference); err != nil {
+		return nil, err
+	}
+	id := payment.ID
+	err := txManager.WithTx(ctx, func(tx repository.Repos) error {
+		var err error
+		if payment, err = tx.Payments.Lock(ctx, id); err != nil {
+			return err
+		}
+		if payment.Status != models.PaymentAuthorized {
+			return nil // the payment.voided event got here first
+		}
+		payment.Status = models.PaymentVoided
+		return tx.Payments.Update(ctx, payment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// refundableOf is what of a locked payment can still be refunded, leaving
+// out the refunds that are pending at the gateway
+func refundableOf(ctx context.Context, tx repository.Repos, payment *models.Payment) (models.Money, error) {
+	left := payment.Refundable()
+	refunds, err := tx.Payments.ListRefunds(ctx, payment.ID)
+	if err != nil {
+		return left, err
+	}
+	for _, refund := range refunds {
+		if refund.Status != models.RefundPending {
+			continue
+		}
+		if left, err = left.Sub(refund.Amount); err != nil {
+			return left, err
+		}
+	}
+	if left.IsNegative() {
+		left.Amount = 0 // a webhook counted a pending refund already
+	}
+	return left, nil
+}
+
+// reserveRefund records a pending refund of amount for a locked payment,
+// made for the return returnID if it isn't nil.
+// Callers check amount against refundableOf first, so refunds running
+// concurrently can't overshoot while the gateway is called.
+func reserveRefund(ctx context.Context, tx repository.Repos, payment *models.Payment, amount models.Money, returnID *int) (*models.Refund, error) {
+	key, err := utils.NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+	refund := &models.Refund{PaymentID: payment.ID, ReturnID: returnID, IdempotencyKey: key, Status: models.RefundPending, Amount: amount}
+	if err := tx.Payments.CreateRefund(ctx, refund); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// sendRefund sends a pending refund to the gateway outside of any
+// transaction and then settles it in one, returning the payment as updated.
+// A rejected refund is failed, on any other error it stays pending and is
+// sent again with the same key later.
+func sendRefund(ctx context.Context, txManager repository.TxManager, gateway PaymentGateway, reference string, refund *models.Refund, changedBy int) (*models.Payment, error) {
+	result, err := gateway.Refund(ctx, reference, refund.Amount, refund.IdempotencyKey)
+	if errors.Is(err, ErrGatewayRejected) {
+		refund.Status, refund.FailureReason = models.RefundFailed, err.Error()
+		if err := txManager.WithTx(ctx, func(tx repository.Repos) error {
+			return tx.Payments.UpdateRefund(ctx, refund)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var payment *models.Payment
+	err = txManager.WithTx(ctx, func(tx repository.Repos) error {
+		var err error
+		if payment, err = tx.Payments.Lock(ctx, refund.PaymentID); err != nil {
+			return err
+		}
+		refund.Status = models.RefundSucceeded
+		if err := tx.Payments.UpdateRefund(ctx, refund); err != nil {
+			return err
+		}
+		return applyRefund(ctx, tx, payment, result.Refunded, changedBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// applyRefund raises the refunded total of a payment to total, a lower total
+// is a stale event. Once fully refunded the order follows if it still can.
+func applyRefund(ctx context.Context, tx repository.Repos, payment *models.Payment, total models.Money, changedBy int) error {
+	if payment.Status != models.PaymentCaptured && payment.Status != models.PaymentPartiallyRefunded {
+		return nil
+	}
+	cmp, err := total.Cmp(payment.Amount)
+	if err != nil {
+		return err
+	}
+	if cmp > 0 {
+		total = payment.Amount
+	}
+	refunded, err := total.Sub(payment.RefundedAmount)
+	if err != nil || !refunded.IsPositive() {
+		return err
+	}
+	payment.RefundedAmount = total
+	payment.Status = models.PaymentPartiallyRefunded
+	if payment.Refundable().IsZero() {
+		payment.Status = models.PaymentRefunded
+	}
+	if err := tx.Payments.Update(ctx, payment); err != nil {
+		return err
+	}
+	if err := tx.Orders.AddRefund(ctx, payment.OrderID, refunded); err != nil {
+		return err
+	}
+	if payment.Status != models.PaymentRefunded {
+		return nil
+	}
+
+	order, err := tx.Orders.GetByID(ctx, payment.OrderID)
+	if err != nil {
+		return err
+	}
+	if !order.Status.CanTransitionTo(models.OrderRefunded) {
+		return nil
+	}
+	change := &models.OrderStatusChange{To: models.OrderRefunded, Note: "payment " + payment.Reference + " refunded", ChangedBy: changedBy}
+	return transitionOrder(ctx, tx, order, change)
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPaymentRepo struct {
+	mock.Mock
+}
+
+func (m *MockPaymentRepo) Create(ctx context.Context, payment *models.Payment) error {
+	args := m.Called(payment)
+	if args.Error(0) == nil {
+		payment.ID = 50
+	}
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepo) GetByID(ctx context.Context, id int) (*models.Payment, error) {
+	return m.payment(m.Called(id))
+}
+
+func (m *MockPaymentRepo) Lock(ctx context.Context, id int) (*models.Payment, error) {
+	return m.payment(m.Called(id))
+}
+
+func (m *MockPaymentRepo) LockByReference(ctx context.Context, provider, reference string) (*models.Payment, error) {
+	return m.payment(m.Called(provider, reference))
+}
+
+func (m *MockPaymentRepo) ListByOrder(ctx context.Context, orderID int) ([]models.Payment, error) {
+	args := m.Called(orderID)
+	return args.Get(0).([]models.Payment), args.Error(1)
+}
+
+func (m *MockPaymentRepo) Update(ctx context.Context, payment *models.Payment) error {
+	args := m.Called(payment)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepo) CreateRefund(ctx context.Context, refund *models.Refund) error {
+	args := m.Called(refund.PaymentID, refund.Amount)
+	if args.Error(0) == nil {
+		refund.ID = 70
+	}
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepo) ListRefunds(ctx context.Context, paymentID int) ([]models.Refund, error) {
+	args := m.Called(paymentID)
+	refunds := args.Get(0).([]models.Refund)
+	return append([]models.Refund(nil), refunds...), args.Error(1)
+}
+
+func (m *MockPaymentRepo) UpdateRefund(ctx context.Context, refund *models.Refund) error {
+	args := m.Called(refund)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepo) RecordEvent(ctx context.Context, event *models.PaymentEvent) (bool, error) {
+	args := m.Called(event.ID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPaymentRepo) payment(args mock.Arguments) (*models.Payment, error) {
+	if payment := args.Get(0); payment != nil {
+		copied := *payment.(*models.Payment)
+		return &copied, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type paymentTestRepos struct {
+	payments  *MockPaymentRepo
+	orders    *MockOrderRepo
+	inventory *MockInventoryRepo
+}
+
+func newTestPaymentService(gateway PaymentGateway) (PaymentService, paymentTestRepos) {
+	repos := paymentTestRepos{new(MockPaymentRepo), new(MockOrderRepo), new(MockInventoryRepo)}
+	tx := inlineTx{repository.Repos{Payments: repos.payments, Orders: repos.orders, Inventory: repos.inventory}}
+	return NewPaymentService(repos.payments, repos.orders, gateway, tx), repos
+}
+
+func TestFakeGateway(t *testing.T) {
+	ctx := context.Background()
+	gateway := NewFakeGateway()
+
+	result, err := gateway.Authorize(ctx, PaymentRequest{OrderID: 40, Amount: usd(1900), MethodToken: "tok_visa"})
+	assert.NoError(t, err)
+	assert.Equal(t, GatewayResult{Reference: "fake_40_1", Status: models.PaymentAuthorized}, result)
+	result, err = gateway.Capture(ctx, result.Reference, usd(1900))
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentCaptured, result.Status)
+	result, err = gateway.Refund(ctx, "fake_40_1", usd(400), "key_1")
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentPartiallyRefunded, result.Status)
+	assert.Equal(t, usd(400), result.Refunded)
+	result, err = gateway.Refund(ctx, "fake_40_1", usd(400), "key_1")
+	assert.NoError(t, err)
+	assert.Equal(t, usd(400), result.Refunded, "a repeated key refunds once")
+	_, err = gateway.Refund(ctx, "fake_40_1", usd(1600), "key_2")
+	assert.ErrorIs(t, err, ErrGatewayRejected)
+
+	result, err = gateway.Authorize(ctx, PaymentRequest{OrderID: 41, Amount: usd(500), MethodToken: FakeTokenDeclined})
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentFailed, result.Status)
+	_, err = gateway.Capture(ctx, result.Reference, usd(500))
+	assert.ErrorIs(t, err, ErrGatewayRejected)
+
+	result, _ = gateway.Authorize(ctx, PaymentRequest{OrderID: 42, Amount: usd(500), MethodToken: FakeTokenAsync})
+	result, err = gateway.Capture(ctx, result.Reference, usd(500))
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentAuthorized, result.Status)
+
+	_, err = NewPaymentGateway("acme")
+	assert.Error(t, err)
+}
+
+func TestPayOrder(t *testing.T) {
+	pending := &models.Order{ID: 40, UserID: 7, Status: models.OrderPending, Subtotal: usd(1900), Discount: usd(0), Tax: usd(0)}
+
+	t.Run("Captured payment marks the order paid", func(t *testing.T) {
+		paymentService, repos := newTestPaymentService(NewFakeGateway())
+		repos.orders.On("Lock", 40).Return(pending, nil)
+		repos.orders.On("GetByID", 40).Return(pending, nil)
+		repos.payments.On("ListByOrder", 40).Return([]models.Payment(nil), nil)
+		repos.payments.On("Create", mock.MatchedBy(func(payment *models.Payment) bool {
+			return payment.Reference == "" && payment.Status == models.PaymentPending && payment.Amount == usd(1900)
+		})).Return(nil).Once()
+		repos.payments.On("Update", mock.MatchedBy(func(payment *models.Payment) bool {
+			return payment.Reference == "fake_40_1" && payment.Status == models.PaymentAuthorized
+		})).Return(nil).Once()
+		repos.payments.On("Lock", 50).Return(&models.Payment{ID: 50, OrderID: 40, Reference: "fake_40_1", Status: models.PaymentAuthorized, Amount: usd(1900), RefundedAmount: usd(0)}, nil)
+		repos.payments.On("Update", mock.MatchedBy(func(payment *models.Payment) bool {
+			return payment.Status == models.PaymentCaptured
+		})).Return(nil).Once()
+		repos.orders.On("UpdateStatus", 40, models.OrderPending, models.OrderPaid).Return(nil).Once()
+		repos.orders.On("AddStatusChange", mock.MatchedBy(func(change *models.OrderStatusChange) bool {
+			return change.To == models.OrderPaid && change.ChangedBy == 7
+		})).Return(nil).Once()
+
+		payment, err := paymentService.PayOrder(context.Background(), 7, 40, "tok_visa")
+		assert.NoError(t, err)
+		assert.Equal(t, models.PaymentCaptured, payment.Status)
+		repos.payments.AssertExpectations(t)
+		repos.orders.AssertExpectations(t)
+	})
+	t.Run("Declined", func(t *testing.T) {
+		paymentService, repos := newTestPaymentService(NewFakeGateway())
+		repos.orders.On("Lock", 40).Return(pending, nil)
+		repos.payments.On("ListByOrder", 40).Return([]models.Payment(nil), nil)
+		repos.payments.On("Create", mock.Anything).Return(nil).Once()
+		repos.payments.On("Update", mock.Anything).Return(nil).Once()
+
+		payment, err := paymentService.PayOrder(context.Background(), 7, 40, FakeTokenDeclined)
+		assert.ErrorIs(t, err, ErrPaymentDeclined)
+		assert.Equal(t, models.PaymentFailed, payment.Status)
+		repos.orders.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("Already paid", func(t *testing.T) {
+		paymentService, repos := newTestPaymentService(NewFakeGateway())
+		repos.orders.On("Lock", 40).Return(pending, nil)
+		repos.payments.On("ListByOrder", 40).Return([]models.Payment{{ID: 50, Status: models.PaymentAuthorized}}, nil)
+
+		_, err := paymentService.PayOrder(context.Background(), 7, 40, "tok_visa")
+		assert.ErrorIs(t, err, ErrInvalidPayment)
+	})
+	t.Run("Another attempt is in progress", func(t *testing.T) {
+		paymentService, repos := newTestPaymentService(NewFakeGateway())
+		repos.orders.On("Lock", 40).Return(pending, nil)
+		repos.payments.On("ListByOrder", 40).Return([]models.Payment{{ID: 50, Status: models.PaymentPending, CreatedAt: time.Now()}}, nil)
+
+		_, err := paymentService.PayOrder(context.Background(), 7, 40, "tok_visa")
+		assert.ErrorIs(t, err, ErrInvalidPayment)
+		repos.payments.AssertNotCalled(t, "Create", mock.Anything)
+	})
+	t.Run("An abandoned attempt doesn't block the order", func(t *testing.T) {
+		paymentService, repos := newTestPaymentService(NewFakeGateway())
+		repos.orders.On("Lock", 40).Return(pending, nil)
+		repos.payments.On("ListByOrder", 40).Return([]models.Payment{{ID: 49, Status: models.PaymentPending, CreatedAt: time.Now().Add(-time.Hour)}}, nil)
+		repos.payments.On("Create", mock.Anything).Return(nil).Once()
+		repos.payments.On("Update", mock.Anything).Return(nil).Once()
+
+		_, err := paymentService.PayOrder(context.Background(), 7, 40, FakeTokenDeclined)
+		assert.ErrorIs(t, err, ErrPaymentDeclined)
+		repos.payments.AssertExpectations(t)
+	})
+	t.Run("Someone else's order", func(t *testing.T) {
+		paymentService, repos := newTestPaymentService(NewFakeGateway())
+		repos.orders.On("Lock", 40).Return(pending, nil)
+
+		_, err := paymentService.PayOrder(context.Background(), 8, 40, "tok_visa")
+		assert.ErrorIs(t, err, ErrOrderNotFound)
+	})
+}
+
+func TestHandlePaymentEvent(t *testing.T) {
+	captured := &models.PaymentEvent{ID: "evt_1", Type: models.EventPaymentCaptured, Reference: "fake_40_1"}
+
+	t.Run("Capture marks the order paid", func(t *testing.T) {
+		paymentService, repos := newTestPaymentService(NewFakeGateway())
+		repos.payments.On("RecordEvent", "evt_1").Return(true, nil).Once()
+		repos.payments.On("LockByReference", GatewayFake, "fake_40_1").Return(&models.Payment{ID: 50, OrderID: 40, Reference: "fake_40_1", Status: models.PaymentAuthorized, Amount: usd(1900), RefundedAmount: usd(0)}, nil)
+		repos.payments.On("Update", mock.Anything).Return(nil).Once()
+		repos.orders.On("GetByID", 40).Return(&models.Order{ID: 40, Status: models.OrderPending}, nil)
+		repos.orders.On("UpdateStatus", 40, models.OrderPending, models.OrderPaid).Return(nil).Once()
+		repos.orders.On("AddStatusChange", mock.Anything).Return(nil).Once()
+
+		assert.NoError(t, paymentService.HandleEvent(context.Background(), captured))
+		repos.orders.AssertExpectations(t)
+	})
+	t.Run("Redelivery is ignored", func(t *testing.T) {
+		paymentService, repos := newTestPaymentService(NewFakeGateway())
+		repos.payments.On("RecordEvent", "evt_1").Return(false, nil).Once()
+
+		assert.NoError(t, paymentService.HandleEvent(context.Background(), captured))
+		repos.payments.AssertNotCalled(t, "LockByReference", mock.Anything, mock.Anything)
+	})
+	t.Run("Full refund refunds the order", func(t *testing.T) {
+		paymentService, repos := newTestPaymentService(NewFakeGateway())
+		repos.payments.On("RecordEvent", "evt_2").Return(true, nil).Once()
+		repos.payments.On("LockByReference", GatewayFake, "fake_40_1").Return(&models.Payment{ID: 50, OrderID: 40, Reference: "fake_40_1", Status: models.PaymentPartiallyRefunded, Amount: usd(1900), RefundedAmount: usd(400)}, nil)
+		repos.payments.On("Update", mock.MatchedBy(func(payment *models.Payment) bool {
+			return payment.Status == models.PaymentRefunded && payment.RefundedAmount == usd(1900)
+		})).Return(nil).Once()
+		repos.orders.On("AddRefund", 40, usd(1500)).Return(nil).Once()
+		repos.orders.On("GetByID", 40).Return(&models.Order{ID: 40, Status: models.OrderShipped}, nil)
+		repos.orders.On("UpdateStatus", 40, models.OrderShipped, models.OrderRefunded).Return(nil).Once()
+		repos.orders.On("AddStatusChange", mock.Anything).Return(nil).Once()
+
+		event := &models.PaymentEvent{ID: "evt_2", Type: models.EventPaymentRefunded, Reference: "fake_40_1", Amount: usd(1900)}
+		assert.NoError(t, paymentService.HandleEvent(context.Background(), event))
+		repos.payments.AssertExpectations(t)
+		repos.orders.AssertExpectations(t)
+	})
+	t.Run("Unknown type", func(t *testing.T) {
+		paymentService, repos := newTestPaymentService(NewFakeGateway())
+		repos.payments.On("RecordEvent", "evt_3").Return(true, nil).Once()
+		repos.payments.On("LockByReference", GatewayFake, "fake_40_1").Return(&models.Payment{ID: 50, OrderID: 40}, nil)
+
+		err := paymentService.HandleEvent(context.Background(), &models.PaymentEvent{ID: "evt_3", Type: "payment.lost", Reference: "fake_40_1"})
+		assert.ErrorIs(t, err, ErrInvalidPaymentEvent)
+	})
+}
+
+func TestVoidPayment(t *testing.T) {
+	gateway := NewFakeGateway()
+	authorized, _ := gateway.Authorize(context.Background(), PaymentRequest{OrderID: 40, Amount: usd(1900), MethodToken: "tok_visa"})
+	paymentService, repos := newTestPaymentService(gateway)
+	payment := &models.Payment{ID: 50, OrderID: 40, Reference: authorized.Reference, Status: models.PaymentAuthorized, Amount: usd(1900), RefundedAmount: usd(0)}
+	repos.payments.On("GetByID", 50).Return(payment, nil)
+	repos.payments.On("Lock", 50).Return(payment, nil)
+	repos.payments.On("Update", mock.MatchedBy(func(payment *models.Payment) bool {
+		return payment.Status == models.PaymentVoided
+	})).Return(nil).Once()
+
+	voided, err := paymentService.VoidPayment(context.Background(), 50, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentVoided, voided.Status)
+	repos.payments.AssertExpectations(t)
+}
+
+func TestRefundPayment(t *testing.T) {
+	newRefund := func(refunds []models.Refund) (PaymentService, paymentTestRepos, *FakeGateway) {
+		gateway := NewFakeGateway()
+		authorized, _ := gateway.Authorize(context.Background(), PaymentRequest{OrderID: 40, Amount: usd(1900), MethodToken: "tok_visa"})
+		gateway.Capture(context.Background(), authorized.Reference, usd(1900))
+		paymentService, repos := newTestPaymentService(gateway)
+		payment := &models.Payment{ID: 50, OrderID: 40, Reference: authorized.Reference, Status: models.PaymentCaptured, Amount: usd(1900), RefundedAmount: usd(0)}
+		repos.payments.On("GetByID", 50).Return(payment, nil)
+		repos.payments.On("Lock", 50).Return(payment, nil)
+		repos.payments.On("ListRefunds", 50).Return(refunds, nil)
+		return paymentService, repos, gateway
+	}
+
+	t.Run("Partial refund", func(t *testing.T) {
+		paymentService, repos, _ := newRefund([]models.Refund(nil))
+
+		_, err := paymentService.RefundPayment(context.Background(), 50, usd(2500), 2)
+		assert.ErrorIs(t, err, ErrInvalidPayment)
+
+		repos.payments.On("CreateRefund", 50, usd(400)).Return(nil).Once()
+		repos.payments.On("UpdateRefund", mock.MatchedBy(func(refund *models.Refund) bool {
+			return refund.ID == 70 && refund.Status == models.RefundSucceeded && refund.IdempotencyKey != ""
+		})).Return(nil).Once()
+		repos.payments.On("Update", mock.Anything).Return(nil).Once()
+		repos.orders.On("AddRefund", 40, usd(400)).Return(nil).Once()
+		payment, err := paymentService.RefundPayment(context.Background(), 50, usd(400), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, models.PaymentPartiallyRefunded, payment.Status)
+		assert.Equal(t, usd(400), payment.RefundedAmount)
+		repos.orders.AssertNotCalled(t, "GetByID", mock.Anything)
+		repos.orders.AssertExpectations(t)
+		repos.payments.AssertExpectations(t)
+	})
+	t.Run("Pending refunds count against the refundable amount", func(t *testing.T) {
+		paymentService, repos, gateway := newRefund([]models.Refund{{ID: 69, PaymentID: 50, IdempotencyKey: "key_1", Status: models.RefundPending, Amount: usd(1500)}})
+		// the gateway made the pending refund, but the commit recording it failed
+		gateway.Refund(context.Background(), "fake_40_1", usd(1500), "key_1")
+		repos.payments.On("UpdateRefund", mock.MatchedBy(func(refund *models.Refund) bool {
+			return refund.ID == 69 && refund.Status == models.RefundSucceeded
+		})).Return(nil).Once()
+		repos.payments.On("Update", mock.MatchedBy(func(payment *models.Payment) bool {
+			return payment.RefundedAmount == usd(1500)
+		})).Return(nil).Once()
+		repos.orders.On("AddRefund", 40, usd(1500)).Return(nil).Once()
+
+		// the mock keeps listing it as pending, so only 400 is left
+		_, err := paymentService.RefundPayment(context.Background(), 50, usd(1000), 2)
+		assert.ErrorIs(t, err, ErrInvalidPayment)
+		repos.payments.AssertExpectations(t)
+		repos.orders.AssertExpectations(t)
+	})
+}
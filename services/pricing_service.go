@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrProductPriceNotFound = repository.ErrProductPriceNotFound
+	ErrUnsupportedCurrency  = errors.New("unsupported currency")
+	ErrNoExchangeRate       = errors.New("no exchange rate")
+	ErrInvalidPrice         = errors.New("invalid price")
+	ErrInvalidExchangeRate  = errors.New("invalid exchange rate")
+)
+
+// PricingService shows the catalogue in the currencies the store sells in.
+// Products are priced in the base currency; in another currency a product
+// sells for its explicit price there, or else its price converted at the
+// exchange rate in effect.
+type PricingService interface {
+	BaseCurrency() string
+	// Supports reports whether prices can be shown in currency
+	Supports(currency string) bool
+	// LocalizeProducts reprices the products and their variants in currency
+	LocalizeProducts(ctx context.Context, products []models.Product, currency string) error
+	Convert(ctx context.Context, amount models.Money, currency string) (models.Money, error)
+
+	GetProductPrices(ctx context.Context, productID int) ([]models.ProductPrice, error)
+	SetProductPrice(ctx context.Context, price *models.ProductPrice) error
+	DeleteProductPrice(ctx context.Context, productID int, currency string) error
+
+	CreateExchangeRate(ctx context.Context, rate *models.ExchangeRate) error
+	GetExchangeRates(ctx context.Context, filter models.ExchangeRateFilter) ([]models.ExchangeRate, error)
+}
+
+type pricingService struct {
+	priceRepo   repository.PriceRepo
+	rateRepo    repository.ExchangeRateRepo
+	productRepo repository.ProductRepo
+	base        string
+	currencies  map[string]bool // base included
+	rounding    models.RoundingMode
+}
+
+// NewPricingService sells in base and the extra currencies, converted amounts are rounded with rounding
+func NewPricingService(priceRepo repository.PriceRepo, rateRepo repository.ExchangeRateRepo, productRepo repository.ProductRepo,
+	base string, currencies []string, rounding models.RoundingMode) PricingService {
+	supported := map[string]bool{base: true}
+	for _, currency := range currencies {
+		supported[currency] = true
+	}
+	return &pricingService{
+		priceRepo:   priceRepo,
+		rateRepo:    rateRepo,
+		productRepo: productRepo,
+		base:        base,
+		currencies:  supported,
+		rounding:    rounding,
+	}
+}
+
+func (s *pricingService) BaseCurrency() string {
+	return s.base
+}
+
+func (s *pricingService) Supports(currency string) bool {
+	return s.currencies[currency]
+}
+
+func (s *pricingService) LocalizeProducts(ctx context.Context, products []models.Product, currency string) error {
+	if !s.Supports(currency) {
+		return fmt.Errorf("%w: %q", ErrUnsupportedCurrency, currency)
+	}
+	if currency == s.base || len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+	prices, err := s.priceRepo.ListByProducts(ctx, ids, currency)
+	if err != nil {
+		return err
+	}
+	explicit := make(map[int]models.Money, len(prices))
+	for _, price := range prices {
+		explicit[price.ProductID] = price.Price
+	}
+
+	// the rate is looked up once per source currency, and not at all when
+	// every product has an explicit price and no variant its own
+	converter := s.converter(ctx, currency)
+	for i := range products {
+		product := &products[i]
+		if price, ok := explicit[product.ID]; ok {
+			product.Price = price
+		} else if product.Price, err = converter(product.Price); err != nil {
+			return err
+		}
+		for j := range product.Variants {
+			variant := &product.Variants[j]
+			if variant.Price == nil {
+				continue
+			}
+			converted, err := converter(*variant.Price)
+			if err != nil {
+				return err
+			}
+			variant.Price = &converted
+		}
+	}
+	return nil
+}
+
+func (s *pricingService) Convert(ctx context.Context, amount models.Money, currency string) (models.Money, error) {
+	return s.converter(ctx, currency)(amount)
+}
+
+// converter returns a function converting amounts into currency at the rates
+// in effect now, remembering the rates it looked up
+func (s *pricingService) converter(ctx context.Context, currency string) func(models.Money) (models.Money, error) {
+	now := time.Now()
+	rates := map[string]*models.ExchangeRate{}
+	return func(amount models.Money) (models.Money, error) {
+		if amount.Currency == currency {
+			return amount, nil
+		}
+		rate, ok := rates[amount.Currency]
+		if !ok {
+			var err error
+			rate, err = s.rateRepo.GetEffective(ctx, amount.Currency, currency, now)
+			if errors.Is(err, repository.ErrExchangeRateNotFound) {
+				return models.Money{}, fmt.Errorf("%w from %s to %s", ErrNoExchangeRate, amount.Currency, currency)
+			}
+			if err != nil {
+				return models.Money{}, err
+			}
+			rates[amount.Currency] = rate
+		}
+		return rate.Convert(amount, s.rounding)
+	}
+}
+
+func (s *pricingService) GetProductPrices(ctx context.Context, productID int) ([]models.ProductPrice, error) {
+	if _, err := s.productRepo.GetByID(ctx, productID); err != nil {
+		return nil, err
+	}
+	return s.priceRepo.ListByProduct(ctx, productID)
+}
+
+// SetProductPrice fixes the product's price in a currency other than the base one
+func (s *pricingService) SetProductPrice(ctx context.Context, price *models.ProductPrice) error {
+	price.Price.Currency = strings.ToUpper(price.Price.Currency)
+	switch {
+	case !s.Supports(price.Price.Currency):
+		return fmt.Errorf("%w: %q", ErrUnsupportedCurrency, price.Price.Currency)
+	case price.Price.Currency == s.base:
+		return fmt.Errorf("%w: the %s price is the product's own price", ErrInvalidPrice, s.base)
+	case !price.Price.IsPositive():
+		return fmt.Errorf("%w: price must be greater than zero", ErrInvalidPrice)
+	}
+	if _, err := s.productRepo.GetByID(ctx, price.ProductID); err != nil {
+		return err
+	}
+	return s.priceRepo.Set(ctx, price)
+}
+
+// DeleteProductPrice goes back to converting the product price into currency
+func (s *pricingService) DeleteProductPrice(ctx context.Context, productID int, currency string) error {
+	return s.priceRepo.Delete(ctx, productID, strings.ToUpper(currency))
+}
+
+// CreateExchangeRate adds a rate to the history, it applies from its
+// effective time (now when left out) until a later one for the pair
+func (s *pricingService) CreateExchangeRate(ctx context.Context, rate *models.ExchangeRate) error {
+	rate.Base = strings.ToUpper(rate.Base)
+	rate.Quote = strings.ToUpper(rate.Quote)
+	switch {
+	case !models.ValidCurrency(rate.Base) || !models.ValidCurrency(rate.Quote):
+		return fmt.Errorf("%w: %q and %q must be ISO 4217 currency codes", ErrInvalidExchangeRate, rate.Base, rate.Quote)
+	case rate.Base == rate.Quote:
+		return fmt.Errorf("%w: base and quote must differ", ErrInvalidExchangeRate)
+	case rate.Rate <= 0:
+		return fmt.Errorf("%w: rate must be greater than zero", ErrInvalidExchangeRate)
+	}
+	if rate.EffectiveAt.IsZero() {
+		rate.EffectiveAt = time.Now()
+	}
+	return s.rateRepo.Create(ctx, rate)
+}
+
+func (s *pricingService) GetExchangeRates(ctx context.Context, filter models.ExchangeRateFilter) ([]models.ExchangeRate, error) {
+	filter.Base = strings.ToUpper(filter.Base)
+	filter.Quote = strings.ToUpper(filter.Quote)
+	return s.rateRepo.List(ctx, filter)
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPriceRepo struct {
+	mock.Mock
+}
+
+func (m *MockPriceRepo) ListByProduct(ctx context.Context, productID int) ([]models.ProductPrice, error) {
+	args := m.Called(productID)
+	return args.Get(0).([]models.ProductPrice), args.Error(1)
+}
+
+func (m *MockPriceRepo) ListByProducts(ctx context.Context, productIDs []int, currency string) ([]models.ProductPrice, error) {
+	args := m.Called(productIDs, currency)
+	return args.Get(0).([]models.ProductPrice), args.Error(1)
+}
+
+func (m *MockPriceRepo) Set(ctx context.Context, price *models.ProductPrice) error {
+	args := m.Called(price)
+	return args.Error(0)
+}
+
+func (m *MockPriceRepo) Delete(ctx context.Context, productID int, currency string) error {
+	args := m.Called(productID, currency)
+	return args.Error(0)
+}
+
+type MockExchangeRateRepo struct {
+	mock.Mock
+}
+
+func (m *MockExchangeRateRepo) Create(ctx context.Context, rate *models.ExchangeRate) error {
+	args := m.Called(rate)
+	return args.Error(0)
+}
+
+func (m *MockExchangeRateRepo) GetEffective(ctx context.Context, from, to string, at time.Time) (*models.ExchangeRate, error) {
+	args := m.Called(from, to)
+	if rate := args.Get(0); rate != nil {
+		return rate.(*models.ExchangeRate), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockExchangeRateRepo) List(ctx context.Context, filter models.ExchangeRateFilter) ([]models.ExchangeRate, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]models.ExchangeRate), args.Error(1)
+}
+
+func newTestPricingService() (PricingService, *MockPriceRepo, *MockExchangeRateRepo, *MockProductRepo) {
+	priceRepo, rateRepo, productRepo := new(MockPriceRepo), new(MockExchangeRateRepo), new(MockProductRepo)
+	return NewPricingService(priceRepo, rateRepo, productRepo, "USD", []string{"EUR", "GBP", "JPY"}, models.RoundHalfEven), priceRepo, rateRepo, productRepo
+}
+
+func TestLocalizeProducts(t *testing.T) {
+	products := func() []models.Product {
+		return []models.Product{
+			{ID: 1, Name: "Mug", Price: usd(1000)},
+			{ID: 2, Name: "Shirt", Price: usd(2000), Variants: []models.Variant{{ID: 4, ProductID: 2}, {ID: 5, ProductID: 2, Price: usdPtr(2250)}}},
+		}
+	}
+	eur := func(amount int64) models.Money { return models.NewMoney(amount, "EUR") }
+
+	t.Run("Explicit prices win over conversion", func(t *testing.T) {
+		pricingService, priceRepo, rateRepo, _ := newTestPricingService()
+		priceRepo.On("ListByProducts", []int{1, 2}, "EUR").Return([]models.ProductPrice{{ProductID: 2, Price: eur(1799)}}, nil)
+		rateRepo.On("GetEffective", "USD", "EUR").Return(&models.ExchangeRate{Base: "EUR", Quote: "USD", Rate: 108000000}, nil).Once()
+
+		localized := products()
+		assert.NoError(t, pricingService.LocalizeProducts(context.Background(), localized, "EUR"))
+		assert.Equal(t, eur(926), localized[0].Price) // 10.00 / 1.08 = 9.259...
+		assert.Equal(t, eur(1799), localized[1].Price)
+		assert.Equal(t, eur(1799), localized[1].Variants[0].EffectivePrice(&localized[1]))
+		assert.Equal(t, eur(2083), *localized[1].Variants[1].Price)
+		rateRepo.AssertExpectations(t)
+	})
+	t.Run("Base currency is left alone", func(t *testing.T) {
+		pricingService, _, _, _ := newTestPricingService()
+
+		localized := products()
+		assert.NoError(t, pricingService.LocalizeProducts(context.Background(), localized, "USD"))
+		assert.Equal(t, products(), localized)
+	})
+	t.Run("Currency without decimals", func(t *testing.T) {
+		pricingService, priceRepo, rateRepo, _ := newTestPricingService()
+		priceRepo.On("ListByProducts", []int{1}, "JPY").Return([]models.ProductPrice(nil), nil)
+		rateRepo.On("GetEffective", "USD", "JPY").Return(&models.ExchangeRate{Base: "USD", Quote: "JPY", Rate: 15025000000}, nil)
+
+		localized := products()[:1]
+		assert.NoError(t, pricingService.LocalizeProducts(context.Background(), localized, "JPY"))
+		assert.Equal(t, models.NewMoney(1502, "JPY"), localized[0].Price) // 1502.5 to even
+	})
+	t.Run("No rate", func(t *testing.T) {
+		pricingService, priceRepo, rateRepo, _ := newTestPricingService()
+		priceRepo.On("ListByProducts", []int{1, 2}, "GBP").Return([]models.ProductPrice(nil), nil)
+		rateRepo.On("GetEffective", "USD", "GBP").Return(nil, repository.ErrExchangeRateNotFound)
+
+		err := pricingService.LocalizeProducts(context.Background(), products(), "GBP")
+		assert.ErrorIs(t, err, ErrNoExchangeRate)
+	})
+	t.Run("Unsupported currency", func(t *testing.T) {
+		pricingService, _, _, _ := newTestPricingService()
+
+		err := pricingService.LocalizeProducts(context.Background(), products(), "CHF")
+		assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+	})
+}
+
+func TestSetProductPrice(t *testing.T) {
+	pricingService, priceRepo, _, productRepo := newTestPricingService()
+	productRepo.On("GetByID", 1).Return(&models.Product{ID: 1, Price: usd(1000)}, nil)
+
+	t.Run("Success", func(t *testing.T) {
+		price := &models.ProductPrice{ProductID: 1, Price: models.NewMoney(899, "gbp")}
+		priceRepo.On("Set", price).Return(nil).Once()
+
+		assert.NoError(t, pricingService.SetProductPrice(context.Background(), price))
+		assert.Equal(t, "GBP", price.Price.Currency)
+	})
+	t.Run("Base currency", func(t *testing.T) {
+		err := pricingService.SetProductPrice(context.Background(), &models.ProductPrice{ProductID: 1, Price: usd(999)})
+		assert.ErrorIs(t, err, ErrInvalidPrice)
+	})
+	t.Run("Not sold in the currency", func(t *testing.T) {
+		err := pricingService.SetProductPrice(context.Background(), &models.ProductPrice{ProductID: 1, Price: models.NewMoney(999, "CHF")})
+		assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+	})
+	priceRepo.AssertExpectations(t)
+}
+
+func TestCreateExchangeRate(t *testing.T) {
+	pricingService, _, rateRepo, _ := newTestPricingService()
+
+	t.Run("Effective now by default", func(t *testing.T) {
+		rateRepo.On("Create", mock.MatchedBy(func(rate *models.ExchangeRate) bool {
+			return rate.Base == "USD" && rate.Quote == "EUR" && !rate.EffectiveAt.IsZero()
+		})).Return(nil).Once()
+
+		err := pricingService.CreateExchangeRate(context.Background(), &models.ExchangeRate{Base: "usd", Quote: "eur", Rate: 92000000})
+		assert.NoError(t, err)
+	})
+	t.Run("Same currency", func(t *testing.T) {
+		err := pricingService.CreateExchangeRate(context.Background(), &models.ExchangeRate{Base: "USD", Quote: "USD", Rate: 100000000})
+		assert.ErrorIs(t, err, ErrInvalidExchangeRate)
+	})
+	rateRepo.AssertExpectations(t)
+}
@@ -1,55 +1,285 @@
 package services
 
 import (
+	"context"
 	"ecommerce/models"
 	"ecommerce/repository"
+	"errors"
 	"fmt"
+	"strings"
+)
+
+var (
+	ErrProductNotFound = repository.ErrProductNotFound
+	ErrVariantNotFound = repository.ErrVariantNotFound
+	ErrInvalidProduct  = errors.New("invalid product")
+	ErrInvalidVariant  = errors.New("invalid variant")
 )
 
 type ProductService interface {
-	CreateProduct(product *models.Product) error
-	GetProductByID(id int) (*models.Product, error)
-	GetAllProducts() ([]models.Product, error)
-	UpdateProduct(product *models.Product) error
-	DeleteProducts(id int) error
+	CreateProduct(ctx context.Context, product *models.Product) error
+	GetProductByID(ctx context.Context, id int) (*models.Product, error)
+	GetAllProducts(ctx context.Context) ([]models.Product, error)
+	UpdateProduct(ctx context.Context, product *models.Product) error
+	DeleteProducts(ctx context.Context, id int) error
+	// GetProductCategoryIDs returns the categories of each product, the ones
+	// above the product's own categories included
+	GetProductCategoryIDs(ctx context.Context, productIDs []int) (map[int][]int, error)
+
+	CreateOptionType(ctx context.Context, optionType *models.OptionType) error
+	GetOptionTypes(ctx context.Context) ([]models.OptionType, error)
+	CreateVariant(ctx context.Context, variant *models.Variant) error
+	GetVariantBySKU(ctx context.Context, sku string) (*models.Variant, *models.Product, error)
+	UpdateVariant(ctx context.Context, variant *models.Variant) error
+	DeleteVariant(ctx context.Context, sku string) error
 }
 
 type productService struct {
-	productRepo repository.ProductRepo
+	productRepo  repository.ProductRepo
+	variantRepo  repository.VariantRepo
+	categoryRepo repository.CategoryRepo
+	txManager    repository.TxManager
+	currency     string // the catalogue is priced in it
 }
 
-func NewProductService(productRepo repository.ProductRepo) ProductService {
-	return &productService{productRepo: productRepo}
+func NewProductService(productRepo repository.ProductRepo, variantRepo repository.VariantRepo, categoryRepo repository.CategoryRepo,
+	txManager repository.TxManager, currency string) ProductService {
+	return &productService{productRepo: productRepo, variantRepo: variantRepo, categoryRepo: categoryRepo, txManager: txManager, currency: currency}
 }
 
-func (s *productService) CreateProduct(product *models.Product) error {
-	if product.Price <= 0 {
-		return fmt.Errorf("product price must be greter than zero")
+func (s *productService) CreateProduct(ctx context.Context, product *models.Product) error {
+	if err := s.validatePrice(product.Price); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProduct, err)
+	}
+	if err := validateShippingSize(product); err != nil {
+		return err
 	}
-	return s.productRepo.Create(product)
+	if product.TaxClass == "" {
+		product.TaxClass = models.DefaultTaxClass
+	}
+	return taxClassError(s.productRepo.Create(ctx, product), product)
 }
 
-func (s *productService) GetProductByID(id int) (*models.Product, error) {
-	return s.productRepo.GetByID(id)
+// GetProductByID returns the product with its variants
+func (s *productService) GetProductByID(ctx context.Context, id int) (*models.Product, error) {
+	product, err := s.productRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	variants, err := s.variantRepo.ListByProducts(ctx, []int{product.ID})
+	if err != nil {
+		return nil, err
+	}
+	product.Variants = variants
+	return product, nil
 }
 
-func (s *productService) GetAllProducts() ([]models.Product, error) {
-	return s.productRepo.GetAll()
+// GetAllProducts returns every product with its variants, loaded in one query
+func (s *productService) GetAllProducts(ctx context.Context) ([]models.Product, error) {
+	products, err := s.productRepo.GetAll(ctx)
+	if err != nil || len(products) == 0 {
+		return products, err
+	}
+
+	ids := make([]int, len(products))
+	index := make(map[int]int, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+		index[product.ID] = i
+	}
+	variants, err := s.variantRepo.ListByProducts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, variant := range variants {
+		i := index[variant.ProductID]
+		products[i].Variants = append(products[i].Variants, variant)
+	}
+	return products, nil
 }
 
-func (s *productService) UpdateProduct(product *models.Product) error {
-	if product.Name == "" || product.Price <= 0 {
+func (s *productService) UpdateProduct(ctx context.Context, product *models.Product) error {
+	if product.Name == "" || product.Price.IsZero() {
 		return fmt.Errorf("all fields are required")
 	}
+	if err := s.validatePrice(product.Price); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProduct, err)
+	}
+	if err := validateShippingSize(product); err != nil {
+		return err
+	}
 
-	existingProduct, err := s.productRepo.GetByID(product.ID)
+	existingProduct, err := s.productRepo.GetByID(ctx, product.ID)
 	if err != nil || existingProduct == nil {
 		return fmt.Errorf("product not found")
 	}
+	if product.TaxClass == "" {
+		product.TaxClass = existingProduct.TaxClass
+	}
+
+	return taxClassError(s.productRepo.Update(ctx, product), product)
+}
+
+// validateShippingSize checks the weight and dimensions, zero means unknown
+func validateShippingSize(product *models.Product) error {
+	d := product.Dimensions
+	if product.Weight < 0 || d.Length < 0 || d.Width < 0 || d.Height < 0 {
+		return fmt.Errorf("%w: weight and dimensions must not be negative", ErrInvalidProduct)
+	}
+	return nil
+}
+
+// taxClassError turns the foreign key violation of an unknown tax class into ErrInvalidProduct
+func taxClassError(err error, product *models.Product) error {
+	if repository.IsMissingReference(err) {
+		return fmt.Errorf("%w: unknown tax class %q", ErrInvalidProduct, product.TaxClass)
+	}
+	return err
+}
+
+func (s *productService) DeleteProducts(ctx context.Context, id int) error {
+	return s.productRepo.Delete(ctx, id)
+}
+
+func (s *productService) GetProductCategoryIDs(ctx context.Context, productIDs []int) (map[int][]int, error) {
+	return s.categoryRepo.ProductCategoryIDs(ctx, productIDs)
+}
+
+// CreateOptionType adds an option type together with its values
+func (s *productService) CreateOptionType(ctx context.Context, optionType *models.OptionType) error {
+	optionType.Name = strings.ToLower(strings.TrimSpace(optionType.Name))
+	if optionType.Name == "" {
+		return fmt.Errorf("%w: option name is required", ErrInvalidVariant)
+	}
+
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		if err := tx.Variants.CreateOptionType(ctx, optionType); err != nil {
+			return err
+		}
+		for i := range optionType.Values {
+			optionType.Values[i].OptionTypeID = optionType.ID
+			if err := tx.Variants.AddOptionValue(ctx, &optionType.Values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if repository.IsDuplicate(err) {
+		return fmt.Errorf("%w: option %q or one of its values already exists", ErrInvalidVariant, optionType.Name)
+	}
+	return err
+}
+
+func (s *productService) GetOptionTypes(ctx context.Context) ([]models.OptionType, error) {
+	return s.variantRepo.GetOptionTypes(ctx)
+}
+
+func (s *productService) CreateVariant(ctx context.Context, variant *models.Variant) error {
+	if err := s.validateVariant(variant); err != nil {
+		return err
+	}
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		if err := s.checkCombination(ctx, tx, variant); err != nil {
+			return err
+		}
+		return tx.Variants.Create(ctx, variant)
+	})
+	return variantError(err, variant)
+}
+
+// GetVariantBySKU returns the variant and the product it belongs to
+func (s *productService) GetVariantBySKU(ctx context.Context, sku string) (*models.Variant, *models.Product, error) {
+	variant, err := s.variantRepo.GetBySKU(ctx, sku)
+	if err != nil {
+		return nil, nil, err
+	}
+	product, err := s.productRepo.GetByID(ctx, variant.ProductID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return variant, product, nil
+}
+
+func (s *productService) UpdateVariant(ctx context.Context, variant *models.Variant) error {
+	if err := s.validateVariant(variant); err != nil {
+		return err
+	}
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		if err := s.checkCombination(ctx, tx, variant); err != nil {
+			return err
+		}
+		return tx.Variants.Update(ctx, variant)
+	})
+	return variantError(err, variant)
+}
 
-	return s.productRepo.Update(product)
+func (s *productService) DeleteVariant(ctx context.Context, sku string) error {
+	variant, err := s.variantRepo.GetBySKU(ctx, sku)
+	if err != nil {
+		return err
+	}
+	return s.variantRepo.Delete(ctx, variant.ID)
 }
 
-func (s *productService) DeleteProducts(id int) error {
-	return s.productRepo.Delete(id)
+func (s *productService) validateVariant(variant *models.Variant) error {
+	variant.SKU = strings.TrimSpace(variant.SKU)
+	if variant.SKU == "" {
+		return fmt.Errorf("%w: sku is required", ErrInvalidVariant)
+	}
+	if variant.Price != nil {
+		if err := s.validatePrice(*variant.Price); err != nil {
+			return fmt.Errorf("%w: price override: %v", ErrInvalidVariant, err)
+		}
+	}
+	return nil
+}
+
+// validatePrice accepts positive amounts in the catalogue's currency
+func (s *productService) validatePrice(price models.Money) error {
+	switch {
+	case !price.IsPositive():
+		return errors.New("price must be greater than zero")
+	case price.Currency != s.currency:
+		return fmt.Errorf("prices are in %s, not %q", s.currency, price.Currency)
+	}
+	return nil
+}
+
+// checkCombination makes sure the product exists and no other variant of it has the same options
+func (s *productService) checkCombination(ctx context.Context, tx repository.Repos, variant *models.Variant) error {
+	if _, err := tx.Products.GetByID(ctx, variant.ProductID); err != nil {
+		return err
+	}
+	siblings, err := tx.Variants.ListByProducts(ctx, []int{variant.ProductID})
+	if err != nil {
+		return err
+	}
+	for _, sibling := range siblings {
+		if sibling.ID != variant.ID && sameOptions(sibling.Options, variant.Options) {
+			return fmt.Errorf("%w: variant %s already has these options", ErrInvalidVariant, sibling.SKU)
+		}
+	}
+	return nil
+}
+
+func sameOptions(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if b[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func variantError(err error, variant *models.Variant) error {
+	switch {
+	case repository.IsDuplicate(err):
+		return fmt.Errorf("%w: sku %q or its barcode is already used", ErrInvalidVariant, variant.SKU)
+	case errors.Is(err, repository.ErrUnknownOption):
+		return fmt.Errorf("%w: %v", ErrInvalidVariant, err)
+	}
+	return err
 }
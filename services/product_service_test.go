@@ -1,10 +1,13 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"testing"
 
 	"ecommerce/models"
+	"ecommerce/repository"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -14,12 +17,12 @@ type MockProductRepo struct {
 	mock.Mock // provides mocking functionality
 }
 
-func (m *MockProductRepo) Create(product *models.Product) error {
+func (m *MockProductRepo) Create(ctx context.Context, product *models.Product) error {
 	args := m.Called(product)
 	return args.Error(0)
 }
 
-func (m *MockProductRepo) GetByID(id int) (*models.Product, error) {
+func (m *MockProductRepo) GetByID(ctx context.Context, id int) (*models.Product, error) {
 	args := m.Called(id)
 	product := args.Get(0)
 	if product != nil {
@@ -28,60 +31,67 @@ func (m *MockProductRepo) GetByID(id int) (*models.Product, error) {
 	return nil, args.Error(1)
 }
 
-func (m *MockProductRepo) GetAll() ([]models.Product, error) {
+func (m *MockProductRepo) GetAll(ctx context.Context) ([]models.Product, error) {
 	args := m.Called()
 	return args.Get(0).([]models.Product), args.Error(1)
 }
 
-func (m *MockProductRepo) Update(product *models.Product) error {
+func (m *MockProductRepo) Update(ctx context.Context, product *models.Product) error {
 	args := m.Called(product)
 	return args.Error(0)
 }
 
-func (m *MockProductRepo) Delete(id int) error {
+func (m *MockProductRepo) Delete(ctx context.Context, id int) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
 func TestCreateProduct(t *testing.T) {
 	mockRepo := new(MockProductRepo)
-	productService := NewProductService(mockRepo)
+	productService, _ := newTestProductService(mockRepo)
 	validProduct := &models.Product{
 		ID:    1,
 		Name:  "Laptop",
-		Price: 61000,
+		Price: usd(61000),
 	}
 	invalid := &models.Product{
 		ID:    2,
 		Name:  "Mouse",
-		Price: 0,
+		Price: usd(0),
 	}
 	t.Run("Valid Product", func(t *testing.T) {
 		mockRepo.On("Create", validProduct).Return(nil)
 
-		err := productService.CreateProduct(validProduct)
+		err := productService.CreateProduct(context.Background(), validProduct)
 		assert.NoError(t, err)
 		mockRepo.AssertExpectations(t)
 	})
 	t.Run("Invalid price", func(t *testing.T) {
 		mockRepo.ExpectedCalls = nil
-		err := productService.CreateProduct(invalid)
-		assert.Error(t, err)
-		assert.Equal(t, "product price must be greter than zero", err.Error())
+		err := productService.CreateProduct(context.Background(), invalid)
+		assert.ErrorIs(t, err, ErrInvalidProduct)
+	})
+	t.Run("Other currency", func(t *testing.T) {
+		err := productService.CreateProduct(context.Background(), &models.Product{Name: "Mouse", Price: models.NewMoney(900, "EUR")})
+		assert.ErrorIs(t, err, ErrInvalidProduct)
+	})
+	t.Run("Negative weight", func(t *testing.T) {
+		err := productService.CreateProduct(context.Background(), &models.Product{Name: "Mouse", Price: usd(900), Weight: -90})
+		assert.ErrorIs(t, err, ErrInvalidProduct)
 	})
 }
 
 func TestGetProductByID(t *testing.T) {
 	mockRepo := new(MockProductRepo)
-	productService := NewProductService(mockRepo)
+	productService, _ := newTestProductService(mockRepo)
 	mockProduct := &models.Product{
 		ID:    1,
 		Name:  "Laptop",
-		Price: 61000,
+		Price: usd(61000),
 	}
 	t.Run("Product Found", func(t *testing.T) {
 		mockRepo.On("GetByID", 1).Return(mockProduct, nil)
-		product, err := productService.GetProductByID(1)
+		product, err := productService.GetProductByID(context.Background(), 1)
 		assert.NoError(t, err)
 		assert.Equal(t, mockProduct, product)
 		mockRepo.AssertExpectations(t)
@@ -89,7 +99,7 @@ func TestGetProductByID(t *testing.T) {
 	t.Run("Product not found", func(t *testing.T) {
 		mockRepo.ExpectedCalls = nil
 		mockRepo.On("GetByID", 90).Return(nil, errors.New("product not found"))
-		product, err := productService.GetProductByID(90)
+		product, err := productService.GetProductByID(context.Background(), 90)
 		assert.Error(t, err)
 		assert.Nil(t, product)
 		mockRepo.AssertExpectations(t)
@@ -98,22 +108,22 @@ func TestGetProductByID(t *testing.T) {
 
 func TestGetAllProduct(t *testing.T) {
 	mockRepo := new(MockProductRepo)
-	productService := NewProductService(mockRepo)
+	productService, _ := newTestProductService(mockRepo)
 	mockProducts := []models.Product{
 		{
 			ID:    1,
 			Name:  "Laptop",
-			Price: 61000,
+			Price: usd(61000),
 		},
 		{
 			ID:    2,
 			Name:  "Mouse",
-			Price: 0,
+			Price: usd(0),
 		},
 	}
 	t.Run("Product Found", func(t *testing.T) {
 		mockRepo.On("GetAll").Return(mockProducts, nil)
-		product, err := productService.GetAllProducts()
+		product, err := productService.GetAllProducts(context.Background())
 		assert.NoError(t, err)
 		assert.Equal(t, 2, len(product))
 		assert.Equal(t, mockProducts, product)
@@ -122,7 +132,7 @@ func TestGetAllProduct(t *testing.T) {
 	t.Run("Not found", func(t *testing.T) {
 		mockRepo.ExpectedCalls = nil
 		mockRepo.On("GetAll").Return([]models.Product{}, errors.New("database error"))
-		product, err := productService.GetAllProducts()
+		product, err := productService.GetAllProducts(context.Background())
 		assert.Error(t, err)
 		assert.Empty(t, product)
 		mockRepo.AssertExpectations(t) // Verify that all expectations were met
@@ -131,27 +141,27 @@ func TestGetAllProduct(t *testing.T) {
 
 func TestUpdateProduct(t *testing.T) {
 	mockRepo := new(MockProductRepo)
-	productService := NewProductService(mockRepo)
+	productService, _ := newTestProductService(mockRepo)
 	product := &models.Product{
 		ID:    1,
 		Name:  "Laptop",
-		Price: 61000,
+		Price: usd(61000),
 	}
 	updatePro := &models.Product{
 		ID:    1,
 		Name:  "Gaming Laptop",
-		Price: 61000,
+		Price: usd(61000),
 	}
 	invalidPro := &models.Product{
 		ID:    1,
 		Name:  "",
-		Price: -61000,
+		Price: usd(-61000),
 	}
 	t.Run("valid product", func(t *testing.T) {
 		mockRepo.On("GetByID", 1).Return(product, nil)
 		mockRepo.On("Update", updatePro).Return(nil)
 
-		err := productService.UpdateProduct(updatePro)
+		err := productService.UpdateProduct(context.Background(), updatePro)
 		assert.NoError(t, err)
 		mockRepo.AssertExpectations(t)
 	})
@@ -159,10 +169,10 @@ func TestUpdateProduct(t *testing.T) {
 		mockRepo.ExpectedCalls = nil
 		mockRepo.On("GetByID", 90).Return(nil, errors.New("product not found"))
 
-		err := productService.UpdateProduct(&models.Product{
+		err := productService.UpdateProduct(context.Background(), &models.Product{
 			ID:    90,
 			Name:  "New Product",
-			Price: 1000,
+			Price: usd(1000),
 		})
 		assert.Error(t, err)
 		assert.Equal(t, "product not found", err.Error())
@@ -170,7 +180,7 @@ func TestUpdateProduct(t *testing.T) {
 	})
 	t.Run("Invalid product details", func(t *testing.T) {
 		mockRepo.ExpectedCalls = nil
-		err := productService.UpdateProduct(invalidPro)
+		err := productService.UpdateProduct(context.Background(), invalidPro)
 		assert.Error(t, err)
 		assert.Equal(t, "all fields are required", err.Error())
 		mockRepo.AssertNotCalled(t, "Update")
@@ -179,20 +189,181 @@ func TestUpdateProduct(t *testing.T) {
 
 func TestDeleteProduct(t *testing.T) {
 	mockRepo := new(MockProductRepo)
-	productService := NewProductService(mockRepo)
+	productService, _ := newTestProductService(mockRepo)
 
 	t.Run("Product deleted", func(t *testing.T) {
 		mockRepo.On("Delete", 1).Return(nil)
-		err := productService.DeleteProducts(1)
+		err := productService.DeleteProducts(context.Background(), 1)
 		assert.NoError(t, err)
 		mockRepo.AssertExpectations(t)
 	})
 
 	t.Run("Product not found", func(t *testing.T) {
 		mockRepo.On("Delete", 99).Return(errors.New("product not found"))
-		err := productService.DeleteProducts(99)
+		err := productService.DeleteProducts(context.Background(), 99)
 		assert.Error(t, err)
 		assert.Equal(t, "product not found", err.Error())
 		mockRepo.AssertExpectations(t)
 	})
 }
+
+type MockVariantRepo struct {
+	mock.Mock
+}
+
+func (m *MockVariantRepo) CreateOptionType(ctx context.Context, optionType *models.OptionType) error {
+	args := m.Called(optionType)
+	return args.Error(0)
+}
+
+func (m *MockVariantRepo) AddOptionValue(ctx context.Context, value *models.OptionValue) error {
+	args := m.Called(value)
+	return args.Error(0)
+}
+
+func (m *MockVariantRepo) GetOptionTypes(ctx context.Context) ([]models.OptionType, error) {
+	args := m.Called()
+	return args.Get(0).([]models.OptionType), args.Error(1)
+}
+
+func (m *MockVariantRepo) Create(ctx context.Context, variant *models.Variant) error {
+	args := m.Called(variant)
+	return args.Error(0)
+}
+
+func (m *MockVariantRepo) GetByID(ctx context.Context, id int) (*models.Variant, error) {
+	args := m.Called(id)
+	if variant := args.Get(0); variant != nil {
+		return variant.(*models.Variant), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockVariantRepo) GetBySKU(ctx context.Context, sku string) (*models.Variant, error) {
+	args := m.Called(sku)
+	if variant := args.Get(0); variant != nil {
+		return variant.(*models.Variant), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockVariantRepo) ListByProducts(ctx context.Context, productIDs []int) ([]models.Variant, error) {
+	args := m.Called(productIDs)
+	return args.Get(0).([]models.Variant), args.Error(1)
+}
+
+func (m *MockVariantRepo) Update(ctx context.Context, variant *models.Variant) error {
+	args := m.Called(variant)
+	return args.Error(0)
+}
+
+func (m *MockVariantRepo) Delete(ctx context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+// newTestProductService wires the product service to mocks, products have no variants unless the test says so
+func newTestProductService(productRepo *MockProductRepo) (ProductService, *MockVariantRepo) {
+	variantRepo := new(MockVariantRepo)
+	variantRepo.On("ListByProducts", mock.Anything).Return([]models.Variant(nil), nil).Maybe()
+	tx := inlineTx{repository.Repos{Products: productRepo, Variants: variantRepo}}
+	return NewProductService(productRepo, variantRepo, new(MockCategoryRepo), tx, "USD"), variantRepo
+}
+
+func usd(amount int64) models.Money { return models.NewMoney(amount, "USD") }
+
+func usdPtr(amount int64) *models.Money {
+	price := usd(amount)
+	return &price
+}
+
+func TestGetProductWithVariants(t *testing.T) {
+	productRepo := new(MockProductRepo)
+	variantRepo := new(MockVariantRepo)
+	productService := NewProductService(productRepo, variantRepo, new(MockCategoryRepo), inlineTx{}, "USD")
+
+	productRepo.On("GetAll").Return([]models.Product{{ID: 1, Name: "T-shirt", Price: usd(500)}, {ID: 2, Name: "Mug", Price: usd(200)}}, nil)
+	variantRepo.On("ListByProducts", []int{1, 2}).Return([]models.Variant{
+		{ID: 1, ProductID: 1, SKU: "TS-S-RED", Options: map[string]string{"size": "S", "color": "red"}},
+		{ID: 2, ProductID: 1, SKU: "TS-XL-RED", Price: usdPtr(550), Options: map[string]string{"size": "XL", "color": "red"}},
+	}, nil)
+
+	products, err := productService.GetAllProducts(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, products[0].Variants, 2)
+	assert.Empty(t, products[1].Variants)
+	assert.Equal(t, usd(550), products[0].Variants[1].EffectivePrice(&products[0]))
+	assert.Equal(t, usd(500), products[0].Variants[0].EffectivePrice(&products[0]))
+}
+
+func TestCreateVariant(t *testing.T) {
+	existing := models.Variant{ID: 1, ProductID: 1, SKU: "TS-S-RED", Options: map[string]string{"size": "S", "color": "red"}}
+
+	t.Run("Success", func(t *testing.T) {
+		productRepo := new(MockProductRepo)
+		productService, variantRepo := newTestProductService(productRepo)
+		productRepo.On("GetByID", 1).Return(&models.Product{ID: 1, Price: usd(500)}, nil)
+		variantRepo.On("Create", mock.Anything).Return(nil)
+
+		variant := &models.Variant{ProductID: 1, SKU: " TS-M-RED ", Options: map[string]string{"size": "M", "color": "red"}}
+		err := productService.CreateVariant(context.Background(), variant)
+		assert.NoError(t, err)
+		assert.Equal(t, "TS-M-RED", variant.SKU)
+	})
+	t.Run("Duplicate combination", func(t *testing.T) {
+		productRepo := new(MockProductRepo)
+		variantRepo := new(MockVariantRepo)
+		tx := inlineTx{repository.Repos{Products: productRepo, Variants: variantRepo}}
+		productService := NewProductService(productRepo, variantRepo, new(MockCategoryRepo), tx, "USD")
+		productRepo.On("GetByID", 1).Return(&models.Product{ID: 1, Price: usd(500)}, nil)
+		variantRepo.On("ListByProducts", []int{1}).Return([]models.Variant{existing}, nil)
+
+		err := productService.CreateVariant(context.Background(), &models.Variant{ProductID: 1, SKU: "OTHER", Options: map[string]string{"color": "red", "size": "S"}})
+		assert.ErrorIs(t, err, ErrInvalidVariant)
+		variantRepo.AssertNotCalled(t, "Create", mock.Anything)
+	})
+	t.Run("Unknown option", func(t *testing.T) {
+		productRepo := new(MockProductRepo)
+		productService, variantRepo := newTestProductService(productRepo)
+		productRepo.On("GetByID", 1).Return(&models.Product{ID: 1, Price: usd(500)}, nil)
+		variantRepo.On("Create", mock.Anything).Return(fmt.Errorf("%w size=XXXL", repository.ErrUnknownOption))
+
+		err := productService.CreateVariant(context.Background(), &models.Variant{ProductID: 1, SKU: "TS", Options: map[string]string{"size": "XXXL"}})
+		assert.ErrorIs(t, err, ErrInvalidVariant)
+	})
+	t.Run("Validation", func(t *testing.T) {
+		productService, _ := newTestProductService(new(MockProductRepo))
+		for _, variant := range []*models.Variant{
+			{ProductID: 1},
+			{ProductID: 1, SKU: "TS", Price: usdPtr(0)},
+			{ProductID: 1, SKU: "TS", Price: &models.Money{Amount: 550, Currency: "GBP"}},
+		} {
+			assert.ErrorIs(t, productService.CreateVariant(context.Background(), variant), ErrInvalidVariant)
+		}
+	})
+}
+
+func TestCreateOptionType(t *testing.T) {
+	productService, variantRepo := newTestProductService(new(MockProductRepo))
+	variantRepo.On("CreateOptionType", mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(0).(*models.OptionType).ID = 3
+	}).Return(nil)
+	variantRepo.On("AddOptionValue", mock.Anything).Return(nil)
+
+	optionType := &models.OptionType{Name: " Size ", Values: []models.OptionValue{{Value: "S"}, {Value: "M"}}}
+	err := productService.CreateOptionType(context.Background(), optionType)
+	assert.NoError(t, err)
+	assert.Equal(t, "size", optionType.Name)
+	assert.Equal(t, 3, optionType.Values[1].OptionTypeID)
+	variantRepo.AssertNumberOfCalls(t, "AddOptionValue", 2)
+}
+
+func TestDeleteVariant(t *testing.T) {
+	productService, variantRepo := newTestProductService(new(MockProductRepo))
+	variantRepo.On("GetBySKU", "TS-S-RED").Return(&models.Variant{ID: 4, SKU: "TS-S-RED"}, nil)
+	variantRepo.On("GetBySKU", "NOPE").Return(nil, ErrVariantNotFound)
+	variantRepo.On("Delete", 4).Return(nil)
+
+	assert.NoError(t, productService.DeleteVariant(context.Background(), "TS-S-RED"))
+	assert.Equal(t, ErrVariantNotFound, productService.DeleteVariant(context.Background(), "NOPE"))
+}
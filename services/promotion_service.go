@@ -0,0 +1,413 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPromotionNotFound   = repository.ErrPromotionNotFound
+	ErrPromotionExhausted  = repository.ErrPromotionExhausted
+	ErrInvalidPromotion    = errors.New("invalid promotion")
+	ErrCouponNotApplicable = errors.New("coupon does not apply")
+)
+
+// promotionRounding rounds percentage discounts to a whole minor unit
+const promotionRounding = models.RoundHalfUp
+
+// PromotionService manages discount rules and previews them on carts.
+// Checkout applies them for real, see orderService.Checkout.
+type PromotionService interface {
+	CreatePromotion(ctx context.Context, promotion *models.Promotion) error
+	GetPromotion(ctx context.Context, id int) (*models.Promotion, error)
+	GetPromotions(ctx context.Context) ([]models.Promotion, error)
+	UpdatePromotion(ctx context.Context, promotion *models.Promotion) error
+	DeletePromotion(ctx context.Context, id int) error
+	// EvaluateCart works out what the promotions and the coupon codes take off the owner's cart
+	EvaluateCart(ctx context.Context, owner models.CartOwner, codes []string) (*models.Cart, *models.PromotionResult, error)
+}
+
+type promotionService struct {
+	promotionRepo  repository.PromotionRepo
+	cartService    CartService
+	productService ProductService
+	txManager      repository.TxManager
+	currency       string // amounts of promotions are in it
+}
+
+func NewPromotionService(promotionRepo repository.PromotionRepo, cartService CartService, productService ProductService,
+	txManager repository.TxManager, currency string) PromotionService {
+	return &promotionService{
+		promotionRepo:  promotionRepo,
+		cartService:    cartService,
+		productService: productService,
+		txManager:      txManager,
+		currency:       currency,
+	}
+}
+
+func (s *promotionService) CreatePromotion(ctx context.Context, promotion *models.Promotion) error {
+	if err := s.validatePromotion(promotion); err != nil {
+		return err
+	}
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		return tx.Promotions.Create(ctx, promotion)
+	})
+	if repository.IsDuplicate(err) {
+		return fmt.Errorf("%w: coupon code %q is already in use", ErrInvalidPromotion, promotion.Code)
+	}
+	return err
+}
+
+func (s *promotionService) GetPromotion(ctx context.Context, id int) (*models.Promotion, error) {
+	return s.promotionRepo.GetByID(ctx, id)
+}
+
+func (s *promotionService) GetPromotions(ctx context.Context) ([]models.Promotion, error) {
+	return s.promotionRepo.List(ctx)
+}
+
+// UpdatePromotion replaces the promotion's rule, how often it was used is kept
+func (s *promotionService) UpdatePromotion(ctx context.Context, promotion *models.Promotion) error {
+	if err := s.validatePromotion(promotion); err != nil {
+		return err
+	}
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		existing, err := tx.Promotions.GetByID(ctx, promotion.ID)
+		if err != nil {
+			return err
+		}
+		promotion.UsageCount = existing.UsageCount
+		return tx.Promotions.Update(ctx, promotion)
+	})
+	if repository.IsDuplicate(err) {
+		return fmt.Errorf("%w: coupon code %q is already in use", ErrInvalidPromotion, promotion.Code)
+	}
+	return err
+}
+
+// DeletePromotion removes the promotion, orders keep their discount breakdown
+func (s *promotionService) DeletePromotion(ctx context.Context, id int) error {
+	return s.promotionRepo.Delete(ctx, id)
+}
+
+func (s *promotionService) EvaluateCart(ctx context.Context, owner models.CartOwner, codes []string) (*models.Cart, *models.PromotionResult, error) {
+	cart, err := s.cartService.GetCart(ctx, owner)
+	if err != nil {
+		return nil, nil, err
+	}
+	lines := make([]models.PromotionLine, len(cart.Items))
+	for i, item := range cart.Items {
+		lines[i] = models.PromotionLine{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity, UnitPrice: item.UnitPrice}
+	}
+	engine := promotionEngine{promotions: s.promotionRepo, productService: s.productService}
+	result, err := engine.evaluate(ctx, owner.UserID, codes, cart.Currency, lines)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cart, result, nil
+}
+
+// validatePromotion normalises the promotion and checks its rule is complete
+func (s *promotionService) validatePromotion(p *models.Promotion) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Code = strings.ToUpper(strings.TrimSpace(p.Code))
+	// amounts left out are zero in the store's currency
+	if p.Amount.Currency == "" && p.Amount.IsZero() {
+		p.Amount.Currency = s.currency
+	}
+	if p.MinSubtotal.Currency == "" && p.MinSubtotal.IsZero() {
+		p.MinSubtotal.Currency = s.currency
+	}
+
+	switch {
+	case p.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidPromotion)
+	case !p.Kind.Valid():
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidPromotion, p.Kind)
+	case p.Amount.Currency != s.currency || p.MinSubtotal.Currency != s.currency:
+		return fmt.Errorf("%w: amounts must be in %s", ErrInvalidPromotion, s.currency)
+	case p.MinSubtotal.IsNegative():
+		return fmt.Errorf("%w: minimum order value must not be negative", ErrInvalidPromotion)
+	case p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt):
+		return fmt.Errorf("%w: the promotion must end after it starts", ErrInvalidPromotion)
+	case p.UsageLimit < 0 || p.PerCustomerLimit < 0:
+		return fmt.Errorf("%w: usage limits must not be negative", ErrInvalidPromotion)
+	}
+
+	switch p.Kind {
+	case models.PromotionPercentage:
+		if p.Percent < 1 || p.Percent > 100 {
+			return fmt.Errorf("%w: percent must be between 1 and 100", ErrInvalidPromotion)
+		}
+	case models.PromotionFixed:
+		if !p.Amount.IsPositive() {
+			return fmt.Errorf("%w: amount must be greater than zero", ErrInvalidPromotion)
+		}
+	case models.PromotionBuyXGetY:
+		if p.BuyQuantity < 1 || p.GetQuantity < 1 {
+			return fmt.Errorf("%w: buy and get quantities must be at least 1", ErrInvalidPromotion)
+		}
+	}
+	return nil
+}
+
+// promotionEngine works out which promotions apply to a set of lines and
+// what each takes off which line. The cart preview and checkout share it,
+// checkout runs it on the repos of its transaction.
+type promotionEngine struct {
+	promotions     repository.PromotionRepo
+	productService ProductService
+}
+
+// evaluate applies the active promotions and the coupons named by codes to
+// the lines. A coupon that is unknown or doesn't apply fails the
+// evaluation, a promotion without a code that doesn't apply is skipped.
+func (e promotionEngine) evaluate(ctx context.Context, userID int, codes []string, currency string, lines []models.PromotionLine) (*models.PromotionResult, error) {
+	codes = normalizeCodes(codes)
+	promotions, err := e.promotions.ListApplicable(ctx, codes)
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, promotion := range promotions {
+		known[promotion.Code] = true
+	}
+	for _, code := range codes {
+		if !known[code] {
+			return nil, fmt.Errorf("%w: %q is not a valid coupon code", ErrCouponNotApplicable, code)
+		}
+	}
+
+	lines = append([]models.PromotionLine(nil), lines...)
+	if err := e.loadCategories(ctx, promotions, lines); err != nil {
+		return nil, err
+	}
+
+	// guests can't be told apart, per customer limits only hold for users
+	used := map[int]int{}
+	var limited []int
+	for _, promotion := range promotions {
+		if promotion.PerCustomerLimit > 0 {
+			limited = append(limited, promotion.ID)
+		}
+	}
+	if userID != 0 && len(limited) > 0 {
+		if used, err = e.promotions.CountRedemptions(ctx, userID, limited); err != nil {
+			return nil, err
+		}
+	}
+	return applyPromotions(promotions, lines, currency, used, time.Now())
+}
+
+// loadCategories fills in the categories of the lines when a promotion targets categories
+func (e promotionEngine) loadCategories(ctx context.Context, promotions []models.Promotion, lines []models.PromotionLine) error {
+	needed := false
+	for _, promotion := range promotions {
+		needed = needed || len(promotion.CategoryIDs) > 0
+	}
+	if !needed || len(lines) == 0 {
+		return nil
+	}
+
+	var productIDs []int
+	seen := map[int]bool{}
+	for _, line := range lines {
+		if !seen[line.ProductID] {
+			seen[line.ProductID] = true
+			productIDs = append(productIDs, line.ProductID)
+		}
+	}
+	categoryIDs, err := e.productService.GetProductCategoryIDs(ctx, productIDs)
+	if err != nil {
+		return err
+	}
+	for i := range lines {
+		lines[i].CategoryIDs = categoryIDs[lines[i].ProductID]
+	}
+	return nil
+}
+
+// applyPromotions runs the promotions over the lines in order, each taking
+// its share off what the ones before it left of every line. used counts
+// the customer's earlier redemptions per promotion.
+//
+// Stacking: a promotion that isn't stackable only applies on its own. It is
+// skipped once another promotion applied, and nothing applies after it.
+func applyPromotions(promotions []models.Promotion, lines []models.PromotionLine, currency string, used map[int]int, now time.Time) (*models.PromotionResult, error) {
+	result := &models.PromotionResult{Subtotal: models.Money{Currency: currency}, Discount: models.Money{Currency: currency}}
+	remaining := make([]int64, len(lines))
+	for i, line := range lines {
+		lineTotal, err := line.LineTotal()
+		if err != nil {
+			return nil, err
+		}
+		if result.Subtotal, err = result.Subtotal.Add(lineTotal); err != nil {
+			return nil, err
+		}
+		remaining[i] = lineTotal.Amount
+	}
+
+	var exclusive *models.Promotion
+	applied := 0
+	for i := range promotions {
+		p := &promotions[i]
+		reason := ""
+		switch {
+		case p.StartsAt != nil && now.Before(*p.StartsAt):
+			reason = "has not started yet"
+		case !p.RunsAt(now):
+			reason = "has expired"
+		case p.UsageLimit > 0 && p.UsageCount >= p.UsageLimit:
+			reason = "has been used up"
+		case p.PerCustomerLimit > 0 && used[p.ID] >= p.PerCustomerLimit:
+			reason = "was already used as often as allowed"
+		case p.MinSubtotal.Currency != currency:
+			reason = fmt.Sprintf("only applies to orders in %s", p.MinSubtotal.Currency)
+		case result.Subtotal.Amount < p.MinSubtotal.Amount:
+			reason = fmt.Sprintf("needs an order of at least %s", p.MinSubtotal)
+		case exclusive != nil:
+			reason = fmt.Sprintf("can't be combined with %s", exclusive.Name)
+		case !p.Stackable && applied > 0:
+			reason = "can't be combined with other promotions"
+		}
+
+		var discounts []models.Discount
+		if reason == "" {
+			off, err := lineDiscounts(p, lines, remaining, currency)
+			if err != nil {
+				return nil, err
+			}
+			for line, amount := range off {
+				if amount > 0 {
+					discounts = append(discounts, models.Discount{PromotionID: p.ID, Name: p.Name, Code: p.Code, Kind: p.Kind, Line: line,
+						Amount: models.Money{Amount: amount, Currency: currency}})
+				}
+			}
+			if p.Kind == models.PromotionFreeShipping {
+				discounts = append(discounts, models.Discount{PromotionID: p.ID, Name: p.Name, Code: p.Code, Kind: p.Kind, Line: -1,
+					Amount: models.Money{Currency: currency}})
+			}
+			if len(discounts) == 0 {
+				reason = "doesn't apply to anything in the cart"
+			}
+		}
+		if reason != "" {
+			if p.Coupon() {
+				return nil, fmt.Errorf("%w: %s %s", ErrCouponNotApplicable, p.Code, reason)
+			}
+			continue
+		}
+
+		for _, discount := range discounts {
+			// lineDiscounts never takes more than is left of a line
+			if discount.Line >= 0 {
+				remaining[discount.Line] -= discount.Amount.Amount
+			}
+			var err error
+			if result.Discount, err = result.Discount.Add(discount.Amount); err != nil {
+				return nil, err
+			}
+		}
+		result.Discounts = append(result.Discounts, discounts...)
+		result.FreeShipping = result.FreeShipping || p.Kind == models.PromotionFreeShipping
+		applied++
+		if !p.Stackable {
+			exclusive = p
+		}
+	}
+	return result, nil
+}
+
+// lineDiscounts returns what the promotion takes off each line, never more
+// than is left of the line
+func lineDiscounts(p *models.Promotion, lines []models.PromotionLine, remaining []int64, currency string) ([]int64, error) {
+	off := make([]int64, len(lines))
+	var eligible []int
+	for i, line := range lines {
+		if remaining[i] > 0 && p.Targets(line.ProductID, line.CategoryIDs) {
+			eligible = append(eligible, i)
+		}
+	}
+	if len(eligible) == 0 {
+		return off, nil
+	}
+
+	switch p.Kind {
+	case models.PromotionPercentage, models.PromotionFixed:
+		// the discount is worked out on the eligible lines together and
+		// spread over them in proportion, so rounding happens only once
+		base := models.Money{Currency: currency}
+		weights := make([]int64, len(eligible))
+		for j, i := range eligible {
+			var err error
+			if base, err = base.Add(models.Money{Amount: remaining[i], Currency: currency}); err != nil {
+				return nil, err
+			}
+			weights[j] = remaining[i]
+		}
+		discount := p.Amount
+		if p.Kind == models.PromotionPercentage {
+			var err error
+			if discount, err = base.MulRatio(int64(p.Percent), 100, promotionRounding); err != nil {
+				return nil, err
+			}
+		} else if discount.Amount > base.Amount {
+			discount = base
+		}
+		shares, err := discount.Allocate(weights...)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range eligible {
+			off[i] = shares[j].Amount
+		}
+
+	case models.PromotionBuyXGetY:
+		// of every group of BuyQuantity+GetQuantity units, most expensive
+		// first, the last GetQuantity are free
+		type unit struct {
+			line  int
+			price int64
+		}
+		var units []unit
+		for _, i := range eligible {
+			for n := 0; n < lines[i].Quantity; n++ {
+				units = append(units, unit{line: i, price: lines[i].UnitPrice.Amount})
+			}
+		}
+		sort.SliceStable(units, func(a, b int) bool { return units[a].price > units[b].price })
+		group := p.BuyQuantity + p.GetQuantity
+		for n, u := range units {
+			if n%group >= p.BuyQuantity {
+				off[u.line] += u.price
+			}
+		}
+		for _, i := range eligible {
+			if off[i] > remaining[i] {
+				off[i] = remaining[i]
+			}
+		}
+	}
+	return off, nil
+}
+
+// normalizeCodes upper cases the coupon codes and drops blanks and repeats
+func normalizeCodes(codes []string) []string {
+	var normalized []string
+	seen := map[string]bool{}
+	for _, code := range codes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code != "" && !seen[code] {
+			seen[code] = true
+			normalized = append(normalized, code)
+		}
+	}
+	return normalized
+}
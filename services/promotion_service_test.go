@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPromotionRepo struct {
+	mock.Mock
+}
+
+func (m *MockPromotionRepo) Create(ctx context.Context, promotion *models.Promotion) error {
+	args := m.Called(promotion)
+	return args.Error(0)
+}
+
+func (m *MockPromotionRepo) GetByID(ctx context.Context, id int) (*models.Promotion, error) {
+	args := m.Called(id)
+	if promotion := args.Get(0); promotion != nil {
+		return promotion.(*models.Promotion), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPromotionRepo) List(ctx context.Context) ([]models.Promotion, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Promotion), args.Error(1)
+}
+
+func (m *MockPromotionRepo) ListApplicable(ctx context.Context, codes []string) ([]models.Promotion, error) {
+	args := m.Called(codes)
+	return args.Get(0).([]models.Promotion), args.Error(1)
+}
+
+func (m *MockPromotionRepo) Update(ctx context.Context, promotion *models.Promotion) error {
+	args := m.Called(promotion)
+	return args.Error(0)
+}
+
+func (m *MockPromotionRepo) Delete(ctx context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockPromotionRepo) Redeem(ctx context.Context, redemption *models.PromotionRedemption) error {
+	args := m.Called(redemption)
+	return args.Error(0)
+}
+
+func (m *MockPromotionRepo) ReleaseRedemptions(ctx context.Context, orderID int) error {
+	args := m.Called(orderID)
+	return args.Error(0)
+}
+
+func (m *MockPromotionRepo) CountRedemptions(ctx context.Context, userID int, promotionIDs []int) (map[int]int, error) {
+	args := m.Called(userID, promotionIDs)
+	return args.Get(0).(map[int]int), args.Error(1)
+}
+
+func TestApplyPromotions(t *testing.T) {
+	now := time.Now()
+	yesterday := now.Add(-24 * time.Hour)
+	lines := []models.PromotionLine{
+		{ProductID: 1, Quantity: 2, UnitPrice: usd(1000), CategoryIDs: []int{5}},
+		{ProductID: 2, Quantity: 1, UnitPrice: usd(2001)},
+		{ProductID: 3, Quantity: 3, UnitPrice: usd(500), CategoryIDs: []int{5, 6}},
+	}
+	promotion := func(p models.Promotion) models.Promotion {
+		p.ID, p.Name, p.Active = 1, string(p.Kind), true
+		p.MinSubtotal.Currency = "USD"
+		return p
+	}
+
+	t.Run("Percentage over the eligible lines", func(t *testing.T) {
+		result, err := applyPromotions([]models.Promotion{
+			promotion(models.Promotion{Kind: models.PromotionPercentage, Percent: 15, ProductIDs: []int{2}, CategoryIDs: []int{5}}),
+		}, lines, "USD", nil, now)
+		assert.NoError(t, err)
+		// 15% of 55.01 is 8.2515, rounded once and spread by line value
+		assert.Equal(t, usd(825), result.Discount)
+		total, err := result.Total()
+		assert.NoError(t, err)
+		assert.Equal(t, usd(4676), total)
+		assert.Equal(t, []int{0, 1, 2}, []int{result.Discounts[0].Line, result.Discounts[1].Line, result.Discounts[2].Line})
+		assert.Equal(t, usd(300), result.Discounts[0].Amount)
+		assert.Equal(t, usd(301), result.Discounts[1].Amount)
+		assert.Equal(t, usd(224), result.Discounts[2].Amount)
+	})
+	t.Run("Fixed amount is capped by the lines", func(t *testing.T) {
+		result, err := applyPromotions([]models.Promotion{
+			promotion(models.Promotion{Kind: models.PromotionFixed, Amount: usd(5000), ProductIDs: []int{3}}),
+		}, lines, "USD", nil, now)
+		assert.NoError(t, err)
+		assert.Equal(t, usd(1500), result.Discount)
+		assert.Equal(t, 2, result.Discounts[0].Line)
+	})
+	t.Run("Buy two get one takes the cheapest", func(t *testing.T) {
+		result, err := applyPromotions([]models.Promotion{
+			promotion(models.Promotion{Kind: models.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, CategoryIDs: []int{5}}),
+		}, lines, "USD", nil, now)
+		assert.NoError(t, err)
+		// 10.00 10.00 5.00 | 5.00 5.00: the third unit of the first group is free
+		assert.Equal(t, usd(500), result.Discount)
+		assert.Equal(t, 2, result.Discounts[0].Line)
+	})
+	t.Run("Free shipping", func(t *testing.T) {
+		result, err := applyPromotions([]models.Promotion{
+			promotion(models.Promotion{Kind: models.PromotionFreeShipping, MinSubtotal: usd(5000)}),
+		}, lines, "USD", nil, now)
+		assert.NoError(t, err)
+		assert.True(t, result.FreeShipping)
+		assert.True(t, result.Discount.IsZero())
+		assert.Equal(t, -1, result.Discounts[0].Line)
+	})
+	t.Run("Stacking", func(t *testing.T) {
+		sale := promotion(models.Promotion{Kind: models.PromotionPercentage, Percent: 50, Priority: 10})
+		extra := promotion(models.Promotion{Kind: models.PromotionFixed, Amount: usd(1000), Stackable: true})
+		extra.ID = 2
+
+		result, err := applyPromotions([]models.Promotion{sale, extra}, lines, "USD", nil, now)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1}, result.PromotionIDs(), "the sale doesn't stack")
+
+		sale.Stackable = true
+		result, err = applyPromotions([]models.Promotion{sale, extra}, lines, "USD", nil, now)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 2}, result.PromotionIDs())
+		// the fixed amount comes off what the sale left
+		assert.Equal(t, usd(2751+1000), result.Discount)
+	})
+	t.Run("Skipped promotions", func(t *testing.T) {
+		limited := promotion(models.Promotion{Kind: models.PromotionPercentage, Percent: 10, PerCustomerLimit: 1})
+		expired := promotion(models.Promotion{Kind: models.PromotionPercentage, Percent: 10, EndsAt: &yesterday})
+		expensive := promotion(models.Promotion{Kind: models.PromotionPercentage, Percent: 10, MinSubtotal: usd(10000)})
+		untargeted := promotion(models.Promotion{Kind: models.PromotionPercentage, Percent: 10, ProductIDs: []int{9}})
+
+		result, err := applyPromotions([]models.Promotion{limited, expired, expensive, untargeted}, lines, "USD", map[int]int{1: 1}, now)
+		assert.NoError(t, err)
+		assert.Empty(t, result.Discounts)
+		total, err := result.Total()
+		assert.NoError(t, err)
+		assert.Equal(t, usd(5501), total)
+	})
+	t.Run("Coupon that doesn't apply", func(t *testing.T) {
+		coupon := promotion(models.Promotion{Kind: models.PromotionFixed, Code: "BIG", Amount: usd(500), MinSubtotal: usd(10000)})
+
+		_, err := applyPromotions([]models.Promotion{coupon}, lines, "USD", nil, now)
+		assert.ErrorIs(t, err, ErrCouponNotApplicable)
+		assert.Contains(t, err.Error(), "at least 100.00 USD")
+	})
+}
+
+func TestEvaluateCart(t *testing.T) {
+	promotionRepo, cartRepo, categoryRepo := new(MockPromotionRepo), new(MockCartRepo), new(MockCategoryRepo)
+	cartService := NewCartService(cartRepo, inlineTx{}, "USD")
+	productService := NewProductService(new(MockProductRepo), new(MockVariantRepo), categoryRepo, inlineTx{}, "USD")
+	promotionService := NewPromotionService(promotionRepo, cartService, productService, inlineTx{}, "USD")
+
+	cartRepo.On("GetByUser", 7).Return(&models.Cart{ID: 3, UserID: 7, Currency: "USD"}, nil)
+	cartRepo.On("ListItems", 3).Return([]models.CartItem{
+		{ID: 8, ProductID: 1, Quantity: 1, UnitPrice: usd(2000)},
+		{ID: 9, ProductID: 2, Quantity: 1, UnitPrice: usd(1000)},
+	}, nil)
+	shoes := models.Promotion{ID: 4, Name: "Shoes", Kind: models.PromotionPercentage, Percent: 20, CategoryIDs: []int{6}, MinSubtotal: usd(0), Active: true}
+
+	t.Run("Category promotion", func(t *testing.T) {
+		promotionRepo.On("ListApplicable", []string(nil)).Return([]models.Promotion{shoes}, nil).Once()
+		categoryRepo.On("ProductCategoryIDs", []int{1, 2}).Return(map[int][]int{2: {3, 6}}, nil).Once()
+
+		cart, result, err := promotionService.EvaluateCart(context.Background(), models.CartOwner{UserID: 7}, nil)
+		assert.NoError(t, err)
+		assert.Len(t, cart.Items, 2)
+		assert.Equal(t, usd(200), result.Discount)
+		assert.Equal(t, 1, result.Discounts[0].Line)
+	})
+	t.Run("Unknown coupon", func(t *testing.T) {
+		promotionRepo.On("ListApplicable", []string{"NOPE"}).Return([]models.Promotion(nil), nil).Once()
+
+		_, _, err := promotionService.EvaluateCart(context.Background(), models.CartOwner{UserID: 7}, []string{"nope", "NOPE"})
+		assert.ErrorIs(t, err, ErrCouponNotApplicable)
+	})
+	promotionRepo.AssertExpectations(t)
+}
+
+func TestCreatePromotion(t *testing.T) {
+	promotionRepo := new(MockPromotionRepo)
+	promotionService := NewPromotionService(promotionRepo, nil, nil, inlineTx{repository.Repos{Promotions: promotionRepo}}, "USD")
+
+	t.Run("Success", func(t *testing.T) {
+		promotionRepo.On("Create", mock.MatchedBy(func(p *models.Promotion) bool {
+			return p.Code == "WELCOME" && p.Amount == usd(500) && p.MinSubtotal == usd(0)
+		})).Return(nil).Once()
+
+		promotion := &models.Promotion{Name: "Welcome", Code: " welcome ", Kind: models.PromotionFixed, Amount: usd(500)}
+		assert.NoError(t, promotionService.CreatePromotion(context.Background(), promotion))
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		starts := time.Now()
+		for name, promotion := range map[string]models.Promotion{
+			"No name":         {Kind: models.PromotionFreeShipping},
+			"Unknown kind":    {Name: "Sale", Kind: "bogo"},
+			"Percent":         {Name: "Sale", Kind: models.PromotionPercentage, Percent: 120},
+			"No amount":       {Name: "Sale", Kind: models.PromotionFixed},
+			"Other currency":  {Name: "Sale", Kind: models.PromotionFixed, Amount: models.NewMoney(500, "EUR")},
+			"Buy nothing":     {Name: "Sale", Kind: models.PromotionBuyXGetY, GetQuantity: 1},
+			"Ends too early":  {Name: "Sale", Kind: models.PromotionFreeShipping, StartsAt: &starts, EndsAt: &starts},
+			"Negative limit":  {Name: "Sale", Kind: models.PromotionFreeShipping, UsageLimit: -1},
+			"Negative amount": {Name: "Sale", Kind: models.PromotionFreeShipping, MinSubtotal: usd(-1)},
+		} {
+			err := promotionService.CreatePromotion(context.Background(), &promotion)
+			assert.ErrorIs(t, err, ErrInvalidPromotion, name)
+		}
+	})
+	promotionRepo.AssertExpectations(t)
+}
@@ -0,0 +1,473 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrReturnNotFound          = repository.ErrReturnNotFound
+	ErrInvalidReturn           = errors.New("invalid return")
+	ErrInvalidReturnStatus     = errors.New("invalid return status")
+	ErrInvalidReturnTransition = errors.New("invalid return status transition")
+)
+
+const (
+	defaultReturnsLimit = 50
+	maxReturnsLimit     = 500
+	maxReturnReasonLen  = 255
+)
+
+// ReturnService handles returns (RMAs): customers ask to send back lines of
+// a delivered order, staff approve or reject, receive the goods and refund
+type ReturnService interface {
+	RequestReturn(ctx context.Context, ret *models.Return) (*models.Return, error)
+	GetReturn(ctx context.Context, id int) (*models.Return, error)
+	GetUserReturn(ctx context.Context, userID, id int) (*models.Return, error)
+	GetReturns(ctx context.Context, filter models.ReturnFilter) ([]models.Return, error)
+	ApproveReturn(ctx context.Context, id int, note string) (*models.Return, error)
+	RejectReturn(ctx context.Context, id int, note string) (*models.Return, error)
+	ReceiveReturn(ctx context.Context, receipt *models.ReturnReceipt) (*models.Return, error)
+	RefundReturn(ctx context.Context, id int, amount models.Money, actorID int) (*models.Return, error)
+}
+
+type returnService struct {
+	returnRepo  repository.ReturnRepo
+	paymentRepo repository.PaymentRepo
+	gateway     PaymentGateway
+	txManager   repository.TxManager
+}
+
+func NewReturnService(returnRepo repository.ReturnRepo, paymentRepo repository.PaymentRepo, gateway PaymentGateway,
+	txManager repository.TxManager) ReturnService {
+	return &returnService{returnRepo: returnRepo, paymentRepo: paymentRepo, gateway: gateway, txManager: txManager}
+}
+
+// RequestReturn opens a return for lines of one of the user's delivered
+// orders. A line can't be returned more often than it was bought, counting
+// the returns that weren't rejected.
+func (s *returnService) RequestReturn(ctx context.Context, ret *models.Return) (*models.Return, error) {
+	ret.Reason = strings.TrimSpace(ret.Reason)
+	switch {
+	case ret.Reason == "":
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidReturn)
+	case len(ret.Reason) > maxReturnReasonLen:
+		return nil, fmt.Errorf("%w: reason must be at most %d characters", ErrInvalidReturn, maxReturnReasonLen)
+	case len(ret.Items) == 0:
+		return nil, fmt.Errorf("%w: at least one item is required", ErrInvalidReturn)
+	}
+	seen := make(map[int]bool, len(ret.Items))
+	for _, item := range ret.Items {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: quantity must be greater than zero", ErrInvalidReturn)
+		}
+		if seen[item.OrderItemID] {
+			return nil, fmt.Errorf("%w: order item %d is listed twice", ErrInvalidReturn, item.OrderItemID)
+		}
+		seen[item.OrderItemID] = true
+	}
+
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		order, err := tx.Orders.GetByID(ctx, ret.OrderID)
+		if err != nil {
+			return err
+		}
+		if order.UserID != ret.UserID {
+			return ErrOrderNotFound
+		}
+		if order.Status != models.OrderDelivered {
+			return fmt.Errorf("%w: only delivered orders can be returned, the order is %s", ErrInvalidReturn, order.Status)
+		}
+		ret.Currency = order.Currency
+		orderItems, err := tx.Orders.ListItems(ctx, []int{order.ID})
+		if err != nil {
+			return err
+		}
+		byID := make(map[int]models.OrderItem, len(orderItems))
+		for _, item := range orderItems {
+			byID[item.ID] = item
+		}
+		discounts, err := tx.Orders.ListDiscounts(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		lineDiscounts := make(map[int]int64)
+		for _, discount := range discounts {
+			if discount.OrderItemID != nil {
+				lineDiscounts[*discount.OrderItemID] += discount.Amount.Amount
+			}
+		}
+		returned, err := tx.Returns.ReturnedQuantities(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+
+		for i := range ret.Items {
+			item := &ret.Items[i]
+			orderItem, ok := byID[item.OrderItemID]
+			if !ok {
+				return fmt.Errorf("%w: item %d is not part of order %d", ErrInvalidReturn, item.OrderItemID, order.ID)
+			}
+			if left := orderItem.Quantity - returned[orderItem.ID]; item.Quantity > left {
+				return fmt.Errorf("%w: %d of %s can still be returned", ErrInvalidReturn, left, orderItem.Name)
+			}
+			item.ProductID, item.VariantID = orderItem.ProductID, orderItem.VariantID
+			item.SKU, item.Name, item.UnitPrice = orderItem.SKU, orderItem.Name, orderItem.UnitPrice
+			item.LineQuantity, item.LineReturned = orderItem.Quantity, returned[orderItem.ID]
+			item.LineDiscount = models.Money{Amount: lineDiscounts[orderItem.ID], Currency: orderItem.UnitPrice.Currency}
+			item.LineTax = models.Money{Currency: orderItem.UnitPrice.Currency}
+			if !order.TaxInclusive {
+				item.LineTax = orderItem.Tax
+			}
+		}
+
+		ret.Status = models.ReturnRequested
+		if err := tx.Returns.Create(ctx, ret); err != nil {
+			return err
+		}
+		for i := range ret.Items {
+			ret.Items[i].ReturnID = ret.ID
+			if err := tx.Returns.AddItem(ctx, &ret.Items[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetReturn returns the return with its items
+func (s *returnService) GetReturn(ctx context.Context, id int) (*models.Return, error) {
+	ret, err := s.returnRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ret.Items, err = s.returnRepo.ListItems(ctx, []int{ret.ID}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetUserReturn is GetReturn for customers, other users' returns are not found
+func (s *returnService) GetUserReturn(ctx context.Context, userID, id int) (*models.Return, error) {
+	ret, err := s.GetReturn(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ret.UserID != userID {
+		return nil, ErrReturnNotFound
+	}
+	return ret, nil
+}
+
+// GetReturns lists returns newest first with their items
+func (s *returnService) GetReturns(ctx context.Context, filter models.ReturnFilter) ([]models.Return, error) {
+	if filter.Status != "" && !filter.Status.Valid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidReturnStatus, filter.Status)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultReturnsLimit
+	}
+	if filter.Limit > maxReturnsLimit {
+		filter.Limit = maxReturnsLimit
+	}
+
+	returns, err := s.returnRepo.List(ctx, filter)
+	if err != nil || len(returns) == 0 {
+		return returns, err
+	}
+	ids := make([]int, len(returns))
+	index := make(map[int]int, len(returns))
+	for i, ret := range returns {
+		ids[i] = ret.ID
+		index[ret.ID] = i
+	}
+	items, err := s.returnRepo.ListItems(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		i := index[item.ReturnID]
+		returns[i].Items = append(returns[i].Items, item)
+	}
+	return returns, nil
+}
+
+func (s *returnService) ApproveReturn(ctx context.Context, id int, note string) (*models.Return, error) {
+	return s.transition(ctx, id, models.ReturnApproved, func(tx repository.Repos, ret *models.Return) error {
+		ret.Note = strings.TrimSpace(note)
+		return nil
+	})
+}
+
+func (s *returnService) RejectReturn(ctx context.Context, id int, note string) (*models.Return, error) {
+	return s.transition(ctx, id, models.ReturnRejected, func(tx repository.Repos, ret *models.Return) error {
+		ret.Note = strings.TrimSpace(note)
+		return nil
+	})
+}
+
+// ReceiveReturn books the goods of an approved return in. Restocked items
+// are added to the warehouse's stock and show up in the ledger under the
+// return's reference.
+func (s *returnService) ReceiveReturn(ctx context.Context, receipt *models.ReturnReceipt) (*models.Return, error) {
+	if len(receipt.Restock) > 0 && receipt.WarehouseID == 0 {
+		return nil, fmt.Errorf("%w: warehouse is required to restock", ErrInvalidReturn)
+	}
+	return s.transition(ctx, receipt.ReturnID, models.ReturnReceived, func(tx repository.Repos, ret *models.Return) error {
+		byID := make(map[int]*models.ReturnItem, len(ret.Items))
+		for i := range ret.Items {
+			byID[ret.Items[i].ID] = &ret.Items[i]
+		}
+		for _, itemID := range receipt.Restock {
+			item, ok := byID[itemID]
+			switch {
+			case !ok:
+				return fmt.Errorf("%w: item %d is not part of return %d", ErrInvalidReturn, itemID, ret.ID)
+			case item.Restocked:
+				continue
+			case item.ProductID == nil:
+				return fmt.Errorf("%w: %s is no longer in the catalogue and can't be restocked", ErrInvalidReturn, item.Name)
+			}
+			current, err := tx.Inventory.EnsureItem(ctx, receipt.WarehouseID, *item.ProductID, item.VariantID)
+			if err != nil {
+				return err
+			}
+			stock, err := tx.Inventory.AdjustOnHand(ctx, current.ID, item.Quantity)
+			if err != nil {
+				return err
+			}
+			adjustment := &models.InventoryAdjustment{
+				ItemID:      stock.ID,
+				Delta:       item.Quantity,
+				OnHandAfter: stock.OnHand,
+				Reason:      models.ReasonReturn,
+				Reference:   ret.Reference(),
+				Note:        strings.TrimSpace(receipt.Note),
+				CreatedBy:   receipt.ReceivedBy,
+			}
+			if err := tx.Inventory.AddAdjustment(ctx, adjustment); err != nil {
+				return err
+			}
+			if err := tx.Returns.SetRestocked(ctx, item.ID); err != nil {
+				return err
+			}
+			item.Restocked = true
+		}
+		if note := strings.TrimSpace(receipt.Note); note != "" {
+			ret.Note = note
+		}
+		return nil
+	})
+}
+
+// RefundReturn refunds amount for the return through the order's payments,
+// 0 refunds the full value of the returned lines. Less than that can be
+// refunded, e.g. to keep a restocking fee, but only once per return. The
+// refunds are reserved as the return becomes refunded, then sent to the
+// gateway one by one and each settled in a transaction of its own. What the
+// gateway rejects is taken off the return's refund amount.
+//
+// Refunds of the order's payments left pending, e.g. by a gateway timeout,
+// are sent again first. So a refunded return with refunds still pending is
+// retried by refunding it again, amount doesn't matter then.
+func (s *returnService) RefundReturn(ctx context.Context, id int, amount models.Money, actorID int) (*models.Return, error) {
+	if amount.IsNegative() {
+		return nil, fmt.Errorf("%w: refund amount must not be negative", ErrInvalidReturn)
+	}
+	ret, err := s.returnRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := s.pendingRefunds(ctx, ret.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sendRefunds(ctx, pending, actorID); err != nil {
+		return nil, err
+	}
+	if ret.Status == models.ReturnRefunded {
+		for _, reserved := range pending {
+			if reserved.refund.ReturnID != nil && *reserved.refund.ReturnID == id {
+				return s.GetReturn(ctx, id)
+			}
+		}
+	}
+
+	var refunds []reservedRefund
+	ret, err = s.transition(ctx, id, models.ReturnRefunded, func(tx repository.Repos, ret *models.Return) error {
+		refunds = nil
+		value, err := ret.Value()
+		if err != nil {
+			return err
+		}
+		toRefund := amount
+		if toRefund.IsZero() {
+			toRefund = value
+		}
+		if !toRefund.SameCurrency(value) {
+			return fmt.Errorf("%w: the return is refunded in %s", ErrInvalidReturn, value.Currency)
+		}
+		if toRefund.Amount > value.Amount {
+			return fmt.Errorf("%w: at most %s can be refunded for the return", ErrInvalidReturn, value)
+		}
+
+		payments, err := tx.Payments.ListByOrder(ctx, ret.OrderID)
+		if err != nil {
+			return err
+		}
+		// lock them all before checking, so nothing is refunded unless all of it can be
+		refundable := models.Money{Currency: ret.Currency}
+		parts := make([]models.Money, len(payments))
+		for i := range payments {
+			locked, err := tx.Payments.Lock(ctx, payments[i].ID)
+			if err != nil {
+				return err
+			}
+			payments[i] = *locked
+			if parts[i], err = refundableOf(ctx, tx, locked); err != nil {
+				return err
+			}
+			if refundable, err = refundable.Add(parts[i]); err != nil {
+				return err
+			}
+		}
+		if toRefund.Amount > refundable.Amount {
+			return fmt.Errorf("%w: only %s of the order's payments can still be refunded", ErrInvalidReturn, refundable)
+		}
+
+		left := toRefund
+		for i := range payments {
+			part := parts[i]
+			if part.Amount > left.Amount {
+				part = left
+			}
+			if !part.IsPositive() {
+				continue
+			}
+			refund, err := reserveRefund(ctx, tx, &payments[i], part, &ret.ID)
+			if err != nil {
+				return err
+			}
+			refunds = append(refunds, reservedRefund{reference: payments[i].Reference, refund: refund})
+			if left, err = left.Sub(part); err != nil {
+				return err
+			}
+		}
+		ret.RefundAmount = toRefund
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sendRefunds(ctx, refunds, actorID); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// pendingRefunds lists the refunds of the order's payments that were never
+// settled, with the references of their payments
+func (s *returnService) pendingRefunds(ctx context.Context, orderID int) ([]reservedRefund, error) {
+	payments, err := s.paymentRepo.ListByOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	var pending []reservedRefund
+	for _, payment := range payments {
+		refunds, err := s.paymentRepo.ListRefunds(ctx, payment.ID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range refunds {
+			if refunds[i].Status == models.RefundPending {
+				pending = append(pending, reservedRefund{reference: payment.Reference, refund: &refunds[i]})
+			}
+		}
+	}
+	return pending, nil
+}
+
+// sendRefunds sends reserved refunds to the gateway and returns the first
+// error. It keeps going after a failure, the other refunds are reserved
+// already. What the gateway rejects is taken off the refund amount of the
+// return it was made for.
+func (s *returnService) sendRefunds(ctx context.Context, refunds []reservedRefund, actorID int) error {
+	var refundErr error
+	var returnIDs []int
+	rejected := make(map[int]models.Money)
+	for _, reserved := range refunds {
+		_, err := sendRefund(ctx, s.txManager, s.gateway, reserved.reference, reserved.refund, actorID)
+		if err != nil && refundErr == nil {
+			refundErr = err
+		}
+		if !errors.Is(err, ErrGatewayRejected) || reserved.refund.ReturnID == nil {
+			continue
+		}
+		returnID := *reserved.refund.ReturnID
+		total, ok := rejected[returnID]
+		if !ok {
+			returnIDs = append(returnIDs, returnID)
+			total = models.Money{Currency: reserved.refund.Amount.Currency}
+		}
+		if rejected[returnID], err = total.Add(reserved.refund.Amount); err != nil {
+			return err
+		}
+	}
+
+	for _, returnID := range returnIDs {
+		err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+			locked, err := tx.Returns.Lock(ctx, returnID)
+			if err != nil {
+				return err
+			}
+			if locked.RefundAmount, err = locked.RefundAmount.Sub(rejected[returnID]); err != nil {
+				return err
+			}
+			return tx.Returns.Update(ctx, locked)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return refundErr
+}
+
+// reservedRefund is a pending refund waiting to be sent to the gateway
+type reservedRefund struct {
+	reference string // of the payment
+	refund    *models.Refund
+}
+
+// transition moves a locked return to status to after apply made its
+// changes, and returns it as stored
+func (s *returnService) transition(ctx context.Context, id int, to models.ReturnStatus, apply func(tx repository.Repos, ret *models.Return) error) (*models.Return, error) {
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		ret, err := tx.Returns.Lock(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !ret.Status.CanTransitionTo(to) {
+			return fmt.Errorf("%w: a %s return can't become %s", ErrInvalidReturnTransition, ret.Status, to)
+		}
+		if ret.Items, err = tx.Returns.ListItems(ctx, []int{ret.ID}); err != nil {
+			return err
+		}
+		if err := apply(tx, ret); err != nil {
+			return err
+		}
+		ret.Status = to
+		return tx.Returns.Update(ctx, ret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetReturn(ctx, id)
+}
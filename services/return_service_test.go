@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReturnRepo struct {
+	mock.Mock
+}
+
+func (m *MockReturnRepo) Create(ctx context.Context, ret *models.Return) error {
+	args := m.Called(ret)
+	if args.Error(0) == nil {
+		ret.ID = 60
+	}
+	return args.Error(0)
+}
+
+func (m *MockReturnRepo) AddItem(ctx context.Context, item *models.ReturnItem) error {
+	args := m.Called(item)
+	return args.Error(0)
+}
+
+func (m *MockReturnRepo) GetByID(ctx context.Context, id int) (*models.Return, error) {
+	return m.ret(m.Called(id))
+}
+
+func (m *MockReturnRepo) Lock(ctx context.Context, id int) (*models.Return, error) {
+	return m.ret(m.Called(id))
+}
+
+func (m *MockReturnRepo) List(ctx context.Context, filter models.ReturnFilter) ([]models.Return, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]models.Return), args.Error(1)
+}
+
+func (m *MockReturnRepo) ListItems(ctx context.Context, returnIDs []int) ([]models.ReturnItem, error) {
+	args := m.Called(returnIDs)
+	items := args.Get(0).([]models.ReturnItem)
+	return append([]models.ReturnItem(nil), items...), args.Error(1)
+}
+
+func (m *MockReturnRepo) ReturnedQuantities(ctx context.Context, orderID int) (map[int]int, error) {
+	args := m.Called(orderID)
+	return args.Get(0).(map[int]int), args.Error(1)
+}
+
+func (m *MockReturnRepo) Update(ctx context.Context, ret *models.Return) error {
+	args := m.Called(ret)
+	return args.Error(0)
+}
+
+func (m *MockReturnRepo) SetRestocked(ctx context.Context, itemID int) error {
+	args := m.Called(itemID)
+	return args.Error(0)
+}
+
+func (m *MockReturnRepo) ret(args mock.Arguments) (*models.Return, error) {
+	if ret := args.Get(0); ret != nil {
+		copied := *ret.(*models.Return)
+		return &copied, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type returnTestRepos struct {
+	returns   *MockReturnRepo
+	orders    *MockOrderRepo
+	payments  *MockPaymentRepo
+	inventory *MockInventoryRepo
+}
+
+func newTestReturnService(gateway PaymentGateway) (ReturnService, returnTestRepos) {
+	repos := returnTestRepos{new(MockReturnRepo), new(MockOrderRepo), new(MockPaymentRepo), new(MockInventoryRepo)}
+	tx := inlineTx{repository.Repos{Returns: repos.returns, Orders: repos.orders, Payments: repos.payments, Inventory: repos.inventory}}
+	return NewReturnService(repos.returns, repos.payments, gateway, tx), repos
+}
+
+func TestRequestReturn(t *testing.T) {
+	productID := 1
+	orderItems := []models.OrderItem{
+		{ID: 1, OrderID: 40, ProductID: &productID, Name: "Mug", Quantity: 2, UnitPrice: usd(950), Tax: usd(160)},
+		{ID: 2, OrderID: 40, Name: "Shirt", Quantity: 1, UnitPrice: usd(2500)},
+	}
+	orderItemID := 1
+	discounts := []models.OrderDiscount{
+		{ID: 1, OrderID: 40, OrderItemID: &orderItemID, Name: "Mugs 300 off", Amount: usd(300)},
+		{ID: 2, OrderID: 40, Name: "Welcome", Amount: usd(500)}, // on the whole order
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		returnService, repos := newTestReturnService(NewFakeGateway())
+		repos.orders.On("GetByID", 40).Return(&models.Order{ID: 40, UserID: 7, Status: models.OrderDelivered, Currency: "USD"}, nil)
+		repos.orders.On("ListItems", []int{40}).Return(orderItems, nil)
+		repos.orders.On("ListDiscounts", 40).Return(discounts, nil)
+		repos.returns.On("ReturnedQuantities", 40).Return(map[int]int{1: 1}, nil)
+		repos.returns.On("Create", mock.MatchedBy(func(ret *models.Return) bool {
+			return ret.Status == models.ReturnRequested && ret.Reason == "chipped"
+		})).Return(nil).Once()
+		repos.returns.On("AddItem", mock.MatchedBy(func(item *models.ReturnItem) bool {
+			return item.ReturnID == 60 && item.OrderItemID == 1 && item.Quantity == 1
+		})).Return(nil).Once()
+
+		ret, err := returnService.RequestReturn(context.Background(), &models.Return{OrderID: 40, UserID: 7, Reason: " chipped ",
+			Items: []models.ReturnItem{{OrderItemID: 1, Quantity: 1}}})
+		assert.NoError(t, err)
+		value, err := ret.Value()
+		assert.NoError(t, err)
+		assert.Equal(t, usd(880), value, "the other half of the mug line after its discount, with its tax")
+		assert.Equal(t, 1, ret.Items[0].LineReturned)
+		assert.Equal(t, "Mug", ret.Items[0].Name)
+		repos.returns.AssertExpectations(t)
+	})
+	t.Run("More than was bought", func(t *testing.T) {
+		returnService, repos := newTestReturnService(NewFakeGateway())
+		repos.orders.On("GetByID", 40).Return(&models.Order{ID: 40, UserID: 7, Status: models.OrderDelivered, Currency: "USD"}, nil)
+		repos.orders.On("ListItems", []int{40}).Return(orderItems, nil)
+		repos.orders.On("ListDiscounts", 40).Return(discounts, nil)
+		repos.returns.On("ReturnedQuantities", 40).Return(map[int]int{1: 1}, nil)
+
+		_, err := returnService.RequestReturn(context.Background(), &models.Return{OrderID: 40, UserID: 7, Reason: "chipped",
+			Items: []models.ReturnItem{{OrderItemID: 1, Quantity: 2}}})
+		assert.ErrorIs(t, err, ErrInvalidReturn)
+		repos.returns.AssertNotCalled(t, "Create", mock.Anything)
+	})
+	t.Run("Not delivered", func(t *testing.T) {
+		returnService, repos := newTestReturnService(NewFakeGateway())
+		repos.orders.On("GetByID", 40).Return(&models.Order{ID: 40, UserID: 7, Status: models.OrderShipped}, nil)
+
+		_, err := returnService.RequestReturn(context.Background(), &models.Return{OrderID: 40, UserID: 7, Reason: "chipped",
+			Items: []models.ReturnItem{{OrderItemID: 1, Quantity: 1}}})
+		assert.ErrorIs(t, err, ErrInvalidReturn)
+	})
+	t.Run("Someone else's order", func(t *testing.T) {
+		returnService, repos := newTestReturnService(NewFakeGateway())
+		repos.orders.On("GetByID", 40).Return(&models.Order{ID: 40, UserID: 8, Status: models.OrderDelivered, Currency: "USD"}, nil)
+
+		_, err := returnService.RequestReturn(context.Background(), &models.Return{OrderID: 40, UserID: 7, Reason: "chipped",
+			Items: []models.ReturnItem{{OrderItemID: 1, Quantity: 1}}})
+		assert.ErrorIs(t, err, ErrOrderNotFound)
+	})
+}
+
+func TestReceiveReturn(t *testing.T) {
+	productID := 1
+	items := []models.ReturnItem{
+		{ID: 5, ReturnID: 60, OrderItemID: 1, ProductID: &productID, Name: "Mug", Quantity: 2, UnitPrice: usd(950)},
+		{ID: 6, ReturnID: 60, OrderItemID: 2, Name: "Discontinued", Quantity: 1, UnitPrice: usd(500)},
+	}
+
+	t.Run("Restocks the listed items", func(t *testing.T) {
+		returnService, repos := newTestReturnService(NewFakeGateway())
+		repos.returns.On("Lock", 60).Return(&models.Return{ID: 60, OrderID: 40, Status: models.ReturnApproved}, nil)
+		repos.returns.On("ListItems", []int{60}).Return(items, nil)
+		repos.inventory.On("EnsureItem", 2, 1, (*int)(nil)).Return(&models.InventoryItem{ID: 10, WarehouseID: 2, OnHand: 3}, nil).Once()
+		repos.inventory.On("AdjustOnHand", 10, 2).Return(&models.InventoryItem{ID: 10, WarehouseID: 2, OnHand: 5}, nil).Once()
+		repos.inventory.On("AddAdjustment", &models.InventoryAdjustment{ItemID: 10, Delta: 2, OnHandAfter: 5, Reason: models.ReasonReturn, Reference: "return-60", CreatedBy: 2}).Return(nil).Once()
+		repos.returns.On("SetRestocked", 5).Return(nil).Once()
+		repos.returns.On("Update", mock.MatchedBy(func(ret *models.Return) bool {
+			return ret.Status == models.ReturnReceived
+		})).Return(nil).Once()
+		repos.returns.On("GetByID", 60).Return(&models.Return{ID: 60, Status: models.ReturnReceived}, nil)
+
+		ret, err := returnService.ReceiveReturn(context.Background(), &models.ReturnReceipt{ReturnID: 60, WarehouseID: 2, Restock: []int{5}, ReceivedBy: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, models.ReturnReceived, ret.Status)
+		repos.inventory.AssertExpectations(t)
+		repos.returns.AssertExpectations(t)
+	})
+	t.Run("Deleted products can't be restocked", func(t *testing.T) {
+		returnService, repos := newTestReturnService(NewFakeGateway())
+		repos.returns.On("Lock", 60).Return(&models.Return{ID: 60, Status: models.ReturnApproved}, nil)
+		repos.returns.On("ListItems", []int{60}).Return(items, nil)
+
+		_, err := returnService.ReceiveReturn(context.Background(), &models.ReturnReceipt{ReturnID: 60, WarehouseID: 2, Restock: []int{6}})
+		assert.ErrorIs(t, err, ErrInvalidReturn)
+	})
+	t.Run("Only approved returns", func(t *testing.T) {
+		returnService, repos := newTestReturnService(NewFakeGateway())
+		repos.returns.On("Lock", 60).Return(&models.Return{ID: 60, Status: models.ReturnRequested}, nil)
+
+		_, err := returnService.ReceiveReturn(context.Background(), &models.ReturnReceipt{ReturnID: 60})
+		assert.ErrorIs(t, err, ErrInvalidReturnTransition)
+	})
+}
+
+func TestRefundReturn(t *testing.T) {
+	var newRefundOf func(gateway PaymentGateway, reference string) (ReturnService, returnTestRepos)
+	items := []models.ReturnItem{{ID: 5, ReturnID: 60, OrderItemID: 1, Name: "Mug", Quantity: 2, UnitPrice: usd(950)}}
+
+	newRefund := func() (ReturnService, returnTestRepos) {
+		gateway := NewFakeGateway()
+		authorized, _ := gateway.Authorize(context.Background(), PaymentRequest{OrderID: 40, Amount: usd(4400), MethodToken: "tok_visa"})
+		gateway.Capture(context.Background(), authorized.Reference, usd(4400))
+		return newRefundOf(gateway, authorized.Reference)
+	}
+	newRefundOf = func(gateway PaymentGateway, reference string) (ReturnService, returnTestRepos) {
+		returnService, repos := newTestReturnService(gateway)
+		payment := models.Payment{ID: 50, OrderID: 40, Reference: reference, Status: models.PaymentCaptured, Amount: usd(4400), RefundedAmount: usd(0)}
+		received := &models.Return{ID: 60, OrderID: 40, Status: models.ReturnReceived, Currency: "USD", RefundAmount: usd(0)}
+		repos.returns.On("GetByID", 60).Return(received, nil).Once()
+		repos.returns.On("Lock", 60).Return(received, nil).Once()
+		repos.returns.On("ListItems", []int{60}).Return(items, nil)
+		repos.payments.On("ListByOrder", 40).Return([]models.Payment{payment}, nil)
+		repos.payments.On("Lock", 50).Return(&payment, nil)
+		repos.payments.On("ListRefunds", 50).Return([]models.Refund(nil), nil)
+		repos.payments.On("CreateRefund", 50, mock.Anything).Return(nil)
+		return returnService, repos
+	}
+
+	t.Run("Partial refund of the order", func(t *testing.T) {
+		returnService, repos := newRefund()
+		repos.payments.On("UpdateRefund", mock.MatchedBy(func(refund *models.Refund) bool {
+			return refund.Status == models.RefundSucceeded && refund.Amount == usd(1900)
+		})).Return(nil).Once()
+		repos.payments.On("Update", mock.MatchedBy(func(payment *models.Payment) bool {
+			return payment.Status == models.PaymentPartiallyRefunded && payment.RefundedAmount == usd(1900)
+		})).Return(nil).Once()
+		repos.orders.On("AddRefund", 40, usd(1900)).Return(nil).Once()
+		repos.returns.On("Update", mock.MatchedBy(func(ret *models.Return) bool {
+			return ret.Status == models.ReturnRefunded && ret.RefundAmount == usd(1900)
+		})).Return(nil).Once()
+		repos.returns.On("GetByID", 60).Return(&models.Return{ID: 60, Status: models.ReturnRefunded, RefundAmount: usd(1900)}, nil)
+
+		ret, err := returnService.RefundReturn(context.Background(), 60, usd(0), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, usd(1900), ret.RefundAmount)
+		repos.orders.AssertExpectations(t)
+		repos.orders.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("Keeping a restocking fee", func(t *testing.T) {
+		returnService, repos := newRefund()
+		repos.payments.On("UpdateRefund", mock.Anything).Return(nil).Once()
+		repos.payments.On("Update", mock.Anything).Return(nil).Once()
+		repos.orders.On("AddRefund", 40, usd(1500)).Return(nil).Once()
+		repos.returns.On("Update", mock.Anything).Return(nil).Once()
+		repos.returns.On("GetByID", 60).Return(&models.Return{ID: 60, Status: models.ReturnRefunded, RefundAmount: usd(1500)}, nil)
+
+		_, err := returnService.RefundReturn(context.Background(), 60, usd(1500), 2)
+		assert.NoError(t, err)
+		repos.orders.AssertExpectations(t)
+	})
+	t.Run("More than the return is worth", func(t *testing.T) {
+		returnService, repos := newRefund()
+
+		_, err := returnService.RefundReturn(context.Background(), 60, usd(2000), 2)
+		assert.ErrorIs(t, err, ErrInvalidReturn)
+		repos.payments.AssertNotCalled(t, "Update", mock.Anything)
+	})
+	t.Run("A rejected refund is taken off the return", func(t *testing.T) {
+		returnService, repos := newRefundOf(NewFakeGateway(), "fake_40_9")
+		repos.payments.On("UpdateRefund", mock.MatchedBy(func(refund *models.Refund) bool {
+			return refund.Status == models.RefundFailed && refund.FailureReason != ""
+		})).Return(nil).Once()
+		repos.returns.On("Update", mock.MatchedBy(func(ret *models.Return) bool {
+			return ret.Status == models.ReturnRefunded && ret.RefundAmount == usd(1900)
+		})).Return(nil).Once()
+		repos.returns.On("GetByID", 60).Return(&models.Return{ID: 60, Status: models.ReturnRefunded, Currency: "USD", RefundAmount: usd(1900)}, nil)
+		repos.returns.On("Lock", 60).Return(&models.Return{ID: 60, OrderID: 40, Status: models.ReturnRefunded, Currency: "USD", RefundAmount: usd(1900)}, nil).Once()
+		repos.returns.On("Update", mock.MatchedBy(func(ret *models.Return) bool {
+			return ret.RefundAmount == usd(0)
+		})).Return(nil).Once()
+
+		_, err := returnService.RefundReturn(context.Background(), 60, usd(0), 2)
+		assert.ErrorIs(t, err, ErrGatewayRejected)
+		repos.returns.AssertExpectations(t)
+		repos.payments.AssertNotCalled(t, "Update", mock.Anything)
+	})
+	t.Run("A timed out refund is sent again", func(t *testing.T) {
+		gateway := &timeoutGateway{FakeGateway: NewFakeGateway()}
+		authorized, _ := gateway.Authorize(context.Background(), PaymentRequest{OrderID: 40, Amount: usd(4400), MethodToken: "tok_visa"})
+		gateway.Capture(context.Background(), authorized.Reference, usd(4400))
+		returnService, repos := newTestReturnService(gateway)
+		payment := models.Payment{ID: 50, OrderID: 40, Reference: authorized.Reference, Status: models.PaymentCaptured, Amount: usd(4400), RefundedAmount: usd(0)}
+		received := &models.Return{ID: 60, OrderID: 40, Status: models.ReturnReceived, Currency: "USD", RefundAmount: usd(0)}
+		refunded := &models.Return{ID: 60, OrderID: 40, Status: models.ReturnRefunded, Currency: "USD", RefundAmount: usd(1900)}
+		repos.returns.On("GetByID", 60).Return(received, nil).Once()
+		repos.returns.On("GetByID", 60).Return(refunded, nil)
+		repos.returns.On("Lock", 60).Return(received, nil).Once()
+		repos.returns.On("ListItems", []int{60}).Return(items, nil)
+		repos.payments.On("ListByOrder", 40).Return([]models.Payment{payment}, nil)
+		repos.payments.On("Lock", 50).Return(&payment, nil)
+		repos.payments.On("ListRefunds", 50).Return([]models.Refund(nil), nil).Twice()
+		repos.payments.On("CreateRefund", 50, usd(1900)).Return(nil).Once()
+		repos.returns.On("Update", mock.MatchedBy(func(ret *models.Return) bool {
+			return ret.Status == models.ReturnRefunded && ret.RefundAmount == usd(1900)
+		})).Return(nil).Once()
+
+		_, err := returnService.RefundReturn(context.Background(), 60, usd(0), 2)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.NotEmpty(t, gateway.timedOut)
+
+		// the refund stayed pending, refunding the return again sends it with the same key
+		returnID := 60
+		repos.payments.On("ListRefunds", 50).Return([]models.Refund{
+			{ID: 70, PaymentID: 50, ReturnID: &returnID, IdempotencyKey: gateway.timedOut, Status: models.RefundPending, Amount: usd(1900)},
+		}, nil).Once()
+		repos.payments.On("UpdateRefund", mock.MatchedBy(func(refund *models.Refund) bool {
+			return refund.ID == 70 && refund.Status == models.RefundSucceeded
+		})).Return(nil).Once()
+		repos.payments.On("Update", mock.MatchedBy(func(payment *models.Payment) bool {
+			return payment.Status == models.PaymentPartiallyRefunded && payment.RefundedAmount == usd(1900)
+		})).Return(nil).Once()
+		repos.orders.On("AddRefund", 40, usd(1900)).Return(nil).Once()
+
+		ret, err := returnService.RefundReturn(context.Background(), 60, usd(0), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, usd(1900), ret.RefundAmount)
+		repos.payments.AssertExpectations(t)
+		repos.orders.AssertExpectations(t)
+		repos.returns.AssertExpectations(t)
+	})
+}
+
+// timeoutGateway makes the first refund it is sent but times out answering
+type timeoutGateway struct {
+	*FakeGateway
+	timedOut string // the idempotency key of that refund
+}
+
+func (g *timeoutGateway) Refund(ctx context.Context, reference string, amount models.Money, idempotencyKey string) (GatewayResult, error) {
+	result, err := g.FakeGateway.Refund(ctx, reference, amount, idempotencyKey)
+	if err == nil && g.timedOut == "" {
+		g.timedOut = idempotencyKey
+		return GatewayResult{}, context.DeadlineExceeded
+	}
+	return result, err
+}
+
+func TestReturnStateMachine(t *testing.T) {
+	assert.True(t, models.ReturnRequested.CanTransitionTo(models.ReturnApproved))
+	assert.True(t, models.ReturnApproved.CanTransitionTo(models.ReturnRefunded))
+	assert.False(t, models.ReturnRequested.CanTransitionTo(models.ReturnReceived))
+	assert.False(t, models.ReturnRejected.CanTransitionTo(models.ReturnApproved))
+	assert.False(t, models.ReturnRefunded.CanTransitionTo(models.ReturnRefunded))
+}
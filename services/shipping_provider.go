@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"fmt"
+	"strconv"
+)
+
+// Names of the shipping rate providers, as used in the configuration
+const (
+	ShippingProviderTable       = "table"
+	ShippingProviderCarrierStub = "carrier_stub"
+)
+
+// ShippingRateProvider quotes the ways of shipping a request, in currency.
+// A provider that can't ship to the destination returns no quotes, errors
+// mean the provider itself failed.
+type ShippingRateProvider interface {
+	Name() string
+	Quote(ctx context.Context, request models.ShippingRequest, currency string) ([]models.ShippingQuote, error)
+}
+
+// NewShippingRateProvider returns the provider registered under name
+func NewShippingRateProvider(name string, shippingRepo repository.ShippingRepo) (ShippingRateProvider, error) {
+	switch name {
+	case ShippingProviderTable:
+		return NewTableRateProvider(shippingRepo), nil
+	case ShippingProviderCarrierStub:
+		return CarrierStubProvider{}, nil
+	}
+	return nil, fmt.Errorf("unknown shipping rate provider %q, expected %s or %s", name, ShippingProviderTable, ShippingProviderCarrierStub)
+}
+
+// TableRateProvider quotes the active methods of the destination's zone
+// from their rate tables. Methods priced in another currency than the cart
+// are left out rather than converted.
+type TableRateProvider struct {
+	shippingRepo repository.ShippingRepo
+}
+
+func NewTableRateProvider(shippingRepo repository.ShippingRepo) *TableRateProvider {
+	return &TableRateProvider{shippingRepo: shippingRepo}
+}
+
+func (p *TableRateProvider) Name() string {
+	return ShippingProviderTable
+}
+
+func (p *TableRateProvider) Quote(ctx context.Context, request models.ShippingRequest, currency string) ([]models.ShippingQuote, error) {
+	zones, err := p.shippingRepo.ListZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zone := models.MatchZone(zones, request.Destination)
+	if zone == nil {
+		return nil, nil
+	}
+	methods, err := p.shippingRepo.ListMethods(ctx, models.ShippingMethodFilter{ZoneID: zone.ID, ActiveOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	var quotes []models.ShippingQuote
+	for _, method := range methods {
+		if method.Currency != currency {
+			continue
+		}
+		price, ok := method.Rate(request.Weight(), request.Subtotal)
+		if !ok {
+			continue
+		}
+		quotes = append(quotes, models.ShippingQuote{
+			Provider:      ShippingProviderTable,
+			Code:          ShippingProviderTable + ":" + strconv.Itoa(method.ID),
+			Name:          method.Name,
+			Price:         price,
+			EstimatedDays: method.EstimatedDays,
+		})
+	}
+	return quotes, nil
+}
+
+// carrierService is a service level of the stub carrier, prices are in
+// hundredths of the currency's major unit
+type carrierService struct {
+	code, name    string
+	base, perKilo int64
+	estimatedDays int
+}
+
+var carrierServices = []carrierService{
+	{"ground", "Ground", 700, 150, 5},
+	{"express", "Express", 1500, 300, 2},
+}
+
+// volumetricDivisor turns a volume in cubic millimetres into grams, the
+// usual 5000 cm³ per kilogram
+const volumetricDivisor = 5000
+
+// CarrierStubProvider stands in for a carrier's rating API in development
+// and tests. It ships anywhere, charging a base price and a price per started
+// kilogram of the billable weight: the actual or volumetric weight of each
+// unit, whichever is higher.
+type CarrierStubProvider struct{}
+
+func (CarrierStubProvider) Name() string {
+	return ShippingProviderCarrierStub
+}
+
+func (CarrierStubProvider) Quote(ctx context.Context, request models.ShippingRequest, currency string) ([]models.ShippingQuote, error) {
+	var billable int64
+	for _, item := range request.Items {
+		weight := int64(item.Weight)
+		if volumetric := item.Dimensions.Volume() / volumetricDivisor; volumetric > weight {
+			weight = volumetric
+		}
+		billable += weight * int64(item.Quantity)
+	}
+	kilos := (billable + 999) / 1000
+
+	// scale the hundredths to the currency's minor units
+	numerator, denominator := int64(1), int64(100)
+	for i := 0; i < models.CurrencyExponent(currency); i++ {
+		numerator *= 10
+	}
+
+	quotes := make([]models.ShippingQuote, 0, len(carrierServices))
+	for _, service := range carrierServices {
+		price, err := models.NewMoney(service.base+service.perKilo*kilos, currency).MulRatio(numerator, denominator, models.RoundHalfUp)
+		if err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, models.ShippingQuote{
+			Provider:      ShippingProviderCarrierStub,
+			Code:          ShippingProviderCarrierStub + ":" + service.code,
+			Name:          service.name,
+			Price:         price,
+			EstimatedDays: service.estimatedDays,
+		})
+	}
+	return quotes, nil
+}
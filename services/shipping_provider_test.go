@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockShippingRepo struct {
+	mock.Mock
+}
+
+func (m *MockShippingRepo) ListZones(ctx context.Context) ([]models.ShippingZone, error) {
+	args := m.Called()
+	return args.Get(0).([]models.ShippingZone), args.Error(1)
+}
+
+func (m *MockShippingRepo) GetZone(ctx context.Context, id int) (*models.ShippingZone, error) {
+	args := m.Called(id)
+	if zone := args.Get(0); zone != nil {
+		return zone.(*models.ShippingZone), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockShippingRepo) CreateZone(ctx context.Context, zone *models.ShippingZone) error {
+	args := m.Called(zone)
+	return args.Error(0)
+}
+
+func (m *MockShippingRepo) UpdateZone(ctx context.Context, zone *models.ShippingZone) error {
+	args := m.Called(zone)
+	return args.Error(0)
+}
+
+func (m *MockShippingRepo) DeleteZone(ctx context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockShippingRepo) ListMethods(ctx context.Context, filter models.ShippingMethodFilter) ([]models.ShippingMethod, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]models.ShippingMethod), args.Error(1)
+}
+
+func (m *MockShippingRepo) GetMethod(ctx context.Context, id int) (*models.ShippingMethod, error) {
+	args := m.Called(id)
+	if method := args.Get(0); method != nil {
+		return method.(*models.ShippingMethod), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockShippingRepo) CreateMethod(ctx context.Context, method *models.ShippingMethod) error {
+	args := m.Called(method)
+	return args.Error(0)
+}
+
+func (m *MockShippingRepo) UpdateMethod(ctx context.Context, method *models.ShippingMethod) error {
+	args := m.Called(method)
+	return args.Error(0)
+}
+
+func (m *MockShippingRepo) DeleteMethod(ctx context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func TestTableRateProvider(t *testing.T) {
+	shippingRepo := new(MockShippingRepo)
+	provider := NewTableRateProvider(shippingRepo)
+
+	shippingRepo.On("ListZones").Return([]models.ShippingZone{
+		{ID: 1, Name: "Domestic", Locations: []models.ZoneLocation{{Country: "US"}}},
+	}, nil)
+	shippingRepo.On("ListMethods", models.ShippingMethodFilter{ZoneID: 1, ActiveOnly: true}).Return([]models.ShippingMethod{
+		{ID: 4, ZoneID: 1, Name: "Standard", Kind: models.ShippingByWeight, Currency: "USD", EstimatedDays: 5,
+			Tiers: []models.ShippingTier{{Min: 0, Price: usd(500)}, {Min: 2000, Price: usd(900)}}},
+		{ID: 5, ZoneID: 1, Name: "Free over $50", Kind: models.ShippingByPrice, Currency: "USD",
+			Tiers: []models.ShippingTier{{Min: 5000, Price: usd(0)}}},
+		{ID: 6, ZoneID: 1, Name: "Standard (EUR)", Kind: models.ShippingFlat, Currency: "EUR",
+			Tiers: []models.ShippingTier{{Min: 0, Price: models.NewMoney(450, "EUR")}}},
+	}, nil)
+
+	t.Run("Destination in a zone", func(t *testing.T) {
+		request := models.ShippingRequest{
+			Destination: models.Destination{Country: "US", Region: "NY"},
+			Subtotal:    usd(3000),
+			Items:       []models.ShippingItem{{ProductID: 1, Quantity: 3, Weight: 800}},
+		}
+		quotes, err := provider.Quote(context.Background(), request, "USD")
+		assert.NoError(t, err)
+		// 2.4kg ships standard, the subtotal is too low for free shipping and EUR methods are skipped
+		assert.Equal(t, []models.ShippingQuote{
+			{Provider: ShippingProviderTable, Code: "table:4", Name: "Standard", Price: usd(900), EstimatedDays: 5},
+		}, quotes)
+	})
+	t.Run("Destination outside every zone", func(t *testing.T) {
+		quotes, err := provider.Quote(context.Background(), models.ShippingRequest{Destination: models.Destination{Country: "CA"}}, "USD")
+		assert.NoError(t, err)
+		assert.Empty(t, quotes)
+	})
+}
+
+func TestCarrierStubProvider(t *testing.T) {
+	request := models.ShippingRequest{
+		Destination: models.Destination{Country: "DE"},
+		Items: []models.ShippingItem{
+			{ProductID: 1, Quantity: 2, Weight: 300},
+			// 400x300x250mm weighs 6kg by volume
+			{ProductID: 2, Quantity: 1, Weight: 1500, Dimensions: models.Dimensions{Length: 400, Width: 300, Height: 250}},
+		},
+	}
+	quotes, err := CarrierStubProvider{}.Quote(context.Background(), request, "USD")
+	assert.NoError(t, err)
+	assert.Len(t, quotes, 2)
+	// 6.6kg bills as 7 started kilograms
+	assert.Equal(t, usd(700+7*150), quotes[0].Price)
+	assert.Equal(t, "carrier_stub:express", quotes[1].Code)
+	assert.Equal(t, usd(1500+7*300), quotes[1].Price)
+
+	quotes, err = CarrierStubProvider{}.Quote(context.Background(), models.ShippingRequest{}, "JPY")
+	assert.NoError(t, err)
+	assert.Equal(t, models.NewMoney(7, "JPY"), quotes[0].Price)
+}
+
+func TestNewShippingRateProvider(t *testing.T) {
+	provider, err := NewShippingRateProvider(ShippingProviderCarrierStub, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ShippingProviderCarrierStub, provider.Name())
+
+	_, err = NewShippingRateProvider("ups", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrShippingZoneNotFound   = repository.ErrShippingZoneNotFound
+	ErrShippingMethodNotFound = repository.ErrShippingMethodNotFound
+	ErrInvalidShippingZone    = errors.New("invalid shipping zone")
+	ErrInvalidShippingMethod  = errors.New("invalid shipping method")
+	ErrShippingUnavailable    = errors.New("no shipping rate provider could quote")
+)
+
+// ShippingService manages the shipping zones and their methods and quotes
+// the ways of shipping a cart
+type ShippingService interface {
+	GetShippingZones(ctx context.Context) ([]models.ShippingZone, error)
+	GetShippingZone(ctx context.Context, id int) (*models.ShippingZone, error)
+	CreateShippingZone(ctx context.Context, zone *models.ShippingZone) error
+	UpdateShippingZone(ctx context.Context, zone *models.ShippingZone) error
+	DeleteShippingZone(ctx context.Context, id int) error
+
+	GetShippingMethods(ctx context.Context, filter models.ShippingMethodFilter) ([]models.ShippingMethod, error)
+	GetShippingMethod(ctx context.Context, id int) (*models.ShippingMethod, error)
+	CreateShippingMethod(ctx context.Context, method *models.ShippingMethod) error
+	UpdateShippingMethod(ctx context.Context, method *models.ShippingMethod) error
+	DeleteShippingMethod(ctx context.Context, id int) error
+
+	// GetCartShippingOptions quotes every provider for shipping the owner's
+	// cart to destination, cheapest first. The coupon codes count towards
+	// the subtotal and can make shipping free.
+	GetCartShippingOptions(ctx context.Context, owner models.CartOwner, destination models.Destination, codes []string) ([]models.ShippingQuote, error)
+}
+
+type shippingService struct {
+	shippingRepo     repository.ShippingRepo
+	promotionService PromotionService
+	providers        []ShippingRateProvider
+	txManager        repository.TxManager
+}
+
+func NewShippingService(shippingRepo repository.ShippingRepo, promotionService PromotionService, providers []ShippingRateProvider,
+	txManager repository.TxManager) ShippingService {
+	return &shippingService{shippingRepo: shippingRepo, promotionService: promotionService, providers: providers, txManager: txManager}
+}
+
+func (s *shippingService) GetShippingZones(ctx context.Context) ([]models.ShippingZone, error) {
+	return s.shippingRepo.ListZones(ctx)
+}
+
+func (s *shippingService) GetShippingZone(ctx context.Context, id int) (*models.ShippingZone, error) {
+	return s.shippingRepo.GetZone(ctx, id)
+}
+
+func (s *shippingService) CreateShippingZone(ctx context.Context, zone *models.ShippingZone) error {
+	if err := validateShippingZone(zone); err != nil {
+		return err
+	}
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		return tx.Shipping.CreateZone(ctx, zone)
+	})
+	return shippingZoneError(err, zone)
+}
+
+func (s *shippingService) UpdateShippingZone(ctx context.Context, zone *models.ShippingZone) error {
+	if err := validateShippingZone(zone); err != nil {
+		return err
+	}
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		if _, err := tx.Shipping.GetZone(ctx, zone.ID); err != nil {
+			return err
+		}
+		return tx.Shipping.UpdateZone(ctx, zone)
+	})
+	return shippingZoneError(err, zone)
+}
+
+// DeleteShippingZone removes the zone and its methods
+func (s *shippingService) DeleteShippingZone(ctx context.Context, id int) error {
+	return s.shippingRepo.DeleteZone(ctx, id)
+}
+
+func (s *shippingService) GetShippingMethods(ctx context.Context, filter models.ShippingMethodFilter) ([]models.ShippingMethod, error) {
+	return s.shippingRepo.ListMethods(ctx, filter)
+}
+
+func (s *shippingService) GetShippingMethod(ctx context.Context, id int) (*models.ShippingMethod, error) {
+	return s.shippingRepo.GetMethod(ctx, id)
+}
+
+func (s *shippingService) CreateShippingMethod(ctx context.Context, method *models.ShippingMethod) error {
+	if err := validateShippingMethod(method); err != nil {
+		return err
+	}
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		return tx.Shipping.CreateMethod(ctx, method)
+	})
+	return shippingMethodError(err, method)
+}
+
+func (s *shippingService) UpdateShippingMethod(ctx context.Context, method *models.ShippingMethod) error {
+	if err := validateShippingMethod(method); err != nil {
+		return err
+	}
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		if _, err := tx.Shipping.GetMethod(ctx, method.ID); err != nil {
+			return err
+		}
+		return tx.Shipping.UpdateMethod(ctx, method)
+	})
+	return shippingMethodError(err, method)
+}
+
+func (s *shippingService) DeleteShippingMethod(ctx context.Context, id int) error {
+	return s.shippingRepo.DeleteMethod(ctx, id)
+}
+
+func (s *shippingService) GetCartShippingOptions(ctx context.Context, owner models.CartOwner, destination models.Destination,
+	codes []string) ([]models.ShippingQuote, error) {
+	destination = destination.Normalize()
+	if !models.ValidCountry(destination.Country) {
+		return nil, fmt.Errorf("%w: country must be an ISO 3166-1 alpha-2 code", ErrInvalidDestination)
+	}
+	cart, promotions, err := s.promotionService.EvaluateCart(ctx, owner, codes)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Items) == 0 {
+		return nil, ErrEmptyCart
+	}
+
+	subtotal, err := promotions.Total()
+	if err != nil {
+		return nil, err
+	}
+	request := models.ShippingRequest{Destination: destination, Subtotal: subtotal}
+	for _, item := range cart.Items {
+		request.Items = append(request.Items, models.ShippingItem{
+			ProductID: item.ProductID, Quantity: item.Quantity, Weight: item.Weight, Dimensions: item.Dimensions,
+		})
+	}
+
+	quotes := []models.ShippingQuote{}
+	var lastErr error
+	failed := 0
+	for _, provider := range s.providers {
+		providerQuotes, err := provider.Quote(ctx, request, cart.Currency)
+		if err != nil {
+			// one provider being down shouldn't keep the others' options from the customer
+			log.Printf("shipping rate provider %s failed: %v", provider.Name(), err)
+			lastErr = err
+			failed++
+			continue
+		}
+		quotes = append(quotes, providerQuotes...)
+	}
+	// but with all of them down an empty list would read as "we don't ship there"
+	if failed > 0 && failed == len(s.providers) {
+		return nil, fmt.Errorf("%w: %v", ErrShippingUnavailable, lastErr)
+	}
+	for i := range quotes {
+		quotes[i].Cost = quotes[i].Price
+	}
+	sort.SliceStable(quotes, func(i, j int) bool { return quotes[i].Price.Amount < quotes[j].Price.Amount })
+	// free shipping takes the cheapest way, faster ones can still be paid for
+	if promotions.FreeShipping && len(quotes) > 0 {
+		quotes[0].Cost = models.NewMoney(0, cart.Currency)
+	}
+	return quotes, nil
+}
+
+// validateShippingZone normalises the zone's locations the way destinations
+// are and checks they are well formed
+func validateShippingZone(zone *models.ShippingZone) error {
+	zone.Name = strings.TrimSpace(zone.Name)
+	switch {
+	case zone.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidShippingZone)
+	case len(zone.Locations) == 0:
+		return fmt.Errorf("%w: at least one location is required", ErrInvalidShippingZone)
+	}
+	seen := make(map[models.ZoneLocation]bool, len(zone.Locations))
+	for i, location := range zone.Locations {
+		destination := models.Destination{Country: location.Country, Region: location.Region, Postcode: location.Postcode}.Normalize()
+		location = models.ZoneLocation{Country: destination.Country, Region: destination.Region, Postcode: destination.Postcode}
+		switch {
+		case !models.ValidCountry(location.Country):
+			return fmt.Errorf("%w: country must be an ISO 3166-1 alpha-2 code", ErrInvalidShippingZone)
+		case strings.Contains(strings.TrimSuffix(location.Postcode, "*"), "*") || location.Postcode == "*":
+			return fmt.Errorf("%w: a postcode may only end in *, leave it empty to match them all", ErrInvalidShippingZone)
+		case seen[location]:
+			return fmt.Errorf("%w: %s is listed twice", ErrInvalidShippingZone, describeLocation(location))
+		}
+		seen[location] = true
+		zone.Locations[i] = location
+	}
+	return nil
+}
+
+// shippingZoneError explains the database errors a zone can run into
+func shippingZoneError(err error, zone *models.ShippingZone) error {
+	if repository.IsDuplicate(err) {
+		return fmt.Errorf("%w: %q or one of its locations is already taken by another zone", ErrInvalidShippingZone, zone.Name)
+	}
+	return err
+}
+
+func describeLocation(location models.ZoneLocation) string {
+	description := location.Country
+	for _, part := range []string{location.Region, location.Postcode} {
+		if part != "" {
+			description += "/" + part
+		}
+	}
+	return description
+}
+
+// validateShippingMethod checks the method's rate table is usable and puts
+// its tiers in order
+func validateShippingMethod(method *models.ShippingMethod) error {
+	method.Name = strings.TrimSpace(method.Name)
+	method.Currency = strings.ToUpper(method.Currency)
+	switch {
+	case method.ZoneID <= 0:
+		return fmt.Errorf("%w: zone is required", ErrInvalidShippingMethod)
+	case method.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidShippingMethod)
+	case !method.Kind.Valid():
+		return fmt.Errorf("%w: kind must be flat, weight or price", ErrInvalidShippingMethod)
+	case !models.ValidCurrency(method.Currency):
+		return fmt.Errorf("%w: %q is not a supported currency", ErrInvalidShippingMethod, method.Currency)
+	case method.EstimatedDays < 0:
+		return fmt.Errorf("%w: estimated days must not be negative", ErrInvalidShippingMethod)
+	case len(method.Tiers) == 0:
+		return fmt.Errorf("%w: at least one rate is required", ErrInvalidShippingMethod)
+	case method.Kind == models.ShippingFlat && (len(method.Tiers) > 1 || method.Tiers[0].Min != 0):
+		return fmt.Errorf("%w: a flat rate has a single rate from 0", ErrInvalidShippingMethod)
+	}
+
+	sort.Slice(method.Tiers, func(i, j int) bool { return method.Tiers[i].Min < method.Tiers[j].Min })
+	for i, tier := range method.Tiers {
+		switch {
+		case tier.Min < 0:
+			return fmt.Errorf("%w: rates must not start below 0", ErrInvalidShippingMethod)
+		case i > 0 && tier.Min == method.Tiers[i-1].Min:
+			return fmt.Errorf("%w: two rates start at %d", ErrInvalidShippingMethod, tier.Min)
+		case tier.Price.Currency != method.Currency:
+			return fmt.Errorf("%w: rates must be in %s", ErrInvalidShippingMethod, method.Currency)
+		case tier.Price.IsNegative():
+			return fmt.Errorf("%w: rates must not be negative", ErrInvalidShippingMethod)
+		}
+	}
+	return nil
+}
+
+// shippingMethodError explains the database errors a method can run into
+func shippingMethodError(err error, method *models.ShippingMethod) error {
+	if repository.IsMissingReference(err) {
+		return fmt.Errorf("%w: unknown shipping zone %d", ErrInvalidShippingMethod, method.ZoneID)
+	}
+	return err
+}
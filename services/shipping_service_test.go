@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type stubShippingProvider struct {
+	name   string
+	quotes []models.ShippingQuote
+	err    error
+}
+
+func (p stubShippingProvider) Name() string {
+	return p.name
+}
+
+func (p stubShippingProvider) Quote(ctx context.Context, request models.ShippingRequest, currency string) ([]models.ShippingQuote, error) {
+	return p.quotes, p.err
+}
+
+func TestCreateShippingZone(t *testing.T) {
+	shippingRepo := new(MockShippingRepo)
+	shippingService := NewShippingService(shippingRepo, nil, nil, inlineTx{repository.Repos{Shipping: shippingRepo}})
+
+	t.Run("Success", func(t *testing.T) {
+		shippingRepo.On("CreateZone", mock.MatchedBy(func(zone *models.ShippingZone) bool {
+			return zone.Name == "Remote" && zone.Locations[0] == models.ZoneLocation{Country: "US", Region: "AK"} && zone.Locations[1].Postcode == "006*"
+		})).Return(nil).Once()
+
+		zone := &models.ShippingZone{Name: " Remote", Locations: []models.ZoneLocation{{Country: "us", Region: "ak"}, {Country: "US", Postcode: "006*"}}}
+		assert.NoError(t, shippingService.CreateShippingZone(context.Background(), zone))
+	})
+	t.Run("Location taken", func(t *testing.T) {
+		shippingRepo.On("CreateZone", mock.Anything).Return(&mysql.MySQLError{Number: 1062}).Once()
+
+		err := shippingService.CreateShippingZone(context.Background(), &models.ShippingZone{Name: "Domestic", Locations: []models.ZoneLocation{{Country: "US"}}})
+		assert.ErrorIs(t, err, ErrInvalidShippingZone)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		for name, zone := range map[string]models.ShippingZone{
+			"No name":         {Locations: []models.ZoneLocation{{Country: "US"}}},
+			"No locations":    {Name: "Domestic"},
+			"Bad country":     {Name: "Domestic", Locations: []models.ZoneLocation{{Country: "USA"}}},
+			"Bare wildcard":   {Name: "Domestic", Locations: []models.ZoneLocation{{Country: "US", Postcode: "*"}}},
+			"Listed twice":    {Name: "Domestic", Locations: []models.ZoneLocation{{Country: "US"}, {Country: "us"}}},
+			"Wildcard inside": {Name: "Domestic", Locations: []models.ZoneLocation{{Country: "US", Postcode: "0*6"}}},
+		} {
+			err := shippingService.CreateShippingZone(context.Background(), &zone)
+			assert.ErrorIs(t, err, ErrInvalidShippingZone, name)
+		}
+	})
+	shippingRepo.AssertExpectations(t)
+}
+
+func TestCreateShippingMethod(t *testing.T) {
+	shippingRepo := new(MockShippingRepo)
+	shippingService := NewShippingService(shippingRepo, nil, nil, inlineTx{repository.Repos{Shipping: shippingRepo}})
+
+	t.Run("Tiers are ordered", func(t *testing.T) {
+		shippingRepo.On("CreateMethod", mock.MatchedBy(func(method *models.ShippingMethod) bool {
+			return method.Tiers[0].Min == 0 && method.Tiers[1].Min == 2000
+		})).Return(nil).Once()
+
+		method := &models.ShippingMethod{ZoneID: 1, Name: "Standard", Kind: models.ShippingByWeight, Currency: "usd",
+			Tiers: []models.ShippingTier{{Min: 2000, Price: usd(900)}, {Min: 0, Price: usd(500)}}}
+		assert.NoError(t, shippingService.CreateShippingMethod(context.Background(), method))
+	})
+	t.Run("Unknown zone", func(t *testing.T) {
+		shippingRepo.On("CreateMethod", mock.Anything).Return(&mysql.MySQLError{Number: 1452}).Once()
+
+		method := &models.ShippingMethod{ZoneID: 9, Name: "Standard", Kind: models.ShippingFlat, Currency: "USD", Tiers: []models.ShippingTier{{Price: usd(500)}}}
+		err := shippingService.CreateShippingMethod(context.Background(), method)
+		assert.ErrorIs(t, err, ErrInvalidShippingMethod)
+		assert.Contains(t, err.Error(), "unknown shipping zone 9")
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		tiers := []models.ShippingTier{{Price: usd(500)}}
+		for name, method := range map[string]models.ShippingMethod{
+			"No zone":          {Name: "Standard", Kind: models.ShippingFlat, Currency: "USD", Tiers: tiers},
+			"Unknown kind":     {ZoneID: 1, Name: "Standard", Kind: "volume", Currency: "USD", Tiers: tiers},
+			"No rates":         {ZoneID: 1, Name: "Standard", Kind: models.ShippingFlat, Currency: "USD"},
+			"Flat with tiers":  {ZoneID: 1, Name: "Standard", Kind: models.ShippingFlat, Currency: "USD", Tiers: []models.ShippingTier{{Price: usd(500)}, {Min: 10, Price: usd(900)}}},
+			"Same start":       {ZoneID: 1, Name: "Standard", Kind: models.ShippingByWeight, Currency: "USD", Tiers: []models.ShippingTier{{Price: usd(500)}, {Price: usd(900)}}},
+			"Other currency":   {ZoneID: 1, Name: "Standard", Kind: models.ShippingFlat, Currency: "EUR", Tiers: tiers},
+			"Negative price":   {ZoneID: 1, Name: "Standard", Kind: models.ShippingFlat, Currency: "USD", Tiers: []models.ShippingTier{{Price: usd(-1)}}},
+			"Name is required": {ZoneID: 1, Kind: models.ShippingFlat, Currency: "USD", Tiers: tiers},
+		} {
+			err := shippingService.CreateShippingMethod(context.Background(), &method)
+			assert.ErrorIs(t, err, ErrInvalidShippingMethod, name)
+		}
+	})
+	shippingRepo.AssertExpectations(t)
+}
+
+func TestGetCartShippingOptions(t *testing.T) {
+	promotionRepo, cartRepo := new(MockPromotionRepo), new(MockCartRepo)
+	cartService := NewCartService(cartRepo, inlineTx{}, "USD")
+	productService := NewProductService(new(MockProductRepo), new(MockVariantRepo), new(MockCategoryRepo), inlineTx{}, "USD")
+	promotionService := NewPromotionService(promotionRepo, cartService, productService, inlineTx{}, "USD")
+	providers := []ShippingRateProvider{
+		stubShippingProvider{name: "table", quotes: []models.ShippingQuote{
+			{Provider: "table", Code: "table:4", Name: "Standard", Price: usd(900)},
+			{Provider: "table", Code: "table:5", Name: "Next day", Price: usd(2500)},
+		}},
+		stubShippingProvider{name: "carrier", err: errors.New("carrier unreachable")},
+		stubShippingProvider{name: "stub", quotes: []models.ShippingQuote{{Provider: "stub", Code: "stub:ground", Name: "Ground", Price: usd(850)}}},
+	}
+	shippingService := NewShippingService(new(MockShippingRepo), promotionService, providers, inlineTx{})
+
+	cartRepo.On("GetByUser", 7).Return(&models.Cart{ID: 3, UserID: 7, Currency: "USD"}, nil)
+	cartRepo.On("ListItems", 3).Return([]models.CartItem{{ID: 8, ProductID: 1, Quantity: 1, Weight: 500, UnitPrice: usd(2400)}}, nil)
+	promotionRepo.On("ListApplicable", []string(nil)).Return([]models.Promotion{}, nil)
+	promotionRepo.On("ListApplicable", []string{"SHIPFREE"}).Return([]models.Promotion{
+		{ID: 2, Name: "Free shipping", Code: "SHIPFREE", Kind: models.PromotionFreeShipping, MinSubtotal: usd(0), Active: true},
+	}, nil)
+
+	t.Run("Cheapest first", func(t *testing.T) {
+		quotes, err := shippingService.GetCartShippingOptions(context.Background(), models.CartOwner{UserID: 7}, models.Destination{Country: "us"}, nil)
+		assert.NoError(t, err)
+		assert.Len(t, quotes, 3, "the failing provider is skipped")
+		assert.Equal(t, "stub:ground", quotes[0].Code)
+		assert.Equal(t, usd(850), quotes[0].Cost)
+		assert.Equal(t, "table:5", quotes[2].Code)
+	})
+	t.Run("Free shipping", func(t *testing.T) {
+		quotes, err := shippingService.GetCartShippingOptions(context.Background(), models.CartOwner{UserID: 7}, models.Destination{Country: "US"}, []string{"SHIPFREE"})
+		assert.NoError(t, err)
+		assert.Equal(t, usd(0), quotes[0].Cost)
+		assert.Equal(t, usd(850), quotes[0].Price)
+		assert.Equal(t, usd(900), quotes[1].Cost)
+	})
+	t.Run("No country", func(t *testing.T) {
+		_, err := shippingService.GetCartShippingOptions(context.Background(), models.CartOwner{UserID: 7}, models.Destination{Postcode: "10001"}, nil)
+		assert.ErrorIs(t, err, ErrInvalidDestination)
+	})
+	t.Run("All providers failing", func(t *testing.T) {
+		down := NewShippingService(new(MockShippingRepo), promotionService, []ShippingRateProvider{
+			stubShippingProvider{name: "carrier", err: errors.New("carrier unreachable")},
+		}, inlineTx{})
+		_, err := down.GetCartShippingOptions(context.Background(), models.CartOwner{UserID: 7}, models.Destination{Country: "US"}, nil)
+		assert.ErrorIs(t, err, ErrShippingUnavailable)
+	})
+}
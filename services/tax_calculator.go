@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"fmt"
+)
+
+// TaxCalculator works out the tax on priced lines shipping to destination.
+// Lines are in currency, each is taxed at the rate of its tax class.
+type TaxCalculator interface {
+	Calculate(ctx context.Context, destination models.Destination, currency string, lines []models.TaxLine) (*models.TaxResult, error)
+}
+
+// LocalTaxCalculator looks rates up in the rate table. Of the rates of a
+// line's class matching the destination the most specific one applies, a
+// line without a matching rate is untaxed.
+type LocalTaxCalculator struct {
+	taxRepo        repository.TaxRepo
+	mode           models.TaxMode
+	defaultCountry string // taxed when the destination has no country
+	rounding       models.RoundingMode
+}
+
+func NewLocalTaxCalculator(taxRepo repository.TaxRepo, mode models.TaxMode, defaultCountry string, rounding models.RoundingMode) *LocalTaxCalculator {
+	return &LocalTaxCalculator{taxRepo: taxRepo, mode: mode, defaultCountry: defaultCountry, rounding: rounding}
+}
+
+func (c *LocalTaxCalculator) Calculate(ctx context.Context, destination models.Destination, currency string, lines []models.TaxLine) (*models.TaxResult, error) {
+	destination = destination.Normalize()
+	if destination.Country != "" && !models.ValidCountry(destination.Country) {
+		return nil, fmt.Errorf("%w: country must be an ISO 3166-1 alpha-2 code", ErrInvalidDestination)
+	}
+	if destination.Country == "" {
+		destination.Country = c.defaultCountry
+	}
+	var rates []models.TaxRate
+	if destination.Country != "" {
+		var err error
+		if rates, err = c.taxRepo.ListRates(ctx, models.TaxRateFilter{Country: destination.Country}); err != nil {
+			return nil, err
+		}
+	}
+
+	zero := models.Money{Currency: currency}
+	result := &models.TaxResult{Mode: c.mode, Destination: destination, Net: zero, Tax: zero, Gross: zero, Lines: make([]models.LineTax, 0, len(lines))}
+	for i, line := range lines {
+		if line.Amount.Currency != currency {
+			return nil, fmt.Errorf("line %d is in %s, expected %s", i, line.Amount.Currency, currency)
+		}
+		lineTax := models.LineTax{Line: i, TaxClass: line.TaxClass, Net: line.Amount, Tax: zero, Gross: line.Amount}
+		if rate := matchTaxRate(rates, line.TaxClass, destination); rate != nil {
+			lineTax.RateID, lineTax.Name, lineTax.Rate = rate.ID, rate.Name, rate.Rate
+			var err error
+			if c.mode == models.TaxInclusive {
+				if lineTax.Tax, err = rate.Rate.IncludedIn(line.Amount, c.rounding); err != nil {
+					return nil, err
+				}
+				if lineTax.Net, err = lineTax.Net.Sub(lineTax.Tax); err != nil {
+					return nil, err
+				}
+			} else {
+				if lineTax.Tax, err = rate.Rate.Of(line.Amount, c.rounding); err != nil {
+					return nil, err
+				}
+				if lineTax.Gross, err = lineTax.Gross.Add(lineTax.Tax); err != nil {
+					return nil, err
+				}
+			}
+		}
+		var err error
+		if result.Net, err = result.Net.Add(lineTax.Net); err != nil {
+			return nil, err
+		}
+		if result.Tax, err = result.Tax.Add(lineTax.Tax); err != nil {
+			return nil, err
+		}
+		if result.Gross, err = result.Gross.Add(lineTax.Gross); err != nil {
+			return nil, err
+		}
+		result.Lines = append(result.Lines, lineTax)
+	}
+	return result, nil
+}
+
+// matchTaxRate returns the most specific rate of taxClass for the
+// destination, the first one listed on a tie
+func matchTaxRate(rates []models.TaxRate, taxClass string, destination models.Destination) *models.TaxRate {
+	var best *models.TaxRate
+	for i := range rates {
+		rate := &rates[i]
+		if rate.TaxClass != taxClass || !rate.Matches(destination) {
+			continue
+		}
+		if best == nil || rate.Specificity() > best.Specificity() {
+			best = rate
+		}
+	}
+	return best
+}
+
+// discountedTaxLines pairs each line's tax class with what it sells for once
+// the promotions took their share off it. Discounts not tied to a line, like
+// free shipping, don't change the taxable amounts.
+func discountedTaxLines(taxClasses []string, amounts []models.Money, promotions *models.PromotionResult) ([]models.TaxLine, error) {
+	lines := make([]models.TaxLine, len(amounts))
+	for i, amount := range amounts {
+		lines[i] = models.TaxLine{TaxClass: taxClasses[i], Amount: amount}
+	}
+	if promotions == nil {
+		return lines, nil
+	}
+	for _, discount := range promotions.Discounts {
+		if discount.Line < 0 {
+			continue
+		}
+		amount, err := lines[discount.Line].Amount.Sub(discount.Amount)
+		if err != nil {
+			return nil, err
+		}
+		lines[discount.Line].Amount = amount
+	}
+	return lines, nil
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockTaxRepo struct {
+	mock.Mock
+}
+
+func (m *MockTaxRepo) ListClasses(ctx context.Context) ([]models.TaxClass, error) {
+	args := m.Called()
+	return args.Get(0).([]models.TaxClass), args.Error(1)
+}
+
+func (m *MockTaxRepo) CreateClass(ctx context.Context, class *models.TaxClass) error {
+	args := m.Called(class)
+	return args.Error(0)
+}
+
+func (m *MockTaxRepo) ListRates(ctx context.Context, filter models.TaxRateFilter) ([]models.TaxRate, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]models.TaxRate), args.Error(1)
+}
+
+func (m *MockTaxRepo) GetRate(ctx context.Context, id int) (*models.TaxRate, error) {
+	args := m.Called(id)
+	if rate := args.Get(0); rate != nil {
+		return rate.(*models.TaxRate), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTaxRepo) CreateRate(ctx context.Context, rate *models.TaxRate) error {
+	args := m.Called(rate)
+	return args.Error(0)
+}
+
+func (m *MockTaxRepo) UpdateRate(ctx context.Context, rate *models.TaxRate) error {
+	args := m.Called(rate)
+	return args.Error(0)
+}
+
+func (m *MockTaxRepo) DeleteRate(ctx context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func TestLocalTaxCalculator(t *testing.T) {
+	taxRepo := new(MockTaxRepo)
+	taxRepo.On("ListRates", models.TaxRateFilter{Country: "GB"}).Return([]models.TaxRate{
+		{ID: 1, TaxClass: "standard", Country: "GB", Rate: 200000, Name: "VAT"},
+		{ID: 2, TaxClass: "reduced", Country: "GB", Rate: 50000, Name: "VAT reduced"},
+		{ID: 3, TaxClass: "standard", Country: "GB", Postcode: "BT*", Rate: 175000, Name: "Made up NI rate"},
+	}, nil)
+	lines := []models.TaxLine{
+		{TaxClass: "standard", Amount: usd(1000)},
+		{TaxClass: "reduced", Amount: usd(999)},
+		{TaxClass: "zero", Amount: usd(500)},
+	}
+
+	t.Run("Exclusive", func(t *testing.T) {
+		calculator := NewLocalTaxCalculator(taxRepo, models.TaxExclusive, "", models.RoundHalfUp)
+		result, err := calculator.Calculate(context.Background(), models.Destination{Country: "gb", Postcode: "SW1A 1AA"}, "USD", lines)
+		assert.NoError(t, err)
+		assert.Equal(t, usd(200), result.Lines[0].Tax)
+		assert.Equal(t, usd(1200), result.Lines[0].Gross)
+		// 5% of 9.99 is 0.4995
+		assert.Equal(t, usd(50), result.Lines[1].Tax)
+		assert.Equal(t, 0, result.Lines[2].RateID, "no rate for the zero class")
+		assert.Equal(t, usd(2499), result.Net)
+		assert.Equal(t, usd(250), result.Tax)
+		assert.Equal(t, usd(2749), result.Gross)
+	})
+	t.Run("Inclusive", func(t *testing.T) {
+		calculator := NewLocalTaxCalculator(taxRepo, models.TaxInclusive, "", models.RoundHalfUp)
+		result, err := calculator.Calculate(context.Background(), models.Destination{Country: "GB"}, "USD", lines[:1])
+		assert.NoError(t, err)
+		// 10.00 contains 10.00 * 20/120
+		assert.Equal(t, usd(167), result.Tax)
+		assert.Equal(t, usd(833), result.Net)
+		assert.Equal(t, usd(1000), result.Gross)
+	})
+	t.Run("Most specific rate wins", func(t *testing.T) {
+		calculator := NewLocalTaxCalculator(taxRepo, models.TaxExclusive, "", models.RoundHalfUp)
+		result, err := calculator.Calculate(context.Background(), models.Destination{Country: "GB", Postcode: "bt1 1aa"}, "USD", lines[:1])
+		assert.NoError(t, err)
+		assert.Equal(t, 3, result.Lines[0].RateID)
+		assert.Equal(t, usd(175), result.Tax)
+	})
+	t.Run("Default country", func(t *testing.T) {
+		calculator := NewLocalTaxCalculator(taxRepo, models.TaxExclusive, "GB", models.RoundHalfUp)
+		result, err := calculator.Calculate(context.Background(), models.Destination{}, "USD", lines[:1])
+		assert.NoError(t, err)
+		assert.Equal(t, "GB", result.Destination.Country)
+		assert.Equal(t, usd(200), result.Tax)
+	})
+	t.Run("No destination", func(t *testing.T) {
+		calculator := NewLocalTaxCalculator(taxRepo, models.TaxExclusive, "", models.RoundHalfUp)
+		result, err := calculator.Calculate(context.Background(), models.Destination{}, "USD", lines)
+		assert.NoError(t, err)
+		assert.True(t, result.Tax.IsZero())
+		assert.Equal(t, usd(2499), result.Gross)
+	})
+	t.Run("Invalid country", func(t *testing.T) {
+		calculator := NewLocalTaxCalculator(taxRepo, models.TaxExclusive, "", models.RoundHalfUp)
+		_, err := calculator.Calculate(context.Background(), models.Destination{Country: "Britain"}, "USD", lines)
+		assert.ErrorIs(t, err, ErrInvalidDestination)
+	})
+}
+
+func TestDiscountedTaxLines(t *testing.T) {
+	promotions := &models.PromotionResult{Discounts: []models.Discount{
+		{PromotionID: 1, Line: 1, Amount: usd(300)},
+		{PromotionID: 2, Line: -1, Amount: usd(0)},
+	}}
+	lines, err := discountedTaxLines([]string{"standard", "reduced"}, []models.Money{usd(1000), usd(2000)}, promotions)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.TaxLine{{TaxClass: "standard", Amount: usd(1000)}, {TaxClass: "reduced", Amount: usd(1700)}}, lines)
+}
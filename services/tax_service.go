@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrTaxRateNotFound = repository.ErrTaxRateNotFound
+	ErrInvalidTaxRate  = errors.New("invalid tax rate")
+	ErrInvalidTaxClass = errors.New("invalid tax class")
+	// ErrInvalidDestination is a destination whose country isn't a country code
+	ErrInvalidDestination = errors.New("invalid destination")
+)
+
+// taxClassCodeLetters are what a tax class code is made of
+const taxClassCodeLetters = "abcdefghijklmnopqrstuvwxyz0123456789_-"
+
+// TaxService manages the tax classes and the rate table and previews the
+// tax on carts. Checkout taxes orders itself, see orderService.Checkout.
+type TaxService interface {
+	GetTaxClasses(ctx context.Context) ([]models.TaxClass, error)
+	CreateTaxClass(ctx context.Context, class *models.TaxClass) error
+
+	GetTaxRates(ctx context.Context, filter models.TaxRateFilter) ([]models.TaxRate, error)
+	GetTaxRate(ctx context.Context, id int) (*models.TaxRate, error)
+	CreateTaxRate(ctx context.Context, rate *models.TaxRate) error
+	UpdateTaxRate(ctx context.Context, rate *models.TaxRate) error
+	DeleteTaxRate(ctx context.Context, id int) error
+
+	// EvaluateCart works out the tax on the owner's cart shipping to
+	// destination, after the promotions and the coupon codes are applied
+	EvaluateCart(ctx context.Context, owner models.CartOwner, destination models.Destination, codes []string) (*models.Cart, *models.PromotionResult, *models.TaxResult, error)
+}
+
+type taxService struct {
+	taxRepo          repository.TaxRepo
+	promotionService PromotionService
+	calculator       TaxCalculator
+	txManager        repository.TxManager
+}
+
+func NewTaxService(taxRepo repository.TaxRepo, promotionService PromotionService, calculator TaxCalculator, txManager repository.TxManager) TaxService {
+	return &taxService{taxRepo: taxRepo, promotionService: promotionService, calculator: calculator, txManager: txManager}
+}
+
+func (s *taxService) GetTaxClasses(ctx context.Context) ([]models.TaxClass, error) {
+	return s.taxRepo.ListClasses(ctx)
+}
+
+func (s *taxService) CreateTaxClass(ctx context.Context, class *models.TaxClass) error {
+	class.Code = strings.ToLower(strings.TrimSpace(class.Code))
+	class.Name = strings.TrimSpace(class.Name)
+	switch {
+	case class.Code == "" || strings.Trim(class.Code, taxClassCodeLetters) != "":
+		return fmt.Errorf("%w: code must be letters, digits, - or _", ErrInvalidTaxClass)
+	case class.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidTaxClass)
+	}
+	err := s.taxRepo.CreateClass(ctx, class)
+	if repository.IsDuplicate(err) {
+		return fmt.Errorf("%w: %q already exists", ErrInvalidTaxClass, class.Code)
+	}
+	return err
+}
+
+func (s *taxService) GetTaxRates(ctx context.Context, filter models.TaxRateFilter) ([]models.TaxRate, error) {
+	filter.Country = strings.ToUpper(filter.Country)
+	return s.taxRepo.ListRates(ctx, filter)
+}
+
+func (s *taxService) GetTaxRate(ctx context.Context, id int) (*models.TaxRate, error) {
+	return s.taxRepo.GetRate(ctx, id)
+}
+
+func (s *taxService) CreateTaxRate(ctx context.Context, rate *models.TaxRate) error {
+	if err := validateTaxRate(rate); err != nil {
+		return err
+	}
+	return taxRateError(s.taxRepo.CreateRate(ctx, rate), rate)
+}
+
+func (s *taxService) UpdateTaxRate(ctx context.Context, rate *models.TaxRate) error {
+	if err := validateTaxRate(rate); err != nil {
+		return err
+	}
+	err := s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		if _, err := tx.Taxes.GetRate(ctx, rate.ID); err != nil {
+			return err
+		}
+		return tx.Taxes.UpdateRate(ctx, rate)
+	})
+	return taxRateError(err, rate)
+}
+
+func (s *taxService) DeleteTaxRate(ctx context.Context, id int) error {
+	return s.taxRepo.DeleteRate(ctx, id)
+}
+
+func (s *taxService) EvaluateCart(ctx context.Context, owner models.CartOwner, destination models.Destination,
+	codes []string) (*models.Cart, *models.PromotionResult, *models.TaxResult, error) {
+	cart, promotions, err := s.promotionService.EvaluateCart(ctx, owner, codes)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	taxClasses := make([]string, len(cart.Items))
+	amounts := make([]models.Money, len(cart.Items))
+	for i, item := range cart.Items {
+		taxClasses[i] = item.TaxClass
+		if amounts[i], err = item.LineTotal(); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	lines, err := discountedTaxLines(taxClasses, amounts, promotions)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	taxes, err := s.calculator.Calculate(ctx, destination, cart.Currency, lines)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return cart, promotions, taxes, nil
+}
+
+// validateTaxRate normalises the rate's jurisdiction the way destinations
+// are and checks the rate is complete
+func validateTaxRate(rate *models.TaxRate) error {
+	rate.TaxClass = strings.ToLower(strings.TrimSpace(rate.TaxClass))
+	rate.Name = strings.TrimSpace(rate.Name)
+	destination := models.Destination{Country: rate.Country, Region: rate.Region, Postcode: rate.Postcode}.Normalize()
+	rate.Country, rate.Region, rate.Postcode = destination.Country, destination.Region, destination.Postcode
+
+	switch {
+	case rate.TaxClass == "":
+		return fmt.Errorf("%w: tax class is required", ErrInvalidTaxRate)
+	case !models.ValidCountry(rate.Country):
+		return fmt.Errorf("%w: country must be an ISO 3166-1 alpha-2 code", ErrInvalidTaxRate)
+	case strings.Contains(strings.TrimSuffix(rate.Postcode, "*"), "*") || rate.Postcode == "*":
+		return fmt.Errorf("%w: a postcode may only end in *, leave it empty to match them all", ErrInvalidTaxRate)
+	case !rate.Rate.Valid():
+		return fmt.Errorf("%w: rate must be between 0 and 100", ErrInvalidTaxRate)
+	case rate.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidTaxRate)
+	}
+	return nil
+}
+
+// taxRateError explains the database errors a rate can run into
+func taxRateError(err error, rate *models.TaxRate) error {
+	switch {
+	case repository.IsMissingReference(err):
+		return fmt.Errorf("%w: unknown tax class %q", ErrInvalidTaxRate, rate.TaxClass)
+	case repository.IsDuplicate(err):
+		return fmt.Errorf("%w: %s already has a rate for %s", ErrInvalidTaxRate, rate.TaxClass, describeJurisdiction(rate))
+	}
+	return err
+}
+
+func describeJurisdiction(rate *models.TaxRate) string {
+	jurisdiction := rate.Country
+	for _, part := range []string{rate.Region, rate.Postcode} {
+		if part != "" {
+			jurisdiction += "/" + part
+		}
+	}
+	return jurisdiction
+}
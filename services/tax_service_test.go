@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateTaxRate(t *testing.T) {
+	taxRepo := new(MockTaxRepo)
+	taxService := NewTaxService(taxRepo, nil, nil, inlineTx{repository.Repos{Taxes: taxRepo}})
+
+	t.Run("Success", func(t *testing.T) {
+		taxRepo.On("CreateRate", mock.MatchedBy(func(rate *models.TaxRate) bool {
+			return rate.Country == "US" && rate.Region == "NY" && rate.Postcode == "100*" && rate.TaxClass == "standard"
+		})).Return(nil).Once()
+
+		rate := &models.TaxRate{TaxClass: " Standard", Country: "us", Region: "ny", Postcode: "100*", Rate: 88750, Name: "NYC sales tax"}
+		assert.NoError(t, taxService.CreateTaxRate(context.Background(), rate))
+	})
+	t.Run("Unknown class", func(t *testing.T) {
+		taxRepo.On("CreateRate", mock.Anything).Return(&mysql.MySQLError{Number: 1452}).Once()
+
+		err := taxService.CreateTaxRate(context.Background(), &models.TaxRate{TaxClass: "luxury", Country: "US", Rate: 10000, Name: "Luxury tax"})
+		assert.ErrorIs(t, err, ErrInvalidTaxRate)
+		assert.Contains(t, err.Error(), "unknown tax class")
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		for name, rate := range map[string]models.TaxRate{
+			"No class":         {Country: "US", Rate: 10000, Name: "Tax"},
+			"Bad country":      {TaxClass: "standard", Country: "USA", Rate: 10000, Name: "Tax"},
+			"Wildcard inside":  {TaxClass: "standard", Country: "US", Postcode: "1*0", Rate: 10000, Name: "Tax"},
+			"Bare wildcard":    {TaxClass: "standard", Country: "US", Postcode: "*", Rate: 10000, Name: "Tax"},
+			"Over a hundred":   {TaxClass: "standard", Country: "US", Rate: 1000001, Name: "Tax"},
+			"Negative rate":    {TaxClass: "standard", Country: "US", Rate: -1, Name: "Tax"},
+			"Name is required": {TaxClass: "standard", Country: "US", Rate: 10000},
+		} {
+			err := taxService.CreateTaxRate(context.Background(), &rate)
+			assert.ErrorIs(t, err, ErrInvalidTaxRate, name)
+		}
+	})
+	taxRepo.AssertExpectations(t)
+}
+
+func TestUpdateTaxRate(t *testing.T) {
+	taxRepo := new(MockTaxRepo)
+	taxService := NewTaxService(taxRepo, nil, nil, inlineTx{repository.Repos{Taxes: taxRepo}})
+
+	taxRepo.On("GetRate", 9).Return(nil, ErrTaxRateNotFound).Once()
+
+	err := taxService.UpdateTaxRate(context.Background(), &models.TaxRate{ID: 9, TaxClass: "standard", Country: "US", Rate: 10000, Name: "Tax"})
+	assert.ErrorIs(t, err, ErrTaxRateNotFound)
+	taxRepo.AssertNotCalled(t, "UpdateRate", mock.Anything)
+}
+
+func TestEvaluateCartTaxes(t *testing.T) {
+	taxRepo, promotionRepo, cartRepo := new(MockTaxRepo), new(MockPromotionRepo), new(MockCartRepo)
+	cartService := NewCartService(cartRepo, inlineTx{}, "USD")
+	productService := NewProductService(new(MockProductRepo), new(MockVariantRepo), new(MockCategoryRepo), inlineTx{}, "USD")
+	promotionService := NewPromotionService(promotionRepo, cartService, productService, inlineTx{}, "USD")
+	calculator := NewLocalTaxCalculator(taxRepo, models.TaxInclusive, "", models.RoundHalfUp)
+	taxService := NewTaxService(taxRepo, promotionService, calculator, inlineTx{})
+
+	cartRepo.On("GetByUser", 7).Return(&models.Cart{ID: 3, UserID: 7, Currency: "USD"}, nil)
+	cartRepo.On("ListItems", 3).Return([]models.CartItem{
+		{ID: 8, ProductID: 1, TaxClass: "standard", Quantity: 1, UnitPrice: usd(2400)},
+		{ID: 9, ProductID: 2, TaxClass: "reduced", Quantity: 2, UnitPrice: usd(1050)},
+	}, nil)
+	promotionRepo.On("ListApplicable", []string{"WELCOME"}).Return([]models.Promotion{
+		{ID: 3, Name: "Welcome", Code: "WELCOME", Kind: models.PromotionFixed, Amount: usd(1200), ProductIDs: []int{1}, MinSubtotal: usd(0), Active: true},
+	}, nil)
+	taxRepo.On("ListRates", models.TaxRateFilter{Country: "FR"}).Return([]models.TaxRate{
+		{ID: 1, TaxClass: "standard", Country: "FR", Rate: 200000, Name: "TVA"},
+		{ID: 2, TaxClass: "reduced", Country: "FR", Rate: 50000, Name: "TVA réduite"},
+	}, nil)
+
+	cart, promotions, taxes, err := taxService.EvaluateCart(context.Background(), models.CartOwner{UserID: 7}, models.Destination{Country: "FR"}, []string{"WELCOME"})
+	assert.NoError(t, err)
+	assert.Len(t, cart.Items, 2)
+	assert.Equal(t, usd(1200), promotions.Discount)
+	// 12.00 left of the first line contains 2.00 of TVA, 21.00 contains 1.00
+	assert.Equal(t, usd(200), taxes.Lines[0].Tax)
+	assert.Equal(t, usd(100), taxes.Lines[1].Tax)
+	assert.Equal(t, usd(3300), taxes.Gross)
+	total, err := promotions.Total()
+	assert.NoError(t, err)
+	assert.Equal(t, total, taxes.Gross)
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"ecommerce/utils"
+	"errors"
+	"time"
+)
+
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, session revoked")
+)
+
+// TokenSigner creates access tokens, implemented by utils.JWTSigner
+type TokenSigner interface {
+	CreateToken(user *models.User) (string, *utils.Claims, error)
+}
+
+type TokenService interface {
+	Issue(ctx context.Context, user *models.User) (*models.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error)
+	Logout(ctx context.Context, principal *utils.Principal, refreshToken string, allSessions bool) error
+}
+
+type tokenService struct {
+	userRepo    repository.UserRepo
+	refreshRepo repository.RefreshTokenRepo
+	revokedRepo repository.RevokedTokenRepo
+	txManager   repository.TxManager
+	signer      TokenSigner
+}
+
+func NewTokenService(userRepo repository.UserRepo, refreshRepo repository.RefreshTokenRepo, revokedRepo repository.RevokedTokenRepo, txManager repository.TxManager, signer TokenSigner) TokenService {
+	return &tokenService{userRepo: userRepo, refreshRepo: refreshRepo, revokedRepo: revokedRepo, txManager: txManager, signer: signer}
+}
+
+// Issue starts a new session (refresh token family) for user
+func (s *tokenService) Issue(ctx context.Context, user *models.User) (*models.TokenPair, error) {
+	familyID, err := utils.NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(ctx, s.refreshRepo, user, familyID)
+}
+
+// Refresh rotates a refresh token: the presented token is consumed and a new
+// pair in the same family is returned. Presenting an already consumed token
+// means it was copied, so the whole family is revoked.
+func (s *tokenService) Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	stored, err := s.refreshRepo.GetByHash(ctx, utils.HashOpaqueToken(refreshToken))
+	if err != nil || stored == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if stored.RevokedAt != nil {
+		return nil, s.revokeFamily(ctx, stored.FamilyID)
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// consuming the old token and storing its successor succeed or fail together
+	var pair *models.TokenPair
+	reused := false
+	err = s.txManager.WithTx(ctx, func(tx repository.Repos) error {
+		consumed, err := tx.RefreshTokens.Revoke(ctx, stored.ID)
+		if err != nil {
+			return err
+		}
+		if !consumed { // lost the race against another refresh with the same token
+			reused = true
+			return nil
+		}
+
+		user, err := tx.Users.GetByID(ctx, stored.UserID) // reload so role changes apply
+		if err != nil || user == nil {
+			return ErrInvalidRefreshToken
+		}
+		pair, err = s.issue(ctx, tx.RefreshTokens, user, stored.FamilyID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, s.revokeFamily(ctx, stored.FamilyID)
+	}
+	return pair, nil
+}
+
+// Logout revokes the caller's access token and the session of refreshToken,
+// or every session of the user when allSessions is set
+func (s *tokenService) Logout(ctx context.Context, principal *utils.Principal, refreshToken string, allSessions bool) error {
+	if principal.TokenID != "" {
+		// the entry is needed until the token expires on its own, whatever TTL it was signed with
+		if err := s.revokedRepo.Revoke(ctx, principal.TokenID, principal.ExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	if allSessions {
+		return s.refreshRepo.RevokeAllForUser(ctx, principal.UserID)
+	}
+	if refreshToken == "" {
+		return nil
+	}
+	stored, err := s.refreshRepo.GetByHash(ctx, utils.HashOpaqueToken(refreshToken))
+	if err != nil || stored == nil || stored.UserID != principal.UserID {
+		return nil // nothing of the caller's to revoke
+	}
+	return s.refreshRepo.RevokeFamily(ctx, stored.FamilyID)
+}
+
+func (s *tokenService) issue(ctx context.Context, refreshRepo repository.RefreshTokenRepo, user *models.User, familyID string) (*models.TokenPair, error) {
+	accessToken, claims, err := s.signer.CreateToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	stored := &models.RefreshToken{
+		UserID:    user.Id,
+		TokenHash: hash,
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	}
+	if err := refreshRepo.Create(ctx, stored); err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		UserID:           user.Id,
+		AccessToken:      accessToken,
+		AccessExpiresAt:  claims.ExpiresAt.Time,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: stored.ExpiresAt,
+	}, nil
+}
+
+func (s *tokenService) revokeFamily(ctx context.Context, familyID string) error {
+	if err := s.refreshRepo.RevokeFamily(ctx, familyID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"ecommerce/utils"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRefreshTokenRepo struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenRepo) Create(ctx context.Context, token *models.RefreshToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepo) GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	args := m.Called(tokenHash)
+	token := args.Get(0)
+	if token != nil {
+		return token.(*models.RefreshToken), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRefreshTokenRepo) Revoke(ctx context.Context, id int) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	args := m.Called(familyID)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepo) RevokeAllForUser(ctx context.Context, userID int) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+type MockRevokedTokenRepo struct {
+	mock.Mock
+}
+
+func (m *MockRevokedTokenRepo) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	args := m.Called(jti, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockRevokedTokenRepo) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	args := m.Called(jti)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRevokedTokenRepo) DeleteExpired(ctx context.Context) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+// MockTokenService is used by the user service tests
+type MockTokenService struct {
+	mock.Mock
+}
+
+func (m *MockTokenService) Issue(ctx context.Context, user *models.User) (*models.TokenPair, error) {
+	args := m.Called(user)
+	pair := args.Get(0)
+	if pair != nil {
+		return pair.(*models.TokenPair), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTokenService) Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	args := m.Called(refreshToken)
+	pair := args.Get(0)
+	if pair != nil {
+		return pair.(*models.TokenPair), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTokenService) Logout(ctx context.Context, principal *utils.Principal, refreshToken string, allSessions bool) error {
+	args := m.Called(principal, refreshToken, allSessions)
+	return args.Error(0)
+}
+
+var testSigner = newTestSigner()
+
+func newTestSigner() utils.JWTSigner {
+	keys, err := utils.NewKeyManager([]utils.KeyConfig{
+		{ID: "test", Algorithm: "HS256", Secret: "0123456789abcdef0123456789abcdef", Active: true},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return utils.JWTSigner{Keys: keys, Issuer: "ecommerce", Audience: "ecommerce-api"}
+}
+
+// inlineTx runs units of work straight against the mocks
+type inlineTx struct {
+	repos repository.Repos
+}
+
+func (i inlineTx) WithTx(ctx context.Context, fn func(tx repository.Repos) error) error {
+	return fn(i.repos)
+}
+
+func newTestTokenService(userRepo *MockUserRepo, refreshRepo *MockRefreshTokenRepo, revokedRepo *MockRevokedTokenRepo) TokenService {
+	tx := inlineTx{repository.Repos{Users: userRepo, RefreshTokens: refreshRepo, RevokedTokens: revokedRepo}}
+	return NewTokenService(userRepo, refreshRepo, revokedRepo, tx, testSigner)
+}
+
+func TestIssueToken(t *testing.T) {
+	refreshRepo := new(MockRefreshTokenRepo)
+	tokenService := newTestTokenService(new(MockUserRepo), refreshRepo, new(MockRevokedTokenRepo))
+	user := &models.User{Id: 1, Username: "abhay123", Role: models.RoleCustomer}
+
+	t.Run("Success", func(t *testing.T) {
+		refreshRepo.On("Create", mock.AnythingOfType("*models.RefreshToken")).Return(nil).Once()
+
+		pair, err := tokenService.Issue(context.Background(), user)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, pair.AccessToken)
+		assert.NotEmpty(t, pair.RefreshToken)
+
+		stored := refreshRepo.Calls[0].Arguments.Get(0).(*models.RefreshToken)
+		assert.Equal(t, utils.HashOpaqueToken(pair.RefreshToken), stored.TokenHash) // only the hash is persisted
+		assert.Equal(t, 1, stored.UserID)
+		assert.NotEmpty(t, stored.FamilyID)
+	})
+	t.Run("Store failure", func(t *testing.T) {
+		refreshRepo.On("Create", mock.Anything).Return(errors.New("database error")).Once()
+
+		pair, err := tokenService.Issue(context.Background(), user)
+		assert.Error(t, err)
+		assert.Nil(t, pair)
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	user := &models.User{Id: 1, Username: "abhay123", Role: models.RoleCustomer}
+	hash := utils.HashOpaqueToken("refresh-token")
+
+	t.Run("Rotates", func(t *testing.T) {
+		userRepo := new(MockUserRepo)
+		refreshRepo := new(MockRefreshTokenRepo)
+		tokenService := newTestTokenService(userRepo, refreshRepo, new(MockRevokedTokenRepo))
+
+		stored := &models.RefreshToken{ID: 5, UserID: 1, TokenHash: hash, FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour)}
+		refreshRepo.On("GetByHash", hash).Return(stored, nil)
+		refreshRepo.On("Revoke", 5).Return(true, nil)
+		refreshRepo.On("Create", mock.MatchedBy(func(token *models.RefreshToken) bool {
+			return token.FamilyID == "family" // stays in the same family
+		})).Return(nil)
+		userRepo.On("GetByID", 1).Return(user, nil)
+
+		pair, err := tokenService.Refresh(context.Background(), "refresh-token")
+		assert.NoError(t, err)
+		assert.NotEqual(t, "refresh-token", pair.RefreshToken)
+		refreshRepo.AssertExpectations(t)
+	})
+	t.Run("Unknown token", func(t *testing.T) {
+		refreshRepo := new(MockRefreshTokenRepo)
+		tokenService := newTestTokenService(new(MockUserRepo), refreshRepo, new(MockRevokedTokenRepo))
+		refreshRepo.On("GetByHash", hash).Return(nil, errors.New("refresh token not found"))
+
+		_, err := tokenService.Refresh(context.Background(), "refresh-token")
+		assert.Equal(t, ErrInvalidRefreshToken, err)
+	})
+	t.Run("Expired", func(t *testing.T) {
+		refreshRepo := new(MockRefreshTokenRepo)
+		tokenService := newTestTokenService(new(MockUserRepo), refreshRepo, new(MockRevokedTokenRepo))
+		stored := &models.RefreshToken{ID: 5, UserID: 1, FamilyID: "family", ExpiresAt: time.Now().Add(-time.Minute)}
+		refreshRepo.On("GetByHash", hash).Return(stored, nil)
+
+		_, err := tokenService.Refresh(context.Background(), "refresh-token")
+		assert.Equal(t, ErrInvalidRefreshToken, err)
+		refreshRepo.AssertNotCalled(t, "Revoke", 5)
+	})
+	t.Run("Reuse revokes family", func(t *testing.T) {
+		refreshRepo := new(MockRefreshTokenRepo)
+		tokenService := newTestTokenService(new(MockUserRepo), refreshRepo, new(MockRevokedTokenRepo))
+		revokedAt := time.Now().Add(-time.Minute)
+		stored := &models.RefreshToken{ID: 5, UserID: 1, FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}
+		refreshRepo.On("GetByHash", hash).Return(stored, nil)
+		refreshRepo.On("RevokeFamily", "family").Return(nil)
+
+		_, err := tokenService.Refresh(context.Background(), "refresh-token")
+		assert.Equal(t, ErrRefreshTokenReused, err)
+		refreshRepo.AssertExpectations(t)
+	})
+	t.Run("Concurrent reuse revokes family", func(t *testing.T) {
+		refreshRepo := new(MockRefreshTokenRepo)
+		tokenService := newTestTokenService(new(MockUserRepo), refreshRepo, new(MockRevokedTokenRepo))
+		stored := &models.RefreshToken{ID: 5, UserID: 1, FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour)}
+		refreshRepo.On("GetByHash", hash).Return(stored, nil)
+		refreshRepo.On("Revoke", 5).Return(false, nil)
+		refreshRepo.On("RevokeFamily", "family").Return(nil)
+
+		_, err := tokenService.Refresh(context.Background(), "refresh-token")
+		assert.Equal(t, ErrRefreshTokenReused, err)
+		refreshRepo.AssertExpectations(t)
+	})
+}
+
+func TestLogout(t *testing.T) {
+	expiresAt := time.Now().Add(2 * time.Hour) // longer than utils.AccessTokenTTL
+	principal := &utils.Principal{UserID: 1, Username: "abhay123", TokenID: "jti-1", ExpiresAt: expiresAt}
+	hash := utils.HashOpaqueToken("refresh-token")
+
+	t.Run("Revokes access token and session", func(t *testing.T) {
+		refreshRepo := new(MockRefreshTokenRepo)
+		revokedRepo := new(MockRevokedTokenRepo)
+		tokenService := newTestTokenService(new(MockUserRepo), refreshRepo, revokedRepo)
+
+		revokedRepo.On("Revoke", "jti-1", expiresAt).Return(nil)
+		refreshRepo.On("GetByHash", hash).Return(&models.RefreshToken{ID: 5, UserID: 1, FamilyID: "family"}, nil)
+		refreshRepo.On("RevokeFamily", "family").Return(nil)
+
+		err := tokenService.Logout(context.Background(), principal, "refresh-token", false)
+		assert.NoError(t, err)
+		revokedRepo.AssertExpectations(t)
+		refreshRepo.AssertExpectations(t)
+	})
+	t.Run("Ignores other users' refresh tokens", func(t *testing.T) {
+		refreshRepo := new(MockRefreshTokenRepo)
+		revokedRepo := new(MockRevokedTokenRepo)
+		tokenService := newTestTokenService(new(MockUserRepo), refreshRepo, revokedRepo)
+
+		revokedRepo.On("Revoke", "jti-1", mock.Anything).Return(nil)
+		refreshRepo.On("GetByHash", hash).Return(&models.RefreshToken{ID: 6, UserID: 2, FamilyID: "other"}, nil)
+
+		err := tokenService.Logout(context.Background(), principal, "refresh-token", false)
+		assert.NoError(t, err)
+		refreshRepo.AssertNotCalled(t, "RevokeFamily", "other")
+	})
+	t.Run("All sessions", func(t *testing.T) {
+		refreshRepo := new(MockRefreshTokenRepo)
+		revokedRepo := new(MockRevokedTokenRepo)
+		tokenService := newTestTokenService(new(MockUserRepo), refreshRepo, revokedRepo)
+
+		revokedRepo.On("Revoke", "jti-1", mock.Anything).Return(nil)
+		refreshRepo.On("RevokeAllForUser", 1).Return(nil)
+
+		err := tokenService.Logout(context.Background(), principal, "", true)
+		assert.NoError(t, err)
+		refreshRepo.AssertExpectations(t)
+	})
+}
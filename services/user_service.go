@@ -1,82 +1,154 @@
 package services
 
 import (
+	"context"
 	"ecommerce/models"
 	"ecommerce/repository"
 	"ecommerce/utils"
 	"errors"
 	"fmt"
+	"log"
 )
 
+// ErrUserExists is returned when another account already has the username or email
+var ErrUserExists = errors.New("username or email is already in use")
+
 type UserService interface {
-	Login(username, password string) (string, error)
-	CreateUser(user *models.User) error
-	GetUserByID(id int) (*models.User, error)
-	GetAllUser() ([]models.User, error)
-	UpdateUser(user *models.User) error
-	DeleteUser(id int) error
+	Login(ctx context.Context, username, password string) (*models.TokenPair, error)
+	CheckPassword(user *models.User, password string) error
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUserByID(ctx context.Context, id int) (*models.User, error)
+	GetAllUser(ctx context.Context) ([]models.User, error)
+	UpdateUser(ctx context.Context, user *models.User) error
+	DeleteUser(ctx context.Context, id int) error
 }
 
 type userService struct {
 	userRepo repository.UserRepo
+	hasher   utils.PasswordHasher
+	tokens   TokenService
 }
 
-func NewUserService(userRepo repository.UserRepo) UserService {
-	return &userService{userRepo: userRepo}
+func NewUserService(userRepo repository.UserRepo, hasher utils.PasswordHasher, tokens TokenService) UserService {
+	return &userService{userRepo: userRepo, hasher: hasher, tokens: tokens}
 }
 
-func (s *userService) Login(username, password string) (string, error) {
-	user, err := s.userRepo.GetByUsername(username)
+func (s *userService) Login(ctx context.Context, username, password string) (*models.TokenPair, error) {
+	user, err := s.userRepo.GetByUsername(ctx, username)
 	if err != nil {
-		return "", errors.New("invalid username or password")
+		return nil, errors.New("invalid username or password")
 	}
 
-	if user.Password != password {
-		return "", errors.New("invalid username or password")
+	match, needsRehash, err := s.hasher.Verify(user.Password, password)
+	if err != nil || !match {
+		return nil, errors.New("invalid username or password")
 	}
 
-	token, err := utils.CreateToken(username)
-	if err != nil {
-		return "", err
+	// upgrade plaintext or weaker hashes now that we know the password
+	if needsRehash {
+		if hash, err := s.hasher.Hash(password); err == nil {
+			user.Password = hash
+			if err := s.userRepo.Update(ctx, user); err != nil {
+				log.Printf("failed to rehash password for user %d: %v", user.Id, err)
+			}
+		}
+	}
+
+	return s.tokens.Issue(ctx, user)
+}
+
+// CheckPassword confirms password against the stored hash, used before sensitive self-service changes
+func (s *userService) CheckPassword(user *models.User, password string) error {
+	match, _, err := s.hasher.Verify(user.Password, password)
+	if err != nil || !match {
+		return errors.New("invalid password")
 	}
-	return token, nil
+	return nil
 }
 
-func (s *userService) CreateUser(user *models.User) error {
+func (s *userService) CreateUser(ctx context.Context, user *models.User) error {
 	if user.Name == "" || user.Email == "" || user.Password == "" {
 		return errors.New("all fields are required")
 	}
+	if user.Role == "" {
+		user.Role = models.RoleCustomer
+	}
+	if !user.Role.Valid() {
+		return errors.New("invalid role")
+	}
 
-	existingUser, _ := s.userRepo.GetByID(user.Id)
+	existingUser, _ := s.userRepo.GetByID(ctx, user.Id)
 	if existingUser != nil {
 		return errors.New("id already registered")
 	}
 
+	hash, err := s.hasher.Hash(user.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		if repository.IsDuplicate(err) {
+			return ErrUserExists
+		}
+		return err
+	}
 	fmt.Println("User registered successfully")
-	return s.userRepo.Create(user)
+	return nil
 }
 
-func (s *userService) GetUserByID(id int) (*models.User, error) {
-	return s.userRepo.GetByID(id)
+func (s *userService) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	return s.userRepo.GetByID(ctx, id)
 }
 
-func (s *userService) GetAllUser() ([]models.User, error) {
-	return s.userRepo.GetAll()
+func (s *userService) GetAllUser(ctx context.Context) ([]models.User, error) {
+	return s.userRepo.GetAll(ctx)
 }
 
-func (s *userService) UpdateUser(user *models.User) error {
+func (s *userService) UpdateUser(ctx context.Context, user *models.User) error {
 	if user.Name == "" || user.Email == "" || user.Password == "" {
 		return errors.New("all fields are required")
 	}
 
-	existingUser, err := s.userRepo.GetByID(user.Id)
+	existingUser, err := s.userRepo.GetByID(ctx, user.Id)
 	if err != nil || existingUser == nil {
 		return errors.New("user not found")
 	}
+	if user.Role == "" {
+		user.Role = existingUser.Role
+	}
+	if user.Role == "" {
+		user.Role = models.RoleCustomer // rows created before roles existed
+	}
+	if !user.Role.Valid() {
+		return errors.New("invalid role")
+	}
+	if user.Username != existingUser.Username {
+		if other, err := s.userRepo.GetByUsername(ctx, user.Username); err == nil && other.Id != user.Id {
+			return fmt.Errorf("%w: username %q is taken", ErrUserExists, user.Username)
+		}
+	}
+
+	// only a changed password is hashed, an unchanged one is already stored hashed
+	if user.Password != existingUser.Password {
+		hash, err := s.hasher.Hash(user.Password)
+		if err != nil {
+			return err
+		}
+		user.Password = hash
+	}
 
-	return s.userRepo.Update(user)
+	// the unique keys still catch a clash that raced the check above, or an email one
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		if repository.IsDuplicate(err) {
+			return ErrUserExists
+		}
+		return err
+	}
+	return nil
 }
 
-func (s *userService) DeleteUser(id int) error {
-	return s.userRepo.Delete(id)
+func (s *userService) DeleteUser(ctx context.Context, id int) error {
+	return s.userRepo.Delete(ctx, id)
 }
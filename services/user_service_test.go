@@ -1,11 +1,15 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"testing"
 
 	"ecommerce/models"
+	"ecommerce/utils"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -14,12 +18,12 @@ type MockUserRepo struct {
 	mock.Mock // provides mocking functionality
 }
 
-func (m *MockUserRepo) Create(user *models.User) error {
+func (m *MockUserRepo) Create(ctx context.Context, user *models.User) error {
 	args := m.Called(user) // Calls the Create method
 	return args.Error(0)   // returns the first argument
 }
 
-func (m *MockUserRepo) GetByID(id int) (*models.User, error) {
+func (m *MockUserRepo) GetByID(ctx context.Context, id int) (*models.User, error) {
 	args := m.Called(id)
 	user := args.Get(0)
 	if user != nil { // user object is found, it returns the user and the error
@@ -28,7 +32,7 @@ func (m *MockUserRepo) GetByID(id int) (*models.User, error) {
 	return nil, args.Error(1) // no user is found, it returns nil and the error.
 }
 
-func (m *MockUserRepo) GetByUsername(username string) (*models.User, error) {
+func (m *MockUserRepo) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	args := m.Called(username)
 	user := args.Get(0)
 	if user != nil {
@@ -37,61 +41,107 @@ func (m *MockUserRepo) GetByUsername(username string) (*models.User, error) {
 	return nil, args.Error(1)
 }
 
-func (m *MockUserRepo) GetAll() ([]models.User, error) {
+func (m *MockUserRepo) GetAll(ctx context.Context) ([]models.User, error) {
 	args := m.Called()
 	return args.Get(0).([]models.User), args.Error(1)
 }
 
-func (m *MockUserRepo) Update(user *models.User) error {
+func (m *MockUserRepo) Update(ctx context.Context, user *models.User) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
 
-func (m *MockUserRepo) Delete(id int) error {
+func (m *MockUserRepo) Delete(ctx context.Context, id int) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
 func TestLogin(t *testing.T) {
 	mockRepo := new(MockUserRepo) // Creates a mock repository
-	userService := NewUserService(mockRepo)
+	mockTokens := new(MockTokenService)
+	userService := NewUserService(mockRepo, utils.NewPasswordHasher(), mockTokens)
 
 	user := &models.User{
 		Id:       1,
 		Username: "abhay123",
 		Password: "abhay@123",
 	}
+	pair := &models.TokenPair{UserID: 1, AccessToken: "access", RefreshToken: "refresh"}
+	mockTokens.On("Issue", mock.Anything).Return(pair, nil)
+
 	t.Run("success	", func(t *testing.T) {
 		mockRepo.On("GetByUsername", "abhay123").Return(user, nil)
+		mockRepo.On("Update", user).Return(nil).Once() // legacy plaintext row is rehashed
 
-		token, err := userService.Login("abhay123", "abhay@123")
+		tokens, err := userService.Login(context.Background(), "abhay123", "abhay@123")
 		assert.NoError(t, err)
-		assert.NotEmpty(t, token) // token should be generated.
+		assert.Equal(t, pair, tokens) // tokens should be issued.
+		assert.NotEqual(t, "abhay@123", user.Password)
 		// Verify that all expectations were met
 		mockRepo.AssertExpectations(t)
 	})
 
+	t.Run("success (already hashed)", func(t *testing.T) {
+		mockRepo.ExpectedCalls = nil
+		mockRepo.Calls = nil
+		mockRepo.On("GetByUsername", "abhay123").Return(user, nil)
+
+		tokens, err := userService.Login(context.Background(), "abhay123", "abhay@123")
+		assert.NoError(t, err)
+		assert.NotNil(t, tokens)
+		mockRepo.AssertNotCalled(t, "Update", user)
+	})
+
+	t.Run("success (bcrypt upgraded to argon2id)", func(t *testing.T) {
+		bcryptHash, err := utils.NewBcryptHasher(4).Hash("abhay@123")
+		assert.NoError(t, err)
+		bcryptUser := &models.User{Id: 2, Username: "yash123", Password: bcryptHash}
+
+		mockRepo.ExpectedCalls = nil
+		mockRepo.On("GetByUsername", "yash123").Return(bcryptUser, nil)
+		mockRepo.On("Update", bcryptUser).Return(nil)
+
+		tokens, err := userService.Login(context.Background(), "yash123", "abhay@123")
+		assert.NoError(t, err)
+		assert.NotNil(t, tokens)
+		assert.True(t, strings.HasPrefix(bcryptUser.Password, "$argon2id$"))
+		mockRepo.AssertExpectations(t)
+	})
+
 	t.Run("fail (incorrect password)", func(t *testing.T) {
 		mockRepo.On("GetByUsername", "abhay123").Return(user, nil)
-		token, err := userService.Login("abhay123", "wrong_password")
+		tokens, err := userService.Login(context.Background(), "abhay123", "wrong_password")
 		assert.Error(t, err) // should return an error
-		assert.Empty(t, token)
+		assert.Nil(t, tokens)
 		assert.Equal(t, "invalid username or password", err.Error())
 		mockRepo.AssertExpectations(t) // Verify that all expectations were met
 	})
 
 	t.Run("fail (Not exist user)", func(t *testing.T) {
 		mockRepo.On("GetByUsername", "non_existent").Return(nil, errors.New("not found"))
-		token, err := userService.Login("non_existent", "password")
+		tokens, err := userService.Login(context.Background(), "non_existent", "password")
 		assert.Error(t, err)
-		assert.Empty(t, token)
+		assert.Nil(t, tokens)
 		mockRepo.AssertExpectations(t) // Verify that all expectations were met
 	})
 }
 
+func TestCheckPassword(t *testing.T) {
+	userService := NewUserService(new(MockUserRepo), utils.NewPasswordHasher(), new(MockTokenService))
+
+	hash, err := utils.NewBcryptHasher(4).Hash("abhay@123")
+	assert.NoError(t, err)
+	user := &models.User{Id: 1, Username: "abhay123", Password: hash}
+
+	assert.NoError(t, userService.CheckPassword(user, "abhay@123"))
+	err = userService.CheckPassword(user, "wrong")
+	assert.Error(t, err)
+	assert.Equal(t, "invalid password", err.Error())
+}
+
 func TestCreateUser(t *testing.T) {
 	mockRepo := new(MockUserRepo)
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, utils.NewPasswordHasher(), new(MockTokenService))
 	user := &models.User{
 		Id:       1,
 		Name:     "Abhay",
@@ -104,15 +154,18 @@ func TestCreateUser(t *testing.T) {
 		mockRepo.On("GetByID", 1).Return(nil, errors.New("not found"))
 		mockRepo.On("Create", user).Return(nil)
 
-		err := userService.CreateUser(user)
+		err := userService.CreateUser(context.Background(), user)
 		assert.NoError(t, err)
+		assert.Equal(t, models.RoleCustomer, user.Role) // default role
+		// stored hashed, never plaintext
+		assert.True(t, strings.HasPrefix(user.Password, "$argon2id$"))
 		mockRepo.AssertExpectations(t) // Verify that all expectations were met
 	})
 	t.Run("Alredy exist", func(t *testing.T) {
 		mockRepo.ExpectedCalls = nil
 		mockRepo.On("GetByID", 1).Return(user, nil)
 
-		err := userService.CreateUser(user)
+		err := userService.CreateUser(context.Background(), user)
 		assert.Error(t, err)
 		assert.Equal(t, "id already registered", err.Error())
 		mockRepo.AssertExpectations(t) // Verify that all expectations were met
@@ -135,7 +188,7 @@ func TestCreateUser(t *testing.T) {
 
 		for _, tc := range tests {
 			t.Run(tc.name, func(t *testing.T) {
-				err := userService.CreateUser(tc.user)
+				err := userService.CreateUser(context.Background(), tc.user)
 				assert.Error(t, err)
 				assert.Equal(t, "all fields are required", err.Error())
 			})
@@ -145,7 +198,7 @@ func TestCreateUser(t *testing.T) {
 
 func TestGetUserByID(t *testing.T) {
 	mockRepo := new(MockUserRepo)
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, utils.NewPasswordHasher(), new(MockTokenService))
 	mockuser := &models.User{
 		Id:       1,
 		Name:     "Abhay",
@@ -156,7 +209,7 @@ func TestGetUserByID(t *testing.T) {
 
 	t.Run("User found", func(t *testing.T) {
 		mockRepo.On("GetByID", 1).Return(mockuser, nil)
-		user, err := userService.GetUserByID(1)
+		user, err := userService.GetUserByID(context.Background(), 1)
 		assert.NoError(t, err)
 		assert.Equal(t, mockuser, user)
 		mockRepo.AssertExpectations(t) // Verify that all expectations were met
@@ -164,7 +217,7 @@ func TestGetUserByID(t *testing.T) {
 
 	t.Run("Not found", func(t *testing.T) {
 		mockRepo.On("GetByID", 99).Return(nil, errors.New("not found"))
-		user, err := userService.GetUserByID(99)
+		user, err := userService.GetUserByID(context.Background(), 99)
 		assert.Error(t, err)
 		assert.Nil(t, user)
 		mockRepo.AssertExpectations(t) // Verify that all expectations were met
@@ -173,7 +226,7 @@ func TestGetUserByID(t *testing.T) {
 
 func TestGetAllUser(t *testing.T) {
 	mockRepo := new(MockUserRepo)
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, utils.NewPasswordHasher(), new(MockTokenService))
 
 	mockUsers := []models.User{
 		{
@@ -193,7 +246,7 @@ func TestGetAllUser(t *testing.T) {
 
 	t.Run("User found", func(t *testing.T) {
 		mockRepo.On("GetAll").Return(mockUsers, nil)
-		users, err := userService.GetAllUser()
+		users, err := userService.GetAllUser(context.Background())
 		assert.NoError(t, err)
 		assert.Equal(t, 2, len(users))
 		assert.Equal(t, mockUsers, users)
@@ -203,7 +256,7 @@ func TestGetAllUser(t *testing.T) {
 	t.Run("Not found", func(t *testing.T) {
 		mockRepo.ExpectedCalls = nil
 		mockRepo.On("GetAll").Return([]models.User{}, errors.New("database error"))
-		users, err := userService.GetAllUser()
+		users, err := userService.GetAllUser(context.Background())
 		assert.Error(t, err)
 		assert.Empty(t, users)
 		mockRepo.AssertExpectations(t) // Verify that all expectations were met
@@ -212,7 +265,7 @@ func TestGetAllUser(t *testing.T) {
 
 func TestUpdateUser(t *testing.T) {
 	mockRepo := new(MockUserRepo)
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, utils.NewPasswordHasher(), new(MockTokenService))
 
 	user := &models.User{
 		Id:       1,
@@ -226,8 +279,21 @@ func TestUpdateUser(t *testing.T) {
 		mockRepo.On("GetByID", 1).Return(user, nil)
 		mockRepo.On("Update", user).Return(nil)
 
-		err := userService.UpdateUser(user)
+		err := userService.UpdateUser(context.Background(), user)
 		assert.NoError(t, err)
+		assert.Equal(t, "abhay@123", user.Password) // unchanged password is not hashed again
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Password changed", func(t *testing.T) {
+		mockRepo.ExpectedCalls = nil
+		changed := &models.User{Id: 1, Name: "Abhay", Email: "abhay123@gmail.com", Password: "new@123"}
+		mockRepo.On("GetByID", 1).Return(user, nil)
+		mockRepo.On("Update", changed).Return(nil)
+
+		err := userService.UpdateUser(context.Background(), changed)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(changed.Password, "$argon2id$"))
 		mockRepo.AssertExpectations(t)
 	})
 
@@ -235,7 +301,7 @@ func TestUpdateUser(t *testing.T) {
 		mockRepo.ExpectedCalls = nil
 		mockRepo.On("GetByID", 99).Return(nil, nil) // Simulating user not found
 
-		err := userService.UpdateUser(&models.User{
+		err := userService.UpdateUser(context.Background(), &models.User{
 			Id:       99,
 			Name:     "Abhay",
 			Email:    "abhay123@gmail.com",
@@ -250,12 +316,41 @@ func TestUpdateUser(t *testing.T) {
 		mockRepo.ExpectedCalls = nil
 		mockRepo.On("GetByID", 1).Return(nil, errors.New("database error"))
 
-		err := userService.UpdateUser(user)
+		err := userService.UpdateUser(context.Background(), user)
 		assert.Error(t, err)
 		assert.Equal(t, "user not found", err.Error()) // Expected output matches function behavior
 
 		mockRepo.AssertExpectations(t)
 	})
+	t.Run("Invalid role", func(t *testing.T) {
+		mockRepo.ExpectedCalls = nil
+		mockRepo.Calls = nil
+		mockRepo.On("GetByID", 1).Return(user, nil)
+
+		err := userService.UpdateUser(context.Background(), &models.User{Id: 1, Name: "Abhay", Email: "abhay123@gmail.com", Password: "abhay@123", Role: "superuser"})
+		assert.Error(t, err)
+		assert.Equal(t, "invalid role", err.Error())
+		mockRepo.AssertNotCalled(t, "Update", mock.Anything)
+	})
+	t.Run("Username taken", func(t *testing.T) {
+		mockRepo.ExpectedCalls = nil
+		mockRepo.Calls = nil
+		mockRepo.On("GetByID", 1).Return(user, nil)
+		mockRepo.On("GetByUsername", "yash123").Return(&models.User{Id: 2, Username: "yash123"}, nil)
+
+		err := userService.UpdateUser(context.Background(), &models.User{Id: 1, Name: "Abhay", Email: "abhay123@gmail.com", Username: "yash123", Password: "abhay@123"})
+		assert.ErrorIs(t, err, ErrUserExists)
+		mockRepo.AssertNotCalled(t, "Update", mock.Anything)
+	})
+	t.Run("Email taken", func(t *testing.T) {
+		mockRepo.ExpectedCalls = nil
+		changed := &models.User{Id: 1, Name: "Abhay", Email: "yash123@gmail.com", Password: "abhay@123"}
+		mockRepo.On("GetByID", 1).Return(user, nil)
+		mockRepo.On("Update", changed).Return(&mysql.MySQLError{Number: 1062})
+
+		err := userService.UpdateUser(context.Background(), changed)
+		assert.ErrorIs(t, err, ErrUserExists)
+	})
 	t.Run("Missing Fields", func(t *testing.T) {
 		tests := []struct {
 			name string
@@ -274,7 +369,7 @@ func TestUpdateUser(t *testing.T) {
 
 		for _, tc := range tests {
 			t.Run(tc.name, func(t *testing.T) {
-				err := userService.UpdateUser(tc.user)
+				err := userService.UpdateUser(context.Background(), tc.user)
 				assert.Error(t, err)
 				assert.Equal(t, "all fields are required", err.Error())
 			})
@@ -284,18 +379,18 @@ func TestUpdateUser(t *testing.T) {
 
 func TestDeleteUser(t *testing.T) {
 	mockRepo := new(MockUserRepo)
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, utils.NewPasswordHasher(), new(MockTokenService))
 
 	t.Run("User found", func(t *testing.T) {
 		mockRepo.On("Delete", 1).Return(nil)
-		err := userService.DeleteUser(1)
+		err := userService.DeleteUser(context.Background(), 1)
 		assert.NoError(t, err)
 	})
 
 	t.Run("User not found", func(t *testing.T) {
 		mockRepo.ExpectedCalls = nil
 		mockRepo.On("Delete", 99).Return(errors.New("not found"))
-		err := userService.DeleteUser(99)
+		err := userService.DeleteUser(context.Background(), 99)
 		assert.Error(t, err)
 		mockRepo.AssertExpectations(t) // Verify that all expectations were met
 	})
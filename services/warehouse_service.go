@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"ecommerce/repository"
+	"ecommerce/utils"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrWarehouseNotFound = repository.ErrWarehouseNotFound
+	ErrInvalidWarehouse  = errors.New("invalid warehouse")
+)
+
+type WarehouseService interface {
+	CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error
+	GetWarehouse(ctx context.Context, id int) (*models.Warehouse, error)
+	GetWarehouses(ctx context.Context) ([]models.Warehouse, error)
+	UpdateWarehouse(ctx context.Context, warehouse *models.Warehouse) error
+}
+
+type warehouseService struct {
+	warehouseRepo repository.WarehouseRepo
+}
+
+func NewWarehouseService(warehouseRepo repository.WarehouseRepo) WarehouseService {
+	return &warehouseService{warehouseRepo: warehouseRepo}
+}
+
+func (s *warehouseService) CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	if err := validateWarehouse(warehouse); err != nil {
+		return err
+	}
+	return codeTaken(s.warehouseRepo.Create(ctx, warehouse), warehouse.Code)
+}
+
+func (s *warehouseService) GetWarehouse(ctx context.Context, id int) (*models.Warehouse, error) {
+	return s.warehouseRepo.GetByID(ctx, id)
+}
+
+func (s *warehouseService) GetWarehouses(ctx context.Context) ([]models.Warehouse, error) {
+	return s.warehouseRepo.GetAll(ctx)
+}
+
+// UpdateWarehouse replaces the warehouse, deactivating it keeps its stock but stops allocation from it
+func (s *warehouseService) UpdateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	if err := validateWarehouse(warehouse); err != nil {
+		return err
+	}
+	if _, err := s.warehouseRepo.GetByID(ctx, warehouse.ID); err != nil {
+		return err
+	}
+	return codeTaken(s.warehouseRepo.Update(ctx, warehouse), warehouse.Code)
+}
+
+func validateWarehouse(warehouse *models.Warehouse) error {
+	warehouse.Name = strings.TrimSpace(warehouse.Name)
+	warehouse.Code = utils.Slugify(warehouse.Code)
+	switch {
+	case warehouse.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidWarehouse)
+	case warehouse.Code == "":
+		return fmt.Errorf("%w: code is required", ErrInvalidWarehouse)
+	}
+	if location := warehouse.Location; location != nil {
+		if location.Latitude < -90 || location.Latitude > 90 || location.Longitude < -180 || location.Longitude > 180 {
+			return fmt.Errorf("%w: location is out of range", ErrInvalidWarehouse)
+		}
+	}
+	return nil
+}
+
+func codeTaken(err error, code string) error {
+	if repository.IsDuplicate(err) {
+		return fmt.Errorf("%w: code %q is already used", ErrInvalidWarehouse, code)
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"ecommerce/models"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateWarehouse(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		warehouseRepo := new(MockWarehouseRepo)
+		warehouseService := NewWarehouseService(warehouseRepo)
+		warehouseRepo.On("Create", mock.Anything).Return(nil)
+
+		warehouse := &models.Warehouse{Code: "Lisbon North", Name: " Lisbon ", Active: true}
+		assert.NoError(t, warehouseService.CreateWarehouse(context.Background(), warehouse))
+		assert.Equal(t, "lisbon-north", warehouse.Code)
+		assert.Equal(t, "Lisbon", warehouse.Name)
+	})
+	t.Run("Duplicate code", func(t *testing.T) {
+		warehouseRepo := new(MockWarehouseRepo)
+		warehouseService := NewWarehouseService(warehouseRepo)
+		warehouseRepo.On("Create", mock.Anything).Return(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+
+		err := warehouseService.CreateWarehouse(context.Background(), &models.Warehouse{Code: "main", Name: "Main"})
+		assert.ErrorIs(t, err, ErrInvalidWarehouse)
+	})
+	t.Run("Validation", func(t *testing.T) {
+		warehouseService := NewWarehouseService(new(MockWarehouseRepo))
+
+		for _, warehouse := range []*models.Warehouse{
+			{Code: "main"},
+			{Name: "Main"},
+			{Code: "main", Name: "Main", Location: &models.GeoPoint{Latitude: 91}},
+		} {
+			assert.ErrorIs(t, warehouseService.CreateWarehouse(context.Background(), warehouse), ErrInvalidWarehouse)
+		}
+	})
+}
+
+func TestUpdateWarehouse(t *testing.T) {
+	warehouseRepo := new(MockWarehouseRepo)
+	warehouseService := NewWarehouseService(warehouseRepo)
+	warehouseRepo.On("GetByID", 9).Return(nil, ErrWarehouseNotFound)
+
+	err := warehouseService.UpdateWarehouse(context.Background(), &models.Warehouse{ID: 9, Code: "main", Name: "Main"})
+	assert.Equal(t, ErrWarehouseNotFound, err)
+	warehouseRepo.AssertNotCalled(t, "Update", mock.Anything)
+}
@@ -1,45 +1,118 @@
 package utils
 
 import (
+	"context"
+	"crypto/rand"
+	"ecommerce/models"
+	"encoding/hex"
 	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var secretKey = []byte("secret-key")
+// access tokens are short lived, clients renew them with a refresh token
+const AccessTokenTTL = 15 * time.Minute
 
-type JWTVerifier struct{} // struct that provides a method to verify tokens
+var ErrTokenRevoked = errors.New("token has been revoked")
 
-func (j JWTVerifier) VerifyToken(tokenString string) (string, error) {
-	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) { // decoding and verifying a JWT token
-		return secretKey, nil
-	})
+// Claims is the payload carried by access tokens
+type Claims struct {
+	UserID   int           `json:"uid"`
+	Username string        `json:"username"`
+	Roles    []models.Role `json:"roles"`
+	jwt.RegisteredClaims
+}
+
+// RevocationList is consulted for every verified token, see repository.RevokedTokenRepo
+type RevocationList interface {
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// JWTSigner issues access tokens with the active key of Keys
+type JWTSigner struct {
+	Keys     *KeyManager
+	Issuer   string
+	Audience string
+	TTL      time.Duration // defaults to AccessTokenTTL
+}
+
+type JWTVerifier struct { // struct that provides a method to verify tokens
+	Keys        *KeyManager
+	Issuer      string
+	Audience    string
+	Revocations RevocationList // optional
+}
+
+func (j JWTVerifier) VerifyToken(ctx context.Context, tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, j.Keys.Keyfunc, // decoding and verifying a JWT token
+		jwt.WithValidMethods(j.Keys.Algorithms()), // pin algorithms, never trust the header alone
+		jwt.WithIssuer(j.Issuer),
+		jwt.WithAudience(j.Audience),
+		jwt.WithExpirationRequired(),
+	)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	// extracts claims (payload data) from a JWT token
-	// token.Claims holds the decoded claim
-	// .(jwt.MapClaims)) checks if the claims are of type jwt.MapClaims
-	// If token.Claims is successfully converted to jwt.MapClaims, ok = true.
-	// Otherwise, ok = false
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		username, _ := claims["username"].(string) // .(string)) ensures it's a string.
-		return username, nil
+	if !token.Valid {
+		return nil, errors.New("invalid token")
 	}
 
-	return "", errors.New("invalid token")
+	if j.Revocations != nil && claims.ID != "" {
+		revoked, err := j.Revocations.IsRevoked(ctx, claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return claims, nil
 }
 
-func CreateToken(username string) (string, error) {
-	claims := jwt.MapClaims{
-		"username": username,
-		"exp":      time.Now().Add(time.Hour * 2).Unix(),
+func (s JWTSigner) CreateToken(user *models.User) (string, *Claims, error) {
+	role := user.Role
+	if role == "" {
+		role = models.RoleCustomer
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims) // HMAC SHA-256 (HS256) as the signing algorithm.
-	tokenString, err := token.SignedString(secretKey)
+	jti, err := NewTokenID()
 	if err != nil {
-		return " ", err
+		return "", nil, err
+	}
+	ttl := s.TTL
+	if ttl == 0 {
+		ttl = AccessTokenTTL
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		UserID:   user.Id,
+		Username: user.Username,
+		Roles:    []models.Role{role},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    s.Issuer,
+			Audience:  jwt.ClaimStrings{s.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	key := s.Keys.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID // lets verifiers pick the right key during rotation
+	tokenString, err := token.SignedString(key.signKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return tokenString, claims, nil
+}
+
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
-	return tokenString, err
+	return hex.EncodeToString(b), nil
 }
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"context"
+	"ecommerce/models"
 	"testing"
 	"time"
 
@@ -8,41 +10,162 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func newTestKeys(t *testing.T, configs ...KeyConfig) *KeyManager {
+	if len(configs) == 0 {
+		configs = []KeyConfig{{ID: "test", Algorithm: "HS256", Secret: testSecret, Active: true}}
+	}
+	keys, err := NewKeyManager(configs)
+	assert.NoError(t, err)
+	return keys
+}
+
+type revocationList map[string]bool
+
+func (l revocationList) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return l[jti], nil
+}
+
 func TestCreateToken(t *testing.T) {
-	username := "testuser"
-	token, err := CreateToken(username)
+	signer := JWTSigner{Keys: newTestKeys(t), Issuer: "ecommerce", Audience: "ecommerce-api"}
+	user := &models.User{Username: "testuser", Role: models.RoleAdmin}
+	token, claims, err := signer.CreateToken(user)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
+	assert.WithinDuration(t, time.Now().Add(AccessTokenTTL), claims.ExpiresAt.Time, time.Second)
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	assert.NoError(t, err)
+	assert.Equal(t, "test", parsed.Header["kid"])
 }
 
 func TestVerifyToken(t *testing.T) {
-	verifier := JWTVerifier{}
+	keys := newTestKeys(t)
+	signer := JWTSigner{Keys: keys, Issuer: "ecommerce", Audience: "ecommerce-api"}
+	verifier := JWTVerifier{Keys: keys, Issuer: "ecommerce", Audience: "ecommerce-api"}
+
 	t.Run("ValidToken", func(t *testing.T) {
-		username := "testuser"
-		token, err := CreateToken(username)
+		user := &models.User{Id: 3, Username: "testuser", Role: models.RoleStaff}
+		token, _, err := signer.CreateToken(user)
 		assert.NoError(t, err)
 		assert.NotEmpty(t, token)
 
-		verifier := JWTVerifier{}
-		verifiedUsername, err := verifier.VerifyToken(token)
+		claims, err := verifier.VerifyToken(context.Background(), token)
 		assert.NoError(t, err)
-		assert.Equal(t, username, verifiedUsername)
+		assert.Equal(t, "testuser", claims.Username)
+		assert.Equal(t, []models.Role{models.RoleStaff}, claims.Roles)
+		assert.Equal(t, 3, claims.UserID)
+		assert.NotEmpty(t, claims.ID) // jti
+	})
+	t.Run("DefaultRole", func(t *testing.T) {
+		token, _, err := signer.CreateToken(&models.User{Username: "testuser"})
+		assert.NoError(t, err)
+
+		claims, err := verifier.VerifyToken(context.Background(), token)
+		assert.NoError(t, err)
+		assert.Equal(t, []models.Role{models.RoleCustomer}, claims.Roles)
+	})
+	t.Run("RevokedToken", func(t *testing.T) {
+		token, claims, err := signer.CreateToken(&models.User{Id: 1, Username: "testuser"})
+		assert.NoError(t, err)
+
+		revoking := verifier
+		revoking.Revocations = revocationList{claims.ID: true}
+		_, err = revoking.VerifyToken(context.Background(), token)
+		assert.Equal(t, ErrTokenRevoked, err)
+
+		revoking.Revocations = revocationList{}
+		_, err = revoking.VerifyToken(context.Background(), token)
+		assert.NoError(t, err)
+	})
+	t.Run("WrongIssuer", func(t *testing.T) {
+		other := signer
+		other.Issuer = "someone-else"
+		token, _, err := other.CreateToken(&models.User{Username: "testuser"})
+		assert.NoError(t, err)
+
+		_, err = verifier.VerifyToken(context.Background(), token)
+		assert.Error(t, err)
+	})
+	t.Run("WrongAudience", func(t *testing.T) {
+		other := signer
+		other.Audience = "another-api"
+		token, _, err := other.CreateToken(&models.User{Username: "testuser"})
+		assert.NoError(t, err)
+
+		_, err = verifier.VerifyToken(context.Background(), token)
+		assert.Error(t, err)
 	})
 	t.Run("InvalidToken", func(t *testing.T) {
-		_, err := verifier.VerifyToken("invalid.token.string")
+		_, err := verifier.VerifyToken(context.Background(), "invalid.token.string")
 		assert.Error(t, err)
 	})
 	t.Run("ExpiredToken", func(t *testing.T) {
 		expiredClaims := jwt.MapClaims{
 			"username": "testuser",
+			"iss":      "ecommerce",
+			"aud":      "ecommerce-api",
 			"exp":      time.Now().Add(-time.Hour).Unix(),
 		}
 		token := jwt.NewWithClaims(jwt.SigningMethodHS256, expiredClaims)
-		tokenString, err := token.SignedString(secretKey)
+		token.Header["kid"] = "test"
+		tokenString, err := token.SignedString([]byte(testSecret))
+		assert.NoError(t, err)
+
+		_, err = verifier.VerifyToken(context.Background(), tokenString)
+		assert.Error(t, err)
+	})
+	t.Run("UnpinnedAlgorithm", func(t *testing.T) {
+		// HS384 signed with the right secret must still be rejected
+		token := jwt.NewWithClaims(jwt.SigningMethodHS384, jwt.MapClaims{
+			"iss": "ecommerce", "aud": "ecommerce-api", "exp": time.Now().Add(time.Hour).Unix(),
+		})
+		token.Header["kid"] = "test"
+		tokenString, err := token.SignedString([]byte(testSecret))
+		assert.NoError(t, err)
+
+		_, err = verifier.VerifyToken(context.Background(), tokenString)
+		assert.Error(t, err)
+	})
+	t.Run("UnknownKid", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss": "ecommerce", "aud": "ecommerce-api", "exp": time.Now().Add(time.Hour).Unix(),
+		})
+		token.Header["kid"] = "retired"
+		tokenString, err := token.SignedString([]byte(testSecret))
 		assert.NoError(t, err)
 
-		verifier := JWTVerifier{}
-		_, err = verifier.VerifyToken(tokenString)
+		_, err = verifier.VerifyToken(context.Background(), tokenString)
 		assert.Error(t, err)
 	})
 }
+
+func TestKeyRotation(t *testing.T) {
+	rsaPrivate, _ := rsaKeyFiles(t)
+	edPrivate := ed25519KeyFile(t)
+
+	oldKeys := newTestKeys(t, KeyConfig{ID: "2024", Algorithm: "RS256", PrivateKeyFile: rsaPrivate, Active: true})
+	oldSigner := JWTSigner{Keys: oldKeys, Issuer: "ecommerce", Audience: "ecommerce-api"}
+	oldToken, _, err := oldSigner.CreateToken(&models.User{Username: "testuser"})
+	assert.NoError(t, err)
+
+	// the new EdDSA key signs, the old RSA key is kept for verification only
+	rotated := newTestKeys(t,
+		KeyConfig{ID: "2024", Algorithm: "RS256", PrivateKeyFile: rsaPrivate},
+		KeyConfig{ID: "2025", Algorithm: "EdDSA", PrivateKeyFile: edPrivate, Active: true},
+	)
+	signer := JWTSigner{Keys: rotated, Issuer: "ecommerce", Audience: "ecommerce-api"}
+	verifier := JWTVerifier{Keys: rotated, Issuer: "ecommerce", Audience: "ecommerce-api"}
+
+	newToken, _, err := signer.CreateToken(&models.User{Username: "testuser"})
+	assert.NoError(t, err)
+
+	_, err = verifier.VerifyToken(context.Background(), oldToken)
+	assert.NoError(t, err)
+	_, err = verifier.VerifyToken(context.Background(), newToken)
+	assert.NoError(t, err)
+
+	// once the old key is dropped its tokens stop verifying
+	retired := JWTVerifier{Keys: newTestKeys(t, KeyConfig{ID: "2025", Algorithm: "EdDSA", PrivateKeyFile: edPrivate, Active: true}), Issuer: "ecommerce", Audience: "ecommerce-api"}
+	_, err = retired.VerifyToken(context.Background(), oldToken)
+	assert.Error(t, err)
+}
@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeyConfig describes one JWT key. During a rotation the old key stays listed
+// (Active false) so tokens it signed still verify until they expire.
+type KeyConfig struct {
+	ID             string `json:"kid" yaml:"kid" toml:"kid"`
+	Algorithm      string `json:"alg" yaml:"alg" toml:"alg"` // HS256, RS256 or EdDSA
+	Secret         string `json:"secret,omitempty" yaml:"secret" toml:"secret"`
+	PrivateKeyFile string `json:"private_key_file,omitempty" yaml:"private_key_file" toml:"private_key_file"`
+	PublicKeyFile  string `json:"public_key_file,omitempty" yaml:"public_key_file" toml:"public_key_file"` // verify-only keys
+	Active         bool   `json:"active" yaml:"active" toml:"active"`                                      // signs new tokens
+}
+
+const minSecretLength = 32
+
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{} // nil for verify-only keys
+	verifyKey interface{}
+}
+
+// KeyManager holds the keys tokens are signed and verified with
+type KeyManager struct {
+	keys       map[string]*SigningKey
+	order      []string // kids in configuration order, for a stable JWKS
+	active     *SigningKey
+	algorithms []string
+}
+
+// LoadKeyConfigs reads a JSON array of KeyConfig
+func LoadKeyConfigs(path string) ([]KeyConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+	var configs []KeyConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %v", path, err)
+	}
+	return configs, nil
+}
+
+func NewKeyManager(configs []KeyConfig) (*KeyManager, error) {
+	m := &KeyManager{keys: map[string]*SigningKey{}}
+	for _, cfg := range configs {
+		if cfg.ID == "" {
+			return nil, errors.New("key id (kid) is required")
+		}
+		if _, exists := m.keys[cfg.ID]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", cfg.ID)
+		}
+
+		key, err := loadKey(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %v", cfg.ID, err)
+		}
+		if cfg.Active {
+			if m.active != nil {
+				return nil, fmt.Errorf("key %q: only one key can be active", cfg.ID)
+			}
+			if key.signKey == nil {
+				return nil, fmt.Errorf("key %q: active key needs a private key or secret", cfg.ID)
+			}
+			m.active = key
+		}
+
+		m.keys[cfg.ID] = key
+		m.order = append(m.order, cfg.ID)
+		if !containsString(m.algorithms, key.Method.Alg()) {
+			m.algorithms = append(m.algorithms, key.Method.Alg())
+		}
+	}
+	if m.active == nil {
+		return nil, errors.New("no active signing key configured")
+	}
+	return m, nil
+}
+
+func loadKey(cfg KeyConfig) (*SigningKey, error) {
+	key := &SigningKey{ID: cfg.ID}
+	switch cfg.Algorithm {
+	case "HS256":
+		if len(cfg.Secret) < minSecretLength {
+			return nil, fmt.Errorf("HS256 secret must be at least %d bytes", minSecretLength)
+		}
+		key.Method = jwt.SigningMethodHS256
+		key.signKey = []byte(cfg.Secret)
+		key.verifyKey = []byte(cfg.Secret)
+
+	case "RS256":
+		key.Method = jwt.SigningMethodRS256
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = private
+			key.verifyKey = &private.PublicKey
+		} else if cfg.PublicKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			public, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.verifyKey = public
+		} else {
+			return nil, errors.New("RS256 needs private_key_file or public_key_file")
+		}
+
+	case "EdDSA":
+		key.Method = jwt.SigningMethodEdDSA
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			private, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = private
+			key.verifyKey = private.(ed25519.PrivateKey).Public()
+		} else if cfg.PublicKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			public, err := jwt.ParseEdPublicKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.verifyKey = public
+		} else {
+			return nil, errors.New("EdDSA needs private_key_file or public_key_file")
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", cfg.Algorithm)
+	}
+	return key, nil
+}
+
+// Active returns the key new tokens are signed with
+func (m *KeyManager) Active() *SigningKey {
+	return m.active
+}
+
+// Algorithms lists the algorithms accepted when verifying, anything else is rejected
+func (m *KeyManager) Algorithms() []string {
+	return m.algorithms
+}
+
+// Keyfunc resolves the verification key from the token's kid header
+func (m *KeyManager) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, ok := m.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if t.Method.Alg() != key.Method.Alg() { // a key is only valid for its own algorithm
+		return nil, fmt.Errorf("unexpected signing method %s for key %q", t.Method.Alg(), kid)
+	}
+	return key.verifyKey, nil
+}
+
+// JWK is a public key in RFC 7517 format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // OKP curve
+	X   string `json:"x,omitempty"`   // OKP public key
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys, symmetric keys are never published
+func (m *KeyManager) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	b64 := base64.RawURLEncoding
+	for _, kid := range m.order {
+		key := m.keys[kid]
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA", Kid: kid, Alg: key.Method.Alg(), Use: "sig",
+				N: b64.EncodeToString(public.N.Bytes()),
+				E: b64.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP", Kid: kid, Alg: key.Method.Alg(), Use: "sig",
+				Crv: "Ed25519", X: b64.EncodeToString(public),
+			})
+		}
+	}
+	return set
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
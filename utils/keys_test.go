@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// writePEM stores a key in a temp dir the way an operator would provide it
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	assert.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+func rsaKeyFiles(t *testing.T) (string, string) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	assert.NoError(t, err)
+	return writePEM(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(private)),
+		writePEM(t, "rsa.pub", "PUBLIC KEY", publicDER)
+}
+
+func ed25519KeyFile(t *testing.T) string {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	assert.NoError(t, err)
+	return writePEM(t, "ed25519.pem", "PRIVATE KEY", der)
+}
+
+func TestNewKeyManager(t *testing.T) {
+	rsaPrivate, rsaPublic := rsaKeyFiles(t)
+
+	t.Run("Mixed algorithms", func(t *testing.T) {
+		keys, err := NewKeyManager([]KeyConfig{
+			{ID: "hs", Algorithm: "HS256", Secret: testSecret},
+			{ID: "rs", Algorithm: "RS256", PrivateKeyFile: rsaPrivate, Active: true},
+			{ID: "ed", Algorithm: "EdDSA", PrivateKeyFile: ed25519KeyFile(t)},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "rs", keys.Active().ID)
+		assert.Equal(t, []string{"HS256", "RS256", "EdDSA"}, keys.Algorithms())
+	})
+
+	tests := []struct {
+		name    string
+		configs []KeyConfig
+	}{
+		{"No active key", []KeyConfig{{ID: "hs", Algorithm: "HS256", Secret: testSecret}}},
+		{"Two active keys", []KeyConfig{
+			{ID: "a", Algorithm: "HS256", Secret: testSecret, Active: true},
+			{ID: "b", Algorithm: "HS256", Secret: testSecret, Active: true},
+		}},
+		{"Short secret", []KeyConfig{{ID: "hs", Algorithm: "HS256", Secret: "secret-key", Active: true}}},
+		{"Missing kid", []KeyConfig{{Algorithm: "HS256", Secret: testSecret, Active: true}}},
+		{"Duplicate kid", []KeyConfig{
+			{ID: "a", Algorithm: "HS256", Secret: testSecret, Active: true},
+			{ID: "a", Algorithm: "HS256", Secret: testSecret},
+		}},
+		{"Unsupported algorithm", []KeyConfig{{ID: "none", Algorithm: "none", Active: true}}},
+		{"Verify-only active key", []KeyConfig{{ID: "rs", Algorithm: "RS256", PublicKeyFile: rsaPublic, Active: true}}},
+		{"Missing file", []KeyConfig{{ID: "rs", Algorithm: "RS256", PrivateKeyFile: "/does/not/exist", Active: true}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewKeyManager(tc.configs)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoadKeyConfigs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`[{"kid": "k1", "alg": "HS256", "secret": "`+testSecret+`", "active": true}]`), 0600))
+
+	configs, err := LoadKeyConfigs(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []KeyConfig{{ID: "k1", Algorithm: "HS256", Secret: testSecret, Active: true}}, configs)
+
+	_, err = LoadKeyConfigs(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestJWKS(t *testing.T) {
+	rsaPrivate, _ := rsaKeyFiles(t)
+	keys, err := NewKeyManager([]KeyConfig{
+		{ID: "hs", Algorithm: "HS256", Secret: testSecret},
+		{ID: "rs", Algorithm: "RS256", PrivateKeyFile: rsaPrivate, Active: true},
+		{ID: "ed", Algorithm: "EdDSA", PrivateKeyFile: ed25519KeyFile(t)},
+	})
+	assert.NoError(t, err)
+
+	set := keys.JWKS()
+	assert.Len(t, set.Keys, 2) // the HMAC secret is never published
+	assert.Equal(t, "RSA", set.Keys[0].Kty)
+	assert.Equal(t, "rs", set.Keys[0].Kid)
+	assert.Equal(t, "AQAB", set.Keys[0].E)
+	assert.NotEmpty(t, set.Keys[0].N)
+	assert.Equal(t, "OKP", set.Keys[1].Kty)
+	assert.Equal(t, "Ed25519", set.Keys[1].Crv)
+	assert.NotEmpty(t, set.Keys[1].X)
+}
@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOpaqueToken(t *testing.T) {
+	token, hash, err := NewOpaqueToken()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, HashOpaqueToken(token))
+	assert.NotEqual(t, token, hash)
+
+	other, _, err := NewOpaqueToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// PasswordHasher hashes passwords and verifies them against a stored hash.
+// Verify reports whether the stored hash should be replaced by a fresh Hash
+// (weaker parameters, older algorithm or a legacy plaintext value).
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(encoded, password string) (match bool, needsRehash bool, err error)
+}
+
+// BcryptHasher stores hashes in the standard $2a$<cost>$... format
+type BcryptHasher struct {
+	Cost int
+}
+
+func NewBcryptHasher(cost int) BcryptHasher {
+	if cost < bcrypt.MinCost {
+		cost = bcrypt.DefaultCost
+	}
+	return BcryptHasher{Cost: cost}
+}
+
+func (b BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %v", err)
+	}
+	return string(hash), nil
+}
+
+func (b BcryptHasher) Verify(encoded, password string) (bool, bool, error) {
+	if !strings.HasPrefix(encoded, "$2a$") && !strings.HasPrefix(encoded, "$2b$") && !strings.HasPrefix(encoded, "$2y$") {
+		return false, false, ErrUnknownHashFormat
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return false, false, err
+	}
+	return true, cost < b.Cost, nil
+}
+
+// Argon2idHasher stores hashes in the PHC string format:
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>
+type Argon2idHasher struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+func NewArgon2idHasher() Argon2idHasher {
+	// parameters recommended by RFC 9106 for memory constrained environments
+	return Argon2idHasher{Time: 3, Memory: 64 * 1024, Threads: 2, KeyLen: 32, SaltLen: 16}
+}
+
+func (a Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %v", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Time, a.Memory, a.Threads, a.KeyLen)
+
+	b64 := base64.RawStdEncoding
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Time, a.Threads, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+func (a Argon2idHasher) Verify(encoded, password string) (bool, bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, false, err
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false, nil
+	}
+	needsRehash := params.Time < a.Time || params.Memory < a.Memory || params.Threads < a.Threads ||
+		uint32(len(key)) < a.KeyLen || uint32(len(salt)) < a.SaltLen
+	return true, needsRehash, nil
+}
+
+func decodeArgon2id(encoded string) (Argon2idHasher, []byte, []byte, error) {
+	var params Argon2idHasher
+	parts := strings.Split(encoded, "$") // "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id version: %v", err)
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: %v", err)
+	}
+
+	b64 := base64.RawStdEncoding
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %v", err)
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id key: %v", err)
+	}
+	params.SaltLen = uint32(len(salt))
+	params.KeyLen = uint32(len(key))
+	return params, salt, key, nil
+}
+
+// UpgradingHasher hashes with Preferred and still accepts hashes produced by
+// the Legacy hashers, flagging them for rehash. Stored values that no hasher
+// recognises are treated as legacy plaintext when AllowPlaintext is set.
+type UpgradingHasher struct {
+	Preferred      PasswordHasher
+	Legacy         []PasswordHasher
+	AllowPlaintext bool
+}
+
+// NewPasswordHasher returns the hasher used for user accounts: argon2id for
+// new hashes, bcrypt and plaintext rows upgraded on the next successful login.
+func NewPasswordHasher() PasswordHasher {
+	return UpgradingHasher{
+		Preferred:      NewArgon2idHasher(),
+		Legacy:         []PasswordHasher{NewBcryptHasher(bcrypt.DefaultCost)},
+		AllowPlaintext: true,
+	}
+}
+
+func (u UpgradingHasher) Hash(password string) (string, error) {
+	return u.Preferred.Hash(password)
+}
+
+func (u UpgradingHasher) Verify(encoded, password string) (bool, bool, error) {
+	match, needsRehash, err := u.Preferred.Verify(encoded, password)
+	if err != ErrUnknownHashFormat {
+		return match, needsRehash, err
+	}
+
+	for _, legacy := range u.Legacy {
+		match, _, err := legacy.Verify(encoded, password)
+		if err == ErrUnknownHashFormat {
+			continue
+		}
+		return match, match, err
+	}
+
+	if !u.AllowPlaintext || encoded == "" || strings.HasPrefix(encoded, "$") {
+		return false, false, ErrUnknownHashFormat
+	}
+	match = subtle.ConstantTimeCompare([]byte(encoded), []byte(password)) == 1
+	return match, match, nil
+}
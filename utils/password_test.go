@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBcryptHasher(t *testing.T) {
+	hasher := NewBcryptHasher(5)
+
+	hash, err := hasher.Hash("abhay@123")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$2a$05$"))
+
+	t.Run("Match", func(t *testing.T) {
+		match, needsRehash, err := hasher.Verify(hash, "abhay@123")
+		assert.NoError(t, err)
+		assert.True(t, match)
+		assert.False(t, needsRehash)
+	})
+	t.Run("Mismatch", func(t *testing.T) {
+		match, _, err := hasher.Verify(hash, "wrong")
+		assert.NoError(t, err)
+		assert.False(t, match)
+	})
+	t.Run("Weaker cost", func(t *testing.T) {
+		match, needsRehash, err := NewBcryptHasher(6).Verify(hash, "abhay@123")
+		assert.NoError(t, err)
+		assert.True(t, match)
+		assert.True(t, needsRehash)
+	})
+	t.Run("Unknown format", func(t *testing.T) {
+		_, _, err := hasher.Verify("abhay@123", "abhay@123")
+		assert.Equal(t, ErrUnknownHashFormat, err)
+	})
+}
+
+func TestArgon2idHasher(t *testing.T) {
+	hasher := Argon2idHasher{Time: 1, Memory: 1024, Threads: 1, KeyLen: 32, SaltLen: 16}
+
+	hash, err := hasher.Hash("abhay@123")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	t.Run("Match", func(t *testing.T) {
+		match, needsRehash, err := hasher.Verify(hash, "abhay@123")
+		assert.NoError(t, err)
+		assert.True(t, match)
+		assert.False(t, needsRehash)
+	})
+	t.Run("Mismatch", func(t *testing.T) {
+		match, _, err := hasher.Verify(hash, "wrong")
+		assert.NoError(t, err)
+		assert.False(t, match)
+	})
+	t.Run("Weaker parameters", func(t *testing.T) {
+		stronger := hasher
+		stronger.Time = 2
+		match, needsRehash, err := stronger.Verify(hash, "abhay@123")
+		assert.NoError(t, err)
+		assert.True(t, match)
+		assert.True(t, needsRehash)
+	})
+	t.Run("Salted", func(t *testing.T) {
+		other, err := hasher.Hash("abhay@123")
+		assert.NoError(t, err)
+		assert.NotEqual(t, hash, other)
+	})
+	t.Run("Malformed", func(t *testing.T) {
+		_, _, err := hasher.Verify("$argon2id$v=19$m=x$salt$key", "abhay@123")
+		assert.Error(t, err)
+	})
+}
+
+func TestUpgradingHasher(t *testing.T) {
+	argon := Argon2idHasher{Time: 1, Memory: 1024, Threads: 1, KeyLen: 32, SaltLen: 16}
+	bcryptHasher := NewBcryptHasher(4)
+	hasher := UpgradingHasher{Preferred: argon, Legacy: []PasswordHasher{bcryptHasher}, AllowPlaintext: true}
+
+	t.Run("Preferred", func(t *testing.T) {
+		hash, err := hasher.Hash("abhay@123")
+		assert.NoError(t, err)
+		match, needsRehash, err := hasher.Verify(hash, "abhay@123")
+		assert.NoError(t, err)
+		assert.True(t, match)
+		assert.False(t, needsRehash)
+	})
+	t.Run("Legacy bcrypt", func(t *testing.T) {
+		hash, err := bcryptHasher.Hash("abhay@123")
+		assert.NoError(t, err)
+		match, needsRehash, err := hasher.Verify(hash, "abhay@123")
+		assert.NoError(t, err)
+		assert.True(t, match)
+		assert.True(t, needsRehash)
+	})
+	t.Run("Legacy plaintext", func(t *testing.T) {
+		match, needsRehash, err := hasher.Verify("abhay@123", "abhay@123")
+		assert.NoError(t, err)
+		assert.True(t, match)
+		assert.True(t, needsRehash)
+
+		match, needsRehash, err = hasher.Verify("abhay@123", "wrong")
+		assert.NoError(t, err)
+		assert.False(t, match)
+		assert.False(t, needsRehash)
+	})
+	t.Run("Plaintext disabled", func(t *testing.T) {
+		strict := hasher
+		strict.AllowPlaintext = false
+		match, _, err := strict.Verify("abhay@123", "abhay@123")
+		assert.Error(t, err)
+		assert.False(t, match)
+	})
+}
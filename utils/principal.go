@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"context"
+	"ecommerce/models"
+	"time"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID    int
+	Username  string
+	Roles     []models.Role
+	TokenID   string    // jti of the access token
+	ExpiresAt time.Time // exp of the access token
+}
+
+type principalKey struct{}
+
+func NewPrincipal(claims *Claims) *Principal {
+	principal := &Principal{
+		UserID:   claims.UserID,
+		Username: claims.Username,
+		Roles:    claims.Roles,
+		TokenID:  claims.ID,
+	}
+	if claims.ExpiresAt != nil {
+		principal.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return principal
+}
+
+func (p *Principal) HasRole(role models.Role) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) Can(permission models.Permission) bool {
+	for _, r := range p.Roles {
+		if r.Can(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// Owns reports whether the principal is the owner of a resource belonging to userID
+func (p *Principal) Owns(userID int) bool {
+	return p.UserID != 0 && p.UserID == userID
+}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the caller stored by the Auth middleware, ok is false for anonymous requests
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
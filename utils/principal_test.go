@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"context"
+	"ecommerce/models"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipalContext(t *testing.T) {
+	t.Run("Anonymous", func(t *testing.T) {
+		principal, ok := PrincipalFromContext(context.Background())
+		assert.False(t, ok)
+		assert.Nil(t, principal)
+	})
+	t.Run("Authenticated", func(t *testing.T) {
+		claims := &Claims{UserID: 3, Username: "abhay123", Roles: []models.Role{models.RoleStaff}}
+		claims.ID = "jti-1"
+		claims.ExpiresAt = jwt.NewNumericDate(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+		ctx := WithPrincipal(context.Background(), NewPrincipal(claims))
+
+		principal, ok := PrincipalFromContext(ctx)
+		assert.True(t, ok)
+		assert.Equal(t, 3, principal.UserID)
+		assert.Equal(t, "abhay123", principal.Username)
+		assert.Equal(t, "jti-1", principal.TokenID)
+		assert.True(t, principal.ExpiresAt.Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)))
+	})
+}
+
+func TestPrincipalChecks(t *testing.T) {
+	principal := &Principal{UserID: 3, Roles: []models.Role{models.RoleStaff}}
+
+	assert.True(t, principal.HasRole(models.RoleStaff))
+	assert.False(t, principal.HasRole(models.RoleAdmin))
+	assert.True(t, principal.Can(models.PermUserRead))
+	assert.False(t, principal.Can(models.PermProductWrite))
+	assert.True(t, principal.Owns(3))
+	assert.False(t, principal.Owns(4))
+	assert.False(t, (&Principal{}).Owns(0))
+}
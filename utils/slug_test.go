@@ -0,0 +1,13 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlugify(t *testing.T) {
+	assert.Equal(t, "men-s-shoes", Slugify("Men's Shoes"))
+	assert.Equal(t, "tv-audio", Slugify("  TV & Audio  "))
+	assert.Equal(t, "", Slugify("!!!"))
+}
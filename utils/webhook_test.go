@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	secret := []byte("whsec_test")
+	body := []byte(`{"id":"evt_1","type":"payment.captured"}`)
+	sentAt := time.Unix(1700000000, 0)
+	header := SignWebhook(secret, sentAt, body)
+
+	t.Run("Valid", func(t *testing.T) {
+		assert.NoError(t, VerifyWebhook(secret, header, body, sentAt.Add(time.Minute), 5*time.Minute))
+	})
+	t.Run("Tampered body", func(t *testing.T) {
+		err := VerifyWebhook(secret, header, []byte(`{"id":"evt_1","type":"payment.refunded"}`), sentAt, 5*time.Minute)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+	t.Run("Wrong secret", func(t *testing.T) {
+		assert.ErrorIs(t, VerifyWebhook([]byte("other"), header, body, sentAt, 5*time.Minute), ErrInvalidSignature)
+	})
+	t.Run("Too old", func(t *testing.T) {
+		assert.ErrorIs(t, VerifyWebhook(secret, header, body, sentAt.Add(10*time.Minute), 5*time.Minute), ErrInvalidSignature)
+	})
+	t.Run("Malformed or unconfigured", func(t *testing.T) {
+		assert.ErrorIs(t, VerifyWebhook(secret, "v1=abc", body, sentAt, 5*time.Minute), ErrInvalidSignature)
+		assert.ErrorIs(t, VerifyWebhook(nil, header, body, sentAt, 5*time.Minute), ErrInvalidSignature)
+	})
+}